@@ -22,10 +22,12 @@ package activity
 
 import (
 	"context"
+	"io"
 
 	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 
+	"go.uber.org/cadence/client"
 	"go.uber.org/cadence/internal"
 )
 
@@ -41,6 +43,9 @@ type (
 
 	// RegistryInfo
 	RegistryInfo = internal.RegistryActivityInfo
+
+	// TaskTokenHandle identifies an activity completed asynchronously, returned by MarkAsyncCompletion.
+	TaskTokenHandle = internal.TaskTokenHandle
 )
 
 // ErrResultPending is returned from activity's implementation to indicate the activity is not completed when
@@ -61,8 +66,73 @@ type (
 // If you cannot heartbeat and cannot tolerate this kind of delayed-activity-loss detection, consider emulating a long
 // activity via a signal channel instead: you can start a short-lived activity and wait for a "saved to external system"
 // signal, retrying as necessary, and then wait for an "external system finished" signal containing the final result.
+//
+// Prefer [MarkAsyncCompletion], which opts in to the asynchronous completion explicitly. ErrResultPending is kept for
+// compatibility.
 var ErrResultPending = internal.ErrActivityResultPending
 
+// ErrHeartbeatDetailsTooLarge is matched by errors.Is for the *HeartbeatDetailsTooLargeError returned by
+// RecordHeartbeatWithError and client.Client.RecordActivityHeartbeat when the serialized heartbeat details are larger
+// than worker.Options.MaxHeartbeatDetailsSize. The server would fail the activity otherwise.
+var ErrHeartbeatDetailsTooLarge = internal.ErrHeartbeatDetailsTooLarge
+
+// ErrHeartbeatDetailsTruncated is returned by GetHeartbeatDetails when the previous attempt recorded details which were
+// too large, and were dropped as worker.Options.TruncateLargeHeartbeatDetails is set.
+var ErrHeartbeatDetailsTruncated = internal.ErrHeartbeatDetailsTruncated
+
+// ErrNotReady is returned by an activity polling an external system which is not ready yet. The activity is retried
+// by the server according to its retry policy, see workflow.ExecutePollingActivity.
+var ErrNotReady = internal.ErrActivityNotReady
+
+// The causes of the cancellation of an activity context, returned by context.Cause, so that the cleanup of an activity
+// can depend on why it is canceled:
+//
+//	<-ctx.Done()
+//	switch cause := context.Cause(ctx); {
+//	case errors.Is(cause, activity.ErrWorkerShutdown):
+//		// release the work to be retried by another worker
+//	case errors.Is(cause, activity.ErrCancelRequested):
+//		// undo the work
+//	}
+//
+// The cancellation of the activity, or of its workflow, and the closing of its workflow are detected by heartbeats,
+// so that only activities recording heartbeats are canceled with ErrCancelRequested, ErrWorkflowClosed or
+// ErrDomainNotActive. The context of an activity exceeding its timeout is canceled with context.DeadlineExceeded.
+var (
+	// ErrWorkerShutdown is the cause of the cancellation of the activities running when their worker is stopped.
+	ErrWorkerShutdown = internal.ErrActivityWorkerShutdown
+	// ErrCancelRequested is the cause of the cancellation of an activity canceled by its workflow, or whose workflow
+	// is canceled.
+	ErrCancelRequested = internal.ErrActivityCancelRequested
+	// ErrWorkflowClosed is the cause of the cancellation of an activity whose workflow is closed, e.g. completed,
+	// terminated or timed out, wrapped with the error returned by the server.
+	ErrWorkflowClosed = internal.ErrActivityWorkflowClosed
+	// ErrDomainNotActive is the cause of the cancellation of an activity whose domain failed over to another
+	// cluster, wrapped with the error returned by the server.
+	ErrDomainNotActive = internal.ErrActivityDomainNotActive
+)
+
+// HeartbeatDetailsTooLargeError is returned when heartbeat details are too large, with their serialized size.
+type HeartbeatDetailsTooLargeError = internal.HeartbeatDetailsTooLargeError
+
+type (
+	// ChunkWriter streams a large result to a cadence.BlobSink in chunks, and returns the cadence.LazyValue
+	// referencing them when closed.
+	ChunkWriter = internal.ChunkWriter
+
+	// ChunkWriterOptions configures a ChunkWriter.
+	ChunkWriterOptions = internal.ChunkWriterOptions
+
+	// BlobSink stores the chunks written by a ChunkWriter, it is the same type as cadence.BlobSink.
+	BlobSink = internal.BlobSink
+
+	// LazyValue references the chunks written by a ChunkWriter, it is the same type as cadence.LazyValue.
+	LazyValue = internal.LazyValue
+)
+
+// ErrChunkWriterClosed is returned when writing to a closed ChunkWriter.
+var ErrChunkWriterClosed = internal.ErrChunkWriterClosed
+
 // Register - calls RegisterWithOptions with default registration options.
 // Deprecated: Global activity registration methods are replaced by equivalent Worker instance methods.
 // This method is kept to maintain backward compatibility and should not be used.
@@ -117,6 +187,13 @@ func GetMetricsScope(ctx context.Context) tally.Scope {
 	return internal.GetActivityMetricsScope(ctx)
 }
 
+// GetClient returns worker.Options.ActivityClient, or by default a client of the domain of the worker running the
+// activity, e.g. to signal or start workflows. Activities should use it instead of creating a client per call.
+// In unit tests, set it with TestActivityEnvironment.SetTestClient or TestWorkflowEnvironment.SetTestClient.
+func GetClient(ctx context.Context) client.Client {
+	return internal.GetActivityClient(ctx)
+}
+
 // RecordHeartbeat sends heartbeat for the currently executing activity
 // If the activity is either cancelled (or) workflow/activity doesn't exist then we would cancel
 // the context with error context.Canceled.
@@ -127,6 +204,13 @@ func RecordHeartbeat(ctx context.Context, details ...interface{}) {
 	internal.RecordActivityHeartbeat(ctx, details...)
 }
 
+// RecordHeartbeatWithError is RecordHeartbeat returning the error of the heartbeat, e.g. a
+// *HeartbeatDetailsTooLargeError when the details are too large to be recorded, or the error of encoding the details.
+// Heartbeats are batched, and errors of heartbeats sent later by the batch are not returned.
+func RecordHeartbeatWithError(ctx context.Context, details ...interface{}) error {
+	return internal.RecordActivityHeartbeatWithError(ctx, details...)
+}
+
 // HasHeartbeatDetails checks if there is heartbeat details from last attempt.
 func HasHeartbeatDetails(ctx context.Context) bool {
 	return internal.HasHeartbeatDetails(ctx)
@@ -142,6 +226,47 @@ func GetHeartbeatDetails(ctx context.Context, d ...interface{}) error {
 	return internal.GetHeartbeatDetails(ctx, d...)
 }
 
+// MarkAsyncCompletion marks the activity as completed asynchronously, and returns the handle to complete it with,
+// which can be serialized and sent to the external system completing the activity. Once marked, the activity is not
+// completed when it returns a nil error, and its result is ignored, so returning [ErrResultPending] is not needed; an
+// activity returning an error still fails, e.g. when it could not send the handle:
+//
+//	handle, err := activity.MarkAsyncCompletion(ctx)
+//	if err != nil {
+//		return "", err
+//	}
+//	if err := approvals.Enqueue(ctx, handle); err != nil {
+//		return "", err
+//	}
+//	return "", nil
+//
+// The external system then calls client.Client.CompleteActivity with handle.TaskToken.
+// Local activities cannot be completed asynchronously, MarkAsyncCompletion returns an error for them.
+func MarkAsyncCompletion(ctx context.Context) (TaskTokenHandle, error) {
+	return internal.MarkActivityAsyncCompletion(ctx)
+}
+
+// ReportCancellation records the details of the cancellation of the activity, and returns a *cadence.CanceledError
+// with these details. Call it once the activity observed that its context is done: when the activity then returns an
+// error, e.g. the returned error or ctx.Err(), the worker reports the activity as canceled with the details, and the
+// workflow receives a *cadence.CanceledError whose Details decode them:
+//
+//	select {
+//	case <-ctx.Done():
+//		return activity.ReportCancellation(ctx, Progress{Processed: processed})
+//	case ...
+//	}
+//
+//	// in the workflow
+//	var canceledErr *cadence.CanceledError
+//	if errors.As(err, &canceledErr) {
+//		var progress Progress
+//		_ = canceledErr.Details(&progress)
+//	}
+func ReportCancellation(ctx context.Context, details ...interface{}) error {
+	return internal.ReportActivityCancellation(ctx, details...)
+}
+
 // GetWorkerStopChannel returns a read-only channel. The closure of this channel indicates the activity worker is stopping.
 // When the worker is stopping, it will close this channel and wait until the worker stop timeout finishes. After the timeout
 // hit, the worker will cancel the activity context and then exit. The timeout can be defined by worker option: WorkerStopTimeout.
@@ -149,3 +274,20 @@ func GetHeartbeatDetails(ctx context.Context, d ...interface{}) error {
 func GetWorkerStopChannel(ctx context.Context) <-chan struct{} {
 	return internal.GetWorkerStopChannel(ctx)
 }
+
+// NewChunkWriter creates a ChunkWriter writing to sink. By default, the keys of the chunks are prefixed with the
+// workflow ID, run ID and activity ID of the activity.
+func NewChunkWriter(ctx context.Context, sink BlobSink, options ChunkWriterOptions) *ChunkWriter {
+	return internal.NewChunkWriter(ctx, sink, options)
+}
+
+// WriteLazyValue streams r to sink and returns the LazyValue referencing the chunks written.
+func WriteLazyValue(ctx context.Context, sink BlobSink, r io.Reader, options ChunkWriterOptions) (LazyValue, error) {
+	return internal.WriteLazyValue(ctx, sink, r, options)
+}
+
+// EncodeLazyValue encodes value with the data converter of the activity, writes it to sink and returns the
+// LazyValue referencing the chunks written. The consuming activities decode it with LazyValue.Decode.
+func EncodeLazyValue(ctx context.Context, sink BlobSink, value interface{}, options ChunkWriterOptions) (LazyValue, error) {
+	return internal.EncodeLazyValue(ctx, sink, value, options)
+}
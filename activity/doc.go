@@ -146,15 +146,20 @@ There are 2 parts to implementing an asynchronously completed activity. The firs
 the information necessary to be able to be completed from an external system and notify the Cadence service that it is
 waiting for that outside callback:
 
-	// retrieve activity information needed to complete activity asynchronously
-	activityInfo := activity.GetInfo(ctx)
-	taskToken := activityInfo.TaskToken
+	// mark the activity as completed asynchronously, and retrieve the information needed to complete it
+	handle, err := activity.MarkAsyncCompletion(ctx)
+	if err != nil {
+		return "", err
+	}
+	taskToken := handle.TaskToken
 
 	// send the taskToken to external service that will complete the activity
 	...
 
-	// return from activity function indicating the Cadence should wait for an async completion message
-	return "", activity.ErrResultPending
+	// return from activity function, Cadence waits for an async completion message
+	return "", nil
+
+Returning activity.ErrResultPending without calling MarkAsyncCompletion is still supported for compatibility.
 
 The second part is then for the external service to call the Cadence service to complete the activity. To complete the
 activity successfully you would do the following:
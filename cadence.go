@@ -42,6 +42,7 @@ The root cadence package contains common data structures. The subpackages are:
   - worker - functions used to create worker instance used to host workflow and
     activity code.
   - testsuite - unit testing framework for activity and workflow testing
+  - retry - retry policy presets and validation
 
 # How Cadence works
 
@@ -29,8 +29,11 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 
+	"go.uber.org/yarpc/api/transport"
+
 	"go.uber.org/cadence"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	s "go.uber.org/cadence/.gen/go/shared"
@@ -51,12 +54,43 @@ const (
 	// QueryTypeQueryTypes is the build in query type for Client.QueryWorkflow() call. Use this query type to list
 	// all query types of the workflow. The result will be a string encoded in the EncodedValue.
 	QueryTypeQueryTypes string = internal.QueryTypeQueryTypes
+
+	// QueryTypePendingOperations is the build in query type for Client.QueryWorkflow() call. Use this query type to
+	// list the activities, timers and child workflows the workflow is waiting for. The result will be a
+	// PendingOperations encoded in the encoded.Value.
+	QueryTypePendingOperations string = internal.QueryTypePendingOperations
+
+	// QueryTypeRecentLogs is the build in query type for Client.QueryWorkflow() call. Use this query type to get the
+	// latest entries of the workflow logger, see worker.Options.WorkflowLogBufferSize. An optional int argument limits
+	// the number of entries. The result will be a list of RecentLogEntry, oldest first, encoded in the encoded.Value.
+	QueryTypeRecentLogs string = internal.QueryTypeRecentLogs
+
+	// QueryTypeMetadata is the build in query type for Client.QueryWorkflow() call. Use this query type to get the
+	// query types, the signal channels and the status text of the workflow, see workflow.SetStatusText. The result
+	// will be a WorkflowMetadata encoded in the EncodedValue.
+	QueryTypeMetadata string = internal.QueryTypeMetadata
 )
 
 type (
 	// Options are optional parameters for Client creation.
 	Options = internal.ClientOptions
 
+	// TLSOptions configures TLS connections to the Cadence frontend, see NewTLSConfig.
+	TLSOptions = internal.TLSOptions
+
+	// PayloadLogger logs sampled requests and responses of service calls, see NewPayloadLogger.
+	PayloadLogger = internal.PayloadLogger
+
+	// PayloadLoggerOptions configures a PayloadLogger.
+	PayloadLoggerOptions = internal.PayloadLoggerOptions
+
+	// ThrottleInfo describes a service call rejected because of rate limiting or overload, passed to
+	// Options.OnThrottle. Options.OnThrottle is called on the goroutine of the call and must not block.
+	ThrottleInfo = internal.ThrottleInfo
+
+	// MetricsOptions configures the prefix, tags and sanitizer applied to the client metrics scope.
+	MetricsOptions = internal.MetricsOptions
+
 	// FeatureFlags define which breaking changes can be enabled for client
 	FeatureFlags = internal.FeatureFlags
 
@@ -69,6 +103,23 @@ type (
 	// WorkflowRun represents a started non child workflow
 	WorkflowRun = internal.WorkflowRun
 
+	// PendingOperations is the result of the QueryTypePendingOperations query.
+	PendingOperations = internal.PendingOperations
+	// PendingActivity is an activity listed by the QueryTypePendingOperations query.
+	PendingActivity = internal.PendingActivity
+	// PendingTimer is a timer listed by the QueryTypePendingOperations query.
+	PendingTimer = internal.PendingTimer
+	// PendingChildWorkflow is a child workflow listed by the QueryTypePendingOperations query.
+	PendingChildWorkflow = internal.PendingChildWorkflow
+
+	// RecentLogEntry is a log entry returned by the QueryTypeRecentLogs query.
+	RecentLogEntry = internal.RecentLogEntry
+
+	// WorkflowMetadata is the result of the QueryTypeMetadata query.
+	WorkflowMetadata = internal.WorkflowMetadata
+	// SignalChannelMetadata is a signal channel listed by the QueryTypeMetadata query.
+	SignalChannelMetadata = internal.SignalChannelMetadata
+
 	// WorkflowIDReusePolicy defines workflow ID reuse behavior.
 	WorkflowIDReusePolicy = internal.WorkflowIDReusePolicy
 
@@ -138,6 +189,13 @@ type (
 		//	- InternalServiceError
 		StartWorkflowAsync(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (*workflow.ExecutionAsync, error)
 
+		// StartWorkflowValidated behaves like StartWorkflow, but first validates the options, the workflow and its
+		// arguments against the default limits of the server: timeouts, task list, ID length and reuse policy, retry
+		// policy, cron expression, types and sizes of memo and search attributes, and size of the arguments.
+		// All the problems found are returned at once before contacting the server, combined with multierr:
+		//	for _, problem := range multierr.Errors(err) { ... }
+		StartWorkflowValidated(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (*workflow.Execution, error)
+
 		// ExecuteWorkflow starts a workflow execution and return a WorkflowRun instance and error
 		// The user can use this to start using a function or workflow type name.
 		// Either by
@@ -196,6 +254,8 @@ type (
 		// If the workflow is not running or not found, it starts the workflow and then sends the signal in transaction.
 		// - workflowID, signalName, signalArg are same as SignalWorkflow's parameters
 		// - options, workflow, workflowArgs are same as StartWorkflow's parameters
+		// options.RequestID is passed to the server as the request ID, but the client does not deduplicate the signal of
+		// a retried call: workflows must deduplicate signals which can be retried, see StartWorkflowOptions.RequestID.
 		// The errors it can return:
 		//  - EntityNotExistsError, if domain does not exist
 		//  - BadRequestError
@@ -309,6 +369,7 @@ type (
 		// The errors it can return:
 		//	- EntityNotExistsError
 		//	- InternalServiceError
+		//	- *activity.HeartbeatDetailsTooLargeError, if the serialized details are larger than 2MB
 		RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error
 
 		// RecordActivityHeartbeatByID records heartbeat for an activity.
@@ -316,6 +377,7 @@ type (
 		// The errors it can return:
 		//	- EntityNotExistsError
 		//	- InternalServiceError
+		//	- *activity.HeartbeatDetailsTooLargeError, if the serialized details are larger than 2MB
 		RecordActivityHeartbeatByID(ctx context.Context, domain, workflowID, runID, activityID string, details ...interface{}) error
 
 		// ListClosedWorkflow gets closed workflow executions based on request filters.
@@ -539,6 +601,48 @@ func NewDomainClient(service workflowserviceclient.Interface, options *Options)
 	return internal.NewDomainClient(service, options)
 }
 
+// NewTLSConfig returns the TLS configuration of a connection to the Cadence frontend, which is passed to the
+// transport creating the service of NewClient and worker.New. NewGRPCOutbound builds the gRPC outbound with it,
+// and for TChannel, e.g. through a TLS terminating proxy:
+//
+//	tchannelTransport, err := tchannel.NewTransport(tchannel.ServiceName(name), tchannel.Dialer((&tls.Dialer{Config: tlsConfig}).DialContext))
+//
+// With TLSOptions.ReloadInterval, the files are read again periodically and new connections use the rotated
+// certificates. The x/config package builds the transport from a configuration file.
+func NewTLSConfig(options TLSOptions) (*tls.Config, error) {
+	return internal.NewTLSConfig(options)
+}
+
+// NewGRPCOutbound returns a gRPC outbound to the Cadence frontend at hostPort using TLS configured by options, see
+// NewTLSConfig. It is used in the YARPC dispatcher creating the service of NewClient and worker.New:
+//
+//	outbound, err := client.NewGRPCOutbound(hostPort, client.TLSOptions{CAFile: "ca.pem", CertFile: "client.pem", KeyFile: "client-key.pem"})
+//	...
+//	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+//	  Name:      callerName,
+//	  Outbounds: yarpc.Outbounds{"cadence-frontend": {Unary: outbound}},
+//	})
+func NewGRPCOutbound(hostPort string, options TLSOptions) (transport.UnaryOutbound, error) {
+	return internal.NewGRPCOutbound(hostPort, options)
+}
+
+// NewPayloadLogger returns a logger of the requests and responses of the service calls of the clients and workers it
+// is set on with Options.PayloadLogger and worker.Options.PayloadLogger, to diagnose encoding or server compatibility
+// issues without capturing the traffic:
+//
+//	payloadLogger := client.NewPayloadLogger(client.PayloadLoggerOptions{Logger: logger, SampleRate: 0.01})
+//	cadenceClient := client.NewClient(service, domain, &client.Options{PayloadLogger: payloadLogger})
+//	...
+//	payloadLogger.SetEnabled(true) // e.g. from a debug endpoint
+//
+// It is disabled until SetEnabled(true) is called, or PayloadLoggerOptions.Enabled is set. Calls are logged at info
+// level as JSON, truncated to PayloadLoggerOptions.MaxSize. The encoded inputs, results and details are logged as
+// their size unless PayloadLoggerOptions.IncludePayloads is set, and PayloadLoggerOptions.RedactFields hides
+// further fields.
+func NewPayloadLogger(options PayloadLoggerOptions) *PayloadLogger {
+	return internal.NewPayloadLogger(options)
+}
+
 // make sure if new methods are added to internal.Client they are also added to public Client.
 var _ Client = internal.Client(nil)
 var _ internal.Client = Client(nil)
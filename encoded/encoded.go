@@ -31,6 +31,16 @@ type (
 	// Values is used to encapsulate/extract encoded one or more values from workflow/activity.
 	Values = internal.Values
 
+	// Payload is implemented by the Value and Values of the client, to introspect the encoded payloads without
+	// decoding them, e.g. in routers and interceptors:
+	//
+	//	if payload, ok := value.(encoded.Payload); ok {
+	//		size, types := payload.Size(), payload.TypeHints()
+	//	}
+	//
+	// It is not part of Value and Values, which can be implemented outside of the client.
+	Payload = internal.Payload
+
 	// DataConverter is used by the framework to serialize/deserialize input and output of activity/workflow
 	// that need to be sent over the wire.
 	// To encode/decode workflow arguments, one should set DataConverter in two places:
@@ -42,9 +52,35 @@ type (
 	// Cadence support using different DataConverters for different activity/childWorkflow in same workflow.
 	//   2. Activity/Workflow worker that run these activity/childWorkflow, through worker.Options.
 	DataConverter = internal.DataConverter
+
+	// DataConverterOptions configures the JSON encoding of the default data converter, see NewDefaultDataConverter.
+	DataConverterOptions = internal.DataConverterOptions
 )
 
 // GetDefaultDataConverter return default data converter used by Cadence worker
 func GetDefaultDataConverter() DataConverter {
 	return internal.DefaultDataConverter
 }
+
+// NewDefaultDataConverter returns the default data converter with options, which encodes thrift values with thrift
+// and everything else with JSON. With the zero options, it is equivalent to GetDefaultDataConverter.
+//
+// Changing the options of a running workflow may make it fail to decode its history, e.g. the times encoded with a
+// previous TimeFormat: combine the new data converter with the previous one using NewFallbackDataConverter.
+func NewDefaultDataConverter(options DataConverterOptions) DataConverter {
+	return internal.NewDefaultDataConverter(options)
+}
+
+// NewFallbackDataConverter returns a DataConverter which encodes with primary, and decodes with the first of primary
+// and fallbacks, in order, that succeeds. It allows migrating payload encodings gradually: the data recorded with the
+// previous encoding by running workflows and in-flight activities is still decoded by its fallback, while new data
+// is encoded with primary. The default data converter is used when primary is nil.
+//
+// The converters must fail to decode the payloads of the other encodings, e.g. the default JSON data converter fails
+// to decode gob payloads, otherwise the fallbacks are not tried. The values are reset before each fallback is tried.
+//
+// As with any DataConverter, it must be set on both the clients and workers, and the fallbacks are only removed once
+// no workflow with data encoded by them can be replayed anymore.
+func NewFallbackDataConverter(primary DataConverter, fallbacks ...DataConverter) DataConverter {
+	return internal.NewFallbackDataConverter(primary, fallbacks...)
+}
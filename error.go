@@ -21,6 +21,8 @@
 package cadence
 
 import (
+	"errors"
+
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal"
 	"go.uber.org/cadence/workflow"
@@ -35,6 +37,9 @@ type (
 
 	// NonDeterministicError is returned when a workflow's replay was non-deterministic, and it could not be resumed safely.
 	NonDeterministicError = internal.NonDeterministicError
+
+	// RegisterFailureTypeOptions consists of options for registering a failure type.
+	RegisterFailureTypeOptions = internal.RegisterFailureTypeOptions
 )
 
 // ErrNoData is returned when trying to extract strong typed data while there is no data available.
@@ -46,6 +51,41 @@ func NewCustomError(reason string, details ...interface{}) *CustomError {
 	return internal.NewCustomError(reason, details...)
 }
 
+// RegisterFailureType registers the type of failure, so that a workflow or an activity failing with an error of this
+// type, or wrapping one, records it in the history as a *CustomError whose reason is the name of the type and whose
+// details are the failure encoded by the data converter. When the failure is received, by a workflow from an activity
+// or child workflow, or by a client from WorkflowRun.Get or GetWorkflowResult, it is decoded back to the registered
+// type, which errors.As can extract from the returned *CustomError:
+//
+//	type InsufficientFundsError struct {
+//		Account string
+//		Missing int
+//	}
+//
+//	func (e *InsufficientFundsError) Error() string { ... }
+//
+//	func init() {
+//		cadence.RegisterFailureType(&InsufficientFundsError{})
+//	}
+//
+//	var insufficientFunds *InsufficientFundsError
+//	if err := run.Get(ctx, nil); errors.As(err, &insufficientFunds) {
+//		...
+//	}
+//
+// Only the exported fields of the failure are encoded by the default data converter. The type must be registered in
+// every process failing with it or decoding it, typically from an init function. It panics if the type or its name is
+// already registered.
+func RegisterFailureType(failure error) {
+	internal.RegisterFailureType(failure)
+}
+
+// RegisterFailureTypeWithOptions registers the type of failure with options, e.g. to name it differently than its Go
+// type, see RegisterFailureType.
+func RegisterFailureTypeWithOptions(failure error, options RegisterFailureTypeOptions) {
+	internal.RegisterFailureTypeWithOptions(failure, options)
+}
+
 // NewCanceledError creates CanceledError instance.
 // Return this error from activity or child workflow to indicate that it was successfully cancelled.
 func NewCanceledError(details ...interface{}) *CanceledError {
@@ -60,8 +100,8 @@ func IsCustomError(err error) bool {
 
 // IsWorkflowExecutionAlreadyStartedError return if the err is a WorkflowExecutionAlreadyStartedError
 func IsWorkflowExecutionAlreadyStartedError(err error) bool {
-	_, ok := err.(*shared.WorkflowExecutionAlreadyStartedError)
-	return ok
+	target := (*shared.WorkflowExecutionAlreadyStartedError)(nil)
+	return errors.As(err, &target)
 }
 
 // IsCanceledError return if the err is a CanceledError
@@ -28,6 +28,9 @@ require (
 	golang.org/x/net v0.19.0
 	golang.org/x/oauth2 v0.1.0
 	golang.org/x/time v0.0.0-20170927054726-6dc17368e09b
+	golang.org/x/tools v0.6.0
+	google.golang.org/grpc v1.28.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -56,13 +59,10 @@ require (
 	golang.org/x/mod v0.8.0 // indirect
 	golang.org/x/sys v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
-	golang.org/x/tools v0.6.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20200212174721-66ed5ce911ce // indirect
-	google.golang.org/grpc v1.28.0 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	honnef.co/go/tools v0.3.2 // indirect
 )
 
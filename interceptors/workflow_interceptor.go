@@ -41,4 +41,8 @@ type (
 	// WorkflowInterceptorBase is a noop implementation of WorkflowInterceptor that just forwards requests
 	// to the next link in an interceptor chain. To be used as base implementation of interceptors.
 	WorkflowInterceptorBase = internal.WorkflowInterceptorBase
+
+	// WorkflowQueryInterceptor can be implemented by a WorkflowInterceptor to intercept the queries answered by the
+	// workflow, including the ones whose type has no handler. HandleQuery must call next to answer the query.
+	WorkflowQueryInterceptor = internal.WorkflowQueryInterceptor
 )
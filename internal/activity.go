@@ -23,7 +23,9 @@
 package internal
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -63,6 +65,14 @@ type (
 		StartedTimestamp   time.Time     // Time of activity start
 		Deadline           time.Time     // Time of activity timeout
 		Attempt            int32         // Attempt starts from 0, and increased by 1 for every retry if retry policy is specified.
+		// WorkflowTaskList is the task list of the workflow which scheduled the activity. It and the parent below are
+		// only set when the worker of the workflow has WorkerOptions.EnableActivityCallerInfo set, and for local
+		// activities.
+		WorkflowTaskList string
+		// ParentWorkflowDomain and ParentWorkflowExecution identify the parent of the workflow which scheduled
+		// the activity, they are nil if that workflow is not a child workflow.
+		ParentWorkflowDomain    *string
+		ParentWorkflowExecution *WorkflowExecution
 	}
 
 	// RegisterActivityOptions consists of options for registering an activity
@@ -74,12 +84,39 @@ type (
 		// Activity type name is equal to function name instead of fully qualified
 		// name including function package (and struct type if used).
 		// This option has no effect when explicit Name is provided.
-		EnableShortName               bool
+		EnableShortName bool
+		// DisableAlreadyRegisteredCheck allows registering a name again, replacing the previous registration, and
+		// registering a name already registered with another function by another worker of the process polling the same
+		// task list.
 		DisableAlreadyRegisteredCheck bool
 		// Automatically send heartbeats for this activity at an interval that is less than the HeartbeatTimeout.
 		// This option has no effect if the activity is executed with a HeartbeatTimeout of 0.
 		// Default: false
 		EnableAutoHeartbeat bool
+		// Optional: extracts metric tags, e.g. tenant or region, from the activity input. The tags are added to the
+		// activity metrics emitted by the worker, such as execution latency and completed/failed counters.
+		// Tags named like the ones set by the worker (Domain, TaskList, WorkflowType, ActivityType) are ignored,
+		// at most 5 tags are kept and values are truncated to 64 characters.
+		// The function runs before every activity execution so it should be cheap and must not block.
+		MetricTagFunc func(args []interface{}) map[string]string
+		// Optional: maximum number of distinct values per tag returned by MetricTagFunc.
+		// Once reached, new values are reported as "_other".
+		// Default: 100
+		MetricTagCardinalityLimit int
+		// Optional: additional activity type names this activity is executed for, e.g. the names it was registered
+		// under before being renamed, so activities scheduled with an old name keep running.
+		// Only supported when registering an activity function.
+		Aliases []string
+		// Tolerate activity tasks with fewer arguments than the activity function accepts, e.g. scheduled before
+		// trailing parameters were added to it. The missing arguments are set to their zero values, and the
+		// activity-missing-args counter is emitted. Custom data converters have to return an error wrapping io.EOF
+		// when running out of arguments to decode, like the default one does.
+		// Default: false
+		AllowMissingTrailingArgs bool
+		// Optional: data converter of this activity, used instead of WorkerOptions.DataConverter to decode its input,
+		// encode its result and heartbeat details. The workflows scheduling it must use the same data converter, see
+		// WithDataConverter. It is not used when the activity is executed as a local activity.
+		DataConverter DataConverter
 	}
 
 	// ActivityOptions stores all activity-specific parameters that will be stored inside of a context.
@@ -221,6 +258,10 @@ func GetActivityInfo(ctx context.Context) ActivityInfo {
 		Attempt:            env.attempt,
 		WorkflowType:       env.workflowType,
 		WorkflowDomain:     env.workflowDomain,
+
+		WorkflowTaskList:        env.workflowTaskList,
+		ParentWorkflowDomain:    env.parentDomain,
+		ParentWorkflowExecution: env.parentExecution,
 	}
 }
 
@@ -245,6 +286,9 @@ func GetHeartbeatDetails(ctx context.Context, d ...interface{}) error {
 	if len(env.heartbeatDetails) == 0 {
 		return ErrNoData
 	}
+	if bytes.Equal(env.heartbeatDetails, heartbeatDetailsTruncatedMarker) {
+		return ErrHeartbeatDetailsTruncated
+	}
 	encoded := newEncodedValues(env.heartbeatDetails, env.dataConverter)
 	return encoded.Get(d...)
 }
@@ -261,6 +305,18 @@ func GetActivityMetricsScope(ctx context.Context) tally.Scope {
 	return env.metricsScope
 }
 
+// GetActivityClient returns WorkerOptions.ActivityClient, or by default a client of the domain of the worker running
+// the activity, e.g. to signal or start workflows. Activities should use it instead of creating a client per call.
+// In tests it is the client set with TestActivityEnvironment.SetTestClient or TestWorkflowEnvironment.SetTestClient.
+// It panics if no client is available.
+func GetActivityClient(ctx context.Context) Client {
+	env := getActivityEnv(ctx)
+	if env.client == nil {
+		panic("getActivityClient: no client available, use SetTestClient of the test environment in tests")
+	}
+	return env.client
+}
+
 // GetWorkerStopChannel returns a read-only channel. The closure of this channel indicates the activity worker is stopping.
 // When the worker is stopping, it will close this channel and wait until the worker stop timeout finishes. After the timeout
 // hit, the worker will cancel the activity context and then exit. The timeout can be defined by worker option: WorkerStopTimeout.
@@ -270,6 +326,46 @@ func GetWorkerStopChannel(ctx context.Context) <-chan struct{} {
 	return env.workerStopChannel
 }
 
+// TaskTokenHandle identifies an activity completed asynchronously, returned by MarkActivityAsyncCompletion.
+// It can be serialized, e.g. as JSON, and handed to the system completing the activity, which passes TaskToken to
+// Client.CompleteActivity, or the IDs to Client.CompleteActivityByID.
+type TaskTokenHandle struct {
+	TaskToken  []byte `json:"taskToken"`
+	Domain     string `json:"domain"`
+	WorkflowID string `json:"workflowID"`
+	RunID      string `json:"runID"`
+	ActivityID string `json:"activityID"`
+}
+
+// MarkActivityAsyncCompletion marks the currently executing activity as completed asynchronously, and returns the
+// handle to complete it with. Once marked, the worker does not complete the activity when it returns a nil error, and
+// the activity result is ignored; an activity returning an error still fails, e.g. when it could not hand the handle
+// over. Local activities cannot be completed asynchronously.
+func MarkActivityAsyncCompletion(ctx context.Context) (TaskTokenHandle, error) {
+	env := getActivityEnv(ctx)
+	if env.isLocalActivity {
+		return TaskTokenHandle{}, errors.New("local activities cannot be completed asynchronously")
+	}
+	env.asyncCompletion.Store(true)
+	return TaskTokenHandle{
+		TaskToken:  env.taskToken,
+		Domain:     env.workflowDomain,
+		WorkflowID: env.workflowExecution.ID,
+		RunID:      env.workflowExecution.RunID,
+		ActivityID: env.activityID,
+	}, nil
+}
+
+// ReportActivityCancellation records the details of the cancellation of the currently executing activity, and returns
+// a *CanceledError with these details. When the activity returns an error after calling it, e.g. the returned error or
+// ctx.Err(), the worker reports the activity as canceled with the details, which the workflow receives as a
+// *CanceledError whose Details decode them.
+func ReportActivityCancellation(ctx context.Context, details ...interface{}) error {
+	err := NewCanceledError(details...)
+	getActivityEnv(ctx).reportedCancellation.Store(err)
+	return err
+}
+
 // RecordActivityHeartbeat sends heartbeat for the currently executing activity
 // If the activity is either cancelled (or) workflow/activity doesn't exist then we would cancel
 // the context with error context.Canceled.
@@ -295,11 +391,24 @@ func RecordActivityHeartbeat(ctx context.Context, details ...interface{}) {
 			panic(err)
 		}
 	}
-	err = env.serviceInvoker.BatchHeartbeat(data)
-	if err != nil {
-		log := GetActivityLogger(ctx)
-		log.Debug("RecordActivityHeartbeat With Error:", zap.Error(err))
+	_ = env.recordHeartbeat(data)
+}
+
+// RecordActivityHeartbeatWithError docs are in the public API to prevent duplication: [go.uber.org/cadence/activity.RecordHeartbeatWithError]
+func RecordActivityHeartbeatWithError(ctx context.Context, details ...interface{}) error {
+	env := getActivityEnv(ctx)
+	if env.isLocalActivity {
+		// no-op for local activity
+		return nil
+	}
+	var data []byte
+	if len(details) != 1 || details[0] != nil {
+		var err error
+		if data, err = encodeArgs(getDataConverterFromActivityCtx(ctx), details); err != nil {
+			return err
+		}
 	}
+	return env.recordHeartbeat(data)
 }
 
 // ServiceInvoker abstracts calls to the Cadence service from an activity implementation.
@@ -359,7 +468,7 @@ func WithActivityTask(
 		zapcore.Field{Key: tagAttempt, Type: zapcore.Int64Type, Integer: int64(task.GetAttempt())},
 	)
 
-	return context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
+	env := &activityEnvironment{
 		taskToken:      task.TaskToken,
 		serviceInvoker: invoker,
 		activityType:   ActivityType{Name: *task.ActivityType.Name},
@@ -384,5 +493,7 @@ func WithActivityTask(
 		workerStopChannel:  workerStopChannel,
 		contextPropagators: contextPropagators,
 		tracer:             tracer,
-	})
+	}
+	env.setWorkflowCaller(task.Header)
+	return context.WithValue(ctx, activityEnvContextKey, env)
 }
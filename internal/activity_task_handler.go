@@ -54,6 +54,11 @@ type (
 		tracer             opentracing.Tracer
 		featureFlags       FeatureFlags
 		activityTracker    debug.ActivityTracker
+		metricTagGuard     *activityMetricTagGuard
+		client             Client
+
+		maxHeartbeatDetailsSize  int
+		truncateHeartbeatDetails bool
 	}
 )
 
@@ -94,6 +99,11 @@ func newActivityTaskHandlerWithCustomProvider(
 		tracer:             params.Tracer,
 		featureFlags:       params.FeatureFlags,
 		activityTracker:    params.WorkerStats.ActivityTracker,
+		metricTagGuard:     newActivityMetricTagGuard(),
+		client:             params.activityClient,
+
+		maxHeartbeatDetailsSize:  params.MaxHeartbeatDetailsSize,
+		truncateHeartbeatDetails: params.TruncateLargeHeartbeatDetails,
 	}
 }
 
@@ -110,8 +120,8 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 	if rootCtx == nil {
 		rootCtx = context.Background()
 	}
-	canCtx, cancel := context.WithCancel(rootCtx)
-	defer cancel()
+	canCtx, cancel := context.WithCancelCause(rootCtx)
+	defer cancel(nil)
 
 	workflowType := t.WorkflowType.GetName()
 	activityType := t.ActivityType.GetName()
@@ -123,6 +133,10 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 
 	metricsScope := getMetricsScopeForActivity(ath.metricsScope, workflowType, activityType)
 	ctx := WithActivityTask(canCtx, t, taskList, invoker, ath.logger, metricsScope, ath.dataConverter, ath.workerStopCh, ath.contextPropagators, ath.tracer)
+	env := getActivityEnv(ctx)
+	env.client = ath.client
+	env.maxHeartbeatDetailsSize = ath.maxHeartbeatDetailsSize
+	env.truncateHeartbeatDetails = ath.truncateHeartbeatDetails
 
 	activityImplementation := ath.getActivity(activityType)
 	if activityImplementation == nil {
@@ -130,6 +144,11 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 		supported := strings.Join(ath.getRegisteredActivityNames(), ", ")
 		return nil, fmt.Errorf("unable to find activityType=%v. Supported types: [%v]", activityType, supported)
 	}
+	dataConverter := ath.dataConverter
+	if activityImplementation.GetOptions().DataConverter != nil {
+		dataConverter = activityImplementation.GetOptions().DataConverter
+		env.dataConverter = dataConverter
+	}
 
 	// panic handler
 	defer func() {
@@ -144,14 +163,15 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 				zap.String(tagPanicStack, st))
 			metricsScope.Counter(metrics.ActivityTaskPanicCounter).Inc(1)
 			panicErr := newPanicError(p, st)
-			result, err = convertActivityResultToRespondRequest(ath.identity, t.TaskToken, nil, panicErr, ath.dataConverter), nil
+			result, err = convertActivityResultToRespondRequest(ath.identity, t.TaskToken, nil, panicErr, dataConverter), nil
 		}
 	}()
 
 	// propagate context information into the activity context from the headers
+	propagatedHeader := withoutWorkflowCallerHeaders(t.Header)
 	for _, ctxProp := range ath.contextPropagators {
 		var err error
-		if ctx, err = ctxProp.Extract(ctx, NewHeaderReader(t.Header)); err != nil {
+		if ctx, err = ctxProp.Extract(ctx, NewHeaderReader(propagatedHeader)); err != nil {
 			return nil, fmt.Errorf("unable to propagate context %w", err)
 		}
 	}
@@ -183,6 +203,14 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 		)
 		return nil, ctx.Err()
 	}
+	if canceled := env.getReportedCancellation(); canceled != nil && err != nil && err != ErrActivityResultPending {
+		// the activity reported the details of its cancellation, which are sent whatever error it returned
+		err = canceled
+	}
+	if env.asyncCompletion.Load() && err == nil {
+		// the activity opted in to be completed asynchronously
+		err = ErrActivityResultPending
+	}
 	if err != nil && err != ErrActivityResultPending {
 		ath.logger.Error("Activity error.",
 			zap.String(tagWorkflowID, t.WorkflowExecution.GetWorkflowId()),
@@ -191,7 +219,7 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 			zap.Error(err),
 		)
 	}
-	return convertActivityResultToRespondRequest(ath.identity, t.TaskToken, output, err, ath.dataConverter), nil
+	return convertActivityResultToRespondRequest(ath.identity, t.TaskToken, output, err, dataConverter), nil
 }
 
 func (ath *activityTaskHandlerImpl) getActivity(name string) activity {
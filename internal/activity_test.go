@@ -22,6 +22,7 @@ package internal
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"go.uber.org/cadence/internal/common/testlogger"
@@ -29,12 +30,14 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
 	"go.uber.org/yarpc"
 	"go.uber.org/zap"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 )
 
 const (
@@ -65,7 +68,7 @@ func (s *activityTestSuite) TearDownTest() {
 }
 
 func (s *activityTestSuite) TestActivityHeartbeat() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.Background())
 	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{}, s.logger, testWorkflowType, testActivityType)
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{serviceInvoker: invoker})
 
@@ -76,7 +79,7 @@ func (s *activityTestSuite) TestActivityHeartbeat() {
 }
 
 func (s *activityTestSuite) TestActivityHeartbeat_InternalError() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.Background())
 	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{}, s.logger, testWorkflowType, testActivityType)
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker,
@@ -92,7 +95,7 @@ func (s *activityTestSuite) TestActivityHeartbeat_InternalError() {
 }
 
 func (s *activityTestSuite) TestActivityHeartbeat_CancelRequested() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.Background())
 	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{}, s.logger, testWorkflowType, testActivityType)
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker,
@@ -107,7 +110,7 @@ func (s *activityTestSuite) TestActivityHeartbeat_CancelRequested() {
 }
 
 func (s *activityTestSuite) TestActivityHeartbeat_EntityNotExist() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.Background())
 	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{}, s.logger, testWorkflowType, testActivityType)
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker,
@@ -122,7 +125,7 @@ func (s *activityTestSuite) TestActivityHeartbeat_EntityNotExist() {
 }
 
 func (s *activityTestSuite) TestActivityHeartbeat_SuppressContinousInvokes() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.Background())
 	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 2, make(chan struct{}), FeatureFlags{}, s.logger, testWorkflowType, testActivityType)
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker,
@@ -203,7 +206,7 @@ func (s *activityTestSuite) TestActivityHeartbeat_SuppressContinousInvokes() {
 }
 
 func (s *activityTestSuite) TestActivityHeartbeat_WorkerStop() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.Background())
 	workerStopChannel := make(chan struct{})
 	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 5, workerStopChannel, FeatureFlags{}, s.logger, testWorkflowType, testActivityType)
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{serviceInvoker: invoker})
@@ -227,6 +230,69 @@ func (s *activityTestSuite) TestActivityHeartbeat_WorkerStop() {
 	<-waitC2
 }
 
+func (s *activityTestSuite) TestActivityHeartbeat_DetailsTooLarge() {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{}, s.logger, testWorkflowType, testActivityType)
+	scope := tally.NewTestScope("", nil)
+	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
+		serviceInvoker:          invoker,
+		logger:                  s.logger,
+		metricsScope:            scope,
+		maxHeartbeatDetailsSize: 10,
+	})
+
+	err := RecordActivityHeartbeatWithError(ctx, "details larger than the limit")
+	s.ErrorIs(err, ErrHeartbeatDetailsTooLarge)
+	var tooLarge *HeartbeatDetailsTooLargeError
+	s.Require().ErrorAs(err, &tooLarge)
+	s.Equal(HeartbeatDetailsTooLargeError{Size: 32, Limit: 10}, *tooLarge)
+	s.Equal(int64(1), scope.Snapshot().Counters()[metrics.ActivityHeartbeatDetailsTooLargeCounter+"+"].Value())
+
+	s.service.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions()...).
+		Return(&shared.RecordActivityTaskHeartbeatResponse{}, nil).Times(1)
+	s.NoError(RecordActivityHeartbeatWithError(ctx, "small"))
+}
+
+func (s *activityTestSuite) TestActivityHeartbeat_DetailsTruncated() {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{}, s.logger, testWorkflowType, testActivityType)
+	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
+		serviceInvoker:           invoker,
+		logger:                   s.logger,
+		metricsScope:             tally.NoopScope,
+		maxHeartbeatDetailsSize:  10,
+		truncateHeartbeatDetails: true,
+	})
+
+	s.service.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions()...).
+		Do(func(ctx context.Context, request *shared.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) {
+			s.Equal(heartbeatDetailsTruncatedMarker, request.Details)
+		}).
+		Return(&shared.RecordActivityTaskHeartbeatResponse{}, nil).Times(1)
+	err := RecordActivityHeartbeatWithError(ctx, "details larger than the limit")
+	var tooLarge *HeartbeatDetailsTooLargeError
+	s.Require().ErrorAs(err, &tooLarge)
+	s.True(tooLarge.Truncated)
+
+	// the next attempt is told the details were truncated
+	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{heartbeatDetails: heartbeatDetailsTruncatedMarker})
+	s.True(HasHeartbeatDetails(ctx))
+	var details string
+	s.ErrorIs(GetHeartbeatDetails(ctx, &details), ErrHeartbeatDetailsTruncated)
+}
+
+func (s *activityTestSuite) TestClientHeartbeat_DetailsTooLarge() {
+	client := NewClient(s.service, "domain", nil)
+	largeDetails := strings.Repeat("x", defaultMaxHeartbeatDetailsSize)
+
+	err := client.RecordActivityHeartbeat(context.Background(), []byte("task-token"), largeDetails)
+	s.ErrorIs(err, ErrHeartbeatDetailsTooLarge)
+	err = client.RecordActivityHeartbeatByID(context.Background(), "domain", "workflow-id", "run-id", "activity-id", largeDetails)
+	s.ErrorIs(err, ErrHeartbeatDetailsTooLarge)
+}
+
 func (s *activityTestSuite) TestGetWorkerStopChannel() {
 	ch := make(chan struct{}, 1)
 	ctx := context.WithValue(context.Background(), activityEnvContextKey, &activityEnvironment{workerStopChannel: ch})
@@ -255,3 +321,22 @@ func (s *activityTestSuite) TestHasActivityInfo() {
 	ctxWithOtherValue := context.WithValue(ctx, activityOptionsContextKey, "other-value")
 	s.False(HasActivityInfo(ctxWithOtherValue))
 }
+
+func (s *activityTestSuite) TestWorkflowCallerHeaders() {
+	header := &shared.Header{Fields: map[string][]byte{"propagated": []byte("value")}}
+	setWorkflowCallerHeaders(header, &WorkflowInfo{
+		TaskListName:            "workflow-tasklist",
+		ParentWorkflowDomain:    common.StringPtr("parent-domain"),
+		ParentWorkflowExecution: &WorkflowExecution{ID: "parent-id", RunID: "parent-run-id"},
+	})
+
+	env := &activityEnvironment{}
+	env.setWorkflowCaller(header)
+	s.Equal("workflow-tasklist", env.workflowTaskList)
+	s.Equal("parent-domain", *env.parentDomain)
+	s.Equal(&WorkflowExecution{ID: "parent-id", RunID: "parent-run-id"}, env.parentExecution)
+
+	s.Equal(map[string][]byte{"propagated": []byte("value")}, withoutWorkflowCallerHeaders(header).Fields,
+		"the context propagators do not see the caller headers")
+	s.Len(header.Fields, 5, "the header of the task is not modified")
+}
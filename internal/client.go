@@ -35,6 +35,8 @@ import (
 	"go.uber.org/cadence/internal/common/auth"
 	"go.uber.org/cadence/internal/common/isolationgroup"
 	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/cadence/internal/common/payloadlog"
+	"go.uber.org/cadence/internal/common/serviceerror"
 )
 
 const (
@@ -49,6 +51,21 @@ const (
 	// QueryTypeQueryTypes is the build in query type for Client.QueryWorkflow() call. Use this query type to list
 	// all query types of the workflow. The result will be a string encoded in the EncodedValue.
 	QueryTypeQueryTypes string = "__query_types"
+
+	// QueryTypePendingOperations is the build in query type for Client.QueryWorkflow() call. Use this query type to
+	// list the activities, timers and child workflows the workflow is waiting for. The result will be a
+	// PendingOperations encoded in the EncodedValue.
+	QueryTypePendingOperations string = "__pending_operations"
+
+	// QueryTypeRecentLogs is the build in query type for Client.QueryWorkflow() call. Use this query type to get the
+	// latest entries of the workflow logger, see WorkerOptions.WorkflowLogBufferSize. An optional int argument limits
+	// the number of entries. The result will be a list of RecentLogEntry, oldest first, encoded in the EncodedValue.
+	QueryTypeRecentLogs string = "__recent_logs"
+
+	// QueryTypeMetadata is the build in query type for Client.QueryWorkflow() call. Use this query type to get the
+	// query types, the signal channels and the status text of the workflow, see SetStatusText. The result will be a
+	// WorkflowMetadata encoded in the EncodedValue.
+	QueryTypeMetadata string = "__cadence_metadata"
 )
 
 // BuiltinQueryTypes returns a list of built-in query types
@@ -57,6 +74,9 @@ func BuiltinQueryTypes() []string {
 		QueryTypeOpenSessions,
 		QueryTypeStackTrace,
 		QueryTypeQueryTypes,
+		QueryTypePendingOperations,
+		QueryTypeRecentLogs,
+		QueryTypeMetadata,
 	}
 }
 
@@ -102,6 +122,13 @@ type (
 		//	- InternalServiceError
 		StartWorkflowAsync(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (*WorkflowExecutionAsync, error)
 
+		// StartWorkflowValidated behaves like StartWorkflow, but first validates the options, the workflow and its
+		// arguments against the default limits of the server: timeouts, task list, ID length and reuse policy, retry
+		// policy, cron expression, types and sizes of memo and search attributes, and size of the arguments.
+		// All the problems found are returned at once before contacting the server, combined with multierr:
+		//	for _, problem := range multierr.Errors(err) { ... }
+		StartWorkflowValidated(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (*WorkflowExecution, error)
+
 		// ExecuteWorkflow starts a workflow execution and return a WorkflowRun instance and error
 		// The user can use this to start using a function or workflow type name.
 		// Either by
@@ -162,6 +189,8 @@ type (
 		// - options, workflow, workflowArgs are same as StartWorkflow's parameters
 		// Note: options.WorkflowIDReusePolicy is default to WorkflowIDReusePolicyAllowDuplicate in this API;
 		// while in StartWorkflow/ExecuteWorkflow APIs it is default to WorkflowIdReusePolicyAllowDuplicateFailedOnly.
+		// options.RequestID is passed to the server as the request ID, but the client does not deduplicate the signal of
+		// a retried call: workflows must deduplicate signals which can be retried, see StartWorkflowOptions.RequestID.
 		// The errors it can return:
 		//  - EntityNotExistsError, if domain does not exist
 		//  - BadRequestError
@@ -263,6 +292,7 @@ type (
 		// The errors it can return:
 		//	- EntityNotExistsError
 		//	- InternalServiceError
+		//	- *HeartbeatDetailsTooLargeError, if the serialized details are larger than 2MB
 		RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error
 
 		// RecordActivityHeartbeatByID records heartbeat for an activity.
@@ -270,6 +300,7 @@ type (
 		// The errors it can return:
 		//	- EntityNotExistsError
 		//	- InternalServiceError
+		//	- *HeartbeatDetailsTooLargeError, if the serialized details are larger than 2MB
 		RecordActivityHeartbeatByID(ctx context.Context, domain, workflowID, runID, activityID string, details ...interface{}) error
 
 		// ListClosedWorkflow gets closed workflow executions based on request filters
@@ -406,6 +437,7 @@ type (
 	// ClientOptions are optional parameters for Client creation.
 	ClientOptions struct {
 		MetricsScope       tally.Scope
+		MetricsOptions     MetricsOptions
 		Identity           string
 		IsolationGroup     string
 		DataConverter      DataConverter
@@ -413,6 +445,23 @@ type (
 		ContextPropagators []ContextPropagator
 		FeatureFlags       FeatureFlags
 		Authorization      auth.AuthorizationProvider
+		PayloadLogger      *PayloadLogger
+		OnThrottle         func(ThrottleInfo)
+	}
+
+	// MetricsOptions configures the naming of metrics emitted to the MetricsScope of a client or worker.
+	// Using the same options for clients and workers of a service keeps their metric names consistent.
+	MetricsOptions struct {
+		// Optional: Prefix is prepended to the name of every metric, separated by the separator of the scope.
+		Prefix string
+
+		// Optional: Tags are added to every metric, e.g. service, environment or build.
+		Tags map[string]string
+
+		// Optional: Sanitizer is applied to metric names, tag keys and tag values before they are
+		// emitted, e.g. tally.NewSanitizer(tally.SanitizeOptions{...}).
+		// default: no sanitization beyond the one configured on the scope.
+		Sanitizer tally.Sanitizer
 	}
 
 	// StartWorkflowOptions configuration parameters for starting a workflow execution.
@@ -511,6 +560,17 @@ type (
 		// ActiveClusterSelectionPolicy - Policy for selecting the active cluster to start the workflow execution on for active-active domains.
 		// Optional: defaulted to nil, if it's nil, the active cluster of the workflow is the domain's active cluster.
 		ActiveClusterSelectionPolicy *ActiveClusterSelectionPolicy
+
+		// RequestID - Idempotency key of the request, e.g. derived from the ID of the message which triggered it.
+		// Retries within a single call always reuse the same key. Set it to deduplicate calls retried by the caller:
+		// StartWorkflow with a RequestID equal to the one which started the running execution returns that execution
+		// instead of failing with WorkflowExecutionAlreadyStartedError.
+		// SignalWithStartWorkflow passes it to the server too, but only as the request ID of the start: the client
+		// neither sets the request ID of signals, SignalWorkflow uses a new one per call, nor warns when a retried
+		// signal is delivered again to a running execution. Workflows receiving signals which can be retried must
+		// deduplicate them, e.g. by an ID carried in the signal argument.
+		// Optional: defaulted to a uuid per call.
+		RequestID string
 	}
 
 	// RetryPolicy defines the retry policy.
@@ -665,7 +725,7 @@ func NewClient(service workflowserviceclient.Interface, domain string, options *
 	}
 	var metricScope tally.Scope
 	if options != nil {
-		metricScope = options.MetricsScope
+		metricScope = applyMetricsOptions(options.MetricsScope, options.MetricsOptions)
 	}
 	metricScope = tagScope(metricScope, tagDomain, domain, clientImplHeaderName, clientImplHeaderValue, callerTypeHeaderName, callerTypeHeaderValue)
 	var dataConverter DataConverter
@@ -685,6 +745,9 @@ func NewClient(service workflowserviceclient.Interface, domain string, options *
 	} else {
 		tracer = opentracing.NoopTracer{}
 	}
+	if options != nil && options.PayloadLogger != nil {
+		service = payloadlog.NewWorkflowServiceWrapper(service, options.PayloadLogger)
+	}
 	if options != nil && options.Authorization != nil {
 		service = auth.NewWorkflowServiceWrapper(service, options.Authorization)
 	}
@@ -692,6 +755,9 @@ func NewClient(service workflowserviceclient.Interface, domain string, options *
 		service = isolationgroup.NewWorkflowServiceWrapper(service, options.IsolationGroup)
 	}
 	service = metrics.NewWorkflowServiceWrapper(service, metricScope)
+	if options != nil && (options.FeatureFlags.ServiceErrorsEnabled || options.OnThrottle != nil) {
+		service = serviceerror.NewWorkflowServiceWrapper(service, options.FeatureFlags.ServiceErrorsEnabled, newThrottleCallback(options.OnThrottle))
+	}
 	return &workflowClient{
 		workflowService:    service,
 		domain:             domain,
@@ -715,13 +781,19 @@ func NewDomainClient(service workflowserviceclient.Interface, options *ClientOpt
 	}
 	var metricScope tally.Scope
 	if options != nil {
-		metricScope = options.MetricsScope
+		metricScope = applyMetricsOptions(options.MetricsScope, options.MetricsOptions)
 	}
 	metricScope = tagScope(metricScope, tagDomain, "domain-client", clientImplHeaderName, clientImplHeaderValue, callerTypeHeaderName, callerTypeHeaderValue)
+	if options != nil && options.PayloadLogger != nil {
+		service = payloadlog.NewWorkflowServiceWrapper(service, options.PayloadLogger)
+	}
 	if options != nil && options.Authorization != nil {
 		service = auth.NewWorkflowServiceWrapper(service, options.Authorization)
 	}
 	service = metrics.NewWorkflowServiceWrapper(service, metricScope)
+	if options != nil && (options.FeatureFlags.ServiceErrorsEnabled || options.OnThrottle != nil) {
+		service = serviceerror.NewWorkflowServiceWrapper(service, options.FeatureFlags.ServiceErrorsEnabled, newThrottleCallback(options.OnThrottle))
+	}
 	return &domainClient{
 		workflowService: service,
 		metricsScope:    metricScope,
@@ -730,6 +802,17 @@ func NewDomainClient(service workflowserviceclient.Interface, options *ClientOpt
 	}
 }
 
+func applyMetricsOptions(scope tally.Scope, options MetricsOptions) tally.Scope {
+	if scope == nil {
+		return nil
+	}
+	return metrics.WrapScopeWithOptions(scope, metrics.ScopeOptions{
+		Prefix:    options.Prefix,
+		Tags:      options.Tags,
+		Sanitizer: options.Sanitizer,
+	})
+}
+
 func (p WorkflowIDReusePolicy) toThriftPtr() *s.WorkflowIdReusePolicy {
 	var policy s.WorkflowIdReusePolicy
 	switch p {
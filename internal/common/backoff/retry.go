@@ -27,6 +27,7 @@ import (
 	"time"
 
 	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/serviceerror"
 )
 
 type (
@@ -161,10 +162,13 @@ Retry_Loop:
 // note that this is only a minimum, however.  longer delays are assumed to
 // be equally valid.
 func ErrRetryableAfter(err error) (retryAfter time.Duration) {
+	if target := (*serviceerror.ServiceBusy)(nil); errors.As(err, &target) {
+		return target.RetryAfter
+	}
 	if target := (*s.ServiceBusyError)(nil); errors.As(err, &target) {
 		// eventually: return a time-until-retry from the server.
 		// for now though, just ensure at least one second before the next attempt.
-		return time.Second
+		return serviceerror.DefaultRetryAfter
 	}
 	return 0
 }
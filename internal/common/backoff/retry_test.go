@@ -29,6 +29,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/serviceerror"
 )
 
 type errCategory int
@@ -162,6 +163,12 @@ func TestConcurrentRetrier(t *testing.T) {
 	}
 }
 
+func TestErrRetryableAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), ErrRetryableAfter(&someError{}))
+	assert.Equal(t, serviceerror.DefaultRetryAfter, ErrRetryableAfter(&shared.ServiceBusyError{}))
+	assert.Equal(t, 5*time.Second, ErrRetryableAfter(&serviceerror.ServiceBusy{RetryAfter: 5 * time.Second}))
+}
+
 type someError struct{}
 
 func (e *someError) Error() string {
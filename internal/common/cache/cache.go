@@ -49,6 +49,10 @@ type Cache interface {
 
 	// Size returns the number of entries currently stored in the Cache
 	Size() int
+
+	// RemoveIf deletes the elements for which remove returns true, skipping the pinned ones, and returns the number
+	// of deleted elements. remove is called with the cache locked, it must not call the cache.
+	RemoveIf(remove func(key string, value interface{}) bool) int
 }
 
 // Options control the behavior of the cache
@@ -171,6 +171,28 @@ func (c *lru) Size() int {
 	return len(c.byKey)
 }
 
+// RemoveIf deletes the unpinned elements for which remove returns true
+func (c *lru) RemoveIf(remove func(key string, value interface{}) bool) int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	removed := 0
+	for elt := c.byAccess.Back(); elt != nil; {
+		prev := elt.Prev()
+		entry := elt.Value.(*cacheEntry)
+		if entry.refCount == 0 && remove(entry.key, entry.value) {
+			c.byAccess.Remove(elt)
+			delete(c.byKey, entry.key)
+			if c.rmFunc != nil {
+				go c.rmFunc(entry.value)
+			}
+			removed++
+		}
+		elt = prev
+	}
+	return removed
+}
+
 // Put puts a new value associated with a given key, returning the existing value (if present)
 // allowUpdate flag is used to control overwrite behavior if the value exists
 func (c *lru) putInternal(key string, value interface{}, allowUpdate bool) (interface{}, error) {
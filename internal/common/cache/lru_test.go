@@ -240,3 +240,40 @@ func TestRemovedFuncWithTTL(t *testing.T) {
 		t.Error("RemovedFunc did not send true on channel ch")
 	}
 }
+
+func TestRemoveIf(t *testing.T) {
+	removed := make(chan interface{}, 5)
+	cache := New(5, &Options{
+		Pin: true,
+		RemovedFunc: func(i interface{}) {
+			removed <- i
+		},
+	})
+
+	for _, key := range []string{"A", "B", "C"} {
+		_, err := cache.PutIfNotExist(key, key)
+		assert.NoError(t, err)
+	}
+	cache.Release("A")
+	cache.Release("B")
+
+	// C is pinned and never passed to the predicate.
+	var seen []string
+	count := cache.RemoveIf(func(key string, value interface{}) bool {
+		seen = append(seen, key)
+		return key != "B"
+	})
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"A", "B"}, seen)
+	assert.False(t, cache.Exist("A"))
+	assert.True(t, cache.Exist("B"))
+	assert.True(t, cache.Exist("C"))
+	assert.Equal(t, 2, cache.Size())
+
+	select {
+	case value := <-removed:
+		assert.Equal(t, "A", value)
+	case <-time.After(100 * time.Millisecond):
+		t.Error("RemovedFunc was not called")
+	}
+}
@@ -34,6 +34,7 @@ const (
 	WorkflowGetHistoryFailedCounter     = CadenceMetricsPrefix + "workflow-get-history-failed"
 	WorkflowGetHistorySucceedCounter    = CadenceMetricsPrefix + "workflow-get-history-succeed"
 	WorkflowGetHistoryLatency           = CadenceMetricsPrefix + "workflow-get-history-latency"
+	WorkflowGetHistoryBytes             = CadenceMetricsPrefix + "workflow-get-history-bytes"
 	WorkflowSignalWithStartCounter      = CadenceMetricsPrefix + "workflow-signal-with-start"
 	WorkflowSignalWithStartAsyncCounter = CadenceMetricsPrefix + "workflow-signal-with-start-async"
 	DecisionTimeoutCounter              = CadenceMetricsPrefix + "decision-timeout"
@@ -53,6 +54,15 @@ const (
 	DecisionTaskPanicCounter           = CadenceMetricsPrefix + "decision-task-panic"
 	DecisionTaskCompletedCounter       = CadenceMetricsPrefix + "decision-task-completed"
 	DecisionTaskForceCompleted         = CadenceMetricsPrefix + "decision-task-force-completed"
+	DecisionTaskNearTimeoutCounter     = CadenceMetricsPrefix + "decision-task-near-timeout"
+
+	DecisionTaskAutoResetCounter        = CadenceMetricsPrefix + "decision-task-auto-reset"
+	DecisionTaskAutoResetFailedCounter  = CadenceMetricsPrefix + "decision-task-auto-reset-failed"
+	DecisionTaskAutoResetSkippedCounter = CadenceMetricsPrefix + "decision-task-auto-reset-skipped"
+	DecisionTaskFilteredCounter         = CadenceMetricsPrefix + "decision-task-filtered"
+
+	DecisionCircuitBreakerTrippedCounter      = CadenceMetricsPrefix + "decision-circuit-breaker-tripped"
+	DecisionTaskCircuitBreakerRejectedCounter = CadenceMetricsPrefix + "decision-task-circuit-breaker-rejected"
 
 	ActivityPollCounter                         = CadenceMetricsPrefix + "activity-poll-total"
 	ActivityPollFailedCounter                   = CadenceMetricsPrefix + "activity-poll-failed"
@@ -86,6 +96,10 @@ const (
 	ActivityLocalDispatchSucceedCounter         = CadenceMetricsPrefix + "activity-local-dispatch-succeed"
 	WorkerPanicCounter                          = CadenceMetricsPrefix + "worker-panic"
 
+	ActivityFairnessRunningTasks = CadenceMetricsPrefix + "activity-fairness-running-tasks" // tagged by tenant
+	ActivityFairnessQueuedTasks  = CadenceMetricsPrefix + "activity-fairness-queued-tasks"
+	ActivityFairnessQueueLatency = CadenceMetricsPrefix + "activity-fairness-queue-latency" // time waited for a slot
+
 	UnhandledSignalsCounter = CadenceMetricsPrefix + "unhandled-signals"
 	CorruptedSignalsCounter = CadenceMetricsPrefix + "corrupted-signals"
 
@@ -103,13 +117,40 @@ const (
 	StickyCacheStall = CadenceMetricsPrefix + "sticky-cache-stall"
 	StickyCacheSize  = CadenceMetricsPrefix + "sticky-cache-size"
 
+	StickyCacheReconciled = CadenceMetricsPrefix + "sticky-cache-reconciled"
+	StickyCacheIdleEvict  = CadenceMetricsPrefix + "sticky-cache-idle-evict"
+
 	NonDeterministicError = CadenceMetricsPrefix + "non-deterministic-error"
 
+	WorkflowTypeAliasMatchedCounter = CadenceMetricsPrefix + "workflow-type-alias-matched"
+	ActivityMissingArgsCounter      = CadenceMetricsPrefix + "activity-missing-args"
+
+	ActivityHeartbeatDetailsTooLargeCounter = CadenceMetricsPrefix + "activity-heartbeat-details-too-large"
+
 	ReplaySucceedCounter = CadenceMetricsPrefix + "replay-succeed"
 	ReplayFailedCounter  = CadenceMetricsPrefix + "replay-failed"
 	ReplaySkippedCounter = CadenceMetricsPrefix + "replay-skipped"
 	ReplayLatency        = CadenceMetricsPrefix + "replay-latency"
 
+	ReplayDecisionMismatchCounter = CadenceMetricsPrefix + "replay-decision-mismatch"
+
+	SDKVersionMismatchCounter      = CadenceMetricsPrefix + "sdk-version-mismatch"
+	SDKVersionMarkerInvalidCounter = CadenceMetricsPrefix + "sdk-version-marker-invalid"
+
+	HistoryGrowthExceededCounter = CadenceMetricsPrefix + "history-growth-exceeded"
+
+	QueryHandlerLatency         = CadenceMetricsPrefix + "query-handler-latency"
+	QueryHandlerSucceedCounter  = CadenceMetricsPrefix + "query-handler-succeed"
+	QueryHandlerFailedCounter   = CadenceMetricsPrefix + "query-handler-failed"
+	QueryHandlerRejectedCounter = CadenceMetricsPrefix + "query-handler-rejected" // unknown query type or panicked workflow
+
+	AdmissionControlPausedCounter  = CadenceMetricsPrefix + "admission-control-paused"
+	AdmissionControlPausedLatency  = CadenceMetricsPrefix + "admission-control-paused-latency"
+	AdmissionControlPausedGauge    = CadenceMetricsPrefix + "admission-control-paused-state"
+	AdmissionControlMemoryUsage    = CadenceMetricsPrefix + "admission-control-memory-usage"
+	AdmissionControlCPUUsage       = CadenceMetricsPrefix + "admission-control-cpu-usage"
+	AdmissionControlMonitorFailure = CadenceMetricsPrefix + "admission-control-monitor-failure"
+
 	EstimatedHistorySize     = CadenceMetricsPrefix + "estimated-history-size"
 	ServerSideHistorySize    = CadenceMetricsPrefix + "server-side-history-size"
 	ConcurrentTaskQuota      = CadenceMetricsPrefix + "concurrent-task-quota"
@@ -117,4 +158,13 @@ const (
 
 	// Concurrency Auto Scaler
 	ConcurrencyAutoScalerScope = CadenceMetricsPrefix + "concurrency-auto-scaler"
+
+	// Workflow execution latency breakdown, emitted per execution when it closes
+	WorkflowFirstDecisionScheduledToStartLatency = CadenceMetricsPrefix + "workflow-first-decision-scheduled-to-start-latency"
+	WorkflowDecisionProcessingLatency            = CadenceMetricsPrefix + "workflow-decision-processing-latency"
+	WorkflowBlockedOnActivitiesLatency           = CadenceMetricsPrefix + "workflow-blocked-on-activities-latency"
+	WorkflowBlockedOnTimersLatency               = CadenceMetricsPrefix + "workflow-blocked-on-timers-latency"
+	WorkflowBlockedOnSignalsLatency              = CadenceMetricsPrefix + "workflow-blocked-on-signals-latency"
+	WorkflowBlockedOnChildWorkflowsLatency       = CadenceMetricsPrefix + "workflow-blocked-on-child-workflows-latency"
+	WorkflowBlockedOnOtherLatency                = CadenceMetricsPrefix + "workflow-blocked-on-other-latency"
 )
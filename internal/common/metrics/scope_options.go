@@ -0,0 +1,102 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"github.com/uber-go/tally"
+)
+
+type (
+	// ScopeOptions configures how a user provided scope is wrapped before metrics are emitted to it.
+	ScopeOptions struct {
+		// Prefix is added to the name of every metric as a sub scope.
+		Prefix string
+		// Tags are added to every metric.
+		Tags map[string]string
+		// Sanitizer is applied to metric names, tag keys and tag values.
+		Sanitizer tally.Sanitizer
+	}
+
+	sanitizingScope struct {
+		scope     tally.Scope
+		sanitizer tally.Sanitizer
+	}
+)
+
+// WrapScopeWithOptions applies the sanitizer, prefix and tags of the options to the scope.
+func WrapScopeWithOptions(scope tally.Scope, options ScopeOptions) tally.Scope {
+	if scope == nil {
+		scope = tally.NoopScope
+	}
+	if options.Sanitizer != nil {
+		scope = &sanitizingScope{scope: scope, sanitizer: options.Sanitizer}
+	}
+	if options.Prefix != "" {
+		scope = scope.SubScope(options.Prefix)
+	}
+	if len(options.Tags) > 0 {
+		scope = scope.Tagged(options.Tags)
+	}
+	return scope
+}
+
+func (s *sanitizingScope) sanitizeTags(tags map[string]string) map[string]string {
+	sanitized := make(map[string]string, len(tags))
+	for k, v := range tags {
+		sanitized[s.sanitizer.Key(k)] = s.sanitizer.Value(v)
+	}
+	return sanitized
+}
+
+// Counter returns the Counter object corresponding to the sanitized name.
+func (s *sanitizingScope) Counter(name string) tally.Counter {
+	return s.scope.Counter(s.sanitizer.Name(name))
+}
+
+// Gauge returns the Gauge object corresponding to the sanitized name.
+func (s *sanitizingScope) Gauge(name string) tally.Gauge {
+	return s.scope.Gauge(s.sanitizer.Name(name))
+}
+
+// Timer returns the Timer object corresponding to the sanitized name.
+func (s *sanitizingScope) Timer(name string) tally.Timer {
+	return s.scope.Timer(s.sanitizer.Name(name))
+}
+
+// Histogram returns the Histogram object corresponding to the sanitized name.
+func (s *sanitizingScope) Histogram(name string, buckets tally.Buckets) tally.Histogram {
+	return s.scope.Histogram(s.sanitizer.Name(name), buckets)
+}
+
+// Tagged returns a new child scope with the given sanitized tags and current tags.
+func (s *sanitizingScope) Tagged(tags map[string]string) tally.Scope {
+	return &sanitizingScope{scope: s.scope.Tagged(s.sanitizeTags(tags)), sanitizer: s.sanitizer}
+}
+
+// SubScope returns a new child scope appending a further sanitized name prefix.
+func (s *sanitizingScope) SubScope(name string) tally.Scope {
+	return &sanitizingScope{scope: s.scope.SubScope(s.sanitizer.Name(name)), sanitizer: s.sanitizer}
+}
+
+// Capabilities returns a description of metrics reporting capabilities.
+func (s *sanitizingScope) Capabilities() tally.Capabilities {
+	return s.scope.Capabilities()
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func Test_WrapScopeWithOptions(t *testing.T) {
+	testScope := tally.NewTestScope("", nil)
+	sanitizer := tally.NewSanitizer(tally.SanitizeOptions{
+		NameCharacters:       tally.ValidCharacters{Ranges: tally.AlphanumericRange, Characters: []rune{'_'}},
+		KeyCharacters:        tally.ValidCharacters{Ranges: tally.AlphanumericRange, Characters: []rune{'_'}},
+		ValueCharacters:      tally.ValidCharacters{Ranges: tally.AlphanumericRange, Characters: []rune{'_'}},
+		ReplacementCharacter: '_',
+	})
+	scope := WrapScopeWithOptions(testScope, ScopeOptions{
+		Prefix:    "my-app",
+		Tags:      map[string]string{"env": "prod-1"},
+		Sanitizer: sanitizer,
+	})
+
+	scope.Tagged(map[string]string{"task-list": "tl.1"}).Counter("cadence-counter").Inc(1)
+
+	counters := testScope.Snapshot().Counters()
+	require.Len(t, counters, 1)
+	for _, c := range counters {
+		require.Equal(t, "my_app.cadence_counter", c.Name())
+		require.Equal(t, map[string]string{"env": "prod_1", "task_list": "tl_1"}, c.Tags())
+		require.Equal(t, int64(1), c.Value())
+	}
+}
+
+func Test_WrapScopeWithOptions_Empty(t *testing.T) {
+	testScope := tally.NewTestScope("", nil)
+	require.Equal(t, tally.Scope(testScope), WrapScopeWithOptions(testScope, ScopeOptions{}))
+	require.Equal(t, tally.NoopScope, WrapScopeWithOptions(nil, ScopeOptions{}))
+}
@@ -0,0 +1,402 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package payloadlog logs the requests and responses of the Cadence service calls, for debugging.
+package payloadlog
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/yarpc"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+type (
+	// Tap decides which service calls are logged and logs them.
+	Tap interface {
+		// Sample is called before each call, and returns whether the call is logged.
+		Sample(operation string) bool
+		// Log is called after each sampled call. The request and response are nil for calls without them.
+		Log(operation string, request, response interface{}, err error, latency time.Duration)
+	}
+
+	workflowServicePayloadLogWrapper struct {
+		service workflowserviceclient.Interface
+		tap     Tap
+	}
+
+	sampledCall struct {
+		tap       Tap
+		operation string
+		request   interface{}
+		startTime time.Time
+	}
+)
+
+// NewWorkflowServiceWrapper creates a new wrapper to WorkflowService that passes the sampled calls to the tap.
+func NewWorkflowServiceWrapper(service workflowserviceclient.Interface, tap Tap) workflowserviceclient.Interface {
+	return &workflowServicePayloadLogWrapper{service: service, tap: tap}
+}
+
+func (w *workflowServicePayloadLogWrapper) start(operation string, request interface{}) *sampledCall {
+	if !w.tap.Sample(operation) {
+		return nil
+	}
+	return &sampledCall{tap: w.tap, operation: operation, request: request, startTime: time.Now()}
+}
+
+func (c *sampledCall) done(response interface{}, err error) {
+	if c == nil {
+		return
+	}
+	c.tap.Log(c.operation, c.request, response, err, time.Since(c.startTime))
+}
+
+func (w *workflowServicePayloadLogWrapper) CountWorkflowExecutions(ctx context.Context, request *shared.CountWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.CountWorkflowExecutionsResponse, error) {
+	call := w.start("CountWorkflowExecutions", request)
+	response, err := w.service.CountWorkflowExecutions(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) DeleteDomain(ctx context.Context, request *shared.DeleteDomainRequest, opts ...yarpc.CallOption) error {
+	call := w.start("DeleteDomain", request)
+	err := w.service.DeleteDomain(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) DeprecateDomain(ctx context.Context, request *shared.DeprecateDomainRequest, opts ...yarpc.CallOption) error {
+	call := w.start("DeprecateDomain", request)
+	err := w.service.DeprecateDomain(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) DescribeDomain(ctx context.Context, request *shared.DescribeDomainRequest, opts ...yarpc.CallOption) (*shared.DescribeDomainResponse, error) {
+	call := w.start("DescribeDomain", request)
+	response, err := w.service.DescribeDomain(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) DescribeTaskList(ctx context.Context, request *shared.DescribeTaskListRequest, opts ...yarpc.CallOption) (*shared.DescribeTaskListResponse, error) {
+	call := w.start("DescribeTaskList", request)
+	response, err := w.service.DescribeTaskList(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) DescribeWorkflowExecution(ctx context.Context, request *shared.DescribeWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.DescribeWorkflowExecutionResponse, error) {
+	call := w.start("DescribeWorkflowExecution", request)
+	response, err := w.service.DescribeWorkflowExecution(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) DiagnoseWorkflowExecution(ctx context.Context, request *shared.DiagnoseWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.DiagnoseWorkflowExecutionResponse, error) {
+	call := w.start("DiagnoseWorkflowExecution", request)
+	response, err := w.service.DiagnoseWorkflowExecution(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) FailoverDomain(ctx context.Context, request *shared.FailoverDomainRequest, opts ...yarpc.CallOption) (*shared.FailoverDomainResponse, error) {
+	call := w.start("FailoverDomain", request)
+	response, err := w.service.FailoverDomain(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) GetClusterInfo(ctx context.Context, opts ...yarpc.CallOption) (*shared.ClusterInfo, error) {
+	call := w.start("GetClusterInfo", nil)
+	response, err := w.service.GetClusterInfo(ctx, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) GetSearchAttributes(ctx context.Context, opts ...yarpc.CallOption) (*shared.GetSearchAttributesResponse, error) {
+	call := w.start("GetSearchAttributes", nil)
+	response, err := w.service.GetSearchAttributes(ctx, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) GetTaskListsByDomain(ctx context.Context, request *shared.GetTaskListsByDomainRequest, opts ...yarpc.CallOption) (*shared.GetTaskListsByDomainResponse, error) {
+	call := w.start("GetTaskListsByDomain", request)
+	response, err := w.service.GetTaskListsByDomain(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) GetWorkflowExecutionHistory(ctx context.Context, request *shared.GetWorkflowExecutionHistoryRequest, opts ...yarpc.CallOption) (*shared.GetWorkflowExecutionHistoryResponse, error) {
+	call := w.start("GetWorkflowExecutionHistory", request)
+	response, err := w.service.GetWorkflowExecutionHistory(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ListArchivedWorkflowExecutions(ctx context.Context, request *shared.ListArchivedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListArchivedWorkflowExecutionsResponse, error) {
+	call := w.start("ListArchivedWorkflowExecutions", request)
+	response, err := w.service.ListArchivedWorkflowExecutions(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ListClosedWorkflowExecutions(ctx context.Context, request *shared.ListClosedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListClosedWorkflowExecutionsResponse, error) {
+	call := w.start("ListClosedWorkflowExecutions", request)
+	response, err := w.service.ListClosedWorkflowExecutions(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ListDomains(ctx context.Context, request *shared.ListDomainsRequest, opts ...yarpc.CallOption) (*shared.ListDomainsResponse, error) {
+	call := w.start("ListDomains", request)
+	response, err := w.service.ListDomains(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ListFailoverHistory(ctx context.Context, request *shared.ListFailoverHistoryRequest, opts ...yarpc.CallOption) (*shared.ListFailoverHistoryResponse, error) {
+	call := w.start("ListFailoverHistory", request)
+	response, err := w.service.ListFailoverHistory(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ListOpenWorkflowExecutions(ctx context.Context, request *shared.ListOpenWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListOpenWorkflowExecutionsResponse, error) {
+	call := w.start("ListOpenWorkflowExecutions", request)
+	response, err := w.service.ListOpenWorkflowExecutions(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ListTaskListPartitions(ctx context.Context, request *shared.ListTaskListPartitionsRequest, opts ...yarpc.CallOption) (*shared.ListTaskListPartitionsResponse, error) {
+	call := w.start("ListTaskListPartitions", request)
+	response, err := w.service.ListTaskListPartitions(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ListWorkflowExecutions(ctx context.Context, request *shared.ListWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListWorkflowExecutionsResponse, error) {
+	call := w.start("ListWorkflowExecutions", request)
+	response, err := w.service.ListWorkflowExecutions(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) PollForActivityTask(ctx context.Context, request *shared.PollForActivityTaskRequest, opts ...yarpc.CallOption) (*shared.PollForActivityTaskResponse, error) {
+	call := w.start("PollForActivityTask", request)
+	response, err := w.service.PollForActivityTask(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) PollForDecisionTask(ctx context.Context, request *shared.PollForDecisionTaskRequest, opts ...yarpc.CallOption) (*shared.PollForDecisionTaskResponse, error) {
+	call := w.start("PollForDecisionTask", request)
+	response, err := w.service.PollForDecisionTask(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) QueryWorkflow(ctx context.Context, request *shared.QueryWorkflowRequest, opts ...yarpc.CallOption) (*shared.QueryWorkflowResponse, error) {
+	call := w.start("QueryWorkflow", request)
+	response, err := w.service.QueryWorkflow(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) RecordActivityTaskHeartbeat(ctx context.Context, request *shared.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) (*shared.RecordActivityTaskHeartbeatResponse, error) {
+	call := w.start("RecordActivityTaskHeartbeat", request)
+	response, err := w.service.RecordActivityTaskHeartbeat(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) RecordActivityTaskHeartbeatByID(ctx context.Context, request *shared.RecordActivityTaskHeartbeatByIDRequest, opts ...yarpc.CallOption) (*shared.RecordActivityTaskHeartbeatResponse, error) {
+	call := w.start("RecordActivityTaskHeartbeatByID", request)
+	response, err := w.service.RecordActivityTaskHeartbeatByID(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) RefreshWorkflowTasks(ctx context.Context, request *shared.RefreshWorkflowTasksRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RefreshWorkflowTasks", request)
+	err := w.service.RefreshWorkflowTasks(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RegisterDomain(ctx context.Context, request *shared.RegisterDomainRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RegisterDomain", request)
+	err := w.service.RegisterDomain(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RequestCancelWorkflowExecution(ctx context.Context, request *shared.RequestCancelWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RequestCancelWorkflowExecution", request)
+	err := w.service.RequestCancelWorkflowExecution(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) ResetStickyTaskList(ctx context.Context, request *shared.ResetStickyTaskListRequest, opts ...yarpc.CallOption) (*shared.ResetStickyTaskListResponse, error) {
+	call := w.start("ResetStickyTaskList", request)
+	response, err := w.service.ResetStickyTaskList(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ResetWorkflowExecution(ctx context.Context, request *shared.ResetWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.ResetWorkflowExecutionResponse, error) {
+	call := w.start("ResetWorkflowExecution", request)
+	response, err := w.service.ResetWorkflowExecution(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondActivityTaskCanceled(ctx context.Context, request *shared.RespondActivityTaskCanceledRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RespondActivityTaskCanceled", request)
+	err := w.service.RespondActivityTaskCanceled(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondActivityTaskCanceledByID(ctx context.Context, request *shared.RespondActivityTaskCanceledByIDRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RespondActivityTaskCanceledByID", request)
+	err := w.service.RespondActivityTaskCanceledByID(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondActivityTaskCompleted(ctx context.Context, request *shared.RespondActivityTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RespondActivityTaskCompleted", request)
+	err := w.service.RespondActivityTaskCompleted(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondActivityTaskCompletedByID(ctx context.Context, request *shared.RespondActivityTaskCompletedByIDRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RespondActivityTaskCompletedByID", request)
+	err := w.service.RespondActivityTaskCompletedByID(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondActivityTaskFailed(ctx context.Context, request *shared.RespondActivityTaskFailedRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RespondActivityTaskFailed", request)
+	err := w.service.RespondActivityTaskFailed(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondActivityTaskFailedByID(ctx context.Context, request *shared.RespondActivityTaskFailedByIDRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RespondActivityTaskFailedByID", request)
+	err := w.service.RespondActivityTaskFailedByID(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondDecisionTaskCompleted(ctx context.Context, request *shared.RespondDecisionTaskCompletedRequest, opts ...yarpc.CallOption) (*shared.RespondDecisionTaskCompletedResponse, error) {
+	call := w.start("RespondDecisionTaskCompleted", request)
+	response, err := w.service.RespondDecisionTaskCompleted(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondDecisionTaskFailed(ctx context.Context, request *shared.RespondDecisionTaskFailedRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RespondDecisionTaskFailed", request)
+	err := w.service.RespondDecisionTaskFailed(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RespondQueryTaskCompleted(ctx context.Context, request *shared.RespondQueryTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	call := w.start("RespondQueryTaskCompleted", request)
+	err := w.service.RespondQueryTaskCompleted(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) RestartWorkflowExecution(ctx context.Context, request *shared.RestartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.RestartWorkflowExecutionResponse, error) {
+	call := w.start("RestartWorkflowExecution", request)
+	response, err := w.service.RestartWorkflowExecution(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) ScanWorkflowExecutions(ctx context.Context, request *shared.ListWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListWorkflowExecutionsResponse, error) {
+	call := w.start("ScanWorkflowExecutions", request)
+	response, err := w.service.ScanWorkflowExecutions(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) SignalWithStartWorkflowExecution(ctx context.Context, request *shared.SignalWithStartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+	call := w.start("SignalWithStartWorkflowExecution", request)
+	response, err := w.service.SignalWithStartWorkflowExecution(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) SignalWithStartWorkflowExecutionAsync(ctx context.Context, request *shared.SignalWithStartWorkflowExecutionAsyncRequest, opts ...yarpc.CallOption) (*shared.SignalWithStartWorkflowExecutionAsyncResponse, error) {
+	call := w.start("SignalWithStartWorkflowExecutionAsync", request)
+	response, err := w.service.SignalWithStartWorkflowExecutionAsync(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) SignalWorkflowExecution(ctx context.Context, request *shared.SignalWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	call := w.start("SignalWorkflowExecution", request)
+	err := w.service.SignalWorkflowExecution(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) StartWorkflowExecution(ctx context.Context, request *shared.StartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+	call := w.start("StartWorkflowExecution", request)
+	response, err := w.service.StartWorkflowExecution(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) StartWorkflowExecutionAsync(ctx context.Context, request *shared.StartWorkflowExecutionAsyncRequest, opts ...yarpc.CallOption) (*shared.StartWorkflowExecutionAsyncResponse, error) {
+	call := w.start("StartWorkflowExecutionAsync", request)
+	response, err := w.service.StartWorkflowExecutionAsync(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
+
+func (w *workflowServicePayloadLogWrapper) TerminateWorkflowExecution(ctx context.Context, request *shared.TerminateWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	call := w.start("TerminateWorkflowExecution", request)
+	err := w.service.TerminateWorkflowExecution(ctx, request, opts...)
+	call.done(nil, err)
+	return err
+}
+
+func (w *workflowServicePayloadLogWrapper) UpdateDomain(ctx context.Context, request *shared.UpdateDomainRequest, opts ...yarpc.CallOption) (*shared.UpdateDomainResponse, error) {
+	call := w.start("UpdateDomain", request)
+	response, err := w.service.UpdateDomain(ctx, request, opts...)
+	call.done(response, err)
+	return response, err
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package payloadlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+type (
+	loggedCall struct {
+		operation string
+		request   interface{}
+		response  interface{}
+		err       error
+	}
+
+	recordingTap struct {
+		sampled bool
+		calls   []loggedCall
+	}
+)
+
+func (t *recordingTap) Sample(operation string) bool {
+	return t.sampled
+}
+
+func (t *recordingTap) Log(operation string, request, response interface{}, err error, latency time.Duration) {
+	t.calls = append(t.calls, loggedCall{operation: operation, request: request, response: response, err: err})
+}
+
+func TestServiceWrapper(t *testing.T) {
+	ctx := context.Background()
+	controller := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(controller)
+	tap := &recordingTap{sampled: true}
+	wrapper := NewWorkflowServiceWrapper(service, tap)
+
+	request := &shared.DescribeDomainRequest{Name: stringPtr("orders")}
+	response := &shared.DescribeDomainResponse{}
+	service.EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).Return(response, nil)
+	actual, err := wrapper.DescribeDomain(ctx, request)
+	require.NoError(t, err)
+	assert.Same(t, response, actual)
+
+	failure := errors.New("unavailable")
+	signal := &shared.SignalWorkflowExecutionRequest{SignalName: stringPtr("cancel")}
+	service.EXPECT().SignalWorkflowExecution(gomock.Any(), signal, gomock.Any()).Return(failure)
+	assert.Equal(t, failure, wrapper.SignalWorkflowExecution(ctx, signal))
+
+	service.EXPECT().GetClusterInfo(gomock.Any(), gomock.Any()).Return(&shared.ClusterInfo{}, nil)
+	_, err = wrapper.GetClusterInfo(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []loggedCall{
+		{operation: "DescribeDomain", request: request, response: response},
+		{operation: "SignalWorkflowExecution", request: signal, err: failure},
+		{operation: "GetClusterInfo", response: &shared.ClusterInfo{}},
+	}, tap.calls)
+
+	tap.sampled = false
+	tap.calls = nil
+	service.EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).Return(response, nil)
+	_, err = wrapper.DescribeDomain(ctx, request)
+	require.NoError(t, err)
+	assert.Empty(t, tap.calls, "calls which are not sampled are not logged")
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
@@ -30,6 +30,7 @@ import (
 	"go.uber.org/thriftrw/wire"
 
 	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
 )
 
 type (
@@ -79,7 +80,8 @@ func Encode(obj ThriftObject) ([]byte, error) {
 	if obj == nil {
 		return nil, MsgPayloadNotThriftEncoded
 	}
-	var writer bytes.Buffer
+	writer := common.GetBuffer()
+	defer common.PutBuffer(writer)
 	// use the first byte to version the serialization
 	err := writer.WriteByte(preambleVersion0)
 	if err != nil {
@@ -89,11 +91,11 @@ func Encode(obj ThriftObject) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = protocol.Binary.Encode(val, &writer)
+	err = protocol.Binary.Encode(val, writer)
 	if err != nil {
 		return nil, err
 	}
-	return writer.Bytes(), nil
+	return common.CopyBytes(writer), nil
 }
 
 // SerializeBatchEvents will serialize history event data to blob data
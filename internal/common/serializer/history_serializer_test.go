@@ -201,3 +201,24 @@ func createEmptyPointer(input interface{}) interface{} {
 	newInstance := reflect.New(elemType)
 	return newInstance.Interface()
 }
+
+func BenchmarkSerializeBatchEvents(b *testing.B) {
+	events := make([]*shared.HistoryEvent, 100)
+	for i := range events {
+		events[i] = &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(int64(i + 1)),
+			Timestamp: common.Int64Ptr(1),
+			EventType: common.EventTypePtr(shared.EventTypeMarkerRecorded),
+			MarkerRecordedEventAttributes: &shared.MarkerRecordedEventAttributes{
+				MarkerName: common.StringPtr("SideEffect"),
+				Details:    make([]byte, 256),
+			},
+		}
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := SerializeBatchEvents(events, shared.EncodingTypeThriftRW); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
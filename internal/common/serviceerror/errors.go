@@ -0,0 +1,267 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package serviceerror converts the errors returned by the Cadence service, over thrift or gRPC, into stable types.
+package serviceerror
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+// DefaultRetryAfter is the minimum delay before retrying a call which failed with ServiceBusy, as the server does
+// not return one yet. It is the RetryAfter of ServiceBusy errors, which the retries of the clients and workers
+// respect.
+const DefaultRetryAfter = time.Second
+
+type (
+	// EntityNotExists is returned when the domain, workflow execution or activity of the request does not exist.
+	EntityNotExists struct {
+		Message        string
+		CurrentCluster string
+		ActiveCluster  string
+		ActiveClusters []string
+		cause          error
+	}
+
+	// DomainNotActive is returned when the domain is not active in the cluster the request was sent to.
+	DomainNotActive struct {
+		Message        string
+		DomainName     string
+		CurrentCluster string
+		ActiveCluster  string
+		ActiveClusters []string
+		cause          error
+	}
+
+	// LimitExceeded is returned when a limit of the domain or workflow execution is exceeded, e.g. the number of
+	// pending activities. Retrying does not help until the limit is no longer exceeded.
+	LimitExceeded struct {
+		Message string
+		cause   error
+	}
+
+	// ServiceBusy is returned when the server rate limits the request or is overloaded. It is retried after at least
+	// RetryAfter.
+	ServiceBusy struct {
+		Message    string
+		Reason     string
+		RetryAfter time.Duration
+		cause      error
+	}
+
+	// BadRequest is returned when the request is invalid.
+	BadRequest struct {
+		Message string
+		cause   error
+	}
+
+	// AccessDenied is returned when the caller is not authorized to make the request.
+	AccessDenied struct {
+		Message string
+		cause   error
+	}
+
+	// InternalService is returned when the request failed because of an internal error of the server.
+	InternalService struct {
+		Message string
+		cause   error
+	}
+
+	// WorkflowExecutionAlreadyStarted is returned when starting a workflow whose ID is used by a running execution,
+	// or by a closed one which the reuse policy does not allow to reuse.
+	WorkflowExecutionAlreadyStarted struct {
+		Message        string
+		StartRequestID string
+		RunID          string
+		cause          error
+	}
+
+	// WorkflowExecutionAlreadyCompleted is returned when the workflow execution of the request is closed, with
+	// FeatureFlags.WorkflowExecutionAlreadyCompletedErrorEnabled.
+	WorkflowExecutionAlreadyCompleted struct {
+		Message string
+		cause   error
+	}
+
+	// DomainAlreadyExists is returned when registering a domain which already exists.
+	DomainAlreadyExists struct {
+		Message string
+		cause   error
+	}
+
+	// CancellationAlreadyRequested is returned when requesting the cancellation of a workflow execution twice.
+	CancellationAlreadyRequested struct {
+		Message string
+		cause   error
+	}
+
+	// QueryFailed is returned when the query failed in the workflow.
+	QueryFailed struct {
+		Message string
+		cause   error
+	}
+)
+
+// Convert returns the error of the service call as one of the types of this package, which unwraps to the original
+// error, so that errors.As keeps working with the thrift error types. Other errors are returned unchanged.
+func Convert(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *shared.EntityNotExistsError:
+		return &EntityNotExists{
+			Message:        e.Message,
+			CurrentCluster: e.GetCurrentCluster(),
+			ActiveCluster:  e.GetActiveCluster(),
+			ActiveClusters: e.ActiveClusters,
+			cause:          err,
+		}
+	case *shared.DomainNotActiveError:
+		return &DomainNotActive{
+			Message:        e.Message,
+			DomainName:     e.DomainName,
+			CurrentCluster: e.CurrentCluster,
+			ActiveCluster:  e.ActiveCluster,
+			ActiveClusters: e.ActiveClusters,
+			cause:          err,
+		}
+	case *shared.LimitExceededError:
+		return &LimitExceeded{Message: e.Message, cause: err}
+	case *shared.ServiceBusyError:
+		return &ServiceBusy{Message: e.Message, Reason: e.GetReason(), RetryAfter: DefaultRetryAfter, cause: err}
+	case *shared.BadRequestError:
+		return &BadRequest{Message: e.Message, cause: err}
+	case *shared.AccessDeniedError:
+		return &AccessDenied{Message: e.Message, cause: err}
+	case *shared.InternalServiceError:
+		return &InternalService{Message: e.Message, cause: err}
+	case *shared.WorkflowExecutionAlreadyStartedError:
+		return &WorkflowExecutionAlreadyStarted{
+			Message:        e.GetMessage(),
+			StartRequestID: e.GetStartRequestId(),
+			RunID:          e.GetRunId(),
+			cause:          err,
+		}
+	case *shared.WorkflowExecutionAlreadyCompletedError:
+		return &WorkflowExecutionAlreadyCompleted{Message: e.Message, cause: err}
+	case *shared.DomainAlreadyExistsError:
+		return &DomainAlreadyExists{Message: e.Message, cause: err}
+	case *shared.CancellationAlreadyRequestedError:
+		return &CancellationAlreadyRequested{Message: e.Message, cause: err}
+	case *shared.QueryFailedError:
+		return &QueryFailed{Message: e.Message, cause: err}
+	}
+
+	// gRPC errors without details are not converted to thrift errors
+	var status *yarpcerrors.Status
+	if errors.As(err, &status) {
+		switch status.Code() {
+		case yarpcerrors.CodeNotFound:
+			return &EntityNotExists{Message: status.Message(), cause: err}
+		case yarpcerrors.CodeResourceExhausted:
+			return &ServiceBusy{Message: status.Message(), RetryAfter: DefaultRetryAfter, cause: err}
+		case yarpcerrors.CodeInvalidArgument:
+			return &BadRequest{Message: status.Message(), cause: err}
+		case yarpcerrors.CodePermissionDenied, yarpcerrors.CodeUnauthenticated:
+			return &AccessDenied{Message: status.Message(), cause: err}
+		}
+	}
+	return err
+}
+
+// IsThrottle returns whether the error is a ServiceBusy or LimitExceeded error, i.e. the server rejected the call
+// because of rate limiting or overload.
+func IsThrottle(err error) bool {
+	var serviceBusy *ServiceBusy
+	var limitExceeded *LimitExceeded
+	return errors.As(err, &serviceBusy) || errors.As(err, &limitExceeded)
+}
+
+func errorString(cause error, message string) string {
+	if cause != nil {
+		return cause.Error()
+	}
+	return message
+}
+
+func (e *EntityNotExists) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.EntityNotExistsError.
+func (e *EntityNotExists) Unwrap() error { return e.cause }
+
+func (e *DomainNotActive) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.DomainNotActiveError.
+func (e *DomainNotActive) Unwrap() error { return e.cause }
+
+func (e *LimitExceeded) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.LimitExceededError.
+func (e *LimitExceeded) Unwrap() error { return e.cause }
+
+func (e *ServiceBusy) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.ServiceBusyError.
+func (e *ServiceBusy) Unwrap() error { return e.cause }
+
+func (e *BadRequest) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.BadRequestError.
+func (e *BadRequest) Unwrap() error { return e.cause }
+
+func (e *AccessDenied) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.AccessDeniedError.
+func (e *AccessDenied) Unwrap() error { return e.cause }
+
+func (e *InternalService) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.InternalServiceError.
+func (e *InternalService) Unwrap() error { return e.cause }
+
+func (e *WorkflowExecutionAlreadyStarted) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.WorkflowExecutionAlreadyStartedError.
+func (e *WorkflowExecutionAlreadyStarted) Unwrap() error { return e.cause }
+
+func (e *WorkflowExecutionAlreadyCompleted) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.WorkflowExecutionAlreadyCompletedError.
+func (e *WorkflowExecutionAlreadyCompleted) Unwrap() error { return e.cause }
+
+func (e *DomainAlreadyExists) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.DomainAlreadyExistsError.
+func (e *DomainAlreadyExists) Unwrap() error { return e.cause }
+
+func (e *CancellationAlreadyRequested) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.CancellationAlreadyRequestedError.
+func (e *CancellationAlreadyRequested) Unwrap() error { return e.cause }
+
+func (e *QueryFailed) Error() string { return errorString(e.cause, e.Message) }
+
+// Unwrap returns the original error, e.g. *shared.QueryFailedError.
+func (e *QueryFailed) Unwrap() error { return e.cause }
@@ -0,0 +1,173 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package serviceerror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/compatibility/testdata"
+	"go.uber.org/cadence/internal/compatibility/thrift"
+)
+
+func TestConvert(t *testing.T) {
+	assert.NoError(t, Convert(nil))
+	unknown := errors.New("unknown")
+	assert.Equal(t, unknown, Convert(unknown))
+
+	for _, transport := range []struct {
+		name string
+		err  func(thriftErr, grpcErr error) error
+	}{
+		{"thrift", func(thriftErr, _ error) error { return thriftErr }},
+		{"grpc", func(_, grpcErr error) error { return thrift.Error(grpcErr) }},
+	} {
+		t.Run(transport.name, func(t *testing.T) {
+			err := Convert(transport.err(&shared.EntityNotExistsError{
+				Message:        testdata.ErrorMessage,
+				CurrentCluster: common.StringPtr(testdata.ClusterName1),
+				ActiveCluster:  common.StringPtr(testdata.ClusterName2),
+			}, testdata.EntityNotExistsError))
+			var entityNotExists *EntityNotExists
+			require.ErrorAs(t, err, &entityNotExists)
+			assert.Equal(t, testdata.ErrorMessage, entityNotExists.Message)
+			assert.Equal(t, testdata.ClusterName1, entityNotExists.CurrentCluster)
+			assert.Equal(t, testdata.ClusterName2, entityNotExists.ActiveCluster)
+			var thriftErr *shared.EntityNotExistsError
+			assert.ErrorAs(t, err, &thriftErr, "the thrift error is still found with errors.As")
+
+			err = Convert(transport.err(&shared.DomainNotActiveError{
+				Message:        testdata.ErrorMessage,
+				DomainName:     testdata.DomainName,
+				CurrentCluster: testdata.ClusterName1,
+				ActiveCluster:  testdata.ClusterName2,
+			}, testdata.DomainNotActiveError))
+			var domainNotActive *DomainNotActive
+			require.ErrorAs(t, err, &domainNotActive)
+			assert.Equal(t, testdata.DomainName, domainNotActive.DomainName)
+			assert.Equal(t, testdata.ClusterName2, domainNotActive.ActiveCluster)
+
+			err = Convert(transport.err(&shared.ServiceBusyError{
+				Message: testdata.ErrorMessage,
+				Reason:  common.StringPtr(testdata.BusyReason),
+			}, testdata.ServiceBusyError))
+			var serviceBusy *ServiceBusy
+			require.ErrorAs(t, err, &serviceBusy)
+			assert.Equal(t, testdata.BusyReason, serviceBusy.Reason)
+			assert.Equal(t, DefaultRetryAfter, serviceBusy.RetryAfter)
+
+			err = Convert(transport.err(&shared.WorkflowExecutionAlreadyStartedError{
+				Message:        common.StringPtr(testdata.ErrorMessage),
+				StartRequestId: common.StringPtr(testdata.RequestID),
+				RunId:          common.StringPtr(testdata.RunID),
+			}, testdata.WorkflowExecutionAlreadyStartedError))
+			var alreadyStarted *WorkflowExecutionAlreadyStarted
+			require.ErrorAs(t, err, &alreadyStarted)
+			assert.Equal(t, testdata.RunID, alreadyStarted.RunID)
+			assert.Equal(t, testdata.RequestID, alreadyStarted.StartRequestID)
+
+			for _, tt := range []struct {
+				thriftErr error
+				grpcErr   error
+				target    interface{}
+			}{
+				{&shared.LimitExceededError{Message: testdata.ErrorMessage}, testdata.LimitExceededError, new(*LimitExceeded)},
+				{&shared.BadRequestError{Message: testdata.ErrorMessage}, testdata.BadRequestError, new(*BadRequest)},
+				{&shared.AccessDeniedError{Message: testdata.ErrorMessage}, testdata.AccessDeniedError, new(*AccessDenied)},
+				{&shared.InternalServiceError{Message: testdata.ErrorMessage}, testdata.InternalServiceError, new(*InternalService)},
+				{&shared.WorkflowExecutionAlreadyCompletedError{Message: testdata.ErrorMessage}, testdata.WorkflowExecutionAlreadyCompletedError, new(*WorkflowExecutionAlreadyCompleted)},
+				{&shared.DomainAlreadyExistsError{Message: testdata.ErrorMessage}, testdata.DomainAlreadyExistsError, new(*DomainAlreadyExists)},
+				{&shared.CancellationAlreadyRequestedError{Message: testdata.ErrorMessage}, testdata.CancellationAlreadyRequestedError, new(*CancellationAlreadyRequested)},
+				{&shared.QueryFailedError{Message: testdata.ErrorMessage}, testdata.QueryFailedError, new(*QueryFailed)},
+			} {
+				thriftErr := transport.err(tt.thriftErr, tt.grpcErr)
+				err := Convert(thriftErr)
+				assert.ErrorAs(t, err, tt.target, "%T", tt.thriftErr)
+				assert.Equal(t, thriftErr.Error(), err.Error())
+				assert.Equal(t, thriftErr, errors.Unwrap(err))
+			}
+		})
+	}
+}
+
+func TestConvertStatus(t *testing.T) {
+	err := Convert(fmt.Errorf("call failed: %w", yarpcerrors.ResourceExhaustedErrorf("rate limited")))
+	var serviceBusy *ServiceBusy
+	require.ErrorAs(t, err, &serviceBusy)
+	assert.Equal(t, "rate limited", serviceBusy.Message)
+	assert.Equal(t, DefaultRetryAfter, serviceBusy.RetryAfter)
+
+	assert.ErrorAs(t, Convert(yarpcerrors.NotFoundErrorf("not found")), new(*EntityNotExists))
+	assert.ErrorAs(t, Convert(yarpcerrors.UnauthenticatedErrorf("no token")), new(*AccessDenied))
+
+	deadlineExceeded := yarpcerrors.DeadlineExceededErrorf("timeout")
+	assert.Equal(t, deadlineExceeded, Convert(deadlineExceeded))
+}
+
+func TestServiceWrapper(t *testing.T) {
+	controller := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(controller)
+	var throttled []string
+	wrapper := NewWorkflowServiceWrapper(service, true, func(operation string, err error) {
+		assert.True(t, IsThrottle(err))
+		throttled = append(throttled, operation)
+	})
+
+	service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, &shared.EntityNotExistsError{Message: "no domain"})
+	_, err := wrapper.DescribeDomain(context.Background(), &shared.DescribeDomainRequest{})
+	assert.ErrorAs(t, err, new(*EntityNotExists))
+
+	service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shared.LimitExceededError{Message: "too many signals"})
+	err = wrapper.SignalWorkflowExecution(context.Background(), &shared.SignalWorkflowExecutionRequest{})
+	assert.ErrorAs(t, err, new(*LimitExceeded))
+
+	service.EXPECT().GetClusterInfo(gomock.Any(), gomock.Any()).Return(nil, &shared.ServiceBusyError{Message: "busy"})
+	_, err = wrapper.GetClusterInfo(context.Background())
+	assert.ErrorAs(t, err, new(*ServiceBusy))
+
+	response := &shared.DescribeDomainResponse{}
+	service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), gomock.Any()).Return(response, nil)
+	actual, err := wrapper.DescribeDomain(context.Background(), &shared.DescribeDomainRequest{})
+	assert.NoError(t, err)
+	assert.Same(t, response, actual)
+	assert.Equal(t, []string{"SignalWorkflowExecution", "GetClusterInfo"}, throttled)
+
+	throttled = nil
+	wrapper = NewWorkflowServiceWrapper(service, false, func(operation string, err error) {
+		assert.ErrorAs(t, err, new(*ServiceBusy), "the callback gets the converted error")
+		throttled = append(throttled, operation)
+	})
+	busy := &shared.ServiceBusyError{Message: "busy"}
+	service.EXPECT().PollForDecisionTask(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, busy)
+	_, err = wrapper.PollForDecisionTask(context.Background(), &shared.PollForDecisionTaskRequest{})
+	assert.Equal(t, busy, err, "errors are not converted")
+	assert.Equal(t, []string{"PollForDecisionTask"}, throttled)
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package serviceerror
+
+import (
+	"context"
+
+	"go.uber.org/yarpc"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+type workflowServiceErrorWrapper struct {
+	service    workflowserviceclient.Interface
+	convert    bool
+	onThrottle func(operation string, err error)
+}
+
+// NewWorkflowServiceWrapper creates a new wrapper to WorkflowService. With convert, the returned errors are converted
+// with Convert. onThrottle, when not nil, is called with the converted error of the calls rejected with ServiceBusy
+// or LimitExceeded, on the goroutine of the call.
+func NewWorkflowServiceWrapper(service workflowserviceclient.Interface, convert bool, onThrottle func(operation string, err error)) workflowserviceclient.Interface {
+	return &workflowServiceErrorWrapper{service: service, convert: convert, onThrottle: onThrottle}
+}
+
+func (w *workflowServiceErrorWrapper) handle(operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+	converted := Convert(err)
+	if w.onThrottle != nil && IsThrottle(converted) {
+		w.onThrottle(operation, converted)
+	}
+	if w.convert {
+		return converted
+	}
+	return err
+}
+
+func (w *workflowServiceErrorWrapper) CountWorkflowExecutions(ctx context.Context, request *shared.CountWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.CountWorkflowExecutionsResponse, error) {
+	response, err := w.service.CountWorkflowExecutions(ctx, request, opts...)
+	return response, w.handle("CountWorkflowExecutions", err)
+}
+
+func (w *workflowServiceErrorWrapper) DeleteDomain(ctx context.Context, request *shared.DeleteDomainRequest, opts ...yarpc.CallOption) error {
+	return w.handle("DeleteDomain", w.service.DeleteDomain(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) DeprecateDomain(ctx context.Context, request *shared.DeprecateDomainRequest, opts ...yarpc.CallOption) error {
+	return w.handle("DeprecateDomain", w.service.DeprecateDomain(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) DescribeDomain(ctx context.Context, request *shared.DescribeDomainRequest, opts ...yarpc.CallOption) (*shared.DescribeDomainResponse, error) {
+	response, err := w.service.DescribeDomain(ctx, request, opts...)
+	return response, w.handle("DescribeDomain", err)
+}
+
+func (w *workflowServiceErrorWrapper) DescribeTaskList(ctx context.Context, request *shared.DescribeTaskListRequest, opts ...yarpc.CallOption) (*shared.DescribeTaskListResponse, error) {
+	response, err := w.service.DescribeTaskList(ctx, request, opts...)
+	return response, w.handle("DescribeTaskList", err)
+}
+
+func (w *workflowServiceErrorWrapper) DescribeWorkflowExecution(ctx context.Context, request *shared.DescribeWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.DescribeWorkflowExecutionResponse, error) {
+	response, err := w.service.DescribeWorkflowExecution(ctx, request, opts...)
+	return response, w.handle("DescribeWorkflowExecution", err)
+}
+
+func (w *workflowServiceErrorWrapper) DiagnoseWorkflowExecution(ctx context.Context, request *shared.DiagnoseWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.DiagnoseWorkflowExecutionResponse, error) {
+	response, err := w.service.DiagnoseWorkflowExecution(ctx, request, opts...)
+	return response, w.handle("DiagnoseWorkflowExecution", err)
+}
+
+func (w *workflowServiceErrorWrapper) FailoverDomain(ctx context.Context, request *shared.FailoverDomainRequest, opts ...yarpc.CallOption) (*shared.FailoverDomainResponse, error) {
+	response, err := w.service.FailoverDomain(ctx, request, opts...)
+	return response, w.handle("FailoverDomain", err)
+}
+
+func (w *workflowServiceErrorWrapper) GetClusterInfo(ctx context.Context, opts ...yarpc.CallOption) (*shared.ClusterInfo, error) {
+	response, err := w.service.GetClusterInfo(ctx, opts...)
+	return response, w.handle("GetClusterInfo", err)
+}
+
+func (w *workflowServiceErrorWrapper) GetSearchAttributes(ctx context.Context, opts ...yarpc.CallOption) (*shared.GetSearchAttributesResponse, error) {
+	response, err := w.service.GetSearchAttributes(ctx, opts...)
+	return response, w.handle("GetSearchAttributes", err)
+}
+
+func (w *workflowServiceErrorWrapper) GetTaskListsByDomain(ctx context.Context, request *shared.GetTaskListsByDomainRequest, opts ...yarpc.CallOption) (*shared.GetTaskListsByDomainResponse, error) {
+	response, err := w.service.GetTaskListsByDomain(ctx, request, opts...)
+	return response, w.handle("GetTaskListsByDomain", err)
+}
+
+func (w *workflowServiceErrorWrapper) GetWorkflowExecutionHistory(ctx context.Context, request *shared.GetWorkflowExecutionHistoryRequest, opts ...yarpc.CallOption) (*shared.GetWorkflowExecutionHistoryResponse, error) {
+	response, err := w.service.GetWorkflowExecutionHistory(ctx, request, opts...)
+	return response, w.handle("GetWorkflowExecutionHistory", err)
+}
+
+func (w *workflowServiceErrorWrapper) ListArchivedWorkflowExecutions(ctx context.Context, request *shared.ListArchivedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListArchivedWorkflowExecutionsResponse, error) {
+	response, err := w.service.ListArchivedWorkflowExecutions(ctx, request, opts...)
+	return response, w.handle("ListArchivedWorkflowExecutions", err)
+}
+
+func (w *workflowServiceErrorWrapper) ListClosedWorkflowExecutions(ctx context.Context, request *shared.ListClosedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListClosedWorkflowExecutionsResponse, error) {
+	response, err := w.service.ListClosedWorkflowExecutions(ctx, request, opts...)
+	return response, w.handle("ListClosedWorkflowExecutions", err)
+}
+
+func (w *workflowServiceErrorWrapper) ListDomains(ctx context.Context, request *shared.ListDomainsRequest, opts ...yarpc.CallOption) (*shared.ListDomainsResponse, error) {
+	response, err := w.service.ListDomains(ctx, request, opts...)
+	return response, w.handle("ListDomains", err)
+}
+
+func (w *workflowServiceErrorWrapper) ListFailoverHistory(ctx context.Context, request *shared.ListFailoverHistoryRequest, opts ...yarpc.CallOption) (*shared.ListFailoverHistoryResponse, error) {
+	response, err := w.service.ListFailoverHistory(ctx, request, opts...)
+	return response, w.handle("ListFailoverHistory", err)
+}
+
+func (w *workflowServiceErrorWrapper) ListOpenWorkflowExecutions(ctx context.Context, request *shared.ListOpenWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListOpenWorkflowExecutionsResponse, error) {
+	response, err := w.service.ListOpenWorkflowExecutions(ctx, request, opts...)
+	return response, w.handle("ListOpenWorkflowExecutions", err)
+}
+
+func (w *workflowServiceErrorWrapper) ListTaskListPartitions(ctx context.Context, request *shared.ListTaskListPartitionsRequest, opts ...yarpc.CallOption) (*shared.ListTaskListPartitionsResponse, error) {
+	response, err := w.service.ListTaskListPartitions(ctx, request, opts...)
+	return response, w.handle("ListTaskListPartitions", err)
+}
+
+func (w *workflowServiceErrorWrapper) ListWorkflowExecutions(ctx context.Context, request *shared.ListWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListWorkflowExecutionsResponse, error) {
+	response, err := w.service.ListWorkflowExecutions(ctx, request, opts...)
+	return response, w.handle("ListWorkflowExecutions", err)
+}
+
+func (w *workflowServiceErrorWrapper) PollForActivityTask(ctx context.Context, request *shared.PollForActivityTaskRequest, opts ...yarpc.CallOption) (*shared.PollForActivityTaskResponse, error) {
+	response, err := w.service.PollForActivityTask(ctx, request, opts...)
+	return response, w.handle("PollForActivityTask", err)
+}
+
+func (w *workflowServiceErrorWrapper) PollForDecisionTask(ctx context.Context, request *shared.PollForDecisionTaskRequest, opts ...yarpc.CallOption) (*shared.PollForDecisionTaskResponse, error) {
+	response, err := w.service.PollForDecisionTask(ctx, request, opts...)
+	return response, w.handle("PollForDecisionTask", err)
+}
+
+func (w *workflowServiceErrorWrapper) QueryWorkflow(ctx context.Context, request *shared.QueryWorkflowRequest, opts ...yarpc.CallOption) (*shared.QueryWorkflowResponse, error) {
+	response, err := w.service.QueryWorkflow(ctx, request, opts...)
+	return response, w.handle("QueryWorkflow", err)
+}
+
+func (w *workflowServiceErrorWrapper) RecordActivityTaskHeartbeat(ctx context.Context, request *shared.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) (*shared.RecordActivityTaskHeartbeatResponse, error) {
+	response, err := w.service.RecordActivityTaskHeartbeat(ctx, request, opts...)
+	return response, w.handle("RecordActivityTaskHeartbeat", err)
+}
+
+func (w *workflowServiceErrorWrapper) RecordActivityTaskHeartbeatByID(ctx context.Context, request *shared.RecordActivityTaskHeartbeatByIDRequest, opts ...yarpc.CallOption) (*shared.RecordActivityTaskHeartbeatResponse, error) {
+	response, err := w.service.RecordActivityTaskHeartbeatByID(ctx, request, opts...)
+	return response, w.handle("RecordActivityTaskHeartbeatByID", err)
+}
+
+func (w *workflowServiceErrorWrapper) RefreshWorkflowTasks(ctx context.Context, request *shared.RefreshWorkflowTasksRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RefreshWorkflowTasks", w.service.RefreshWorkflowTasks(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RegisterDomain(ctx context.Context, request *shared.RegisterDomainRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RegisterDomain", w.service.RegisterDomain(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RequestCancelWorkflowExecution(ctx context.Context, request *shared.RequestCancelWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RequestCancelWorkflowExecution", w.service.RequestCancelWorkflowExecution(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) ResetStickyTaskList(ctx context.Context, request *shared.ResetStickyTaskListRequest, opts ...yarpc.CallOption) (*shared.ResetStickyTaskListResponse, error) {
+	response, err := w.service.ResetStickyTaskList(ctx, request, opts...)
+	return response, w.handle("ResetStickyTaskList", err)
+}
+
+func (w *workflowServiceErrorWrapper) ResetWorkflowExecution(ctx context.Context, request *shared.ResetWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.ResetWorkflowExecutionResponse, error) {
+	response, err := w.service.ResetWorkflowExecution(ctx, request, opts...)
+	return response, w.handle("ResetWorkflowExecution", err)
+}
+
+func (w *workflowServiceErrorWrapper) RespondActivityTaskCanceled(ctx context.Context, request *shared.RespondActivityTaskCanceledRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RespondActivityTaskCanceled", w.service.RespondActivityTaskCanceled(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RespondActivityTaskCanceledByID(ctx context.Context, request *shared.RespondActivityTaskCanceledByIDRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RespondActivityTaskCanceledByID", w.service.RespondActivityTaskCanceledByID(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RespondActivityTaskCompleted(ctx context.Context, request *shared.RespondActivityTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RespondActivityTaskCompleted", w.service.RespondActivityTaskCompleted(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RespondActivityTaskCompletedByID(ctx context.Context, request *shared.RespondActivityTaskCompletedByIDRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RespondActivityTaskCompletedByID", w.service.RespondActivityTaskCompletedByID(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RespondActivityTaskFailed(ctx context.Context, request *shared.RespondActivityTaskFailedRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RespondActivityTaskFailed", w.service.RespondActivityTaskFailed(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RespondActivityTaskFailedByID(ctx context.Context, request *shared.RespondActivityTaskFailedByIDRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RespondActivityTaskFailedByID", w.service.RespondActivityTaskFailedByID(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RespondDecisionTaskCompleted(ctx context.Context, request *shared.RespondDecisionTaskCompletedRequest, opts ...yarpc.CallOption) (*shared.RespondDecisionTaskCompletedResponse, error) {
+	response, err := w.service.RespondDecisionTaskCompleted(ctx, request, opts...)
+	return response, w.handle("RespondDecisionTaskCompleted", err)
+}
+
+func (w *workflowServiceErrorWrapper) RespondDecisionTaskFailed(ctx context.Context, request *shared.RespondDecisionTaskFailedRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RespondDecisionTaskFailed", w.service.RespondDecisionTaskFailed(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RespondQueryTaskCompleted(ctx context.Context, request *shared.RespondQueryTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	return w.handle("RespondQueryTaskCompleted", w.service.RespondQueryTaskCompleted(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) RestartWorkflowExecution(ctx context.Context, request *shared.RestartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.RestartWorkflowExecutionResponse, error) {
+	response, err := w.service.RestartWorkflowExecution(ctx, request, opts...)
+	return response, w.handle("RestartWorkflowExecution", err)
+}
+
+func (w *workflowServiceErrorWrapper) ScanWorkflowExecutions(ctx context.Context, request *shared.ListWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListWorkflowExecutionsResponse, error) {
+	response, err := w.service.ScanWorkflowExecutions(ctx, request, opts...)
+	return response, w.handle("ScanWorkflowExecutions", err)
+}
+
+func (w *workflowServiceErrorWrapper) SignalWithStartWorkflowExecution(ctx context.Context, request *shared.SignalWithStartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+	response, err := w.service.SignalWithStartWorkflowExecution(ctx, request, opts...)
+	return response, w.handle("SignalWithStartWorkflowExecution", err)
+}
+
+func (w *workflowServiceErrorWrapper) SignalWithStartWorkflowExecutionAsync(ctx context.Context, request *shared.SignalWithStartWorkflowExecutionAsyncRequest, opts ...yarpc.CallOption) (*shared.SignalWithStartWorkflowExecutionAsyncResponse, error) {
+	response, err := w.service.SignalWithStartWorkflowExecutionAsync(ctx, request, opts...)
+	return response, w.handle("SignalWithStartWorkflowExecutionAsync", err)
+}
+
+func (w *workflowServiceErrorWrapper) SignalWorkflowExecution(ctx context.Context, request *shared.SignalWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	return w.handle("SignalWorkflowExecution", w.service.SignalWorkflowExecution(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) StartWorkflowExecution(ctx context.Context, request *shared.StartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+	response, err := w.service.StartWorkflowExecution(ctx, request, opts...)
+	return response, w.handle("StartWorkflowExecution", err)
+}
+
+func (w *workflowServiceErrorWrapper) StartWorkflowExecutionAsync(ctx context.Context, request *shared.StartWorkflowExecutionAsyncRequest, opts ...yarpc.CallOption) (*shared.StartWorkflowExecutionAsyncResponse, error) {
+	response, err := w.service.StartWorkflowExecutionAsync(ctx, request, opts...)
+	return response, w.handle("StartWorkflowExecutionAsync", err)
+}
+
+func (w *workflowServiceErrorWrapper) TerminateWorkflowExecution(ctx context.Context, request *shared.TerminateWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	return w.handle("TerminateWorkflowExecution", w.service.TerminateWorkflowExecution(ctx, request, opts...))
+}
+
+func (w *workflowServiceErrorWrapper) UpdateDomain(ctx context.Context, request *shared.UpdateDomainRequest, opts ...yarpc.CallOption) (*shared.UpdateDomainResponse, error) {
+	response, err := w.service.UpdateDomain(ctx, request, opts...)
+	return response, w.handle("UpdateDomain", err)
+}
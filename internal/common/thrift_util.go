@@ -23,17 +23,32 @@ package common
 import (
 	"context"
 	"reflect"
+	"sync"
 
 	"github.com/apache/thrift/lib/go/thrift"
 )
 
+var (
+	serializerPool = sync.Pool{
+		New: func() interface{} {
+			return thrift.NewTSerializer()
+		},
+	}
+	deserializerPool = sync.Pool{
+		New: func() interface{} {
+			return thrift.NewTDeserializer()
+		},
+	}
+)
+
 // TListSerialize is used to serialize list of thrift TStruct to []byte
 func TListSerialize(ts []thrift.TStruct) ([]byte, error) {
 	if ts == nil {
 		return nil, nil
 	}
 
-	t := thrift.NewTSerializer()
+	t := serializerPool.Get().(*thrift.TSerializer)
+	defer putTransport(&serializerPool, t, t.Transport)
 
 	// NOTE: we don't write any markers as thrift by design being a streaming protocol doesn't
 	// recommend writing length.
@@ -45,14 +60,16 @@ func TListSerialize(ts []thrift.TStruct) ([]byte, error) {
 			return nil, thrift.PrependError("error writing TStruct: ", e)
 		}
 	}
-
-	return t.Transport.Bytes(), t.Protocol.Flush(ctx)
+	if err := t.Protocol.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return append([]byte{}, t.Transport.Bytes()...), nil
 }
 
 // TListDeserialize is used to deserialize []byte to list of thrift TStruct
 func TListDeserialize(ts []thrift.TStruct, b []byte) (err error) {
-	t := thrift.NewTDeserializer()
-	err = nil
+	t := deserializerPool.Get().(*thrift.TDeserializer)
+	defer putTransport(&deserializerPool, t, t.Transport)
 	if _, err = t.Transport.Write(b); err != nil {
 		return
 	}
@@ -69,6 +86,16 @@ func TListDeserialize(ts []thrift.TStruct, b []byte) (err error) {
 	return
 }
 
+// putTransport returns a serializer or deserializer to its pool with its transport emptied, unless the transport grew
+// too large to be kept.
+func putTransport(pool *sync.Pool, value interface{}, transport *thrift.TMemoryBuffer) {
+	if transport.Cap() > maxPooledBufferSize {
+		return
+	}
+	transport.Reset()
+	pool.Put(value)
+}
+
 // IsUseThriftEncoding checks if the objects passed in are all encoded using thrift.
 func IsUseThriftEncoding(objs []interface{}) bool {
 	if len(objs) == 0 {
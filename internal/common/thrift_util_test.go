@@ -148,3 +148,68 @@ func (m *mockThriftStruct) Write(ctx context.Context, oprot thrift.TProtocol) er
 func (m *mockThriftStruct) String() string {
 	return ""
 }
+
+// payloadThriftStruct serializes its fields, unlike mockThriftStruct.
+type payloadThriftStruct struct {
+	Field1 string
+	Field2 int32
+}
+
+func (p *payloadThriftStruct) Read(ctx context.Context, iprot thrift.TProtocol) (err error) {
+	if p.Field1, err = iprot.ReadString(ctx); err != nil {
+		return err
+	}
+	p.Field2, err = iprot.ReadI32(ctx)
+	return err
+}
+
+func (p *payloadThriftStruct) Write(ctx context.Context, oprot thrift.TProtocol) error {
+	if err := oprot.WriteString(ctx, p.Field1); err != nil {
+		return err
+	}
+	return oprot.WriteI32(ctx, p.Field2)
+}
+
+func TestTListRoundTrip(t *testing.T) {
+	for i := 0; i < 3; i++ { // reuses the pooled serializers
+		data, err := TListSerialize([]thrift.TStruct{
+			&payloadThriftStruct{Field1: "value1", Field2: int32(i)},
+			&payloadThriftStruct{Field1: "value2", Field2: 2},
+		})
+		assert.NoError(t, err)
+
+		decoded := []thrift.TStruct{&payloadThriftStruct{}, &payloadThriftStruct{}}
+		assert.NoError(t, TListDeserialize(decoded, data))
+		assert.Equal(t, &payloadThriftStruct{Field1: "value1", Field2: int32(i)}, decoded[0])
+		assert.Equal(t, &payloadThriftStruct{Field1: "value2", Field2: 2}, decoded[1])
+	}
+}
+
+func BenchmarkTListSerialize(b *testing.B) {
+	ts := []thrift.TStruct{
+		&payloadThriftStruct{Field1: string(make([]byte, 1024)), Field2: 1},
+		&payloadThriftStruct{Field1: "value2", Field2: 2},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := TListSerialize(ts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTListDeserialize(b *testing.B) {
+	data, err := TListSerialize([]thrift.TStruct{
+		&payloadThriftStruct{Field1: string(make([]byte, 1024)), Field2: 1},
+		&payloadThriftStruct{Field1: "value2", Field2: 2},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := TListDeserialize([]thrift.TStruct{&payloadThriftStruct{}, &payloadThriftStruct{}}, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -32,6 +32,9 @@ func anyToString(d interface{}) string {
 	v := reflect.ValueOf(d)
 	switch v.Kind() {
 	case reflect.Ptr:
+		if v.IsNil() {
+			return fmt.Sprint(d)
+		}
 		return anyToString(v.Elem().Interface())
 	case reflect.Struct:
 		var buf bytes.Buffer
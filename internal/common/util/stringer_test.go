@@ -70,6 +70,11 @@ func Test_anyToString(t *testing.T) {
 			}{A: "test", B: 1, C: true},
 			expected: "(A:test, B:1, C:true)",
 		},
+		{
+			name:             "nil pointer",
+			thingToSerialize: (*s.DecisionTaskStartedEventAttributes)(nil),
+			expected:         "<nil>",
+		},
 		{
 			name:             "slice",
 			thingToSerialize: []int{1, 2, 3},
@@ -78,7 +78,7 @@ func Error(err error) error {
 	case *shared.LimitExceededError:
 		return protobuf.NewError(yarpcerrors.CodeResourceExhausted, e.Message, protobuf.WithErrorDetails(&apiv1.LimitExceededError{}))
 	case *shared.ServiceBusyError:
-		return protobuf.NewError(yarpcerrors.CodeResourceExhausted, e.Message, protobuf.WithErrorDetails(&apiv1.ServiceBusyError{}))
+		return protobuf.NewError(yarpcerrors.CodeResourceExhausted, e.Message, protobuf.WithErrorDetails(&apiv1.ServiceBusyError{Reason: e.GetReason()}))
 	}
 
 	return protobuf.NewError(yarpcerrors.CodeUnknown, err.Error())
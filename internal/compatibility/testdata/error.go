@@ -30,6 +30,7 @@ import (
 const (
 	ErrorMessage = "ErrorMessage"
 	FeatureFlag  = "FeatureFlag"
+	BusyReason   = "BusyReason"
 )
 
 var (
@@ -58,7 +59,7 @@ var (
 	InternalServiceError                   = protobuf.NewError(yarpcerrors.CodeInternal, ErrorMessage)
 	LimitExceededError                     = protobuf.NewError(yarpcerrors.CodeResourceExhausted, ErrorMessage, protobuf.WithErrorDetails(&apiv1.LimitExceededError{}))
 	QueryFailedError                       = protobuf.NewError(yarpcerrors.CodeInvalidArgument, ErrorMessage, protobuf.WithErrorDetails(&apiv1.QueryFailedError{}))
-	ServiceBusyError                       = protobuf.NewError(yarpcerrors.CodeResourceExhausted, ErrorMessage, protobuf.WithErrorDetails(&apiv1.ServiceBusyError{}))
+	ServiceBusyError                       = protobuf.NewError(yarpcerrors.CodeResourceExhausted, ErrorMessage, protobuf.WithErrorDetails(&apiv1.ServiceBusyError{Reason: BusyReason}))
 	WorkflowExecutionAlreadyStartedError   = protobuf.NewError(yarpcerrors.CodeAlreadyExists, ErrorMessage, protobuf.WithErrorDetails(&apiv1.WorkflowExecutionAlreadyStartedError{
 		StartRequestId: RequestID,
 		RunId:          RunID,
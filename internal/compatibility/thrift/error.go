@@ -112,7 +112,7 @@ func Error(err error) error {
 			}
 		}
 	case yarpcerrors.CodeResourceExhausted:
-		switch getErrorDetails(err).(type) {
+		switch details := getErrorDetails(err).(type) {
 		case *apiv1.LimitExceededError:
 			return &shared.LimitExceededError{
 				Message: status.Message(),
@@ -120,6 +120,7 @@ func Error(err error) error {
 		case *apiv1.ServiceBusyError:
 			return &shared.ServiceBusyError{
 				Message: status.Message(),
+				Reason:  common.StringPtr(details.Reason),
 			}
 		}
 	case yarpcerrors.CodeUnknown:
@@ -21,6 +21,9 @@
 package internal
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"reflect"
 
 	"go.uber.org/cadence/internal/common"
@@ -46,6 +49,15 @@ type (
 		Get(valuePtr ...interface{}) error
 	}
 
+	// Payload docs are in the public API to prevent duplication: [go.uber.org/cadence/encoded.Payload]
+	Payload interface {
+		// Size returns the number of bytes of the encoded values, 0 when there are no values.
+		Size() int
+		// TypeHints returns the types of the encoded values, as formatted by %T, when the DataConverter wrote them,
+		// see DataConverterOptions.TypeHints, and nil otherwise.
+		TypeHints() []string
+	}
+
 	// DataConverter is used by the framework to serialize/deserialize input and output of activity/workflow
 	// that need to be sent over the wire.
 	// To encode/decode workflow arguments, one should set DataConverter in two places:
@@ -64,8 +76,38 @@ type (
 		FromData(input []byte, valuePtr ...interface{}) error
 	}
 
+	// DataConverterOptions docs are in the public API to prevent duplication: [go.uber.org/cadence/encoded.DataConverterOptions]
+	DataConverterOptions struct {
+		// Optional: layout of the time.Time values, e.g. time.RFC3339 to drop the fractional seconds, used to encode
+		// them and to decode the ones of the arguments and results, including nested ones.
+		// Types implementing json.Marshaler or json.Unmarshaler themselves, other than time.Time, are not changed.
+		// default: time.RFC3339Nano, as encoded by encoding/json
+		TimeFormat string
+		// Optional: decode JSON numbers into interface{} values as float64 instead of json.Number.
+		// default: false
+		DecodeNumbersAsFloat64 bool
+		// Optional: fail to decode objects with fields that the struct they are decoded into does not have.
+		// This prevents adding or removing fields of the arguments of running workflows.
+		// default: false, unknown fields are ignored
+		DisallowUnknownFields bool
+		// Optional: indentation of the encoded JSON, e.g. to make the history easier to read.
+		// default: "", the JSON is not indented
+		Indent string
+		// Optional: write the types of the JSON encoded values before them, so that generic code such as routers and
+		// interceptors can introspect the payloads with Payload.TypeHints without decoding them, and skip them when
+		// decoding. The workers and clients without this option, of versions without type hints, or of other
+		// languages, fail to decode the payloads: enable it only once all of them have it.
+		// default: false
+		TypeHints bool
+	}
+
+	// RawPayload docs are in the public API to prevent duplication: [go.uber.org/cadence.RawPayload]
+	RawPayload []byte
+
 	// defaultDataConverter uses thrift encoder/decoder when possible, for everything else use json.
-	defaultDataConverter struct{}
+	defaultDataConverter struct {
+		options DataConverterOptions
+	}
 )
 
 var defaultJSONDataConverter = &defaultDataConverter{}
@@ -78,22 +120,38 @@ func getDefaultDataConverter() DataConverter {
 	return defaultJSONDataConverter
 }
 
+// NewDefaultDataConverter docs are in the public API to prevent duplication: [go.uber.org/cadence/encoded.NewDefaultDataConverter]
+func NewDefaultDataConverter(options DataConverterOptions) DataConverter {
+	return &defaultDataConverter{options: options}
+}
+
 func (dc *defaultDataConverter) ToData(r ...interface{}) ([]byte, error) {
 	if len(r) == 1 && util.IsTypeByteSlice(reflect.TypeOf(r[0])) {
 		return r[0].([]byte), nil
 	}
+	if len(r) == 1 {
+		if raw, ok := r[0].(RawPayload); ok {
+			return raw, nil
+		}
+	}
+	if err := checkRawPayload(r); err != nil {
+		return nil, err
+	}
 
 	var encoder encoding
 	if common.IsUseThriftEncoding(r) {
 		encoder = &thriftEncoding{}
 	} else {
-		encoder = &jsonEncoding{}
+		encoder = &jsonEncoding{options: dc.options}
 	}
 
 	data, err := encoder.Marshal(r)
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := encoder.(*jsonEncoding); ok && dc.options.TypeHints {
+		return append(encodeTypeHints(r), data...), nil
+	}
 	return data, nil
 }
 
@@ -102,13 +160,69 @@ func (dc *defaultDataConverter) FromData(data []byte, to ...interface{}) error {
 		reflect.ValueOf(to[0]).Elem().SetBytes(data)
 		return nil
 	}
+	if len(to) == 1 {
+		if raw, ok := to[0].(*RawPayload); ok {
+			*raw = data
+			return nil
+		}
+	}
+	if err := checkRawPayload(to); err != nil {
+		return err
+	}
 
 	var encoder encoding
 	if common.IsUseThriftDecoding(to) {
 		encoder = &thriftEncoding{}
 	} else {
-		encoder = &jsonEncoding{}
+		encoder = &jsonEncoding{options: dc.options}
+		if dc.options.TypeHints {
+			_, data = splitTypeHints(data)
+		}
 	}
 
 	return encoder.Unmarshal(data, to)
 }
+
+// checkRawPayload returns an error when one of several values is a RawPayload, as its bytes cannot be delimited from
+// the encoded values around it.
+func checkRawPayload(values []interface{}) error {
+	if len(values) < 2 {
+		return nil
+	}
+	for i, v := range values {
+		switch v.(type) {
+		case RawPayload, *RawPayload:
+			return fmt.Errorf("RawPayload must be the only value, found at index %d of %d values", i, len(values))
+		}
+	}
+	return nil
+}
+
+// typeHintsPrefix starts the line of type hints written before the values, see DataConverterOptions.TypeHints.
+var typeHintsPrefix = []byte(`{"cadenceTypeHints":`)
+
+type typeHints struct {
+	CadenceTypeHints []string `json:"cadenceTypeHints"`
+}
+
+func encodeTypeHints(values []interface{}) []byte {
+	hints := typeHints{CadenceTypeHints: make([]string, len(values))}
+	for i, v := range values {
+		hints.CadenceTypeHints[i] = fmt.Sprintf("%T", v)
+	}
+	data, _ := json.Marshal(hints) // cannot fail for a slice of strings
+	return append(data, '\n')
+}
+
+// splitTypeHints returns the type hints written before the values in data, if any, and the encoded values.
+func splitTypeHints(data []byte) ([]string, []byte) {
+	if !bytes.HasPrefix(data, typeHintsPrefix) {
+		return nil, data
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var hints typeHints
+	if err := dec.Decode(&hints); err != nil {
+		return nil, data
+	}
+	return hints.CadenceTypeHints, bytes.TrimPrefix(data[dec.InputOffset():], []byte("\n"))
+}
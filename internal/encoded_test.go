@@ -22,10 +22,13 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -128,3 +131,245 @@ func TestDecodeArg(t *testing.T) {
 	require.NoError(t, err)
 	require.Error(t, decodeArg(dc, b, &r))
 }
+
+func TestDecodeArgsStructEvolution(t *testing.T) {
+	t.Parallel()
+	type orderV1 struct {
+		ID       string
+		Amount   int
+		Discount int
+	}
+	type orderV2 struct {
+		ID       string
+		Amount   int
+		Currency string
+	}
+
+	// fields added to or removed from a single argument struct do not break decoding the arguments of running
+	// workflows, unlike positional arguments
+	b, err := encodeArgs(nil, []interface{}{orderV1{ID: "order-1", Amount: 10, Discount: 2}})
+	require.NoError(t, err)
+	values, err := decodeArgs(nil, reflect.TypeOf(func(ctx Context, order orderV2) error { return nil }), b)
+	require.NoError(t, err)
+	require.Equal(t, orderV2{ID: "order-1", Amount: 10}, values[0].Interface())
+
+	b, err = encodeArgs(nil, []interface{}{"order-1"})
+	require.NoError(t, err)
+	_, err = decodeArgs(nil, reflect.TypeOf(func(ctx Context, id string, amount int) error { return nil }), b)
+	require.Error(t, err, "a positional argument cannot be added")
+}
+
+func TestDefaultDataConverterOptions(t *testing.T) {
+	t.Parallel()
+	type Audit struct {
+		CreatedAt time.Time `json:"created"`
+	}
+	type event struct {
+		Name string
+		Audit
+		At       time.Time
+		Optional *time.Time `json:",omitempty"`
+		History  []time.Time
+		ByName   map[string]time.Time
+		Raw      string
+	}
+	at := time.Date(2026, 10, 16, 10, 30, 0, 123, time.UTC)
+	value := event{
+		Name:    "created",
+		Audit:   Audit{CreatedAt: at},
+		At:      at,
+		History: []time.Time{at},
+		ByName:  map[string]time.Time{"first": at},
+		Raw:     "2026-10-16T10:30:00Z",
+	}
+
+	t.Run("time format", func(t *testing.T) {
+		t.Parallel()
+		dc := NewDefaultDataConverter(DataConverterOptions{TimeFormat: time.DateTime})
+		data, err := dc.ToData(value, at)
+		require.NoError(t, err)
+		require.Equal(t, `{"Name":"created","created":"2026-10-16 10:30:00","At":"2026-10-16 10:30:00",`+
+			`"History":["2026-10-16 10:30:00"],"ByName":{"first":"2026-10-16 10:30:00"},"Raw":"2026-10-16T10:30:00Z"}`+"\n"+
+			`"2026-10-16 10:30:00"`+"\n", string(data))
+
+		var decoded event
+		var decodedAt time.Time
+		require.NoError(t, dc.FromData(data, &decoded, &decodedAt))
+		truncated := at.Truncate(time.Second)
+		require.Equal(t, event{
+			Name:    "created",
+			Audit:   Audit{CreatedAt: truncated},
+			At:      truncated,
+			History: []time.Time{truncated},
+			ByName:  map[string]time.Time{"first": truncated},
+			Raw:     "2026-10-16T10:30:00Z",
+		}, decoded)
+		require.Equal(t, truncated, decodedAt)
+
+		// times encoded with another format are not decoded
+		data, err = getDefaultDataConverter().ToData(at)
+		require.NoError(t, err)
+		require.Error(t, dc.FromData(data, &decodedAt))
+	})
+
+	t.Run("numbers", func(t *testing.T) {
+		t.Parallel()
+		var number, float interface{}
+		require.NoError(t, getDefaultDataConverter().FromData([]byte("1.5"), &number))
+		require.Equal(t, json.Number("1.5"), number)
+		require.NoError(t, NewDefaultDataConverter(DataConverterOptions{DecodeNumbersAsFloat64: true}).FromData([]byte("1.5"), &float))
+		require.Equal(t, 1.5, float)
+	})
+
+	t.Run("unknown fields", func(t *testing.T) {
+		t.Parallel()
+		var decoded Audit
+		data := []byte(`{"created":"2026-10-16T10:30:00Z","deleted":"2026-10-17T10:30:00Z"}`)
+		require.NoError(t, getDefaultDataConverter().FromData(data, &decoded))
+		err := NewDefaultDataConverter(DataConverterOptions{DisallowUnknownFields: true}).FromData(data, &decoded)
+		require.ErrorContains(t, err, `unknown field "deleted"`)
+	})
+
+	t.Run("indent", func(t *testing.T) {
+		t.Parallel()
+		data, err := NewDefaultDataConverter(DataConverterOptions{Indent: "  "}).ToData(Audit{CreatedAt: at})
+		require.NoError(t, err)
+		require.Equal(t, "{\n  \"created\": \"2026-10-16T10:30:00.000000123Z\"\n}\n", string(data))
+	})
+}
+
+func BenchmarkDefaultDataConverter(b *testing.B) {
+	type payload struct {
+		ID     string
+		Amount int
+		Items  []string
+	}
+	value := payload{ID: "order-1", Amount: 42, Items: make([]string, 64)}
+	for i := range value.Items {
+		value.Items[i] = fmt.Sprintf("item-%d", i)
+	}
+	b.Run("ToData", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := DefaultDataConverter.ToData("change-id", Version(1), value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("FromData", func(b *testing.B) {
+		data, err := DefaultDataConverter.ToData("change-id", Version(1), value)
+		require.NoError(b, err)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var changeID string
+			var version Version
+			var decoded payload
+			if err := DefaultDataConverter.FromData(data, &changeID, &version, &decoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestRawPayload(t *testing.T) {
+	t.Parallel()
+	dc := NewDefaultDataConverter(DataConverterOptions{Indent: "  "})
+	payload := RawPayload{0x0a, 0x03, 'f', 'o', 'o', 0xff}
+
+	data, err := dc.ToData(payload)
+	require.NoError(t, err)
+	require.Equal(t, []byte(payload), data)
+	var decoded RawPayload
+	require.NoError(t, dc.FromData(data, &decoded))
+	require.Equal(t, payload, decoded)
+
+	_, err = dc.ToData("value", payload)
+	require.EqualError(t, err, "RawPayload must be the only value, found at index 1 of 2 values")
+	var value string
+	err = dc.FromData(data, &decoded, &value)
+	require.EqualError(t, err, "RawPayload must be the only value, found at index 0 of 2 values")
+}
+
+func TestRawPayloadActivity(t *testing.T) {
+	payload := RawPayload{0x0a, 0x03, 'f', 'o', 'o', 0xff}
+	activityFn := func(ctx context.Context, in RawPayload) (RawPayload, error) {
+		return append(in, 0x00), nil
+	}
+	workflowFn := func(ctx Context) (RawPayload, error) {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		var result RawPayload
+		err := ExecuteActivity(ctx, activityFn, payload).Get(ctx, &result)
+		return result, err
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityFn)
+	var input []byte
+	env.SetOnActivityStartedListener(func(_ *ActivityInfo, _ context.Context, args Values) {
+		input = args.(*EncodedValues).values
+	})
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []byte(payload), input, "the payload is not encoded")
+	var result RawPayload
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, append(payload, 0x00), result)
+}
+
+func TestDataConverterTypeHints(t *testing.T) {
+	t.Parallel()
+	dc := NewDefaultDataConverter(DataConverterOptions{TypeHints: true, Indent: "  "})
+	value := testStruct{Name: "name", Age: 1}
+
+	data, err := dc.ToData("text", value)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, []byte(`{"cadenceTypeHints":["string","internal.testStruct"]}`+"\n\"text\"\n")), string(data))
+	values := newEncodedValues(data, dc).(Payload)
+	require.Equal(t, []string{"string", "internal.testStruct"}, values.TypeHints())
+	require.Equal(t, len(data), values.Size())
+
+	var text string
+	var decoded testStruct
+	require.NoError(t, newEncodedValues(data, dc).Get(&text, &decoded))
+	require.Equal(t, "text", text)
+	require.Equal(t, value, decoded)
+
+	// raw payloads are not changed
+	data, err = dc.ToData([]byte("raw"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("raw"), data)
+	require.Nil(t, newEncodedValue(data, dc).(Payload).TypeHints())
+
+	data, err = getDefaultDataConverter().ToData(value)
+	require.NoError(t, err)
+	require.Nil(t, newEncodedValue(data, dc).(Payload).TypeHints())
+	require.Equal(t, len(data), newEncodedValue(data, dc).(Payload).Size())
+	require.Zero(t, newEncodedValue(nil, dc).(Payload).Size())
+}
+
+func TestDataConverterTypeHintsDisabled(t *testing.T) {
+	t.Parallel()
+	// without the option, values looking like type hints are decoded as they are
+	data, err := getDefaultDataConverter().ToData(map[string][]string{"cadenceTypeHints": {"string"}}, "text")
+	require.NoError(t, err)
+	var decoded map[string][]string
+	var text string
+	require.NoError(t, getDefaultDataConverter().FromData(data, &decoded, &text))
+	require.Equal(t, map[string][]string{"cadenceTypeHints": {"string"}}, decoded)
+	require.Equal(t, "text", text)
+}
+
+func TestErrorDetailsValuesSizeAndTypeHints(t *testing.T) {
+	t.Parallel()
+	details := ErrorDetailsValues{"text", 1}
+	require.Equal(t, []string{"string", "int"}, details.TypeHints())
+	require.Equal(t, len("\"text\"\n1\n"), details.Size())
+	require.Nil(t, ErrorDetailsValues(nil).TypeHints())
+	require.Zero(t, ErrorDetailsValues(nil).Size())
+}
@@ -40,13 +40,26 @@ type encoding interface {
 
 // jsonEncoding encapsulates json encoding and decoding
 type jsonEncoding struct {
+	options DataConverterOptions
 }
 
 // Marshal encodes an array of object into bytes
 func (g jsonEncoding) Marshal(objs []interface{}) ([]byte, error) {
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
+	buf := common.GetBuffer()
+	defer common.PutBuffer(buf)
+	enc := json.NewEncoder(buf)
+	if g.options.Indent != "" {
+		enc.SetIndent("", g.options.Indent)
+	}
 	for i, obj := range objs {
+		if g.options.TimeFormat != "" {
+			node, err := formatTimes(obj, g.options.TimeFormat)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"unable to encode argument: %d, %v, with json error: %v", i, reflect.TypeOf(obj), err)
+			}
+			obj = node
+		}
 		if err := enc.Encode(obj); err != nil {
 			if err == io.EOF {
 				return nil, fmt.Errorf("missing argument at index %d of type %T", i, obj)
@@ -55,22 +68,51 @@ func (g jsonEncoding) Marshal(objs []interface{}) ([]byte, error) {
 				"unable to encode argument: %d, %v, with json error: %v", i, reflect.TypeOf(obj), err)
 		}
 	}
-	return buf.Bytes(), nil
+	return common.CopyBytes(buf), nil
 }
 
 // Unmarshal decodes a byte array into the passed in objects
 func (g jsonEncoding) Unmarshal(data []byte, objs []interface{}) error {
-	dec := json.NewDecoder(bytes.NewBuffer(data))
-	dec.UseNumber()
+	dec := g.newDecoder(bytes.NewReader(data))
 	for i, obj := range objs {
-		if err := dec.Decode(obj); err != nil {
+		var err error
+		if g.options.TimeFormat != "" {
+			err = g.decodeWithTimeFormat(dec, obj)
+		} else {
+			err = dec.Decode(obj)
+		}
+		if err != nil {
 			return fmt.Errorf(
-				"unable to decode argument: %d, %v, with json error: %v", i, reflect.TypeOf(obj), err)
+				"unable to decode argument: %d, %v, with json error: %w", i, reflect.TypeOf(obj), err)
 		}
 	}
 	return nil
 }
 
+func (g jsonEncoding) newDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	if !g.options.DecodeNumbersAsFloat64 {
+		dec.UseNumber()
+	}
+	if g.options.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	return dec
+}
+
+// decodeWithTimeFormat decodes the next value of dec, with the times formatted with DataConverterOptions.TimeFormat.
+func (g jsonEncoding) decodeWithTimeFormat(dec *json.Decoder, obj interface{}) error {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	data, err := parseTimes(raw, reflect.TypeOf(obj), g.options.TimeFormat)
+	if err != nil {
+		return err
+	}
+	return g.newDecoder(bytes.NewReader(data)).Decode(obj)
+}
+
 // thriftEncoding encapsulates thrift serializer/de-serializer.
 type thriftEncoding struct{}
 
@@ -91,6 +91,7 @@ type (
 	CustomError struct {
 		reason  string
 		details Values
+		failure error // decoded failure of a registered type, see RegisterFailureType
 	}
 
 	// GenericError returned from workflow/workflow when the implementations return errors other than from NewCustomError() API.
@@ -115,8 +116,9 @@ type (
 
 	// PanicError contains information about panicked workflow/activity.
 	PanicError struct {
-		value      interface{}
-		stackTrace string
+		value        interface{}
+		stackTrace   string
+		workflowArgs string
 	}
 
 	// workflowPanicError contains information about panicked workflow.
@@ -169,6 +171,11 @@ type (
 		// DecisionText contains a String() representation of a replay decision
 		// event (i.e. created during replay) that is related to the problem.
 		DecisionText string
+
+		// DecisionDiff aligns all the decisions of the replay with the decision events
+		// of the history, when the non-determinism was detected by comparing them.
+		// It is nil otherwise, e.g. when the replay panicked on an illegal state.
+		DecisionDiff *HistoryDiff
 	}
 
 	// ContinueAsNewError contains information about how to continue the workflow as new.
@@ -209,6 +216,27 @@ var ErrTooManyArg = errors.New("too many arguments")
 // that could report the activity completed event to cadence server via Client.CompleteActivity() API.
 var ErrActivityResultPending = errors.New("not error: do not autocomplete, using Client.CompleteActivity() to complete")
 
+var (
+	// ErrActivityWorkerShutdown is the cause of the cancellation of the context of the activities running when their
+	// worker is stopped, returned by context.Cause.
+	ErrActivityWorkerShutdown = errors.New("activity worker is shutting down")
+
+	// ErrActivityCancelRequested is the cause of the cancellation of the context of an activity whose heartbeat
+	// reported that its cancellation was requested, by its workflow or because its workflow is being canceled,
+	// returned by context.Cause.
+	ErrActivityCancelRequested = errors.New("activity cancellation requested")
+
+	// ErrActivityWorkflowClosed is the cause of the cancellation of the context of an activity whose heartbeat
+	// reported that its workflow is closed, e.g. completed, terminated or timed out, or that the activity no longer
+	// exists. context.Cause returns it wrapped with the error returned by the server.
+	ErrActivityWorkflowClosed = errors.New("activity workflow is closed")
+
+	// ErrActivityDomainNotActive is the cause of the cancellation of the context of an activity whose heartbeat
+	// reported that its domain is no longer active in this cluster, after a failover. context.Cause returns it wrapped
+	// with the error returned by the server.
+	ErrActivityDomainNotActive = errors.New("activity domain is not active")
+)
+
 // NewCustomError create new instance of *CustomError with reason and optional details.
 func NewCustomError(reason string, details ...interface{}) *CustomError {
 	if strings.HasPrefix(reason, "cadenceInternal:") {
@@ -300,9 +328,18 @@ func NewContinueAsNewError(ctx Context, wfn interface{}, args ...interface{}) *C
 
 // Error from error interface
 func (e *CustomError) Error() string {
+	if e.failure != nil {
+		return e.failure.Error()
+	}
 	return e.reason
 }
 
+// Unwrap returns the failure of a registered type decoded from the details of this custom error, see
+// RegisterFailureType.
+func (e *CustomError) Unwrap() error {
+	return e.failure
+}
+
 // Reason gets the reason of this custom error
 func (e *CustomError) Reason() string {
 	return e.reason
@@ -385,6 +422,12 @@ func (e *PanicError) StackTrace() string {
 	return e.stackTrace
 }
 
+// WorkflowArgs returns the JSON of the arguments of the panicking workflow, redacted and truncated, or an empty string
+// if they were not captured, see WorkerOptions.WorkflowPanicArgs.
+func (e *PanicError) WorkflowArgs() string {
+	return e.workflowArgs
+}
+
 // Error from error interface
 func (e *workflowPanicError) Error() string {
 	return fmt.Sprintf("%v", e.value)
@@ -450,6 +493,31 @@ func (b ErrorDetailsValues) HasValues() bool {
 	return b != nil && len(b) != 0
 }
 
+// Size returns the number of bytes of the values once encoded by the default DataConverter, as they are not encoded
+// yet, or 0 when they cannot be encoded.
+func (b ErrorDetailsValues) Size() int {
+	if !b.HasValues() {
+		return 0
+	}
+	data, err := getDefaultDataConverter().ToData(b...)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// TypeHints returns the types of the values, as they are not encoded yet.
+func (b ErrorDetailsValues) TypeHints() []string {
+	if !b.HasValues() {
+		return nil
+	}
+	hints := make([]string, len(b))
+	for i, v := range b {
+		hints[i] = fmt.Sprintf("%T", v)
+	}
+	return hints
+}
+
 // Get extract data from encoded data to desired value type. valuePtr is pointer to the actual value type.
 func (b ErrorDetailsValues) Get(valuePtr ...interface{}) error {
 	if !b.HasValues() {
@@ -0,0 +1,330 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/util"
+)
+
+// maxHistoryDiffCells bounds the size of the alignment table used by the diff. When the
+// differing window of the two inputs is larger than this, entries are paired positionally.
+const maxHistoryDiffCells = 4 * 1024 * 1024
+
+// HistoryDiffType describes how a single HistoryDiffEntry differs between the two inputs.
+type HistoryDiffType int
+
+const (
+	// HistoryDiffTypeEqual means the entry is present in both inputs.
+	HistoryDiffTypeEqual HistoryDiffType = iota
+	// HistoryDiffTypeMissing means the entry is present in the expected input only.
+	HistoryDiffTypeMissing
+	// HistoryDiffTypeExtra means the entry is present in the actual input only.
+	HistoryDiffTypeExtra
+	// HistoryDiffTypeMismatch means both inputs have an entry at this position, but they do not match.
+	HistoryDiffTypeMismatch
+)
+
+type (
+	// HistoryDiffEntry is a single aligned line of a HistoryDiff.
+	// Expected* fields are empty for HistoryDiffTypeExtra, Actual* fields are empty for HistoryDiffTypeMissing.
+	// EventIDs are 0 when the entry is a decision rather than a history event.
+	HistoryDiffEntry struct {
+		Type            HistoryDiffType
+		ExpectedEventID int64
+		ActualEventID   int64
+		ExpectedText    string
+		ActualText      string
+	}
+
+	// HistoryDiff is the structured result of comparing two histories, or replay decisions with a recorded history.
+	HistoryDiff struct {
+		Entries []HistoryDiffEntry
+	}
+
+	// diffItem is the common view of a history event or a decision used by the alignment.
+	diffItem struct {
+		eventID  int64
+		text     string
+		event    *s.HistoryEvent
+		decision *s.Decision
+	}
+)
+
+// String returns the string representation of a HistoryDiffType.
+func (t HistoryDiffType) String() string {
+	switch t {
+	case HistoryDiffTypeEqual:
+		return "Equal"
+	case HistoryDiffTypeMissing:
+		return "Missing"
+	case HistoryDiffTypeExtra:
+		return "Extra"
+	case HistoryDiffTypeMismatch:
+		return "Mismatch"
+	default:
+		return fmt.Sprintf("HistoryDiffType(%d)", int(t))
+	}
+}
+
+// DiffHistories compares two histories, e.g. an original run and a run reset from it, and returns the aligned diff.
+// Events are matched on their type and identifying attributes (activity ID and type, timer ID, marker name, signal
+// name, child workflow type), so event IDs, timestamps and payloads that legitimately differ between runs are ignored.
+func DiffHistories(expected, actual *s.History) *HistoryDiff {
+	return diffItems(historyDiffItems(expected.GetEvents()), historyDiffItems(actual.GetEvents()), func(e, a diffItem) bool {
+		return historyEventDiffKey(e.event) == historyEventDiffKey(a.event)
+	})
+}
+
+// DiffDecisionsWithHistory compares decisions produced by a replay with the decision events recorded in a history.
+// Matching follows the same rules as the replay non-determinism check, and version markers are ignored on both sides.
+func DiffDecisionsWithHistory(decisions []*s.Decision, history *s.History) *HistoryDiff {
+	var recorded []diffItem
+	events := history.GetEvents()
+	for i := 0; i < len(events); i++ {
+		if skipDeterministicCheckForUpsertChangeVersion(events, i) {
+			i++
+			continue
+		}
+		e := events[i]
+		if !isDecisionEvent(e.GetEventType()) || skipDeterministicCheckForEvent(e) {
+			continue
+		}
+		recorded = append(recorded, diffItem{eventID: e.GetEventId(), text: util.HistoryEventToString(e), event: e})
+	}
+
+	var replayed []diffItem
+	for _, d := range decisions {
+		if skipDeterministicCheckForDecision(d) {
+			continue
+		}
+		replayed = append(replayed, diffItem{text: util.DecisionToString(d), decision: d})
+	}
+
+	return diffItems(recorded, replayed, func(e, a diffItem) bool {
+		return isDecisionMatchEvent(a.decision, e.event, false)
+	})
+}
+
+// HasDifferences returns true if any entry of the diff is not HistoryDiffTypeEqual.
+func (d *HistoryDiff) HasDifferences() bool {
+	for _, entry := range d.Entries {
+		if entry.Type != HistoryDiffTypeEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// Differences returns only the entries that are not HistoryDiffTypeEqual.
+func (d *HistoryDiff) Differences() []HistoryDiffEntry {
+	var result []HistoryDiffEntry
+	for _, entry := range d.Entries {
+		if entry.Type != HistoryDiffTypeEqual {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// String renders the diff in a unified-diff like format, one entry per line:
+// "  " for equal entries, "- " for missing, "+ " for extra and a "- "/"+ " pair for mismatched ones.
+func (d *HistoryDiff) String() string {
+	var buf bytes.Buffer
+	for _, entry := range d.Entries {
+		switch entry.Type {
+		case HistoryDiffTypeEqual:
+			writeHistoryDiffLine(&buf, "  ", entry.ActualEventID, entry.ActualText)
+		case HistoryDiffTypeMissing:
+			writeHistoryDiffLine(&buf, "- ", entry.ExpectedEventID, entry.ExpectedText)
+		case HistoryDiffTypeExtra:
+			writeHistoryDiffLine(&buf, "+ ", entry.ActualEventID, entry.ActualText)
+		case HistoryDiffTypeMismatch:
+			writeHistoryDiffLine(&buf, "- ", entry.ExpectedEventID, entry.ExpectedText)
+			writeHistoryDiffLine(&buf, "+ ", entry.ActualEventID, entry.ActualText)
+		}
+	}
+	return buf.String()
+}
+
+func writeHistoryDiffLine(buf *bytes.Buffer, prefix string, eventID int64, text string) {
+	buf.WriteString(prefix)
+	if eventID > 0 {
+		buf.WriteString(fmt.Sprintf("[%d] ", eventID))
+	}
+	buf.WriteString(text)
+	buf.WriteString("\n")
+}
+
+func historyDiffItems(events []*s.HistoryEvent) []diffItem {
+	items := make([]diffItem, 0, len(events))
+	for _, e := range events {
+		items = append(items, diffItem{eventID: e.GetEventId(), text: util.HistoryEventToString(e), event: e})
+	}
+	return items
+}
+
+// historyEventDiffKey returns the identity of an event, i.e. the attributes which are expected to be the same
+// for the "same" event across different runs of a workflow.
+func historyEventDiffKey(e *s.HistoryEvent) string {
+	key := e.GetEventType().String()
+	switch e.GetEventType() {
+	case s.EventTypeActivityTaskScheduled:
+		attr := e.GetActivityTaskScheduledEventAttributes()
+		key += ":" + attr.GetActivityId() + ":" + lastPartOfName(attr.GetActivityType().GetName())
+	case s.EventTypeTimerStarted:
+		key += ":" + e.GetTimerStartedEventAttributes().GetTimerId()
+	case s.EventTypeTimerCanceled:
+		key += ":" + e.GetTimerCanceledEventAttributes().GetTimerId()
+	case s.EventTypeMarkerRecorded:
+		key += ":" + e.GetMarkerRecordedEventAttributes().GetMarkerName()
+	case s.EventTypeWorkflowExecutionSignaled:
+		key += ":" + e.GetWorkflowExecutionSignaledEventAttributes().GetSignalName()
+	case s.EventTypeSignalExternalWorkflowExecutionInitiated:
+		key += ":" + e.GetSignalExternalWorkflowExecutionInitiatedEventAttributes().GetSignalName()
+	case s.EventTypeStartChildWorkflowExecutionInitiated:
+		key += ":" + lastPartOfName(e.GetStartChildWorkflowExecutionInitiatedEventAttributes().GetWorkflowType().GetName())
+	}
+	return key
+}
+
+// diffItems aligns expected and actual using the longest common subsequence of matching items.
+// Runs of unmatched items on both sides are paired up positionally and reported as mismatches.
+func diffItems(expected, actual []diffItem, match func(e, a diffItem) bool) *HistoryDiff {
+	// trim common prefix and suffix, which is the vast majority of real histories
+	prefix := 0
+	for prefix < len(expected) && prefix < len(actual) && match(expected[prefix], actual[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(expected)-prefix && suffix < len(actual)-prefix &&
+		match(expected[len(expected)-1-suffix], actual[len(actual)-1-suffix]) {
+		suffix++
+	}
+
+	diff := &HistoryDiff{}
+	for i := 0; i < prefix; i++ {
+		diff.Entries = append(diff.Entries, equalDiffEntry(expected[i], actual[i]))
+	}
+
+	midExpected := expected[prefix : len(expected)-suffix]
+	midActual := actual[prefix : len(actual)-suffix]
+	diff.Entries = append(diff.Entries, alignDiffItems(midExpected, midActual, match)...)
+
+	for i := 0; i < suffix; i++ {
+		diff.Entries = append(diff.Entries, equalDiffEntry(expected[len(expected)-suffix+i], actual[len(actual)-suffix+i]))
+	}
+	return diff
+}
+
+func alignDiffItems(expected, actual []diffItem, match func(e, a diffItem) bool) []HistoryDiffEntry {
+	n, m := len(expected), len(actual)
+	if n == 0 && m == 0 {
+		return nil
+	}
+	if (n+1)*(m+1) > maxHistoryDiffCells {
+		return pairUnmatchedDiffItems(expected, actual)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of expected[i:] and actual[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if match(expected[i], actual[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var entries []HistoryDiffEntry
+	var pendingExpected, pendingActual []diffItem
+	flush := func() {
+		entries = append(entries, pairUnmatchedDiffItems(pendingExpected, pendingActual)...)
+		pendingExpected, pendingActual = nil, nil
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && match(expected[i], actual[j]):
+			flush()
+			entries = append(entries, equalDiffEntry(expected[i], actual[j]))
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			pendingExpected = append(pendingExpected, expected[i])
+			i++
+		default:
+			pendingActual = append(pendingActual, actual[j])
+			j++
+		}
+	}
+	flush()
+	return entries
+}
+
+func pairUnmatchedDiffItems(expected, actual []diffItem) []HistoryDiffEntry {
+	var entries []HistoryDiffEntry
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		switch {
+		case i < len(expected) && i < len(actual):
+			entries = append(entries, HistoryDiffEntry{
+				Type:            HistoryDiffTypeMismatch,
+				ExpectedEventID: expected[i].eventID,
+				ExpectedText:    expected[i].text,
+				ActualEventID:   actual[i].eventID,
+				ActualText:      actual[i].text,
+			})
+		case i < len(expected):
+			entries = append(entries, HistoryDiffEntry{
+				Type:            HistoryDiffTypeMissing,
+				ExpectedEventID: expected[i].eventID,
+				ExpectedText:    expected[i].text,
+			})
+		default:
+			entries = append(entries, HistoryDiffEntry{
+				Type:          HistoryDiffTypeExtra,
+				ActualEventID: actual[i].eventID,
+				ActualText:    actual[i].text,
+			})
+		}
+	}
+	return entries
+}
+
+func equalDiffEntry(expected, actual diffItem) HistoryDiffEntry {
+	return HistoryDiffEntry{
+		Type:            HistoryDiffTypeEqual,
+		ExpectedEventID: expected.eventID,
+		ExpectedText:    expected.text,
+		ActualEventID:   actual.eventID,
+		ActualText:      actual.text,
+	}
+}
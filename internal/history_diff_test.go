@@ -0,0 +1,172 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func TestDiffHistories(t *testing.T) {
+	activity := func(eventID int64, activityID string) *s.HistoryEvent {
+		return createTestEventActivityTaskScheduled(eventID, &s.ActivityTaskScheduledEventAttributes{
+			ActivityId:   common.StringPtr(activityID),
+			ActivityType: &s.ActivityType{Name: common.StringPtr("main.testActivity")},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		expected []*s.HistoryEvent
+		actual   []*s.HistoryEvent
+		types    []HistoryDiffType
+	}{
+		{
+			name:     "identical",
+			expected: []*s.HistoryEvent{createTestEventDecisionTaskStarted(1), activity(2, "0")},
+			actual:   []*s.HistoryEvent{createTestEventDecisionTaskStarted(1), activity(2, "0")},
+			types:    []HistoryDiffType{HistoryDiffTypeEqual, HistoryDiffTypeEqual},
+		},
+		{
+			name:     "event IDs are ignored",
+			expected: []*s.HistoryEvent{activity(5, "0"), createTestEventTimerStarted(6, 1)},
+			actual:   []*s.HistoryEvent{activity(10, "0"), createTestEventTimerStarted(11, 1)},
+			types:    []HistoryDiffType{HistoryDiffTypeEqual, HistoryDiffTypeEqual},
+		},
+		{
+			name:     "missing event",
+			expected: []*s.HistoryEvent{activity(1, "0"), createTestEventTimerStarted(2, 1), activity(3, "2")},
+			actual:   []*s.HistoryEvent{activity(1, "0"), activity(2, "2")},
+			types:    []HistoryDiffType{HistoryDiffTypeEqual, HistoryDiffTypeMissing, HistoryDiffTypeEqual},
+		},
+		{
+			name:     "extra event",
+			expected: []*s.HistoryEvent{activity(1, "0")},
+			actual:   []*s.HistoryEvent{activity(1, "0"), createTestEventTimerStarted(2, 1)},
+			types:    []HistoryDiffType{HistoryDiffTypeEqual, HistoryDiffTypeExtra},
+		},
+		{
+			name:     "mismatched event",
+			expected: []*s.HistoryEvent{activity(1, "0"), createTestEventTimerStarted(2, 1), activity(3, "2")},
+			actual:   []*s.HistoryEvent{activity(1, "0"), createTestEventTimerStarted(2, 5), activity(3, "2")},
+			types:    []HistoryDiffType{HistoryDiffTypeEqual, HistoryDiffTypeMismatch, HistoryDiffTypeEqual},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffHistories(&s.History{Events: tt.expected}, &s.History{Events: tt.actual})
+			var types []HistoryDiffType
+			for _, entry := range diff.Entries {
+				types = append(types, entry.Type)
+			}
+			assert.Equal(t, tt.types, types)
+			assert.Equal(t, len(tt.types) != countDiffType(tt.types, HistoryDiffTypeEqual), diff.HasDifferences())
+			assert.Len(t, diff.Differences(), len(tt.types)-countDiffType(tt.types, HistoryDiffTypeEqual))
+		})
+	}
+}
+
+func TestDiffDecisionsWithHistory(t *testing.T) {
+	activityScheduled := mockHistoryEvent(s.EventTypeActivityTaskScheduled)
+	activityScheduled.EventId = common.Int64Ptr(5)
+	history := &s.History{Events: []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{}),
+		activityScheduled,
+		createTestEventTimerStarted(6, 0),
+	}}
+
+	t.Run("matching", func(t *testing.T) {
+		timer := mockDecision(s.DecisionTypeStartTimer)
+		timer.StartTimerDecisionAttributes.TimerId = common.StringPtr("0")
+		diff := DiffDecisionsWithHistory([]*s.Decision{mockDecision(s.DecisionTypeScheduleActivityTask), timer}, history)
+		assert.False(t, diff.HasDifferences())
+		assert.Len(t, diff.Entries, 2)
+		assert.Equal(t, int64(5), diff.Entries[0].ExpectedEventID)
+		assert.Equal(t, int64(0), diff.Entries[0].ActualEventID)
+	})
+
+	t.Run("missing and mismatched", func(t *testing.T) {
+		diff := DiffDecisionsWithHistory([]*s.Decision{mockDecision(s.DecisionTypeStartTimer)}, history)
+		assert.True(t, diff.HasDifferences())
+		assert.Equal(t, []HistoryDiffEntry{
+			{
+				Type:            HistoryDiffTypeMismatch,
+				ExpectedEventID: 5,
+				ExpectedText:    diff.Entries[0].ExpectedText,
+				ActualText:      diff.Entries[0].ActualText,
+			},
+			{
+				Type:            HistoryDiffTypeMissing,
+				ExpectedEventID: 6,
+				ExpectedText:    diff.Entries[1].ExpectedText,
+			},
+		}, diff.Entries)
+		assert.Contains(t, diff.String(), "- [5] ActivityTaskScheduled")
+		assert.Contains(t, diff.String(), "+ StartTimer")
+		assert.Contains(t, diff.String(), "- [6] TimerStarted")
+	})
+}
+
+func TestDiffHistoriesWithoutAttributes(t *testing.T) {
+	var events []*s.HistoryEvent
+	for _, eventType := range []s.EventType{
+		s.EventTypeActivityTaskScheduled,
+		s.EventTypeTimerStarted,
+		s.EventTypeTimerCanceled,
+		s.EventTypeMarkerRecorded,
+		s.EventTypeWorkflowExecutionSignaled,
+		s.EventTypeSignalExternalWorkflowExecutionInitiated,
+		s.EventTypeStartChildWorkflowExecutionInitiated,
+	} {
+		events = append(events, &s.HistoryEvent{EventId: common.Int64Ptr(int64(len(events) + 1)), EventType: eventType.Ptr()})
+	}
+
+	diff := DiffHistories(&s.History{Events: events}, &s.History{Events: events[:1]})
+	assert.True(t, diff.HasDifferences())
+	assert.Len(t, diff.Entries, len(events))
+	assert.Equal(t, HistoryDiffTypeEqual, diff.Entries[0].Type)
+}
+
+func TestHistoryDiffTypeString(t *testing.T) {
+	assert.Equal(t, "Equal", HistoryDiffTypeEqual.String())
+	assert.Equal(t, "Missing", HistoryDiffTypeMissing.String())
+	assert.Equal(t, "Extra", HistoryDiffTypeExtra.String())
+	assert.Equal(t, "Mismatch", HistoryDiffTypeMismatch.String())
+	assert.Equal(t, "HistoryDiffType(10)", HistoryDiffType(10).String())
+}
+
+func countDiffType(types []HistoryDiffType, t HistoryDiffType) int {
+	count := 0
+	for _, tt := range types {
+		if tt == t {
+			count++
+		}
+	}
+	return count
+}
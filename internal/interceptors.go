@@ -70,6 +70,14 @@ type WorkflowInterceptor interface {
 	GetLastCompletionResult(ctx Context, d ...interface{}) error
 }
 
+// WorkflowQueryInterceptor can be implemented by a WorkflowInterceptor to intercept the queries answered by the
+// workflow, e.g. to measure their latency and errors per query type like the query-handler-* metrics of the worker.
+// Unlike SetQueryHandler, it also sees the queries whose type has no handler, which fail. HandleQuery must call next
+// to answer the query, and is called outside of the workflow coroutines, so it must not block.
+type WorkflowQueryInterceptor interface {
+	HandleQuery(queryType string, queryArgs []byte, next func(queryType string, queryArgs []byte) ([]byte, error)) ([]byte, error)
+}
+
 var _ WorkflowInterceptor = (*WorkflowInterceptorBase)(nil)
 
 // WorkflowInterceptorBase is a helper type that can simplify creation of WorkflowInterceptorChainFactories
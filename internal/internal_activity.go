@@ -28,6 +28,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync/atomic"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -133,9 +134,19 @@ type (
 		heartbeatDetails   []byte
 		workflowType       *WorkflowType
 		workflowDomain     string
+		workflowTaskList   string
+		parentDomain       *string
+		parentExecution    *WorkflowExecution
 		workerStopChannel  <-chan struct{}
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
+		client             Client
+
+		maxHeartbeatDetailsSize  int
+		truncateHeartbeatDetails bool
+
+		reportedCancellation atomic.Value // *CanceledError set by ReportActivityCancellation
+		asyncCompletion      atomic.Bool  // set by MarkActivityAsyncCompletion
 	}
 
 	// context.WithValue need this type instead of basic type string to avoid lint error
@@ -148,6 +159,63 @@ const (
 	localActivityOptionsContextKey contextKey = "localActivityOptions"
 )
 
+// headers used to pass the context of the scheduling workflow to the activity worker when
+// WorkerOptions.EnableActivityCallerInfo is set, as the activity task does not contain it
+const (
+	workflowTaskListHeaderKey     = "cadence-workflow-tasklist"
+	parentWorkflowDomainHeaderKey = "cadence-parent-workflow-domain"
+	parentWorkflowIDHeaderKey     = "cadence-parent-workflow-id"
+	parentRunIDHeaderKey          = "cadence-parent-run-id"
+)
+
+// setWorkflowCallerHeaders writes the context of the scheduling workflow into the activity header.
+func setWorkflowCallerHeaders(header *shared.Header, info *WorkflowInfo) {
+	writer := NewHeaderWriter(header)
+	writer.Set(workflowTaskListHeaderKey, []byte(info.TaskListName))
+	if info.ParentWorkflowExecution != nil {
+		writer.Set(parentWorkflowIDHeaderKey, []byte(info.ParentWorkflowExecution.ID))
+		writer.Set(parentRunIDHeaderKey, []byte(info.ParentWorkflowExecution.RunID))
+		if info.ParentWorkflowDomain != nil {
+			writer.Set(parentWorkflowDomainHeaderKey, []byte(*info.ParentWorkflowDomain))
+		}
+	}
+}
+
+// setWorkflowCaller reads the context of the scheduling workflow written by setWorkflowCallerHeaders.
+// Activities scheduled by workers which do not write these headers are left without it.
+func (env *activityEnvironment) setWorkflowCaller(header *shared.Header) {
+	if header == nil {
+		return
+	}
+	env.workflowTaskList = string(header.Fields[workflowTaskListHeaderKey])
+	if parentID, ok := header.Fields[parentWorkflowIDHeaderKey]; ok {
+		env.parentExecution = &WorkflowExecution{
+			ID:    string(parentID),
+			RunID: string(header.Fields[parentRunIDHeaderKey]),
+		}
+	}
+	if parentDomain, ok := header.Fields[parentWorkflowDomainHeaderKey]; ok {
+		env.parentDomain = common.StringPtr(string(parentDomain))
+	}
+}
+
+// withoutWorkflowCallerHeaders returns the header without the ones written by setWorkflowCallerHeaders, which are
+// not passed to the context propagators.
+func withoutWorkflowCallerHeaders(header *shared.Header) *shared.Header {
+	if header == nil {
+		return nil
+	}
+	fields := make(map[string][]byte, len(header.Fields))
+	for key, value := range header.Fields {
+		switch key {
+		case workflowTaskListHeaderKey, parentWorkflowDomainHeaderKey, parentWorkflowIDHeaderKey, parentRunIDHeaderKey:
+		default:
+			fields[key] = value
+		}
+	}
+	return &shared.Header{Fields: fields}
+}
+
 func getActivityEnv(ctx context.Context) *activityEnvironment {
 	env := ctx.Value(activityEnvContextKey)
 	if env == nil {
@@ -156,6 +224,12 @@ func getActivityEnv(ctx context.Context) *activityEnvironment {
 	return env.(*activityEnvironment)
 }
 
+// getReportedCancellation returns the error recorded by ReportActivityCancellation, or nil.
+func (env *activityEnvironment) getReportedCancellation() *CanceledError {
+	err, _ := env.reportedCancellation.Load().(*CanceledError)
+	return err
+}
+
 func hasActivityEnv(ctx context.Context) bool {
 	env := ctx.Value(activityEnvContextKey)
 	return env != nil
@@ -218,10 +292,30 @@ func getValidatedLocalActivityOptions(ctx Context) (*localActivityOptions, error
 	if p.ScheduleToCloseTimeoutSeconds <= 0 {
 		return nil, errors.New("missing or negative ScheduleToCloseTimeoutSeconds")
 	}
+	if err := validateLocalActivityRetryPolicy(p.RetryPolicy); err != nil {
+		return nil, err
+	}
 
 	return p, nil
 }
 
+// validateLocalActivityRetryPolicy validates the retry policy with ValidateRetryPolicy, unless it sets neither
+// MaximumAttempts nor ExpirationInterval: such a policy never retries local activities and is kept valid.
+func validateLocalActivityRetryPolicy(p *RetryPolicy) error {
+	if p == nil || (p.MaximumAttempts == 0 && p.ExpirationInterval == 0) {
+		return nil
+	}
+	return ValidateRetryPolicy(p)
+}
+
+// retryExpired returns whether the ExpirationInterval of the retry policy elapsed at now, in workflow time.
+func (p *executeLocalActivityParams) retryExpired(now time.Time) bool {
+	if p.RetryPolicy == nil || p.RetryPolicy.ExpirationInterval <= 0 {
+		return false
+	}
+	return now.After(p.ScheduledTime.Add(p.RetryPolicy.ExpirationInterval))
+}
+
 func validateRetryPolicy(p *shared.RetryPolicy) error {
 	if p == nil {
 		return nil
@@ -0,0 +1,355 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/cadence/internal/common/util"
+)
+
+const (
+	defaultActivityTenant       = "_default"
+	tagTenant                   = "Tenant"
+	fairnessQueuedTasksDivisor  = 10
+	defaultFairnessTenantWeight = 1.0
+	fairnessMetricTagGuardKey   = "_fairness" // tracks the tenant tag values in the metric tag guard
+)
+
+type (
+	// ActivityFairnessOptions configures the fair scheduling of the activity executions of a worker between the
+	// tenants of its task list, see WorkerOptions.ActivityFairness.
+	//
+	// The worker polls up to MaxQueuedTasks tasks more than its execution slots. The polled tasks wait in a queue per
+	// tenant, and each free slot goes to the waiting tenant having the fewest running tasks relative to its weight, so
+	// that a burst of tasks of one tenant does not delay the tasks of the others for longer than an execution.
+	// When a single tenant has tasks, it uses all the slots.
+	//
+	// The StartToClose timeout of a task starts when it is polled, so the time it waits for a slot counts toward it.
+	ActivityFairnessOptions struct {
+		// Required: returns the tenant of an activity task, e.g. from a header or an argument. Tasks with an empty
+		// tenant, or for which TenantKey panics, are scheduled as one "_default" tenant.
+		TenantKey func(info ActivityTenantInfo) string
+
+		// Optional: weights of the tenants, a tenant of weight 2 is given twice as many slots as a tenant of weight 1
+		// when both have waiting tasks. Weights must be positive.
+		// default: DefaultWeight for every tenant
+		Weights map[string]float64
+
+		// Optional: weight of the tenants missing from Weights.
+		// default: 1
+		DefaultWeight float64
+
+		// Optional: maximum number of polled tasks waiting for an execution slot.
+		// default: 10% of MaxConcurrentActivityExecutionSize, at least 1
+		MaxQueuedTasks int
+
+		// Optional: maximum number of tenants tagged in the metrics, the others are reported under the "_other" tenant.
+		// default: 100
+		MetricTenantCardinalityLimit int
+	}
+
+	// ActivityTenantInfo is the information of an activity task passed to ActivityFairnessOptions.TenantKey.
+	ActivityTenantInfo struct {
+		WorkflowDomain    string
+		WorkflowType      string
+		WorkflowExecution WorkflowExecution
+		ActivityType      string
+		// Header contains the context propagation headers of the task.
+		Header map[string][]byte
+		// Args are the decoded arguments of the activity, nil if the activity is not registered or they could not be
+		// decoded.
+		Args []interface{}
+	}
+
+	// activityTenantInfoProvider is implemented by activity task handlers that can decode the tenant information of
+	// an activity task, see ActivityFairnessOptions.TenantKey
+	activityTenantInfoProvider interface {
+		activityTenantInfo(task *s.PollForActivityTaskResponse) ActivityTenantInfo
+	}
+
+	// activityFairScheduler hands out the execution slots of an activity worker to the tenants with waiting tasks
+	activityFairScheduler struct {
+		sync.Mutex
+		options      ActivityFairnessOptions
+		slots        int
+		running      int
+		seq          uint64
+		tenants      map[string]*fairTenant
+		tagCounts    map[string]*fairTenantCounts // tenant tag -> tasks, tenants may share the overflow tag
+		tagGuard     *activityMetricTagGuard
+		metricsScope *metrics.TaggedScope
+		logger       *zap.Logger
+	}
+
+	fairTenant struct {
+		tag     string
+		weight  float64
+		running int
+		waiting []*fairWaiter
+	}
+
+	fairTenantCounts struct {
+		running int
+		queued  int
+	}
+
+	fairWaiter struct {
+		seq     uint64
+		granted chan struct{}
+	}
+)
+
+func newActivityFairScheduler(
+	options ActivityFairnessOptions,
+	slots int,
+	metricsScope *metrics.TaggedScope,
+	logger *zap.Logger,
+) *activityFairScheduler {
+	if options.DefaultWeight <= 0 {
+		options.DefaultWeight = defaultFairnessTenantWeight
+	}
+	return &activityFairScheduler{
+		options:      options,
+		slots:        slots,
+		tenants:      make(map[string]*fairTenant),
+		tagCounts:    make(map[string]*fairTenantCounts),
+		tagGuard:     newActivityMetricTagGuard(),
+		metricsScope: metricsScope,
+		logger:       logger,
+	}
+}
+
+// fairnessMaxQueuedTasks returns the number of tasks polled in addition to the execution slots
+func fairnessMaxQueuedTasks(options *ActivityFairnessOptions, slots int) int {
+	if options == nil {
+		return 0
+	}
+	if options.MaxQueuedTasks > 0 {
+		return options.MaxQueuedTasks
+	}
+	if queued := slots / fairnessQueuedTasksDivisor; queued > 0 {
+		return queued
+	}
+	return 1
+}
+
+func validateActivityFairnessOptions(options *ActivityFairnessOptions) error {
+	if options == nil {
+		return nil
+	}
+	if options.TenantKey == nil {
+		return fmt.Errorf("ActivityFairness.TenantKey is required")
+	}
+	if options.DefaultWeight < 0 {
+		return fmt.Errorf("ActivityFairness.DefaultWeight must be positive")
+	}
+	for tenant, weight := range options.Weights {
+		if weight <= 0 {
+			return fmt.Errorf("ActivityFairness weight of tenant %q must be positive", tenant)
+		}
+	}
+	return nil
+}
+
+// tenantKey returns the tenant of the task, or defaultActivityTenant if TenantKey returns an empty string or panics
+func (f *activityFairScheduler) tenantKey(info ActivityTenantInfo) (key string) {
+	defer func() {
+		if p := recover(); p != nil {
+			f.logger.Warn("Activity tenant key func panic",
+				zap.String(tagActivityType, info.ActivityType),
+				zap.String(tagPanicError, fmt.Sprintf("%v", p)))
+			key = defaultActivityTenant
+		}
+	}()
+	if key = f.options.TenantKey(info); key == "" {
+		key = defaultActivityTenant
+	}
+	return key
+}
+
+// acquire blocks until the tenant is given an execution slot, and returns the func releasing it.
+// It returns errShutdown if stopC is closed first.
+func (f *activityFairScheduler) acquire(tenant string, stopC <-chan struct{}) (func(), error) {
+	enqueueTime := time.Now()
+	f.Lock()
+	t, ok := f.tenants[tenant]
+	if !ok {
+		t = &fairTenant{tag: tenant, weight: f.options.DefaultWeight}
+		if weight, ok := f.options.Weights[tenant]; ok {
+			t.weight = weight
+		}
+		if tags := f.tagGuard.guard(fairnessMetricTagGuardKey, f.options.MetricTenantCardinalityLimit, map[string]string{tagTenant: tenant}); len(tags) == 2 {
+			t.tag = tags[1]
+		}
+		f.tenants[tenant] = t
+	}
+	w := &fairWaiter{seq: f.seq, granted: make(chan struct{})}
+	f.seq++
+	t.waiting = append(t.waiting, w)
+	f.updateCounts(t, 0, 1)
+	f.dispatch()
+	f.Unlock()
+
+	select {
+	case <-w.granted:
+	case <-stopC:
+		f.Lock()
+		defer f.Unlock()
+		select {
+		case <-w.granted:
+			f.releaseLocked(tenant, t)
+		default:
+			for i, waiting := range t.waiting {
+				if waiting == w {
+					t.waiting = append(t.waiting[:i], t.waiting[i+1:]...)
+					break
+				}
+			}
+			f.updateCounts(t, 0, -1)
+			f.removeIfIdle(tenant, t)
+		}
+		return nil, errShutdown
+	}
+
+	f.tenantScope(t).Timer(metrics.ActivityFairnessQueueLatency).Record(time.Since(enqueueTime))
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			f.Lock()
+			defer f.Unlock()
+			f.releaseLocked(tenant, t)
+		})
+	}, nil
+}
+
+func (f *activityFairScheduler) releaseLocked(tenant string, t *fairTenant) {
+	f.running--
+	t.running--
+	f.updateCounts(t, -1, 0)
+	f.removeIfIdle(tenant, t)
+	f.dispatch()
+}
+
+// dispatch gives the free slots to the waiting tenants with the fewest running tasks relative to their weight,
+// the tenant waiting the longest first in case of a tie.
+func (f *activityFairScheduler) dispatch() {
+	for f.running < f.slots {
+		var next *fairTenant
+		for _, t := range f.tenants {
+			if len(t.waiting) == 0 {
+				continue
+			}
+			if next == nil {
+				next = t
+				continue
+			}
+			load, nextLoad := float64(t.running)/t.weight, float64(next.running)/next.weight
+			if load < nextLoad || (load == nextLoad && t.waiting[0].seq < next.waiting[0].seq) {
+				next = t
+			}
+		}
+		if next == nil {
+			return
+		}
+		w := next.waiting[0]
+		next.waiting[0] = nil
+		next.waiting = next.waiting[1:]
+		f.running++
+		next.running++
+		f.updateCounts(next, 1, -1)
+		close(w.granted)
+	}
+}
+
+func (f *activityFairScheduler) removeIfIdle(tenant string, t *fairTenant) {
+	if t.running == 0 && len(t.waiting) == 0 {
+		delete(f.tenants, tenant)
+	}
+}
+
+func (f *activityFairScheduler) updateCounts(t *fairTenant, running, queued int) {
+	counts, ok := f.tagCounts[t.tag]
+	if !ok {
+		counts = &fairTenantCounts{}
+		f.tagCounts[t.tag] = counts
+	}
+	counts.running += running
+	counts.queued += queued
+	scope := f.tenantScope(t)
+	scope.Gauge(metrics.ActivityFairnessRunningTasks).Update(float64(counts.running))
+	scope.Gauge(metrics.ActivityFairnessQueuedTasks).Update(float64(counts.queued))
+}
+
+func (f *activityFairScheduler) tenantScope(t *fairTenant) tally.Scope {
+	return f.metricsScope.GetTaggedScope(tagTenant, t.tag)
+}
+
+func (ath *activityTaskHandlerImpl) activityTenantInfo(t *s.PollForActivityTaskResponse) ActivityTenantInfo {
+	info := ActivityTenantInfo{
+		WorkflowDomain: t.GetWorkflowDomain(),
+		WorkflowType:   t.WorkflowType.GetName(),
+		WorkflowExecution: WorkflowExecution{
+			ID:    t.WorkflowExecution.GetWorkflowId(),
+			RunID: t.WorkflowExecution.GetRunId(),
+		},
+		ActivityType: t.ActivityType.GetName(),
+		Header:       t.Header.GetFields(),
+	}
+	a := ath.getActivity(info.ActivityType)
+	if a == nil {
+		return info
+	}
+	args, err := decodeActivityTaskArgs(a.GetFunction(), t.Input, ath.dataConverter)
+	if err != nil {
+		ath.logger.Warn("Failed to decode activity arguments for the tenant key",
+			zap.String(tagActivityType, info.ActivityType),
+			zap.Error(err))
+		return info
+	}
+	info.Args = args
+	return info
+}
+
+// decodeActivityTaskArgs decodes the input of an activity task for fn, which is passed as is if fn takes a byte slice
+func decodeActivityTaskArgs(fn interface{}, input []byte, dataConverter DataConverter) ([]interface{}, error) {
+	fnType := reflect.TypeOf(fn)
+	if fnType.NumIn() == 1 && util.IsTypeByteSlice(fnType.In(0)) {
+		return []interface{}{input}, nil
+	}
+	decoded, err := decodeArgs(dataConverter, fnType, input)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(decoded))
+	for _, arg := range decoded {
+		args = append(args, arg.Interface())
+	}
+	return args, nil
+}
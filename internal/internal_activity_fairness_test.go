@@ -0,0 +1,264 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+func newTestFairScheduler(options ActivityFairnessOptions, slots int) (*activityFairScheduler, tally.TestScope) {
+	scope := tally.NewTestScope("", nil)
+	return newActivityFairScheduler(options, slots, metrics.NewTaggedScope(scope), zap.NewNop()), scope
+}
+
+// acquireAsync starts acquiring a slot for the tenant and returns the channel receiving its release func
+func acquireAsync(t *testing.T, f *activityFairScheduler, tenant string, stopC <-chan struct{}) <-chan func() {
+	ch := make(chan func(), 1)
+	go func() {
+		release, err := f.acquire(tenant, stopC)
+		if err == nil {
+			ch <- release
+		}
+	}()
+	// wait for the task to be queued
+	require.Eventually(t, func() bool {
+		f.Lock()
+		defer f.Unlock()
+		tenantState, ok := f.tenants[tenant]
+		return ok && (len(tenantState.waiting) > 0 || tenantState.running > 0)
+	}, time.Second, time.Millisecond)
+	return ch
+}
+
+func requireGranted(t *testing.T, ch <-chan func()) func() {
+	select {
+	case release := <-ch:
+		return release
+	case <-time.After(time.Second):
+		require.FailNow(t, "slot not granted")
+		return nil
+	}
+}
+
+func requireWaiting(t *testing.T, ch <-chan func()) {
+	select {
+	case <-ch:
+		require.FailNow(t, "slot granted")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestActivityFairScheduler_burstDoesNotDelayOtherTenants(t *testing.T) {
+	f, scope := newTestFairScheduler(ActivityFairnessOptions{}, 2)
+	stopC := make(chan struct{})
+
+	// tenant a takes all the slots and queues more tasks
+	a1 := requireGranted(t, acquireAsync(t, f, "a", stopC))
+	a2 := requireGranted(t, acquireAsync(t, f, "a", stopC))
+	a3 := acquireAsync(t, f, "a", stopC)
+	a4 := acquireAsync(t, f, "a", stopC)
+	b1 := acquireAsync(t, f, "b", stopC)
+	requireWaiting(t, a3)
+	requireWaiting(t, b1)
+
+	gauges := scope.Snapshot().Gauges()
+	assert.Equal(t, 2.0, gauges["cadence-activity-fairness-running-tasks+Tenant=a"].Value())
+	assert.Equal(t, 2.0, gauges["cadence-activity-fairness-queued-tasks+Tenant=a"].Value())
+	assert.Equal(t, 1.0, gauges["cadence-activity-fairness-queued-tasks+Tenant=b"].Value())
+
+	// the first free slot goes to b, which was queued after the tasks of a
+	a1()
+	a1() // releasing twice has no effect
+	b1Release := requireGranted(t, b1)
+	requireWaiting(t, a3)
+
+	a2()
+	requireGranted(t, a3)()
+	requireGranted(t, a4)()
+	b1Release()
+
+	f.Lock()
+	assert.Zero(t, f.running)
+	assert.Empty(t, f.tenants)
+	f.Unlock()
+	gauges = scope.Snapshot().Gauges()
+	assert.Zero(t, gauges["cadence-activity-fairness-running-tasks+Tenant=a"].Value())
+	assert.Zero(t, gauges["cadence-activity-fairness-queued-tasks+Tenant=b"].Value())
+	assert.Len(t, scope.Snapshot().Timers()["cadence-activity-fairness-queue-latency+Tenant=a"].Values(), 4)
+}
+
+func TestActivityFairScheduler_weights(t *testing.T) {
+	f, _ := newTestFairScheduler(ActivityFairnessOptions{Weights: map[string]float64{"a": 2}}, 3)
+	stopC := make(chan struct{})
+
+	// fill the slots with tenant c, and queue tasks of a and b
+	var releases []func()
+	for i := 0; i < 3; i++ {
+		releases = append(releases, requireGranted(t, acquireAsync(t, f, "c", stopC)))
+	}
+	var waiting []<-chan func()
+	for i := 0; i < 3; i++ {
+		waiting = append(waiting, acquireAsync(t, f, "a", stopC))
+	}
+	for i := 0; i < 3; i++ {
+		waiting = append(waiting, acquireAsync(t, f, "b", stopC))
+	}
+
+	for _, release := range releases {
+		release()
+	}
+	// a has twice the weight of b
+	f.Lock()
+	assert.Equal(t, 2, f.tenants["a"].running)
+	assert.Equal(t, 1, f.tenants["b"].running)
+	f.Unlock()
+	close(stopC)
+}
+
+func TestActivityFairScheduler_shutdown(t *testing.T) {
+	f, scope := newTestFairScheduler(ActivityFairnessOptions{}, 1)
+	stopC := make(chan struct{})
+	release := requireGranted(t, acquireAsync(t, f, "a", stopC))
+	acquireAsync(t, f, "b", stopC)
+
+	errC := make(chan error, 1)
+	go func() {
+		_, err := f.acquire("a", stopC)
+		errC <- err
+	}()
+	close(stopC)
+	assert.Equal(t, errShutdown, <-errC)
+	require.Eventually(t, func() bool {
+		f.Lock()
+		defer f.Unlock()
+		_, ok := f.tenants["b"]
+		return !ok
+	}, time.Second, time.Millisecond)
+
+	release()
+	f.Lock()
+	assert.Zero(t, f.running)
+	assert.Empty(t, f.tenants)
+	f.Unlock()
+	assert.Zero(t, scope.Snapshot().Gauges()["cadence-activity-fairness-queued-tasks+Tenant=b"].Value())
+}
+
+func TestActivityFairScheduler_tenantKey(t *testing.T) {
+	f, _ := newTestFairScheduler(ActivityFairnessOptions{
+		TenantKey: func(info ActivityTenantInfo) string {
+			if len(info.Args) == 0 {
+				panic("no args")
+			}
+			return info.Args[0].(string)
+		},
+	}, 1)
+	assert.Equal(t, "tenant-1", f.tenantKey(ActivityTenantInfo{Args: []interface{}{"tenant-1"}}))
+	assert.Equal(t, defaultActivityTenant, f.tenantKey(ActivityTenantInfo{Args: []interface{}{""}}))
+	assert.Equal(t, defaultActivityTenant, f.tenantKey(ActivityTenantInfo{}))
+}
+
+func TestActivityFairScheduler_metricTenantCardinalityLimit(t *testing.T) {
+	f, scope := newTestFairScheduler(ActivityFairnessOptions{MetricTenantCardinalityLimit: 1}, 2)
+	stopC := make(chan struct{})
+	a := requireGranted(t, acquireAsync(t, f, "a", stopC))
+	b := requireGranted(t, acquireAsync(t, f, "b", stopC))
+	c := acquireAsync(t, f, "c", stopC)
+
+	gauges := scope.Snapshot().Gauges()
+	assert.Equal(t, 1.0, gauges["cadence-activity-fairness-running-tasks+Tenant=a"].Value())
+	assert.Equal(t, 1.0, gauges["cadence-activity-fairness-running-tasks+Tenant=_other"].Value())
+	assert.Equal(t, 1.0, gauges["cadence-activity-fairness-queued-tasks+Tenant=_other"].Value())
+
+	a()
+	requireGranted(t, c)()
+	b()
+	assert.Zero(t, scope.Snapshot().Gauges()["cadence-activity-fairness-running-tasks+Tenant=_other"].Value())
+}
+
+func TestActivityFairnessOptions(t *testing.T) {
+	assert.Equal(t, 0, fairnessMaxQueuedTasks(nil, 100))
+	assert.Equal(t, 10, fairnessMaxQueuedTasks(&ActivityFairnessOptions{}, 100))
+	assert.Equal(t, 1, fairnessMaxQueuedTasks(&ActivityFairnessOptions{}, 5))
+	assert.Equal(t, 3, fairnessMaxQueuedTasks(&ActivityFairnessOptions{MaxQueuedTasks: 3}, 100))
+
+	tenantKey := func(info ActivityTenantInfo) string { return info.WorkflowDomain }
+	assert.NoError(t, WorkerOptions{ActivityFairness: &ActivityFairnessOptions{TenantKey: tenantKey}}.Validate())
+	assert.Error(t, WorkerOptions{ActivityFairness: &ActivityFairnessOptions{}}.Validate())
+	assert.Error(t, WorkerOptions{ActivityFairness: &ActivityFairnessOptions{
+		TenantKey: tenantKey,
+		Weights:   map[string]float64{"a": 0},
+	}}.Validate())
+	assert.Error(t, WorkerOptions{
+		ActivityFairness: &ActivityFairnessOptions{TenantKey: tenantKey},
+		ActivitySlotPool: NewSlotPool(1),
+	}.Validate())
+}
+
+func TestActivityTenantInfo(t *testing.T) {
+	registry := newRegistry()
+	registry.RegisterActivityWithOptions(func(ctx context.Context, tenant string, count int) error {
+		return nil
+	}, RegisterActivityOptions{Name: "typed"})
+	registry.RegisterActivityWithOptions(func(input []byte) error {
+		return nil
+	}, RegisterActivityOptions{Name: "raw"})
+
+	handler := newActivityTaskHandler(nil, workerExecutionParameters{
+		TaskList:      &s.TaskList{Name: common.StringPtr(_testTaskList)},
+		WorkerOptions: WorkerOptions{Logger: zap.NewNop(), DataConverter: getDefaultDataConverter()},
+	}, registry).(*activityTaskHandlerImpl)
+
+	input, err := encodeArgs(getDefaultDataConverter(), []interface{}{"tenant-1", 5})
+	require.NoError(t, err)
+	newTask := func(activityType string) *s.PollForActivityTaskResponse {
+		return &s.PollForActivityTaskResponse{
+			WorkflowDomain:    common.StringPtr("domain"),
+			WorkflowType:      &s.WorkflowType{Name: common.StringPtr("workflow")},
+			WorkflowExecution: &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+			ActivityType:      &s.ActivityType{Name: common.StringPtr(activityType)},
+			Header:            &s.Header{Fields: map[string][]byte{"tenant": []byte("tenant-2")}},
+			Input:             input,
+		}
+	}
+
+	assert.Equal(t, ActivityTenantInfo{
+		WorkflowDomain:    "domain",
+		WorkflowType:      "workflow",
+		WorkflowExecution: WorkflowExecution{ID: "wid", RunID: "rid"},
+		ActivityType:      "typed",
+		Header:            map[string][]byte{"tenant": []byte("tenant-2")},
+		Args:              []interface{}{"tenant-1", 5},
+	}, handler.activityTenantInfo(newTask("typed")))
+	assert.Equal(t, []interface{}{input}, handler.activityTenantInfo(newTask("raw")).Args)
+	assert.Nil(t, handler.activityTenantInfo(newTask("unknown")).Args)
+	assert.Equal(t, "tenant-2", string(handler.activityTenantInfo(newTask("unknown")).Header["tenant"]))
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+const (
+	defaultMetricTagCardinalityLimit = 100
+	maxActivityMetricTags            = 5
+	maxMetricTagValueLength          = 64
+	metricTagOverflowValue           = "_other"
+)
+
+type (
+	// activityMetricTagsProvider is implemented by activity task handlers that can extract
+	// metric tags from activity input, see RegisterActivityOptions.MetricTagFunc
+	activityMetricTagsProvider interface {
+		activityMetricTags(task *s.PollForActivityTaskResponse) []string
+	}
+
+	// activityMetricTagGuard keeps the tags returned by MetricTagFunc within the cardinality limits
+	activityMetricTagGuard struct {
+		sync.Mutex
+		// activity type -> tag key -> seen values
+		values map[string]map[string]map[string]struct{}
+	}
+)
+
+var reservedActivityMetricTags = map[string]struct{}{
+	tagDomain:       {},
+	tagTaskList:     {},
+	tagWorkflowType: {},
+	tagActivityType: {},
+}
+
+func newActivityMetricTagGuard() *activityMetricTagGuard {
+	return &activityMetricTagGuard{
+		values: make(map[string]map[string]map[string]struct{}),
+	}
+}
+
+// guard returns the tags as key value pairs sorted by key. Reserved tags are dropped, at most
+// maxActivityMetricTags tags are kept, long values are truncated and values exceeding the
+// cardinality limit of their tag are replaced by metricTagOverflowValue.
+func (g *activityMetricTagGuard) guard(activityType string, limit int, tags map[string]string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	if limit <= 0 {
+		limit = defaultMetricTagCardinalityLimit
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if _, ok := reservedActivityMetricTags[k]; ok || k == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > maxActivityMetricTags {
+		keys = keys[:maxActivityMetricTags]
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	seen, ok := g.values[activityType]
+	if !ok {
+		seen = make(map[string]map[string]struct{})
+		g.values[activityType] = seen
+	}
+
+	pairs := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		v := tags[k]
+		if len(v) > maxMetricTagValueLength {
+			v = v[:maxMetricTagValueLength]
+		}
+		values, ok := seen[k]
+		if !ok {
+			values = make(map[string]struct{})
+			seen[k] = values
+		}
+		if _, ok := values[v]; !ok {
+			if len(values) >= limit {
+				v = metricTagOverflowValue
+			} else {
+				values[v] = struct{}{}
+			}
+		}
+		pairs = append(pairs, k, v)
+	}
+	return pairs
+}
+
+func (ath *activityTaskHandlerImpl) activityMetricTags(t *s.PollForActivityTaskResponse) []string {
+	activityType := t.ActivityType.GetName()
+	a := ath.getActivity(activityType)
+	if a == nil {
+		return nil
+	}
+	options := a.GetOptions()
+	if options.MetricTagFunc == nil {
+		return nil
+	}
+
+	dataConverter := ath.dataConverter
+	if options.DataConverter != nil {
+		dataConverter = options.DataConverter
+	}
+	tags, err := extractActivityMetricTags(a.GetFunction(), options.MetricTagFunc, t.Input, dataConverter)
+	if err != nil {
+		ath.logger.Warn("Failed to extract activity metric tags",
+			zap.String(tagActivityType, activityType),
+			zap.Error(err))
+		return nil
+	}
+	return ath.metricTagGuard.guard(activityType, options.MetricTagCardinalityLimit, tags)
+}
+
+func extractActivityMetricTags(
+	fn interface{},
+	tagFunc func(args []interface{}) map[string]string,
+	input []byte,
+	dataConverter DataConverter,
+) (tags map[string]string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("metric tag func panic: %v", p)
+		}
+	}()
+
+	args, err := decodeActivityTaskArgs(fn, input, dataConverter)
+	if err != nil {
+		return nil, err
+	}
+	return tagFunc(args), nil
+}
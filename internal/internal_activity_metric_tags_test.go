@@ -0,0 +1,113 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func TestActivityMetricTagGuard(t *testing.T) {
+	g := newActivityMetricTagGuard()
+
+	// reserved tags are dropped and the rest are sorted by key
+	require.Equal(t,
+		[]string{"region", "us", "tenant", "a"},
+		g.guard("activity", 2, map[string]string{"tenant": "a", "region": "us", tagActivityType: "x"}),
+	)
+	// long values are truncated
+	require.Equal(t,
+		[]string{"tenant", strings.Repeat("b", maxMetricTagValueLength)},
+		g.guard("activity", 2, map[string]string{"tenant": strings.Repeat("b", 100)}),
+	)
+	// cardinality limit is reached for tenant
+	require.Equal(t, []string{"tenant", metricTagOverflowValue}, g.guard("activity", 2, map[string]string{"tenant": "c"}))
+	// known values are still reported
+	require.Equal(t, []string{"tenant", "a"}, g.guard("activity", 2, map[string]string{"tenant": "a"}))
+	// limits are tracked per activity type
+	require.Equal(t, []string{"tenant", "c"}, g.guard("other-activity", 2, map[string]string{"tenant": "c"}))
+
+	tags := map[string]string{}
+	for i := 0; i < maxActivityMetricTags+2; i++ {
+		tags[fmt.Sprintf("tag%v", i)] = "v"
+	}
+	require.Len(t, g.guard("activity", 0, tags), 2*maxActivityMetricTags)
+	require.Nil(t, g.guard("activity", 0, nil))
+}
+
+func TestActivityMetricTags(t *testing.T) {
+	activityFn := func(ctx context.Context, tenant string, count int) error {
+		return nil
+	}
+	registry := newRegistry()
+	registry.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{
+		Name: "tagged",
+		MetricTagFunc: func(args []interface{}) map[string]string {
+			return map[string]string{"tenant": args[0].(string)}
+		},
+	})
+	registry.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{
+		Name: "panicking",
+		MetricTagFunc: func(args []interface{}) map[string]string {
+			panic("bad tag func")
+		},
+	})
+	registry.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{Name: "untagged"})
+	registry.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{
+		Name:          "gob",
+		DataConverter: newTestDataConverter(),
+		MetricTagFunc: func(args []interface{}) map[string]string {
+			return map[string]string{"tenant": args[0].(string)}
+		},
+	})
+
+	handler := newActivityTaskHandler(nil, workerExecutionParameters{
+		TaskList:      &s.TaskList{Name: common.StringPtr(_testTaskList)},
+		WorkerOptions: WorkerOptions{Logger: zap.NewNop(), DataConverter: getDefaultDataConverter()},
+	}, registry).(*activityTaskHandlerImpl)
+
+	input, err := encodeArgs(getDefaultDataConverter(), []interface{}{"tenant-1", 5})
+	require.NoError(t, err)
+	newTask := func(activityType string) *s.PollForActivityTaskResponse {
+		return &s.PollForActivityTaskResponse{
+			ActivityType: &s.ActivityType{Name: common.StringPtr(activityType)},
+			Input:        input,
+		}
+	}
+
+	require.Equal(t, []string{"tenant", "tenant-1"}, handler.activityMetricTags(newTask("tagged")))
+	require.Nil(t, handler.activityMetricTags(newTask("panicking")))
+	require.Nil(t, handler.activityMetricTags(newTask("untagged")))
+	require.Nil(t, handler.activityMetricTags(newTask("unknown")))
+
+	// the input is decoded with the data converter of the registration
+	input, err = encodeArgs(newTestDataConverter(), []interface{}{"tenant-2", 5})
+	require.NoError(t, err)
+	require.Equal(t, []string{"tenant", "tenant-2"}, handler.activityMetricTags(newTask("gob")))
+}
@@ -0,0 +1,384 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	runtimemetrics "runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+const (
+	defaultAdmissionControlCheckInterval = time.Second
+	defaultAdmissionControlHysteresis    = 0.1
+
+	defaultCgroupRoot = "/sys/fs/cgroup"
+	// cgroup v1 reports a memory limit close to the max int64 when the memory is not limited
+	cgroupV1UnlimitedMemory = 1 << 62
+)
+
+type (
+	// ResourceUsage is the fraction of the available memory and CPU in use, between 0 and 1. A value of 0 means
+	// the usage is unknown or not limited.
+	ResourceUsage struct {
+		Memory float64
+		CPU    float64
+	}
+
+	// ResourceMonitor reports the resource usage of the worker process. It is called from a single goroutine.
+	ResourceMonitor interface {
+		Usage() (ResourceUsage, error)
+	}
+
+	// AdmissionControlOptions configures pausing activity polling while the worker is short on memory or CPU, so that
+	// memory heavy activities don't get the process OOM killed. Running activities are not affected, the worker just
+	// stops taking new activity tasks until the usage drops below the low watermarks.
+	// Admission control is disabled unless MemoryHighWatermark or CPUHighWatermark is set.
+	AdmissionControlOptions struct {
+		// Optional: fraction of the memory limit at which activity polling is paused, e.g. 0.85.
+		// The memory limit is read from the cgroup of the process, or GOMEMLIMIT if there is no cgroup limit.
+		// default: 0, memory usage is not checked
+		MemoryHighWatermark float64
+
+		// Optional: fraction of the memory limit below which activity polling is resumed.
+		// default: MemoryHighWatermark - 0.1
+		MemoryLowWatermark float64
+
+		// Optional: fraction of the CPU quota at which activity polling is paused, e.g. 0.9.
+		// The CPU quota is read from the cgroup (v1 or v2) of the process, or GOMAXPROCS if there is no cgroup quota.
+		// The CPU time of the process is read with getrusage, CPU usage is not checked on platforms without it.
+		// default: 0, CPU usage is not checked
+		CPUHighWatermark float64
+
+		// Optional: fraction of the CPU quota below which activity polling is resumed.
+		// default: CPUHighWatermark - 0.1
+		CPULowWatermark float64
+
+		// Optional: interval between two resource usage checks.
+		// default: 1s
+		CheckInterval time.Duration
+
+		// Optional: source of the resource usage.
+		// default: NewProcessResourceMonitor()
+		ResourceMonitor ResourceMonitor
+	}
+
+	// admissionController pauses polling of the workers sharing it while the resource usage is above the high
+	// watermarks, and resumes it once the usage is back below the low watermarks.
+	admissionController struct {
+		options      AdmissionControlOptions
+		logger       *zap.Logger
+		metricsScope tally.Scope
+
+		sync.Mutex
+		gate     chan struct{} // nil while polling is admitted, closed on resume
+		pausedAt time.Time
+		starts   int
+		stopCh   chan struct{}
+		stopped  sync.WaitGroup
+	}
+
+	processResourceMonitor struct {
+		cgroupRoot string
+		now        func() time.Time
+		cpuTime    func() (float64, bool)
+
+		lastCPUTime float64
+		lastCheck   time.Time
+	}
+)
+
+// NewProcessResourceMonitor returns a ResourceMonitor of the current process. It honors cgroup v1 and v2 memory limits
+// and CPU quotas, so it reports the usage against the container limits when running in a container.
+func NewProcessResourceMonitor() ResourceMonitor {
+	return &processResourceMonitor{cgroupRoot: defaultCgroupRoot, now: time.Now, cpuTime: processCPUTime}
+}
+
+func newAdmissionController(options AdmissionControlOptions, logger *zap.Logger, metricsScope tally.Scope) *admissionController {
+	if options.MemoryHighWatermark <= 0 && options.CPUHighWatermark <= 0 {
+		return nil
+	}
+	if options.MemoryLowWatermark <= 0 || options.MemoryLowWatermark > options.MemoryHighWatermark {
+		options.MemoryLowWatermark = options.MemoryHighWatermark - defaultAdmissionControlHysteresis
+	}
+	if options.CPULowWatermark <= 0 || options.CPULowWatermark > options.CPUHighWatermark {
+		options.CPULowWatermark = options.CPUHighWatermark - defaultAdmissionControlHysteresis
+	}
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = defaultAdmissionControlCheckInterval
+	}
+	if options.ResourceMonitor == nil {
+		options.ResourceMonitor = NewProcessResourceMonitor()
+	}
+	if metricsScope == nil {
+		metricsScope = tally.NoopScope
+	}
+	return &admissionController{
+		options:      options,
+		logger:       logger,
+		metricsScope: metricsScope,
+	}
+}
+
+// start starts checking the resource usage. It is shared by several workers, so checks only stop once every
+// worker that started the controller has stopped it. It is a no-op on a nil controller.
+func (ac *admissionController) start() {
+	if ac == nil {
+		return
+	}
+	ac.Lock()
+	defer ac.Unlock()
+	ac.starts++
+	if ac.starts > 1 {
+		return
+	}
+	ac.stopCh = make(chan struct{})
+	ac.stopped.Add(1)
+	go ac.run(ac.stopCh)
+}
+
+func (ac *admissionController) stop() {
+	if ac == nil {
+		return
+	}
+	ac.Lock()
+	if ac.starts == 0 {
+		ac.Unlock()
+		return
+	}
+	ac.starts--
+	if ac.starts > 0 {
+		ac.Unlock()
+		return
+	}
+	close(ac.stopCh)
+	ac.Unlock()
+	ac.stopped.Wait()
+	ac.resume()
+}
+
+func (ac *admissionController) run(stopCh <-chan struct{}) {
+	defer ac.stopped.Done()
+	ticker := time.NewTicker(ac.options.CheckInterval)
+	defer ticker.Stop()
+	for {
+		ac.check()
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check pauses or resumes polling based on the current resource usage. Failing to read the usage keeps the
+// current state.
+func (ac *admissionController) check() {
+	usage, err := ac.options.ResourceMonitor.Usage()
+	if err != nil {
+		ac.metricsScope.Counter(metrics.AdmissionControlMonitorFailure).Inc(1)
+		ac.logger.Warn("Failed to read resource usage for admission control.", zap.Error(err))
+		return
+	}
+	ac.metricsScope.Gauge(metrics.AdmissionControlMemoryUsage).Update(usage.Memory)
+	ac.metricsScope.Gauge(metrics.AdmissionControlCPUUsage).Update(usage.CPU)
+
+	if ac.isPaused() {
+		if ac.belowLowWatermarks(usage) {
+			ac.resume()
+			ac.logger.Info("Resumed activity polling, resource usage is back to normal.",
+				zap.Float64("MemoryUsage", usage.Memory), zap.Float64("CPUUsage", usage.CPU))
+		}
+	} else if ac.aboveHighWatermarks(usage) {
+		ac.pause()
+		ac.logger.Warn("Paused activity polling, resource usage is too high.",
+			zap.Float64("MemoryUsage", usage.Memory), zap.Float64("CPUUsage", usage.CPU))
+	}
+}
+
+func (ac *admissionController) aboveHighWatermarks(usage ResourceUsage) bool {
+	return (ac.options.MemoryHighWatermark > 0 && usage.Memory >= ac.options.MemoryHighWatermark) ||
+		(ac.options.CPUHighWatermark > 0 && usage.CPU >= ac.options.CPUHighWatermark)
+}
+
+func (ac *admissionController) belowLowWatermarks(usage ResourceUsage) bool {
+	return (ac.options.MemoryHighWatermark <= 0 || usage.Memory < ac.options.MemoryLowWatermark) &&
+		(ac.options.CPUHighWatermark <= 0 || usage.CPU < ac.options.CPULowWatermark)
+}
+
+func (ac *admissionController) isPaused() bool {
+	ac.Lock()
+	defer ac.Unlock()
+	return ac.gate != nil
+}
+
+func (ac *admissionController) pause() {
+	ac.Lock()
+	defer ac.Unlock()
+	if ac.gate != nil {
+		return
+	}
+	ac.gate = make(chan struct{})
+	ac.pausedAt = time.Now()
+	ac.metricsScope.Counter(metrics.AdmissionControlPausedCounter).Inc(1)
+	ac.metricsScope.Gauge(metrics.AdmissionControlPausedGauge).Update(1)
+}
+
+func (ac *admissionController) resume() {
+	ac.Lock()
+	defer ac.Unlock()
+	if ac.gate == nil {
+		return
+	}
+	close(ac.gate)
+	ac.gate = nil
+	ac.metricsScope.Timer(metrics.AdmissionControlPausedLatency).Record(time.Since(ac.pausedAt))
+	ac.metricsScope.Gauge(metrics.AdmissionControlPausedGauge).Update(0)
+}
+
+// wait blocks while polling is paused. It returns immediately on a nil controller.
+func (ac *admissionController) wait(ctx context.Context) error {
+	if ac == nil {
+		return nil
+	}
+	ac.Lock()
+	gate := ac.gate
+	ac.Unlock()
+	if gate == nil {
+		return nil
+	}
+	select {
+	case <-gate:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *processResourceMonitor) Usage() (ResourceUsage, error) {
+	memory, err := m.memoryUsage()
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	return ResourceUsage{Memory: memory, CPU: m.cpuUsage()}, nil
+}
+
+// memoryUsage returns the memory usage of the cgroup if it has a memory limit, and otherwise the memory used by the
+// Go runtime relative to GOMEMLIMIT.
+func (m *processResourceMonitor) memoryUsage() (float64, error) {
+	for _, files := range [][2]string{
+		{"memory.max", "memory.current"},                                 // cgroup v2
+		{"memory/memory.limit_in_bytes", "memory/memory.usage_in_bytes"}, // cgroup v1
+	} {
+		limit, err := readCgroupValue(filepath.Join(m.cgroupRoot, files[0]))
+		if err != nil || limit <= 0 || limit >= cgroupV1UnlimitedMemory {
+			continue
+		}
+		current, err := readCgroupValue(filepath.Join(m.cgroupRoot, files[1]))
+		if err != nil {
+			return 0, err
+		}
+		return float64(current) / float64(limit), nil
+	}
+
+	limit := debug.SetMemoryLimit(-1)
+	if limit <= 0 || limit == math.MaxInt64 {
+		return 0, nil
+	}
+	samples := []runtimemetrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	runtimemetrics.Read(samples)
+	if samples[0].Value.Kind() != runtimemetrics.KindUint64 || samples[1].Value.Kind() != runtimemetrics.KindUint64 {
+		return 0, nil
+	}
+	return float64(samples[0].Value.Uint64()-samples[1].Value.Uint64()) / float64(limit), nil
+}
+
+// cpuUsage returns the CPU time used by the process since the previous call relative to the CPU quota of the cgroup,
+// or GOMAXPROCS if there is no quota. The first call returns 0, as does a platform without getrusage.
+func (m *processResourceMonitor) cpuUsage() float64 {
+	cpuTime, ok := m.cpuTime()
+	if !ok {
+		return 0
+	}
+	now := m.now()
+	lastCPUTime, lastCheck := m.lastCPUTime, m.lastCheck
+	m.lastCPUTime, m.lastCheck = cpuTime, now
+	if lastCheck.IsZero() || !now.After(lastCheck) {
+		return 0
+	}
+	return (cpuTime - lastCPUTime) / now.Sub(lastCheck).Seconds() / m.cpuQuota()
+}
+
+// cpuQuota returns the number of CPUs available to the process.
+func (m *processResourceMonitor) cpuQuota() float64 {
+	// cgroup v2
+	if content, err := os.ReadFile(filepath.Join(m.cgroupRoot, "cpu.max")); err == nil {
+		fields := strings.Fields(string(content))
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, qErr := strconv.ParseFloat(fields[0], 64)
+			period, pErr := strconv.ParseFloat(fields[1], 64)
+			if qErr == nil && pErr == nil && quota > 0 && period > 0 {
+				return quota / period
+			}
+		}
+	}
+	// cgroup v1, the quota is -1 when the CPU is not limited
+	quota, qErr := readCgroupValue(filepath.Join(m.cgroupRoot, "cpu/cpu.cfs_quota_us"))
+	period, pErr := readCgroupValue(filepath.Join(m.cgroupRoot, "cpu/cpu.cfs_period_us"))
+	if qErr == nil && pErr == nil && quota > 0 && period > 0 {
+		return float64(quota) / float64(period)
+	}
+	return float64(runtime.GOMAXPROCS(0))
+}
+
+// readCgroupValue reads a cgroup file holding a single number, returning 0 for "max".
+func readCgroupValue(path string) (int64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed cgroup value %q in %s", value, path)
+	}
+	return n, nil
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap/zaptest"
+
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+type fakeResourceMonitor struct {
+	sync.Mutex
+	usage ResourceUsage
+	err   error
+}
+
+func (m *fakeResourceMonitor) Usage() (ResourceUsage, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.usage, m.err
+}
+
+func (m *fakeResourceMonitor) set(usage ResourceUsage, err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.usage, m.err = usage, err
+}
+
+func TestAdmissionControllerDisabled(t *testing.T) {
+	ac := newAdmissionController(AdmissionControlOptions{}, zaptest.NewLogger(t), nil)
+	assert.Nil(t, ac)
+
+	// a nil controller admits everything
+	ac.start()
+	assert.NoError(t, ac.wait(context.Background()))
+	ac.stop()
+}
+
+func TestAdmissionControllerDefaults(t *testing.T) {
+	ac := newAdmissionController(AdmissionControlOptions{MemoryHighWatermark: 0.8}, zaptest.NewLogger(t), nil)
+	require.NotNil(t, ac)
+	assert.InDelta(t, 0.7, ac.options.MemoryLowWatermark, 1e-9)
+	assert.Equal(t, defaultAdmissionControlCheckInterval, ac.options.CheckInterval)
+	assert.IsType(t, &processResourceMonitor{}, ac.options.ResourceMonitor)
+}
+
+func TestAdmissionControllerHysteresis(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	monitor := &fakeResourceMonitor{}
+	ac := newAdmissionController(AdmissionControlOptions{
+		MemoryHighWatermark: 0.8,
+		MemoryLowWatermark:  0.6,
+		CPUHighWatermark:    0.9,
+		ResourceMonitor:     monitor,
+	}, zaptest.NewLogger(t), scope)
+
+	for _, step := range []struct {
+		usage  ResourceUsage
+		err    error
+		paused bool
+	}{
+		{usage: ResourceUsage{Memory: 0.5, CPU: 0.5}, paused: false},
+		{usage: ResourceUsage{Memory: 0.8, CPU: 0.5}, paused: true},
+		{usage: ResourceUsage{Memory: 0.7, CPU: 0.5}, paused: true}, // between the watermarks
+		{err: errors.New("unavailable"), paused: true},
+		{usage: ResourceUsage{Memory: 0.5, CPU: 0.85}, paused: true}, // CPU above its low watermark of 0.8
+		{usage: ResourceUsage{Memory: 0.5, CPU: 0.5}, paused: false},
+		{usage: ResourceUsage{Memory: 0.7, CPU: 0.5}, paused: false}, // between the watermarks
+		{usage: ResourceUsage{Memory: 0.1, CPU: 0.95}, paused: true},
+	} {
+		monitor.set(step.usage, step.err)
+		ac.check()
+		assert.Equal(t, step.paused, ac.isPaused(), "usage %+v", step.usage)
+	}
+
+	snapshot := scope.Snapshot()
+	assert.EqualValues(t, 2, snapshot.Counters()[metrics.AdmissionControlPausedCounter+"+"].Value())
+	assert.EqualValues(t, 1, snapshot.Counters()[metrics.AdmissionControlMonitorFailure+"+"].Value())
+	assert.EqualValues(t, 1, snapshot.Gauges()[metrics.AdmissionControlPausedGauge+"+"].Value())
+	assert.EqualValues(t, 0.95, snapshot.Gauges()[metrics.AdmissionControlCPUUsage+"+"].Value())
+	assert.Len(t, snapshot.Timers()[metrics.AdmissionControlPausedLatency+"+"].Values(), 1)
+}
+
+func TestAdmissionControllerWait(t *testing.T) {
+	monitor := &fakeResourceMonitor{usage: ResourceUsage{Memory: 0.9}}
+	ac := newAdmissionController(AdmissionControlOptions{
+		MemoryHighWatermark: 0.8,
+		ResourceMonitor:     monitor,
+	}, zaptest.NewLogger(t), nil)
+	ac.check()
+	require.True(t, ac.isPaused())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, ac.wait(ctx), context.DeadlineExceeded)
+
+	done := make(chan error)
+	go func() {
+		done <- ac.wait(context.Background())
+	}()
+	monitor.set(ResourceUsage{Memory: 0.1}, nil)
+	ac.check()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("wait was not unblocked by resume")
+	}
+}
+
+func TestAdmissionControllerStartStop(t *testing.T) {
+	monitor := &fakeResourceMonitor{usage: ResourceUsage{CPU: 1}}
+	ac := newAdmissionController(AdmissionControlOptions{
+		CPUHighWatermark: 0.8,
+		CheckInterval:    time.Millisecond,
+		ResourceMonitor:  monitor,
+	}, zaptest.NewLogger(t), nil)
+
+	// shared by two workers
+	ac.start()
+	ac.start()
+	assert.Eventually(t, ac.isPaused, time.Second, time.Millisecond)
+
+	ac.stop()
+	assert.True(t, ac.isPaused(), "checks must continue while a worker is running")
+
+	ac.stop()
+	assert.False(t, ac.isPaused(), "polling must not stay paused once stopped")
+	assert.NoError(t, ac.wait(context.Background()))
+}
+
+func TestProcessResourceMonitorMemory(t *testing.T) {
+	write := func(t *testing.T, root, name, content string) {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	t.Run("cgroup v2", func(t *testing.T) {
+		root := t.TempDir()
+		write(t, root, "memory.max", "1000\n")
+		write(t, root, "memory.current", "250\n")
+		usage, err := (&processResourceMonitor{cgroupRoot: root, now: time.Now}).memoryUsage()
+		require.NoError(t, err)
+		assert.InDelta(t, 0.25, usage, 1e-9)
+	})
+
+	t.Run("cgroup v1", func(t *testing.T) {
+		root := t.TempDir()
+		write(t, root, "memory/memory.limit_in_bytes", "2000\n")
+		write(t, root, "memory/memory.usage_in_bytes", "500\n")
+		usage, err := (&processResourceMonitor{cgroupRoot: root, now: time.Now}).memoryUsage()
+		require.NoError(t, err)
+		assert.InDelta(t, 0.25, usage, 1e-9)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		root := t.TempDir()
+		write(t, root, "memory.max", "max\n")
+		write(t, root, "memory.current", "250\n")
+		usage, err := (&processResourceMonitor{cgroupRoot: root, now: time.Now}).memoryUsage()
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, usage, 0.0)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		root := t.TempDir()
+		write(t, root, "memory.max", "1000\n")
+		write(t, root, "memory.current", "lots\n")
+		_, err := (&processResourceMonitor{cgroupRoot: root, now: time.Now}).memoryUsage()
+		assert.ErrorContains(t, err, "malformed cgroup value")
+	})
+}
+
+func TestProcessResourceMonitorCPU(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cpu.max"), []byte("50000 100000\n"), 0o644))
+	now := time.Now()
+	cpuTime := 10.0
+	m := &processResourceMonitor{
+		cgroupRoot: root,
+		now:        func() time.Time { return now },
+		cpuTime:    func() (float64, bool) { return cpuTime, true },
+	}
+	assert.Equal(t, 0.5, m.cpuQuota())
+
+	assert.Zero(t, m.cpuUsage(), "the first call has nothing to compare with")
+	now = now.Add(time.Second)
+	cpuTime += 0.4
+	assert.InDelta(t, 0.8, m.cpuUsage(), 1e-9)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cpu.max"), []byte("max 100000\n"), 0o644))
+	assert.Greater(t, m.cpuQuota(), 0.0)
+}
+
+func TestProcessResourceMonitorCPUQuotaCgroupV1(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cpu"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cpu/cpu.cfs_quota_us"), []byte("200000\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cpu/cpu.cfs_period_us"), []byte("100000\n"), 0o644))
+	m := &processResourceMonitor{cgroupRoot: root, now: time.Now, cpuTime: processCPUTime}
+	assert.Equal(t, 2.0, m.cpuQuota())
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cpu/cpu.cfs_quota_us"), []byte("-1\n"), 0o644))
+	assert.Equal(t, float64(runtime.GOMAXPROCS(0)), m.cpuQuota())
+}
+
+func TestProcessCPUTime(t *testing.T) {
+	before, ok := processCPUTime()
+	if !ok {
+		t.Skip("getrusage is not supported on this platform")
+	}
+	for start := time.Now(); time.Since(start) < 100*time.Millisecond; {
+	}
+	after, _ := processCPUTime()
+	assert.Greater(t, after, before, "the CPU time is updated without a GC")
+}
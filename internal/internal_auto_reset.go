@@ -0,0 +1,221 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/backoff"
+	"go.uber.org/cadence/internal/common/cache"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+const (
+	defaultAutoResetMinAttempts        = 3
+	defaultAutoResetMaxResetsPerSecond = 1
+	defaultAutoResetCooldown           = time.Hour
+	autoResetCacheSize                 = 10000
+	autoResetReasonPrefix              = "cadence-client auto reset: "
+	// autoResetTimeout bounds the retries of a reset, which block the decision task poller
+	autoResetTimeout = 10 * time.Second
+)
+
+// DecisionTaskFailureAction is returned by a DecisionTaskFailureClassifier to tell the worker how to handle
+// a failed decision task.
+type DecisionTaskFailureAction int
+
+const (
+	// DecisionTaskFailureActionNone keeps the default handling: the decision task is retried by the server.
+	DecisionTaskFailureActionNone DecisionTaskFailureAction = iota
+	// DecisionTaskFailureActionReset resets the workflow to the last successfully completed decision.
+	DecisionTaskFailureActionReset
+)
+
+type (
+	// DecisionTaskFailureInfo describes a failed decision task for a DecisionTaskFailureClassifier.
+	DecisionTaskFailureInfo struct {
+		Domain            string
+		TaskList          string
+		WorkflowType      string
+		WorkflowExecution WorkflowExecution
+		// Attempt is the number of previously failed attempts of this decision task, starting from 0.
+		Attempt int64
+		// Err is the error returned while processing the decision task, e.g. a *NonDeterministicError or a *PanicError.
+		Err error
+	}
+
+	// DecisionTaskFailureClassifier classifies failed decision tasks. It is called from the decision task poller
+	// goroutine and must be fast and safe for concurrent use.
+	DecisionTaskFailureClassifier func(info DecisionTaskFailureInfo) DecisionTaskFailureAction
+
+	// AutoResetOptions configures automatic reset of workflows whose decision tasks keep failing.
+	// Auto reset is disabled unless Classifier is set. A reset blocks the decision task poller while it is retried,
+	// for up to 10 seconds, and the failed decision task of a reset workflow is not responded to.
+	AutoResetOptions struct {
+		// Optional: decides if a failed decision task should reset the workflow.
+		// default: nil, auto reset is disabled
+		Classifier DecisionTaskFailureClassifier
+
+		// Optional: Classifier is only consulted once a decision task has failed at least this many times in a row.
+		// default: 3
+		MinAttempts int
+
+		// Optional: limits the number of resets this worker can request per second.
+		// default: 1
+		MaxResetsPerSecond float64
+
+		// Optional: a workflow reset by this worker is not reset again by it before the cooldown expires,
+		// so that a workflow which keeps failing after reset does not loop.
+		// default: 1 hour
+		Cooldown time.Duration
+
+		// Optional: do not reapply signals received after the reset point.
+		// default: false, signals are reapplied
+		SkipSignalReapply bool
+	}
+
+	// autoResetter requests workflow resets for failing decision tasks, according to AutoResetOptions.
+	autoResetter struct {
+		domain       string
+		taskList     string
+		identity     string
+		service      workflowserviceclient.Interface
+		options      AutoResetOptions
+		limiter      *rate.Limiter
+		recent       cache.Cache
+		metricsScope *metrics.TaggedScope
+		logger       *zap.Logger
+		featureFlags FeatureFlags
+		timeout      time.Duration
+	}
+)
+
+// newAutoResetter returns nil if auto reset is not enabled in params.
+func newAutoResetter(service workflowserviceclient.Interface, domain string, params workerExecutionParameters) *autoResetter {
+	options := params.AutoResetOptions
+	if options.Classifier == nil {
+		return nil
+	}
+	if options.MinAttempts <= 0 {
+		options.MinAttempts = defaultAutoResetMinAttempts
+	}
+	if options.MaxResetsPerSecond <= 0 {
+		options.MaxResetsPerSecond = defaultAutoResetMaxResetsPerSecond
+	}
+	if options.Cooldown <= 0 {
+		options.Cooldown = defaultAutoResetCooldown
+	}
+	return &autoResetter{
+		domain:       domain,
+		taskList:     params.TaskList.GetName(),
+		identity:     params.Identity,
+		service:      service,
+		options:      options,
+		limiter:      rate.NewLimiter(rate.Limit(options.MaxResetsPerSecond), 1),
+		recent:       cache.New(autoResetCacheSize, &cache.Options{TTL: options.Cooldown}),
+		metricsScope: metrics.NewTaggedScope(params.MetricsScope),
+		logger:       params.Logger,
+		featureFlags: params.FeatureFlags,
+		timeout:      autoResetTimeout,
+	}
+}
+
+// onDecisionTaskFailed consults the classifier and resets the workflow if requested and allowed by the limits.
+// It returns true if the workflow was reset, in which case the failed decision task belongs to the terminated run.
+func (r *autoResetter) onDecisionTaskFailed(task *s.PollForDecisionTaskResponse, taskErr error) bool {
+	if task.GetAttempt() < int64(r.options.MinAttempts) {
+		return false
+	}
+	// the reset point is the DecisionTaskCompleted event of the last successful decision
+	previousStartedEventID := task.GetPreviousStartedEventId()
+	if previousStartedEventID <= 0 || previousStartedEventID == replayPreviousStartedEventID {
+		return false
+	}
+
+	info := DecisionTaskFailureInfo{
+		Domain:       r.domain,
+		TaskList:     r.taskList,
+		WorkflowType: task.WorkflowType.GetName(),
+		WorkflowExecution: WorkflowExecution{
+			ID:    task.WorkflowExecution.GetWorkflowId(),
+			RunID: task.WorkflowExecution.GetRunId(),
+		},
+		Attempt: task.GetAttempt(),
+		Err:     taskErr,
+	}
+	if r.options.Classifier(info) != DecisionTaskFailureActionReset {
+		return false
+	}
+
+	logger := r.logger.With(
+		zap.String(tagWorkflowType, info.WorkflowType),
+		zap.String(tagWorkflowID, info.WorkflowExecution.ID),
+		zap.String(tagRunID, info.WorkflowExecution.RunID),
+	)
+	scope := r.metricsScope.GetTaggedScope(tagWorkflowType, info.WorkflowType)
+	if r.recent.Exist(info.WorkflowExecution.ID) || !r.limiter.Allow() {
+		scope.Counter(metrics.DecisionTaskAutoResetSkippedCounter).Inc(1)
+		logger.Warn("Skipped auto reset of workflow with failing decision task.", zap.Error(taskErr))
+		return false
+	}
+	r.recent.Put(info.WorkflowExecution.ID, struct{}{})
+
+	request := &s.ResetWorkflowExecutionRequest{
+		Domain:                common.StringPtr(r.domain),
+		WorkflowExecution:     task.WorkflowExecution,
+		Reason:                common.StringPtr(fmt.Sprintf("%sdecision task failed %d times, last error: %v", autoResetReasonPrefix, info.Attempt+1, taskErr)),
+		DecisionFinishEventId: common.Int64Ptr(previousStartedEventID + 1),
+		RequestId:             common.StringPtr(uuid.New()),
+		SkipSignalReapply:     common.BoolPtr(r.options.SkipSignalReapply),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	var response *s.ResetWorkflowExecutionResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			tchCtx, cancel, opt := newChannelContext(ctx, r.featureFlags)
+			defer cancel()
+			var err error
+			response, err = r.service.ResetWorkflowExecution(tchCtx, request, opt...)
+			return err
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		scope.Counter(metrics.DecisionTaskAutoResetFailedCounter).Inc(1)
+		logger.Error("Failed to auto reset workflow with failing decision task.", zap.Error(err))
+		return false
+	}
+
+	scope.Counter(metrics.DecisionTaskAutoResetCounter).Inc(1)
+	logger.Warn("Auto reset workflow with failing decision task.",
+		zap.Int64("DecisionFinishEventID", previousStartedEventID+1),
+		zap.String("NewRunID", response.GetRunId()),
+		zap.Error(taskErr))
+	return true
+}
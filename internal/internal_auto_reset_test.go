@@ -0,0 +1,171 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func TestNewAutoResetter(t *testing.T) {
+	assert.Nil(t, newAutoResetter(nil, _testDomainName, workerExecutionParameters{}))
+
+	r := newAutoResetter(nil, _testDomainName, workerExecutionParameters{
+		WorkerOptions: WorkerOptions{
+			AutoResetOptions: AutoResetOptions{
+				Classifier: func(DecisionTaskFailureInfo) DecisionTaskFailureAction { return DecisionTaskFailureActionReset },
+			},
+		},
+	})
+	assert.NotNil(t, r)
+	assert.Equal(t, defaultAutoResetMinAttempts, r.options.MinAttempts)
+	assert.Equal(t, float64(defaultAutoResetMaxResetsPerSecond), r.options.MaxResetsPerSecond)
+	assert.Equal(t, defaultAutoResetCooldown, r.options.Cooldown)
+}
+
+func TestAutoResetter_OnDecisionTaskFailed(t *testing.T) {
+	task := func(attempt, previousStartedEventID int64) *s.PollForDecisionTaskResponse {
+		return &s.PollForDecisionTaskResponse{
+			Attempt:                common.Int64Ptr(attempt),
+			PreviousStartedEventId: common.Int64Ptr(previousStartedEventID),
+			WorkflowType:           &s.WorkflowType{Name: common.StringPtr("test-workflow")},
+			WorkflowExecution: &s.WorkflowExecution{
+				WorkflowId: common.StringPtr("test-workflow-id"),
+				RunId:      common.StringPtr("test-run-id"),
+			},
+		}
+	}
+	newResetter := func(t *testing.T, action DecisionTaskFailureAction) (*autoResetter, *workflowservicetest.MockClient, *[]DecisionTaskFailureInfo) {
+		var infos []DecisionTaskFailureInfo
+		service := workflowservicetest.NewMockClient(gomock.NewController(t))
+		r := newAutoResetter(service, _testDomainName, workerExecutionParameters{
+			TaskList: &s.TaskList{Name: common.StringPtr(_testTaskList)},
+			WorkerOptions: WorkerOptions{
+				Identity:     _testIdentity,
+				Logger:       zap.NewNop(),
+				MetricsScope: tally.NoopScope,
+				AutoResetOptions: AutoResetOptions{
+					MinAttempts:        2,
+					MaxResetsPerSecond: 100,
+					Classifier: func(info DecisionTaskFailureInfo) DecisionTaskFailureAction {
+						infos = append(infos, info)
+						return action
+					},
+				},
+			},
+		})
+		return r, service, &infos
+	}
+
+	t.Run("not enough attempts", func(t *testing.T) {
+		r, _, infos := newResetter(t, DecisionTaskFailureActionReset)
+		assert.False(t, r.onDecisionTaskFailed(task(1, 5), assert.AnError))
+		assert.Empty(t, *infos)
+	})
+	t.Run("no completed decision", func(t *testing.T) {
+		r, _, infos := newResetter(t, DecisionTaskFailureActionReset)
+		assert.False(t, r.onDecisionTaskFailed(task(5, 0), assert.AnError))
+		assert.Empty(t, *infos)
+	})
+	t.Run("classified as no action", func(t *testing.T) {
+		r, _, infos := newResetter(t, DecisionTaskFailureActionNone)
+		assert.False(t, r.onDecisionTaskFailed(task(2, 5), assert.AnError))
+		assert.Equal(t, []DecisionTaskFailureInfo{{
+			Domain:            _testDomainName,
+			TaskList:          _testTaskList,
+			WorkflowType:      "test-workflow",
+			WorkflowExecution: WorkflowExecution{ID: "test-workflow-id", RunID: "test-run-id"},
+			Attempt:           2,
+			Err:               assert.AnError,
+		}}, *infos)
+	})
+	t.Run("reset once per cooldown", func(t *testing.T) {
+		r, service, _ := newResetter(t, DecisionTaskFailureActionReset)
+		service.EXPECT().ResetWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).
+			DoAndReturn(func(_ interface{}, request *s.ResetWorkflowExecutionRequest, _ ...interface{}) (*s.ResetWorkflowExecutionResponse, error) {
+				assert.Equal(t, _testDomainName, request.GetDomain())
+				assert.Equal(t, "test-workflow-id", request.WorkflowExecution.GetWorkflowId())
+				assert.Equal(t, int64(6), request.GetDecisionFinishEventId())
+				assert.Contains(t, request.GetReason(), autoResetReasonPrefix)
+				assert.False(t, request.GetSkipSignalReapply())
+				return &s.ResetWorkflowExecutionResponse{RunId: common.StringPtr("new-run-id")}, nil
+			}).Times(1)
+
+		assert.True(t, r.onDecisionTaskFailed(task(2, 5), assert.AnError))
+		assert.False(t, r.onDecisionTaskFailed(task(3, 5), assert.AnError))
+	})
+	t.Run("reset fails", func(t *testing.T) {
+		r, service, _ := newResetter(t, DecisionTaskFailureActionReset)
+		service.EXPECT().ResetWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).
+			Return(nil, &s.BadRequestError{Message: "bad request"})
+
+		assert.False(t, r.onDecisionTaskFailed(task(2, 5), assert.AnError))
+	})
+	t.Run("reset retries time out", func(t *testing.T) {
+		r, service, _ := newResetter(t, DecisionTaskFailureActionReset)
+		r.timeout = 100 * time.Millisecond
+		service.EXPECT().ResetWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).
+			Return(nil, &s.InternalServiceError{Message: "unavailable"}).MinTimes(1)
+
+		start := time.Now()
+		assert.False(t, r.onDecisionTaskFailed(task(2, 5), assert.AnError))
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestRespondTaskCompleted_autoReset(t *testing.T) {
+	poller, service, _, _ := buildWorkflowTaskPoller(t)
+	poller.autoResetter = newAutoResetter(service, _testDomainName, workerExecutionParameters{
+		TaskList: &s.TaskList{Name: common.StringPtr(_testTaskList)},
+		WorkerOptions: WorkerOptions{
+			Logger:       zap.NewNop(),
+			MetricsScope: tally.NoopScope,
+			AutoResetOptions: AutoResetOptions{
+				Classifier: func(DecisionTaskFailureInfo) DecisionTaskFailureAction { return DecisionTaskFailureActionReset },
+			},
+		},
+	})
+	service.EXPECT().ResetWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).
+		Return(&s.ResetWorkflowExecutionResponse{RunId: common.StringPtr("new-run-id")}, nil)
+
+	// the failed decision task of the reset run is not responded to, RespondDecisionTaskFailed is not expected
+	res, err := poller.RespondTaskCompletedWithMetrics(nil, assert.AnError, &s.PollForDecisionTaskResponse{
+		TaskToken:              []byte("test-task-token"),
+		Attempt:                common.Int64Ptr(defaultAutoResetMinAttempts),
+		PreviousStartedEventId: common.Int64Ptr(5),
+		WorkflowType:           &s.WorkflowType{Name: common.StringPtr("test-workflow")},
+		WorkflowExecution: &s.WorkflowExecution{
+			WorkflowId: common.StringPtr("test-workflow-id"),
+			RunId:      common.StringPtr("test-run-id"),
+		},
+	}, time.Now())
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+}
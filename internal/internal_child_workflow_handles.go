@@ -0,0 +1,226 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxClosedChildWorkflowHandles is the number of closed child workflows kept for GetChildWorkflowHandles, the oldest
+// ones are dropped beyond it so that long-running workflows starting many children do not grow without bound.
+const maxClosedChildWorkflowHandles = 1000
+
+// ChildWorkflowState is the state of a child workflow started by the current run, see GetChildWorkflowHandles.
+type ChildWorkflowState int
+
+const (
+	// ChildWorkflowStatePending means the start of the child workflow was requested, and it did not start yet.
+	ChildWorkflowStatePending ChildWorkflowState = iota
+	// ChildWorkflowStateStartFailed means the child workflow could not be started, e.g. because a workflow with the
+	// same ID is running.
+	ChildWorkflowStateStartFailed
+	// ChildWorkflowStateRunning means the child workflow started, and did not close yet.
+	ChildWorkflowStateRunning
+	// ChildWorkflowStateCompleted means the child workflow completed successfully.
+	ChildWorkflowStateCompleted
+	// ChildWorkflowStateFailed means the child workflow failed.
+	ChildWorkflowStateFailed
+	// ChildWorkflowStateCanceled means the child workflow was canceled.
+	ChildWorkflowStateCanceled
+	// ChildWorkflowStateTimedOut means the child workflow timed out.
+	ChildWorkflowStateTimedOut
+	// ChildWorkflowStateTerminated means the child workflow was terminated.
+	ChildWorkflowStateTerminated
+)
+
+type (
+	// ChildWorkflowHandle is a snapshot of a child workflow started by the current run, see GetChildWorkflowHandles.
+	ChildWorkflowHandle struct {
+		// WorkflowID of the child workflow. It is empty until the child workflow started when the ID was not set
+		// in the ChildWorkflowOptions.
+		WorkflowID string
+		// RunID of the child workflow, empty until it started.
+		RunID        string
+		WorkflowType string
+		Domain       string
+		State        ChildWorkflowState
+		// Future returned by ExecuteChildWorkflow, e.g. to wait for the child workflow or to signal it.
+		Future ChildWorkflowFuture
+	}
+
+	// childWorkflowRecord tracks a child workflow started by the current run, updated by the callbacks of its
+	// decisions, so that it is deterministic.
+	childWorkflowRecord struct {
+		handle ChildWorkflowHandle
+	}
+)
+
+// String returns the string representation of a ChildWorkflowState.
+func (s ChildWorkflowState) String() string {
+	switch s {
+	case ChildWorkflowStatePending:
+		return "Pending"
+	case ChildWorkflowStateStartFailed:
+		return "StartFailed"
+	case ChildWorkflowStateRunning:
+		return "Running"
+	case ChildWorkflowStateCompleted:
+		return "Completed"
+	case ChildWorkflowStateFailed:
+		return "Failed"
+	case ChildWorkflowStateCanceled:
+		return "Canceled"
+	case ChildWorkflowStateTimedOut:
+		return "TimedOut"
+	case ChildWorkflowStateTerminated:
+		return "Terminated"
+	default:
+		return fmt.Sprintf("ChildWorkflowState(%d)", int(s))
+	}
+}
+
+// IsClosed returns true when the child workflow will not make progress anymore, i.e. it failed to start or closed.
+func (s ChildWorkflowState) IsClosed() bool {
+	return s != ChildWorkflowStatePending && s != ChildWorkflowStateRunning
+}
+
+// GetChildWorkflowHandles returns the child workflows started by the current run, in the order they were started,
+// with their state, e.g. to cancel the ones still running or to wait for them:
+//
+//	for _, child := range workflow.GetChildWorkflowHandles(ctx) {
+//	  if !child.State.IsClosed() {
+//	    _ = child.Future.Get(ctx, nil)
+//	  }
+//	}
+//
+// The handles are snapshots, call GetChildWorkflowHandles again to get the current states. The child workflows which
+// were not started because their options or arguments were invalid, or because the context was already canceled,
+// are not returned. Only the last 1000 closed child workflows are returned, the pending and running ones are always
+// returned.
+func GetChildWorkflowHandles(ctx Context) []ChildWorkflowHandle {
+	children := getEnvInterceptor(ctx).children
+	handles := make([]ChildWorkflowHandle, 0, len(children))
+	for _, child := range children {
+		handles = append(handles, child.handle)
+	}
+	return handles
+}
+
+// CancelChildren requests the cancellation of the child workflows of the current run which are pending or running, and
+// for which filter returns true, or all of them when filter is nil. It returns a Future ready once all the requests
+// completed, whose error combines the errors of the failed requests. The pending child workflows are canceled once they
+// started, the ones which fail to start are ignored.
+// Use a disconnected context to cancel the children when the workflow itself is canceled:
+//
+//	defer func() {
+//	  if errors.Is(ctx.Err(), workflow.ErrCanceled) {
+//	    ctx, _ := workflow.NewDisconnectedContext(ctx)
+//	    _ = workflow.CancelChildren(ctx, nil).Get(ctx, nil)
+//	  }
+//	}()
+func CancelChildren(ctx Context, filter func(child ChildWorkflowHandle) bool) Future {
+	var children []ChildWorkflowHandle
+	for _, child := range GetChildWorkflowHandles(ctx) {
+		if !child.State.IsClosed() && (filter == nil || filter(child)) {
+			children = append(children, child)
+		}
+	}
+	future, settable := NewFuture(ctx)
+	Go(ctx, func(ctx Context) {
+		var (
+			executions []WorkflowExecution
+			futures    []Future
+		)
+		for _, child := range children {
+			var execution WorkflowExecution
+			if err := child.Future.GetChildWorkflowExecution().Get(ctx, &execution); err != nil || child.Future.IsReady() {
+				// not started or already closed, there is nothing to cancel
+				continue
+			}
+			executions = append(executions, execution)
+			futures = append(futures, RequestCancelExternalWorkflow(WithWorkflowDomain(ctx, child.Domain), execution.ID, execution.RunID))
+		}
+		err := combineCancelRequests(ctx, executions, futures).Get(ctx, nil)
+		settable.Set(nil, err)
+	})
+	return future
+}
+
+// dropClosedChildren drops the oldest closed child workflows beyond maxClosedChildWorkflowHandles.
+func (wc *workflowEnvironmentInterceptor) dropClosedChildren() {
+	closed := 0
+	for _, child := range wc.children {
+		if child.handle.State.IsClosed() {
+			closed++
+		}
+	}
+	if closed <= maxClosedChildWorkflowHandles {
+		return
+	}
+	children := wc.children[:0]
+	for _, child := range wc.children {
+		if closed > maxClosedChildWorkflowHandles && child.handle.State.IsClosed() {
+			closed--
+			continue
+		}
+		children = append(children, child)
+	}
+	for i := len(children); i < len(wc.children); i++ {
+		wc.children[i] = nil
+	}
+	wc.children = children
+}
+
+func (r *childWorkflowRecord) started(execution WorkflowExecution, err error) {
+	if err != nil {
+		if r.handle.State == ChildWorkflowStatePending {
+			r.handle.State = ChildWorkflowStateStartFailed
+		}
+		return
+	}
+	r.handle.WorkflowID = execution.ID
+	r.handle.RunID = execution.RunID
+	r.handle.State = ChildWorkflowStateRunning
+}
+
+func (r *childWorkflowRecord) closed(err error) {
+	if r.handle.State == ChildWorkflowStateStartFailed {
+		return
+	}
+	var (
+		canceledErr   *CanceledError
+		timeoutErr    *TimeoutError
+		terminatedErr *TerminatedError
+	)
+	switch {
+	case err == nil:
+		r.handle.State = ChildWorkflowStateCompleted
+	case errors.As(err, &canceledErr):
+		r.handle.State = ChildWorkflowStateCanceled
+	case errors.As(err, &timeoutErr):
+		r.handle.State = ChildWorkflowStateTimedOut
+	case errors.As(err, &terminatedErr):
+		r.handle.State = ChildWorkflowStateTerminated
+	default:
+		r.handle.State = ChildWorkflowStateFailed
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+func TestGetChildWorkflowHandles(t *testing.T) {
+	childFn := func(ctx Context, behavior string) error {
+		switch behavior {
+		case "fail":
+			return errors.New("child failed")
+		case "sleep":
+			return Sleep(ctx, time.Hour)
+		default:
+			return nil
+		}
+	}
+	states := func(handles []ChildWorkflowHandle) []ChildWorkflowState {
+		var states []ChildWorkflowState
+		for _, handle := range handles {
+			states = append(states, handle.State)
+		}
+		return states
+	}
+
+	workflowFn := func(ctx Context) error {
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{
+			ExecutionStartToCloseTimeout: 2 * time.Hour,
+			WaitForCancellation:          true,
+		})
+		assert.Empty(t, GetChildWorkflowHandles(ctx))
+
+		completed := ExecuteChildWorkflow(WithWorkflowID(ctx, "completed"), childFn, "complete")
+		failed := ExecuteChildWorkflow(ctx, childFn, "fail")
+		sleepCtx, cancel := WithCancel(ctx)
+		canceled := ExecuteChildWorkflow(WithWorkflowID(sleepCtx, "canceled"), childFn, "sleep")
+		// not started, as the context is already canceled
+		canceledCtx, cancelNow := WithCancel(ctx)
+		cancelNow()
+		_ = ExecuteChildWorkflow(canceledCtx, childFn, "complete")
+
+		handles := GetChildWorkflowHandles(ctx)
+		require.Len(t, handles, 3)
+		assert.Equal(t, []ChildWorkflowState{ChildWorkflowStatePending, ChildWorkflowStatePending, ChildWorkflowStatePending}, states(handles))
+		assert.Equal(t, "completed", handles[0].WorkflowID)
+		assert.Empty(t, handles[1].WorkflowID, "the generated ID is known once started")
+		assert.Equal(t, completed, handles[0].Future)
+		assert.Equal(t, defaultTestDomain, handles[0].Domain)
+		assert.Contains(t, handles[0].WorkflowType, "TestGetChildWorkflowHandles")
+
+		var execution WorkflowExecution
+		require.NoError(t, canceled.GetChildWorkflowExecution().Get(ctx, &execution))
+		handles = GetChildWorkflowHandles(ctx)
+		assert.Equal(t, ChildWorkflowStateRunning, handles[2].State)
+		assert.Equal(t, execution.RunID, handles[2].RunID)
+
+		assert.NoError(t, completed.Get(ctx, nil))
+		assert.Error(t, failed.Get(ctx, nil))
+		cancel()
+		assert.Error(t, canceled.Get(ctx, nil))
+
+		handles = GetChildWorkflowHandles(ctx)
+		assert.Equal(t, []ChildWorkflowState{ChildWorkflowStateCompleted, ChildWorkflowStateFailed, ChildWorkflowStateCanceled}, states(handles))
+		for _, handle := range handles {
+			assert.True(t, handle.State.IsClosed())
+			assert.NotEmpty(t, handle.WorkflowID)
+			assert.NotEmpty(t, handle.RunID)
+		}
+		return nil
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterWorkflow(childFn)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+}
+
+func TestCancelChildren(t *testing.T) {
+	shardFn := func(ctx Context) error {
+		return Sleep(ctx, time.Hour)
+	}
+	reportFn := func(ctx Context) error {
+		return Sleep(ctx, time.Hour)
+	}
+
+	workflowFn := func(ctx Context) error {
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{
+			ExecutionStartToCloseTimeout: 2 * time.Hour,
+			WaitForCancellation:          true,
+		})
+		shard1 := ExecuteChildWorkflow(ctx, "shard")
+		shard2 := ExecuteChildWorkflow(ctx, "shard")
+		report := ExecuteChildWorkflow(ctx, "report")
+		// shard2 is pending, and canceled once started
+		require.NoError(t, shard1.GetChildWorkflowExecution().Get(ctx, nil))
+
+		err := CancelChildren(ctx, func(child ChildWorkflowHandle) bool {
+			return child.WorkflowType == "shard"
+		}).Get(ctx, nil)
+		require.NoError(t, err)
+		var canceledErr *CanceledError
+		assert.True(t, errors.As(shard1.Get(ctx, nil), &canceledErr))
+		assert.True(t, errors.As(shard2.Get(ctx, nil), &canceledErr))
+		assert.False(t, report.IsReady())
+		states := []ChildWorkflowState{}
+		for _, child := range GetChildWorkflowHandles(ctx) {
+			states = append(states, child.State)
+		}
+		assert.Equal(t, []ChildWorkflowState{ChildWorkflowStateCanceled, ChildWorkflowStateCanceled, ChildWorkflowStateRunning}, states)
+
+		// the closed children are not canceled again
+		require.NoError(t, CancelChildren(ctx, nil).Get(ctx, nil))
+		assert.True(t, errors.As(report.Get(ctx, nil), &canceledErr))
+		return nil
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterWorkflowWithOptions(shardFn, RegisterWorkflowOptions{Name: "shard"})
+	env.RegisterWorkflowWithOptions(reportFn, RegisterWorkflowOptions{Name: "report"})
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+}
+
+func TestRequestCancelExternalWorkflows(t *testing.T) {
+	var err error
+	workflowFn := func(ctx Context) error {
+		err = RequestCancelExternalWorkflows(ctx, []WorkflowExecution{
+			{ID: "workflow-1", RunID: "run-1"},
+			{ID: "workflow-2", RunID: "run-2"},
+			{ID: "workflow-3", RunID: "run-3"},
+		}).Get(ctx, nil)
+		return nil
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.OnRequestCancelExternalWorkflow(mock.Anything, "workflow-1", "run-1").Return(errors.New("not found")).Once()
+	env.OnRequestCancelExternalWorkflow(mock.Anything, "workflow-2", "run-2").Return(nil).Once()
+	env.OnRequestCancelExternalWorkflow(mock.Anything, "workflow-3", "run-3").Return(errors.New("already completed")).Once()
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	env.AssertExpectations(t)
+
+	require.NoError(t, env.GetWorkflowError())
+	errs := multierr.Errors(err)
+	require.Len(t, errs, 2, "unexpected error %v", err)
+	assert.ErrorContains(t, errs[0], "cancel workflow workflow-1: not found")
+	assert.ErrorContains(t, errs[1], "cancel workflow workflow-3: already completed")
+}
+
+func TestChildWorkflowRecord(t *testing.T) {
+	record := &childWorkflowRecord{}
+	record.started(WorkflowExecution{}, errors.New("workflow already started"))
+	record.closed(errors.New("workflow already started"))
+	assert.Equal(t, ChildWorkflowStateStartFailed, record.handle.State)
+
+	// canceled before it started
+	record = &childWorkflowRecord{}
+	record.closed(NewCanceledError())
+	record.started(WorkflowExecution{}, NewCanceledError())
+	assert.Equal(t, ChildWorkflowStateCanceled, record.handle.State)
+
+	record = &childWorkflowRecord{}
+	record.started(WorkflowExecution{ID: "id", RunID: "run-id"}, nil)
+	assert.Equal(t, ChildWorkflowHandle{WorkflowID: "id", RunID: "run-id", State: ChildWorkflowStateRunning}, record.handle)
+	record.closed(NewTimeoutError(shared.TimeoutTypeStartToClose))
+	assert.Equal(t, ChildWorkflowStateTimedOut, record.handle.State)
+}
+
+func TestDropClosedChildren(t *testing.T) {
+	running := &childWorkflowRecord{handle: ChildWorkflowHandle{WorkflowID: "running", State: ChildWorkflowStateRunning}}
+	wc := &workflowEnvironmentInterceptor{children: []*childWorkflowRecord{running}}
+	for i := 0; i <= maxClosedChildWorkflowHandles; i++ {
+		wc.children = append(wc.children, &childWorkflowRecord{handle: ChildWorkflowHandle{
+			WorkflowID: fmt.Sprintf("closed-%d", i),
+			State:      ChildWorkflowStateCompleted,
+		}})
+	}
+	wc.dropClosedChildren()
+	require.Len(t, wc.children, maxClosedChildWorkflowHandles+1)
+	assert.Equal(t, running, wc.children[0], "the running children are kept")
+	assert.Equal(t, "closed-1", wc.children[1].handle.WorkflowID, "the oldest closed child is dropped")
+
+	wc.dropClosedChildren()
+	assert.Len(t, wc.children, maxClosedChildWorkflowHandles+1)
+}
+
+func TestChildWorkflowStateString(t *testing.T) {
+	assert.Equal(t, "Running", ChildWorkflowStateRunning.String())
+	assert.Equal(t, "TimedOut", ChildWorkflowStateTimedOut.String())
+	assert.Equal(t, "ChildWorkflowState(42)", ChildWorkflowState(42).String())
+	assert.False(t, ChildWorkflowStatePending.IsClosed())
+	assert.True(t, ChildWorkflowStateStartFailed.IsClosed())
+}
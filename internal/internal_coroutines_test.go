@@ -691,6 +691,24 @@ func TestPanic(t *testing.T) {
 	require.Contains(t, panicError.StackTrace(), "cadence/internal.TestPanic")
 }
 
+func TestBlockingOnCompletedCoroutine(t *testing.T) {
+	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+		var childCtx Context
+		wg := NewWaitGroup(ctx)
+		wg.Add(1)
+		Go(ctx, func(ctx Context) {
+			childCtx = ctx
+			wg.Done()
+		})
+		wg.Wait(ctx)
+		NewChannel(ctx).Receive(childCtx, nil)
+	})
+	defer d.Close()
+	err := d.ExecuteUntilAllBlocked()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "block on coroutine which has completed")
+}
+
 func TestAwait(t *testing.T) {
 	flag := false
 	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
@@ -727,6 +745,60 @@ func TestAwaitCancellation(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestReceiveWithCancel(t *testing.T) {
+	var history []string
+	ctx := createRootTestContext(t)
+	ctx, cancelHandler := WithCancel(ctx)
+	d, _ := newDispatcher(ctx, func(ctx Context) {
+		c := NewChannel(ctx)
+		Go(ctx, func(ctx Context) {
+			c.Send(ctx, "value")
+		})
+		var value string
+		ok, canceled := ReceiveWithCancel(ctx, c, &value)
+		history = append(history, fmt.Sprintf("received %v %v %v", value, ok, canceled))
+
+		ok, canceled = ReceiveWithCancel(ctx, c, &value)
+		history = append(history, fmt.Sprintf("canceled %v %v", ok, canceled))
+
+		// a value which can be received without blocking wins
+		buffered := NewBufferedChannel(ctx, 1)
+		buffered.SendAsync("buffered")
+		ok, canceled = ReceiveWithCancel(ctx, buffered, &value)
+		history = append(history, fmt.Sprintf("received %v %v %v", value, ok, canceled))
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	require.False(t, d.IsDone())
+	cancelHandler()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	require.True(t, d.IsDone())
+	require.Equal(t, []string{
+		"received value true false",
+		"canceled false true",
+		"received buffered true false",
+	}, history)
+}
+
+func TestReceiveWithCancelClosed(t *testing.T) {
+	var history []string
+	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+		c := NewChannel(ctx)
+		Go(ctx, func(ctx Context) {
+			c.Close()
+		})
+		var value string
+		ok, canceled := ReceiveWithCancel(ctx, c, &value)
+		history = append(history, fmt.Sprintf("closed while blocked %v %v", ok, canceled))
+		ok, canceled = ReceiveWithCancel(ctx, c, &value)
+		history = append(history, fmt.Sprintf("closed %v %v", ok, canceled))
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	require.True(t, d.IsDone())
+	require.Equal(t, []string{"closed while blocked false false", "closed false false"}, history)
+}
+
 func TestFutureSetValue(t *testing.T) {
 	var history []string
 	var f Future
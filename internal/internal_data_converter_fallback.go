@@ -0,0 +1,74 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// fallbackDataConverter encodes with its first converter, and decodes with the first of its converters that succeeds.
+type fallbackDataConverter struct {
+	converters []DataConverter
+}
+
+// NewFallbackDataConverter docs are in the public API to prevent duplication: [go.uber.org/cadence/encoded.NewFallbackDataConverter]
+func NewFallbackDataConverter(primary DataConverter, fallbacks ...DataConverter) DataConverter {
+	if primary == nil {
+		primary = getDefaultDataConverter()
+	}
+	converters := []DataConverter{primary}
+	for _, fallback := range fallbacks {
+		if fallback != nil {
+			converters = append(converters, fallback)
+		}
+	}
+	return &fallbackDataConverter{converters: converters}
+}
+
+func (dc *fallbackDataConverter) ToData(value ...interface{}) ([]byte, error) {
+	return dc.converters[0].ToData(value...)
+}
+
+func (dc *fallbackDataConverter) FromData(input []byte, valuePtr ...interface{}) error {
+	var errs []error
+	for i, converter := range dc.converters {
+		if i > 0 {
+			// a failed attempt may have decoded some of the values, or some of their fields
+			resetValues(valuePtr)
+		}
+		err := converter.FromData(input, valuePtr...)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("data converter %d: %w", i, err))
+	}
+	return errors.Join(errs...)
+}
+
+func resetValues(valuePtr []interface{}) {
+	for _, ptr := range valuePtr {
+		if v := reflect.ValueOf(ptr); v.Kind() == reflect.Ptr && !v.IsNil() {
+			v.Elem().Set(reflect.Zero(v.Elem().Type()))
+		}
+	}
+}
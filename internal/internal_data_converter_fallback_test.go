@@ -0,0 +1,72 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingDataConverter struct{}
+
+func (failingDataConverter) ToData(value ...interface{}) ([]byte, error) {
+	return nil, errors.New("failed to encode")
+}
+
+func (failingDataConverter) FromData(input []byte, valuePtr ...interface{}) error {
+	return errors.New("failed to decode")
+}
+
+func TestFallbackDataConverter(t *testing.T) {
+	t.Parallel()
+	type order struct {
+		ID     string
+		Amount int
+	}
+	legacy := newTestDataConverter()
+	dc := NewFallbackDataConverter(nil, legacy)
+
+	data, err := dc.ToData(order{ID: "order-1", Amount: 10}, "note")
+	require.NoError(t, err)
+	jsonData, err := getDefaultDataConverter().ToData(order{ID: "order-1", Amount: 10}, "note")
+	require.NoError(t, err)
+	assert.Equal(t, jsonData, data, "encoded by the primary data converter")
+
+	var o order
+	var note string
+	require.NoError(t, dc.FromData(data, &o, &note))
+	assert.Equal(t, order{ID: "order-1", Amount: 10}, o)
+	assert.Equal(t, "note", note)
+
+	gobData, err := legacy.ToData(order{ID: "order-2", Amount: 20}, "legacy")
+	require.NoError(t, err)
+	o, note = order{ID: "stale"}, ""
+	require.NoError(t, dc.FromData(gobData, &o, &note), "decoded by the fallback data converter")
+	assert.Equal(t, order{ID: "order-2", Amount: 20}, o)
+	assert.Equal(t, "legacy", note)
+
+	err = NewFallbackDataConverter(failingDataConverter{}, failingDataConverter{}).FromData(data, &o)
+	assert.ErrorContains(t, err, "data converter 0: failed to decode")
+	assert.ErrorContains(t, err, "data converter 1: failed to decode")
+}
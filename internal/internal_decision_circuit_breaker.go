@@ -0,0 +1,158 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultDecisionCircuitBreakerCooldown = 5 * time.Minute
+
+type (
+	// DecisionCircuitBreakerOptions configures a DecisionCircuitBreaker.
+	DecisionCircuitBreakerOptions struct {
+		// Required: number of consecutive failed decision tasks of a workflow type which trips the breaker.
+		// Failures are counted per worker process, a process crashing while processing a decision task is not
+		// counted.
+		FailureThreshold int
+
+		// Optional: how long decision tasks of a tripped workflow type are not processed. Once it has passed, decision
+		// tasks are processed again, and the first failure trips the breaker again.
+		// default: 5 minutes
+		Cooldown time.Duration
+	}
+
+	// DecisionCircuitBreaker stops processing decision tasks of a workflow type after they keep failing, e.g. because
+	// its workflow code panics, see WorkerOptions.DecisionCircuitBreaker. Decision tasks of a tripped workflow type are
+	// failed for the cooldown, like the tasks rejected by a WorkflowFilter, so that the server dispatches them again
+	// right away, possibly to another worker. Use NewDecisionCircuitBreaker to create one; it is safe for concurrent
+	// use and can be shared by workers.
+	DecisionCircuitBreaker struct {
+		options DecisionCircuitBreakerOptions
+		now     func() time.Time
+
+		mu    sync.Mutex
+		types map[string]*decisionCircuitState
+	}
+
+	decisionCircuitState struct {
+		consecutiveFailures int
+		openUntil           time.Time
+	}
+)
+
+// NewDecisionCircuitBreaker returns a closed circuit breaker, or nil if FailureThreshold is not positive.
+func NewDecisionCircuitBreaker(options DecisionCircuitBreakerOptions) *DecisionCircuitBreaker {
+	if options.FailureThreshold <= 0 {
+		return nil
+	}
+	if options.Cooldown <= 0 {
+		options.Cooldown = defaultDecisionCircuitBreakerCooldown
+	}
+	return &DecisionCircuitBreaker{
+		options: options,
+		now:     time.Now,
+		types:   make(map[string]*decisionCircuitState),
+	}
+}
+
+// OpenWorkflowTypes returns the workflow types whose decision tasks are currently not processed, sorted by name.
+func (b *DecisionCircuitBreaker) OpenWorkflowTypes() []string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	var open []string
+	for workflowType, state := range b.types {
+		if now.Before(state.openUntil) {
+			open = append(open, workflowType)
+		}
+	}
+	sort.Strings(open)
+	return open
+}
+
+// Reset closes the breaker of the workflow type and clears its failure count, e.g. once a fix is deployed.
+func (b *DecisionCircuitBreaker) Reset(workflowType string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.types, workflowType)
+}
+
+// ResetAll closes the breakers of all workflow types.
+func (b *DecisionCircuitBreaker) ResetAll() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.types = make(map[string]*decisionCircuitState)
+}
+
+// allow returns whether decision tasks of the workflow type are processed. A nil breaker allows all of them.
+func (b *DecisionCircuitBreaker) allow(workflowType string) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.types[workflowType]
+	return !ok || !b.now().Before(state.openUntil)
+}
+
+// onResult records the outcome of a decision task and returns true if its failure tripped the breaker.
+func (b *DecisionCircuitBreaker) onResult(workflowType string, failed bool) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.types[workflowType]
+	if !failed {
+		if ok {
+			delete(b.types, workflowType)
+		}
+		return false
+	}
+	if !ok {
+		state = &decisionCircuitState{}
+		b.types[workflowType] = state
+	}
+	now := b.now()
+	if now.Before(state.openUntil) {
+		// a task which started before the breaker tripped
+		return false
+	}
+	state.consecutiveFailures++
+	if state.consecutiveFailures < b.options.FailureThreshold {
+		return false
+	}
+	state.openUntil = now.Add(b.options.Cooldown)
+	// after the cooldown, the next failure trips the breaker again
+	state.consecutiveFailures = b.options.FailureThreshold - 1
+	return true
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+func TestDecisionCircuitBreaker(t *testing.T) {
+	var nilBreaker *DecisionCircuitBreaker
+	assert.True(t, nilBreaker.allow("any"))
+	assert.False(t, nilBreaker.onResult("any", true))
+	assert.Nil(t, NewDecisionCircuitBreaker(DecisionCircuitBreakerOptions{}))
+
+	now := time.Unix(0, 0)
+	b := NewDecisionCircuitBreaker(DecisionCircuitBreakerOptions{FailureThreshold: 3})
+	require.NotNil(t, b)
+	assert.Equal(t, defaultDecisionCircuitBreakerCooldown, b.options.Cooldown)
+	b.now = func() time.Time { return now }
+
+	assert.False(t, b.onResult("wt", true))
+	assert.False(t, b.onResult("wt", true))
+	assert.False(t, b.onResult("wt", false), "success resets the failure count")
+	assert.False(t, b.onResult("wt", true))
+	assert.False(t, b.onResult("wt", true))
+	assert.True(t, b.allow("wt"))
+	assert.True(t, b.onResult("wt", true))
+	assert.False(t, b.allow("wt"))
+	assert.True(t, b.allow("other"))
+	assert.Equal(t, []string{"wt"}, b.OpenWorkflowTypes())
+	assert.False(t, b.onResult("wt", true), "failures of tasks started before tripping are ignored")
+
+	now = now.Add(defaultDecisionCircuitBreakerCooldown)
+	assert.True(t, b.allow("wt"))
+	assert.Empty(t, b.OpenWorkflowTypes())
+	assert.True(t, b.onResult("wt", true), "first failure after the cooldown trips again")
+	assert.False(t, b.allow("wt"))
+
+	b.Reset("wt")
+	assert.True(t, b.allow("wt"))
+	assert.False(t, b.onResult("wt", true), "reset clears the failure count")
+
+	b.onResult("a", true)
+	b.onResult("a", true)
+	b.onResult("a", true)
+	b.onResult("b", true)
+	b.onResult("b", true)
+	b.onResult("b", true)
+	assert.Equal(t, []string{"a", "b"}, b.OpenWorkflowTypes())
+	b.ResetAll()
+	assert.Empty(t, b.OpenWorkflowTypes())
+}
+
+func TestWorkflowTaskPoller_DecisionCircuitBreaker(t *testing.T) {
+	poller, service, taskHandler, _ := buildWorkflowTaskPoller(t)
+	scope := tally.NewTestScope("", nil)
+	poller.metricsScope = metrics.NewTaggedScope(scope)
+	poller.breaker = NewDecisionCircuitBreaker(DecisionCircuitBreakerOptions{FailureThreshold: 2, Cooldown: time.Hour})
+
+	// failures of retried decision tasks are not reported to the service
+	task := &s.PollForDecisionTaskResponse{
+		TaskToken:    []byte("token"),
+		Attempt:      common.Int64Ptr(1),
+		WorkflowType: &s.WorkflowType{Name: common.StringPtr("poison")},
+		WorkflowExecution: &s.WorkflowExecution{
+			WorkflowId: common.StringPtr("wid"),
+			RunId:      common.StringPtr("rid"),
+		},
+	}
+	for i := 0; i < 2; i++ {
+		_, err := poller.RespondTaskCompletedWithMetrics(nil, errors.New("panic"), task, time.Now())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"poison"}, poller.breaker.OpenWorkflowTypes())
+
+	// the task handler is not called, and the rejected task is failed so that the server dispatches it again
+	service.EXPECT().RespondDecisionTaskFailed(gomock.Any(), gomock.Any(), callOptions()...).
+		DoAndReturn(func(_ context.Context, request *s.RespondDecisionTaskFailedRequest, _ ...yarpc.CallOption) error {
+			assert.Equal(t, s.DecisionTaskFailedCauseResetStickyTasklist, request.GetCause())
+			return nil
+		})
+	task.Attempt = common.Int64Ptr(0)
+	assert.NoError(t, poller.ProcessTask(&workflowTask{task: task}))
+	taskHandler.AssertNotCalled(t, "ProcessWorkflowTask")
+
+	counters := make(map[string]int64)
+	for _, c := range scope.Snapshot().Counters() {
+		counters[c.Name()] += c.Value()
+	}
+	assert.Equal(t, int64(1), counters[metrics.DecisionCircuitBreakerTrippedCounter])
+	assert.Equal(t, int64(1), counters[metrics.DecisionTaskCircuitBreakerRejectedCounter])
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+// ratioToWarnDecisionTaskNearTimeout is the fraction of the decision timeout after which a decision task that is
+// still being processed is reported as near timeout. It is below ratioToForceCompleteDecisionTaskComplete so the
+// warning is raised before local activities force a decision heartbeat.
+const ratioToWarnDecisionTaskNearTimeout = 0.7
+
+// DecisionTaskNearTimeoutInfo describes a decision task that has used most of its decision timeout.
+type DecisionTaskNearTimeoutInfo struct {
+	WorkflowType      string
+	WorkflowExecution WorkflowExecution
+	// Elapsed is the time spent processing the decision task so far.
+	Elapsed time.Duration
+	// Remaining is the time left before the decision task times out.
+	Remaining time.Duration
+}
+
+// startDecisionNearTimeoutTimer starts a timer which reports the decision task as near timeout once
+// ratioToWarnDecisionTaskNearTimeout of the decision timeout has elapsed. The returned timer is nil
+// if the task has no decision timeout; otherwise the caller must stop it once the task is processed.
+func (wth *workflowTaskHandlerImpl) startDecisionNearTimeoutTimer(
+	task *s.PollForDecisionTaskResponse,
+	startTime time.Time,
+	decisionTimeout time.Duration,
+) *time.Timer {
+	if task.Query != nil || decisionTimeout <= 0 {
+		return nil
+	}
+	warnAfter := time.Duration(ratioToWarnDecisionTaskNearTimeout * float64(decisionTimeout))
+	return time.AfterFunc(startTime.Add(warnAfter).Sub(time.Now()), func() {
+		elapsed := time.Since(startTime)
+		info := DecisionTaskNearTimeoutInfo{
+			WorkflowType: task.WorkflowType.GetName(),
+			WorkflowExecution: WorkflowExecution{
+				ID:    task.WorkflowExecution.GetWorkflowId(),
+				RunID: task.WorkflowExecution.GetRunId(),
+			},
+			Elapsed:   elapsed,
+			Remaining: decisionTimeout - elapsed,
+		}
+		wth.metricsScope.GetTaggedScope(tagWorkflowType, info.WorkflowType).
+			Counter(metrics.DecisionTaskNearTimeoutCounter).Inc(1)
+		wth.logger.Warn("Decision task is close to its timeout.",
+			zap.String(tagWorkflowType, info.WorkflowType),
+			zap.String(tagWorkflowID, info.WorkflowExecution.ID),
+			zap.String(tagRunID, info.WorkflowExecution.RunID),
+			zap.Duration("Elapsed", info.Elapsed),
+			zap.Duration("Remaining", info.Remaining))
+		if wth.decisionTaskNearTimeoutCallback != nil {
+			wth.decisionTaskNearTimeoutCallback(info)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap/zaptest"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+func TestDecisionNearTimeoutTimer(t *testing.T) {
+	testScope := tally.NewTestScope("", nil)
+	infoCh := make(chan DecisionTaskNearTimeoutInfo, 1)
+	wth := &workflowTaskHandlerImpl{
+		logger:       zaptest.NewLogger(t),
+		metricsScope: metrics.NewTaggedScope(testScope),
+		decisionTaskNearTimeoutCallback: func(info DecisionTaskNearTimeoutInfo) {
+			infoCh <- info
+		},
+	}
+	task := &s.PollForDecisionTaskResponse{
+		WorkflowType:      &s.WorkflowType{Name: common.StringPtr("test-workflow")},
+		WorkflowExecution: &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+	}
+
+	timer := wth.startDecisionNearTimeoutTimer(task, time.Now(), 100*time.Millisecond)
+	require.NotNil(t, timer)
+	defer timer.Stop()
+
+	select {
+	case info := <-infoCh:
+		assert.Equal(t, "test-workflow", info.WorkflowType)
+		assert.Equal(t, WorkflowExecution{ID: "wid", RunID: "rid"}, info.WorkflowExecution)
+		assert.GreaterOrEqual(t, info.Elapsed, 70*time.Millisecond)
+		assert.Equal(t, 100*time.Millisecond, info.Elapsed+info.Remaining)
+	case <-time.After(time.Second):
+		t.Fatal("near timeout callback was not called")
+	}
+	counter := testScope.Snapshot().Counters()[metrics.DecisionTaskNearTimeoutCounter+"+WorkflowType=test-workflow"]
+	require.NotNil(t, counter)
+	assert.Equal(t, int64(1), counter.Value())
+}
+
+func TestDecisionNearTimeoutTimer_Disabled(t *testing.T) {
+	wth := &workflowTaskHandlerImpl{}
+	task := &s.PollForDecisionTaskResponse{}
+	assert.Nil(t, wth.startDecisionNearTimeoutTimer(task, time.Now(), 0))
+
+	task.Query = &s.WorkflowQuery{}
+	assert.Nil(t, wth.startDecisionNearTimeoutTimer(task, time.Now(), time.Second))
+}
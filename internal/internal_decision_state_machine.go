@@ -23,6 +23,7 @@ package internal
 import (
 	"container/list"
 	"fmt"
+	"strconv"
 
 	s "go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
@@ -161,6 +162,7 @@ const (
 	versionMarkerName           = "Version"
 	localActivityMarkerName     = "LocalActivity"
 	mutableSideEffectMarkerName = "MutableSideEffect"
+	sdkVersionMarkerName        = "SDKVersion"
 )
 
 func (d decisionState) String() string {
@@ -213,6 +215,10 @@ func (d decisionID) String() string {
 	return fmt.Sprintf("DecisionType: %v, ID: %v", d.decisionType, d.id)
 }
 
+// decisionStateHistoryCapacity is the initial capacity of the history of a decision state machine, enough for the
+// transitions of most decisions, e.g. created, sent, initiated, started and completed, without growing the slice.
+const decisionStateHistoryCapacity = 8
+
 func makeDecisionID(decisionType decisionType, id string) decisionID {
 	return decisionID{decisionType: decisionType, id: id}
 }
@@ -221,7 +227,7 @@ func (h *decisionsHelper) newDecisionStateMachineBase(decisionType decisionType,
 	return &decisionStateMachineBase{
 		id:      makeDecisionID(decisionType, id),
 		state:   decisionStateCreated,
-		history: []string{decisionStateCreated.String()},
+		history: append(make([]string, 0, decisionStateHistoryCapacity), decisionStateCreated.String()),
 		helper:  h,
 	}
 }
@@ -774,7 +780,7 @@ func (h *decisionsHelper) getActivityID(event *s.HistoryEvent) string {
 }
 
 func (h *decisionsHelper) recordVersionMarker(changeID string, version Version, dataConverter DataConverter) decisionStateMachine {
-	markerID := fmt.Sprintf("%v_%v", versionMarkerName, changeID)
+	markerID := versionMarkerName + "_" + changeID
 	details, err := encodeArgs(dataConverter, []interface{}{changeID, version})
 	if err != nil {
 		panic(err)
@@ -791,7 +797,7 @@ func (h *decisionsHelper) recordVersionMarker(changeID string, version Version,
 }
 
 func (h *decisionsHelper) recordSideEffectMarker(sideEffectID int32, data []byte) decisionStateMachine {
-	markerID := fmt.Sprintf("%v_%v", sideEffectMarkerName, sideEffectID)
+	markerID := sideEffectMarkerName + "_" + strconv.Itoa(int(sideEffectID))
 	attributes := &s.RecordMarkerDecisionAttributes{
 		MarkerName: common.StringPtr(sideEffectMarkerName),
 		Details:    data,
@@ -802,7 +808,7 @@ func (h *decisionsHelper) recordSideEffectMarker(sideEffectID int32, data []byte
 }
 
 func (h *decisionsHelper) recordLocalActivityMarker(activityID string, result []byte) decisionStateMachine {
-	markerID := fmt.Sprintf("%v_%v", localActivityMarkerName, activityID)
+	markerID := localActivityMarkerName + "_" + activityID
 	attributes := &s.RecordMarkerDecisionAttributes{
 		MarkerName: common.StringPtr(localActivityMarkerName),
 		Details:    result,
@@ -813,7 +819,7 @@ func (h *decisionsHelper) recordLocalActivityMarker(activityID string, result []
 }
 
 func (h *decisionsHelper) recordMutableSideEffectMarker(mutableSideEffectID string, data []byte) decisionStateMachine {
-	markerID := fmt.Sprintf("%v_%v", mutableSideEffectMarkerName, mutableSideEffectID)
+	markerID := mutableSideEffectMarkerName + "_" + mutableSideEffectID
 	attributes := &s.RecordMarkerDecisionAttributes{
 		MarkerName: common.StringPtr(mutableSideEffectMarkerName),
 		Details:    data,
@@ -823,6 +829,16 @@ func (h *decisionsHelper) recordMutableSideEffectMarker(mutableSideEffectID stri
 	return decision
 }
 
+func (h *decisionsHelper) recordSDKVersionMarker(details []byte) decisionStateMachine {
+	attributes := &s.RecordMarkerDecisionAttributes{
+		MarkerName: common.StringPtr(sdkVersionMarkerName),
+		Details:    details,
+	}
+	decision := h.newMarkerDecisionStateMachine(sdkVersionMarkerName, attributes)
+	h.addDecision(decision)
+	return decision
+}
+
 func (h *decisionsHelper) startChildWorkflowExecution(attributes *s.StartChildWorkflowExecutionDecisionAttributes) decisionStateMachine {
 	decision := h.newChildWorkflowDecisionStateMachine(attributes)
 	h.addDecision(decision)
@@ -1037,6 +1053,10 @@ func (h *decisionsHelper) getDecisions(markAsSent bool) []*s.Decision {
 		d := curr.Value.(decisionStateMachine)
 		decision := d.getDecision()
 		if decision != nil {
+			if result == nil {
+				// the remaining state machines are an upper bound of the decisions
+				result = make([]*s.Decision, 0, h.orderedDecisions.Len())
+			}
 			result = append(result, decision)
 		}
 
@@ -21,6 +21,7 @@
 package internal
 
 import (
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -638,3 +639,21 @@ func runAndCatchPanic(f func()) (err *workflowPanicError) {
 	f()
 	return nil
 }
+
+func BenchmarkDecisionsHelper(b *testing.B) {
+	details := make([]byte, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h := newDecisionsHelper()
+		for j := 0; j < 100; j++ {
+			h.recordSideEffectMarker(int32(j), details)
+			h.scheduleActivityTask(&s.ScheduleActivityTaskDecisionAttributes{
+				ActivityId: common.StringPtr(strconv.Itoa(j)),
+			})
+		}
+		h.recordVersionMarker("change-id", Version(1), DefaultDataConverter)
+		if decisions := h.getDecisions(true); len(decisions) != 201 {
+			b.Fatalf("expected 201 decisions, got %d", len(decisions))
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+const (
+	// decisionTaskWatchdogLabel is the pprof label set with the run ID on the goroutines of the coroutines of a
+	// workflow when the decision task watchdog is enabled, to find them in the goroutine profile.
+	decisionTaskWatchdogLabel = "cadence-workflow-run"
+
+	defaultDecisionTaskWatchdogThresholdPercent = 90
+	defaultDecisionTaskWatchdogMaxDumpSize      = 64 * 1024
+)
+
+// DecisionTaskWatchdogOptions configures the dump of the goroutine stacks of decision tasks close to their timeout,
+// see WorkerOptions.DecisionTaskWatchdog.
+type DecisionTaskWatchdogOptions struct {
+	// Optional: Percentage of the decision timeout after which the stacks are dumped if the decision task is still
+	// being processed, between 1 and 99.
+	// default: 90
+	ThresholdPercent int
+
+	// Optional: Also dump the stacks of all the goroutines of the process, e.g. to find who holds a lock the workflow
+	// code waits for.
+	// default: false
+	IncludeHostGoroutines bool
+
+	// Optional: Size limit of each logged dump, larger dumps are truncated.
+	// default: 64KB
+	MaxDumpSize int
+}
+
+func validateDecisionTaskWatchdogOptions(options *DecisionTaskWatchdogOptions) error {
+	if options == nil {
+		return nil
+	}
+	if options.ThresholdPercent < 0 || options.ThresholdPercent >= 100 {
+		return errors.New("DecisionTaskWatchdog.ThresholdPercent must be between 1 and 99")
+	}
+	if options.MaxDumpSize < 0 {
+		return errors.New("DecisionTaskWatchdog.MaxDumpSize must not be negative")
+	}
+	return nil
+}
+
+// startDecisionTaskWatchdog starts a timer which logs the stacks of the goroutine processing the decision task and of
+// the coroutines of the workflow once ThresholdPercent of the decision timeout has elapsed. The returned timer is nil
+// if the watchdog is disabled or the task has no decision timeout; otherwise the caller must stop it once the task is
+// processed. It must be called on the goroutine processing the decision task.
+func (wth *workflowTaskHandlerImpl) startDecisionTaskWatchdog(
+	task *s.PollForDecisionTaskResponse,
+	startTime time.Time,
+	decisionTimeout time.Duration,
+) *time.Timer {
+	options := wth.decisionTaskWatchdog
+	if options == nil || task.Query != nil || decisionTimeout <= 0 {
+		return nil
+	}
+	thresholdPercent := options.ThresholdPercent
+	if thresholdPercent == 0 {
+		thresholdPercent = defaultDecisionTaskWatchdogThresholdPercent
+	}
+	maxDumpSize := options.MaxDumpSize
+	if maxDumpSize == 0 {
+		maxDumpSize = defaultDecisionTaskWatchdogMaxDumpSize
+	}
+	eventLoopGoroutineID := currentGoroutineID()
+	dumpAfter := decisionTimeout * time.Duration(thresholdPercent) / 100
+	return time.AfterFunc(startTime.Add(dumpAfter).Sub(time.Now()), func() {
+		elapsed := time.Since(startTime)
+		runID := task.WorkflowExecution.GetRunId()
+		_, _, eventLoopStack := goroutineStack(eventLoopGoroutineID)
+		fields := []zap.Field{
+			zap.String(tagWorkflowType, task.WorkflowType.GetName()),
+			zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
+			zap.String(tagRunID, runID),
+			zap.Duration("Elapsed", elapsed),
+			zap.Duration("Remaining", decisionTimeout-elapsed),
+			zap.String("EventLoopStack", truncateDump(eventLoopStack, maxDumpSize)),
+			zap.String("WorkflowCoroutineStacks", truncateDump(workflowCoroutineStacks(runID), maxDumpSize)),
+		}
+		if options.IncludeHostGoroutines {
+			fields = append(fields, zap.String("HostGoroutineStacks", truncateDump(allGoroutineStacks(), maxDumpSize)))
+		}
+		wth.logger.Warn("Decision task is about to time out, dumping its goroutine stacks.", fields...)
+	})
+}
+
+// workflowCoroutineStacks returns the records of the goroutine profile of the goroutines labelled with the run ID,
+// i.e. the coroutines of the workflow and the native goroutines its code started.
+func workflowCoroutineStacks(runID string) string {
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		return ""
+	}
+	label := fmt.Sprintf("%q:%q", decisionTaskWatchdogLabel, runID)
+	var records []string
+	for _, record := range strings.Split(profile.String(), "\n\n") {
+		if strings.Contains(record, label) {
+			records = append(records, record)
+		}
+	}
+	return strings.Join(records, "\n\n")
+}
+
+// allGoroutineStacks returns the stack traces of all the goroutines of the process.
+func allGoroutineStacks() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+func truncateDump(dump string, maxSize int) string {
+	if len(dump) <= maxSize {
+		return dump
+	}
+	return fmt.Sprintf("%s...<truncated %d bytes>", dump[:maxSize], len(dump)-maxSize)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func blockedWatchdogWorkflow(ctx Context) {
+	NewChannel(ctx).Receive(ctx, nil)
+}
+
+func TestDecisionTaskWatchdog(t *testing.T) {
+	d, _ := newDispatcher(createRootTestContext(t), blockedWatchdogWorkflow)
+	d.watchdogRunID = "rid"
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	defer d.Close()
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	wth := &workflowTaskHandlerImpl{
+		logger:               zap.New(core),
+		decisionTaskWatchdog: &DecisionTaskWatchdogOptions{ThresholdPercent: 50, IncludeHostGoroutines: true},
+	}
+	task := &s.PollForDecisionTaskResponse{
+		WorkflowType:      &s.WorkflowType{Name: common.StringPtr("test-workflow")},
+		WorkflowExecution: &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+	}
+	timer := wth.startDecisionTaskWatchdog(task, time.Now(), 100*time.Millisecond)
+	require.NotNil(t, timer)
+	defer timer.Stop()
+
+	require.Eventually(t, func() bool { return logs.Len() == 1 }, time.Second, 10*time.Millisecond)
+	entry := logs.All()[0]
+	assert.Equal(t, "Decision task is about to time out, dumping its goroutine stacks.", entry.Message)
+	fields := entry.ContextMap()
+	assert.Equal(t, "test-workflow", fields[tagWorkflowType])
+	assert.Equal(t, "wid", fields[tagWorkflowID])
+	assert.Equal(t, "rid", fields[tagRunID])
+	assert.Contains(t, fields["EventLoopStack"], "TestDecisionTaskWatchdog")
+	assert.Contains(t, fields["WorkflowCoroutineStacks"], "blockedWatchdogWorkflow")
+	assert.Contains(t, fields["WorkflowCoroutineStacks"], `"cadence-workflow-run":"rid"`)
+	assert.Contains(t, fields["HostGoroutineStacks"], "goroutine ")
+
+	assert.Empty(t, workflowCoroutineStacks("other-rid"))
+}
+
+func TestDecisionTaskWatchdog_Disabled(t *testing.T) {
+	wth := &workflowTaskHandlerImpl{}
+	task := &s.PollForDecisionTaskResponse{}
+	assert.Nil(t, wth.startDecisionTaskWatchdog(task, time.Now(), time.Second))
+
+	wth.decisionTaskWatchdog = &DecisionTaskWatchdogOptions{}
+	assert.Nil(t, wth.startDecisionTaskWatchdog(task, time.Now(), 0))
+
+	task.Query = &s.WorkflowQuery{}
+	assert.Nil(t, wth.startDecisionTaskWatchdog(task, time.Now(), time.Second))
+}
+
+func TestValidateDecisionTaskWatchdogOptions(t *testing.T) {
+	assert.NoError(t, validateDecisionTaskWatchdogOptions(nil))
+	assert.NoError(t, validateDecisionTaskWatchdogOptions(&DecisionTaskWatchdogOptions{}))
+	assert.NoError(t, validateDecisionTaskWatchdogOptions(&DecisionTaskWatchdogOptions{ThresholdPercent: 80}))
+	assert.Error(t, validateDecisionTaskWatchdogOptions(&DecisionTaskWatchdogOptions{ThresholdPercent: 100}))
+	assert.Error(t, validateDecisionTaskWatchdogOptions(&DecisionTaskWatchdogOptions{ThresholdPercent: -1}))
+	assert.Error(t, validateDecisionTaskWatchdogOptions(&DecisionTaskWatchdogOptions{MaxDumpSize: -1}))
+}
+
+func TestTruncateDump(t *testing.T) {
+	assert.Equal(t, "stack", truncateDump("stack", 5))
+	assert.Equal(t, "st...<truncated 3 bytes>", truncateDump("stack", 2))
+}
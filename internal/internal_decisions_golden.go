@@ -0,0 +1,161 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+// UpdateGoldenEnv is the environment variable which, set to true, makes TestWorkflowEnvironment.AssertDecisionsGolden
+// write the golden files instead of comparing them.
+const UpdateGoldenEnv = "CADENCE_UPDATE_GOLDEN"
+
+// AssertDecisionsGolden compares the decisions produced by the test workflow, see GetProducedDecisions, with the
+// golden file at path, and fails the test when they differ, e.g. because a code change schedules activities in a
+// different order or with different arguments, which would break the replay of running workflows.
+// Unlike replaying the histories of a server, it also covers scenarios which did not happen in production yet.
+//
+// The golden file is an indented JSON document which is meant to be committed and reviewed with the code. It is written
+// when it does not exist, failing the test so that a missing file does not go unnoticed, and it is rewritten when the
+// CADENCE_UPDATE_GOLDEN environment variable is true, after an expected change of the decisions:
+//
+//	CADENCE_UPDATE_GOLDEN=true go test ./...
+//
+// The scenario must be deterministic, e.g. activities mocked with fixed results and signals sent at fixed times.
+func (t *TestWorkflowEnvironment) AssertDecisionsGolden(testingT mock.TestingT, path string) bool {
+	actual, err := formatGoldenDecisions(t.impl.getProducedDecisions())
+	if err != nil {
+		testingT.Errorf("unable to format the decisions: %v", err)
+		return false
+	}
+
+	update, _ := strconv.ParseBool(os.Getenv(UpdateGoldenEnv))
+	expected, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			testingT.Errorf("unable to create the directory of golden file %v: %v", path, err)
+			return false
+		}
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			testingT.Errorf("unable to write golden file %v: %v", path, err)
+			return false
+		}
+		if !update {
+			// a missing golden file must not go unnoticed, e.g. when it is not committed
+			testingT.Errorf("golden file %v did not exist and was created, run the test again", path)
+			return false
+		}
+		testingT.Logf("golden file %v updated", path)
+		return true
+	}
+	if err != nil {
+		testingT.Errorf("unable to read golden file %v: %v", path, err)
+		return false
+	}
+	return assert.Equal(testingT, string(expected), string(actual),
+		"decisions differ from golden file %v, run the test with %v=true to update it if the change is expected", path, UpdateGoldenEnv)
+}
+
+// formatGoldenDecisions returns the decisions as indented JSON, with the payloads as text when they are valid UTF-8,
+// e.g. encoded by the default data converter, and base64 otherwise.
+func formatGoldenDecisions(decisions [][]*shared.Decision) ([]byte, error) {
+	tasks := make([]jsonNode, 0, len(decisions))
+	for _, task := range decisions {
+		nodes := make([]jsonNode, 0, len(task))
+		for _, decision := range task {
+			nodes = append(nodes, goldenNode(reflect.ValueOf(decision)))
+		}
+		tasks = append(tasks, nodes)
+	}
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func goldenNode(v reflect.Value) jsonNode {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if stringer, ok := v.Interface().(fmt.Stringer); ok && v.Kind() == reflect.Int32 {
+		// thrift enums
+		return stringer.String()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		object := jsonObject{}
+		for _, field := range jsonFields(v.Type()) {
+			value, err := v.FieldByIndexErr(field.index)
+			if err != nil || isNilValue(value) {
+				continue
+			}
+			object = append(object, jsonMember{key: field.name, value: goldenNode(value)})
+		}
+		return object
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := v.Bytes()
+			if utf8.Valid(data) {
+				return string(data)
+			}
+			return "base64:" + base64.StdEncoding.EncodeToString(data)
+		}
+		array := make([]jsonNode, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			array = append(array, goldenNode(v.Index(i)))
+		}
+		return array
+	case reflect.Map:
+		object := jsonObject{}
+		for _, key := range v.MapKeys() {
+			object = append(object, jsonMember{key: fmt.Sprint(key.Interface()), value: goldenNode(v.MapIndex(key))})
+		}
+		sort.Slice(object, func(i, j int) bool { return object[i].key < object[j].key })
+		return object
+	}
+	return v.Interface()
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingTestingT struct {
+	errors []string
+	logs   []string
+}
+
+func (r *recordingTestingT) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTestingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTestingT) FailNow() {}
+
+func TestAssertDecisionsGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "order.golden")
+	run := func(amount int) *TestWorkflowEnvironment {
+		workflowFn := func(ctx Context) error {
+			ctx = WithActivityOptions(ctx, ActivityOptions{
+				ScheduleToStartTimeout: time.Minute,
+				StartToCloseTimeout:    time.Minute,
+			})
+			if err := ExecuteActivity(ctx, testActivityHello, fmt.Sprint(amount)).Get(ctx, nil); err != nil {
+				return err
+			}
+			return Sleep(ctx, time.Hour)
+		}
+		var s WorkflowTestSuite
+		env := s.NewTestWorkflowEnvironment()
+		env.RegisterWorkflow(workflowFn)
+		env.RegisterActivity(testActivityHello)
+		env.ExecuteWorkflow(workflowFn)
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		return env
+	}
+
+	recorder := &recordingTestingT{}
+	assert.False(t, run(10).AssertDecisionsGolden(recorder, path))
+	require.Len(t, recorder.errors, 1)
+	assert.Contains(t, recorder.errors[0], "did not exist and was created")
+	golden, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(golden), `"decisionType": "ScheduleActivityTask"`)
+	assert.Contains(t, string(golden), `"input": "\"10\"\n"`)
+	assert.Contains(t, string(golden), `"startToFireTimeoutSeconds": 3600`)
+
+	recorder = &recordingTestingT{}
+	assert.True(t, run(10).AssertDecisionsGolden(recorder, path))
+	assert.Empty(t, recorder.errors)
+
+	recorder = &recordingTestingT{}
+	assert.False(t, run(20).AssertDecisionsGolden(recorder, path))
+	require.Len(t, recorder.errors, 1)
+	assert.Contains(t, recorder.errors[0], "decisions differ from golden file")
+	assert.Contains(t, recorder.errors[0], `+        "input": "\"20\"\n"`)
+
+	t.Setenv(UpdateGoldenEnv, "true")
+	recorder = &recordingTestingT{}
+	assert.True(t, run(20).AssertDecisionsGolden(recorder, path))
+	assert.Empty(t, recorder.errors)
+	golden, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(golden), `"input": "\"20\"\n"`)
+}
@@ -0,0 +1,258 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// goroutine label set on the coroutines of a guarded dispatcher. Goroutines inherit the labels of the goroutine
+	// which started them, which is how native goroutines started by workflow code are found.
+	determinismGuardLabel = "cadence-determinism-guard"
+
+	// how often the goroutine of a coroutine which did not yield is sampled for blocking calls. Each sample takes the
+	// stacks of all the goroutines of the process, so it is kept well above the time workflow code usually runs.
+	determinismGuardSampleInterval = 100 * time.Millisecond
+
+	cadencePackagePrefix = "go.uber.org/cadence/"
+)
+
+// packages of loggers, which may block in I/O when called by workflow code through the workflow logger
+var loggerPackages = []string{"log", "log/slog", "go.uber.org/zap", "go.uber.org/zap/zapcore"}
+
+var determinismGuardSequence atomic.Int64
+
+type (
+	// determinismGuard watches the goroutines of a dispatcher for workflow code which is not deterministic, i.e.
+	// starts native goroutines or blocks the coroutine outside of the dispatcher, e.g. in time.Sleep. Both are found
+	// from the goroutines at run time, so violations are only caught while they happen: a native goroutine which
+	// exited before the coroutines yielded, or a blocking call shorter than determinismGuardSampleInterval may be
+	// missed. Calls which return immediately, like time.Now or math/rand, cannot be observed at all, and goroutines
+	// blocked in a logger, e.g. the workflow logger writing to a slow output, are not reported.
+	determinismGuard struct {
+		label     string
+		violation *workflowPanicError // the first violation, reported by the dispatcher once the coroutine yields
+	}
+
+	goroutineFrame struct {
+		function string
+		file     string
+	}
+)
+
+func newDeterminismGuard() *determinismGuard {
+	return &determinismGuard{label: strconv.FormatInt(determinismGuardSequence.Add(1), 10)}
+}
+
+// watch is called on the goroutine of a coroutine before the workflow code of the coroutine runs. It returns the pprof
+// labels to set on the goroutine with the label of the guard added.
+func (g *determinismGuard) watch(s *coroutineState, labels context.Context) context.Context {
+	s.goroutineID.Store(currentGoroutineID())
+	return pprof.WithLabels(labels, pprof.Labels(determinismGuardLabel, g.label))
+}
+
+// waitForYield waits for the coroutine to yield, sampling its goroutine while it does not.
+func (g *determinismGuard) waitForYield(s *coroutineState) {
+	timer := time.NewTimer(determinismGuardSampleInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.aboutToBlock:
+			return
+		case <-timer.C:
+			if g.violation == nil {
+				g.violation = blockingCallViolation(s)
+			}
+			timer.Reset(determinismGuardSampleInterval)
+		}
+	}
+}
+
+// check returns the first violation found since the last check.
+func (g *determinismGuard) check() *workflowPanicError {
+	if g.violation == nil {
+		g.violation = g.nativeGoroutineViolation()
+	}
+	violation := g.violation
+	g.violation = nil
+	return violation
+}
+
+// nativeGoroutineViolation looks for goroutines carrying the label of the dispatcher which were not started by the
+// framework, i.e. were started by workflow code with the go statement.
+func (g *determinismGuard) nativeGoroutineViolation() *workflowPanicError {
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		return nil
+	}
+	labels := fmt.Sprintf("%q:%q", determinismGuardLabel, g.label)
+	for _, record := range strings.Split(profile.String(), "\n\n") {
+		var labelled bool
+		var frames []goroutineFrame
+		for _, line := range strings.Split(record, "\n") {
+			if strings.HasPrefix(line, "# labels: ") {
+				labelled = strings.Contains(line, labels)
+			} else if fields := strings.Fields(line); len(fields) == 4 && fields[0] == "#" {
+				function := fields[2][:strings.LastIndex(fields[2], "+")]
+				file := fields[3][:strings.LastIndex(fields[3], ":")]
+				frames = append(frames, goroutineFrame{function: function, file: file})
+			}
+		}
+		if !labelled || len(frames) == 0 || frames[len(frames)-1].isFramework() {
+			continue
+		}
+		return newWorkflowPanicError(
+			fmt.Sprintf("determinism guard: workflow code started a native goroutine running %v, use workflow.Go instead",
+				frames[len(frames)-1].function),
+			record,
+		)
+	}
+	return nil
+}
+
+// blockingCallViolation returns a violation if the goroutine of the coroutine is blocked by workflow code outside of
+// the dispatcher, e.g. in time.Sleep, on a native channel or on a sync.Mutex.
+func blockingCallViolation(s *coroutineState) *workflowPanicError {
+	state, frames, stack := goroutineStack(s.goroutineID.Load())
+	if !isBlockingGoroutineState(state) {
+		return nil
+	}
+	for i, frame := range frames {
+		if frame.isLogger() {
+			// a logger writing its output, not the workflow code
+			return nil
+		}
+		if frame.isStandardLibrary() {
+			continue
+		}
+		if frame.isFramework() {
+			// blocked by the framework, e.g. while yielding
+			return nil
+		}
+		if i > 0 {
+			frame = frames[i-1]
+		}
+		return newWorkflowPanicError(
+			fmt.Sprintf("determinism guard: workflow code in coroutine %v is blocked [%v] in %v outside of the "+
+				"workflow dispatcher, use workflow.Sleep, workflow.Channel or workflow.Selector instead",
+				s.name, state, frame.function),
+			stack,
+		)
+	}
+	return nil
+}
+
+func isBlockingGoroutineState(state string) bool {
+	for _, prefix := range []string{"sleep", "chan ", "select", "sync.", "semacquire", "IO wait"} {
+		if strings.HasPrefix(state, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// goroutineStack returns the state, frames and stack trace of the goroutine with the ID.
+func goroutineStack(goroutineID int64) (string, []goroutineFrame, string) {
+	prefix := fmt.Sprintf("goroutine %d [", goroutineID)
+	for _, stack := range strings.Split(allGoroutineStacks(), "\n\n") {
+		if !strings.HasPrefix(stack, prefix) {
+			continue
+		}
+		scanner := bufio.NewScanner(strings.NewReader(stack))
+		scanner.Scan()
+		header := scanner.Text()
+		state := header[len(prefix):strings.Index(header, "]")]
+		if i := strings.Index(state, ","); i >= 0 {
+			state = state[:i] // drop the wait time, e.g. "chan receive, 2 minutes"
+		}
+		var frames []goroutineFrame
+		for scanner.Scan() {
+			function := scanner.Text()
+			if strings.HasPrefix(function, "created by ") || !scanner.Scan() {
+				break
+			}
+			if i := strings.LastIndex(function, "("); i > 0 && strings.HasSuffix(function, ")") {
+				function = function[:i]
+			}
+			file := strings.TrimSpace(scanner.Text())
+			if i := strings.LastIndex(file, ":"); i > 0 {
+				file = file[:i]
+			}
+			frames = append(frames, goroutineFrame{function: function, file: file})
+		}
+		return state, frames, stack
+	}
+	return "", nil, ""
+}
+
+func currentGoroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	fields := strings.Fields(string(buf))
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(fields[1], 10, 64)
+	return id
+}
+
+// clearDeterminismGuardLabels is called by goroutines the framework starts from workflow code to run user code which
+// is allowed to start goroutines, e.g. activities in the test environment.
+func clearDeterminismGuardLabels() {
+	pprof.SetGoroutineLabels(context.Background())
+}
+
+func (f goroutineFrame) isFramework() bool {
+	return strings.HasPrefix(f.function, cadencePackagePrefix) && !strings.HasSuffix(f.file, "_test.go")
+}
+
+func (f goroutineFrame) isStandardLibrary() bool {
+	pkg := f.pkg()
+	return pkg != "main" && !strings.Contains(strings.SplitN(pkg, "/", 2)[0], ".")
+}
+
+func (f goroutineFrame) isLogger() bool {
+	pkg := f.pkg()
+	for _, loggerPkg := range loggerPackages {
+		if pkg == loggerPkg {
+			return true
+		}
+	}
+	return false
+}
+
+// pkg returns the import path of the package of the function, e.g. "go.uber.org/zap" for "go.uber.org/zap.(*Logger).Info"
+func (f goroutineFrame) pkg() string {
+	slash := strings.LastIndex(f.function, "/") + 1
+	if i := strings.Index(f.function[slash:], "."); i >= 0 {
+		return f.function[:slash+i]
+	}
+	return f.function
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeterminismGuard(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	// a logger whose output blocks until it is read
+	logReader, logWriter := io.Pipe()
+	defer logWriter.Close()
+	logger := log.New(logWriter, "", 0)
+
+	for _, tc := range []struct {
+		name          string
+		workflowFn    func(ctx Context) error
+		before        func()
+		disabled      bool
+		expectedError string
+	}{
+		{
+			name: "native goroutine",
+			workflowFn: func(ctx Context) error {
+				go func() { <-done }()
+				return nil
+			},
+			expectedError: "workflow code started a native goroutine running go.uber.org/cadence/internal.TestDeterminismGuard",
+		},
+		{
+			name: "time.Sleep",
+			workflowFn: func(ctx Context) error {
+				time.Sleep(300 * time.Millisecond)
+				return nil
+			},
+			expectedError: "is blocked [sleep] in time.Sleep outside of the workflow dispatcher",
+		},
+		{
+			name: "native channel",
+			workflowFn: func(ctx Context) error {
+				<-time.After(300 * time.Millisecond)
+				return nil
+			},
+			expectedError: "is blocked [chan receive] in go.uber.org/cadence/internal.TestDeterminismGuard",
+		},
+		{
+			name: "time.Sleep in coroutine",
+			workflowFn: func(ctx Context) error {
+				Go(ctx, func(ctx Context) {
+					time.Sleep(300 * time.Millisecond)
+				})
+				return Sleep(ctx, time.Minute)
+			},
+			expectedError: "workflow code in coroutine 2 is blocked [sleep] in time.Sleep",
+		},
+		{
+			name: "blocked in a logger",
+			workflowFn: func(ctx Context) error {
+				logger.Print("blocked until read")
+				return nil
+			},
+			before: func() {
+				go func() {
+					time.Sleep(300 * time.Millisecond)
+					_, _ = io.Copy(io.Discard, logReader)
+				}()
+			},
+		},
+		{
+			name: "guard disabled",
+			workflowFn: func(ctx Context) error {
+				time.Sleep(20 * time.Millisecond)
+				go func() { <-done }()
+				return nil
+			},
+			disabled: true,
+		},
+		{
+			name: "workflow APIs and activities starting goroutines",
+			workflowFn: func(ctx Context) error {
+				ctx = WithActivityOptions(ctx, ActivityOptions{ScheduleToStartTimeout: time.Minute, StartToCloseTimeout: time.Minute})
+				ch := NewChannel(ctx)
+				Go(ctx, func(ctx Context) {
+					_ = Sleep(ctx, time.Minute)
+					ch.Send(ctx, true)
+				})
+				ch.Receive(ctx, nil)
+				return ExecuteActivity(ctx, "startGoroutine").Get(ctx, nil)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var s WorkflowTestSuite
+			env := s.NewTestWorkflowEnvironment()
+			env.SetWorkerOptions(WorkerOptions{EnableDeterminismGuard: !tc.disabled})
+			env.RegisterWorkflowWithOptions(tc.workflowFn, RegisterWorkflowOptions{Name: "guarded"})
+			env.RegisterActivityWithOptions(func(ctx context.Context) error {
+				go func() { <-done }()
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			}, RegisterActivityOptions{Name: "startGoroutine"})
+			if tc.before != nil {
+				tc.before()
+			}
+			env.ExecuteWorkflow("guarded")
+
+			require.True(t, env.IsWorkflowCompleted())
+			if tc.expectedError == "" {
+				assert.NoError(t, env.GetWorkflowError())
+				return
+			}
+			var panicErr *PanicError
+			require.ErrorAs(t, env.GetWorkflowError(), &panicErr)
+			assert.Contains(t, panicErr.Error(), "determinism guard: ")
+			assert.Contains(t, panicErr.Error(), tc.expectedError)
+			assert.NotEmpty(t, panicErr.StackTrace())
+		})
+	}
+}
+
+func TestGoroutineFrame(t *testing.T) {
+	for function, expected := range map[string]bool{
+		"time.Sleep":                       true,
+		"internal/sync.(*Mutex).lockSlow":  true,
+		"main.main.func1":                  false,
+		"github.com/foo/bar.(*Baz).Run":    false,
+		"go.uber.org/cadence/internal.Now": false,
+	} {
+		assert.Equal(t, expected, goroutineFrame{function: function}.isStandardLibrary(), function)
+	}
+	for function, expected := range map[string]bool{
+		"log.(*Logger).output":                        true,
+		"log/slog.(*Logger).log":                      true,
+		"go.uber.org/zap.(*Logger).Info":              true,
+		"go.uber.org/zap/zapcore.(*ioCore).Write":     true,
+		"go.uber.org/zap/zaptest.TestingWriter.Write": false,
+		"go.uber.org/cadence/internal.(*Logger).Info": false,
+		"os.(*File).Write":                            false,
+	} {
+		assert.Equal(t, expected, goroutineFrame{function: function}.isLogger(), function)
+	}
+	assert.True(t, goroutineFrame{function: "go.uber.org/cadence/internal.Now", file: "/src/internal/workflow.go"}.isFramework())
+	assert.False(t, goroutineFrame{function: "go.uber.org/cadence/internal.TestX", file: "/src/internal/x_test.go"}.isFramework())
+	assert.False(t, goroutineFrame{function: "github.com/foo/bar.Run", file: "/src/bar.go"}.isFramework())
+}
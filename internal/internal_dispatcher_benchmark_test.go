@@ -0,0 +1,209 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// dispatcherBenchmark measures a primitive of the deterministic dispatcher which runs workflow code.
+type dispatcherBenchmark struct {
+	name string
+	// run runs the primitive n times in workflow coroutines, without a workflow environment processing decisions.
+	run func(n int) error
+}
+
+var dispatcherBenchmarks = []dispatcherBenchmark{
+	{name: "CoroutineSwitch", run: benchmarkCoroutineSwitch},
+	{name: "CoroutineSpawn", run: benchmarkCoroutineSpawn},
+	{name: "ChannelSendReceive", run: benchmarkChannelSendReceive},
+	{name: "ChannelBlockingReceive", run: benchmarkChannelBlockingReceive},
+	{name: "Selector", run: benchmarkSelector},
+	{name: "FutureResolution", run: benchmarkFutureResolution},
+	{name: "FutureBlockingGet", run: benchmarkFutureBlockingGet},
+}
+
+func BenchmarkDispatcher(b *testing.B) {
+	for _, benchmark := range dispatcherBenchmarks {
+		run := benchmark.run
+		b.Run(benchmark.name, func(b *testing.B) {
+			b.ReportAllocs()
+			if err := run(b.N); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// runDispatcherBenchmark runs root in a new dispatcher of a workflow environment processing no history until it
+// returns, which must happen without blocking on anything else than the coroutines it starts.
+func runDispatcherBenchmark(n int, root func(ctx Context, n int)) error {
+	env := newWorkflowExecutionEventHandler(
+		&WorkflowInfo{WorkflowType: WorkflowType{Name: "benchmark"}},
+		func(result []byte, err error) {},
+		zap.NewNop(),
+		false,
+		0,
+		false,
+		false,
+		false,
+		true,
+		false,
+		tally.NoopScope,
+		newRegistry(),
+		DefaultDataConverter,
+		nil,
+		opentracing.NoopTracer{},
+		nil,
+		FeatureFlags{},
+	).(*workflowExecutionEventHandlerImpl).workflowEnvironmentImpl
+	interceptors, envInterceptor := newWorkflowInterceptors(env, nil)
+	ctx := newWorkflowContext(env, interceptors, envInterceptor)
+	d, _ := newDispatcher(ctx, func(ctx Context) { root(ctx, n) })
+	defer d.Close()
+
+	if err := d.ExecuteUntilAllBlocked(); err != nil {
+		return err
+	}
+	if !d.IsDone() {
+		return errors.New("the benchmarked workflow code is blocked")
+	}
+	return nil
+}
+
+// benchmarkCoroutineSwitch measures a round trip between two coroutines, i.e. two switches through the dispatcher.
+func benchmarkCoroutineSwitch(n int) error {
+	return runDispatcherBenchmark(n, func(ctx Context, n int) {
+		ping, pong := NewChannel(ctx), NewChannel(ctx)
+		Go(ctx, func(ctx Context) {
+			for i := 0; i < n; i++ {
+				ping.Receive(ctx, nil)
+				pong.Send(ctx, i)
+			}
+		})
+		for i := 0; i < n; i++ {
+			ping.Send(ctx, i)
+			pong.Receive(ctx, nil)
+		}
+	})
+}
+
+// benchmarkCoroutineSpawn measures starting a coroutine and waiting for it to complete.
+func benchmarkCoroutineSpawn(n int) error {
+	return runDispatcherBenchmark(n, func(ctx Context, n int) {
+		wg := NewWaitGroup(ctx)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			Go(ctx, func(ctx Context) {
+				wg.Done()
+			})
+			wg.Wait(ctx)
+		}
+	})
+}
+
+// benchmarkChannelSendReceive measures a send and a receive on a buffered channel, which do not block.
+func benchmarkChannelSendReceive(n int) error {
+	return runDispatcherBenchmark(n, func(ctx Context, n int) {
+		c := NewBufferedChannel(ctx, 1)
+		var v int
+		for i := 0; i < n; i++ {
+			c.Send(ctx, i)
+			c.Receive(ctx, &v)
+		}
+	})
+}
+
+// benchmarkChannelBlockingReceive measures a receive blocking until another coroutine sends, i.e. one switch.
+func benchmarkChannelBlockingReceive(n int) error {
+	return runDispatcherBenchmark(n, func(ctx Context, n int) {
+		c := NewChannel(ctx)
+		Go(ctx, func(ctx Context) {
+			for i := 0; i < n; i++ {
+				c.Send(ctx, i)
+			}
+		})
+		var v int
+		for i := 0; i < n; i++ {
+			c.Receive(ctx, &v)
+		}
+	})
+}
+
+// benchmarkSelector measures a new selector with two receive cases of which one is ready.
+func benchmarkSelector(n int) error {
+	return runDispatcherBenchmark(n, func(ctx Context, n int) {
+		c1, c2 := NewBufferedChannel(ctx, 1), NewBufferedChannel(ctx, 1)
+		var v int
+		for i := 0; i < n; i++ {
+			c := c1
+			if i%2 == 1 {
+				c = c2
+			}
+			c.SendAsync(i)
+			NewSelector(ctx).
+				AddReceive(c1, func(c Channel, more bool) { c.Receive(ctx, &v) }).
+				AddReceive(c2, func(c Channel, more bool) { c.Receive(ctx, &v) }).
+				Select(ctx)
+		}
+	})
+}
+
+// benchmarkFutureResolution measures setting a future and getting its value, which does not block.
+func benchmarkFutureResolution(n int) error {
+	return runDispatcherBenchmark(n, func(ctx Context, n int) {
+		var v int
+		for i := 0; i < n; i++ {
+			f, s := NewFuture(ctx)
+			s.SetValue(i)
+			if err := f.Get(ctx, &v); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+// benchmarkFutureBlockingGet measures getting the value of a future blocking until another coroutine sets it.
+func benchmarkFutureBlockingGet(n int) error {
+	return runDispatcherBenchmark(n, func(ctx Context, n int) {
+		settables := NewChannel(ctx)
+		Go(ctx, func(ctx Context) {
+			var s Settable
+			for i := 0; i < n; i++ {
+				settables.Receive(ctx, &s)
+				s.SetValue(i)
+			}
+		})
+		var v int
+		for i := 0; i < n; i++ {
+			f, s := NewFuture(ctx)
+			settables.Send(ctx, s)
+			if err := f.Get(ctx, &v); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
@@ -64,12 +64,16 @@ type (
 
 	scheduledTimer struct {
 		callback resultHandler
+		fireTime time.Time
 		handled  bool
 	}
 
 	scheduledActivity struct {
 		callback             resultHandler
 		waitForCancelRequest bool
+		scheduledTime        time.Time
+		started              bool
+		attempt              int32
 		handled              bool
 	}
 
@@ -77,6 +81,7 @@ type (
 		resultCallback      resultHandler
 		startedCallback     func(r WorkflowExecution, e error)
 		waitForCancellation bool
+		runID               string
 		handled             bool
 	}
 
@@ -94,13 +99,17 @@ type (
 	workflowEnvironmentImpl struct {
 		workflowInfo *WorkflowInfo
 
-		decisionsHelper   *decisionsHelper
-		sideEffectResult  map[int32][]byte
-		changeVersions    map[string]Version
-		pendingLaTasks    map[string]*localActivityTask
-		mutableSideEffect map[string][]byte
-		unstartedLaTasks  map[string]struct{}
-		openSessions      map[string]*SessionInfo
+		decisionsHelper  *decisionsHelper
+		sideEffectResult map[int32][]byte
+		changeVersions   map[string]Version
+		// change IDs of version markers recorded by the decision being replayed, which its workflow code has not
+		// asked for yet. ExecutionVersions hides them, as they were not decided yet when the code first ran.
+		unobservedVersions map[string]struct{}
+		pendingLaTasks     map[string]*localActivityTask
+		mutableSideEffect  map[string][]byte
+		unstartedLaTasks   map[string]struct{}
+		openSessions       map[string]*SessionInfo
+		recentLogs         *recentLogs // nil unless WorkerOptions.WorkflowLogBufferSize is set
 
 		counterID         int32     // To generate sequence IDs for activity/timer etc.
 		currentReplayTime time.Time // Indicates current replay time of the decision.
@@ -115,6 +124,18 @@ type (
 		isReplay              bool // flag to indicate if workflow is in replay mode
 		enableLoggingInReplay bool // flag to indicate if workflow should enable logging in replay mode
 
+		enableDeterminismGuard     bool // flag to indicate if the dispatcher of the workflow is guarded by a determinismGuard
+		enableDecisionTaskWatchdog bool // flag to indicate if the goroutines of the workflow are labelled for the watchdog
+		enableActivityCallerInfo   bool // flag to indicate if the workflow context is passed to the activities it schedules
+		expireLocalActivityRetries bool // flag to indicate if local activity retries end when their timer fires after the expiration
+		recordSDKVersionMarker     bool // flag to indicate if the SDK version marker is recorded when the workflow starts
+
+		// workerLogger and workerMetricsScope are not replay-aware: they report the SDK version of replayed histories
+		workerLogger       *zap.Logger
+		workerMetricsScope tally.Scope
+
+		latencyBreakdown workflowLatencyBreakdown // where the time of the execution was spent, read from the history
+
 		metricsScope                 tally.Scope
 		registry                     *registry
 		dataConverter                DataConverter
@@ -200,6 +221,12 @@ func newWorkflowExecutionEventHandler(
 	completeHandler completionHandler,
 	logger *zap.Logger,
 	enableLoggingInReplay bool,
+	recentLogsSize int,
+	enableDeterminismGuard bool,
+	enableDecisionTaskWatchdog bool,
+	enableActivityCallerInfo bool,
+	expireLocalActivityRetries bool,
+	recordSDKVersionMarker bool,
 	scope tally.Scope,
 	registry *registry,
 	dataConverter DataConverter,
@@ -214,11 +241,17 @@ func newWorkflowExecutionEventHandler(
 		sideEffectResult:             make(map[int32][]byte),
 		mutableSideEffect:            make(map[string][]byte),
 		changeVersions:               make(map[string]Version),
+		unobservedVersions:           make(map[string]struct{}),
 		pendingLaTasks:               make(map[string]*localActivityTask),
 		unstartedLaTasks:             make(map[string]struct{}),
 		openSessions:                 make(map[string]*SessionInfo),
 		completeHandler:              completeHandler,
 		enableLoggingInReplay:        enableLoggingInReplay,
+		enableDeterminismGuard:       enableDeterminismGuard,
+		enableDecisionTaskWatchdog:   enableDecisionTaskWatchdog,
+		enableActivityCallerInfo:     enableActivityCallerInfo,
+		expireLocalActivityRetries:   expireLocalActivityRetries,
+		recordSDKVersionMarker:       recordSDKVersionMarker,
 		registry:                     registry,
 		dataConverter:                dataConverter,
 		contextPropagators:           contextPropagators,
@@ -226,13 +259,24 @@ func newWorkflowExecutionEventHandler(
 		workflowInterceptorFactories: workflowInterceptorFactories,
 		featureFlags:                 featureFlags,
 	}
-	context.logger = logger.With(
+	wrapCore := wrapLogger(&context.isReplay, &context.enableLoggingInReplay)
+	if recentLogsSize > 0 {
+		// capture the entries which are actually logged, i.e. not the ones skipped during replay
+		context.recentLogs = newRecentLogs(recentLogsSize)
+		replayAwareWrapCore := wrapCore
+		wrapCore = func(c zapcore.Core) zapcore.Core {
+			return replayAwareWrapCore(context.recentLogs.wrapCore(c))
+		}
+	}
+	context.workerLogger = logger.With(
 		zapcore.Field{Key: tagWorkflowType, Type: zapcore.StringType, String: workflowInfo.WorkflowType.Name},
 		zapcore.Field{Key: tagWorkflowID, Type: zapcore.StringType, String: workflowInfo.WorkflowExecution.ID},
 		zapcore.Field{Key: tagRunID, Type: zapcore.StringType, String: workflowInfo.WorkflowExecution.RunID},
-	).WithOptions(zap.WrapCore(wrapLogger(&context.isReplay, &context.enableLoggingInReplay)))
+	)
+	context.logger = context.workerLogger.WithOptions(zap.WrapCore(wrapCore))
 
 	if scope != nil {
+		context.workerMetricsScope = tagScope(scope, tagWorkflowType, workflowInfo.WorkflowType.Name)
 		context.metricsScope = tagScope(metrics.WrapScope(&context.isReplay, scope, context),
 			tagWorkflowType, workflowInfo.WorkflowType.Name)
 	}
@@ -444,6 +488,31 @@ func (wc *workflowEnvironmentImpl) GetMetricsScope() tally.Scope {
 	return wc.metricsScope
 }
 
+// withLogger makes GetLogger return logger until the returned function is called.
+func (wc *workflowEnvironmentImpl) withLogger(logger *zap.Logger) (restore func()) {
+	previous := wc.logger
+	wc.logger = logger
+	return func() {
+		wc.logger = previous
+	}
+}
+
+// executeQueryHandler executes the query handler with the logger and the metrics scope tagged with the query type.
+// Queries are not replayed, so the logs and metrics of query handlers are emitted even when the query is answered
+// after replaying the history.
+func (wc *workflowEnvironmentImpl) executeQueryHandler(queryType string, queryArgs []byte) ([]byte, error) {
+	isReplay, scope := wc.isReplay, wc.metricsScope
+	defer wc.withLogger(wc.logger.With(zap.String(tagQueryType, queryType)))()
+	defer func() {
+		wc.isReplay, wc.metricsScope = isReplay, scope
+	}()
+	wc.isReplay = false
+	if scope != nil {
+		wc.metricsScope = tagScope(scope, tagQueryType, queryType)
+	}
+	return wc.queryHandler(queryType, queryArgs)
+}
+
 func (wc *workflowEnvironmentImpl) GetDataConverter() DataConverter {
 	return wc.dataConverter
 }
@@ -456,6 +525,22 @@ func (wc *workflowEnvironmentImpl) IsReplaying() bool {
 	return wc.isReplay
 }
 
+func (wc *workflowEnvironmentImpl) IsDeterminismGuardEnabled() bool {
+	return wc.enableDeterminismGuard
+}
+
+func (wc *workflowEnvironmentImpl) IsActivityCallerInfoEnabled() bool {
+	return wc.enableActivityCallerInfo
+}
+
+func (wc *workflowEnvironmentImpl) IsLocalActivityRetryExpirationEnabled() bool {
+	return wc.expireLocalActivityRetries
+}
+
+func (wc *workflowEnvironmentImpl) IsDecisionTaskWatchdogEnabled() bool {
+	return wc.enableDecisionTaskWatchdog
+}
+
 func (wc *workflowEnvironmentImpl) GenerateSequenceID() string {
 	return fmt.Sprintf("%d", wc.GenerateSequence())
 }
@@ -488,6 +573,7 @@ func (wc *workflowEnvironmentImpl) ExecuteActivity(parameters executeActivityPar
 	decision.setData(&scheduledActivity{
 		callback:             callback,
 		waitForCancelRequest: parameters.WaitForCancellation,
+		scheduledTime:        wc.currentReplayTime,
 	})
 
 	wc.logger.Debug("ExecuteActivity",
@@ -571,7 +657,7 @@ func (wc *workflowEnvironmentImpl) NewTimer(d time.Duration, callback resultHand
 	startTimerAttr.StartToFireTimeoutSeconds = common.Int64Ptr(durationInSeconds)
 
 	decision := wc.decisionsHelper.startTimer(startTimerAttr)
-	decision.setData(&scheduledTimer{callback: callback})
+	decision.setData(&scheduledTimer{callback: callback, fireTime: wc.currentReplayTime.Add(time.Duration(durationInSeconds) * time.Second)})
 
 	wc.logger.Debug("NewTimer",
 		zap.String(tagTimerID, startTimerAttr.GetTimerId()),
@@ -592,6 +678,28 @@ func (wc *workflowEnvironmentImpl) RequestCancelTimer(timerID string) {
 	wc.logger.Debug("RequestCancelTimer", zap.String(tagTimerID, timerID))
 }
 
+// DecidedVersions returns the versions of the changes which were decided, by GetVersion or by a version marker of the
+// history, without deciding the others.
+func (wc *workflowEnvironmentImpl) DecidedVersions(changeIDs []string) map[string]Version {
+	versions := make(map[string]Version, len(changeIDs))
+	for _, changeID := range changeIDs {
+		if version, ok := wc.changeVersions[changeID]; ok {
+			versions[changeID] = version
+		}
+	}
+	return versions
+}
+
+func (wc *workflowEnvironmentImpl) ExecutionVersions() map[string]Version {
+	versions := make(map[string]Version, len(wc.changeVersions))
+	for changeID, version := range wc.changeVersions {
+		if _, ok := wc.unobservedVersions[changeID]; !ok {
+			versions[changeID] = version
+		}
+	}
+	return versions
+}
+
 func validateVersion(changeID string, version, minSupported, maxSupported Version) {
 	if version < minSupported {
 		panic(fmt.Sprintf("Workflow code removed support of version %v. "+
@@ -611,6 +719,7 @@ func (wc *workflowEnvironmentImpl) GetVersion(changeID string, minSupported, max
 	// ensuring it is within the acceptable range
 	if version, ok := wc.changeVersions[changeID]; ok {
 		validateVersion(changeID, version, minSupported, maxSupported)
+		delete(wc.unobservedVersions, changeID)
 		return version
 	}
 
@@ -699,7 +808,7 @@ func (wc *workflowEnvironmentImpl) SideEffect(f func() ([]byte, error), callback
 		details = result
 	} else {
 		var err error
-		result, err = f()
+		result, err = wc.executeSideEffect(sideEffectID, f)
 		if err != nil {
 			callback(result, err)
 			return
@@ -717,6 +826,12 @@ func (wc *workflowEnvironmentImpl) SideEffect(f func() ([]byte, error), callback
 	wc.logger.Debug("SideEffect Marker added", zap.Int32(tagSideEffectID, sideEffectID))
 }
 
+// executeSideEffect executes f with the logger returned by GetLogger tagged with the side effect ID.
+func (wc *workflowEnvironmentImpl) executeSideEffect(sideEffectID int32, f func() ([]byte, error)) ([]byte, error) {
+	defer wc.withLogger(wc.logger.With(zap.Int32(tagSideEffectID, sideEffectID)))()
+	return f()
+}
+
 func (wc *workflowEnvironmentImpl) MutableSideEffect(id string, f func() interface{}, equals func(a, b interface{}) bool) Value {
 	if result, ok := wc.mutableSideEffect[id]; ok {
 		encodedResult := newEncodedValue(result, wc.GetDataConverter())
@@ -847,7 +962,6 @@ func (weh *workflowExecutionEventHandlerImpl) ProcessEvent(
 		m.EventTypeDecisionTaskScheduled,
 		m.EventTypeDecisionTaskTimedOut,
 		m.EventTypeDecisionTaskFailed,
-		m.EventTypeActivityTaskStarted,
 		m.EventTypeDecisionTaskCompleted,
 		m.EventTypeWorkflowExecutionCanceled,
 		m.EventTypeWorkflowExecutionContinuedAsNew:
@@ -858,11 +972,14 @@ func (weh *workflowExecutionEventHandlerImpl) ProcessEvent(
 		// Set replay clock.
 		weh.SetCurrentReplayTime(time.Unix(0, event.GetTimestamp()))
 		weh.workflowDefinition.OnDecisionTaskStarted()
+		weh.clearUnobservedVersions()
 		// Set replay decisionStarted eventID
 		weh.workflowInfo.DecisionStartedEventID = event.GetEventId()
 	case m.EventTypeActivityTaskScheduled:
 		weh.decisionsHelper.handleActivityTaskScheduled(
 			event.GetEventId(), event.ActivityTaskScheduledEventAttributes.GetActivityId())
+	case m.EventTypeActivityTaskStarted:
+		weh.handleActivityTaskStarted(event)
 	case m.EventTypeActivityTaskCompleted:
 		weh.handleActivityTaskCompleted(event)
 	case m.EventTypeActivityTaskFailed:
@@ -942,21 +1059,34 @@ func (weh *workflowExecutionEventHandlerImpl) ProcessEvent(
 	// Don't call for EventType_DecisionTaskStarted as it was already called when handling it.
 	if isLast && event.GetEventType() != m.EventTypeDecisionTaskStarted {
 		weh.workflowDefinition.OnDecisionTaskStarted()
+		weh.clearUnobservedVersions()
 	}
 
 	return nil
 }
 
+// clearUnobservedVersions is called once the workflow code of a decision has run, the versions recorded by the
+// decision were decided before any code of the following decisions runs.
+func (weh *workflowExecutionEventHandlerImpl) clearUnobservedVersions() {
+	for changeID := range weh.unobservedVersions {
+		delete(weh.unobservedVersions, changeID)
+	}
+}
+
 func (weh *workflowExecutionEventHandlerImpl) ProcessQuery(queryType string, queryArgs []byte) ([]byte, error) {
 	switch queryType {
 	case QueryTypeStackTrace:
 		return weh.encodeArg(weh.StackTrace())
 	case QueryTypeOpenSessions:
 		return weh.encodeArg(weh.getOpenSessions())
+	case QueryTypePendingOperations:
+		return weh.encodeArg(weh.getPendingOperations())
+	case QueryTypeRecentLogs:
+		return weh.queryRecentLogs(queryArgs)
 	case QueryTypeQueryTypes:
 		return weh.encodeArg(weh.KnownQueryTypes())
 	default:
-		result, err := weh.queryHandler(queryType, queryArgs)
+		result, err := weh.executeQueryHandler(queryType, queryArgs)
 		if err != nil {
 			return nil, err
 		}
@@ -996,12 +1126,49 @@ func (weh *workflowExecutionEventHandlerImpl) handleWorkflowExecutionStarted(
 	if err != nil {
 		return err
 	}
+	if dataConverter := weh.registry.getWorkflowDataConverter(weh.workflowInfo.WorkflowType); dataConverter != nil {
+		weh.dataConverter = dataConverter
+	}
+	if registerName, ok := weh.registry.getMatchedWorkflowTypeAlias(weh.workflowInfo.WorkflowType.Name); ok {
+		weh.workflowInfo.MatchedWorkflowTypeAlias = weh.workflowInfo.WorkflowType.Name
+		// reported once per execution, not on every replay of its history
+		if !weh.isReplay {
+			weh.metricsScope.Counter(metrics.WorkflowTypeAliasMatchedCounter).Inc(1)
+			weh.logger.Info("Workflow type matched an alias of a registered workflow.",
+				zap.String(tagWorkflowType, weh.workflowInfo.WorkflowType.Name),
+				zap.String("RegisteredWorkflowType", registerName))
+		}
+	}
+
+	if weh.recordSDKVersionMarker && !weh.isReplay {
+		if err := weh.recordSDKVersion(); err != nil {
+			return err
+		}
+	}
 
 	// Invoke the workflow.
 	weh.workflowDefinition.Execute(weh, attributes.Header, attributes.Input)
 	return nil
 }
 
+// handleActivityTaskStarted only records the attempt reported by QueryTypePendingOperations. The decision is looked up
+// without getDecision, which would reorder decisions, and without failing on unknown activities.
+func (weh *workflowExecutionEventHandlerImpl) handleActivityTaskStarted(event *m.HistoryEvent) {
+	attributes := event.ActivityTaskStartedEventAttributes
+	activityID, ok := weh.decisionsHelper.scheduledEventIDToActivityID[attributes.GetScheduledEventId()]
+	if !ok {
+		return
+	}
+	element, ok := weh.decisionsHelper.decisions[makeDecisionID(decisionTypeActivity, activityID)]
+	if !ok {
+		return
+	}
+	if activity, ok := element.Value.(decisionStateMachine).getData().(*scheduledActivity); ok {
+		activity.started = true
+		activity.attempt = attributes.GetAttempt()
+	}
+}
+
 func (weh *workflowExecutionEventHandlerImpl) handleActivityTaskCompleted(event *m.HistoryEvent) {
 	activityID := weh.decisionsHelper.getActivityID(event)
 	decision := weh.decisionsHelper.handleActivityTaskClosed(activityID)
@@ -1101,6 +1268,7 @@ func (weh *workflowExecutionEventHandlerImpl) handleMarkerRecorded(
 			return fmt.Errorf("extract change id: %w", err)
 		}
 		weh.changeVersions[changeID] = version
+		weh.unobservedVersions[changeID] = struct{}{}
 		return nil
 	case localActivityMarkerName:
 		return weh.handleLocalActivityMarker(attributes.Details)
@@ -1113,6 +1281,9 @@ func (weh *workflowExecutionEventHandlerImpl) handleMarkerRecorded(
 		}
 		weh.mutableSideEffect[fixedID] = []byte(result)
 		return nil
+	case sdkVersionMarkerName:
+		weh.handleSDKVersionMarker(encodedValues)
+		return nil
 	default:
 		return fmt.Errorf("unknown marker name \"%v\" for eventID \"%v\"",
 			attributes.GetMarkerName(), eventID)
@@ -1217,6 +1388,7 @@ func (weh *workflowExecutionEventHandlerImpl) handleChildWorkflowExecutionStarte
 	childRunID := attributes.WorkflowExecution.GetRunId()
 	decision := weh.decisionsHelper.handleChildWorkflowExecutionStarted(childWorkflowID)
 	childWorkflow := decision.getData().(*scheduledChildWorkflow)
+	childWorkflow.runID = childRunID
 	if childWorkflow.handled {
 		return
 	}
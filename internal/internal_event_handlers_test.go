@@ -31,6 +31,7 @@ import (
 	"github.com/uber-go/tally"
 
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -413,7 +414,7 @@ func TestProcessQuery_KnownQueryTypes(t *testing.T) {
 
 	result, err := weh.ProcessQuery(QueryTypeQueryTypes, nil)
 	assert.NoError(t, err)
-	assert.Equal(t, "[\"__open_sessions\",\"__query_types\",\"__stack_trace\",\"a\"]\n", string(result))
+	assert.Equal(t, "[\"__cadence_metadata\",\"__open_sessions\",\"__pending_operations\",\"__query_types\",\"__recent_logs\",\"__stack_trace\",\"a\"]\n", string(result))
 }
 
 func TestWorkflowExecutionEventHandler_ProcessEvent_WorkflowExecutionStarted(t *testing.T) {
@@ -729,6 +730,76 @@ func TestSideEffect(t *testing.T) {
 	})
 }
 
+func observedWorkflowExecutionEventHandler(t *testing.T) (*workflowExecutionEventHandlerImpl, *observer.ObservedLogs, tally.TestScope) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	scope := tally.NewTestScope("", nil)
+	weh := newWorkflowExecutionEventHandler(
+		&WorkflowInfo{WorkflowType: WorkflowType{Name: "test"}},
+		func(result []byte, err error) {},
+		zap.New(core),
+		false,
+		0,
+		false,
+		false,
+		false,
+		true,
+		false,
+		scope,
+		newRegistry(),
+		&defaultDataConverter{},
+		nil,
+		opentracing.NoopTracer{},
+		nil,
+		FeatureFlags{},
+	).(*workflowExecutionEventHandlerImpl)
+	return weh, logs, scope
+}
+
+func TestSideEffectLogger(t *testing.T) {
+	weh, logs, _ := observedWorkflowExecutionEventHandler(t)
+	weh.counterID = 3
+	weh.SideEffect(func() ([]byte, error) {
+		weh.GetLogger().Info("in side effect")
+		return nil, nil
+	}, func(result []byte, err error) {})
+	weh.GetLogger().Info("after side effect")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	assert.Equal(t, int32(3), entries[0].ContextMap()[tagSideEffectID])
+	assert.NotContains(t, entries[1].ContextMap(), tagSideEffectID)
+}
+
+func TestProcessQuery_LoggerAndMetricsScope(t *testing.T) {
+	weh, logs, scope := observedWorkflowExecutionEventHandler(t)
+	logger := weh.GetLogger()
+	weh.RegisterQueryHandler(func(queryType string, queryArgs []byte) ([]byte, error) {
+		assert.False(t, weh.IsReplaying(), "queries are not replayed")
+		weh.GetLogger().Info("in query handler")
+		logger.Info("captured logger in query handler")
+		weh.GetMetricsScope().Counter("queries").Inc(1)
+		return nil, nil
+	})
+
+	weh.isReplay = true
+	_, err := weh.ProcessQuery("state", nil)
+	require.NoError(t, err)
+	assert.True(t, weh.IsReplaying())
+	weh.GetLogger().Info("replayed")
+	weh.GetMetricsScope().Counter("replayed").Inc(1)
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2, "the logs after the query are replayed")
+	assert.Equal(t, "state", entries[0].ContextMap()[tagQueryType])
+	assert.Equal(t, "captured logger in query handler", entries[1].Message)
+	assert.NotContains(t, entries[1].ContextMap(), tagQueryType)
+
+	counters := scope.Snapshot().Counters()
+	require.Contains(t, counters, "queries+QueryType=state,WorkflowType=test")
+	assert.Equal(t, int64(1), counters["queries+QueryType=state,WorkflowType=test"].Value())
+	assert.Zero(t, counters["replayed+WorkflowType=test"].Value(), "the metrics after the query are replayed")
+}
+
 func TestGetVersion_validation(t *testing.T) {
 	t.Run("version < minSupported", func(t *testing.T) {
 		assert.PanicsWithValue(t, `Workflow code removed support of version 1. for "test" changeID. The oldest supported version is 2`, func() {
@@ -837,6 +908,39 @@ func TestGetVersion(t *testing.T) {
 	})
 }
 
+func TestGetVersions(t *testing.T) {
+	t.Run("undecided versions are not decided", func(t *testing.T) {
+		weh := testWorkflowExecutionEventHandler(t, newRegistry())
+		weh.changeVersions = map[string]Version{
+			"decided": 2,
+		}
+		res := weh.DecidedVersions([]string{"decided", "undecided"})
+		assert.Equal(t, map[string]Version{"decided": 2}, res)
+		assert.Equal(t, Version(3), weh.GetVersion("undecided", DefaultVersion, 3))
+	})
+	t.Run("replayed versions are hidden until observed", func(t *testing.T) {
+		weh := testWorkflowExecutionEventHandler(t, newRegistry())
+		require.NoError(t, weh.handleMarkerRecorded(1, &s.MarkerRecordedEventAttributes{
+			MarkerName: common.StringPtr(versionMarkerName),
+			Details:    getSerializedDetails(t, "first", Version(1)),
+		}))
+		require.NoError(t, weh.handleMarkerRecorded(2, &s.MarkerRecordedEventAttributes{
+			MarkerName: common.StringPtr(versionMarkerName),
+			Details:    getSerializedDetails(t, "second", Version(2)),
+		}))
+		assert.Empty(t, weh.ExecutionVersions())
+
+		assert.Equal(t, Version(1), weh.GetVersion("first", DefaultVersion, 1))
+		assert.Equal(t, map[string]Version{"first": 1}, weh.ExecutionVersions())
+
+		weh.clearUnobservedVersions()
+		versions := weh.ExecutionVersions()
+		assert.Equal(t, map[string]Version{"first": 1, "second": 2}, versions)
+		versions["third"] = 3
+		assert.NotContains(t, weh.ExecutionVersions(), "third", "ensure a copy is returned")
+	})
+}
+
 func TestMutableSideEffect(t *testing.T) {
 	t.Run("replay with existing value", func(t *testing.T) {
 		weh := testWorkflowExecutionEventHandler(t, newRegistry())
@@ -1016,6 +1120,52 @@ func TestWorkflowEnvironment_sessions(t *testing.T) {
 	assert.Empty(t, list)
 }
 
+func TestWorkflowExecutionEventHandler_PendingOperations(t *testing.T) {
+	weh := testWorkflowExecutionEventHandler(t, newRegistry())
+	now := time.Unix(1000, 0).UTC()
+	weh.SetCurrentReplayTime(now)
+
+	activity := weh.ExecuteActivity(executeActivityParams{ActivityType: ActivityType{Name: "remote"}}, func([]byte, error) {})
+	canceled := weh.ExecuteActivity(executeActivityParams{ActivityType: ActivityType{Name: "canceled"}}, func([]byte, error) {})
+	weh.RequestCancelActivity(canceled.activityID)
+	weh.NewTimer(time.Minute, func([]byte, error) {})
+	require.NoError(t, weh.ExecuteChildWorkflow(executeWorkflowParams{
+		workflowOptions: workflowOptions{workflowID: "child-id"},
+		workflowType:    &WorkflowType{Name: "child"},
+	}, func([]byte, error) {}, func(WorkflowExecution, error) {}))
+	local := weh.ExecuteLocalActivity(executeLocalActivityParams{ActivityType: "local", ScheduledTime: now}, func(*localActivityResultWrapper) {})
+
+	weh.decisionsHelper.getDecisions(true)
+	require.NoError(t, weh.ProcessEvent(&s.HistoryEvent{
+		EventId:   common.Int64Ptr(5),
+		EventType: s.EventTypeActivityTaskScheduled.Ptr(),
+		ActivityTaskScheduledEventAttributes: &s.ActivityTaskScheduledEventAttributes{
+			ActivityId: common.StringPtr(activity.activityID),
+		},
+	}, false, false))
+	require.NoError(t, weh.ProcessEvent(&s.HistoryEvent{
+		EventId:   common.Int64Ptr(6),
+		EventType: s.EventTypeActivityTaskStarted.Ptr(),
+		ActivityTaskStartedEventAttributes: &s.ActivityTaskStartedEventAttributes{
+			ScheduledEventId: common.Int64Ptr(5),
+			Attempt:          common.Int32Ptr(2),
+		},
+	}, false, false))
+
+	result, err := weh.ProcessQuery(QueryTypePendingOperations, nil)
+	require.NoError(t, err)
+	var pending PendingOperations
+	require.NoError(t, newEncodedValue(result, weh.GetDataConverter()).Get(&pending))
+
+	assert.Equal(t, []PendingActivity{
+		{ActivityID: activity.activityID, ActivityType: "remote", ScheduledTime: now, Started: true, Attempt: 2},
+		{ActivityID: local.activityID, ActivityType: "local", IsLocal: true, ScheduledTime: now},
+	}, pending.Activities)
+	require.Len(t, pending.Timers, 1)
+	assert.True(t, now.Add(time.Minute).Equal(pending.Timers[0].FireTime))
+	assert.Equal(t, []PendingChildWorkflow{{WorkflowID: "child-id", WorkflowType: "child"}}, pending.ChildWorkflows)
+}
+
 func TestWorkflowExecutionEnvironment_NewTimer_immediate_calls(t *testing.T) {
 	t.Run("immediate call", func(t *testing.T) {
 		handler := testWorkflowExecutionEventHandler(t, newRegistry())
@@ -1059,6 +1209,12 @@ func testWorkflowExecutionEventHandler(t *testing.T, registry *registry) *workfl
 		func(result []byte, err error) {},
 		testlogger.NewZap(t),
 		true,
+		0,
+		false,
+		false,
+		false,
+		true,
+		false,
 		tally.NewTestScope("test", nil),
 		registry,
 		&defaultDataConverter{},
@@ -1075,3 +1231,30 @@ func getSerializedDetails[T, V any](t *testing.T, id T, data V) []byte {
 	require.NoError(t, err)
 	return res
 }
+
+func TestWorkflowTypeAliasReportedOnce(t *testing.T) {
+	for _, isReplay := range []bool{false, true} {
+		weh, logs, scope := observedWorkflowExecutionEventHandler(t)
+		weh.registry.RegisterWorkflowWithOptions(func(ctx Context) error { return nil },
+			RegisterWorkflowOptions{Name: "test-v2", Aliases: []string{"test"}})
+		weh.enableLoggingInReplay = true
+		weh.isReplay = isReplay
+		require.NoError(t, weh.handleWorkflowExecutionStarted(&s.WorkflowExecutionStartedEventAttributes{}))
+		weh.Close()
+
+		assert.Equal(t, "test", weh.workflowInfo.MatchedWorkflowTypeAlias)
+		var matched int64
+		for _, counter := range scope.Snapshot().Counters() {
+			if counter.Name() == metrics.WorkflowTypeAliasMatchedCounter {
+				matched += counter.Value()
+			}
+		}
+		if isReplay {
+			assert.Zero(t, logs.FilterMessageSnippet("matched an alias").Len(), "not logged on replay")
+			assert.Zero(t, matched, "not counted on replay")
+		} else {
+			assert.Equal(t, 1, logs.FilterMessageSnippet("matched an alias").Len())
+			assert.Equal(t, int64(1), matched)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type (
+	// RegisterFailureTypeOptions consists of options for registering a failure type.
+	RegisterFailureTypeOptions struct {
+		// Optional: reason of the failures of this type, which identifies the type in the history and must be the
+		// same for all the workers and clients of the domain.
+		// default: the name of the type, without its package
+		Name string
+	}
+
+	// failureTypeRegistry holds the failure types registered with RegisterFailureType
+	failureTypeRegistry struct {
+		sync.RWMutex
+		types map[string]reflect.Type
+		names map[reflect.Type]string
+	}
+)
+
+var globalFailureTypes = &failureTypeRegistry{
+	types: make(map[string]reflect.Type),
+	names: make(map[reflect.Type]string),
+}
+
+// RegisterFailureType registers the type of failure, so that a workflow or an activity failing with an error of this
+// type, or wrapping one, records it in the history as a *CustomError whose reason is the name of the type and whose
+// details are the failure encoded by the data converter. When the failure is received, by a workflow from an activity
+// or child workflow, or by a client from WorkflowRun.Get or GetWorkflowResult, it is decoded back to the registered
+// type, which errors.As can extract from the returned *CustomError:
+//
+//	type InsufficientFundsError struct {
+//		Account string
+//		Missing int
+//	}
+//
+//	func (e *InsufficientFundsError) Error() string { ... }
+//
+//	func init() {
+//		cadence.RegisterFailureType(&InsufficientFundsError{})
+//	}
+//
+//	var insufficientFunds *InsufficientFundsError
+//	if err := run.Get(ctx, nil); errors.As(err, &insufficientFunds) {
+//		...
+//	}
+//
+// Only the exported fields of the failure are encoded by the default data converter. The type must be registered in
+// every process failing with it or decoding it, typically from an init function. It panics if the type or its name is
+// already registered.
+func RegisterFailureType(failure error) {
+	RegisterFailureTypeWithOptions(failure, RegisterFailureTypeOptions{})
+}
+
+// RegisterFailureTypeWithOptions registers the type of failure with options, see RegisterFailureType.
+func RegisterFailureTypeWithOptions(failure error, options RegisterFailureTypeOptions) {
+	if err := globalFailureTypes.register(failure, options); err != nil {
+		panic(err)
+	}
+}
+
+func (r *failureTypeRegistry) register(failure error, options RegisterFailureTypeOptions) error {
+	if failure == nil {
+		return errors.New("failure type cannot be nil")
+	}
+	t := reflect.TypeOf(failure)
+	name := options.Name
+	if name == "" {
+		name = t.Name()
+		if t.Kind() == reflect.Ptr {
+			name = t.Elem().Name()
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("failure type %v has no name", t)
+	}
+	if strings.HasPrefix(name, "cadenceInternal:") {
+		return fmt.Errorf("'cadenceInternal:' is reserved prefix, failure type %v cannot be named %q", t, name)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if registered, ok := r.names[t]; ok {
+		return fmt.Errorf("failure type %v is already registered as %q", t, registered)
+	}
+	if registered, ok := r.types[name]; ok {
+		return fmt.Errorf("failure type name %q is already registered by %v", name, registered)
+	}
+	r.types[name] = t
+	r.names[t] = name
+	return nil
+}
+
+// encode returns the reason and details of the first error of the chain of err having a registered type
+func (r *failureTypeRegistry) encode(err error, dataConverter DataConverter) (string, []byte, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	if len(r.names) == 0 {
+		return "", nil, false
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		name, ok := r.names[reflect.TypeOf(err)]
+		if !ok {
+			continue
+		}
+		data, encodeErr := encodeArgs(dataConverter, []interface{}{err})
+		if encodeErr != nil {
+			return "", nil, false
+		}
+		return name, data, true
+	}
+	return "", nil, false
+}
+
+// decode returns the failure of the registered type named reason, decoded from details
+func (r *failureTypeRegistry) decode(reason string, details []byte, dataConverter DataConverter) (error, bool) {
+	r.RLock()
+	t, ok := r.types[reason]
+	r.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	valuePtr := reflect.New(t)
+	if t.Kind() == reflect.Ptr {
+		valuePtr.Elem().Set(reflect.New(t.Elem()))
+	}
+	if err := newEncodedValues(details, dataConverter).Get(valuePtr.Interface()); err != nil {
+		return nil, false
+	}
+	failure, ok := valuePtr.Elem().Interface().(error)
+	return failure, ok
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testInsufficientFundsFailure struct {
+	Account string
+	Missing int
+}
+
+func (e *testInsufficientFundsFailure) Error() string {
+	return fmt.Sprintf("account %v is missing %v", e.Account, e.Missing)
+}
+
+type testQuotaFailure struct {
+	Quota string
+}
+
+func (e testQuotaFailure) Error() string {
+	return "quota exceeded: " + e.Quota
+}
+
+func init() {
+	RegisterFailureType(&testInsufficientFundsFailure{})
+	RegisterFailureTypeWithOptions(testQuotaFailure{}, RegisterFailureTypeOptions{Name: "QuotaExceeded"})
+}
+
+func TestFailureTypeRegistry(t *testing.T) {
+	r := &failureTypeRegistry{
+		types: make(map[string]reflect.Type),
+		names: make(map[reflect.Type]string),
+	}
+	require.NoError(t, r.register(&testInsufficientFundsFailure{}, RegisterFailureTypeOptions{}))
+	assert.Equal(t, "testInsufficientFundsFailure", r.names[reflect.TypeOf(&testInsufficientFundsFailure{})])
+	assert.ErrorContains(t, r.register(&testInsufficientFundsFailure{}, RegisterFailureTypeOptions{Name: "other"}), "already registered")
+	assert.ErrorContains(t, r.register(testQuotaFailure{}, RegisterFailureTypeOptions{Name: "testInsufficientFundsFailure"}), "already registered")
+	assert.ErrorContains(t, r.register(testQuotaFailure{}, RegisterFailureTypeOptions{Name: "cadenceInternal:quota"}), "reserved")
+	assert.ErrorContains(t, r.register(struct{ error }{errors.New("anonymous")}, RegisterFailureTypeOptions{}), "has no name")
+	assert.Error(t, r.register(nil, RegisterFailureTypeOptions{}))
+}
+
+func TestFailureTypeRoundTrip(t *testing.T) {
+	dc := getDefaultDataConverter()
+
+	reason, details := getErrorDetails(fmt.Errorf("charge failed: %w", &testInsufficientFundsFailure{Account: "a", Missing: 10}), dc)
+	assert.Equal(t, "testInsufficientFundsFailure", reason)
+	err := constructError(reason, details, dc)
+	var customErr *CustomError
+	require.True(t, errors.As(err, &customErr))
+	assert.Equal(t, "testInsufficientFundsFailure", customErr.Reason())
+	assert.Equal(t, "account a is missing 10", customErr.Error())
+	var failure *testInsufficientFundsFailure
+	require.True(t, errors.As(err, &failure))
+	assert.Equal(t, &testInsufficientFundsFailure{Account: "a", Missing: 10}, failure)
+
+	// a received failure is passed through unchanged
+	reason2, details2 := getErrorDetails(err, dc)
+	assert.Equal(t, reason, reason2)
+	assert.Equal(t, details, details2)
+
+	reason, details = getErrorDetails(testQuotaFailure{Quota: "cpu"}, dc)
+	assert.Equal(t, "QuotaExceeded", reason)
+	var quota testQuotaFailure
+	require.True(t, errors.As(constructError(reason, details, dc), &quota))
+	assert.Equal(t, "cpu", quota.Quota)
+
+	// details which cannot be decoded leave a plain custom error
+	err = constructError("QuotaExceeded", []byte("not json"), dc)
+	require.True(t, errors.As(err, &customErr))
+	assert.Nil(t, customErr.Unwrap())
+	assert.Equal(t, "QuotaExceeded", err.Error())
+
+	reason, details = getErrorDetails(errors.New("unregistered"), dc)
+	assert.Equal(t, errReasonGeneric, reason)
+	assert.Equal(t, "unregistered", string(details))
+}
+
+func TestFailureTypeWorkflow(t *testing.T) {
+	chargeActivity := func(ctx context.Context, account string) error {
+		return &testInsufficientFundsFailure{Account: account, Missing: 5}
+	}
+	workflowFn := func(ctx Context) error {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		err := ExecuteActivity(ctx, chargeActivity, "a").Get(ctx, nil)
+		var failure *testInsufficientFundsFailure
+		if !errors.As(err, &failure) {
+			return fmt.Errorf("unexpected activity error: %w", err)
+		}
+		failure.Missing *= 2
+		return fmt.Errorf("workflow failed: %w", failure)
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(chargeActivity)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+
+	err := env.GetWorkflowError()
+	var failure *testInsufficientFundsFailure
+	require.True(t, errors.As(err, &failure), "%v", err)
+	assert.Equal(t, &testInsufficientFundsFailure{Account: "a", Missing: 10}, failure)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"go.uber.org/multierr"
+)
+
+// WhenAll returns a Future that is ready once all futures are ready. Its value is nil, and its error combines the
+// errors of the futures that failed, in the order of futures. It is ready immediately when there are no futures.
+func WhenAll(ctx Context, futures ...Future) Future {
+	future, settable := NewFuture(ctx)
+	Go(ctx, func(ctx Context) {
+		var errs error
+		for _, f := range futures {
+			errs = multierr.Append(errs, f.Get(ctx, nil))
+		}
+		settable.Set(nil, errs)
+	})
+	return future
+}
+
+// WhenAny returns a Future that is ready once any of futures is ready, whether it succeeded or failed. Its value is
+// the index in futures of the first future that became ready. It panics when there are no futures.
+func WhenAny(ctx Context, futures ...Future) Future {
+	if len(futures) == 0 {
+		panic("WhenAny: no futures")
+	}
+	future, settable := NewFuture(ctx)
+	Go(ctx, func(ctx Context) {
+		selector := NewSelector(ctx)
+		for i, f := range futures {
+			i := i
+			selector.AddFuture(f, func(Future) {
+				settable.SetValue(i)
+			})
+		}
+		selector.Select(ctx)
+	})
+	return future
+}
+
+// WhenAnyResult returns a Future that is ready once any of futures succeeds, with the value of the first future that
+// succeeded. When all futures fail, its error combines their errors in the order they failed. It panics when there are
+// no futures.
+func WhenAnyResult(ctx Context, futures ...Future) Future {
+	if len(futures) == 0 {
+		panic("WhenAnyResult: no futures")
+	}
+	future, settable := NewFuture(ctx)
+	Go(ctx, func(ctx Context) {
+		var errs []error
+		selector := NewSelector(ctx)
+		for _, f := range futures {
+			selector.AddFuture(f, func(f Future) {
+				value, err := f.(asyncFuture).GetValueAndError()
+				if err != nil {
+					errs = append(errs, err)
+					return
+				}
+				settable.SetValue(value)
+			})
+		}
+		for pending := len(futures); pending > 0 && !future.IsReady(); pending-- {
+			selector.Select(ctx)
+		}
+		if !future.IsReady() {
+			settable.SetError(multierr.Combine(errs...))
+		}
+	})
+	return future
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+)
+
+func TestWhenAll(t *testing.T) {
+	var all, none Future
+	var settables []Settable
+	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+		var futures []Future
+		for i := 0; i < 3; i++ {
+			f, s := NewFuture(ctx)
+			futures = append(futures, f)
+			settables = append(settables, s)
+		}
+		all = WhenAll(ctx, futures...)
+		none = WhenAll(ctx)
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.True(t, none.IsReady())
+	_, err := none.(asyncFuture).GetValueAndError()
+	assert.NoError(t, err)
+
+	settables[2].SetError(errors.New("error 2"))
+	settables[0].SetValue(0)
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.False(t, all.IsReady())
+
+	settables[1].SetError(errors.New("error 1"))
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	require.True(t, all.IsReady())
+	_, err = all.(asyncFuture).GetValueAndError()
+	assert.Equal(t, []error{errors.New("error 1"), errors.New("error 2")}, multierr.Errors(err))
+	assert.True(t, d.IsDone())
+}
+
+func TestWhenAny(t *testing.T) {
+	var whenAny Future
+	var settables []Settable
+	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+		var futures []Future
+		for i := 0; i < 3; i++ {
+			f, s := NewFuture(ctx)
+			futures = append(futures, f)
+			settables = append(settables, s)
+		}
+		whenAny = WhenAny(ctx, futures...)
+		assert.Panics(t, func() { WhenAny(ctx) })
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.False(t, whenAny.IsReady())
+
+	// failed futures are ready too
+	settables[2].SetError(errors.New("error 2"))
+	settables[1].SetValue(1)
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	require.True(t, whenAny.IsReady())
+	index, err := whenAny.(asyncFuture).GetValueAndError()
+	require.NoError(t, err)
+	assert.Equal(t, 2, index)
+	assert.True(t, d.IsDone())
+}
+
+func TestWhenAnyResult(t *testing.T) {
+	var succeeded, failed Future
+	var settables []Settable
+	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+		var futures []Future
+		for i := 0; i < 5; i++ {
+			f, s := NewFuture(ctx)
+			futures = append(futures, f)
+			settables = append(settables, s)
+		}
+		succeeded = WhenAnyResult(ctx, futures[:3]...)
+		failed = WhenAnyResult(ctx, futures[3:]...)
+		assert.Panics(t, func() { WhenAnyResult(ctx) })
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+
+	settables[0].SetError(errors.New("error 0"))
+	settables[4].SetError(errors.New("error 4"))
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.False(t, succeeded.IsReady())
+	assert.False(t, failed.IsReady())
+
+	settables[2].SetValue("result 2")
+	settables[3].SetError(errors.New("error 3"))
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	require.True(t, succeeded.IsReady())
+	result, err := succeeded.(asyncFuture).GetValueAndError()
+	require.NoError(t, err)
+	assert.Equal(t, "result 2", result)
+	require.True(t, failed.IsReady())
+	_, err = failed.(asyncFuture).GetValueAndError()
+	assert.Equal(t, []error{errors.New("error 4"), errors.New("error 3")}, multierr.Errors(err))
+	assert.True(t, d.IsDone(), "the remaining futures are not waited on")
+}
+
+func TestWhenAnyResultActivities(t *testing.T) {
+	activityFn := func(ctx context.Context, delay time.Duration, fail bool) (time.Duration, error) {
+		if fail {
+			return 0, errors.New("failed")
+		}
+		return delay, nil
+	}
+	workflowFn := func(ctx Context) (time.Duration, error) {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		var fastest time.Duration
+		err := WhenAnyResult(ctx,
+			ExecuteActivity(ctx, activityFn, time.Millisecond, true),
+			ExecuteActivity(ctx, activityFn, 20*time.Millisecond, false),
+		).Get(ctx, &fastest)
+		return fastest, err
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityFn)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var fastest time.Duration
+	require.NoError(t, env.GetWorkflowResult(&fastest))
+	assert.Equal(t, 20*time.Millisecond, fastest)
+}
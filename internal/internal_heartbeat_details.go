@@ -0,0 +1,105 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+// defaultMaxHeartbeatDetailsSize is the default blob size limit of the server. The server fails activities which
+// heartbeat larger details.
+const defaultMaxHeartbeatDetailsSize = 2 * 1024 * 1024
+
+var (
+	// ErrHeartbeatDetailsTooLarge is matched by errors.Is for the *HeartbeatDetailsTooLargeError returned when
+	// heartbeat details are larger than WorkerOptions.MaxHeartbeatDetailsSize.
+	ErrHeartbeatDetailsTooLarge = errors.New("heartbeat details too large")
+
+	// ErrHeartbeatDetailsTruncated is returned by GetHeartbeatDetails when the previous attempt recorded details
+	// which were too large, and were truncated as WorkerOptions.TruncateLargeHeartbeatDetails is set.
+	ErrHeartbeatDetailsTruncated = errors.New("heartbeat details of the previous attempt were too large and truncated")
+
+	// recorded instead of details which are too large. It cannot be produced by the default data converter, which
+	// encodes each value as JSON followed by a new line.
+	heartbeatDetailsTruncatedMarker = []byte("cadence:heartbeat-details-truncated")
+)
+
+// HeartbeatDetailsTooLargeError is returned when heartbeat details are larger than the limit once serialized.
+type HeartbeatDetailsTooLargeError struct {
+	// Size of the serialized details in bytes.
+	Size int
+	// Limit is WorkerOptions.MaxHeartbeatDetailsSize, or the default blob size limit of the server.
+	Limit int
+	// Truncated is set if the heartbeat was recorded without its details, see
+	// WorkerOptions.TruncateLargeHeartbeatDetails.
+	Truncated bool
+}
+
+func (e *HeartbeatDetailsTooLargeError) Error() string {
+	if e.Truncated {
+		return fmt.Sprintf("heartbeat details too large: %d bytes exceed the limit of %d bytes, the heartbeat was recorded without details", e.Size, e.Limit)
+	}
+	return fmt.Sprintf("heartbeat details too large: %d bytes exceed the limit of %d bytes, the heartbeat was not recorded", e.Size, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrHeartbeatDetailsTooLarge) match.
+func (e *HeartbeatDetailsTooLargeError) Is(target error) bool {
+	return target == ErrHeartbeatDetailsTooLarge
+}
+
+func validateHeartbeatDetails(details []byte, limit int) *HeartbeatDetailsTooLargeError {
+	if limit <= 0 {
+		limit = defaultMaxHeartbeatDetailsSize
+	}
+	if len(details) > limit {
+		return &HeartbeatDetailsTooLargeError{Size: len(details), Limit: limit}
+	}
+	return nil
+}
+
+// recordHeartbeat validates the serialized details before they are batched for the server.
+func (env *activityEnvironment) recordHeartbeat(details []byte) error {
+	tooLarge := validateHeartbeatDetails(details, env.maxHeartbeatDetailsSize)
+	if tooLarge != nil {
+		env.metricsScope.Counter(metrics.ActivityHeartbeatDetailsTooLargeCounter).Inc(1)
+		if !env.truncateHeartbeatDetails {
+			env.logger.Warn("Heartbeat details too large, heartbeat not recorded.",
+				zap.Int(tagHeartbeatDetailsSize, tooLarge.Size), zap.Int(tagHeartbeatDetailsLimit, tooLarge.Limit))
+			return tooLarge
+		}
+		env.logger.Warn("Heartbeat details too large, recording heartbeat without details.",
+			zap.Int(tagHeartbeatDetailsSize, tooLarge.Size), zap.Int(tagHeartbeatDetailsLimit, tooLarge.Limit))
+		tooLarge.Truncated = true
+		details = heartbeatDetailsTruncatedMarker
+	}
+	if err := env.serviceInvoker.BatchHeartbeat(details); err != nil {
+		env.logger.Debug("RecordActivityHeartbeat With Error:", zap.Error(err))
+		return err
+	}
+	if tooLarge != nil {
+		return tooLarge
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+const (
+	defaultHistoryGrowthMaxEventsPerHour = 1000
+	defaultHistoryGrowthWindow           = time.Hour
+	defaultHistoryGrowthMinEvents        = 100
+	defaultHistoryGrowthMaxFlagged       = 100
+
+	// maxHistoryGrowthSamples bounds the samples kept per cached execution: the oldest ones are dropped, which
+	// shortens the window of executions with many decision tasks.
+	maxHistoryGrowthSamples = 100
+)
+
+type (
+	// HistoryGrowthDetectorOptions configures a HistoryGrowthDetector.
+	HistoryGrowthDetectorOptions struct {
+		// Optional: number of history events per hour above which an execution is flagged.
+		// default: 1000
+		MaxEventsPerHour float64
+
+		// Optional: period over which the growth rate of the history of an execution is measured.
+		// default: 1 hour
+		Window time.Duration
+
+		// Optional: minimum number of events the history must have grown by during the window before it is flagged,
+		// so that the first decision tasks of a new execution do not flag it.
+		// default: 100
+		MinEvents int64
+
+		// Optional: maximum number of flagged executions kept, the ones flagged first are dropped beyond it.
+		// default: 100
+		MaxFlagged int
+	}
+
+	// HistoryGrowthReport describes an execution whose history grows faster than
+	// HistoryGrowthDetectorOptions.MaxEventsPerHour.
+	HistoryGrowthReport struct {
+		Domain        string    `json:"domain"`
+		WorkflowType  string    `json:"workflowType"`
+		WorkflowID    string    `json:"workflowID"`
+		RunID         string    `json:"runID"`
+		Events        int64     `json:"events"`
+		EventsPerHour float64   `json:"eventsPerHour"`
+		FlaggedAt     time.Time `json:"flaggedAt"`
+	}
+
+	// HistoryGrowthDetector flags the executions whose history event count grows faster than a rate, e.g. workflow
+	// code looping on activities or timers, before they reach the history limits of the server, see
+	// WorkerOptions.HistoryGrowthDetector. The rate is measured by the workers on the decision tasks they process for
+	// the executions in their sticky cache, from the start of the execution or from the decision tasks processed
+	// during the window. Flagged executions are logged with a warning, counted with the
+	// cadence-history-growth-exceeded counter, and listed by Flagged until their growth slows down or they close.
+	// HistoryGrowthDetector implements http.Handler to serve them as JSON, e.g. on a debug endpoint of the service.
+	// Use NewHistoryGrowthDetector to create one; it is safe for concurrent use and can be shared by workers.
+	HistoryGrowthDetector struct {
+		options HistoryGrowthDetectorOptions
+		now     func() time.Time
+
+		mu      sync.Mutex
+		flagged map[string]HistoryGrowthReport // by run ID
+	}
+
+	historyGrowthSample struct {
+		at     time.Time
+		events int64
+	}
+)
+
+// NewHistoryGrowthDetector returns a HistoryGrowthDetector with no flagged executions.
+func NewHistoryGrowthDetector(options HistoryGrowthDetectorOptions) *HistoryGrowthDetector {
+	if options.MaxEventsPerHour <= 0 {
+		options.MaxEventsPerHour = defaultHistoryGrowthMaxEventsPerHour
+	}
+	if options.Window <= 0 {
+		options.Window = defaultHistoryGrowthWindow
+	}
+	if options.MinEvents <= 0 {
+		options.MinEvents = defaultHistoryGrowthMinEvents
+	}
+	if options.MaxFlagged <= 0 {
+		options.MaxFlagged = defaultHistoryGrowthMaxFlagged
+	}
+	return &HistoryGrowthDetector{
+		options: options,
+		now:     time.Now,
+		flagged: make(map[string]HistoryGrowthReport),
+	}
+}
+
+// Flagged returns the executions currently flagged, the fastest growing first.
+func (d *HistoryGrowthDetector) Flagged() []HistoryGrowthReport {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	reports := make([]HistoryGrowthReport, 0, len(d.flagged))
+	for _, report := range d.flagged {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].EventsPerHour != reports[j].EventsPerHour {
+			return reports[i].EventsPerHour > reports[j].EventsPerHour
+		}
+		return reports[i].RunID < reports[j].RunID
+	})
+	return reports
+}
+
+// ServeHTTP writes the flagged executions as a JSON array.
+func (d *HistoryGrowthDetector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d.Flagged())
+}
+
+// observe records the number of events of the history of an execution when one of its decision tasks is processed,
+// with the samples previously recorded for it, and returns the samples to keep along with the report of the execution
+// when its history grows too fast. flagged is true when the execution was not flagged yet. When there are no samples,
+// e.g. the execution was not cached, the first history event of the task in the window is used instead.
+func (d *HistoryGrowthDetector) observe(
+	info *WorkflowInfo,
+	samples []historyGrowthSample,
+	history []*s.HistoryEvent,
+	events int64,
+) (_ []historyGrowthSample, report *HistoryGrowthReport, flagged bool) {
+	now := d.now()
+	windowStart := now.Add(-d.options.Window)
+	if len(samples) == 0 {
+		for _, event := range history {
+			if at := time.Unix(0, event.GetTimestamp()); !at.Before(windowStart) {
+				samples = append(samples, historyGrowthSample{at: at, events: event.GetEventId() - 1})
+				break
+			}
+		}
+	}
+	for len(samples) > 0 && samples[0].at.Before(windowStart) {
+		samples = samples[1:]
+	}
+	if len(samples) >= maxHistoryGrowthSamples {
+		samples = samples[len(samples)-maxHistoryGrowthSamples+1:]
+	}
+
+	if len(samples) > 0 {
+		oldest := samples[0]
+		growth := events - oldest.events
+		if elapsed := now.Sub(oldest.at); growth >= d.options.MinEvents && elapsed > 0 {
+			if rate := float64(growth) / elapsed.Hours(); rate > d.options.MaxEventsPerHour {
+				report = &HistoryGrowthReport{
+					Domain:        info.Domain,
+					WorkflowType:  info.WorkflowType.Name,
+					WorkflowID:    info.WorkflowExecution.ID,
+					RunID:         info.WorkflowExecution.RunID,
+					Events:        events,
+					EventsPerHour: rate,
+					FlaggedAt:     now,
+				}
+			}
+		}
+	}
+	samples = append(samples, historyGrowthSample{at: now, events: events})
+
+	if report == nil {
+		d.forget(info.WorkflowExecution.RunID)
+		return samples, nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if previous, ok := d.flagged[report.RunID]; ok {
+		report.FlaggedAt = previous.FlaggedAt
+	} else {
+		flagged = true
+		if len(d.flagged) >= d.options.MaxFlagged {
+			d.dropFirstFlagged()
+		}
+	}
+	d.flagged[report.RunID] = *report
+	return samples, report, flagged
+}
+
+// forget removes the execution from the flagged ones, e.g. once it is closed.
+func (d *HistoryGrowthDetector) forget(runID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.flagged, runID)
+}
+
+func (d *HistoryGrowthDetector) dropFirstFlagged() {
+	var first *HistoryGrowthReport
+	for _, report := range d.flagged {
+		report := report
+		if first == nil || report.FlaggedAt.Before(first.FlaggedAt) {
+			first = &report
+		}
+	}
+	if first != nil {
+		delete(d.flagged, first.RunID)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func historyGrowthTestInfo(runID string) *WorkflowInfo {
+	return &WorkflowInfo{
+		Domain:            "domain",
+		WorkflowType:      WorkflowType{Name: "wt"},
+		WorkflowExecution: WorkflowExecution{ID: "wid-" + runID, RunID: runID},
+	}
+}
+
+func TestHistoryGrowthDetector(t *testing.T) {
+	var nilDetector *HistoryGrowthDetector
+	assert.Nil(t, nilDetector.Flagged())
+
+	d := NewHistoryGrowthDetector(HistoryGrowthDetectorOptions{})
+	assert.Equal(t, HistoryGrowthDetectorOptions{
+		MaxEventsPerHour: defaultHistoryGrowthMaxEventsPerHour,
+		Window:           defaultHistoryGrowthWindow,
+		MinEvents:        defaultHistoryGrowthMinEvents,
+		MaxFlagged:       defaultHistoryGrowthMaxFlagged,
+	}, d.options)
+
+	now := time.Unix(10000, 0)
+	d.now = func() time.Time { return now }
+	info := historyGrowthTestInfo("run")
+
+	samples, report, flagged := d.observe(info, nil, nil, 10)
+	assert.Nil(t, report)
+	assert.False(t, flagged)
+	require.Len(t, samples, 1)
+
+	// 150 events in 30 minutes is 300 events per hour
+	now = now.Add(30 * time.Minute)
+	samples, report, flagged = d.observe(info, samples, nil, 160)
+	assert.Nil(t, report)
+	assert.Len(t, samples, 2)
+
+	// 1210 events in 45 minutes
+	now = now.Add(15 * time.Minute)
+	flaggedAt := now
+	samples, report, flagged = d.observe(info, samples, nil, 1220)
+	require.NotNil(t, report)
+	assert.True(t, flagged)
+	assert.Equal(t, HistoryGrowthReport{
+		Domain:        "domain",
+		WorkflowType:  "wt",
+		WorkflowID:    "wid-run",
+		RunID:         "run",
+		Events:        1220,
+		EventsPerHour: 1210 / 0.75,
+		FlaggedAt:     flaggedAt,
+	}, *report)
+
+	// the first sample is out of the window: 1060 events in 45 minutes
+	now = now.Add(30 * time.Minute)
+	samples, report, flagged = d.observe(info, samples, nil, 2280)
+	require.NotNil(t, report)
+	assert.False(t, flagged, "the execution was already flagged")
+	assert.Equal(t, flaggedAt, report.FlaggedAt)
+	assert.Len(t, samples, 3)
+	assert.Equal(t, []HistoryGrowthReport{*report}, d.Flagged())
+
+	// the growth slowed down
+	now = now.Add(time.Hour)
+	_, report, _ = d.observe(info, samples, nil, 2300)
+	assert.Nil(t, report)
+	assert.Empty(t, d.Flagged())
+}
+
+func TestHistoryGrowthDetector_seedFromHistory(t *testing.T) {
+	d := NewHistoryGrowthDetector(HistoryGrowthDetectorOptions{MaxEventsPerHour: 100, MinEvents: 10})
+	now := time.Unix(10000, 0)
+	d.now = func() time.Time { return now }
+	event := func(id int64, at time.Duration) *s.HistoryEvent {
+		return &s.HistoryEvent{EventId: common.Int64Ptr(id), Timestamp: common.Int64Ptr(now.Add(at).UnixNano())}
+	}
+
+	// the first event in the window is the 3rd one, 97 events in 30 minutes
+	history := []*s.HistoryEvent{event(1, -2*time.Hour), event(2, -time.Hour-time.Second), event(3, -30*time.Minute), event(4, -time.Minute)}
+	samples, report, flagged := d.observe(historyGrowthTestInfo("run"), nil, history, 99)
+	require.NotNil(t, report)
+	assert.True(t, flagged)
+	assert.Equal(t, 97*2.0, report.EventsPerHour)
+	assert.Equal(t, []historyGrowthSample{{at: now.Add(-30 * time.Minute), events: 2}, {at: now, events: 99}}, samples)
+
+	// samples are kept instead of the events of the task
+	_, report, _ = d.observe(historyGrowthTestInfo("run"), samples[1:], history, 100)
+	assert.Nil(t, report)
+}
+
+func TestHistoryGrowthDetector_maxFlagged(t *testing.T) {
+	d := NewHistoryGrowthDetector(HistoryGrowthDetectorOptions{MinEvents: 1, MaxFlagged: 2})
+	now := time.Unix(10000, 0)
+	d.now = func() time.Time { return now }
+	for _, runID := range []string{"a", "b", "c"} {
+		start := []historyGrowthSample{{at: now.Add(-time.Minute), events: 0}}
+		_, report, flagged := d.observe(historyGrowthTestInfo(runID), start, nil, 1000)
+		require.NotNil(t, report)
+		assert.True(t, flagged)
+		now = now.Add(time.Second)
+	}
+	flagged := d.Flagged()
+	require.Len(t, flagged, 2)
+	assert.Equal(t, []string{"b", "c"}, []string{flagged[0].RunID, flagged[1].RunID})
+
+	d.forget("b")
+	recorder := httptest.NewRecorder()
+	d.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var served []HistoryGrowthReport
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &served))
+	require.Len(t, served, 1)
+	assert.Equal(t, "c", served[0].RunID)
+	assert.Equal(t, "wid-c", served[0].WorkflowID)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"go.uber.org/atomic"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+type (
+	// historyPrefetcher fetches history pages ahead of the consumer so the next page is usually
+	// available by the time the current one has been replayed. Page tokens are only known once the
+	// previous page has been fetched, so pages are fetched one after another by a single goroutine.
+	// The goroutine exits whenever the buffer is full, so an abandoned prefetcher never leaks it.
+	historyPrefetcher struct {
+		fetch func(nextPageToken []byte) (*s.History, []byte, error)
+		pages chan historyPageResult
+
+		// fetcherDone is closed when the current fetch goroutine exits, nil if none was started.
+		fetcherDone chan struct{}
+		stopped     atomic.Bool
+
+		// owned by the fetch goroutine while it runs
+		nextPageToken []byte
+		finished      bool
+	}
+
+	historyPageResult struct {
+		history       *s.History
+		nextPageToken []byte
+		err           error
+	}
+)
+
+func newHistoryPrefetcher(
+	fetch func(nextPageToken []byte) (*s.History, []byte, error),
+	firstPageToken []byte,
+	pages int,
+) *historyPrefetcher {
+	return &historyPrefetcher{
+		fetch:         fetch,
+		pages:         make(chan historyPageResult, pages),
+		nextPageToken: firstPageToken,
+	}
+}
+
+// next returns the page following the previously returned one. It must not be called again after it
+// returned an error or a nil page token.
+func (p *historyPrefetcher) next() (*s.History, []byte, error) {
+	p.ensureFetching()
+	page := <-p.pages
+	if page.err == nil && page.nextPageToken != nil {
+		// refill the slot that was just consumed
+		p.ensureFetching()
+	}
+	return page.history, page.nextPageToken, page.err
+}
+
+// stop prevents any page after the one currently being fetched from being fetched.
+func (p *historyPrefetcher) stop() {
+	p.stopped.Store(true)
+}
+
+func (p *historyPrefetcher) ensureFetching() {
+	if p.fetcherDone != nil {
+		select {
+		case <-p.fetcherDone:
+		default:
+			// still fetching
+			return
+		}
+	}
+	if p.finished || len(p.pages) == cap(p.pages) {
+		return
+	}
+
+	done := make(chan struct{})
+	p.fetcherDone = done
+	go func() {
+		defer close(done)
+		// only this goroutine sends to pages, so the send below never blocks
+		for len(p.pages) < cap(p.pages) && !p.stopped.Load() {
+			history, nextPageToken, err := p.fetch(p.nextPageToken)
+			p.pages <- historyPageResult{history: history, nextPageToken: nextPageToken, err: err}
+			if err != nil || nextPageToken == nil {
+				p.finished = true
+				return
+			}
+			p.nextPageToken = nextPageToken
+		}
+	}()
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+type fakeHistoryPages struct {
+	sync.Mutex
+	pages     int
+	fetched   []int
+	failPage  int
+	failCount int
+}
+
+func (f *fakeHistoryPages) fetch(token []byte) (*s.History, []byte, error) {
+	f.Lock()
+	defer f.Unlock()
+	page := 0
+	if token != nil {
+		page, _ = strconv.Atoi(string(token))
+	}
+	if page == f.failPage && f.failCount > 0 {
+		f.failCount--
+		return nil, nil, errors.New("fetch failed")
+	}
+	f.fetched = append(f.fetched, page)
+	history := &s.History{Events: []*s.HistoryEvent{{EventId: common.Int64Ptr(int64(page))}}}
+	if page == f.pages-1 {
+		return history, nil, nil
+	}
+	return history, []byte(strconv.Itoa(page + 1)), nil
+}
+
+func (f *fakeHistoryPages) fetchedCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.fetched)
+}
+
+func TestHistoryIterator_Prefetch(t *testing.T) {
+	pages := &fakeHistoryPages{pages: 5, failPage: -1}
+	iterator := &historyIteratorImpl{
+		iteratorFunc:  pages.fetch,
+		nextPageToken: []byte("1"),
+		prefetchPages: 2,
+	}
+
+	history, err := iterator.GetNextPage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), history.Events[0].GetEventId())
+	// pages 2 and 3 are fetched while page 1 is being processed
+	assert.Eventually(t, func() bool { return pages.fetchedCount() == 3 }, time.Second, time.Millisecond)
+
+	for page := 2; iterator.HasNextPage(); page++ {
+		history, err = iterator.GetNextPage()
+		require.NoError(t, err)
+		assert.Equal(t, int64(page), history.Events[0].GetEventId())
+	}
+	assert.Equal(t, []int{1, 2, 3, 4}, pages.fetched)
+	assert.Nil(t, iterator.prefetcher)
+}
+
+func TestHistoryIterator_PrefetchError(t *testing.T) {
+	pages := &fakeHistoryPages{pages: 4, failPage: 2, failCount: 1}
+	iterator := &historyIteratorImpl{
+		iteratorFunc:  pages.fetch,
+		nextPageToken: []byte("1"),
+		prefetchPages: 3,
+	}
+
+	history, err := iterator.GetNextPage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), history.Events[0].GetEventId())
+
+	_, err = iterator.GetNextPage()
+	assert.Error(t, err)
+	assert.True(t, iterator.HasNextPage())
+
+	// retried from the page that failed
+	history, err = iterator.GetNextPage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), history.Events[0].GetEventId())
+	history, err = iterator.GetNextPage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), history.Events[0].GetEventId())
+	assert.False(t, iterator.HasNextPage())
+}
+
+func TestHistoryIterator_PrefetchReset(t *testing.T) {
+	pages := &fakeHistoryPages{pages: 10, failPage: -1}
+	iterator := &historyIteratorImpl{
+		iteratorFunc:  pages.fetch,
+		nextPageToken: []byte("1"),
+		prefetchPages: 2,
+	}
+
+	_, err := iterator.GetNextPage()
+	require.NoError(t, err)
+	prefetcher := iterator.prefetcher
+	iterator.Reset()
+	assert.Nil(t, iterator.prefetcher)
+	assert.False(t, iterator.HasNextPage())
+	assert.True(t, prefetcher.stopped.Load())
+}
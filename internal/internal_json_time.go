@@ -0,0 +1,322 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DataConverterOptions.TimeFormat is applied to the JSON encoded by encoding/json, which formats time.Time values
+// as RFC 3339: the JSON is parsed into a jsonNode tree, keeping the order of object members, and the strings
+// corresponding to time.Time values, found by walking the value or type alongside the tree, are replaced.
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+type (
+	// jsonNode is a JSON value: jsonObject, []jsonNode, string, json.Number, bool or nil
+	jsonNode interface{}
+
+	jsonObject []jsonMember
+
+	jsonMember struct {
+		key   string
+		value jsonNode
+	}
+
+	// jsonField is an encoded field of a struct
+	jsonField struct {
+		name  string
+		index []int
+	}
+)
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(member.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o jsonObject) member(name string) (int, bool) {
+	for i, member := range o {
+		if member.key == name {
+			return i, true
+		}
+	}
+	// encoding/json matches the names of the fields case-insensitively
+	for i, member := range o {
+		if strings.EqualFold(member.key, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func parseJSONNode(data []byte) (jsonNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return readJSONNode(dec)
+}
+
+func readJSONNode(dec *json.Decoder) (jsonNode, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := jsonObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, jsonMember{key: key.(string), value: value})
+		}
+		_, err = dec.Token()
+		return object, err
+	case json.Delim('['):
+		array := []jsonNode{}
+		for dec.More() {
+			value, err := readJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = dec.Token()
+		return array, err
+	case json.Delim('}'), json.Delim(']'):
+		return nil, errors.New("unexpected end of JSON value")
+	}
+	return token, nil
+}
+
+// formatTimes returns the JSON encoding of value, with its time.Time values formatted with layout.
+func formatTimes(value interface{}, layout string) (jsonNode, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	node, err := parseJSONNode(data)
+	if err != nil {
+		return nil, err
+	}
+	return formatTimeNode(reflect.ValueOf(value), node, layout), nil
+}
+
+func formatTimeNode(v reflect.Value, node jsonNode, layout string) jsonNode {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return node
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return node
+	}
+	if v.Type() == timeType {
+		if _, ok := node.(string); ok {
+			return v.Interface().(time.Time).Format(layout)
+		}
+		return node
+	}
+	if v.Type().Implements(marshalerType) || reflect.PtrTo(v.Type()).Implements(marshalerType) {
+		return node
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		object, ok := node.(jsonObject)
+		if !ok {
+			return node
+		}
+		for _, field := range jsonFields(v.Type()) {
+			fv, err := v.FieldByIndexErr(field.index)
+			if err != nil {
+				continue // nil embedded pointer
+			}
+			if i, ok := object.member(field.name); ok {
+				object[i].value = formatTimeNode(fv, object[i].value, layout)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		array, ok := node.([]jsonNode)
+		if !ok {
+			return node
+		}
+		for i := range array {
+			if i < v.Len() {
+				array[i] = formatTimeNode(v.Index(i), array[i], layout)
+			}
+		}
+	case reflect.Map:
+		object, ok := node.(jsonObject)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return node
+		}
+		for i, member := range object {
+			if value := v.MapIndex(reflect.ValueOf(member.key).Convert(v.Type().Key())); value.IsValid() {
+				object[i].value = formatTimeNode(value, member.value, layout)
+			}
+		}
+	}
+	return node
+}
+
+// parseTimes returns data with the strings decoded into time.Time values of t, formatted with layout, converted to
+// RFC 3339.
+func parseTimes(data []byte, t reflect.Type, layout string) ([]byte, error) {
+	node, err := parseJSONNode(data)
+	if err != nil {
+		return nil, err
+	}
+	node, err = parseTimeNode(t, node, layout)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(node)
+}
+
+func parseTimeNode(t reflect.Type, node jsonNode, layout string) (jsonNode, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		s, ok := node.(string)
+		if !ok {
+			return node, nil
+		}
+		parsed, err := time.Parse(layout, s)
+		if err != nil {
+			return nil, err
+		}
+		return parsed.Format(time.RFC3339Nano), nil
+	}
+	if t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return node, nil
+	}
+	var err error
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := node.(jsonObject)
+		if !ok {
+			return node, nil
+		}
+		for _, field := range jsonFields(t) {
+			if i, ok := object.member(field.name); ok {
+				if object[i].value, err = parseTimeNode(fieldType(t, field.index), object[i].value, layout); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		array, ok := node.([]jsonNode)
+		if !ok {
+			return node, nil
+		}
+		for i := range array {
+			if array[i], err = parseTimeNode(t.Elem(), array[i], layout); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Map:
+		object, ok := node.(jsonObject)
+		if !ok {
+			return node, nil
+		}
+		for i := range object {
+			if object[i].value, err = parseTimeNode(t.Elem(), object[i].value, layout); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return node, nil
+}
+
+// jsonFields returns the fields of the struct encoded by encoding/json, with the fields of embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, embedded := range jsonFields(ft) {
+				fields = append(fields, jsonField{name: embedded.name, index: append([]int{i}, embedded.index...)})
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name: name, index: []int{i}})
+	}
+	return fields
+}
+
+func fieldType(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
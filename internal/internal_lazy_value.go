@@ -0,0 +1,239 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const defaultLazyValueChunkSize = 1 << 20 // 1MB
+
+type (
+	// BlobSink stores the chunks of large activity results outside of the workflow history.
+	// Data written for a key must never change: chunks are content addressed and readers rely on it
+	// to verify the chunks they read.
+	BlobSink interface {
+		// PutBlob stores data under key. It may be called again with the same key and data when an activity is retried.
+		PutBlob(ctx context.Context, key string, data []byte) error
+		// GetBlob returns the data stored under key.
+		GetBlob(ctx context.Context, key string) ([]byte, error)
+	}
+
+	// BlobChunk references a chunk of a LazyValue stored in a BlobSink.
+	BlobChunk struct {
+		Key      string `json:"key"`
+		Size     int    `json:"size"`
+		Checksum string `json:"checksum"`
+	}
+
+	// LazyValue is the manifest of a large value written to a BlobSink by a ChunkWriter. It is small and serializable:
+	// an activity returns it instead of the value, the workflow passes it to the activities consuming the value, and
+	// they read the chunks only when they need them. Workflows must not read it, as the BlobSink is not deterministic.
+	LazyValue struct {
+		Chunks []BlobChunk `json:"chunks"`
+		Size   int64       `json:"size"`
+	}
+
+	// ChunkWriterOptions configures a ChunkWriter.
+	ChunkWriterOptions struct {
+		// Optional: maximum size of the chunks written to the BlobSink.
+		// default: 1MB
+		ChunkSize int
+
+		// Optional: prefix of the keys of the chunks, which are followed by "/" and the SHA-256 of the chunk.
+		// default: the workflow ID, run ID and activity ID separated by "/" when called from an activity, "lazyvalue" otherwise
+		KeyPrefix string
+	}
+
+	// ChunkWriter streams a large value to a BlobSink in chunks, and returns the LazyValue referencing them when closed.
+	// It is not safe for concurrent use.
+	ChunkWriter struct {
+		ctx     context.Context
+		sink    BlobSink
+		options ChunkWriterOptions
+		buf     []byte
+		value   LazyValue
+		err     error
+		closed  bool
+	}
+
+	lazyValueReader struct {
+		ctx   context.Context
+		sink  BlobSink
+		value LazyValue
+		next  int
+		chunk *bytes.Reader
+	}
+)
+
+var (
+	// ErrChunkWriterClosed is returned when writing to a closed ChunkWriter.
+	ErrChunkWriterClosed = errors.New("chunk writer is closed")
+
+	// ErrBlobChecksumMismatch is returned when a chunk read from a BlobSink does not match its LazyValue.
+	ErrBlobChecksumMismatch = errors.New("blob checksum mismatch")
+)
+
+// NewChunkWriter creates a ChunkWriter writing to sink. ctx is passed to the BlobSink, and is the activity context
+// when the key prefix is not set.
+func NewChunkWriter(ctx context.Context, sink BlobSink, options ChunkWriterOptions) *ChunkWriter {
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = defaultLazyValueChunkSize
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "lazyvalue"
+		if HasActivityInfo(ctx) {
+			info := GetActivityInfo(ctx)
+			options.KeyPrefix = info.WorkflowExecution.ID + "/" + info.WorkflowExecution.RunID + "/" + info.ActivityID
+		}
+	}
+	return &ChunkWriter{ctx: ctx, sink: sink, options: options, value: LazyValue{Chunks: []BlobChunk{}}}
+}
+
+// Write buffers p and writes the full chunks to the BlobSink. The first error is returned by every later call.
+func (w *ChunkWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrChunkWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		n := w.options.ChunkSize - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(w.buf) == w.options.ChunkSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the last chunk and returns the LazyValue referencing the chunks written.
+func (w *ChunkWriter) Close() (LazyValue, error) {
+	if w.closed {
+		return LazyValue{}, ErrChunkWriterClosed
+	}
+	w.closed = true
+	if w.err != nil {
+		return LazyValue{}, w.err
+	}
+	if len(w.buf) > 0 {
+		if err := w.flush(); err != nil {
+			return LazyValue{}, err
+		}
+	}
+	return w.value, nil
+}
+
+func (w *ChunkWriter) flush() error {
+	sum := sha256.Sum256(w.buf)
+	chunk := BlobChunk{
+		Key:      w.options.KeyPrefix + "/" + hex.EncodeToString(sum[:]),
+		Size:     len(w.buf),
+		Checksum: hex.EncodeToString(sum[:]),
+	}
+	if err := w.sink.PutBlob(w.ctx, chunk.Key, w.buf); err != nil {
+		w.err = fmt.Errorf("unable to write chunk %v: %w", len(w.value.Chunks), err)
+		return w.err
+	}
+	w.value.Chunks = append(w.value.Chunks, chunk)
+	w.value.Size += int64(chunk.Size)
+	// the sink may keep the slice, so a new buffer is used for the next chunk
+	w.buf = nil
+	return nil
+}
+
+// WriteLazyValue streams r to sink and returns the LazyValue referencing the chunks written.
+func WriteLazyValue(ctx context.Context, sink BlobSink, r io.Reader, options ChunkWriterOptions) (LazyValue, error) {
+	w := NewChunkWriter(ctx, sink, options)
+	if _, err := io.Copy(w, r); err != nil {
+		return LazyValue{}, err
+	}
+	return w.Close()
+}
+
+// EncodeLazyValue encodes value with the data converter of the activity and writes it to sink.
+func EncodeLazyValue(ctx context.Context, sink BlobSink, value interface{}, options ChunkWriterOptions) (LazyValue, error) {
+	data, err := getDataConverterFromActivityCtx(ctx).ToData(value)
+	if err != nil {
+		return LazyValue{}, fmt.Errorf("unable to encode value: %w", err)
+	}
+	return WriteLazyValue(ctx, sink, bytes.NewReader(data), options)
+}
+
+// Open returns a reader of the value, which reads the chunks from sink one at a time as they are consumed and
+// verifies their checksum.
+func (v LazyValue) Open(ctx context.Context, sink BlobSink) io.Reader {
+	return &lazyValueReader{ctx: ctx, sink: sink, value: v}
+}
+
+// Bytes reads the whole value from sink.
+func (v LazyValue) Bytes(ctx context.Context, sink BlobSink) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, v.Size))
+	if _, err := io.Copy(buf, v.Open(ctx, sink)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode reads the value written by EncodeLazyValue from sink and decodes it into valuePtr with the data converter
+// of the activity.
+func (v LazyValue) Decode(ctx context.Context, sink BlobSink, valuePtr interface{}) error {
+	data, err := v.Bytes(ctx, sink)
+	if err != nil {
+		return err
+	}
+	return getDataConverterFromActivityCtx(ctx).FromData(data, valuePtr)
+}
+
+func (r *lazyValueReader) Read(p []byte) (int, error) {
+	for r.chunk == nil || r.chunk.Len() == 0 {
+		if r.next == len(r.value.Chunks) {
+			return 0, io.EOF
+		}
+		chunk := r.value.Chunks[r.next]
+		data, err := r.sink.GetBlob(r.ctx, chunk.Key)
+		if err != nil {
+			return 0, fmt.Errorf("unable to read chunk %v: %w", r.next, err)
+		}
+		sum := sha256.Sum256(data)
+		if len(data) != chunk.Size || hex.EncodeToString(sum[:]) != chunk.Checksum {
+			return 0, fmt.Errorf("chunk %v with key %v: %w", r.next, chunk.Key, ErrBlobChecksumMismatch)
+		}
+		r.chunk = bytes.NewReader(data)
+		r.next++
+	}
+	return r.chunk.Read(p)
+}
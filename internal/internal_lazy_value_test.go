@@ -0,0 +1,179 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryBlobSink struct {
+	sync.Mutex
+	blobs map[string][]byte
+	puts  int
+	err   error
+}
+
+func newMemoryBlobSink() *memoryBlobSink {
+	return &memoryBlobSink{blobs: map[string][]byte{}}
+}
+
+func (s *memoryBlobSink) PutBlob(ctx context.Context, key string, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.puts++
+	s.blobs[key] = data
+	return nil
+}
+
+func (s *memoryBlobSink) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func TestChunkWriter(t *testing.T) {
+	sink := newMemoryBlobSink()
+	w := NewChunkWriter(context.Background(), sink, ChunkWriterOptions{ChunkSize: 4})
+	n, err := w.Write([]byte("abcdef"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	_, err = w.Write([]byte("ghabcd"))
+	require.NoError(t, err)
+	value, err := w.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(12), value.Size)
+	require.Len(t, value.Chunks, 3)
+	assert.Equal(t, []int{4, 4, 4}, []int{value.Chunks[0].Size, value.Chunks[1].Size, value.Chunks[2].Size})
+	assert.True(t, strings.HasPrefix(value.Chunks[0].Key, "lazyvalue/"))
+	assert.Equal(t, value.Chunks[0], value.Chunks[2], "identical chunks are content addressed")
+	assert.Len(t, sink.blobs, 2)
+
+	data, err := value.Bytes(context.Background(), sink)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefghabcd", string(data))
+
+	_, err = w.Write([]byte("x"))
+	assert.ErrorIs(t, err, ErrChunkWriterClosed)
+	_, err = w.Close()
+	assert.ErrorIs(t, err, ErrChunkWriterClosed)
+}
+
+func TestChunkWriterEmpty(t *testing.T) {
+	sink := newMemoryBlobSink()
+	value, err := NewChunkWriter(context.Background(), sink, ChunkWriterOptions{}).Close()
+	require.NoError(t, err)
+	assert.Zero(t, value.Size)
+	assert.Zero(t, sink.puts)
+
+	data, err := value.Bytes(context.Background(), sink)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestChunkWriterSinkError(t *testing.T) {
+	sink := newMemoryBlobSink()
+	sink.err = errors.New("unavailable")
+	w := NewChunkWriter(context.Background(), sink, ChunkWriterOptions{ChunkSize: 2})
+	_, err := w.Write([]byte("abc"))
+	assert.ErrorContains(t, err, "unable to write chunk 0: unavailable")
+	sink.err = nil
+	_, err = w.Write([]byte("d"))
+	assert.ErrorContains(t, err, "unavailable", "errors are sticky")
+	_, err = w.Close()
+	assert.ErrorContains(t, err, "unavailable")
+}
+
+func TestLazyValueChecksumMismatch(t *testing.T) {
+	sink := newMemoryBlobSink()
+	value, err := WriteLazyValue(context.Background(), sink, strings.NewReader("abcdef"), ChunkWriterOptions{ChunkSize: 3})
+	require.NoError(t, err)
+	sink.blobs[value.Chunks[1].Key] = []byte("xyz")
+
+	r := value.Open(context.Background(), sink)
+	buf := make([]byte, 3)
+	_, err = r.Read(buf)
+	require.NoError(t, err, "the first chunk is read before the second one is fetched")
+	assert.Equal(t, "abc", string(buf))
+	_, err = r.Read(buf)
+	assert.ErrorIs(t, err, ErrBlobChecksumMismatch)
+}
+
+func TestLazyValueBetweenActivities(t *testing.T) {
+	sink := newMemoryBlobSink()
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	produce := func(ctx context.Context) (LazyValue, error) {
+		return EncodeLazyValue(ctx, sink, map[string][]byte{"data": large}, ChunkWriterOptions{ChunkSize: 1024})
+	}
+	consume := func(ctx context.Context, value LazyValue) (int, error) {
+		var decoded map[string][]byte
+		if err := value.Decode(ctx, sink, &decoded); err != nil {
+			return 0, err
+		}
+		return len(decoded["data"]), nil
+	}
+	workflowFn := func(ctx Context) (int, error) {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		var value LazyValue
+		if err := ExecuteActivity(ctx, produce).Get(ctx, &value); err != nil {
+			return 0, err
+		}
+		var size int
+		err := ExecuteActivity(ctx, consume, value).Get(ctx, &size)
+		return size, err
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(produce)
+	env.RegisterActivity(consume)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var size int
+	require.NoError(t, env.GetWorkflowResult(&size))
+	assert.Equal(t, len(large), size)
+
+	require.NotEmpty(t, sink.blobs)
+	for key := range sink.blobs {
+		assert.True(t, strings.HasPrefix(key, defaultTestWorkflowID+"/"+defaultTestRunID+"/"), key)
+	}
+}
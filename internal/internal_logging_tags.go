@@ -42,9 +42,17 @@ const (
 	tagVisibilityQuery             = "VisibilityQuery"
 	tagPanicError                  = "PanicError"
 	tagPanicStack                  = "PanicStack"
+	tagWorkflowArgs                = "WorkflowArgs"
 	causeTag                       = "pollerrorcause"
 	tagWorkflowRuntimeLength       = "workflowruntimelength"
 	tagNonDeterminismDetectionType = "NonDeterminismDetectionType"
+	tagHeartbeatDetailsSize        = "HeartbeatDetailsSize"
+	tagHeartbeatDetailsLimit       = "HeartbeatDetailsLimit"
+	tagOperation                   = "Operation"
+	tagLatency                     = "Latency"
+	tagRequest                     = "Request"
+	tagResponse                    = "Response"
+	tagArgumentCount               = "ArgumentCount"
 )
 
 type nonDeterminismDetectionType string
@@ -0,0 +1,237 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxLoggedPayloadSize = 4 * 1024
+	redactedValue               = "<redacted>"
+)
+
+type (
+	// PayloadLoggerOptions configure a PayloadLogger.
+	PayloadLoggerOptions struct {
+		// Required: Logger the requests and responses are logged to, at info level.
+		Logger *zap.Logger
+
+		// Optional: Enabled is the initial state of the logger, see PayloadLogger.SetEnabled.
+		// default: false
+		Enabled bool
+
+		// Optional: SampleRate is the fraction of the service calls which are logged, between 0 and 1.
+		// default: 0, which logs every call
+		SampleRate float64
+
+		// Optional: Operations limits the logged calls to these service operations, e.g. "PollForDecisionTask".
+		// default: all operations
+		Operations []string
+
+		// Optional: MaxSize is the maximum size of the logged request and response, each is truncated beyond it.
+		// default: 4KB
+		MaxSize int
+
+		// Optional: IncludePayloads logs the content of the binary fields, i.e. the encoded workflow and activity
+		// inputs, results, details, memos, headers and task tokens, instead of their size. They may contain
+		// sensitive data.
+		// default: false
+		IncludePayloads bool
+
+		// Optional: RedactFields are the names of further fields whose values are replaced by "<redacted>", e.g.
+		// "identity". The names are the ones of the logged JSON, and are matched in any request or response.
+		RedactFields []string
+	}
+
+	// PayloadLogger logs sampled requests and responses of the service calls of the clients and workers it is set
+	// on, see ClientOptions.PayloadLogger and WorkerOptions.PayloadLogger. It is meant to diagnose encoding or
+	// server compatibility issues, and can be enabled and disabled at runtime.
+	PayloadLogger struct {
+		logger          *zap.Logger
+		operations      map[string]bool
+		maxSize         int
+		includePayloads bool
+		redactor        *fieldRedactor
+
+		enabled    *atomic.Bool
+		sampleRate *atomic.Float64
+
+		randLock sync.Mutex
+		rand     *rand.Rand
+	}
+)
+
+// NewPayloadLogger docs are in the public API to prevent duplication: [go.uber.org/cadence/client.NewPayloadLogger]
+func NewPayloadLogger(options PayloadLoggerOptions) *PayloadLogger {
+	l := &PayloadLogger{
+		logger:          options.Logger,
+		maxSize:         options.MaxSize,
+		includePayloads: options.IncludePayloads,
+		redactor:        newFieldRedactor(options.RedactFields),
+		enabled:         atomic.NewBool(options.Enabled),
+		sampleRate:      atomic.NewFloat64(0),
+		rand:            rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if l.logger == nil {
+		l.logger = zap.NewNop()
+	}
+	if l.maxSize <= 0 {
+		l.maxSize = defaultMaxLoggedPayloadSize
+	}
+	if len(options.Operations) > 0 {
+		l.operations = make(map[string]bool, len(options.Operations))
+		for _, operation := range options.Operations {
+			l.operations[operation] = true
+		}
+	}
+	l.SetSampleRate(options.SampleRate)
+	return l
+}
+
+// SetEnabled enables or disables logging, e.g. from a debug endpoint of the service.
+func (l *PayloadLogger) SetEnabled(enabled bool) {
+	l.enabled.Store(enabled)
+}
+
+// Enabled returns whether calls are logged.
+func (l *PayloadLogger) Enabled() bool {
+	return l.enabled.Load()
+}
+
+// SetSampleRate changes the fraction of the logged calls, 0 or values above 1 log every call.
+func (l *PayloadLogger) SetSampleRate(rate float64) {
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+	l.sampleRate.Store(rate)
+}
+
+// Sample implements payloadlog.Tap.
+func (l *PayloadLogger) Sample(operation string) bool {
+	if !l.enabled.Load() || (l.operations != nil && !l.operations[operation]) {
+		return false
+	}
+	rate := l.sampleRate.Load()
+	if rate >= 1 {
+		return true
+	}
+	l.randLock.Lock()
+	defer l.randLock.Unlock()
+	return l.rand.Float64() < rate
+}
+
+// Log implements payloadlog.Tap.
+func (l *PayloadLogger) Log(operation string, request, response interface{}, err error, latency time.Duration) {
+	fields := []zap.Field{
+		zap.String(tagOperation, operation),
+		zap.Duration(tagLatency, latency),
+		zap.String(tagRequest, l.format(request)),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	} else {
+		fields = append(fields, zap.String(tagResponse, l.format(response)))
+	}
+	l.logger.Info("Cadence service call", fields...)
+}
+
+// format converts the thrift struct to JSON, with binary fields and redacted fields replaced, truncated to maxSize.
+func (l *PayloadLogger) format(value interface{}) string {
+	data, err := json.Marshal(l.redactor.redact(l.loggable(reflect.ValueOf(value))))
+	if err != nil {
+		return fmt.Sprintf("<failed to format: %v>", err)
+	}
+	if len(data) > l.maxSize {
+		return fmt.Sprintf("%s...<truncated %d bytes>", data[:l.maxSize], len(data)-l.maxSize)
+	}
+	return string(data)
+}
+
+func (l *PayloadLogger) loggable(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return l.loggable(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if l.includePayloads {
+				return string(v.Bytes())
+			}
+			return fmt.Sprintf("<%d bytes>", v.Len())
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = l.loggable(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		entries := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entries[fmt.Sprint(iter.Key().Interface())] = l.loggable(iter.Value())
+		}
+		return entries
+	case reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			value := v.Field(i)
+			if (value.Kind() == reflect.Ptr || value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.IsNil() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				name = field.Name
+			}
+			fields[name] = l.loggable(value)
+		}
+		return fields
+	default:
+		if stringer, ok := v.Interface().(fmt.Stringer); ok && v.Kind() == reflect.Int32 {
+			// thrift enums
+			return stringer.String()
+		}
+		return v.Interface()
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func TestPayloadLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := NewPayloadLogger(PayloadLoggerOptions{Logger: zap.New(core), RedactFields: []string{"identity"}})
+
+	assert.False(t, logger.Enabled())
+	assert.False(t, logger.Sample("StartWorkflowExecution"), "disabled by default")
+	logger.SetEnabled(true)
+	assert.True(t, logger.Sample("StartWorkflowExecution"))
+
+	request := &shared.StartWorkflowExecutionRequest{
+		Domain:       common.StringPtr("orders"),
+		WorkflowId:   common.StringPtr("order-1"),
+		WorkflowType: &shared.WorkflowType{Name: common.StringPtr("OrderWorkflow")},
+		Input:        []byte(`"secret"`),
+		Identity:     common.StringPtr("host-1"),
+		Memo:         &shared.Memo{Fields: map[string][]byte{"customer": []byte("alice")}},
+	}
+	logger.Log("StartWorkflowExecution", request, &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr("run-1")}, nil, time.Millisecond)
+	logger.Log("SignalWorkflowExecution", &shared.SignalWorkflowExecutionRequest{}, nil, errors.New("unavailable"), time.Millisecond)
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "StartWorkflowExecution", fields[tagOperation])
+	assert.Equal(t, time.Millisecond, fields[tagLatency])
+	assert.JSONEq(t, `{
+		"domain": "orders",
+		"workflowId": "order-1",
+		"workflowType": {"name": "OrderWorkflow"},
+		"input": "<8 bytes>",
+		"identity": "<redacted>",
+		"memo": {"fields": {"customer": "<5 bytes>"}}
+	}`, fields[tagRequest].(string))
+	assert.JSONEq(t, `{"runId": "run-1"}`, fields[tagResponse].(string))
+	fields = entries[1].ContextMap()
+	assert.Equal(t, "unavailable", fields["error"])
+	assert.NotContains(t, fields, tagResponse)
+}
+
+func TestPayloadLoggerOptions(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := NewPayloadLogger(PayloadLoggerOptions{
+		Logger:          zap.New(core),
+		Enabled:         true,
+		Operations:      []string{"RespondActivityTaskCompleted"},
+		MaxSize:         32,
+		IncludePayloads: true,
+	})
+	assert.False(t, logger.Sample("PollForActivityTask"))
+	assert.True(t, logger.Sample("RespondActivityTaskCompleted"))
+
+	logger.Log("RespondActivityTaskCompleted", &shared.RespondActivityTaskCompletedRequest{Result: []byte(strings.Repeat("a", 100))}, nil, nil, 0)
+	request := logs.AllUntimed()[0].ContextMap()[tagRequest].(string)
+	assert.Equal(t, `{"result":"`+strings.Repeat("a", 21)+`...<truncated 81 bytes>`, request)
+
+	logger.SetSampleRate(0.5)
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		if logger.Sample("RespondActivityTaskCompleted") {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 500, sampled, 150)
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	// PendingOperations is the result of the QueryTypePendingOperations query. It lists what the workflow is
+	// currently waiting for, as seen by the workflow code of the worker answering the query.
+	PendingOperations struct {
+		Activities     []PendingActivity
+		Timers         []PendingTimer
+		ChildWorkflows []PendingChildWorkflow
+	}
+
+	// PendingActivity is an activity the workflow has scheduled and not yet received the result of.
+	PendingActivity struct {
+		ActivityID   string
+		ActivityType string
+		// IsLocal is true for local activities.
+		IsLocal bool
+		// ScheduledTime is the workflow time the activity was scheduled at.
+		ScheduledTime time.Time
+		// Started is true once the activity was started. The server records the start of activities with a retry
+		// policy only when they close, so Started stays false for them.
+		Started bool
+		// Attempt of the latest start of the activity, starting from 0. For activities with a retry policy, use
+		// DescribeWorkflowExecution to get the current attempt.
+		Attempt int32
+	}
+
+	// PendingTimer is a timer the workflow has started and which has neither fired nor been canceled.
+	PendingTimer struct {
+		TimerID string
+		// FireTime is the workflow time the timer fires at, the server may fire it up to a second later.
+		FireTime time.Time
+	}
+
+	// PendingChildWorkflow is a child workflow the workflow has started and not yet received the result of.
+	PendingChildWorkflow struct {
+		WorkflowID   string
+		WorkflowType string
+		// RunID is empty until the child workflow has started.
+		RunID string
+	}
+)
+
+func (wc *workflowEnvironmentImpl) getPendingOperations() *PendingOperations {
+	result := &PendingOperations{
+		Activities:     []PendingActivity{},
+		Timers:         []PendingTimer{},
+		ChildWorkflows: []PendingChildWorkflow{},
+	}
+	for e := wc.decisionsHelper.orderedDecisions.Front(); e != nil; e = e.Next() {
+		switch d := e.Value.(type) {
+		case *activityDecisionStateMachine:
+			if activity, ok := d.getData().(*scheduledActivity); ok && !activity.handled {
+				result.Activities = append(result.Activities, PendingActivity{
+					ActivityID:    d.attributes.GetActivityId(),
+					ActivityType:  d.attributes.ActivityType.GetName(),
+					ScheduledTime: activity.scheduledTime,
+					Started:       activity.started,
+					Attempt:       activity.attempt,
+				})
+			}
+		case *timerDecisionStateMachine:
+			if timer, ok := d.getData().(*scheduledTimer); ok && !timer.handled {
+				result.Timers = append(result.Timers, PendingTimer{
+					TimerID:  d.attributes.GetTimerId(),
+					FireTime: timer.fireTime,
+				})
+			}
+		case *childWorkflowDecisionStateMachine:
+			if child, ok := d.getData().(*scheduledChildWorkflow); ok && !child.handled {
+				result.ChildWorkflows = append(result.ChildWorkflows, PendingChildWorkflow{
+					WorkflowID:   d.attributes.GetWorkflowId(),
+					WorkflowType: d.attributes.WorkflowType.GetName(),
+					RunID:        child.runID,
+				})
+			}
+		}
+	}
+
+	localActivities := make([]PendingActivity, 0, len(wc.pendingLaTasks))
+	for activityID, task := range wc.pendingLaTasks {
+		_, unstarted := wc.unstartedLaTasks[activityID]
+		task.Lock()
+		localActivities = append(localActivities, PendingActivity{
+			ActivityID:    activityID,
+			ActivityType:  task.params.ActivityType,
+			IsLocal:       true,
+			ScheduledTime: task.params.ScheduledTime,
+			Started:       !unstarted,
+			Attempt:       task.attempt,
+		})
+		task.Unlock()
+	}
+	sort.Slice(localActivities, func(i, j int) bool {
+		if !localActivities[i].ScheduledTime.Equal(localActivities[j].ScheduledTime) {
+			return localActivities[i].ScheduledTime.Before(localActivities[j].ScheduledTime)
+		}
+		return localActivities[i].ActivityID < localActivities[j].ActivityID
+	})
+	result.Activities = append(result.Activities, localActivities...)
+	return result
+}
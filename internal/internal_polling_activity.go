@@ -0,0 +1,87 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// activityNotReadyReason is the reason of ErrActivityNotReady, it is never a non-retriable reason of polling
+// activities.
+const activityNotReadyReason = "cadence:ActivityNotReady"
+
+// ErrActivityNotReady docs are in the public API to prevent duplication: [go.uber.org/cadence/activity.ErrNotReady]
+var ErrActivityNotReady = NewCustomError(activityNotReadyReason)
+
+// PollOptions docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.PollOptions]
+type PollOptions struct {
+	// Interval between the attempts of the activity. Required.
+	Interval time.Duration
+
+	// BackoffCoefficient multiplies the interval after each attempt, up to MaximumInterval.
+	// Default is 1, the activity is polled at a fixed interval.
+	BackoffCoefficient float64
+
+	// MaximumInterval caps the interval when BackoffCoefficient is larger than 1.
+	// Default is 100x of Interval.
+	MaximumInterval time.Duration
+
+	// Timeout is how long the activity is polled for, including all its attempts and intervals. Required.
+	Timeout time.Duration
+
+	// NonRetriableErrorReasons stop polling when the activity fails with one of these reasons. Other errors are
+	// retried like ErrActivityNotReady.
+	NonRetriableErrorReasons []string
+}
+
+// ExecutePollingActivity docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.ExecutePollingActivity]
+func ExecutePollingActivity(ctx Context, options PollOptions, activity interface{}, args ...interface{}) Future {
+	policy := RetryPolicy{
+		InitialInterval:    options.Interval,
+		BackoffCoefficient: options.BackoffCoefficient,
+		MaximumInterval:    options.MaximumInterval,
+		ExpirationInterval: options.Timeout,
+	}
+	if policy.BackoffCoefficient == 0 {
+		policy.BackoffCoefficient = 1
+	}
+	for _, reason := range options.NonRetriableErrorReasons {
+		if reason != activityNotReadyReason {
+			policy.NonRetriableErrorReasons = append(policy.NonRetriableErrorReasons, reason)
+		}
+	}
+	if err := ValidateRetryPolicy(&policy); err != nil {
+		future, settable := NewFuture(ctx)
+		settable.SetError(err)
+		return future
+	}
+
+	ctx = WithRetryPolicy(ctx, policy)
+	ctx = WithScheduleToCloseTimeout(ctx, options.Timeout)
+	return ExecuteActivity(ctx, activity, args...)
+}
+
+// IsActivityNotReadyError docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.IsActivityNotReadyError]
+func IsActivityNotReadyError(err error) bool {
+	var customErr *CustomError
+	return errors.As(err, &customErr) && customErr.Reason() == activityNotReadyReason
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/internal/common/testlogger"
+)
+
+func TestExecutePollingActivity(t *testing.T) {
+	tests := map[string]struct {
+		options          PollOptions
+		readyAfter       int
+		activityErr      error
+		expectedAttempts int
+		expectedResult   string
+		expectedErr      func(t *testing.T, err error)
+	}{
+		"ready": {
+			options:          PollOptions{Interval: time.Minute, Timeout: time.Hour},
+			readyAfter:       3,
+			expectedAttempts: 4,
+			expectedResult:   "settled",
+		},
+		"timeout": {
+			options:          PollOptions{Interval: time.Minute, Timeout: 5 * time.Minute},
+			readyAfter:       10,
+			expectedAttempts: 6,
+			expectedErr: func(t *testing.T, err error) {
+				assert.True(t, IsActivityNotReadyError(err), "%v", err)
+			},
+		},
+		"non retriable error": {
+			options: PollOptions{
+				Interval:                 time.Minute,
+				Timeout:                  time.Hour,
+				NonRetriableErrorReasons: []string{"PaymentDeclined", activityNotReadyReason},
+			},
+			readyAfter:       10,
+			activityErr:      NewCustomError("PaymentDeclined"),
+			expectedAttempts: 1,
+			expectedErr: func(t *testing.T, err error) {
+				var customErr *CustomError
+				require.ErrorAs(t, err, &customErr)
+				assert.Equal(t, "PaymentDeclined", customErr.Reason())
+			},
+		},
+		"invalid options": {
+			options: PollOptions{Interval: time.Minute},
+			expectedErr: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "neither MaximumAttempts nor ExpirationInterval is set")
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := WorkflowTestSuite{}
+			s.SetLogger(testlogger.NewZap(t))
+			env := s.NewTestWorkflowEnvironment()
+
+			attempts := 0
+			env.RegisterActivityWithOptions(func(ctx context.Context) (string, error) {
+				attempts++
+				if attempts > tt.readyAfter {
+					return "settled", nil
+				}
+				if tt.activityErr != nil {
+					return "", tt.activityErr
+				}
+				return "", ErrActivityNotReady
+			}, RegisterActivityOptions{Name: "poll"})
+			env.RegisterWorkflowWithOptions(func(ctx Context) (string, error) {
+				ctx = WithActivityOptions(ctx, ActivityOptions{
+					ScheduleToStartTimeout: time.Minute,
+					StartToCloseTimeout:    10 * time.Second,
+				})
+				var status string
+				err := ExecutePollingActivity(ctx, tt.options, "poll").Get(ctx, &status)
+				return status, err
+			}, RegisterWorkflowOptions{Name: "polling"})
+
+			env.ExecuteWorkflow("polling")
+			require.True(t, env.IsWorkflowCompleted())
+			assert.Equal(t, tt.expectedAttempts, attempts)
+			if tt.expectedErr != nil {
+				tt.expectedErr(t, env.GetWorkflowError())
+				return
+			}
+			require.NoError(t, env.GetWorkflowError())
+			var status string
+			require.NoError(t, env.GetWorkflowResult(&status))
+			assert.Equal(t, tt.expectedResult, status)
+		})
+	}
+}
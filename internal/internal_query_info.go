@@ -0,0 +1,36 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+const queryInfoContextKey contextKey = "queryInfo"
+
+// QueryInfo describes the query a query handler is answering, see GetQueryInfo.
+type QueryInfo struct {
+	QueryType string
+}
+
+// GetQueryInfo returns the query answered by the query handler which received ctx as its first parameter, or nil if
+// ctx was not passed to a query handler. The context of a query handler is the context passed to SetQueryHandler, so
+// it carries the values set on it and the ones extracted from the workflow headers by the context propagators. A query
+// has neither a deadline nor headers of its own.
+func GetQueryInfo(ctx Context) *QueryInfo {
+	info, _ := ctx.Value(queryInfoContextKey).(*QueryInfo)
+	return info
+}
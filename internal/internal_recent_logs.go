@@ -0,0 +1,140 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// RecentLogEntry is a log entry of the workflow logger returned by the QueryTypeRecentLogs query.
+	RecentLogEntry struct {
+		Time    time.Time
+		Level   string
+		Message string
+		Fields  map[string]interface{}
+	}
+
+	// recentLogs is a ring buffer of the latest log entries of a workflow execution
+	recentLogs struct {
+		sync.Mutex
+		entries []RecentLogEntry
+		next    int
+		full    bool
+	}
+
+	// recentLogsCore is a zapcore.Core writing to recentLogs, teed with the core of the workflow logger
+	recentLogsCore struct {
+		zapcore.LevelEnabler
+		fields []zapcore.Field
+		logs   *recentLogs
+	}
+)
+
+var errRecentLogsDisabled = errors.New("recent logs are not captured by this worker, set worker.Options.WorkflowLogBufferSize to enable them")
+
+func newRecentLogs(size int) *recentLogs {
+	return &recentLogs{entries: make([]RecentLogEntry, size)}
+}
+
+func (l *recentLogs) add(entry RecentLogEntry) {
+	l.Lock()
+	defer l.Unlock()
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// last returns up to limit latest entries, oldest first. A limit of 0 returns all entries.
+func (l *recentLogs) last(limit int) []RecentLogEntry {
+	l.Lock()
+	defer l.Unlock()
+	var result []RecentLogEntry
+	if l.full {
+		result = append(result, l.entries[l.next:]...)
+	}
+	result = append(result, l.entries[:l.next]...)
+	if limit > 0 && limit < len(result) {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
+
+// wrapCore tees the core of the workflow logger with one writing to the buffer. Entries are captured at the levels
+// enabled by the core.
+func (l *recentLogs) wrapCore(c zapcore.Core) zapcore.Core {
+	return zapcore.NewTee(c, &recentLogsCore{LevelEnabler: c, logs: l})
+}
+
+func (c *recentLogsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &recentLogsCore{
+		LevelEnabler: c.LevelEnabler,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+		logs:         c.logs,
+	}
+}
+
+func (c *recentLogsCore) Check(entry zapcore.Entry, checkedEntry *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checkedEntry.AddCore(entry, c)
+	}
+	return checkedEntry
+}
+
+func (c *recentLogsCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(encoder)
+	}
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	c.logs.add(RecentLogEntry{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+		Fields:  encoder.Fields,
+	})
+	return nil
+}
+
+func (c *recentLogsCore) Sync() error {
+	return nil
+}
+
+// queryRecentLogs answers the QueryTypeRecentLogs query, whose optional argument limits the number of entries.
+func (wc *workflowEnvironmentImpl) queryRecentLogs(queryArgs []byte) ([]byte, error) {
+	if wc.recentLogs == nil {
+		return nil, errRecentLogsDisabled
+	}
+	var limit int
+	if len(queryArgs) > 0 {
+		if err := decodeArg(wc.dataConverter, queryArgs, &limit); err != nil {
+			return nil, err
+		}
+	}
+	return wc.encodeArg(wc.recentLogs.last(limit))
+}
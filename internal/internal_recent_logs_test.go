@@ -0,0 +1,108 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRecentLogs(t *testing.T) {
+	logs := newRecentLogs(3)
+	assert.Empty(t, logs.last(0))
+	for _, message := range []string{"a", "b"} {
+		logs.add(RecentLogEntry{Message: message})
+	}
+	assert.Equal(t, []RecentLogEntry{{Message: "a"}, {Message: "b"}}, logs.last(0))
+	for _, message := range []string{"c", "d", "e"} {
+		logs.add(RecentLogEntry{Message: message})
+	}
+	assert.Equal(t, []RecentLogEntry{{Message: "c"}, {Message: "d"}, {Message: "e"}}, logs.last(0))
+	assert.Equal(t, []RecentLogEntry{{Message: "d"}, {Message: "e"}}, logs.last(2))
+	assert.Len(t, logs.last(5), 3)
+}
+
+func TestProcessQuery_RecentLogs(t *testing.T) {
+	newHandler := func(size int) (*workflowExecutionEventHandlerImpl, *observer.ObservedLogs) {
+		core, observed := observer.New(zapcore.InfoLevel)
+		return newWorkflowExecutionEventHandler(
+			&WorkflowInfo{WorkflowType: WorkflowType{Name: "test"}},
+			func(result []byte, err error) {},
+			zap.New(core),
+			false,
+			size,
+			false,
+			false,
+			false,
+			true,
+			false,
+			tally.NoopScope,
+			newRegistry(),
+			DefaultDataConverter,
+			nil,
+			opentracing.NoopTracer{},
+			nil,
+			FeatureFlags{},
+		).(*workflowExecutionEventHandlerImpl), observed
+	}
+
+	weh, _ := newHandler(0)
+	_, err := weh.ProcessQuery(QueryTypeRecentLogs, nil)
+	assert.Equal(t, errRecentLogsDisabled, err)
+
+	weh, observed := newHandler(3)
+	weh.isReplay = true
+	weh.logger.Info("replayed")
+	weh.isReplay = false
+	weh.logger.Debug("below the logger level")
+	weh.logger.With(zap.String("ActivityID", "1")).Info("first", zap.Int("Attempt", 2))
+	weh.logger.Warn("second")
+	assert.Equal(t, 2, observed.Len(), "the workflow logger still writes to its core")
+
+	getLogs := func(args ...interface{}) []RecentLogEntry {
+		input, err := encodeArgs(DefaultDataConverter, args)
+		require.NoError(t, err)
+		result, err := weh.ProcessQuery(QueryTypeRecentLogs, input)
+		require.NoError(t, err)
+		var entries []RecentLogEntry
+		require.NoError(t, newEncodedValue(result, DefaultDataConverter).Get(&entries))
+		return entries
+	}
+	entries := getLogs()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "first", entries[0].Message)
+	assert.Equal(t, "info", entries[0].Level)
+	assert.Equal(t, map[string]interface{}{"ActivityID": "1", "Attempt": json.Number("2")}, entries[0].Fields)
+	assert.False(t, entries[0].Time.IsZero())
+	assert.Equal(t, "second", entries[1].Message)
+	assert.Equal(t, "warn", entries[1].Level)
+
+	entries = getLogs(1)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "second", entries[0].Message)
+}
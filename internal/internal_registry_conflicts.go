@@ -0,0 +1,158 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+type (
+	// registrationScope is the domain and task list polled by a worker.
+	registrationScope struct {
+		domain   string
+		taskList string
+	}
+
+	processRegistrationKey struct {
+		registrationScope
+		kind string
+		name string
+	}
+
+	// processRegistration is a registration of a workflow or activity name by a worker of the process.
+	processRegistration struct {
+		registry *registry
+		fnName   string
+		site     string
+	}
+
+	// workerRegistration is a workflow or activity name registered in the registry of a worker, checked against the
+	// other workers of the process when the worker starts.
+	workerRegistration struct {
+		kind   string
+		name   string
+		fnName string
+		site   string
+	}
+
+	// processRegistrations detects the workers of the process polling the same task list which register different
+	// functions under the same name, e.g. two libraries registering their own "Process" activity. Only one of them
+	// would be called by the worker polling the task list, and the other workers would execute the tasks of the other
+	// function as their own. The registrations of a worker are added when it starts, and removed when it is stopped
+	// or fails to start, so that workers which never run do not hold on to them.
+	processRegistrations struct {
+		sync.Mutex
+		byKey map[processRegistrationKey][]processRegistration
+	}
+)
+
+var workerRegistrations = &processRegistrations{byKey: make(map[processRegistrationKey][]processRegistration)}
+
+// addRegistration records a registration of the registry of a worker, to be checked by registerAll once it starts.
+func (r *registry) addRegistration(kind string, name string, fnName string) {
+	r.pendingRegistrations = append(r.pendingRegistrations, workerRegistration{
+		kind:   kind,
+		name:   name,
+		fnName: fnName,
+		site:   registrationSite(),
+	})
+}
+
+// registerAll records the registrations of r when its worker starts, and returns an error if the registry of another
+// running worker polling the same task list registered a different function under the same name.
+func (p *processRegistrations) registerAll(r *registry) error {
+	if r.workerScope == nil {
+		return nil
+	}
+	r.Lock()
+	registrations := append([]workerRegistration(nil), r.pendingRegistrations...)
+	r.Unlock()
+
+	p.Lock()
+	defer p.Unlock()
+	for _, registration := range registrations {
+		if err := p.registerLocked(r, registration); err != nil {
+			p.unregisterLocked(r)
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *processRegistrations) registerLocked(r *registry, registration workerRegistration) error {
+	key := processRegistrationKey{registrationScope: *r.workerScope, kind: registration.kind, name: registration.name}
+	for _, existing := range p.byKey[key] {
+		if existing.registry != r && existing.fnName != registration.fnName {
+			return fmt.Errorf(
+				"%v name \"%v\" is registered by two workers of the process polling task list \"%v\" of domain \"%v\" "+
+					"with different functions: %v at %v and %v at %v, use RegisterOptions.Name to register them under "+
+					"different names",
+				registration.kind, registration.name, key.taskList, key.domain, existing.fnName, existing.site,
+				registration.fnName, registration.site)
+		}
+	}
+	p.byKey[key] = append(p.byKey[key], processRegistration{registry: r, fnName: registration.fnName, site: registration.site})
+	return nil
+}
+
+// unregister removes the registrations of r, when its worker is stopped or failed to start.
+func (p *processRegistrations) unregister(r *registry) {
+	p.Lock()
+	defer p.Unlock()
+	p.unregisterLocked(r)
+}
+
+func (p *processRegistrations) unregisterLocked(r *registry) {
+	for key, registrations := range p.byKey {
+		kept := registrations[:0]
+		for _, registration := range registrations {
+			if registration.registry != r {
+				kept = append(kept, registration)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.byKey, key)
+		} else {
+			p.byKey[key] = kept
+		}
+	}
+}
+
+// registrationSite returns the location of the code registering the workflow or activity, the first caller outside
+// of the client.
+func registrationSite() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, "go.uber.org/cadence/internal.") ||
+			strings.HasPrefix(frame.Function, "go.uber.org/cadence/worker.")
+		if !internal || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%v:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown location"
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newWorkerRegistry() *registry {
+	return newTaskListWorkerRegistry("conflict-tasklist")
+}
+
+func newTaskListWorkerRegistry(taskList string) *registry {
+	r := newRegistry()
+	r.workerScope = &registrationScope{domain: "conflict-domain", taskList: taskList}
+	return r
+}
+
+// startWorker starts a worker of r without pollers, which only records its registrations.
+func startWorker(t *testing.T, r *registry) error {
+	w := &aggregatedWorker{registry: r, logger: zap.NewNop()}
+	err := w.Start()
+	if err == nil {
+		t.Cleanup(w.Stop)
+	}
+	return err
+}
+
+func conflictWorkflowA(ctx Context) error { return nil }
+
+func conflictWorkflowB(ctx Context) error { return nil }
+
+func conflictActivityA(ctx context.Context) error { return nil }
+
+func conflictActivityB(ctx context.Context) error { return nil }
+
+func TestRegistryProcessConflicts(t *testing.T) {
+	t.Run("same function", func(t *testing.T) {
+		options := RegisterWorkflowOptions{Name: "conflict-same-workflow"}
+		r1, r2 := newWorkerRegistry(), newWorkerRegistry()
+		r1.RegisterWorkflowWithOptions(conflictWorkflowA, options)
+		r2.RegisterWorkflowWithOptions(conflictWorkflowA, options)
+		assert.NoError(t, startWorker(t, r1))
+		assert.NoError(t, startWorker(t, r2))
+	})
+
+	t.Run("workflow", func(t *testing.T) {
+		r1, r2 := newWorkerRegistry(), newWorkerRegistry()
+		r1.RegisterWorkflowWithOptions(conflictWorkflowA, RegisterWorkflowOptions{Name: "conflict-workflow"})
+		r2.RegisterWorkflowWithOptions(conflictWorkflowB, RegisterWorkflowOptions{Name: "conflict-workflow"})
+		assert.NoError(t, startWorker(t, r1))
+		err := startWorker(t, r2)
+		assert.ErrorContains(t, err, `workflow name "conflict-workflow" is registered by two workers of the process polling task list "conflict-tasklist" of domain "conflict-domain" with different functions`)
+		assert.ErrorContains(t, err, "go.uber.org/cadence/internal.conflictWorkflowA at ")
+		assert.ErrorContains(t, err, "go.uber.org/cadence/internal.conflictWorkflowB at ")
+		assert.Regexp(t, `internal_registry_conflicts_test.go:\d+ and .* at .*internal_registry_conflicts_test.go:\d+`, err.Error())
+	})
+
+	t.Run("workflow alias", func(t *testing.T) {
+		r1, r2 := newWorkerRegistry(), newWorkerRegistry()
+		r1.RegisterWorkflowWithOptions(conflictWorkflowA, RegisterWorkflowOptions{Name: "conflict-alias-a", Aliases: []string{"conflict-alias"}})
+		r2.RegisterWorkflowWithOptions(conflictWorkflowB, RegisterWorkflowOptions{Name: "conflict-alias"})
+		assert.NoError(t, startWorker(t, r1))
+		assert.Error(t, startWorker(t, r2))
+	})
+
+	t.Run("activity", func(t *testing.T) {
+		r1, r2 := newWorkerRegistry(), newWorkerRegistry()
+		r1.RegisterActivityWithOptions(conflictActivityA, RegisterActivityOptions{Name: "conflict-activity"})
+		r2.RegisterActivityWithOptions(conflictActivityB, RegisterActivityOptions{Name: "conflict-activity"})
+		assert.NoError(t, startWorker(t, r1))
+		assert.ErrorContains(t, startWorker(t, r2), `activity name "conflict-activity" is registered by two workers of the process polling task list "conflict-tasklist" of domain "conflict-domain" with different functions`)
+	})
+
+	t.Run("other task list", func(t *testing.T) {
+		r1, r2 := newTaskListWorkerRegistry("conflict-tasklist-a"), newTaskListWorkerRegistry("conflict-tasklist-b")
+		r1.RegisterWorkflowWithOptions(conflictWorkflowA, RegisterWorkflowOptions{Name: "conflict-other"})
+		r2.RegisterWorkflowWithOptions(conflictWorkflowB, RegisterWorkflowOptions{Name: "conflict-other"})
+		assert.NoError(t, startWorker(t, r1))
+		assert.NoError(t, startWorker(t, r2))
+	})
+
+	t.Run("stopped worker", func(t *testing.T) {
+		r1, r2 := newWorkerRegistry(), newWorkerRegistry()
+		r1.RegisterActivityWithOptions(conflictActivityA, RegisterActivityOptions{Name: "conflict-stopped"})
+		r2.RegisterActivityWithOptions(conflictActivityB, RegisterActivityOptions{Name: "conflict-stopped"})
+		w := &aggregatedWorker{registry: r1, logger: zap.NewNop()}
+		assert.NoError(t, w.Start())
+		w.Stop()
+		assert.NoError(t, startWorker(t, r2))
+	})
+
+	t.Run("worker never started or failed to start", func(t *testing.T) {
+		running, never, failed, started := newWorkerRegistry(), newWorkerRegistry(), newWorkerRegistry(), newWorkerRegistry()
+		running.RegisterActivityWithOptions(conflictActivityA, RegisterActivityOptions{Name: "conflict-running"})
+		never.RegisterActivityWithOptions(conflictActivityA, RegisterActivityOptions{Name: "conflict-never"})
+		failed.RegisterActivityWithOptions(conflictActivityA, RegisterActivityOptions{Name: "conflict-failed"})
+		failed.RegisterActivityWithOptions(conflictActivityB, RegisterActivityOptions{Name: "conflict-running"})
+		started.RegisterActivityWithOptions(conflictActivityB, RegisterActivityOptions{Name: "conflict-never"})
+		started.RegisterActivityWithOptions(conflictActivityB, RegisterActivityOptions{Name: "conflict-failed"})
+
+		assert.NoError(t, startWorker(t, running))
+		assert.Error(t, startWorker(t, failed))
+		assert.NoError(t, startWorker(t, started), "only the registrations of running workers are checked")
+	})
+
+	t.Run("not checked", func(t *testing.T) {
+		r1, r2, r3 := newWorkerRegistry(), newRegistry(), newWorkerRegistry()
+		r1.RegisterActivityWithOptions(conflictActivityA, RegisterActivityOptions{Name: "conflict-unchecked"})
+		// the registries of clients, replayers and test environments are not checked
+		r2.RegisterActivityWithOptions(conflictActivityB, RegisterActivityOptions{Name: "conflict-unchecked"})
+		r3.RegisterActivityWithOptions(conflictActivityB, RegisterActivityOptions{
+			Name:                          "conflict-unchecked",
+			DisableAlreadyRegisteredCheck: true,
+		})
+		assert.NoError(t, startWorker(t, r1))
+		assert.NoError(t, startWorker(t, r2))
+		assert.NoError(t, startWorker(t, r3))
+	})
+}
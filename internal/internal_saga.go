@@ -0,0 +1,103 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"go.uber.org/multierr"
+)
+
+type (
+	// SagaOptions configures how a Saga runs its compensations.
+	SagaOptions struct {
+		// Optional: Run all the compensations concurrently instead of one at a time in the reverse order they were
+		// added. All of them run even when some fail, and their errors are combined.
+		// default: false
+		ParallelCompensation bool
+
+		// Optional: Keep running the remaining compensations when one fails, and combine their errors. Compensations
+		// run concurrently always continue on error.
+		// default: false, the compensation stops at the first failing compensation
+		ContinueWithError bool
+	}
+
+	// Saga records the compensations of the steps of a workflow, e.g. the activities undoing the activities which
+	// succeeded, and runs them when a later step fails or the workflow is canceled.
+	// It must be created and used from workflow code only.
+	Saga struct {
+		options       SagaOptions
+		compensations []func(ctx Context) error
+	}
+)
+
+// NewSaga creates a Saga with no compensations.
+func NewSaga(options SagaOptions) *Saga {
+	return &Saga{options: options}
+}
+
+// AddCompensation records an activity run with args when compensating, with the activity options of the context
+// passed to Compensate.
+func (s *Saga) AddCompensation(activity interface{}, args ...interface{}) {
+	s.AddCompensationFunc(func(ctx Context) error {
+		return ExecuteActivity(ctx, activity, args...).Get(ctx, nil)
+	})
+}
+
+// AddCompensationFunc records a function called when compensating, e.g. to run a local activity or a child
+// workflow, or to compensate with several activities.
+func (s *Saga) AddCompensationFunc(compensation func(ctx Context) error) {
+	s.compensations = append(s.compensations, compensation)
+}
+
+// Compensate runs the compensations added so far, in the reverse order they were added unless
+// SagaOptions.ParallelCompensation is set, and returns their combined errors. The compensations are run on a
+// disconnected context, so that they are run, and not interrupted, when ctx is canceled. They are removed from the
+// Saga, so calling Compensate again only runs the compensations added since.
+func (s *Saga) Compensate(ctx Context) error {
+	compensations := s.compensations
+	s.compensations = nil
+	ctx, _ = NewDisconnectedContext(ctx)
+
+	if s.options.ParallelCompensation {
+		errs := make([]error, len(compensations))
+		wg := NewWaitGroup(ctx)
+		for i := len(compensations) - 1; i >= 0; i-- {
+			i := i
+			wg.Add(1)
+			Go(ctx, func(ctx Context) {
+				defer wg.Done()
+				errs[i] = compensations[i](ctx)
+			})
+		}
+		wg.Wait(ctx)
+		return multierr.Combine(errs...)
+	}
+
+	var errs error
+	for i := len(compensations) - 1; i >= 0; i-- {
+		if err := compensations[i](ctx); err != nil {
+			errs = multierr.Append(errs, err)
+			if !s.options.ContinueWithError {
+				return errs
+			}
+		}
+	}
+	return errs
+}
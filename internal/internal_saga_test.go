@@ -0,0 +1,165 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+)
+
+// sagaTestEnvironment returns a test environment with the "compensate" activity, failing for steps starting with
+// "fail", and the steps it compensated.
+func sagaTestEnvironment() (*TestWorkflowEnvironment, func() []string) {
+	var mu sync.Mutex
+	var compensated []string
+	compensate := func(ctx context.Context, step string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		compensated = append(compensated, step)
+		if strings.HasPrefix(step, "fail") {
+			return errors.New("cannot compensate " + step)
+		}
+		return nil
+	}
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(compensate, RegisterActivityOptions{Name: "compensate"})
+	return env, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, compensated...)
+	}
+}
+
+func TestSagaCompensate(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		options     SagaOptions
+		steps       []string
+		compensated []string
+		errors      int
+	}{
+		{
+			name:        "reverse order",
+			steps:       []string{"a", "b", "c"},
+			compensated: []string{"c", "b", "a"},
+		},
+		{
+			name:        "stop at first error",
+			steps:       []string{"a", "fail-b", "c"},
+			compensated: []string{"c", "fail-b"},
+			errors:      1,
+		},
+		{
+			name:        "continue with error",
+			options:     SagaOptions{ContinueWithError: true},
+			steps:       []string{"a", "fail-b", "fail-c"},
+			compensated: []string{"fail-c", "fail-b", "a"},
+			errors:      2,
+		},
+		{
+			name:        "parallel",
+			options:     SagaOptions{ParallelCompensation: true},
+			steps:       []string{"a", "fail-b", "c"},
+			compensated: []string{"a", "fail-b", "c"},
+			errors:      1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env, compensated := sagaTestEnvironment()
+			workflowFn := func(ctx Context) (int, error) {
+				ctx = WithActivityOptions(ctx, ActivityOptions{
+					ScheduleToStartTimeout: time.Minute,
+					StartToCloseTimeout:    time.Minute,
+				})
+				saga := NewSaga(tc.options)
+				for _, step := range tc.steps {
+					saga.AddCompensation("compensate", step)
+				}
+				err := saga.Compensate(ctx)
+				if err == nil {
+					return 0, nil
+				}
+				for _, err := range multierr.Errors(err) {
+					var genericErr *GenericError
+					require.ErrorAs(t, err, &genericErr)
+					assert.Contains(t, genericErr.Error(), "cannot compensate fail-")
+				}
+				return len(multierr.Errors(err)), nil
+			}
+			env.RegisterWorkflow(workflowFn)
+			env.ExecuteWorkflow(workflowFn)
+			require.True(t, env.IsWorkflowCompleted())
+			require.NoError(t, env.GetWorkflowError())
+
+			var errs int
+			require.NoError(t, env.GetWorkflowResult(&errs))
+			assert.Equal(t, tc.errors, errs)
+			if tc.options.ParallelCompensation {
+				assert.ElementsMatch(t, tc.compensated, compensated())
+			} else {
+				assert.Equal(t, tc.compensated, compensated())
+			}
+		})
+	}
+}
+
+func TestSagaCompensateCanceledWorkflow(t *testing.T) {
+	env, compensated := sagaTestEnvironment()
+	var funcCtxErr error
+	workflowFn := func(ctx Context) (err error) {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		saga := NewSaga(SagaOptions{})
+		defer func() {
+			if err != nil {
+				err = multierr.Append(err, saga.Compensate(ctx))
+				assert.NoError(t, saga.Compensate(ctx), "the compensations run once")
+			}
+		}()
+
+		saga.AddCompensation("compensate", "a")
+		saga.AddCompensationFunc(func(ctx Context) error {
+			funcCtxErr = ctx.Err()
+			return Sleep(ctx, time.Minute)
+		})
+		return Sleep(ctx, time.Hour)
+	}
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+
+	var canceledErr *CanceledError
+	assert.ErrorAs(t, env.GetWorkflowError(), &canceledErr)
+	assert.NoError(t, funcCtxErr, "the compensations run on a disconnected context")
+	assert.Equal(t, []string{"a"}, compensated())
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+// sdkVersionMarkerData is recorded in the SDKVersion marker when WorkerOptions.RecordSDKVersionMarker is set.
+type sdkVersionMarkerData struct {
+	LibraryVersion string `json:"libraryVersion"`
+	FeatureVersion string `json:"featureVersion"`
+	GoVersion      string `json:"goVersion"`
+}
+
+func currentSDKVersion() sdkVersionMarkerData {
+	return sdkVersionMarkerData{
+		LibraryVersion: LibraryVersion,
+		FeatureVersion: FeatureVersion,
+		GoVersion:      runtime.Version(),
+	}
+}
+
+// recordSDKVersion records the SDKVersion marker, before the workflow function is invoked so that the marker is the
+// first decision of the workflow.
+func (weh *workflowExecutionEventHandlerImpl) recordSDKVersion() error {
+	details, err := encodeArg(weh.dataConverter, currentSDKVersion())
+	if err != nil {
+		return err
+	}
+	weh.decisionsHelper.recordSDKVersionMarker(details)
+	return nil
+}
+
+// handleSDKVersionMarker checks the SDK version recorded in the marker. The marker is only diagnostic: a marker which
+// can't be decoded is reported instead of failing the decision task.
+func (weh *workflowExecutionEventHandlerImpl) handleSDKVersionMarker(details Values) {
+	var recorded sdkVersionMarkerData
+	if err := details.Get(&recorded); err != nil {
+		weh.workerLogger.Warn("Ignoring an SDKVersion marker which can't be decoded.", zap.Error(err))
+		if weh.workerMetricsScope != nil {
+			weh.workerMetricsScope.Counter(metrics.SDKVersionMarkerInvalidCounter).Inc(1)
+		}
+		return
+	}
+	weh.checkSDKVersion(recorded)
+}
+
+// checkSDKVersion warns when the replayed history was started by another major or minor SDK version or another Go
+// release, whose behavior may subtly differ. Markers are only handled while replaying, so the warning is reported
+// with the worker logger and metrics scope rather than the replay-aware ones of the workflow.
+func (weh *workflowExecutionEventHandlerImpl) checkSDKVersion(recorded sdkVersionMarkerData) {
+	current := currentSDKVersion()
+	if !sdkVersionsDiffer(recorded, current) {
+		return
+	}
+	weh.workerLogger.Warn("Replaying a workflow started by another SDK version.",
+		zap.String("RecordedFeatureVersion", recorded.FeatureVersion),
+		zap.String("RecordedGoVersion", recorded.GoVersion),
+		zap.String("FeatureVersion", current.FeatureVersion),
+		zap.String("GoVersion", current.GoVersion))
+	if weh.workerMetricsScope != nil {
+		weh.workerMetricsScope.Counter(metrics.SDKVersionMismatchCounter).Inc(1)
+	}
+}
+
+// sdkVersionsDiffer reports whether the major or minor feature versions, or the Go releases, differ. Unparseable
+// feature versions differ from any other one.
+func sdkVersionsDiffer(a, b sdkVersionMarkerData) bool {
+	if goRelease(a.GoVersion) != goRelease(b.GoVersion) {
+		return true
+	}
+	if a.FeatureVersion == b.FeatureVersion {
+		return false
+	}
+	parsedA, okA := parseFeatureVersion(a.FeatureVersion)
+	parsedB, okB := parseFeatureVersion(b.FeatureVersion)
+	if !okA || !okB {
+		return true
+	}
+	return parsedA[0] != parsedB[0] || parsedA[1] != parsedB[1]
+}
+
+// goRelease trims the patch version and the suffixes of a version returned by runtime.Version, e.g. "go1.21.5" and
+// "go1.21rc2" both are "go1.21".
+func goRelease(version string) string {
+	if !strings.HasPrefix(version, "go") {
+		return version
+	}
+	end := len("go")
+	dots := 0
+	for end < len(version) {
+		c := version[end]
+		if c == '.' {
+			dots++
+			if dots == 2 {
+				break
+			}
+		} else if c < '0' || c > '9' {
+			break
+		}
+		end++
+	}
+	return version[:end]
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+func TestRecordSDKVersionMarker(t *testing.T) {
+	started := &s.HistoryEvent{
+		EventType: common.EventTypePtr(s.EventTypeWorkflowExecutionStarted),
+		WorkflowExecutionStartedEventAttributes: &s.WorkflowExecutionStartedEventAttributes{
+			WorkflowType: &s.WorkflowType{Name: common.StringPtr("test")},
+		},
+	}
+	newHandler := func(record bool) *workflowExecutionEventHandlerImpl {
+		registry := newRegistry()
+		registry.RegisterWorkflowWithOptions(func(ctx Context) error { return nil }, RegisterWorkflowOptions{Name: "test"})
+		weh := testWorkflowExecutionEventHandler(t, registry)
+		weh.recordSDKVersionMarker = record
+		return weh
+	}
+
+	weh := newHandler(true)
+	require.NoError(t, weh.ProcessEvent(started, false, false))
+	decisions := weh.decisionsHelper.getDecisions(true)
+	require.NotEmpty(t, decisions)
+	assert.Equal(t, s.DecisionTypeRecordMarker, decisions[0].GetDecisionType())
+	assert.Equal(t, sdkVersionMarkerName, decisions[0].RecordMarkerDecisionAttributes.GetMarkerName())
+	var recorded sdkVersionMarkerData
+	require.NoError(t, newEncodedValues(decisions[0].RecordMarkerDecisionAttributes.Details, weh.dataConverter).Get(&recorded))
+	assert.Equal(t, sdkVersionMarkerData{LibraryVersion: LibraryVersion, FeatureVersion: FeatureVersion, GoVersion: runtime.Version()}, recorded)
+	assert.True(t, skipDeterministicCheckForDecision(decisions[0]))
+
+	weh = newHandler(true)
+	require.NoError(t, weh.ProcessEvent(started, true, false))
+	assert.Empty(t, weh.decisionsHelper.getDecisions(true), "the marker is not recorded while replaying")
+
+	weh = newHandler(false)
+	require.NoError(t, weh.ProcessEvent(started, false, false))
+	assert.Empty(t, weh.decisionsHelper.getDecisions(true))
+}
+
+func TestCheckSDKVersion(t *testing.T) {
+	marker := func(version sdkVersionMarkerData) *s.HistoryEvent {
+		details, err := encodeArg(DefaultDataConverter, version)
+		require.NoError(t, err)
+		return &s.HistoryEvent{
+			EventId:   common.Int64Ptr(5),
+			EventType: common.EventTypePtr(s.EventTypeMarkerRecorded),
+			MarkerRecordedEventAttributes: &s.MarkerRecordedEventAttributes{
+				MarkerName: common.StringPtr(sdkVersionMarkerName),
+				Details:    details,
+			},
+		}
+	}
+
+	t.Run("same version", func(t *testing.T) {
+		weh, logs, scope := observedWorkflowExecutionEventHandler(t)
+		event := marker(currentSDKVersion())
+		assert.True(t, skipDeterministicCheckForEvent(event))
+		require.NoError(t, weh.ProcessEvent(event, true, false))
+		assert.Zero(t, logs.Len())
+		assert.NotContains(t, scope.Snapshot().Counters(), metrics.SDKVersionMismatchCounter+"+WorkflowType=test")
+	})
+	t.Run("other version", func(t *testing.T) {
+		weh, logs, scope := observedWorkflowExecutionEventHandler(t)
+		recorded := sdkVersionMarkerData{LibraryVersion: "1.0.0", FeatureVersion: "1.0.0", GoVersion: runtime.Version()}
+		require.NoError(t, weh.ProcessEvent(marker(recorded), true, false))
+
+		entries := logs.FilterMessage("Replaying a workflow started by another SDK version.").AllUntimed()
+		require.Len(t, entries, 1, "the warning is logged while replaying")
+		assert.Equal(t, "1.0.0", entries[0].ContextMap()["RecordedFeatureVersion"])
+		assert.Equal(t, FeatureVersion, entries[0].ContextMap()["FeatureVersion"])
+		assert.Equal(t, "test", entries[0].ContextMap()[tagWorkflowType])
+		counter := scope.Snapshot().Counters()[metrics.SDKVersionMismatchCounter+"+WorkflowType=test"]
+		require.NotNil(t, counter)
+		assert.Equal(t, int64(1), counter.Value())
+	})
+	t.Run("invalid marker", func(t *testing.T) {
+		weh, logs, scope := observedWorkflowExecutionEventHandler(t)
+		event := marker(currentSDKVersion())
+		event.MarkerRecordedEventAttributes.Details = []byte("not json")
+		require.NoError(t, weh.ProcessEvent(event, true, false), "the decision task is not failed")
+
+		assert.Equal(t, 1, logs.FilterMessage("Ignoring an SDKVersion marker which can't be decoded.").Len())
+		counter := scope.Snapshot().Counters()[metrics.SDKVersionMarkerInvalidCounter+"+WorkflowType=test"]
+		require.NotNil(t, counter)
+		assert.Equal(t, int64(1), counter.Value())
+	})
+}
+
+func TestSDKVersionsDiffer(t *testing.T) {
+	version := func(feature, goVersion string) sdkVersionMarkerData {
+		return sdkVersionMarkerData{FeatureVersion: feature, GoVersion: goVersion}
+	}
+	for _, tc := range []struct {
+		name   string
+		a, b   sdkVersionMarkerData
+		differ bool
+	}{
+		{"same", version("1.7.0", "go1.21.5"), version("1.7.0", "go1.21.5"), false},
+		{"patch versions", version("1.7.0", "go1.21.5"), version("1.7.3", "go1.21.1"), false},
+		{"minor version", version("1.6.0", "go1.21.5"), version("1.7.0", "go1.21.5"), true},
+		{"major version", version("1.7.0", "go1.21.5"), version("2.7.0", "go1.21.5"), true},
+		{"go release", version("1.7.0", "go1.21.5"), version("1.7.0", "go1.22.0"), true},
+		{"go release candidate", version("1.7.0", "go1.22rc1"), version("1.7.0", "go1.22.0"), false},
+		{"unparseable", version("unknown", "go1.21.5"), version("1.7.0", "go1.21.5"), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.differ, sdkVersionsDiffer(tc.a, tc.b))
+			assert.Equal(t, tc.differ, sdkVersionsDiffer(tc.b, tc.a))
+		})
+	}
+}
+
+func TestGoRelease(t *testing.T) {
+	for version, release := range map[string]string{
+		"go1.21":                "go1.21",
+		"go1.21.5":              "go1.21",
+		"go1.22rc2":             "go1.22",
+		"go1.22.0 X:nocoverage": "go1.22",
+		"devel go1.23-abc":      "devel go1.23-abc",
+	} {
+		assert.Equal(t, release, goRelease(version), version)
+	}
+}
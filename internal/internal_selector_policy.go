@@ -0,0 +1,210 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+type (
+	// SelectorPolicy chooses the case invoked by Select when several cases of a Selector are ready.
+	SelectorPolicy int
+
+	// SelectorOptions configure a Selector created by NewSelectorWithOptions.
+	SelectorOptions struct {
+		// Optional: human readable name of the Selector, which appears in stack traces blocked on it.
+		// default: "selector-<sequence number>", as NewSelector
+		Name string
+		// Optional: policy choosing the case invoked when several cases are ready.
+		// default: SelectorPolicyRegistrationOrder
+		Policy SelectorPolicy
+	}
+)
+
+const (
+	// SelectorPolicyRegistrationOrder invokes the first ready case in the order the cases were added when Select is
+	// called, and otherwise the first case that becomes ready while Select is blocked, whatever its order. This is
+	// the policy of the Selectors created by NewSelector.
+	SelectorPolicyRegistrationOrder SelectorPolicy = iota
+	// SelectorPolicyRoundRobin invokes the first ready case starting after the case invoked by the previous Select
+	// call, so that a case which is always ready, e.g. a busy channel, does not starve the other cases.
+	SelectorPolicyRoundRobin
+	// SelectorPolicyPriority invokes the first ready case in the order the cases were added, including when several
+	// cases become ready while Select is blocked, e.g. when signals are received on a high and a low priority channel
+	// in the same decision task. The cases added first have the highest priority.
+	SelectorPolicyPriority
+)
+
+// NewSelectorWithOptions creates a new Selector instance with options. Like the Selectors created by
+// NewSelectorWithDeadline, it supports timeout cases.
+func NewSelectorWithOptions(ctx Context, options SelectorOptions) SelectorWithDeadline {
+	var s *selectorImpl
+	if options.Name != "" {
+		s = NewNamedSelector(ctx, options.Name).(*selectorImpl)
+	} else {
+		s = NewSelector(ctx).(*selectorImpl)
+	}
+	s.policy = options.Policy
+	return s
+}
+
+// selectWithPolicy implements Select for the policies other than SelectorPolicyRegistrationOrder. Rather than
+// invoking the first case that becomes ready while it is blocked, it is woken up without receiving anything, and scans
+// the cases again in the order of the policy, so that all the cases that became ready meanwhile are considered.
+// Send cases are the exception: a value taken by a receiver while blocked is delivered, so the send case is invoked.
+func (s *selectorImpl) selectWithPolicy(ctx Context) {
+	state := getState(ctx)
+	var cleanups []func()
+	defer func() {
+		for _, c := range cleanups {
+			c()
+		}
+	}()
+
+	var timers []asyncFuture
+	blocked := false
+	for {
+		if s.selectReadyCase() {
+			if blocked {
+				state.unblocked()
+			}
+			return
+		}
+		if s.defaultFunc != nil {
+			(*s.defaultFunc)()
+			return
+		}
+		if timers == nil {
+			for _, t := range s.timeouts {
+				// the timers are started once per Select call, see Select
+				timerCtx, cancelTimer := NewDisconnectedContext(ctx)
+				timers = append(timers, NewTimer(timerCtx, t.timeout).(asyncFuture))
+				cleanups = append(cleanups, cancelTimer)
+			}
+		}
+		for i, timer := range timers {
+			if timer.IsReady() {
+				if blocked {
+					state.unblocked()
+				}
+				s.timeouts[i].f()
+				return
+			}
+		}
+
+		var sent func()
+		woken := false
+		wake := &receiveCallback{
+			fn: func(v interface{}, more bool) bool {
+				woken = true
+				return false
+			},
+		}
+		var removeCallbacks []func()
+		for _, pair := range s.cases {
+			p := pair
+			switch {
+			case p.receiveFunc != nil:
+				p.channel.receiveAsyncImpl(wake)
+				removeCallbacks = append(removeCallbacks, func() {
+					p.channel.removeReceiveCallback(wake)
+				})
+			case p.sendFunc != nil:
+				callback := &sendCallback{
+					value: *p.sendValue,
+					fn: func() bool {
+						if sent != nil {
+							return false
+						}
+						sent = *p.sendFunc
+						woken = true
+						return true
+					},
+				}
+				p.channel.sendAsyncImpl(*p.sendValue, callback)
+				removeCallbacks = append(removeCallbacks, func() {
+					p.channel.removeSendCallback(callback)
+				})
+			case p.futureFunc != nil:
+				p.future.GetAsync(wake)
+				removeCallbacks = append(removeCallbacks, func() {
+					p.future.RemoveReceiveCallback(wake)
+				})
+			}
+		}
+		for _, timer := range timers {
+			t := timer
+			t.GetAsync(wake)
+			removeCallbacks = append(removeCallbacks, func() {
+				t.RemoveReceiveCallback(wake)
+			})
+		}
+		for !woken {
+			state.yield("blocked on " + s.name + ".Select")
+		}
+		blocked = true
+		for _, remove := range removeCallbacks {
+			remove()
+		}
+		if sent != nil {
+			state.unblocked()
+			sent()
+			return
+		}
+	}
+}
+
+// selectReadyCase invokes the first ready case in the order of the policy, and returns false when no case is ready.
+func (s *selectorImpl) selectReadyCase() bool {
+	for k := range s.cases {
+		i := k
+		if s.policy == SelectorPolicyRoundRobin {
+			i = (s.next + k) % len(s.cases)
+		}
+		pair := s.cases[i]
+		switch {
+		case pair.receiveFunc != nil:
+			v, ok, more := pair.channel.receiveAsyncImpl(nil)
+			if !ok && more {
+				continue
+			}
+			if more {
+				pair.channel.recValue = &v
+			}
+			s.next = (i + 1) % len(s.cases)
+			(*pair.receiveFunc)(pair.channel, more)
+			return true
+		case pair.sendFunc != nil:
+			if !pair.channel.sendAsyncImpl(*pair.sendValue, nil) {
+				continue
+			}
+			s.next = (i + 1) % len(s.cases)
+			(*pair.sendFunc)()
+			return true
+		case pair.futureFunc != nil:
+			if _, ok, _ := pair.future.GetAsync(nil); !ok {
+				continue
+			}
+			f := *pair.futureFunc
+			pair.futureFunc = nil
+			s.next = (i + 1) % len(s.cases)
+			f(pair.future)
+			return true
+		}
+	}
+	return false
+}
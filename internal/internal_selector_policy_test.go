@@ -0,0 +1,179 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectorPolicyPriority(t *testing.T) {
+	for _, policy := range []SelectorPolicy{SelectorPolicyRegistrationOrder, SelectorPolicyPriority} {
+		var history []string
+		var high, low Channel
+		d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+			high = NewBufferedChannel(ctx, 10)
+			low = NewBufferedChannel(ctx, 10)
+			s := NewSelectorWithOptions(ctx, SelectorOptions{Policy: policy})
+			for _, c := range []Channel{high, low} {
+				s.AddReceive(c, func(c Channel, more bool) {
+					var v string
+					c.Receive(ctx, &v)
+					history = append(history, v)
+				})
+			}
+			for i := 0; i < 3; i++ {
+				s.Select(ctx)
+			}
+		})
+		require.NoError(t, d.ExecuteUntilAllBlocked())
+
+		// both channels receive values while Select is blocked
+		low.SendAsync("low 1")
+		high.SendAsync("high 1")
+		high.SendAsync("high 2")
+		require.NoError(t, d.ExecuteUntilAllBlocked())
+		if policy == SelectorPolicyPriority {
+			assert.Equal(t, []string{"high 1", "high 2", "low 1"}, history)
+		} else {
+			assert.Equal(t, []string{"low 1", "high 1", "high 2"}, history, "the first ready case wins")
+		}
+		assert.True(t, d.IsDone())
+		d.Close()
+	}
+}
+
+func TestSelectorPolicyRoundRobin(t *testing.T) {
+	for _, policy := range []SelectorPolicy{SelectorPolicyRegistrationOrder, SelectorPolicyRoundRobin} {
+		var history []string
+		d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+			s := NewSelectorWithOptions(ctx, SelectorOptions{Name: "round robin", Policy: policy})
+			for _, name := range []string{"a", "b", "c"} {
+				c := NewBufferedChannel(ctx, 10)
+				for i := 0; i < 3; i++ {
+					c.SendAsync(fmt.Sprintf("%v%v", name, i))
+				}
+				s.AddReceive(c, func(c Channel, more bool) {
+					var v string
+					c.Receive(ctx, &v)
+					history = append(history, v)
+				})
+			}
+			for i := 0; i < 5; i++ {
+				s.Select(ctx)
+			}
+		})
+		require.NoError(t, d.ExecuteUntilAllBlocked())
+		if policy == SelectorPolicyRoundRobin {
+			assert.Equal(t, []string{"a0", "b0", "c0", "a1", "b1"}, history)
+		} else {
+			assert.Equal(t, []string{"a0", "a1", "a2", "b0", "b1"}, history)
+		}
+		assert.True(t, d.IsDone())
+		d.Close()
+	}
+}
+
+func TestSelectorPolicySendAndFuture(t *testing.T) {
+	var history []string
+	var settable Settable
+	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+		c := NewChannel(ctx)
+		var f Future
+		f, settable = NewFuture(ctx)
+		s := NewSelectorWithOptions(ctx, SelectorOptions{Policy: SelectorPolicyPriority})
+		s.AddFuture(f, func(f Future) {
+			history = append(history, "future")
+		})
+		s.AddSend(c, "value", func() {
+			history = append(history, "sent")
+		})
+		// the receiving selector is blocked first, and both selectors use the priority policy
+		Go(ctx, func(ctx Context) {
+			var v string
+			NewSelectorWithOptions(ctx, SelectorOptions{Policy: SelectorPolicyPriority}).
+				AddReceive(c, func(c Channel, more bool) {
+					c.Receive(ctx, &v)
+					history = append(history, "received "+v)
+				}).
+				Select(ctx)
+		})
+		Go(ctx, func(ctx Context) {
+			s.Select(ctx)
+			s.Select(ctx)
+		})
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.Equal(t, []string{"received value", "sent"}, history)
+	assert.False(t, d.IsDone())
+
+	settable.SetValue(true)
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.Equal(t, []string{"received value", "sent", "future"}, history)
+	assert.True(t, d.IsDone())
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_SelectorWithOptionsTimeout() {
+	workflowFn := func(ctx Context) ([]string, error) {
+		var selected []string
+		start := Now(ctx)
+		signals := GetSignalChannel(ctx, "signal")
+		selector := NewSelectorWithOptions(ctx, SelectorOptions{Policy: SelectorPolicyPriority}).
+			AddTimeout(time.Hour, func() { selected = append(selected, "timeout") })
+		selector.AddReceive(signals, func(c Channel, more bool) {
+			var v string
+			c.Receive(ctx, &v)
+			selected = append(selected, v)
+		})
+
+		// the signal wins, and the timer of the timeout is canceled
+		selector.Select(ctx)
+		s.Equal(time.Minute, Now(ctx).Sub(start))
+		selector.Select(ctx)
+		s.Equal(time.Minute+time.Hour, Now(ctx).Sub(start))
+
+		selector.AddDefault(func() { selected = append(selected, "default") })
+		selector.Select(ctx)
+		return selected, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	var canceledTimers, firedTimers int
+	env.SetOnTimerCancelledListener(func(string) { canceledTimers++ })
+	env.SetOnTimerFiredListener(func(string) { firedTimers++ })
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("signal", "signal")
+	}, time.Minute)
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var selected []string
+	s.NoError(env.GetWorkflowResult(&selected))
+	s.Equal([]string{"signal", "timeout", "default"}, selected)
+	s.Equal(1, canceledTimers)
+	s.Equal(1, firedTimers)
+}
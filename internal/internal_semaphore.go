@@ -0,0 +1,118 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"container/list"
+)
+
+type (
+	// Semaphore bounds the number of coroutines of a workflow holding a resource, e.g. the number of activities or
+	// child workflows executing concurrently. It is created by NewSemaphore.
+	Semaphore interface {
+		// Acquire blocks until n units are acquired, or returns CanceledError if ctx is canceled first, in which
+		// case no units are acquired. Waiting coroutines acquire in the order they called Acquire.
+		Acquire(ctx Context, n int64) error
+		// TryAcquire acquires n units without blocking, and returns false when they are not available or when
+		// coroutines are waiting in Acquire.
+		TryAcquire(n int64) bool
+		// Release releases n units. It panics when more units are released than held.
+		Release(n int64)
+	}
+
+	semaphoreImpl struct {
+		size    int64
+		cur     int64
+		waiters list.List // of *semaphoreWaiter, in the order of the Acquire calls
+	}
+
+	semaphoreWaiter struct {
+		n     int64
+		ready bool
+	}
+)
+
+var _ Semaphore = (*semaphoreImpl)(nil)
+
+// NewSemaphore creates a new Semaphore with n units.
+func NewSemaphore(ctx Context, n int64) Semaphore {
+	return &semaphoreImpl{size: n}
+}
+
+func (s *semaphoreImpl) Acquire(ctx Context, n int64) error {
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return nil
+	}
+	if ctx.Err() != nil {
+		return NewCanceledError("Semaphore context cancelled")
+	}
+	w := &semaphoreWaiter{n: n}
+	elem := s.waiters.PushBack(w)
+	if err := Await(ctx, func() bool { return w.ready }); err != nil {
+		if w.ready {
+			// acquired while the context was canceled, give the units back
+			s.Release(n)
+			return err
+		}
+		isFront := s.waiters.Front() == elem
+		s.waiters.Remove(elem)
+		if isFront {
+			// the next waiters may acquire the units that this one was waiting for
+			s.notifyWaiters()
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *semaphoreImpl) TryAcquire(n int64) bool {
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+func (s *semaphoreImpl) Release(n int64) {
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+func (s *semaphoreImpl) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(*semaphoreWaiter)
+		if s.size-s.cur < w.n {
+			// the waiters acquire in order, so that large requests are not starved by small ones
+			return
+		}
+		s.cur += w.n
+		w.ready = true
+		s.waiters.Remove(next)
+	}
+}
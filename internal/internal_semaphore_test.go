@@ -0,0 +1,149 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemaphore(t *testing.T) {
+	var history []string
+	var sem Semaphore
+	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+		sem = NewSemaphore(ctx, 3)
+		require.NoError(t, sem.Acquire(ctx, 2))
+		assert.False(t, sem.TryAcquire(2))
+		for _, n := range []int64{2, 1} {
+			n := n
+			Go(ctx, func(ctx Context) {
+				require.NoError(t, sem.Acquire(ctx, n))
+				history = append(history, fmt.Sprintf("acquired %v", n))
+			})
+		}
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	// the waiter of 1 unit is behind the waiter of 2 units
+	assert.Empty(t, history)
+	assert.False(t, sem.TryAcquire(1), "coroutines are waiting")
+
+	sem.Release(1)
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.Equal(t, []string{"acquired 2"}, history)
+
+	sem.Release(1)
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.Equal(t, []string{"acquired 2", "acquired 1"}, history)
+	assert.True(t, d.IsDone())
+
+	sem.Release(3)
+	assert.True(t, sem.TryAcquire(3))
+	assert.Panics(t, func() { sem.Release(4) })
+}
+
+func TestSemaphoreCancellation(t *testing.T) {
+	var history []string
+	var sem Semaphore
+	ctx := createRootTestContext(t)
+	d, _ := newDispatcher(ctx, func(ctx Context) {
+		sem = NewSemaphore(ctx, 2)
+		require.NoError(t, sem.Acquire(ctx, 1))
+		canceledCtx, cancel := WithCancel(ctx)
+		Go(canceledCtx, func(ctx Context) {
+			err := sem.Acquire(ctx, 2)
+			_, canceled := err.(*CanceledError)
+			history = append(history, fmt.Sprintf("canceled %v", canceled))
+		})
+		Go(ctx, func(ctx Context) {
+			require.NoError(t, sem.Acquire(ctx, 1))
+			history = append(history, "acquired 1")
+		})
+		Go(ctx, func(ctx Context) {
+			cancel()
+		})
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	// the canceled waiter leaves the queue, and the next one acquires the free unit
+	assert.Equal(t, []string{"canceled true", "acquired 1"}, history)
+	assert.True(t, d.IsDone())
+	assert.False(t, sem.TryAcquire(1))
+}
+
+func TestSemaphoreBoundsActivities(t *testing.T) {
+	var running, maxRunning int32
+	activityFn := func(ctx context.Context, i int) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return i, nil
+	}
+	workflowFn := func(ctx Context) (int, error) {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		sem := NewSemaphore(ctx, 2)
+		wg := NewWaitGroup(ctx)
+		sum := 0
+		for i := 1; i <= 6; i++ {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return 0, err
+			}
+			i := i
+			wg.Add(1)
+			Go(ctx, func(ctx Context) {
+				defer wg.Done()
+				defer sem.Release(1)
+				var result int
+				require.NoError(t, ExecuteActivity(ctx, activityFn, i).Get(ctx, &result))
+				sum += result
+			})
+		}
+		wg.Wait(ctx)
+		return sum, nil
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityFn)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var sum int
+	require.NoError(t, env.GetWorkflowResult(&sum))
+	assert.Equal(t, 21, sum)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(2))
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import "fmt"
+
+// SetSignalHandler docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.SetSignalHandler]
+func SetSignalHandler[T any](ctx Context, signalName string, handler func(ctx Context, value T)) error {
+	eo := getWorkflowEnvOptions(ctx)
+	if _, ok := eo.signalHandlers[signalName]; ok {
+		return fmt.Errorf("signal handler of %q is already set", signalName)
+	}
+	signals := GetTypedSignalChannel[T](ctx, signalName)
+	eo.signalHandlers[signalName] = struct{}{}
+	GoNamed(ctx, "signal-handler-"+signalName, func(ctx Context) {
+		for {
+			value, more := signals.Receive(ctx)
+			if !more {
+				return
+			}
+			handler(ctx, value)
+		}
+	})
+	return nil
+}
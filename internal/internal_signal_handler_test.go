@@ -0,0 +1,72 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSignalHandler(t *testing.T) {
+	type item struct {
+		Name string
+	}
+	var items []string
+	var unhandled []string
+	workflowFn := func(ctx Context) error {
+		err := SetSignalHandler(ctx, "add", func(ctx Context, value item) {
+			// the next signals wait for the handler to return
+			_ = Sleep(ctx, time.Minute)
+			items = append(items, value.Name)
+		})
+		if err != nil {
+			return err
+		}
+		if err := SetSignalHandler(ctx, "add", func(ctx Context, value item) {}); err == nil {
+			return NewCustomError("duplicate handler")
+		}
+		if err := Await(ctx, func() bool { return len(items) == 2 }); err != nil {
+			return err
+		}
+		unhandled = GetUnhandledSignalNames(ctx)
+		return nil
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("add", item{Name: "apple"})
+		env.SignalWorkflow("add", "not an item")
+		env.SignalWorkflow("add", item{Name: "pear"})
+		env.SignalWorkflow("add", item{Name: "plum"})
+		env.SignalWorkflow("other", "value")
+	}, time.Second)
+	env.ExecuteWorkflow(workflowFn)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, []string{"apple", "pear"}, items)
+	// plum waits for the handler and is not reported
+	assert.Equal(t, []string{"other"}, unhandled)
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"go.uber.org/multierr"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+// The default limits of the server checked by Client.StartWorkflowValidated.
+const (
+	maxWorkflowIDLength                = 1000
+	maxDecisionTaskStartToCloseTimeout = 240 * time.Second
+	maxStartWorkflowPayloadSize        = 2 * 1024 * 1024
+	maxSearchAttributesCount           = 100
+	maxSearchAttributeValueSize        = 2 * 1024
+	maxSearchAttributesTotalSize       = 40 * 1024
+)
+
+// StartWorkflowValidated validates the options, the workflow and its arguments, and starts the workflow if they are
+// valid. All the problems found are returned at once, combined with multierr, before contacting the server.
+func (wc *workflowClient) StartWorkflowValidated(
+	ctx context.Context,
+	options StartWorkflowOptions,
+	workflowFunc interface{},
+	args ...interface{},
+) (*WorkflowExecution, error) {
+	if err := wc.validateStartWorkflow(options, workflowFunc, args); err != nil {
+		return nil, err
+	}
+	return wc.StartWorkflow(ctx, options, workflowFunc, args...)
+}
+
+func (wc *workflowClient) validateStartWorkflow(options StartWorkflowOptions, workflowFunc interface{}, args []interface{}) error {
+	var errs error
+	invalid := func(field string, format string, a ...interface{}) {
+		errs = multierr.Append(errs, fmt.Errorf("%v: %v", field, fmt.Sprintf(format, a...)))
+	}
+
+	if len(options.ID) > maxWorkflowIDLength {
+		invalid("ID", "length %d exceeds the limit of %d", len(options.ID), maxWorkflowIDLength)
+	}
+	if options.TaskList == "" {
+		invalid("TaskList", "is required")
+	}
+	if options.ExecutionStartToCloseTimeout <= 0 {
+		invalid("ExecutionStartToCloseTimeout", "must be positive, got %v", options.ExecutionStartToCloseTimeout)
+	}
+	if options.DecisionTaskStartToCloseTimeout < 0 {
+		invalid("DecisionTaskStartToCloseTimeout", "must not be negative, got %v", options.DecisionTaskStartToCloseTimeout)
+	} else if options.DecisionTaskStartToCloseTimeout > maxDecisionTaskStartToCloseTimeout {
+		invalid("DecisionTaskStartToCloseTimeout", "%v exceeds the limit of %v", options.DecisionTaskStartToCloseTimeout, maxDecisionTaskStartToCloseTimeout)
+	}
+	switch options.WorkflowIDReusePolicy {
+	case WorkflowIDReusePolicyAllowDuplicateFailedOnly, WorkflowIDReusePolicyAllowDuplicate,
+		WorkflowIDReusePolicyRejectDuplicate, WorkflowIDReusePolicyTerminateIfRunning:
+	default:
+		invalid("WorkflowIDReusePolicy", "unknown policy %d", options.WorkflowIDReusePolicy)
+	}
+	if err := ValidateRetryPolicy(options.RetryPolicy); err != nil {
+		invalid("RetryPolicy", "%v", err)
+	}
+	if err := validateCronSchedule(options.CronSchedule); err != nil {
+		invalid("CronSchedule", "invalid cron expression %q: %v", options.CronSchedule, err)
+	}
+	switch options.CronOverlapPolicy {
+	case s.CronOverlapPolicySkipped, s.CronOverlapPolicyBufferone:
+	default:
+		invalid("CronOverlapPolicy", "unknown policy %v", options.CronOverlapPolicy)
+	}
+	if options.DelayStart < 0 {
+		invalid("DelayStart", "must not be negative, got %v", options.DelayStart)
+	}
+	if options.JitterStart < 0 {
+		invalid("JitterStart", "must not be negative, got %v", options.JitterStart)
+	}
+	if !options.FirstRunAt.IsZero() && options.FirstRunAt.UnixNano() < 0 {
+		invalid("FirstRunAt", "must not be before the Unix epoch, got %v", options.FirstRunAt)
+	}
+
+	if _, input, err := getValidatedWorkflowFunction(workflowFunc, args, wc.dataConverter, wc.registry); err != nil {
+		invalid("workflow", "%v", err)
+	} else if len(input) > maxStartWorkflowPayloadSize {
+		invalid("args", "encoded size %d exceeds the limit of %d bytes", len(input), maxStartWorkflowPayloadSize)
+	}
+
+	memoSize := 0
+	for key, value := range options.Memo {
+		data, err := encodeArg(wc.dataConverter, value)
+		if err != nil {
+			invalid("Memo", "unable to encode %q: %v", key, err)
+			continue
+		}
+		memoSize += len(data)
+	}
+	if memoSize > maxStartWorkflowPayloadSize {
+		invalid("Memo", "encoded size %d exceeds the limit of %d bytes", memoSize, maxStartWorkflowPayloadSize)
+	}
+
+	if len(options.SearchAttributes) > maxSearchAttributesCount {
+		invalid("SearchAttributes", "%d attributes exceed the limit of %d", len(options.SearchAttributes), maxSearchAttributesCount)
+	}
+	searchAttributesSize := 0
+	for key, value := range options.SearchAttributes {
+		if !isSearchAttributeType(reflect.TypeOf(value)) {
+			invalid("SearchAttributes", "%q has unsupported type %T, expected a string, number, bool, time.Time or a slice of them", key, value)
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			invalid("SearchAttributes", "unable to encode %q: %v", key, err)
+			continue
+		}
+		if len(data) > maxSearchAttributeValueSize {
+			invalid("SearchAttributes", "encoded size %d of %q exceeds the limit of %d bytes", len(data), key, maxSearchAttributeValueSize)
+		}
+		searchAttributesSize += len(key) + len(data)
+	}
+	if searchAttributesSize > maxSearchAttributesTotalSize {
+		invalid("SearchAttributes", "encoded size %d exceeds the limit of %d bytes", searchAttributesSize, maxSearchAttributesTotalSize)
+	}
+
+	if _, err := convertActiveClusterSelectionPolicy(options.ActiveClusterSelectionPolicy); err != nil {
+		invalid("ActiveClusterSelectionPolicy", "%v", err)
+	}
+	return errs
+}
+
+// isSearchAttributeType returns whether values of type t can be indexed as a search attribute.
+func isSearchAttributeType(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Slice && t.Elem().Kind() != reflect.Array && isSearchAttributeType(t.Elem())
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func validatedTestWorkflow(ctx Context, input string) error {
+	return nil
+}
+
+func TestStartWorkflowValidated(t *testing.T) {
+	validOptions := StartWorkflowOptions{
+		ID:                           "wid",
+		TaskList:                     "tl",
+		ExecutionStartToCloseTimeout: time.Hour,
+		CronSchedule:                 "*/5 * * * *",
+		Memo:                         map[string]interface{}{"owner": "team"},
+		SearchAttributes: map[string]interface{}{
+			"CustomKeywordField":  "keyword",
+			"CustomIntField":      1,
+			"CustomDatetimeField": time.Now(),
+			"CustomKeywordArray":  []string{"a", "b"},
+		},
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		service := workflowservicetest.NewMockClient(gomock.NewController(t))
+		service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&s.StartWorkflowExecutionResponse{RunId: common.StringPtr("rid")}, nil)
+		client := NewClient(service, "domain", &ClientOptions{})
+
+		execution, err := client.StartWorkflowValidated(context.Background(), validOptions, validatedTestWorkflow, "input")
+		require.NoError(t, err)
+		assert.Equal(t, &WorkflowExecution{ID: "wid", RunID: "rid"}, execution)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// no call is expected on the service
+		client := NewClient(workflowservicetest.NewMockClient(gomock.NewController(t)), "domain", &ClientOptions{})
+		options := StartWorkflowOptions{
+			ID:                              strings.Repeat("a", maxWorkflowIDLength+1),
+			DecisionTaskStartToCloseTimeout: time.Hour,
+			WorkflowIDReusePolicy:           WorkflowIDReusePolicy(42),
+			RetryPolicy:                     &RetryPolicy{InitialInterval: time.Second},
+			CronSchedule:                    "every minute",
+			DelayStart:                      -time.Second,
+			Memo:                            map[string]interface{}{"callback": func() {}},
+			SearchAttributes: map[string]interface{}{
+				"CustomStructField": struct{}{},
+				"CustomLongField":   strings.Repeat("a", maxSearchAttributeValueSize),
+			},
+		}
+
+		_, err := client.StartWorkflowValidated(context.Background(), options, validatedTestWorkflow, "input", "extra")
+		require.Error(t, err)
+		var problems []string
+		for _, problem := range multierr.Errors(err) {
+			problems = append(problems, strings.SplitN(problem.Error(), ":", 2)[0])
+		}
+		assert.ElementsMatch(t, []string{
+			"ID",
+			"TaskList",
+			"ExecutionStartToCloseTimeout",
+			"DecisionTaskStartToCloseTimeout",
+			"WorkflowIDReusePolicy",
+			"RetryPolicy",
+			"CronSchedule",
+			"DelayStart",
+			"workflow",
+			"Memo",
+			"SearchAttributes",
+			"SearchAttributes",
+		}, problems)
+	})
+
+	t.Run("payload size", func(t *testing.T) {
+		client := NewClient(workflowservicetest.NewMockClient(gomock.NewController(t)), "domain", &ClientOptions{})
+		_, err := client.StartWorkflowValidated(context.Background(), validOptions, validatedTestWorkflow, strings.Repeat("a", maxStartWorkflowPayloadSize))
+		require.Len(t, multierr.Errors(err), 1)
+		assert.ErrorContains(t, err, "args: encoded size")
+	})
+}
+
+func TestIsSearchAttributeType(t *testing.T) {
+	now := time.Now()
+	for _, value := range []interface{}{"a", 1, int64(1), uint8(1), 1.5, true, now, &now, []string{"a"}, [2]int{1, 2}, []time.Time{now}} {
+		assert.True(t, isSearchAttributeType(reflect.TypeOf(value)), "%T", value)
+	}
+	for _, value := range []interface{}{nil, struct{}{}, map[string]string{}, [][]string{{"a"}}, func() {}} {
+		assert.False(t, isSearchAttributeType(reflect.TypeOf(value)), "%T", value)
+	}
+}
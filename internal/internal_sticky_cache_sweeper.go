@@ -0,0 +1,118 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/cadence/internal/common/cache"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+// minStickyCacheIdleTimeout bounds how often the sticky workflow cache is checked for idle executions.
+const minStickyCacheIdleTimeout = time.Second
+
+var stickyCacheIdleTimeout time.Duration
+
+// stickyCacheSweeper is the goroutine evicting the idle executions, which runs while workflow workers are started.
+var stickyCacheSweeper struct {
+	workers int
+	stopC   chan struct{}
+}
+
+// SetStickyWorkflowCacheIdleTimeout evicts the workflow executions which did not receive a decision task for longer
+// than timeout from the sticky workflow cache, see SetStickyWorkflowCacheSize. Executions which are waiting for a long
+// time still hold their goroutines and state, and executions abandoned by the server, e.g. after the worker lost its
+// sticky task list, would only leave the cache when it is full. Evicted executions are replayed from their history
+// by their next decision task. The cache is checked every half timeout while workflow workers are running, and the
+// evictions are counted by the cadence-sticky-cache-idle-evict counter of the worker which cached the execution. The
+// timeout must be at least 1s. This must be called before any worker is started. If not called, or called with 0,
+// executions are only evicted when the cache is full.
+func SetStickyWorkflowCacheIdleTimeout(timeout time.Duration) {
+	if timeout != 0 && timeout < minStickyCacheIdleTimeout {
+		panic(fmt.Sprintf("cache idle timeout %v is below the minimum of %v.", timeout, minStickyCacheIdleTimeout))
+	}
+	stickyCacheLock.Lock()
+	defer stickyCacheLock.Unlock()
+	if workflowCache != nil {
+		panic("cache already created, please set cache idle timeout before worker starts.")
+	}
+	stickyCacheIdleTimeout = timeout
+}
+
+// startStickyCacheSweeper starts the sweeper of the sticky workflow cache for a workflow worker if an idle timeout
+// is set. The sweeper stops when the returned function was called by every worker which started it.
+func startStickyCacheSweeper() (stop func()) {
+	c := getWorkflowCache()
+	stickyCacheLock.Lock()
+	defer stickyCacheLock.Unlock()
+	if stickyCacheIdleTimeout <= 0 {
+		return func() {}
+	}
+	if stickyCacheSweeper.workers == 0 {
+		stickyCacheSweeper.stopC = make(chan struct{})
+		go sweepIdleWorkflowContexts(c, stickyCacheIdleTimeout, stickyCacheSweeper.stopC)
+	}
+	stickyCacheSweeper.workers++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			stickyCacheLock.Lock()
+			defer stickyCacheLock.Unlock()
+			stickyCacheSweeper.workers--
+			if stickyCacheSweeper.workers == 0 {
+				close(stickyCacheSweeper.stopC)
+			}
+		})
+	}
+}
+
+func sweepIdleWorkflowContexts(c cache.Cache, idleTimeout time.Duration, stopC <-chan struct{}) {
+	ticker := time.NewTicker(idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			evictIdleWorkflowContexts(c, idleTimeout, now)
+		case <-stopC:
+			return
+		}
+	}
+}
+
+// evictIdleWorkflowContexts evicts the contexts whose last decision task started more than idleTimeout before now.
+// Contexts which are locked are processing a task, they are skipped.
+func evictIdleWorkflowContexts(c cache.Cache, idleTimeout time.Duration, now time.Time) int {
+	idleSince := now.Add(-idleTimeout).UnixNano()
+	return c.RemoveIf(func(_ string, value interface{}) bool {
+		wc := value.(*workflowExecutionContextImpl)
+		if wc.lastDecisionTaskTime.Load() >= idleSince || !wc.mutex.TryLock() {
+			return false
+		}
+		wc.mutex.Unlock()
+		wc.wth.metricsScope.GetTaggedScope(tagWorkflowType, wc.workflowInfo.WorkflowType.Name).
+			Counter(metrics.StickyCacheIdleEvict).Inc(1)
+		return true
+	})
+}
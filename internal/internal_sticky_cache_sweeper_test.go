@@ -0,0 +1,129 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	"go.uber.org/cadence/internal/common/cache"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+func TestEvictIdleWorkflowContexts(t *testing.T) {
+	now := time.Now()
+	scope := tally.NewTestScope("", nil)
+	wth := &workflowTaskHandlerImpl{metricsScope: metrics.NewTaggedScope(scope)}
+	evicted := make(chan string, 3)
+	c := cache.New(10, &cache.Options{
+		RemovedFunc: func(value interface{}) {
+			evicted <- value.(*workflowExecutionContextImpl).workflowInfo.WorkflowExecution.RunID
+		},
+	})
+	newContext := func(runID string, lastDecisionTask time.Time) *workflowExecutionContextImpl {
+		wc := &workflowExecutionContextImpl{
+			workflowInfo: &WorkflowInfo{
+				WorkflowType:      WorkflowType{Name: "wf"},
+				WorkflowExecution: WorkflowExecution{RunID: runID},
+			},
+			wth: wth,
+		}
+		wc.lastDecisionTaskTime.Store(lastDecisionTask.UnixNano())
+		c.Put(runID, wc)
+		return wc
+	}
+	newContext("idle", now.Add(-2*time.Hour))
+	newContext("active", now.Add(-time.Minute))
+	busy := newContext("busy", now.Add(-2*time.Hour))
+	busy.mutex.Lock()
+	defer busy.mutex.Unlock()
+
+	assert.Equal(t, 1, evictIdleWorkflowContexts(c, time.Hour, now))
+	select {
+	case runID := <-evicted:
+		assert.Equal(t, "idle", runID)
+	case <-time.After(time.Second):
+		t.Fatal("idle context was not evicted")
+	}
+	assert.True(t, c.Exist("active"))
+	assert.True(t, c.Exist("busy"))
+
+	var count int64
+	for _, counter := range scope.Snapshot().Counters() {
+		if counter.Name() == metrics.StickyCacheIdleEvict {
+			assert.Equal(t, "wf", counter.Tags()[tagWorkflowType])
+			count += counter.Value()
+		}
+	}
+	require.Equal(t, int64(1), count)
+}
+
+func TestSetStickyWorkflowCacheIdleTimeout_TooSmall(t *testing.T) {
+	assert.PanicsWithValue(t, "cache idle timeout 1ns is below the minimum of 1s.", func() {
+		SetStickyWorkflowCacheIdleTimeout(time.Nanosecond)
+	})
+	assert.Panics(t, func() { SetStickyWorkflowCacheIdleTimeout(-time.Minute) })
+}
+
+func TestStartStickyCacheSweeper(t *testing.T) {
+	stickyCacheLock.Lock()
+	stickyCacheIdleTimeout = time.Hour
+	stickyCacheLock.Unlock()
+	defer func() {
+		stickyCacheLock.Lock()
+		stickyCacheIdleTimeout = 0
+		stickyCacheLock.Unlock()
+	}()
+
+	stop1 := startStickyCacheSweeper()
+	stop2 := startStickyCacheSweeper()
+	stickyCacheLock.Lock()
+	stopC := stickyCacheSweeper.stopC
+	assert.Equal(t, 2, stickyCacheSweeper.workers)
+	stickyCacheLock.Unlock()
+
+	stop1()
+	stop1()
+	select {
+	case <-stopC:
+		t.Fatal("the sweeper stopped while a worker is running")
+	default:
+	}
+	stop2()
+	select {
+	case <-stopC:
+	default:
+		t.Fatal("the sweeper did not stop with the last worker")
+	}
+	stickyCacheLock.Lock()
+	assert.Equal(t, 0, stickyCacheSweeper.workers)
+	stickyCacheLock.Unlock()
+
+	stickyCacheLock.Lock()
+	stickyCacheIdleTimeout = 0
+	stickyCacheLock.Unlock()
+	startStickyCacheSweeper()()
+	assert.Equal(t, 0, stickyCacheSweeper.workers, "no sweeper runs without an idle timeout")
+}
@@ -126,26 +126,39 @@ type (
 		currentDecisionTask *s.PollForDecisionTaskResponse
 		laTunnel            *localActivityTunnel
 		decisionStartTime   time.Time
+
+		historyGrowth []historyGrowthSample // samples of the history event count, see HistoryGrowthDetector
+
+		lastDecisionTaskTime atomic.Int64 // unix nanoseconds, see SetStickyWorkflowCacheIdleTimeout
 	}
 
 	// workflowTaskHandlerImpl is the implementation of WorkflowTaskHandler
 	workflowTaskHandlerImpl struct {
-		domain                         string
-		metricsScope                   *metrics.TaggedScope
-		ppMgr                          pressurePointMgr
-		logger                         *zap.Logger
-		identity                       string
-		enableLoggingInReplay          bool
-		disableStickyExecution         bool
-		registry                       *registry
-		laTunnel                       *localActivityTunnel
-		nonDeterministicWorkflowPolicy NonDeterministicWorkflowPolicy
-		dataConverter                  DataConverter
-		contextPropagators             []ContextPropagator
-		tracer                         opentracing.Tracer
-		workflowInterceptorFactories   []WorkflowInterceptorFactory
-		disableStrictNonDeterminism    bool
-		featureFlags                   FeatureFlags
+		domain                          string
+		metricsScope                    *metrics.TaggedScope
+		ppMgr                           pressurePointMgr
+		logger                          *zap.Logger
+		identity                        string
+		enableLoggingInReplay           bool
+		workflowLogBufferSize           int
+		enableDeterminismGuard          bool
+		enableActivityCallerInfo        bool
+		expireLocalActivityRetries      bool
+		workflowPanicArgs               *WorkflowPanicArgsOptions
+		decisionTaskWatchdog            *DecisionTaskWatchdogOptions
+		historyGrowthDetector           *HistoryGrowthDetector
+		recordSDKVersionMarker          bool
+		disableStickyExecution          bool
+		registry                        *registry
+		laTunnel                        *localActivityTunnel
+		nonDeterministicWorkflowPolicy  NonDeterministicWorkflowPolicy
+		dataConverter                   DataConverter
+		contextPropagators              []ContextPropagator
+		tracer                          opentracing.Tracer
+		workflowInterceptorFactories    []WorkflowInterceptorFactory
+		disableStrictNonDeterminism     bool
+		featureFlags                    FeatureFlags
+		decisionTaskNearTimeoutCallback func(DecisionTaskNearTimeoutInfo)
 	}
 
 	activityProvider func(name string) activity
@@ -360,6 +373,9 @@ OrderEvents:
 		}
 
 		eh.nextEventID++
+		if eh.eventsHandler != nil {
+			eh.eventsHandler.latencyBreakdown.handleEvent(event)
+		}
 
 		switch event.GetEventType() {
 		case s.EventTypeDecisionTaskStarted:
@@ -407,21 +423,30 @@ func newWorkflowTaskHandler(
 ) WorkflowTaskHandler {
 	ensureRequiredParams(&params)
 	wth := &workflowTaskHandlerImpl{
-		domain:                         domain,
-		logger:                         params.Logger,
-		ppMgr:                          ppMgr,
-		metricsScope:                   metrics.NewTaggedScope(params.MetricsScope),
-		identity:                       params.Identity,
-		enableLoggingInReplay:          params.EnableLoggingInReplay,
-		disableStickyExecution:         params.DisableStickyExecution,
-		registry:                       registry,
-		nonDeterministicWorkflowPolicy: params.NonDeterministicWorkflowPolicy,
-		dataConverter:                  params.DataConverter,
-		contextPropagators:             params.ContextPropagators,
-		tracer:                         params.Tracer,
-		workflowInterceptorFactories:   params.WorkflowInterceptorChainFactories,
-		disableStrictNonDeterminism:    params.WorkerBugPorts.DisableStrictNonDeterminismCheck,
-		featureFlags:                   params.FeatureFlags,
+		domain:                          domain,
+		logger:                          params.Logger,
+		ppMgr:                           ppMgr,
+		metricsScope:                    metrics.NewTaggedScope(params.MetricsScope),
+		identity:                        params.Identity,
+		enableLoggingInReplay:           params.EnableLoggingInReplay,
+		workflowLogBufferSize:           params.WorkflowLogBufferSize,
+		enableDeterminismGuard:          params.EnableDeterminismGuard,
+		enableActivityCallerInfo:        params.EnableActivityCallerInfo,
+		expireLocalActivityRetries:      !params.WorkerBugPorts.RetryLocalActivitiesAfterExpiration,
+		workflowPanicArgs:               params.WorkflowPanicArgs,
+		decisionTaskWatchdog:            params.DecisionTaskWatchdog,
+		historyGrowthDetector:           params.HistoryGrowthDetector,
+		recordSDKVersionMarker:          params.RecordSDKVersionMarker,
+		disableStickyExecution:          params.DisableStickyExecution,
+		registry:                        registry,
+		nonDeterministicWorkflowPolicy:  params.NonDeterministicWorkflowPolicy,
+		dataConverter:                   params.DataConverter,
+		contextPropagators:              params.ContextPropagators,
+		tracer:                          params.Tracer,
+		workflowInterceptorFactories:    params.WorkflowInterceptorChainFactories,
+		disableStrictNonDeterminism:     params.WorkerBugPorts.DisableStrictNonDeterminismCheck,
+		featureFlags:                    params.FeatureFlags,
+		decisionTaskNearTimeoutCallback: params.OnDecisionTaskNearTimeout,
 	}
 
 	traceLog(func() {
@@ -500,6 +525,7 @@ func newWorkflowExecutionContext(
 		workflowInfo:      workflowInfo,
 		wth:               taskHandler,
 	}
+	workflowContext.lastDecisionTaskTime.Store(time.Now().UnixNano())
 	workflowContext.createEventHandler()
 	return workflowContext
 }
@@ -619,6 +645,12 @@ func (w *workflowExecutionContextImpl) createEventHandler() {
 		w.completeWorkflow,
 		w.wth.logger,
 		w.wth.enableLoggingInReplay,
+		w.wth.workflowLogBufferSize,
+		w.wth.enableDeterminismGuard,
+		w.wth.decisionTaskWatchdog != nil,
+		w.wth.enableActivityCallerInfo,
+		w.wth.expireLocalActivityRetries,
+		w.wth.recordSDKVersionMarker,
 		w.wth.metricsScope,
 		w.wth.registry,
 		w.wth.dataConverter,
@@ -675,6 +707,8 @@ func (wth *workflowTaskHandlerImpl) createWorkflowContext(task *s.PollForDecisio
 		Domain:                              wth.domain,
 		Attempt:                             attributes.GetAttempt(),
 		lastCompletionResult:                attributes.LastCompletionResult,
+		lastFailureReason:                   attributes.GetContinuedFailureReason(),
+		lastFailureDetails:                  attributes.ContinuedFailureDetails,
 		CronSchedule:                        attributes.CronSchedule,
 		ContinuedExecutionRunID:             attributes.ContinuedExecutionRunId,
 		ParentWorkflowDomain:                attributes.ParentWorkflowDomain,
@@ -720,6 +754,12 @@ func (wth *workflowTaskHandlerImpl) getOrCreateWorkflowContext(
 		} else if history.Events[0].GetEventId() == workflowContext.previousStartedEventID+1 {
 			// non query task and we have a valid cached state
 			scope.Counter(metrics.StickyCacheHit).Inc(1)
+		} else if reconciled, reconcileErr := workflowContext.reconcileHistoryWithCachedState(task, historyIterator); reconcileErr != nil {
+			workflowContext.Unlock(reconcileErr)
+			return nil, reconcileErr
+		} else if reconciled {
+			// full history task, but the cached state only misses the events after its last decision
+			scope.Counter(metrics.StickyCacheReconciled).Inc(1)
 		} else {
 			// non query task and cached state is missing events, we need to discard the cached state and rebuild one.
 			workflowContext.ResetIfStale(task, historyIterator)
@@ -811,15 +851,31 @@ func (wth *workflowTaskHandlerImpl) ProcessWorkflowTask(
 	if err != nil {
 		return nil, err
 	}
+	if task.Query == nil {
+		workflowContext.lastDecisionTaskTime.Store(time.Now().UnixNano())
+	}
 
 	defer func() {
 		workflowContext.Unlock(errRet)
 	}()
 
 	var response interface{}
+	// the near timeout warning is raised once per task, not after each decision heartbeat of local activities
+	if nearTimeoutTimer := wth.startDecisionNearTimeoutTimer(task, time.Now(), workflowContext.GetDecisionTimeout()); nearTimeoutTimer != nil {
+		defer nearTimeoutTimer.Stop()
+	}
+	var watchdogTimer *time.Timer
+	stopWatchdog := func() {
+		if watchdogTimer != nil {
+			watchdogTimer.Stop()
+		}
+	}
+	defer stopWatchdog()
 process_Workflow_Loop:
 	for {
 		startTime := time.Now()
+		stopWatchdog()
+		watchdogTimer = wth.startDecisionTaskWatchdog(task, startTime, workflowContext.GetDecisionTimeout())
 		response, err = workflowContext.ProcessWorkflowTask(workflowTask)
 		if err == nil && response == nil {
 		wait_LocalActivity_Loop:
@@ -867,6 +923,7 @@ func (w *workflowExecutionContextImpl) ProcessWorkflowTask(workflowTask *workflo
 		return nil, err
 	}
 	w.SetCurrentTask(task)
+	w.observeHistoryGrowth(task)
 
 	eventHandler := w.getEventHandler()
 	reorderedHistory := newHistory(workflowTask, eventHandler)
@@ -975,6 +1032,9 @@ ProcessEvents:
 	if !skipReplayCheck && !w.isWorkflowCompleted || isReplayTest {
 		// check if decisions from reply matches to the history events
 		if err := matchReplayWithHistory(w.workflowInfo, replayDecisions, respondEvents); err != nil {
+			if ndErr, ok := err.(*NonDeterministicError); ok {
+				ndErr.DecisionDiff = DiffDecisionsWithHistory(replayDecisions, &s.History{Events: respondEvents})
+			}
 			nonDeterministicErr = err
 			nonDeterminismType = nonDeterminismDetectionTypeReplayComparison
 		}
@@ -1182,6 +1242,27 @@ func (w *workflowExecutionContextImpl) clearCurrentTask() {
 	w.currentDecisionTask = nil
 }
 
+// observeHistoryGrowth flags the execution when its history grows too fast, see HistoryGrowthDetector.
+func (w *workflowExecutionContextImpl) observeHistoryGrowth(task *s.PollForDecisionTaskResponse) {
+	detector := w.wth.historyGrowthDetector
+	if detector == nil || task.Query != nil {
+		return
+	}
+	var report *HistoryGrowthReport
+	var flagged bool
+	w.historyGrowth, report, flagged = detector.observe(w.workflowInfo, w.historyGrowth, task.History.GetEvents(), w.workflowInfo.HistoryCount)
+	if !flagged {
+		return
+	}
+	w.wth.metricsScope.GetTaggedScope(tagWorkflowType, report.WorkflowType).Counter(metrics.HistoryGrowthExceededCounter).Inc(1)
+	w.wth.logger.Warn("Workflow history grows too fast.",
+		zap.String(tagWorkflowType, report.WorkflowType),
+		zap.String(tagWorkflowID, report.WorkflowID),
+		zap.String(tagRunID, report.RunID),
+		zap.Int64("HistoryEvents", report.Events),
+		zap.Float64("EventsPerHour", report.EventsPerHour))
+}
+
 func (w *workflowExecutionContextImpl) skipReplayCheck() bool {
 	return w.currentDecisionTask.Query != nil || !isFullHistory(w.currentDecisionTask.History)
 }
@@ -1215,6 +1296,63 @@ func (w *workflowExecutionContextImpl) ResetIfStale(task *s.PollForDecisionTaskR
 	return nil
 }
 
+// reconcileHistoryWithCachedState drops the events of a full history task which the cached workflow state has
+// already processed, so the cached state is caught up with the missing events instead of being rebuilt by
+// replaying the whole history. The history API only pages from the first event, so pages before the cached
+// position are still fetched, but they are not replayed and later pages are only fetched when reached.
+// It returns false if the history doesn't continue from the last decision of the cached state.
+func (w *workflowExecutionContextImpl) reconcileHistoryWithCachedState(
+	task *s.PollForDecisionTaskResponse,
+	historyIterator HistoryIterator,
+) (bool, error) {
+	previousStartedEventID := w.previousStartedEventID
+	if task.Query != nil || previousStartedEventID <= 0 || !isFullHistory(task.History) ||
+		task.GetStartedEventId() <= previousStartedEventID {
+		return false, nil
+	}
+
+	events := task.History.Events
+	pageFetched := false
+	for events[len(events)-1].GetEventId() <= previousStartedEventID {
+		if historyIterator == nil || !historyIterator.HasNextPage() {
+			return false, w.restoreFirstHistoryPage(task, historyIterator, pageFetched)
+		}
+		page, err := historyIterator.GetNextPage()
+		pageFetched = true
+		if err != nil || len(page.Events) == 0 {
+			return false, w.restoreFirstHistoryPage(task, historyIterator, pageFetched)
+		}
+		events = page.Events
+	}
+
+	// the cached state must end with a decision that was completed right after it was started
+	index := previousStartedEventID + 1 - events[0].GetEventId()
+	if index < 0 {
+		return false, w.restoreFirstHistoryPage(task, historyIterator, pageFetched)
+	}
+	completed := events[index]
+	if completed.GetEventType() != s.EventTypeDecisionTaskCompleted ||
+		completed.DecisionTaskCompletedEventAttributes.GetStartedEventId() != previousStartedEventID {
+		return false, w.restoreFirstHistoryPage(task, historyIterator, pageFetched)
+	}
+
+	task.History = &s.History{Events: events[index:]}
+	return true, nil
+}
+
+// restoreFirstHistoryPage rewinds the history iterator if reconciliation already consumed some of its pages.
+func (w *workflowExecutionContextImpl) restoreFirstHistoryPage(
+	task *s.PollForDecisionTaskResponse,
+	historyIterator HistoryIterator,
+	pageFetched bool,
+) error {
+	if !pageFetched {
+		return nil
+	}
+	_, err := resetHistory(task, historyIterator)
+	return err
+}
+
 func (w *workflowExecutionContextImpl) GetDecisionTimeout() time.Duration {
 	return time.Second * time.Duration(w.workflowInfo.TaskStartToCloseTimeoutSeconds)
 }
@@ -1237,6 +1375,38 @@ func (w *workflowExecutionContextImpl) getWorkflowPanicIfIllegaleStatePanic() (*
 	return panicErr, true
 }
 
+// processQuery answers a query against the replayed workflow state and emits per query type
+// latency, success, failure and rejection metrics.
+func (wth *workflowTaskHandlerImpl) processQuery(
+	eventHandler *workflowExecutionEventHandlerImpl,
+	queryType string,
+	queryArgs []byte,
+) ([]byte, error) {
+	startTime := time.Now()
+	result, err := eventHandler.ProcessQuery(queryType, queryArgs)
+	if errors.Is(err, errUnknownQueryType) {
+		// query types of rejected queries are caller provided, don't use them as tag values
+		wth.queryMetricsScope(eventHandler, "unknown").Counter(metrics.QueryHandlerRejectedCounter).Inc(1)
+		return nil, err
+	}
+
+	metricsScope := wth.queryMetricsScope(eventHandler, queryType)
+	metricsScope.Timer(metrics.QueryHandlerLatency).Record(time.Since(startTime))
+	if err != nil {
+		metricsScope.Counter(metrics.QueryHandlerFailedCounter).Inc(1)
+		return nil, err
+	}
+	metricsScope.Counter(metrics.QueryHandlerSucceedCounter).Inc(1)
+	return result, nil
+}
+
+func (wth *workflowTaskHandlerImpl) queryMetricsScope(eventHandler *workflowExecutionEventHandlerImpl, queryType string) tally.Scope {
+	return wth.metricsScope.GetTaggedScope(
+		tagWorkflowType, eventHandler.workflowEnvironmentImpl.workflowInfo.WorkflowType.Name,
+		tagQueryType, queryType,
+	)
+}
+
 func (wth *workflowTaskHandlerImpl) completeWorkflow(
 	eventHandler *workflowExecutionEventHandlerImpl,
 	task *s.PollForDecisionTaskResponse,
@@ -1256,6 +1426,7 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 				zap.String(tagPanicStack, panicErr.StackTrace()),
 			)
 
+			wth.queryMetricsScope(eventHandler, task.Query.GetQueryType()).Counter(metrics.QueryHandlerRejectedCounter).Inc(1)
 			queryCompletedRequest.CompletedType = common.QueryTaskCompletedTypePtr(s.QueryTaskCompletedTypeFailed)
 			queryCompletedRequest.ErrorMessage = common.StringPtr("Workflow panic: " + panicErr.Error())
 			return queryCompletedRequest
@@ -1273,7 +1444,7 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 			)
 		}
 
-		result, err := eventHandler.ProcessQuery(task.Query.GetQueryType(), task.Query.QueryArgs)
+		result, err := wth.processQuery(eventHandler, task.Query.GetQueryType(), task.Query.QueryArgs)
 		if err != nil {
 			queryCompletedRequest.CompletedType = common.QueryTaskCompletedTypePtr(s.QueryTaskCompletedTypeFailed)
 			queryCompletedRequest.ErrorMessage = common.StringPtr(err.Error())
@@ -1290,13 +1461,23 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 	if panicErr, ok := workflowContext.err.(*workflowPanicError); ok {
 		// Workflow panic
 		metricsScope.Counter(metrics.DecisionTaskPanicCounter).Inc(1)
-		wth.logger.Error("Workflow panic.",
+		fields := []zap.Field{
 			zap.String(tagWorkflowType, task.WorkflowType.GetName()),
 			zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
 			zap.String(tagRunID, task.WorkflowExecution.GetRunId()),
 			zap.String(tagPanicError, panicErr.Error()),
-			zap.String(tagPanicStack, panicErr.StackTrace()))
-		return errorToFailDecisionTask(task.TaskToken, panicErr, wth.identity)
+			zap.String(tagPanicStack, panicErr.StackTrace()),
+		}
+		args := formatWorkflowPanicArgs(wth.workflowPanicArgs, eventHandler.workflowDefinition)
+		if args != "" {
+			fields = append(fields, zap.String(tagWorkflowArgs, args))
+		}
+		wth.logger.Error("Workflow panic.", fields...)
+		failRequest := errorToFailDecisionTask(task.TaskToken, panicErr, wth.identity)
+		if args != "" {
+			failRequest.Details = []byte(fmt.Sprintf("%s\n%s: %s", failRequest.Details, tagWorkflowArgs, args))
+		}
+		return failRequest
 	}
 
 	// complete decision task
@@ -1351,6 +1532,10 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 			elapsed,
 			metrics.High1ms24h,
 		)
+		eventHandler.latencyBreakdown.emit(metricsScope, time.Since(workflowContext.decisionStartTime))
+		if wth.historyGrowthDetector != nil {
+			wth.historyGrowthDetector.forget(workflowContext.workflowInfo.WorkflowExecution.RunID)
+		}
 		forceNewDecision = false
 	}
 
@@ -1358,7 +1543,7 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 	if len(task.Queries) != 0 {
 		queryResults = make(map[string]*s.WorkflowQueryResult)
 		for queryID, query := range task.Queries {
-			result, err := eventHandler.ProcessQuery(query.GetQueryType(), query.QueryArgs)
+			result, err := wth.processQuery(eventHandler, query.GetQueryType(), query.QueryArgs)
 			if err != nil {
 				queryResults[queryID] = &s.WorkflowQueryResult{
 					ResultType:   common.QueryResultTypePtr(s.QueryResultTypeFailed),
@@ -1417,7 +1602,7 @@ type cadenceInvoker struct {
 	identity              string
 	service               workflowserviceclient.Interface
 	taskToken             []byte
-	cancelHandler         func()
+	cancelHandler         context.CancelCauseFunc
 	heartBeatTimeoutInSec int32       // The heart beat interval configured for this activity.
 	hbBatchEndTimer       *time.Timer // Whether we started a batch of operations that need to be reported in the cycle. This gets started on a user call.
 	detailsToReport       *[]byte     // Details to be reported in the next reporting interval.
@@ -1551,13 +1736,17 @@ func (i *cadenceInvoker) internalHeartBeat(details []byte) (bool, error) {
 	switch err.(type) {
 	case *CanceledError:
 		// We are asked to cancel. inform the activity about cancellation through context.
-		i.cancelHandler()
+		i.cancelHandler(ErrActivityCancelRequested)
 		isActivityCancelled = true
 
-	case *s.EntityNotExistsError, *s.WorkflowExecutionAlreadyCompletedError, *s.DomainNotActiveError:
+	case *s.EntityNotExistsError, *s.WorkflowExecutionAlreadyCompletedError:
 		// We will pass these through as cancellation for now but something we can change
 		// later when we have setter on cancel handler.
-		i.cancelHandler()
+		i.cancelHandler(fmt.Errorf("%w: %w", ErrActivityWorkflowClosed, err))
+		isActivityCancelled = true
+
+	case *s.DomainNotActiveError:
+		i.cancelHandler(fmt.Errorf("%w: %w", ErrActivityDomainNotActive, err))
 		isActivityCancelled = true
 	}
 
@@ -1588,7 +1777,7 @@ func newServiceInvoker(
 	taskToken []byte,
 	identity string,
 	service workflowserviceclient.Interface,
-	cancelHandler func(),
+	cancelHandler context.CancelCauseFunc,
 	heartBeatTimeoutInSec int32,
 	workerStopChannel <-chan struct{},
 	featureFlags FeatureFlags,
@@ -38,6 +38,7 @@ import (
 	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
 	"go.uber.org/goleak"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -46,6 +47,7 @@ import (
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	s "go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 )
 
 const (
@@ -438,6 +440,109 @@ func (t *TaskHandlersTestSuite) TestWorkflowTask_ActivityTaskScheduled() {
 	t.NotNil(response.Decisions[0].CompleteWorkflowExecutionDecisionAttributes)
 }
 
+func (t *TaskHandlersTestSuite) TestWorkflowTask_LatencyBreakdown() {
+	taskList := &s.TaskList{Name: common.StringPtr("taskList"), Kind: s.TaskListKindNormal.Ptr()}
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		createTestEventActivityTaskScheduled(5, &s.ActivityTaskScheduledEventAttributes{
+			ActivityId:   common.StringPtr("0"),
+			ActivityType: &s.ActivityType{Name: common.StringPtr("Greeter_Activity")},
+			TaskList:     taskList,
+		}),
+		createTestEventActivityTaskStarted(6, &s.ActivityTaskStartedEventAttributes{}),
+		createTestEventActivityTaskCompleted(7, &s.ActivityTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(5)}),
+		createTestEventDecisionTaskScheduled(8, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(9),
+	}
+	start := time.Unix(1000, 0)
+	for i, at := range []time.Duration{0, time.Second, 3 * time.Second, 4 * time.Second, 4 * time.Second, 5 * time.Second, time.Minute, time.Minute, time.Minute} {
+		testEvents[i].Timestamp = common.Int64Ptr(start.Add(at).UnixNano())
+	}
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		WorkerOptions: WorkerOptions{
+			Identity:     "test-id-1",
+			Logger:       t.logger,
+			MetricsScope: testScope,
+		},
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: createWorkflowTask(testEvents, 3, "HelloWorld_Workflow")}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Equal(s.DecisionTypeCompleteWorkflowExecution, response.Decisions[0].GetDecisionType())
+
+	histograms := testScope.Snapshot().Histograms()
+	tags := "_ns+WorkflowType=HelloWorld_Workflow"
+	t.Require().Contains(histograms, metrics.WorkflowFirstDecisionScheduledToStartLatency+tags)
+	assertHistogramValue(t.T(), histograms[metrics.WorkflowFirstDecisionScheduledToStartLatency+tags], 2*time.Second)
+	assertHistogramValue(t.T(), histograms[metrics.WorkflowBlockedOnActivitiesLatency+tags], 56*time.Second)
+	assertHistogramValue(t.T(), histograms[metrics.WorkflowBlockedOnTimersLatency+tags], 0)
+	t.Contains(histograms, metrics.WorkflowDecisionProcessingLatency+tags)
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_HistoryGrowthDetector() {
+	taskList := &s.TaskList{Name: common.StringPtr("taskList"), Kind: s.TaskListKindNormal.Ptr()}
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	testEvents[0].Timestamp = common.Int64Ptr(time.Now().Add(-time.Minute).UnixNano())
+	testScope := tally.NewTestScope("", nil)
+	detector := NewHistoryGrowthDetector(HistoryGrowthDetectorOptions{MaxEventsPerHour: 10, MinEvents: 2})
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		WorkerOptions: WorkerOptions{
+			Identity:              "test-id-1",
+			Logger:                t.logger,
+			MetricsScope:          testScope,
+			HistoryGrowthDetector: detector,
+		},
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	task := createWorkflowTask(testEvents, 0, "HelloWorld_Workflow")
+	task.NextEventId = common.Int64Ptr(4)
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Equal(s.DecisionTypeScheduleActivityTask, response.Decisions[0].GetDecisionType())
+
+	counters := testScope.Snapshot().Counters()
+	t.Require().Contains(counters, metrics.HistoryGrowthExceededCounter+"+WorkflowType=HelloWorld_Workflow")
+	t.EqualValues(1, counters[metrics.HistoryGrowthExceededCounter+"+WorkflowType=HelloWorld_Workflow"].Value())
+	flagged := detector.Flagged()
+	t.Require().Len(flagged, 1)
+	t.Equal("HelloWorld_Workflow", flagged[0].WorkflowType)
+	t.EqualValues(3, flagged[0].Events)
+	t.Greater(flagged[0].EventsPerHour, 10.0)
+
+	// the second execution grows too fast as well, but is forgotten once it completes
+	testEvents = append(testEvents,
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		createTestEventActivityTaskScheduled(5, &s.ActivityTaskScheduledEventAttributes{
+			ActivityId:   common.StringPtr("0"),
+			ActivityType: &s.ActivityType{Name: common.StringPtr("Greeter_Activity")},
+			TaskList:     taskList,
+		}),
+		createTestEventActivityTaskStarted(6, &s.ActivityTaskStartedEventAttributes{}),
+		createTestEventActivityTaskCompleted(7, &s.ActivityTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(5)}),
+		createTestEventDecisionTaskScheduled(8, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(9),
+	)
+	task = createWorkflowTask(testEvents, 3, "HelloWorld_Workflow")
+	task.NextEventId = common.Int64Ptr(10)
+	request, err = taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	response = request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Equal(s.DecisionTypeCompleteWorkflowExecution, response.Decisions[0].GetDecisionType())
+	t.Equal(flagged, detector.Flagged())
+}
+
 func (t *TaskHandlersTestSuite) TestWorkflowTask_QueryWorkflow() {
 	// Schedule an activity and see if we complete workflow.
 	taskList := &s.TaskList{Name: common.StringPtr("taskList"), Kind: s.TaskListKindNormal.Ptr()}
@@ -556,6 +661,104 @@ func (t *TaskHandlersTestSuite) TestWorkflowTask_QueryWorkflow_2() {
 	t.Contains(*queryResp.ErrorMessage, "unknown queryType")
 }
 
+func (t *TaskHandlersTestSuite) TestWorkflowTask_QueryMetrics() {
+	taskList := &s.TaskList{Name: common.StringPtr("taskList"), Kind: s.TaskListKindNormal.Ptr()}
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		WorkerOptions: WorkerOptions{
+			Identity:     "test-id-1",
+			Logger:       t.logger,
+			MetricsScope: testScope,
+		},
+	}
+
+	task := createQueryTask(testEvents, 3, "HelloWorld_Workflow", queryType)
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	response, _ := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.verifyQueryResult(response, "waiting-activity-result")
+
+	task = createQueryTask(testEvents, 3, "HelloWorld_Workflow", "invalid-query-type")
+	taskHandler = newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	response, _ = taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NotNil(response.(*s.RespondQueryTaskCompletedRequest).ErrorMessage)
+
+	snapshot := testScope.Snapshot()
+	succeed := snapshot.Counters()[metrics.QueryHandlerSucceedCounter+"+QueryType="+queryType+",WorkflowType=HelloWorld_Workflow"]
+	t.NotNil(succeed)
+	t.Equal(int64(1), succeed.Value())
+	t.NotNil(snapshot.Timers()[metrics.QueryHandlerLatency+"+QueryType="+queryType+",WorkflowType=HelloWorld_Workflow"])
+	rejected := snapshot.Counters()[metrics.QueryHandlerRejectedCounter+"+QueryType=unknown,WorkflowType=HelloWorld_Workflow"]
+	t.NotNil(rejected)
+	t.Equal(int64(1), rejected.Value())
+}
+
+func (t *TaskHandlersTestSuite) TestReconcileHistoryWithCachedState() {
+	taskList := &s.TaskList{Name: common.StringPtr("taskList")}
+	events := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{
+			ScheduledEventId: common.Int64Ptr(2), StartedEventId: common.Int64Ptr(3),
+		}),
+		createTestEventWorkflowExecutionSignaled(5, "test-signal"),
+		createTestEventDecisionTaskScheduled(6, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(7),
+	}
+	newTask := func(firstPage []*s.HistoryEvent) *s.PollForDecisionTaskResponse {
+		return &s.PollForDecisionTaskResponse{
+			StartedEventId: common.Int64Ptr(7),
+			History:        &s.History{Events: firstPage},
+		}
+	}
+
+	// the cached state is at the decision started in event 3
+	workflowContext := &workflowExecutionContextImpl{previousStartedEventID: 3}
+	task := newTask(events)
+	reconciled, err := workflowContext.reconcileHistoryWithCachedState(task, nil)
+	t.NoError(err)
+	t.True(reconciled)
+	t.Equal(events[3:], task.History.Events)
+
+	// the missing events are on the second page
+	iterator := &historyIteratorImpl{
+		nextPageToken: []byte("page-2"),
+		iteratorFunc: func(nextPageToken []byte) (*s.History, []byte, error) {
+			if nextPageToken == nil {
+				return &s.History{Events: events[:3]}, []byte("page-2"), nil
+			}
+			return &s.History{Events: events[3:]}, nil, nil
+		},
+	}
+	task = newTask(events[:3])
+	reconciled, err = workflowContext.reconcileHistoryWithCachedState(task, iterator)
+	t.NoError(err)
+	t.True(reconciled)
+	t.Equal(events[3:], task.History.Events)
+
+	// the last decision of the cached state was not completed, the first page is restored
+	iterator.nextPageToken = []byte("page-2")
+	workflowContext = &workflowExecutionContextImpl{previousStartedEventID: 4}
+	task = newTask(events[:3])
+	reconciled, err = workflowContext.reconcileHistoryWithCachedState(task, iterator)
+	t.NoError(err)
+	t.False(reconciled)
+	t.Equal(events[:3], task.History.Events)
+	t.True(iterator.HasNextPage())
+
+	// partial history tasks are not reconciled
+	task = newTask(events[3:])
+	reconciled, err = workflowContext.reconcileHistoryWithCachedState(task, nil)
+	t.NoError(err)
+	t.False(reconciled)
+}
+
 func (t *TaskHandlersTestSuite) verifyQueryResult(response interface{}, expectedResult string) {
 	t.NotNil(response)
 	queryResp, ok := response.(*s.RespondQueryTaskCompletedRequest)
@@ -837,6 +1040,65 @@ func (t *TaskHandlersTestSuite) TestWorkflowTask_NondeterministicDetection() {
 	t.NotNil(request)
 }
 
+func (t *TaskHandlersTestSuite) TestWorkflowTask_SDKVersionMarker() {
+	taskList := &s.TaskList{Name: common.StringPtr("taskList"), Kind: s.TaskListKindNormal.Ptr()}
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		WorkerOptions: WorkerOptions{
+			Identity:               "test-id-1",
+			Logger:                 t.logger,
+			RecordSDKVersionMarker: true,
+		},
+	}
+	startEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: createWorkflowTask(startEvents, 0, "HelloWorld_Workflow")}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Equal(2, len(response.Decisions))
+	t.Equal(s.DecisionTypeRecordMarker, response.Decisions[0].GetDecisionType())
+	t.Equal(sdkVersionMarkerName, response.Decisions[0].RecordMarkerDecisionAttributes.GetMarkerName())
+	t.Equal(s.DecisionTypeScheduleActivityTask, response.Decisions[1].GetDecisionType())
+
+	history := func(withMarker bool) []*s.HistoryEvent {
+		events := append([]*s.HistoryEvent{}, startEvents...)
+		events = append(events, createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}))
+		if withMarker {
+			events = append(events, &s.HistoryEvent{
+				EventId:   common.Int64Ptr(int64(len(events) + 1)),
+				EventType: common.EventTypePtr(s.EventTypeMarkerRecorded),
+				MarkerRecordedEventAttributes: &s.MarkerRecordedEventAttributes{
+					MarkerName:                   common.StringPtr(sdkVersionMarkerName),
+					Details:                      response.Decisions[0].RecordMarkerDecisionAttributes.Details,
+					DecisionTaskCompletedEventId: common.Int64Ptr(4),
+				},
+			})
+		}
+		return append(events, createTestEventActivityTaskScheduled(int64(len(events)+1), &s.ActivityTaskScheduledEventAttributes{
+			ActivityId:   common.StringPtr("0"),
+			ActivityType: &s.ActivityType{Name: common.StringPtr("Greeter_Activity")},
+			TaskList:     taskList,
+		}))
+	}
+
+	// the marker is ignored by the replay checks, whether the workers record it or not
+	for _, record := range []bool{true, false} {
+		for _, withMarker := range []bool{true, false} {
+			params.RecordSDKVersionMarker = record
+			params.NonDeterministicWorkflowPolicy = NonDeterministicWorkflowPolicyFailWorkflow
+			taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+			request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: createWorkflowTask(history(withMarker), 3, "HelloWorld_Workflow")}, nil)
+			t.NoError(err)
+			response := request.(*s.RespondDecisionTaskCompletedRequest)
+			t.Empty(response.Decisions, "record %v, with marker %v", record, withMarker)
+		}
+	}
+}
+
 func (t *TaskHandlersTestSuite) TestWorkflowTask_NondeterministicLogNonexistingID() {
 	taskList := &s.TaskList{Name: common.StringPtr("taskList"), Kind: s.TaskListKindNormal.Ptr()}
 	testEvents := []*s.HistoryEvent{
@@ -954,6 +1216,37 @@ func (t *TaskHandlersTestSuite) TestWorkflowTask_WorkflowPanics() {
 	require.Equal(t.T(), "PanicWorkflow", wfTypeField.String)
 }
 
+func (t *TaskHandlersTestSuite) TestWorkflowTask_WorkflowPanicArgs() {
+	taskList := &s.TaskList{Name: common.StringPtr("taskList"), Kind: s.TaskListKindNormal.Ptr()}
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: taskList, Input: []byte("input")}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: taskList}),
+		createTestEventDecisionTaskStarted(3),
+	}
+
+	obs, logs := observer.New(zap.ErrorLevel)
+	task := createWorkflowTask(testEvents, 3, "PanicWorkflow")
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		WorkerOptions: WorkerOptions{
+			Identity:          "test-id-1",
+			Logger:            zap.New(obs),
+			WorkflowPanicArgs: &WorkflowPanicArgsOptions{},
+		},
+	}
+
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	r, ok := request.(*s.RespondDecisionTaskFailedRequest)
+	t.Require().True(ok)
+	t.Equal("panicError\nWorkflowArgs: [\"aW5wdXQ=\"]", string(r.Details))
+
+	panicLogs := logs.FilterMessage("Workflow panic.")
+	t.Require().Len(panicLogs.All(), 1)
+	t.Equal(`["aW5wdXQ="]`, panicLogs.All()[0].ContextMap()[tagWorkflowArgs])
+}
+
 func (t *TaskHandlersTestSuite) TestGetWorkflowInfo() {
 	taskList := &s.TaskList{Name: common.StringPtr("taskList"), Kind: s.TaskListKindNormal.Ptr()}
 	parentID := "parentID"
@@ -1429,7 +1722,7 @@ func (t *TaskHandlersTestSuite) TestHeartBeat_NilResponseWithError() {
 		nil,
 		"Test_Cadence_Invoker",
 		mockService,
-		func() {},
+		func(error) {},
 		0,
 		make(chan struct{}),
 		FeatureFlags{},
@@ -1453,7 +1746,7 @@ func (t *TaskHandlersTestSuite) TestHeartBeat_NilResponseWithDomainNotActiveErro
 	mockService.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, domainNotActiveError)
 
 	called := false
-	cancelHandler := func() { called = true }
+	cancelHandler := func(error) { called = true }
 
 	cadenceInvoker := newServiceInvoker(
 		nil,
@@ -1475,6 +1768,58 @@ func (t *TaskHandlersTestSuite) TestHeartBeat_NilResponseWithDomainNotActiveErro
 	t.True(called)
 }
 
+func (t *TaskHandlersTestSuite) TestHeartBeat_CancelCause() {
+	tests := []struct {
+		name       string
+		response   *s.RecordActivityTaskHeartbeatResponse
+		err        error
+		cause      error
+		serviceErr interface{}
+	}{
+		{
+			name:     "cancel requested",
+			response: &s.RecordActivityTaskHeartbeatResponse{CancelRequested: common.BoolPtr(true)},
+			cause:    ErrActivityCancelRequested,
+		},
+		{
+			name:       "workflow closed",
+			err:        &s.WorkflowExecutionAlreadyCompletedError{},
+			cause:      ErrActivityWorkflowClosed,
+			serviceErr: new(*s.WorkflowExecutionAlreadyCompletedError),
+		},
+		{
+			name:       "activity not found",
+			err:        &s.EntityNotExistsError{},
+			cause:      ErrActivityWorkflowClosed,
+			serviceErr: new(*s.EntityNotExistsError),
+		},
+		{
+			name:       "domain not active",
+			err:        &s.DomainNotActiveError{},
+			cause:      ErrActivityDomainNotActive,
+			serviceErr: new(*s.DomainNotActiveError),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func() {
+			mockCtrl := gomock.NewController(t.T())
+			mockService := workflowservicetest.NewMockClient(mockCtrl)
+			mockService.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions()...).Return(tt.response, tt.err)
+
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cadenceInvoker := newServiceInvoker(nil, "Test_Cadence_Invoker", mockService, cancel, 0, make(chan struct{}), FeatureFlags{}, testlogger.NewZap(t.T()), testWorkflowType, testActivityType)
+			t.Error(cadenceInvoker.BatchHeartbeat(nil))
+
+			<-ctx.Done()
+			t.Equal(context.Canceled, ctx.Err())
+			t.ErrorIs(context.Cause(ctx), tt.cause)
+			if tt.serviceErr != nil {
+				t.ErrorAs(context.Cause(ctx), tt.serviceErr)
+			}
+		})
+	}
+}
+
 type testActivityDeadline struct {
 	logger *zap.Logger
 	d      time.Duration
@@ -37,6 +37,7 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/pborman/uuid"
 	"github.com/uber-go/tally"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
@@ -81,9 +82,14 @@ type (
 		service      workflowserviceclient.Interface
 		taskHandler  WorkflowTaskHandler
 		ldaTunnel    localDispatcher
+		autoResetter *autoResetter
+		filter       *WorkflowFilter
+		breaker      *DecisionCircuitBreaker
 		metricsScope *metrics.TaggedScope
 		logger       *zap.Logger
 
+		scheduleToStartLatency *latencyWindow
+
 		stickyUUID                   string
 		disableStickyExecution       bool
 		StickyScheduleToStartTimeout time.Duration
@@ -93,6 +99,7 @@ type (
 		stickyBacklog           int64
 		requestLock             sync.Mutex
 		featureFlags            FeatureFlags
+		historyPrefetchPages    int
 	}
 
 	// activityTaskPoller implements polling/processing a workflow task
@@ -105,8 +112,9 @@ type (
 		taskHandler         ActivityTaskHandler
 		metricsScope        *metrics.TaggedScope
 		logger              *zap.Logger
-		activitiesPerSecond float64
+		activitiesPerSecond *atomic.Float64 // can be changed while polling, see WorkerAdmin
 		featureFlags        FeatureFlags
+		fairScheduler       *activityFairScheduler // nil unless WorkerOptions.ActivityFairness is set
 	}
 
 	// locallyDispatchedActivityTaskPoller implements polling/processing a locally dispatched activity task
@@ -125,6 +133,8 @@ type (
 		startedEventID int64
 		maxEventID     int64 // Equivalent to History Count
 		featureFlags   FeatureFlags
+		prefetchPages  int
+		prefetcher     *historyPrefetcher
 	}
 
 	localActivityTaskPoller struct {
@@ -143,6 +153,7 @@ type (
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
 		activityTracker    debug.ActivityTracker
+		client             Client
 	}
 
 	localActivityResult struct {
@@ -305,12 +316,17 @@ func newWorkflowTaskPoller(
 		identity:                     params.Identity,
 		taskHandler:                  taskHandler,
 		ldaTunnel:                    ldaTunnelInterface,
+		autoResetter:                 newAutoResetter(service, domain, params),
+		filter:                       params.WorkflowFilter,
+		breaker:                      params.DecisionCircuitBreaker,
+		scheduleToStartLatency:       newLatencyWindow(latencyWindowSize),
 		metricsScope:                 metrics.NewTaggedScope(params.MetricsScope),
 		logger:                       params.Logger,
 		stickyUUID:                   uuid.New(),
 		disableStickyExecution:       params.DisableStickyExecution,
 		StickyScheduleToStartTimeout: params.StickyScheduleToStartTimeout,
 		featureFlags:                 params.FeatureFlags,
+		historyPrefetchPages:         params.HistoryPrefetchPages,
 	}
 }
 
@@ -349,6 +365,24 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 		})
 		return nil
 	}
+	if !wtp.filter.Allows(task.task.WorkflowType.GetName(), task.task.WorkflowExecution.GetWorkflowId()) {
+		wtp.metricsScope.Counter(metrics.DecisionTaskFilteredCounter).Inc(1)
+		wtp.logger.Debug("Decision task rejected by workflow filter.",
+			zap.String(tagWorkflowType, task.task.WorkflowType.GetName()),
+			zap.String(tagWorkflowID, task.task.WorkflowExecution.GetWorkflowId()),
+			zap.String(tagRunID, task.task.WorkflowExecution.GetRunId()))
+		return wtp.rejectWorkflowTask(task.task, "workflow is not allowed by the filter of worker "+wtp.identity)
+	}
+	if !wtp.breaker.allow(task.task.WorkflowType.GetName()) {
+		// same as for filtered tasks, the task is processed by another worker, or by this one once the breaker closes
+		wtp.metricsScope.GetTaggedScope(tagWorkflowType, task.task.WorkflowType.GetName()).
+			Counter(metrics.DecisionTaskCircuitBreakerRejectedCounter).Inc(1)
+		wtp.logger.Debug("Decision task rejected by open circuit breaker.",
+			zap.String(tagWorkflowType, task.task.WorkflowType.GetName()),
+			zap.String(tagWorkflowID, task.task.WorkflowExecution.GetWorkflowId()),
+			zap.String(tagRunID, task.task.WorkflowExecution.GetRunId()))
+		return wtp.rejectWorkflowTask(task.task, "decision circuit breaker of worker "+wtp.identity+" is open for the workflow type")
+	}
 	doneCh := make(chan struct{})
 	laResultCh := make(chan *localActivityResult)
 	// close doneCh so local activity worker won't get blocked forever when trying to send back result to laResultCh.
@@ -398,6 +432,33 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 	}
 }
 
+// rejectWorkflowTask hands a task the worker does not process back to the server. A decision task is failed, which
+// resets the sticky task list of the workflow and schedules the task again on the normal task list right away,
+// instead of after the decision timeout. A query task is failed with the reason, so the caller does not wait for
+// the query timeout.
+func (wtp *workflowTaskPoller) rejectWorkflowTask(task *s.PollForDecisionTaskResponse, reason string) error {
+	removeWorkflowContext(task.WorkflowExecution.GetRunId())
+	var request interface{}
+	if task.Query != nil {
+		request = &s.RespondQueryTaskCompletedRequest{
+			TaskToken:     task.TaskToken,
+			CompletedType: common.QueryTaskCompletedTypePtr(s.QueryTaskCompletedTypeFailed),
+			ErrorMessage:  common.StringPtr(reason),
+		}
+	} else {
+		cause := s.DecisionTaskFailedCauseResetStickyTasklist
+		request = &s.RespondDecisionTaskFailedRequest{
+			TaskToken:      task.TaskToken,
+			Cause:          &cause,
+			Details:        []byte(reason),
+			Identity:       common.StringPtr(wtp.identity),
+			BinaryChecksum: common.StringPtr(getBinaryChecksum()),
+		}
+	}
+	_, err := wtp.respondTaskCompleted(request, task)
+	return err
+}
+
 func (wtp *workflowTaskPoller) processResetStickinessTask(rst *resetStickinessTask) error {
 	tchCtx, cancel, opt := newChannelContext(context.Background(), wtp.featureFlags)
 	defer cancel()
@@ -422,10 +483,21 @@ func (wtp *workflowTaskPoller) RespondTaskCompletedWithMetrics(completedRequest
 			zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
 			zap.String(tagRunID, task.WorkflowExecution.GetRunId()),
 			zap.Error(taskErr))
+		if wtp.breaker.onResult(task.WorkflowType.GetName(), true) {
+			metricsScope.Counter(metrics.DecisionCircuitBreakerTrippedCounter).Inc(1)
+			wtp.logger.Warn("Decision circuit breaker tripped, decision tasks of the workflow type are not processed until the cooldown ends.",
+				zap.String(tagWorkflowType, task.WorkflowType.GetName()),
+				zap.Duration("Cooldown", wtp.breaker.options.Cooldown))
+		}
+		if wtp.autoResetter != nil && wtp.autoResetter.onDecisionTaskFailed(task, taskErr) {
+			// the reset terminated the run of the decision task, there is nothing to respond to
+			return nil, nil
+		}
 		// convert err to DecisionTaskFailed
 		completedRequest = errorToFailDecisionTask(task.TaskToken, taskErr, wtp.identity)
 	} else {
 		metricsScope.Counter(metrics.DecisionTaskCompletedCounter).Inc(1)
+		wtp.breaker.onResult(task.WorkflowType.GetName(), false)
 	}
 
 	metrics.EmitLatency(
@@ -577,6 +649,7 @@ func newLocalActivityPoller(params workerExecutionParameters, laTunnel *localAct
 		contextPropagators: params.ContextPropagators,
 		tracer:             params.Tracer,
 		activityTracker:    params.WorkerStats.ActivityTracker,
+		client:             params.activityClient,
 	}
 	return &localActivityTaskPoller{
 		basePoller:   basePoller{shutdownC: params.WorkerStopChannel},
@@ -640,6 +713,9 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 		workflowType:      &workflowTypeLocal,
 		workflowDomain:    task.params.WorkflowInfo.Domain,
 		taskList:          task.params.WorkflowInfo.TaskListName,
+		workflowTaskList:  task.params.WorkflowInfo.TaskListName,
+		parentDomain:      task.params.WorkflowInfo.ParentWorkflowDomain,
+		parentExecution:   task.params.WorkflowInfo.ParentWorkflowExecution,
 		activityType:      ActivityType{Name: activityType},
 		activityID:        fmt.Sprintf("%v", task.activityID),
 		workflowExecution: task.params.WorkflowInfo.WorkflowExecution,
@@ -648,6 +724,7 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 		isLocalActivity:   true,
 		dataConverter:     lath.dataConverter,
 		attempt:           task.attempt,
+		client:            lath.client,
 	})
 
 	// propagate context information into the local activity activity context from the headers
@@ -898,6 +975,9 @@ func (wtp *workflowTaskPoller) poll(ctx context.Context) (interface{}, error) {
 		scheduledToStartLatency,
 		metrics.High1ms24h,
 	)
+	if wtp.scheduleToStartLatency != nil {
+		wtp.scheduleToStartLatency.record(scheduledToStartLatency)
+	}
 	return task, nil
 }
 
@@ -930,6 +1010,7 @@ func (wtp *workflowTaskPoller) toWorkflowTask(response *s.PollForDecisionTaskRes
 		startedEventID: startEventID,
 		maxEventID:     nextEventID - 1,
 		featureFlags:   wtp.featureFlags,
+		prefetchPages:  wtp.historyPrefetchPages,
 	}
 	task := &workflowTask{
 		task:            response,
@@ -952,6 +1033,10 @@ func (h *historyIteratorImpl) GetNextPage() (*s.History, error) {
 			h.featureFlags)
 	}
 
+	if h.prefetchPages > 0 {
+		return h.getNextPrefetchedPage()
+	}
+
 	history, token, err := h.iteratorFunc(h.nextPageToken)
 	if err != nil {
 		return nil, err
@@ -960,7 +1045,28 @@ func (h *historyIteratorImpl) GetNextPage() (*s.History, error) {
 	return history, nil
 }
 
+func (h *historyIteratorImpl) getNextPrefetchedPage() (*s.History, error) {
+	if h.prefetcher == nil {
+		h.prefetcher = newHistoryPrefetcher(h.iteratorFunc, h.nextPageToken, h.prefetchPages)
+	}
+	history, token, err := h.prefetcher.next()
+	if err != nil {
+		// the next call starts over from the page that failed
+		h.prefetcher = nil
+		return nil, err
+	}
+	h.nextPageToken = token
+	if token == nil {
+		h.prefetcher = nil
+	}
+	return history, nil
+}
+
 func (h *historyIteratorImpl) Reset() {
+	if h.prefetcher != nil {
+		h.prefetcher.stop()
+		h.prefetcher = nil
+	}
 	h.nextPageToken = nil
 }
 
@@ -1024,6 +1130,7 @@ func newGetHistoryPageFunc(
 		} else {
 			h = resp.History
 		}
+		metricsScope.Counter(metrics.WorkflowGetHistoryBytes).Inc(int64(estimateHistoryPageSize(h)))
 
 		// TODO: is this check valid/useful? atDecisionTaskCompletedEventID is startedEventID in pollForDecisionTaskResponse and
 		// - For decision tasks, since there's only one inflight decision task, there won't be any event after startEventID.
@@ -1074,9 +1181,17 @@ func newActivityTaskPoller(taskHandler ActivityTaskHandler, service workflowserv
 		identity:            params.Identity,
 		logger:              params.Logger,
 		metricsScope:        metrics.NewTaggedScope(params.MetricsScope),
-		activitiesPerSecond: params.TaskListActivitiesPerSecond,
+		activitiesPerSecond: atomic.NewFloat64(params.TaskListActivitiesPerSecond),
 		featureFlags:        params.FeatureFlags,
 	}
+	if params.ActivityFairness != nil {
+		activityTaskPoller.fairScheduler = newActivityFairScheduler(
+			*params.ActivityFairness,
+			params.MaxConcurrentActivityExecutionSize,
+			activityTaskPoller.metricsScope,
+			params.Logger,
+		)
+	}
 	return activityTaskPoller
 }
 
@@ -1093,7 +1208,7 @@ func (atp *activityTaskPoller) poll(ctx context.Context) (*s.PollForActivityTask
 		Domain:           common.StringPtr(atp.domain),
 		TaskList:         atp.taskList,
 		Identity:         common.StringPtr(atp.identity),
-		TaskListMetadata: &s.TaskListMetadata{MaxTasksPerSecond: &atp.activitiesPerSecond},
+		TaskListMetadata: &s.TaskListMetadata{MaxTasksPerSecond: common.Float64Ptr(atp.activitiesPerSecond.Load())},
 	}
 	response, err := atp.service.PollForActivityTask(ctx, request, getYarpcCallOptions(atp.featureFlags)...)
 
@@ -1201,6 +1316,23 @@ func (atp *activityTaskPoller) ProcessTask(task interface{}) error {
 	workflowType := activityTask.task.WorkflowType.GetName()
 	activityType := activityTask.task.ActivityType.GetName()
 	metricsScope := getMetricsScopeForActivity(atp.metricsScope, workflowType, activityType)
+	if tagsProvider, ok := atp.taskHandler.(activityMetricTagsProvider); ok {
+		if tags := tagsProvider.activityMetricTags(activityTask.task); len(tags) > 0 {
+			metricsScope = atp.metricsScope.GetTaggedScope(append([]string{tagWorkflowType, workflowType, tagActivityType, activityType}, tags...)...)
+		}
+	}
+
+	if atp.fairScheduler != nil {
+		tenant := defaultActivityTenant
+		if infoProvider, ok := atp.taskHandler.(activityTenantInfoProvider); ok {
+			tenant = atp.fairScheduler.tenantKey(infoProvider.activityTenantInfo(activityTask.task))
+		}
+		release, err := atp.fairScheduler.acquire(tenant, atp.shutdownC)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
 
 	executionStartTime := time.Now()
 	// Process the activity task.
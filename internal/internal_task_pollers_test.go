@@ -33,6 +33,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/tally"
+	"go.uber.org/atomic"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	s "go.uber.org/cadence/.gen/go/shared"
@@ -368,7 +369,7 @@ func buildActivityTaskPoller(t *testing.T, shutdown bool) (*activityTaskPoller,
 		service:             mockService,
 		metricsScope:        &metrics.TaggedScope{Scope: tally.NewTestScope("test", nil)},
 		logger:              testlogger.NewZap(t),
-		activitiesPerSecond: 0.0,
+		activitiesPerSecond: atomic.NewFloat64(0),
 		featureFlags:        FeatureFlags{},
 	}, mockService
 }
@@ -0,0 +1,52 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/cadence/internal/common/serviceerror"
+)
+
+// ThrottleInfo describes a service call which the server rejected because of rate limiting or overload.
+type ThrottleInfo struct {
+	// Operation is the name of the service call, e.g. "StartWorkflowExecution".
+	Operation string
+	// Err is a *serviceerror.ServiceBusy or *serviceerror.LimitExceeded.
+	Err error
+	// RetryAfter is the minimum delay before retrying the call, zero for LimitExceeded errors which are not retried.
+	RetryAfter time.Duration
+}
+
+// newThrottleCallback adapts the OnThrottle option to the service wrapper, it returns nil if onThrottle is nil.
+func newThrottleCallback(onThrottle func(ThrottleInfo)) func(operation string, err error) {
+	if onThrottle == nil {
+		return nil
+	}
+	return func(operation string, err error) {
+		info := ThrottleInfo{Operation: operation, Err: err}
+		if serviceBusy := (*serviceerror.ServiceBusy)(nil); errors.As(err, &serviceBusy) {
+			info.RetryAfter = serviceBusy.RetryAfter
+		}
+		onThrottle(info)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+const timeZoneSideEffectIDPrefix = "cadence-time-zone:"
+
+// LoadLocation docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.LoadLocation]
+func LoadLocation(ctx Context, name string) (*time.Location, error) {
+	switch name {
+	case "", "UTC":
+		return time.UTC, nil
+	case "Local":
+		return nil, errors.New("the Local time zone depends on the host and cannot be used in workflows")
+	}
+
+	// only the name of a time zone which could be loaded is recorded, so the outcome does not depend on the worker
+	// replaying the workflow
+	var loaded string
+	err := MutableSideEffect(ctx, timeZoneSideEffectIDPrefix+name, func(ctx Context) interface{} {
+		if _, err := time.LoadLocation(name); err != nil {
+			return ""
+		}
+		return name
+	}, func(a, b interface{}) bool {
+		return a.(string) == b.(string)
+	}).Get(&loaded)
+	if err != nil {
+		return nil, err
+	}
+	if loaded == "" {
+		return nil, fmt.Errorf("unknown time zone %s", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		// fails the decision task, so it is retried, possibly on a worker having the tzdata
+		panic(fmt.Sprintf("time zone %s was loaded before but not on this worker: %v", name, err))
+	}
+	return loc, nil
+}
+
+// NextBusinessDay docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.NextBusinessDay]
+func NextBusinessDay(ctx Context, tz string) (time.Time, error) {
+	loc, err := LoadLocation(ctx, tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := Now(ctx).In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return day, nil
+}
+
+// NextCronTime docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.NextCronTime]
+func NextCronTime(ctx Context, cronSchedule string, tz string) (time.Time, error) {
+	schedule, err := cron.ParseStandard(cronSchedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron schedule %q: %w", cronSchedule, err)
+	}
+	loc, err := LoadLocation(ctx, tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := schedule.Next(Now(ctx).In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron schedule %q never fires", cronSchedule)
+	}
+	return next, nil
+}
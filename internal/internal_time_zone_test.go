@@ -0,0 +1,92 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflowTimeZone(t *testing.T) {
+	type result struct {
+		Offset       int
+		BusinessDay  time.Time
+		CronTime     time.Time
+		UTCCronTime  time.Time
+		UnknownError string
+		LocalError   string
+	}
+	workflowFn := func(ctx Context) (result, error) {
+		var r result
+		loc, err := LoadLocation(ctx, "America/New_York")
+		if err != nil {
+			return r, err
+		}
+		_, r.Offset = Now(ctx).In(loc).Zone()
+		if r.BusinessDay, err = NextBusinessDay(ctx, "America/New_York"); err != nil {
+			return r, err
+		}
+		if r.CronTime, err = NextCronTime(ctx, "0 9 * * *", "Europe/Berlin"); err != nil {
+			return r, err
+		}
+		if r.UTCCronTime, err = NextCronTime(ctx, "0 9 * * *", "UTC"); err != nil {
+			return r, err
+		}
+		if _, err := LoadLocation(ctx, "Mars/Olympus_Mons"); err != nil {
+			r.UnknownError = err.Error()
+		}
+		if _, err := LoadLocation(ctx, "Local"); err != nil {
+			r.LocalError = err.Error()
+		}
+		return r, nil
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	// Friday 18:30 in New York, Saturday 00:30 in Berlin
+	env.SetStartTime(time.Date(2026, 10, 16, 22, 30, 0, 0, time.UTC))
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var r result
+	require.NoError(t, env.GetWorkflowResult(&r))
+
+	assert.Equal(t, -4*60*60, r.Offset)
+	assert.True(t, time.Date(2026, 10, 19, 4, 0, 0, 0, time.UTC).Equal(r.BusinessDay), r.BusinessDay)
+	assert.True(t, time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC).Equal(r.CronTime), r.CronTime)
+	assert.True(t, time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC).Equal(r.UTCCronTime), r.UTCCronTime)
+	assert.Contains(t, r.UnknownError, "unknown time zone")
+	assert.Contains(t, r.LocalError, "cannot be used in workflows")
+
+	// each time zone is recorded once
+	markers := 0
+	for _, decisions := range env.GetProducedDecisions() {
+		for _, decision := range decisions {
+			if decision.RecordMarkerDecisionAttributes != nil {
+				markers++
+			}
+		}
+	}
+	assert.Equal(t, 3, markers)
+}
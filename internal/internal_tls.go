@@ -0,0 +1,225 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/transport/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSOptions configures TLS connections to the Cadence frontend, see NewTLSConfig.
+type TLSOptions struct {
+	// CAFile is a PEM file with the certificate authorities the frontend certificate is verified with.
+	// Default: the system certificate authorities.
+	CAFile string
+
+	// CertFile and KeyFile are PEM files with the client certificate and its key, for mutual TLS.
+	// They must be set together.
+	CertFile string
+	KeyFile  string
+
+	// ServerName overrides the name the frontend certificate is verified against, e.g. when connecting through a
+	// load balancer. Default: the host of the dialed address.
+	// With ReloadInterval and CAFile, it is required when the transport does not send the name of the host, e.g. when
+	// the dialed host is an IP address, and the connection fails otherwise.
+	ServerName string
+
+	// MinVersion is the minimum TLS version, e.g. tls.VersionTLS13. Default: tls.VersionTLS12.
+	MinVersion uint16
+
+	// InsecureSkipVerify disables the verification of the frontend certificate. Only use it for testing.
+	InsecureSkipVerify bool
+
+	// ReloadInterval is how often the files are read again, so that rotated certificates are used by new
+	// connections without restarting the process. Files which fail to load keep the previous certificates in use.
+	// Default: 0, which loads the files once.
+	ReloadInterval time.Duration
+}
+
+// tlsReloader holds the certificates loaded from the files of TLSOptions and reloads them when they change.
+type tlsReloader struct {
+	options TLSOptions
+	now     func() time.Time
+
+	lock        sync.Mutex
+	loadedAt    time.Time
+	files       [][]byte
+	certificate *tls.Certificate
+	rootCAs     *x509.CertPool
+}
+
+// NewTLSConfig docs are in the public API to prevent duplication: [go.uber.org/cadence/client.NewTLSConfig]
+func NewTLSConfig(options TLSOptions) (*tls.Config, error) {
+	return newTLSConfig(options, time.Now)
+}
+
+// NewGRPCOutbound docs are in the public API to prevent duplication: [go.uber.org/cadence/client.NewGRPCOutbound]
+func NewGRPCOutbound(hostPort string, options TLSOptions) (transport.UnaryOutbound, error) {
+	tlsConfig, err := NewTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	t := grpc.NewTransport()
+	dialer := t.NewDialer(grpc.DialerCredentials(credentials.NewTLS(tlsConfig)))
+	return t.NewOutbound(peer.NewSingle(hostport.Identify(hostPort), dialer)), nil
+}
+
+func newTLSConfig(options TLSOptions, now func() time.Time) (*tls.Config, error) {
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		return nil, errors.New("TLS CertFile and KeyFile must be set together")
+	}
+	if options.MinVersion == 0 {
+		options.MinVersion = tls.VersionTLS12
+	}
+	r := &tlsReloader{options: options, now: now}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.loadedAt = now()
+
+	config := &tls.Config{
+		ServerName:         options.ServerName,
+		MinVersion:         options.MinVersion,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+	if options.ReloadInterval <= 0 {
+		config.RootCAs = r.rootCAs
+		if r.certificate != nil {
+			config.Certificates = []tls.Certificate{*r.certificate}
+		}
+		return config, nil
+	}
+
+	if options.CertFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.reloadIfDue()
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			return r.certificate, nil
+		}
+	}
+	if options.CAFile != "" && !options.InsecureSkipVerify {
+		// the root certificate authorities of a tls.Config cannot change, so the verification done by crypto/tls is
+		// replaced by one using the latest certificate authorities
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = r.verifyConnection
+	}
+	return config, nil
+}
+
+// load reads the files, and replaces the certificates if the files changed since they were last loaded.
+func (r *tlsReloader) load() error {
+	var files [][]byte
+	for _, name := range []string{r.options.CAFile, r.options.CertFile, r.options.KeyFile} {
+		var data []byte
+		if name != "" {
+			var err error
+			if data, err = os.ReadFile(name); err != nil {
+				return fmt.Errorf("failed to read TLS file: %w", err)
+			}
+		}
+		files = append(files, data)
+	}
+
+	r.lock.Lock()
+	unchanged := r.files != nil && bytes.Equal(files[0], r.files[0]) && bytes.Equal(files[1], r.files[1]) &&
+		bytes.Equal(files[2], r.files[2])
+	r.lock.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	var rootCAs *x509.CertPool
+	if len(files[0]) > 0 {
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(files[0]) {
+			return fmt.Errorf("TLS CAFile %v does not contain any PEM certificate", r.options.CAFile)
+		}
+	}
+	var certificate *tls.Certificate
+	if len(files[1]) > 0 {
+		c, err := tls.X509KeyPair(files[1], files[2])
+		if err != nil {
+			return fmt.Errorf("failed to load TLS CertFile and KeyFile: %w", err)
+		}
+		certificate = &c
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.files, r.rootCAs, r.certificate = files, rootCAs, certificate
+	return nil
+}
+
+func (r *tlsReloader) reloadIfDue() {
+	r.lock.Lock()
+	due := r.now().Sub(r.loadedAt) >= r.options.ReloadInterval
+	if due {
+		r.loadedAt = r.now()
+	}
+	r.lock.Unlock()
+	if due {
+		// on failure the previous certificates stay in use, e.g. while the files are being replaced
+		_ = r.load()
+	}
+}
+
+func (r *tlsReloader) verifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("TLS server did not present a certificate")
+	}
+	r.reloadIfDue()
+	r.lock.Lock()
+	rootCAs := r.rootCAs
+	r.lock.Unlock()
+
+	// the name is not sent by crypto/tls when the dialed host is an IP address, or when the transport does not set
+	// it, and an empty name would skip the verification of the host
+	serverName := state.ServerName
+	if serverName == "" {
+		serverName = r.options.ServerName
+	}
+	if serverName == "" {
+		return errors.New("TLS ServerName must be set to verify the server certificate")
+	}
+	options := x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         rootCAs,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, certificate := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(certificate)
+	}
+	_, err := state.PeerCertificates[0].Verify(options)
+	return err
+}
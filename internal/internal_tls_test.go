@@ -0,0 +1,222 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPEM     []byte
+	keyPEM      []byte
+}
+
+func newTestCertificate(t *testing.T, name string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		certPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCertificate) tlsCertificate(t *testing.T) tls.Certificate {
+	certificate, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	require.NoError(t, err)
+	return certificate
+}
+
+// handshake connects the client config to a server presenting serverCert, and returns the client certificate the
+// server received.
+func handshake(t *testing.T, config *tls.Config, serverCert tls.Certificate) (*x509.Certificate, error) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	server := tls.Server(serverConn, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequestClientCert,
+	})
+	serverDone := make(chan *x509.Certificate, 1)
+	go func() {
+		defer serverConn.Close()
+		var peer *x509.Certificate
+		if server.Handshake() == nil && len(server.ConnectionState().PeerCertificates) > 0 {
+			peer = server.ConnectionState().PeerCertificates[0]
+		}
+		serverDone <- peer
+	}()
+	err := tls.Client(clientConn, config).Handshake()
+	clientConn.Close()
+	return <-serverDone, err
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+func TestTLSConfig(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	server := newTestCertificate(t, "cadence-frontend", ca)
+	client := newTestCertificate(t, "worker", ca)
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestFile(t, caFile, ca.certPEM)
+	writeTestFile(t, certFile, client.certPEM)
+	writeTestFile(t, keyFile, client.keyPEM)
+
+	config, err := NewTLSConfig(TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "cadence-frontend"})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	peer, err := handshake(t, config, server.tlsCertificate(t))
+	require.NoError(t, err)
+	assert.Equal(t, "worker", peer.Subject.CommonName)
+
+	config.ServerName = "other-host"
+	_, err = handshake(t, config, server.tlsCertificate(t))
+	assert.Error(t, err, "the server name is verified")
+
+	_, err = NewTLSConfig(TLSOptions{CertFile: certFile})
+	assert.ErrorContains(t, err, "CertFile and KeyFile must be set together")
+	_, err = NewTLSConfig(TLSOptions{CAFile: keyFile})
+	assert.ErrorContains(t, err, "does not contain any PEM certificate")
+	_, err = NewTLSConfig(TLSOptions{CAFile: filepath.Join(dir, "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read TLS file")
+}
+
+func TestGRPCOutbound(t *testing.T) {
+	outbound, err := NewGRPCOutbound("localhost:7833", TLSOptions{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, outbound.Transports())
+
+	_, err = NewGRPCOutbound("localhost:7833", TLSOptions{CertFile: "cert.pem"})
+	assert.ErrorContains(t, err, "CertFile and KeyFile must be set together")
+}
+
+func TestTLSConfigReload(t *testing.T) {
+	oldCA, newCA := newTestCertificate(t, "old-ca", nil), newTestCertificate(t, "new-ca", nil)
+	oldServer, newServer := newTestCertificate(t, "cadence-frontend", oldCA), newTestCertificate(t, "cadence-frontend", newCA)
+	oldClient, newClient := newTestCertificate(t, "old-worker", oldCA), newTestCertificate(t, "new-worker", newCA)
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestFile(t, caFile, oldCA.certPEM)
+	writeTestFile(t, certFile, oldClient.certPEM)
+	writeTestFile(t, keyFile, oldClient.keyPEM)
+
+	now := time.Now()
+	config, err := newTLSConfig(TLSOptions{
+		CAFile:         caFile,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ServerName:     "cadence-frontend",
+		MinVersion:     tls.VersionTLS13,
+		ReloadInterval: time.Minute,
+	}, func() time.Time { return now })
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+
+	peer, err := handshake(t, config, oldServer.tlsCertificate(t))
+	require.NoError(t, err)
+	assert.Equal(t, "old-worker", peer.Subject.CommonName)
+	_, err = handshake(t, config, newServer.tlsCertificate(t))
+	assert.Error(t, err, "the server certificate is verified with the loaded certificate authorities")
+
+	writeTestFile(t, caFile, newCA.certPEM)
+	writeTestFile(t, certFile, newClient.certPEM)
+	writeTestFile(t, keyFile, newClient.keyPEM)
+	_, err = handshake(t, config, newServer.tlsCertificate(t))
+	assert.Error(t, err, "the files are not reloaded before the interval")
+
+	now = now.Add(time.Minute)
+	peer, err = handshake(t, config, newServer.tlsCertificate(t))
+	require.NoError(t, err)
+	assert.Equal(t, "new-worker", peer.Subject.CommonName)
+
+	writeTestFile(t, certFile, []byte("being replaced"))
+	now = now.Add(time.Minute)
+	peer, err = handshake(t, config, newServer.tlsCertificate(t))
+	require.NoError(t, err, "files failing to load keep the previous certificates")
+	assert.Equal(t, "new-worker", peer.Subject.CommonName)
+}
+
+func TestTLSConfigReloadServerName(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	server := newTestCertificate(t, "cadence-frontend", ca)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	writeTestFile(t, caFile, ca.certPEM)
+
+	config, err := NewTLSConfig(TLSOptions{CAFile: caFile, ReloadInterval: time.Minute})
+	require.NoError(t, err)
+	_, err = handshake(t, config, server.tlsCertificate(t))
+	assert.ErrorContains(t, err, "TLS ServerName must be set", "the host is not left unverified")
+
+	config, err = NewTLSConfig(TLSOptions{CAFile: caFile, ServerName: "cadence-frontend", ReloadInterval: time.Minute})
+	require.NoError(t, err)
+	config.ServerName = ""
+	_, err = handshake(t, config, server.tlsCertificate(t))
+	assert.NoError(t, err, "the configured name is verified when it is not sent, e.g. for an IP address")
+
+	config, err = NewTLSConfig(TLSOptions{CAFile: caFile, ServerName: "other-host", ReloadInterval: time.Minute})
+	require.NoError(t, err)
+	config.ServerName = ""
+	_, err = handshake(t, config, server.tlsCertificate(t))
+	assert.Error(t, err, "the configured name is verified")
+}
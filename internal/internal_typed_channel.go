@@ -0,0 +1,100 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+type (
+	// TypedChannel is a Channel of values of type T, so that the values sent and received are checked by the compiler.
+	TypedChannel[T any] interface {
+		// Receive blocks until it receives a value, and returns it. more is false when the channel is closed and
+		// empty, along with the zero value of T.
+		Receive(ctx Context) (value T, more bool)
+		// ReceiveWithCancel is like Receive, and also stops blocking when ctx is canceled, returning canceled equal to
+		// true. ok is false when no value was received, i.e. ctx was canceled or the channel is closed and empty.
+		ReceiveWithCancel(ctx Context) (value T, ok bool, canceled bool)
+		// ReceiveAsync tries to receive a value without blocking. ok is false when no value was received.
+		ReceiveAsync() (value T, ok bool)
+		// ReceiveAsyncWithMoreFlag is like ReceiveAsync, and returns more equal to false when the channel is closed.
+		ReceiveAsyncWithMoreFlag() (value T, ok bool, more bool)
+		// Send blocks until the value is sent.
+		Send(ctx Context, value T)
+		// SendAsync tries to send the value without blocking, and returns true if it was sent.
+		SendAsync(value T) (ok bool)
+		// Close closes the channel.
+		Close()
+		// Channel returns the underlying Channel, e.g. to add it to a Selector.
+		Channel() Channel
+	}
+
+	typedChannel[T any] struct {
+		channel Channel
+	}
+)
+
+// NewTypedChannel creates a new TypedChannel.
+func NewTypedChannel[T any](ctx Context) TypedChannel[T] {
+	return typedChannel[T]{channel: NewChannel(ctx)}
+}
+
+// NewTypedBufferedChannel creates a new buffered TypedChannel.
+func NewTypedBufferedChannel[T any](ctx Context, size int) TypedChannel[T] {
+	return typedChannel[T]{channel: NewBufferedChannel(ctx, size)}
+}
+
+// GetTypedSignalChannel returns the channel of the signals of signalName, whose values are decoded into a T.
+func GetTypedSignalChannel[T any](ctx Context, signalName string) TypedChannel[T] {
+	return typedChannel[T]{channel: GetSignalChannel(ctx, signalName)}
+}
+
+func (c typedChannel[T]) Receive(ctx Context) (value T, more bool) {
+	more = c.channel.Receive(ctx, &value)
+	return value, more
+}
+
+func (c typedChannel[T]) ReceiveWithCancel(ctx Context) (value T, ok bool, canceled bool) {
+	ok, canceled = ReceiveWithCancel(ctx, c.channel, &value)
+	return value, ok, canceled
+}
+
+func (c typedChannel[T]) ReceiveAsync() (value T, ok bool) {
+	ok = c.channel.ReceiveAsync(&value)
+	return value, ok
+}
+
+func (c typedChannel[T]) ReceiveAsyncWithMoreFlag() (value T, ok bool, more bool) {
+	ok, more = c.channel.ReceiveAsyncWithMoreFlag(&value)
+	return value, ok, more
+}
+
+func (c typedChannel[T]) Send(ctx Context, value T) {
+	c.channel.Send(ctx, value)
+}
+
+func (c typedChannel[T]) SendAsync(value T) (ok bool) {
+	return c.channel.SendAsync(value)
+}
+
+func (c typedChannel[T]) Close() {
+	c.channel.Close()
+}
+
+func (c typedChannel[T]) Channel() Channel {
+	return c.channel
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedChannelApproval struct {
+	Approver string
+	Approved bool
+}
+
+func TestGetTypedSignalChannel(t *testing.T) {
+	workflowFn := func(ctx Context) ([]typedChannelApproval, error) {
+		approvals := GetTypedSignalChannel[typedChannelApproval](ctx, "approval")
+		var received []typedChannelApproval
+		for len(received) < 2 {
+			var approval typedChannelApproval
+			NewSelector(ctx).AddReceive(approvals.Channel(), func(Channel, bool) {
+				approval, _ = approvals.Receive(ctx)
+			}).Select(ctx)
+			received = append(received, approval)
+		}
+		return received, nil
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("approval", typedChannelApproval{Approver: "alice", Approved: true})
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		// dropped, as it cannot be decoded into a typedChannelApproval
+		env.SignalWorkflow("approval", "corrupted")
+	}, 2*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("approval", typedChannelApproval{Approver: "bob"})
+	}, 3*time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var received []typedChannelApproval
+	require.NoError(t, env.GetWorkflowResult(&received))
+	assert.Equal(t, []typedChannelApproval{{Approver: "alice", Approved: true}, {Approver: "bob"}}, received)
+}
+
+func TestTypedChannel(t *testing.T) {
+	workflowFn := func(ctx Context) ([]int, error) {
+		buffered := NewTypedBufferedChannel[int](ctx, 2)
+		assert.True(t, buffered.SendAsync(1))
+		buffered.Send(ctx, 2)
+		assert.False(t, buffered.SendAsync(3))
+		value, ok := buffered.ReceiveAsync()
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+
+		unbuffered := NewTypedChannel[int](ctx)
+		Go(ctx, func(ctx Context) {
+			unbuffered.Send(ctx, 4)
+			unbuffered.Close()
+		})
+		var values []int
+		for {
+			value, more := unbuffered.Receive(ctx)
+			if !more {
+				break
+			}
+			values = append(values, value)
+		}
+		value, ok, more := unbuffered.ReceiveAsyncWithMoreFlag()
+		assert.Equal(t, []interface{}{0, false, false}, []interface{}{value, ok, more})
+		value, ok, canceled := unbuffered.ReceiveWithCancel(ctx)
+		assert.Equal(t, []interface{}{0, false, false}, []interface{}{value, ok, canceled})
+
+		value, _ = buffered.Receive(ctx)
+		return append(values, value), nil
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var values []int
+	require.NoError(t, env.GetWorkflowResult(&values))
+	assert.Equal(t, []int{4, 2}, values)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+type (
+	// TypedFuture is a Future whose value is decoded into a T, so that it is retrieved without passing a pointer.
+	TypedFuture[T any] interface {
+		// Get blocks until the future is ready, and returns its value decoded into a T, or its error.
+		Get(ctx Context) (T, error)
+		// IsReady returns true when the future is ready, i.e. Get does not block.
+		IsReady() bool
+		// Future returns the underlying Future, e.g. to add it to a Selector.
+		Future() Future
+	}
+
+	typedFuture[T any] struct {
+		future Future
+	}
+)
+
+// NewTypedFuture wraps future, whose value is decoded into a T.
+func NewTypedFuture[T any](future Future) TypedFuture[T] {
+	return typedFuture[T]{future: future}
+}
+
+// ExecuteActivityTyped executes an activity like ExecuteActivity, and returns a future of its result decoded into a T.
+func ExecuteActivityTyped[T any](ctx Context, activity interface{}, args ...interface{}) TypedFuture[T] {
+	return NewTypedFuture[T](ExecuteActivity(ctx, activity, args...))
+}
+
+// ExecuteLocalActivityTyped executes a local activity like ExecuteLocalActivity, and returns a future of its result
+// decoded into a T.
+func ExecuteLocalActivityTyped[T any](ctx Context, activity interface{}, args ...interface{}) TypedFuture[T] {
+	return NewTypedFuture[T](ExecuteLocalActivity(ctx, activity, args...))
+}
+
+func (f typedFuture[T]) Get(ctx Context) (T, error) {
+	var value T
+	err := f.future.Get(ctx, &value)
+	return value, err
+}
+
+func (f typedFuture[T]) IsReady() bool {
+	return f.future.IsReady()
+}
+
+func (f typedFuture[T]) Future() Future {
+	return f.future
+}
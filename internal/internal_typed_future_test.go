@@ -0,0 +1,109 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedFutureOrder struct {
+	ID    string
+	Items []string
+}
+
+func TestTypedFuture(t *testing.T) {
+	loadOrder := func(_ context.Context, id string) (*typedFutureOrder, error) {
+		if id == "" {
+			return nil, errors.New("missing order id")
+		}
+		return &typedFutureOrder{ID: id, Items: []string{"apple"}}, nil
+	}
+	countItems := func(_ context.Context, order *typedFutureOrder) (int, error) {
+		return len(order.Items), nil
+	}
+
+	workflowFn := func(ctx Context) (int, error) {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		ctx = WithLocalActivityOptions(ctx, LocalActivityOptions{ScheduleToCloseTimeout: time.Minute})
+
+		future := ExecuteActivityTyped[*typedFutureOrder](ctx, loadOrder, "order-1")
+		assert.False(t, future.IsReady())
+		var selected bool
+		NewSelector(ctx).AddFuture(future.Future(), func(Future) { selected = true }).Select(ctx)
+		assert.True(t, selected)
+		assert.True(t, future.IsReady())
+		order, err := future.Get(ctx)
+		if err != nil {
+			return 0, err
+		}
+		assert.Equal(t, &typedFutureOrder{ID: "order-1", Items: []string{"apple"}}, order)
+
+		failed, err := ExecuteActivityTyped[*typedFutureOrder](ctx, loadOrder, "").Get(ctx)
+		assert.Nil(t, failed)
+		assert.ErrorContains(t, err, "missing order id")
+
+		return ExecuteLocalActivityTyped[int](ctx, countItems, order).Get(ctx)
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(loadOrder)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var count int
+	require.NoError(t, env.GetWorkflowResult(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestNewTypedFuture(t *testing.T) {
+	workflowFn := func(ctx Context) (string, error) {
+		future, settable := NewFuture(ctx)
+		typed := NewTypedFuture[string](future)
+		Go(ctx, func(ctx Context) {
+			_ = Sleep(ctx, time.Second)
+			settable.Set("value", nil)
+		})
+		assert.False(t, typed.IsReady())
+		assert.Equal(t, future, typed.Future())
+		return typed.Get(ctx)
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var result string
+	require.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, "value", result)
+}
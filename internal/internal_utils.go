@@ -84,6 +84,10 @@ type (
 		// Deprecated: use AutoScalerOptions instead
 		PollerAutoScalerEnabled   bool
 		EphemeralTaskListsEnabled bool
+		// ServiceErrorsEnabled makes the clients return the errors of the service as the types of the serviceerror
+		// package, e.g. *serviceerror.EntityNotExists, which unwrap to the thrift errors. Type assertions on the
+		// thrift errors must be replaced by errors.As before enabling it.
+		ServiceErrorsEnabled bool
 		// MetricEmitMode controls how latency metrics are emitted (timers, histograms, or both).
 		// Default: metrics.EmitBoth (dual-emit for safe migration) - set automatically if not specified.
 		// Set to metrics.EmitTimersOnly for legacy OSS behavior, or metrics.EmitHistogramsOnly post-migration.
@@ -313,6 +317,9 @@ func getErrorDetails(err error, dataConverter DataConverter) (string, []byte) {
 		}
 		return fmt.Sprintf("%v %v", errReasonTimeout, err.timeoutType), data
 	default:
+		if reason, data, ok := globalFailureTypes.encode(err, dataConverter); ok {
+			return reason, data
+		}
 		// will be convert to GenericError when receiving from server.
 		return errReasonGeneric, []byte(err.Error())
 	}
@@ -347,8 +354,8 @@ func constructError(reason string, details []byte, dataConverter DataConverter)
 		details := newEncodedValues(details, dataConverter)
 		return NewCanceledError(details)
 	default:
-		details := newEncodedValues(details, dataConverter)
-		err := NewCustomError(reason, details)
+		err := NewCustomError(reason, newEncodedValues(details, dataConverter))
+		err.failure, _ = globalFailureTypes.decode(reason, details, dataConverter)
 		return err
 	}
 }
@@ -379,6 +386,15 @@ func getTimeoutTypeFromErrReason(reason string) (s.TimeoutType, error) {
 	return timeoutType, nil
 }
 
+// estimateHistoryPageSize returns the estimated size in bytes of the events of a history page.
+func estimateHistoryPageSize(history *s.History) int {
+	size := 0
+	for _, event := range history.GetEvents() {
+		size += estimateHistorySize(zap.NewNop(), event)
+	}
+	return size
+}
+
 func estimateHistorySize(logger *zap.Logger, event *s.HistoryEvent) int {
 	sum := historySizeEstimationBuffer
 	switch event.GetEventType() {
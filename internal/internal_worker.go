@@ -55,6 +55,8 @@ import (
 	"go.uber.org/cadence/internal/common/auth"
 	"go.uber.org/cadence/internal/common/backoff"
 	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/cadence/internal/common/payloadlog"
+	"go.uber.org/cadence/internal/common/serviceerror"
 	"go.uber.org/cadence/internal/common/util"
 )
 
@@ -99,6 +101,7 @@ type (
 		localActivityWorker *baseWorker
 		identity            string
 		stopC               chan struct{}
+		stopSweeper         func()
 	}
 
 	// ActivityWorker wraps the code for hosting activity types.
@@ -141,6 +144,9 @@ type (
 		// Context cancel function to cancel user context
 		UserContextCancel context.CancelFunc
 
+		// Client returned by GetActivityClient
+		activityClient Client
+
 		// WorkerStopTimeout is the time delay before hard terminate worker
 		WorkerStopTimeout time.Duration
 
@@ -149,6 +155,9 @@ type (
 
 		// SessionResourceID is a unique identifier of the resource the session will consume
 		SessionResourceID string
+
+		// admissionController pauses activity polling based on ActivityAdmissionControl, shared by all activity workers
+		admissionController *admissionController
 	}
 )
 
@@ -340,6 +349,7 @@ func (ww *workflowWorker) Start() error {
 	if err != nil {
 		return err
 	}
+	ww.stopSweeper = startStickyCacheSweeper()
 	ww.localActivityWorker.Start()
 	ww.worker.Start()
 	return nil // TODO: propagate error
@@ -350,6 +360,8 @@ func (ww *workflowWorker) Run() error {
 	if err != nil {
 		return err
 	}
+	ww.stopSweeper = startStickyCacheSweeper()
+	defer ww.stopSweeper()
 	ww.localActivityWorker.Start()
 	ww.worker.Run()
 	return nil
@@ -366,6 +378,9 @@ func (ww *workflowWorker) Stop() {
 	// TODO: remove the stop methods in favor of the workerStopChannel
 	ww.localActivityWorker.Stop()
 	ww.worker.Stop()
+	if ww.stopSweeper != nil {
+		ww.stopSweeper()
+	}
 }
 
 func newSessionWorker(service workflowserviceclient.Interface,
@@ -488,7 +503,7 @@ func newActivityTaskWorker(
 			pollerAutoScaler:              workerParams.AutoScalerOptions,
 			pollerCountWithoutAutoScaling: workerParams.MaxConcurrentActivityTaskPollers,
 			pollerRate:                    defaultPollerRate,
-			maxConcurrentTask:             workerParams.MaxConcurrentActivityExecutionSize,
+			maxConcurrentTask:             workerParams.MaxConcurrentActivityExecutionSize + fairnessMaxQueuedTasks(workerParams.ActivityFairness, workerParams.MaxConcurrentActivityExecutionSize),
 			maxTaskPerSecond:              workerParams.WorkerActivitiesPerSecond,
 			taskWorker:                    poller,
 			identity:                      workerParams.Identity,
@@ -496,6 +511,8 @@ func newActivityTaskWorker(
 			shutdownTimeout:               workerParams.WorkerStopTimeout,
 			userContextCancel:             workerParams.UserContextCancel,
 			pollerTracker:                 workerParams.WorkerStats.PollerTracker,
+			admissionController:           workerParams.admissionController,
+			slotPool:                      workerParams.ActivitySlotPool,
 		},
 
 		workerParams.Logger,
@@ -668,9 +685,17 @@ type workflowExecutor struct {
 	workflowType string
 	fn           interface{}
 	path         string
+	options      RegisterWorkflowOptions
 }
 
 func (we *workflowExecutor) Execute(ctx Context, input []byte) ([]byte, error) {
+	if we.options.DefaultActivityOptions != nil {
+		ctx = WithActivityOptions(ctx, *we.options.DefaultActivityOptions)
+	}
+	if we.options.DefaultLocalActivityOptions != nil {
+		ctx = WithLocalActivityOptions(ctx, *we.options.DefaultLocalActivityOptions)
+	}
+
 	var args []interface{}
 	dataConverter := getWorkflowEnvOptions(ctx).dataConverter
 	fnType := reflect.TypeOf(we.fn)
@@ -688,6 +713,7 @@ func (we *workflowExecutor) Execute(ctx Context, input []byte) ([]byte, error) {
 	}
 	envInterceptor := getEnvInterceptor(ctx)
 	envInterceptor.fn = we.fn
+	envInterceptor.args = args
 	results := envInterceptor.interceptorChainHead.ExecuteWorkflow(ctx, we.workflowType, args...)
 	return serializeResults(we.fn, results, dataConverter)
 }
@@ -736,7 +762,13 @@ func (ae *activityExecutor) Execute(ctx context.Context, input []byte) ([]byte,
 	if fnType.NumIn() == 1 && util.IsTypeByteSlice(fnType.In(0)) {
 		args = append(args, reflect.ValueOf(input))
 	} else {
-		decoded, err := decodeArgs(dataConverter, fnType, input)
+		var decoded []reflect.Value
+		var err error
+		if ae.options.AllowMissingTrailingArgs {
+			decoded, err = ae.decodeArgsAllowingMissing(ctx, dataConverter, fnType, input)
+		} else {
+			decoded, err = decodeArgs(dataConverter, fnType, input)
+		}
 		if err != nil {
 			return nil, fmt.Errorf(
 				"unable to decode the activity function input bytes with error: %v for function name: %v",
@@ -750,6 +782,25 @@ func (ae *activityExecutor) Execute(ctx context.Context, input []byte) ([]byte,
 	return validateFunctionAndGetResults(ae.fn, retValues, dataConverter)
 }
 
+// decodeArgsAllowingMissing is decodeArgs for activities registered with AllowMissingTrailingArgs: arguments missing
+// at the end of input, i.e. when the data converter runs out of data, keep their zero values.
+func (ae *activityExecutor) decodeArgsAllowingMissing(ctx context.Context, dc DataConverter, fnType reflect.Type, input []byte) ([]reflect.Value, error) {
+	values, err := decodeArgsToValues(dc, fnType, input)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err != nil && hasActivityEnv(ctx) {
+		env := getActivityEnv(ctx)
+		env.metricsScope.Counter(metrics.ActivityMissingArgsCounter).Inc(1)
+		env.logger.Warn("Activity input is missing trailing arguments, using zero values", zap.Error(err))
+	}
+	result := make([]reflect.Value, 0, len(values))
+	for _, v := range values {
+		result = append(result, reflect.ValueOf(v).Elem())
+	}
+	return result, nil
+}
+
 func (ae *activityExecutor) ExecuteWithActualArgs(ctx context.Context, actualArgs []interface{}) ([]byte, error) {
 	retValues := ae.executeWithActualArgsWithoutParseResult(ctx, actualArgs)
 	dataConverter := getDataConverterFromActivityCtx(ctx)
@@ -802,6 +853,10 @@ type aggregatedWorker struct {
 	logger                          *zap.Logger
 	registry                        *registry
 	workerstats                     debug.WorkerStats
+	validator                       *workerValidator
+	capabilitiesNegotiation         bool
+	capabilities                    atomic.Value // WorkerCapabilities
+	positionalArgsWarning           bool
 }
 
 var _ debug.Debugger = &aggregatedWorker{}
@@ -826,24 +881,70 @@ func (aw *aggregatedWorker) GetRegisteredActivities() []RegistryActivityInfo {
 
 func (aw *aggregatedWorker) RegisterWorkflow(w interface{}) {
 	aw.registry.RegisterWorkflow(w)
+	aw.warnPositionalArgs(w, tagWorkflowType)
 }
 
 func (aw *aggregatedWorker) RegisterWorkflowWithOptions(w interface{}, options RegisterWorkflowOptions) {
 	aw.registry.RegisterWorkflowWithOptions(w, options)
+	aw.warnPositionalArgs(w, tagWorkflowType)
 }
 
 func (aw *aggregatedWorker) RegisterActivity(a interface{}) {
 	aw.registry.RegisterActivity(a)
+	aw.warnPositionalArgs(a, tagActivityType)
 }
 
 func (aw *aggregatedWorker) RegisterActivityWithOptions(a interface{}, options RegisterActivityOptions) {
 	aw.registry.RegisterActivityWithOptions(a, options)
+	aw.warnPositionalArgs(a, tagActivityType)
+}
+
+// warnPositionalArgs logs the registered functions, or methods of a registered activity structure, taking more than
+// one argument besides the context, if WorkerOptions.EnablePositionalArgsWarning is set. Unlike the fields of a single
+// struct argument, positional arguments cannot be added, removed or reordered without breaking running workflows and
+// in-flight activities.
+func (aw *aggregatedWorker) warnPositionalArgs(fn interface{}, tag string) {
+	if !aw.positionalArgsWarning {
+		return
+	}
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		if count := positionalArgsCount(value.Type()); count > 1 {
+			aw.logger.Warn("Registered function takes several positional arguments, use a single struct argument instead.",
+				zap.String(tag, getFunctionName(fn)), zap.Int(tagArgumentCount, count))
+		}
+		return
+	}
+	for i := 0; i < value.NumMethod(); i++ {
+		if count := positionalArgsCount(value.Method(i).Type()); count > 1 {
+			aw.logger.Warn("Registered function takes several positional arguments, use a single struct argument instead.",
+				zap.String(tag, value.Type().Method(i).Name), zap.Int(tagArgumentCount, count))
+		}
+	}
+}
+
+// positionalArgsCount returns the number of arguments of the function besides the context.
+func positionalArgsCount(fnType reflect.Type) int {
+	count := fnType.NumIn()
+	if count > 0 && (isWorkflowContext(fnType.In(0)) || isActivityContext(fnType.In(0))) {
+		count--
+	}
+	return count
 }
 
-func (aw *aggregatedWorker) Start() error {
+func (aw *aggregatedWorker) Start() (err error) {
 	if _, err := initBinaryChecksum(); err != nil {
 		return fmt.Errorf("failed to get executable checksum: %v", err)
 	}
+	if err := workerRegistrations.registerAll(aw.registry); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			workerRegistrations.unregister(aw.registry)
+		}
+	}()
+	aw.negotiateCapabilities()
 
 	if aw.workflowWorker != nil {
 		if len(aw.registry.GetRegisteredWorkflowTypes()) == 0 {
@@ -1007,6 +1108,7 @@ func (aw *aggregatedWorker) Stop() {
 	if aw.shadowWorker != nil {
 		aw.shadowWorker.Stop()
 	}
+	workerRegistrations.unregister(aw.registry)
 	aw.logger.Info("Stopped Worker")
 }
 
@@ -1027,6 +1129,9 @@ func newAggregatedWorker(
 	if err := options.Validate(); err != nil {
 		return nil, fmt.Errorf("worker options validation error: %w", err)
 	}
+	if wOptions.WorkerAdmin != nil && wOptions.WorkflowFilter == nil {
+		wOptions.WorkflowFilter = NewWorkflowFilter()
+	}
 
 	// Wire up MetricEmitMode feature flag to control timer/histogram emission
 	metrics.SetEmitMode(wOptions.FeatureFlags.MetricEmitMode)
@@ -1035,7 +1140,7 @@ func newAggregatedWorker(
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	backgroundActivityContext, backgroundActivityContextCancel := context.WithCancel(ctx)
+	backgroundActivityContext, backgroundActivityContextCancel := context.WithCancelCause(ctx)
 
 	workerParams := workerExecutionParameters{
 		WorkerOptions: wOptions,
@@ -1044,10 +1149,11 @@ func newAggregatedWorker(
 			Kind: shared.TaskListKindNormal.Ptr(),
 		},
 		UserContext:       backgroundActivityContext,
-		UserContextCancel: backgroundActivityContextCancel,
+		UserContextCancel: func() { backgroundActivityContextCancel(ErrActivityWorkerShutdown) },
 	}
 
 	ensureRequiredParams(&workerParams)
+	workerParams.MetricsScope = applyMetricsOptions(workerParams.MetricsScope, wOptions.MetricsOptions)
 	workerParams.MetricsScope = tagScope(workerParams.MetricsScope, tagDomain, domain, tagTaskList, taskList, clientImplHeaderName, clientImplHeaderValue, callerTypeHeaderName, callerTypeHeaderValue)
 	workerParams.Logger = workerParams.Logger.With(
 		zapcore.Field{Key: tagDomain, Type: zapcore.StringType, String: domain},
@@ -1055,6 +1161,25 @@ func newAggregatedWorker(
 		zapcore.Field{Key: tagWorkerID, Type: zapcore.StringType, String: workerParams.Identity},
 	)
 	logger := workerParams.Logger
+	workerParams.activityClient = options.ActivityClient
+	if workerParams.activityClient == nil {
+		workerParams.activityClient = NewClient(service, domain, &ClientOptions{
+			MetricsScope:       options.MetricsScope,
+			MetricsOptions:     options.MetricsOptions,
+			Identity:           workerParams.Identity,
+			IsolationGroup:     options.IsolationGroup,
+			DataConverter:      workerParams.DataConverter,
+			Tracer:             options.Tracer,
+			ContextPropagators: options.ContextPropagators,
+			FeatureFlags:       wOptions.FeatureFlags,
+			Authorization:      options.Authorization,
+			PayloadLogger:      options.PayloadLogger,
+			OnThrottle:         options.OnThrottle,
+		})
+	}
+	if options.PayloadLogger != nil {
+		service = payloadlog.NewWorkflowServiceWrapper(service, options.PayloadLogger)
+	}
 	if options.Authorization != nil {
 		service = auth.NewWorkflowServiceWrapper(service, options.Authorization)
 	}
@@ -1062,10 +1187,15 @@ func newAggregatedWorker(
 		service = isolationgroup.NewWorkflowServiceWrapper(service, options.IsolationGroup)
 	}
 	service = metrics.NewWorkflowServiceWrapper(service, workerParams.MetricsScope)
+	if options.OnThrottle != nil {
+		service = serviceerror.NewWorkflowServiceWrapper(service, false, newThrottleCallback(options.OnThrottle))
+	}
 	processTestTags(&wOptions, &workerParams)
+	workerParams.admissionController = newAdmissionController(wOptions.ActivityAdmissionControl, logger, workerParams.MetricsScope)
 
 	// worker specific registry
 	registry := newRegistry()
+	registry.workerScope = &registrationScope{domain: domain, taskList: taskList}
 
 	// ldaTunnel is a one way tunnel to dispatch activity tasks from workflow poller to activity poller
 	var ldaTunnel *locallyDispatchedActivityTunnel
@@ -1154,6 +1284,12 @@ func newAggregatedWorker(
 		)
 	}
 
+	if wOptions.WorkerAdmin != nil {
+		if err := wOptions.WorkerAdmin.attach(logger, wOptions.WorkflowFilter, activityWorker, locallyDispatchedActivityWorker); err != nil {
+			return nil, err
+		}
+	}
+
 	return &aggregatedWorker{
 		workflowWorker:                  workflowWorker,
 		activityWorker:                  activityWorker,
@@ -1163,6 +1299,17 @@ func newAggregatedWorker(
 		logger:                          logger,
 		registry:                        registry,
 		workerstats:                     workerParams.WorkerStats,
+		capabilitiesNegotiation:         wOptions.EnableCapabilitiesNegotiation,
+		positionalArgsWarning:           wOptions.EnablePositionalArgsWarning,
+		validator: &workerValidator{
+			service:          service,
+			domain:           domain,
+			taskList:         taskList,
+			searchAttributes: wOptions.UpsertedSearchAttributes,
+			authorization:    wOptions.Authorization,
+			featureFlags:     wOptions.FeatureFlags,
+			now:              time.Now,
+		},
 	}, nil
 }
 
@@ -0,0 +1,359 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+)
+
+var (
+	errWorkerAdminNotAttached  = errors.New("worker admin is not used by a worker")
+	errWorkerAdminAttached     = errors.New("worker admin is already used by another worker")
+	errWorkerAdminNoActivities = errors.New("activity worker is disabled")
+	errWorkerAdminNoLogLevel   = errors.New("log level is not set in WorkerAdminOptions")
+)
+
+type (
+	// WorkerAdminOptions configures a WorkerAdmin.
+	WorkerAdminOptions struct {
+		// Optional: level of the logger of the worker, changed by WorkerAdmin.SetLogLevel. The logger set in
+		// WorkerOptions.Logger must be built with it, e.g. with zap.Config.Level.
+		// default: nil, the log level can't be changed
+		LogLevel *zap.AtomicLevel
+
+		// Optional: logger the changes of the settings are audited to, with an info entry per change.
+		// default: the logger of the worker
+		AuditLogger *zap.Logger
+	}
+
+	// WorkerSettings are the settings of a worker which can be changed while it is running, see WorkerAdmin.
+	WorkerSettings struct {
+		TaskListActivitiesPerSecond float64             `json:"taskListActivitiesPerSecond"`
+		WorkerActivitiesPerSecond   float64             `json:"workerActivitiesPerSecond"`
+		AllowList                   WorkflowFilterRules `json:"allowList"`
+		DenyList                    WorkflowFilterRules `json:"denyList"`
+		LogLevel                    string              `json:"logLevel,omitempty"`
+	}
+
+	// WorkerAdmin changes settings of a running worker without restarting it: the activity rate limits, the allow and
+	// deny lists of its WorkflowFilter and its log level, see WorkerOptions.WorkerAdmin. Each change is audited with
+	// an info log entry naming the setting, its old and new values, and who changed it.
+	//
+	// WorkerAdmin implements http.Handler, e.g. to mount it on a debug endpoint of the service: GET returns the
+	// WorkerSettings as JSON, and POST changes the settings present in the JSON body, e.g.
+	//	{"taskListActivitiesPerSecond": 50, "denyList": {"workflowTypes": ["billing"]}, "changedBy": "oncall"}
+	// The changes are validated before any of them is applied; changedBy defaults to the remote address. The endpoint
+	// has no access control of its own, so it must only be exposed to operators.
+	//
+	// Use NewWorkerAdmin to create one; it is safe for concurrent use but can only be used by a single worker.
+	WorkerAdmin struct {
+		options WorkerAdminOptions
+
+		mu                  sync.Mutex
+		attached            bool
+		logger              *zap.Logger
+		filter              *WorkflowFilter
+		taskListRates       []*atomic.Float64 // of the activity task pollers
+		taskLimiters        []*rate.Limiter   // of the activity workers
+		workerActivitiesRPS float64
+	}
+
+	// workerSettingsUpdate is the body of the POST requests of WorkerAdmin.ServeHTTP, unset settings are unchanged.
+	workerSettingsUpdate struct {
+		TaskListActivitiesPerSecond *float64             `json:"taskListActivitiesPerSecond"`
+		WorkerActivitiesPerSecond   *float64             `json:"workerActivitiesPerSecond"`
+		AllowList                   *WorkflowFilterRules `json:"allowList"`
+		DenyList                    *WorkflowFilterRules `json:"denyList"`
+		LogLevel                    *string              `json:"logLevel"`
+		ChangedBy                   string               `json:"changedBy"`
+	}
+)
+
+// NewWorkerAdmin returns a WorkerAdmin, to set on a worker with WorkerOptions.WorkerAdmin.
+func NewWorkerAdmin(options WorkerAdminOptions) *WorkerAdmin {
+	return &WorkerAdmin{options: options}
+}
+
+// Settings returns the current settings of the worker.
+func (a *WorkerAdmin) Settings() WorkerSettings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var settings WorkerSettings
+	if len(a.taskListRates) > 0 {
+		settings.TaskListActivitiesPerSecond = a.taskListRates[0].Load()
+		settings.WorkerActivitiesPerSecond = a.workerActivitiesRPS
+	}
+	if a.filter != nil {
+		settings.AllowList, settings.DenyList = a.filter.lists()
+	}
+	if a.options.LogLevel != nil {
+		settings.LogLevel = a.options.LogLevel.Level().String()
+	}
+	return settings
+}
+
+// SetTaskListActivitiesPerSecond changes WorkerOptions.TaskListActivitiesPerSecond, sent to the server with the
+// next activity task polls. Local dispatch of activities stays enabled or disabled as it was when the worker was
+// created.
+func (a *WorkerAdmin) SetTaskListActivitiesPerSecond(rps float64, changedBy string) error {
+	if err := validateWorkerAdminRate("TaskListActivitiesPerSecond", rps); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.checkActivities(); err != nil {
+		return err
+	}
+	old := a.taskListRates[0].Load()
+	for _, r := range a.taskListRates {
+		r.Store(rps)
+	}
+	a.audit("TaskListActivitiesPerSecond", old, rps, changedBy)
+	return nil
+}
+
+// SetWorkerActivitiesPerSecond changes WorkerOptions.WorkerActivitiesPerSecond, applied to the next activity tasks.
+func (a *WorkerAdmin) SetWorkerActivitiesPerSecond(rps float64, changedBy string) error {
+	if err := validateWorkerAdminRate("WorkerActivitiesPerSecond", rps); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.checkActivities(); err != nil {
+		return err
+	}
+	old := a.workerActivitiesRPS
+	for _, limiter := range a.taskLimiters {
+		limiter.SetLimit(rate.Limit(rps))
+	}
+	a.workerActivitiesRPS = rps
+	a.audit("WorkerActivitiesPerSecond", old, rps, changedBy)
+	return nil
+}
+
+// SetAllowList replaces the allow list of the WorkflowFilter of the worker, see WorkflowFilter.SetAllowList.
+func (a *WorkerAdmin) SetAllowList(rules WorkflowFilterRules, changedBy string) error {
+	return a.setFilterList(false, rules, changedBy)
+}
+
+// SetDenyList replaces the deny list of the WorkflowFilter of the worker, see WorkflowFilter.SetDenyList.
+func (a *WorkerAdmin) SetDenyList(rules WorkflowFilterRules, changedBy string) error {
+	return a.setFilterList(true, rules, changedBy)
+}
+
+// SetLogLevel changes the level of WorkerAdminOptions.LogLevel.
+func (a *WorkerAdmin) SetLogLevel(level zapcore.Level, changedBy string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.attached {
+		return errWorkerAdminNotAttached
+	}
+	if a.options.LogLevel == nil {
+		return errWorkerAdminNoLogLevel
+	}
+	old := a.options.LogLevel.Level()
+	a.options.LogLevel.SetLevel(level)
+	a.audit("LogLevel", old.String(), level.String(), changedBy)
+	return nil
+}
+
+// ServeHTTP returns the settings of the worker as JSON on GET, and changes them on POST, see WorkerAdmin.
+func (a *WorkerAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var update workerSettingsUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, fmt.Sprintf("invalid worker settings: %v", err), http.StatusBadRequest)
+			return
+		}
+		if update.ChangedBy == "" {
+			update.ChangedBy = r.RemoteAddr
+		}
+		if err := a.update(update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a.Settings())
+}
+
+// update validates all the settings of the update before changing them, so that an invalid update changes nothing.
+func (a *WorkerAdmin) update(update workerSettingsUpdate) error {
+	var level zapcore.Level
+	if update.TaskListActivitiesPerSecond != nil {
+		if err := validateWorkerAdminRate("TaskListActivitiesPerSecond", *update.TaskListActivitiesPerSecond); err != nil {
+			return err
+		}
+	}
+	if update.WorkerActivitiesPerSecond != nil {
+		if err := validateWorkerAdminRate("WorkerActivitiesPerSecond", *update.WorkerActivitiesPerSecond); err != nil {
+			return err
+		}
+	}
+	for _, rules := range []*WorkflowFilterRules{update.AllowList, update.DenyList} {
+		if rules != nil {
+			if _, err := newWorkflowFilterMatcher(*rules); err != nil {
+				return err
+			}
+		}
+	}
+	if update.LogLevel != nil {
+		if err := level.UnmarshalText([]byte(*update.LogLevel)); err != nil {
+			return err
+		}
+	}
+	if err := a.check(update); err != nil {
+		return err
+	}
+
+	// cannot fail anymore, unless the admin is used concurrently
+	if update.TaskListActivitiesPerSecond != nil {
+		if err := a.SetTaskListActivitiesPerSecond(*update.TaskListActivitiesPerSecond, update.ChangedBy); err != nil {
+			return err
+		}
+	}
+	if update.WorkerActivitiesPerSecond != nil {
+		if err := a.SetWorkerActivitiesPerSecond(*update.WorkerActivitiesPerSecond, update.ChangedBy); err != nil {
+			return err
+		}
+	}
+	if update.AllowList != nil {
+		if err := a.SetAllowList(*update.AllowList, update.ChangedBy); err != nil {
+			return err
+		}
+	}
+	if update.DenyList != nil {
+		if err := a.SetDenyList(*update.DenyList, update.ChangedBy); err != nil {
+			return err
+		}
+	}
+	if update.LogLevel != nil {
+		return a.SetLogLevel(level, update.ChangedBy)
+	}
+	return nil
+}
+
+// check returns the error the setters would return for the settings of the update, besides their validation.
+func (a *WorkerAdmin) check(update workerSettingsUpdate) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.attached {
+		return errWorkerAdminNotAttached
+	}
+	if update.TaskListActivitiesPerSecond != nil || update.WorkerActivitiesPerSecond != nil {
+		if err := a.checkActivities(); err != nil {
+			return err
+		}
+	}
+	if update.LogLevel != nil && a.options.LogLevel == nil {
+		return errWorkerAdminNoLogLevel
+	}
+	return nil
+}
+
+func (a *WorkerAdmin) setFilterList(deny bool, rules WorkflowFilterRules, changedBy string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.attached {
+		return errWorkerAdminNotAttached
+	}
+	oldAllow, oldDeny := a.filter.lists()
+	setting, old, set := "AllowList", oldAllow, a.filter.SetAllowList
+	if deny {
+		setting, old, set = "DenyList", oldDeny, a.filter.SetDenyList
+	}
+	if err := set(rules); err != nil {
+		return err
+	}
+	a.audit(setting, old, rules, changedBy)
+	return nil
+}
+
+// checkActivities is called with the lock held.
+func (a *WorkerAdmin) checkActivities() error {
+	if !a.attached {
+		return errWorkerAdminNotAttached
+	}
+	if len(a.taskListRates) == 0 {
+		return errWorkerAdminNoActivities
+	}
+	return nil
+}
+
+// audit is called with the lock held.
+func (a *WorkerAdmin) audit(setting string, oldValue, newValue interface{}, changedBy string) {
+	a.logger.Info("Worker setting changed.",
+		zap.String("Setting", setting),
+		zap.Any("OldValue", oldValue),
+		zap.Any("NewValue", newValue),
+		zap.String("ChangedBy", changedBy),
+	)
+}
+
+// attach binds the admin to the workflow filter and the activity workers of a worker, which are nil when disabled.
+func (a *WorkerAdmin) attach(logger *zap.Logger, filter *WorkflowFilter, activityWorkers ...*activityWorker) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.attached {
+		return errWorkerAdminAttached
+	}
+	a.attached = true
+	a.logger = logger
+	if a.options.AuditLogger != nil {
+		a.logger = a.options.AuditLogger
+	}
+	a.filter = filter
+	for _, aw := range activityWorkers {
+		if aw == nil {
+			continue
+		}
+		a.workerActivitiesRPS = aw.executionParameters.WorkerActivitiesPerSecond
+		a.taskLimiters = append(a.taskLimiters, aw.worker.taskLimiter)
+		switch poller := aw.poller.(type) {
+		case *activityTaskPoller:
+			a.taskListRates = append(a.taskListRates, poller.activitiesPerSecond)
+		case *locallyDispatchedActivityTaskPoller:
+			a.taskListRates = append(a.taskListRates, poller.activitiesPerSecond)
+		}
+	}
+	return nil
+}
+
+func validateWorkerAdminRate(setting string, rps float64) error {
+	if rps <= 0 {
+		return fmt.Errorf("%s must be positive, got %v", setting, rps)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+)
+
+func newWorkerWithAdmin(t *testing.T, options WorkerOptions) (*aggregatedWorker, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	options.Logger = zap.New(core)
+	w, err := newAggregatedWorker(workflowservicetest.NewMockClient(gomock.NewController(t)), "domain", "tasklist", options)
+	require.NoError(t, err)
+	return w, logs
+}
+
+func auditEntries(logs *observer.ObservedLogs) []observer.LoggedEntry {
+	return logs.FilterMessage("Worker setting changed.").AllUntimed()
+}
+
+func TestWorkerAdmin(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	admin := NewWorkerAdmin(WorkerAdminOptions{LogLevel: &level})
+	assert.Equal(t, errWorkerAdminNotAttached, admin.SetTaskListActivitiesPerSecond(50, "test"))
+
+	w, logs := newWorkerWithAdmin(t, WorkerOptions{WorkerAdmin: admin, WorkerActivitiesPerSecond: 20})
+	require.NotNil(t, w.locallyDispatchedActivityWorker)
+	assert.Equal(t, WorkerSettings{
+		TaskListActivitiesPerSecond: defaultTaskListActivitiesPerSecond,
+		WorkerActivitiesPerSecond:   20,
+		LogLevel:                    "info",
+	}, admin.Settings())
+
+	require.NoError(t, admin.SetTaskListActivitiesPerSecond(50, "oncall"))
+	assert.Equal(t, 50.0, w.activityWorker.poller.(*activityTaskPoller).activitiesPerSecond.Load())
+	assert.Equal(t, 50.0, w.locallyDispatchedActivityWorker.poller.(*locallyDispatchedActivityTaskPoller).activitiesPerSecond.Load())
+
+	require.NoError(t, admin.SetWorkerActivitiesPerSecond(30, "oncall"))
+	assert.Equal(t, 30.0, float64(w.activityWorker.worker.taskLimiter.Limit()))
+	assert.Equal(t, 30.0, float64(w.locallyDispatchedActivityWorker.worker.taskLimiter.Limit()))
+
+	deny := WorkflowFilterRules{WorkflowTypes: []string{"poison"}}
+	require.NoError(t, admin.SetDenyList(deny, "oncall"))
+	assert.False(t, w.workflowWorker.worker.options.taskWorker.(*workflowTaskPoller).filter.Allows("poison", "wid"))
+	assert.Error(t, admin.SetAllowList(WorkflowFilterRules{WorkflowIDPatterns: []string{"("}}, "oncall"))
+
+	require.NoError(t, admin.SetLogLevel(zapcore.DebugLevel, "oncall"))
+	assert.Equal(t, zapcore.DebugLevel, level.Level())
+
+	assert.Error(t, admin.SetTaskListActivitiesPerSecond(0, "oncall"))
+	assert.Equal(t, WorkerSettings{
+		TaskListActivitiesPerSecond: 50,
+		WorkerActivitiesPerSecond:   30,
+		DenyList:                    deny,
+		LogLevel:                    "debug",
+	}, admin.Settings())
+
+	entries := auditEntries(logs)
+	require.Len(t, entries, 4)
+	assert.Equal(t, map[string]interface{}{
+		"Setting":   "TaskListActivitiesPerSecond",
+		"OldValue":  defaultTaskListActivitiesPerSecond,
+		"NewValue":  50.0,
+		"ChangedBy": "oncall",
+		"Domain":    "domain",
+		"TaskList":  "tasklist",
+		"WorkerID":  w.activityWorker.identity,
+	}, entries[0].ContextMap())
+	assert.Equal(t, "DenyList", entries[2].ContextMap()["Setting"])
+	assert.Equal(t, "debug", entries[3].ContextMap()["NewValue"])
+
+	_, err := newAggregatedWorker(workflowservicetest.NewMockClient(gomock.NewController(t)), "domain", "tasklist", WorkerOptions{WorkerAdmin: admin})
+	assert.Equal(t, errWorkerAdminAttached, err)
+}
+
+func TestWorkerAdmin_disabled(t *testing.T) {
+	admin := NewWorkerAdmin(WorkerAdminOptions{})
+	newWorkerWithAdmin(t, WorkerOptions{WorkerAdmin: admin, DisableActivityWorker: true})
+
+	assert.Equal(t, errWorkerAdminNoActivities, admin.SetWorkerActivitiesPerSecond(10, "oncall"))
+	assert.Equal(t, errWorkerAdminNoLogLevel, admin.SetLogLevel(zapcore.DebugLevel, "oncall"))
+	assert.NoError(t, admin.SetAllowList(WorkflowFilterRules{WorkflowTypes: []string{"canary"}}, "oncall"))
+	assert.Equal(t, WorkerSettings{AllowList: WorkflowFilterRules{WorkflowTypes: []string{"canary"}}}, admin.Settings())
+}
+
+func TestWorkerAdmin_ServeHTTP(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	admin := NewWorkerAdmin(WorkerAdminOptions{LogLevel: &level})
+	_, logs := newWorkerWithAdmin(t, WorkerOptions{WorkerAdmin: admin, WorkerActivitiesPerSecond: 20})
+
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/debug/worker", strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, r)
+		return w
+	}
+
+	resp := serve(http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"taskListActivitiesPerSecond":100000,"workerActivitiesPerSecond":20,"allowList":{},"denyList":{},"logLevel":"info"}`, resp.Body.String())
+
+	resp = serve(http.MethodPost, `{"workerActivitiesPerSecond":10,"logLevel":"verbose"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "verbose")
+	assert.Equal(t, 20.0, admin.Settings().WorkerActivitiesPerSecond, "invalid updates change nothing")
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, `{"taskListActivitiesPerSecond":-1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, `{`).Code)
+	assert.Empty(t, auditEntries(logs))
+
+	resp = serve(http.MethodPost, `{"workerActivitiesPerSecond":10,"denyList":{"workflowIDPatterns":["^billing-"]},"logLevel":"warn"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"taskListActivitiesPerSecond":100000,"workerActivitiesPerSecond":10,"allowList":{},"denyList":{"workflowIDPatterns":["^billing-"]},"logLevel":"warn"}`, resp.Body.String())
+	require.Len(t, auditEntries(logs), 3)
+	assert.Equal(t, "10.0.0.1:1234", auditEntries(logs)[0].ContextMap()["ChangedBy"])
+
+	serve(http.MethodPost, `{"logLevel":"error","changedBy":"oncall"}`)
+	assert.Equal(t, "oncall", auditEntries(logs)[3].ContextMap()["ChangedBy"])
+
+	resp = serve(http.MethodPut, `{}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	assert.Equal(t, "GET, POST", resp.Header().Get("Allow"))
+}
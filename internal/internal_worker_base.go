@@ -76,6 +76,8 @@ type (
 		workflowTimerClient
 		SideEffect(f func() ([]byte, error), callback resultHandler)
 		GetVersion(changeID string, minSupported, maxSupported Version, opts ...GetVersionOption) Version
+		DecidedVersions(changeIDs []string) map[string]Version
+		ExecutionVersions() map[string]Version
 		WorkflowInfo() *WorkflowInfo
 		Complete(result []byte, err error)
 		RegisterCancelHandler(handler func())
@@ -88,6 +90,10 @@ type (
 		SignalExternalWorkflow(domainName, workflowID, runID, signalName string, input []byte, arg interface{}, childWorkflowOnly bool, callback resultHandler)
 		RegisterQueryHandler(handler func(queryType string, queryArgs []byte) ([]byte, error))
 		IsReplaying() bool
+		IsDeterminismGuardEnabled() bool
+		IsDecisionTaskWatchdogEnabled() bool
+		IsActivityCallerInfoEnabled() bool
+		IsLocalActivityRetryExpirationEnabled() bool
 		MutableSideEffect(id string, f func() interface{}, equals func(a, b interface{}) bool) Value
 		GetDataConverter() DataConverter
 		AddSession(sessionInfo *SessionInfo)
@@ -126,6 +132,8 @@ type (
 		userContextCancel             context.CancelFunc
 		host                          string
 		pollerTracker                 debug.PollerTracker
+		admissionController           *admissionController
+		slotPool                      *worker.SlotPool // nil unless execution slots are shared with other workers
 	}
 
 	// baseWorker that wraps worker activities.
@@ -170,16 +178,20 @@ func newBaseWorker(options baseWorkerOptions, logger *zap.Logger, metricsScope t
 	logger = logger.With(zap.String(tagWorkerType, options.workerType))
 	metricsScope = tagScope(metricsScope, tagWorkerType, options.workerType)
 
+	taskPermit := worker.NewResizablePermit(options.maxConcurrentTask)
+	if options.slotPool != nil {
+		taskPermit = worker.NewSharedPermit(options.maxConcurrentTask, options.slotPool)
+	}
 	concurrency := &worker.ConcurrencyLimit{
 		PollerPermit: worker.NewResizablePermit(options.pollerCountWithoutAutoScaling),
-		TaskPermit:   worker.NewResizablePermit(options.maxConcurrentTask),
+		TaskPermit:   taskPermit,
 	}
 
 	var concurrencyAS *worker.ConcurrencyAutoScaler
 	if pollerOptions := options.pollerAutoScaler; pollerOptions.Enabled {
 		concurrency = &worker.ConcurrencyLimit{
 			PollerPermit: worker.NewResizablePermit(pollerOptions.PollerInitCount),
-			TaskPermit:   worker.NewResizablePermit(options.maxConcurrentTask),
+			TaskPermit:   taskPermit,
 		}
 		concurrencyAS = worker.NewConcurrencyAutoScaler(worker.ConcurrencyAutoScalerInput{
 			Concurrency:              concurrency,
@@ -223,6 +235,7 @@ func (bw *baseWorker) Start() {
 	bw.metricsScope.Counter(metrics.WorkerStartCounter).Inc(1)
 
 	bw.concurrencyAutoScaler.Start()
+	bw.options.admissionController.start()
 
 	maxPollerCount := bw.options.pollerCountWithoutAutoScaling
 	if bw.options.pollerAutoScaler.Enabled {
@@ -282,6 +295,10 @@ func (bw *baseWorker) runPoller() {
 			if bw.sessionTokenBucket != nil {
 				bw.sessionTokenBucket.waitForAvailableToken()
 			}
+			if err := bw.options.admissionController.wait(bw.limiterContext); err != nil {
+				bw.concurrency.TaskPermit.Release() // shutting down, return the permit without polling
+				continue
+			}
 			bw.pollTask()
 		}
 	}
@@ -348,6 +365,13 @@ func (bw *baseWorker) pollTask() {
 	}
 
 	if task != nil {
+		// a permit acquired while all own slots were in use takes a slot of the shared pool only now
+		if permit, ok := bw.concurrency.TaskPermit.(worker.BorrowingPermit); ok {
+			if err := permit.Borrow(bw.limiterContext); err != nil {
+				bw.concurrency.TaskPermit.Release() // shutting down, drop the task as below
+				return
+			}
+		}
 		select {
 		case bw.taskQueueCh <- &polledTask{task}:
 		case <-bw.shutdownCh:
@@ -418,6 +442,7 @@ func (bw *baseWorker) Stop() {
 	close(bw.shutdownCh)
 	bw.limiterContextCancel(errShutdown)
 	bw.concurrencyAutoScaler.Stop()
+	bw.options.admissionController.stop()
 
 	if success := util.AwaitWaitGroup(&bw.shutdownWG, bw.options.shutdownTimeout); !success {
 		traceLog(func() {
@@ -12,6 +12,7 @@ import (
 
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common/debug"
+	"go.uber.org/cadence/internal/worker"
 )
 
 func TestBaseWorker_pollTask_no_warnLogOnShutdown(t *testing.T) {
@@ -97,3 +98,51 @@ func (t *nonRetryableTaskWorker) PollTask() (interface{}, error) {
 func (t *nonRetryableTaskWorker) ProcessTask(task interface{}) error {
 	return nil
 }
+
+func TestBaseWorker_sharedSlotPool(t *testing.T) {
+	pool := worker.NewSlotPool(1)
+	newWorker := func(taskWorker taskPoller) *baseWorker {
+		return newBaseWorker(baseWorkerOptions{
+			maxConcurrentTask:             1,
+			pollerCountWithoutAutoScaling: 1,
+			identity:                      "test-identity",
+			pollerTracker:                 debug.NewNoopPollerTracker(),
+			taskWorker:                    taskWorker,
+			slotPool:                      pool,
+		}, zap.NewNop(), tally.NoopScope, nil)
+	}
+	busy, idle := newWorker(&polledTaskWorker{}), newWorker(&testTaskWorker{})
+	go func() {
+		for range busy.taskQueueCh {
+		}
+	}()
+
+	// the idle worker polls beyond its own slot without holding the slot of the pool
+	assert.NoError(t, idle.concurrency.TaskPermit.Acquire(idle.limiterContext))
+	assert.NoError(t, idle.concurrency.TaskPermit.Acquire(idle.limiterContext))
+	idle.pollTask()
+	assert.Equal(t, 0, pool.InUse())
+	assert.Equal(t, 1, idle.concurrency.TaskPermit.Count())
+
+	// the busy worker uses its own slot, and borrows the slot of the pool once a task is polled
+	assert.NoError(t, busy.concurrency.TaskPermit.Acquire(busy.limiterContext))
+	busy.pollTask()
+	assert.NoError(t, busy.concurrency.TaskPermit.Acquire(busy.limiterContext))
+	assert.Equal(t, 0, pool.InUse())
+	busy.pollTask()
+	assert.Equal(t, 2, busy.concurrency.TaskPermit.Count())
+	assert.Equal(t, 1, pool.InUse())
+
+	// the idle worker keeps its own slot
+	assert.Equal(t, 1, idle.concurrency.TaskPermit.Quota())
+}
+
+type polledTaskWorker struct{}
+
+func (t *polledTaskWorker) PollTask() (interface{}, error) {
+	return "task", nil
+}
+
+func (t *polledTaskWorker) ProcessTask(task interface{}) error {
+	return nil
+}
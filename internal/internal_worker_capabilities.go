@@ -0,0 +1,163 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+)
+
+// The lowest Go client feature versions the server must support for the features of WorkerCapabilities.
+const (
+	stickyExecutionFeatureVersion = "1.0.0"
+)
+
+const capabilitiesNegotiationTimeout = minRPCTimeout
+
+type (
+	// WorkerCapabilities are the features of the server used by the worker options, detected when the worker starts
+	// from the highest Go client feature version the server supports. The options requesting a feature the server
+	// does not support are disabled on the worker with a warning.
+	WorkerCapabilities struct {
+		// Detected is false until the worker started, and when the server could not be reached or did not report the
+		// versions it supports. Every feature is then assumed to be supported.
+		Detected bool
+		// ServerFeatureVersion is the highest Go client feature version supported by the server, empty if not detected.
+		ServerFeatureVersion string
+		// StickyExecution is true if decision tasks and queries can be dispatched to the sticky task list of the
+		// worker caching the workflow. Sticky execution is disabled on the worker otherwise.
+		StickyExecution bool
+	}
+)
+
+// allWorkerCapabilities is assumed when the capabilities of the server are not detected.
+var allWorkerCapabilities = WorkerCapabilities{
+	StickyExecution: true,
+}
+
+// detectWorkerCapabilities gets the versions supported by the server, and returns the features it supports.
+func detectWorkerCapabilities(ctx context.Context, service workflowserviceclient.Interface, featureFlags FeatureFlags, logger *zap.Logger) WorkerCapabilities {
+	tchCtx, cancel, opt := newChannelContext(ctx, featureFlags, chanTimeout(capabilitiesNegotiationTimeout))
+	defer cancel()
+	info, err := service.GetClusterInfo(tchCtx, opt...)
+	if err != nil {
+		logger.Warn("Unable to detect the capabilities of the server, every feature is assumed to be supported.", zap.Error(err))
+		return allWorkerCapabilities
+	}
+	serverVersion := info.GetSupportedClientVersions().GetGoSdk()
+	server, ok := parseFeatureVersion(serverVersion)
+	if !ok {
+		logger.Warn("The server did not report a valid Go client version, every feature is assumed to be supported.",
+			zap.String("ServerFeatureVersion", serverVersion))
+		return allWorkerCapabilities
+	}
+	supports := func(version string) bool {
+		v, _ := parseFeatureVersion(version)
+		return compareFeatureVersions(server, v) >= 0
+	}
+
+	if !supports(FeatureVersion) {
+		logger.Warn("The server supports Go client feature versions older than the one of this client, requests may be rejected as unsupported.",
+			zap.String("ServerFeatureVersion", serverVersion),
+			zap.String("ClientFeatureVersion", FeatureVersion))
+	}
+	return WorkerCapabilities{
+		Detected:             true,
+		ServerFeatureVersion: serverVersion,
+		StickyExecution:      supports(stickyExecutionFeatureVersion),
+	}
+}
+
+// negotiateCapabilities detects the capabilities of the server and disables the requested features it does not
+// support. It must be called before the workers start.
+func (aw *aggregatedWorker) negotiateCapabilities() {
+	capabilities := allWorkerCapabilities
+	if aw.capabilitiesNegotiation {
+		ctx, cancel := context.WithTimeout(context.Background(), capabilitiesNegotiationTimeout)
+		capabilities = detectWorkerCapabilities(ctx, aw.validator.service, aw.validator.featureFlags, aw.logger)
+		cancel()
+	}
+	aw.capabilities.Store(capabilities)
+	if !capabilities.Detected {
+		return
+	}
+	aw.logger.Info("Detected the capabilities of the server.",
+		zap.String("ServerFeatureVersion", capabilities.ServerFeatureVersion),
+		zap.Bool("StickyExecution", capabilities.StickyExecution))
+
+	if !capabilities.StickyExecution && aw.workflowWorker != nil {
+		if poller, ok := aw.workflowWorker.poller.(*workflowTaskPoller); ok && !poller.disableStickyExecution {
+			aw.logger.Warn("Sticky execution is not supported by the server, it is disabled as if WorkerOptions.DisableStickyExecution was set.",
+				zap.String("ServerFeatureVersion", capabilities.ServerFeatureVersion),
+				zap.String("RequiredFeatureVersion", stickyExecutionFeatureVersion))
+			poller.disableStickyExecution = true
+			if handler, ok := poller.taskHandler.(*workflowTaskHandlerImpl); ok {
+				handler.disableStickyExecution = true
+			}
+		}
+	}
+}
+
+// Capabilities returns the capabilities of the server detected when the worker started.
+func (aw *aggregatedWorker) Capabilities() WorkerCapabilities {
+	if capabilities, ok := aw.capabilities.Load().(WorkerCapabilities); ok {
+		return capabilities
+	}
+	return WorkerCapabilities{}
+}
+
+// parseFeatureVersion parses a "major.minor.patch" version, ignoring any pre-release or build suffix.
+func parseFeatureVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) != len(parsed) {
+		return parsed, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
+
+func compareFeatureVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func TestParseFeatureVersion(t *testing.T) {
+	for version, expected := range map[string][3]int{
+		"1.7.0":        {1, 7, 0},
+		"v1.5.12":      {1, 5, 12},
+		"2.0.1-rc.1":   {2, 0, 1},
+		"1.6.0+build5": {1, 6, 0},
+	} {
+		parsed, ok := parseFeatureVersion(version)
+		assert.True(t, ok, version)
+		assert.Equal(t, expected, parsed, version)
+	}
+	for _, version := range []string{"", "1.7", "1.x.0", "1.-1.0", "1.2.3.4"} {
+		_, ok := parseFeatureVersion(version)
+		assert.False(t, ok, version)
+	}
+	assert.Equal(t, -1, compareFeatureVersions([3]int{1, 4, 9}, [3]int{1, 5, 0}))
+	assert.Equal(t, 1, compareFeatureVersions([3]int{2, 0, 0}, [3]int{1, 9, 9}))
+	assert.Equal(t, 0, compareFeatureVersions([3]int{1, 5, 0}, [3]int{1, 5, 0}))
+}
+
+func newCapabilitiesTestWorker(t *testing.T, setup func(service *workflowservicetest.MockClient)) (*aggregatedWorker, *workflowTaskPoller, *observer.ObservedLogs) {
+	service := workflowservicetest.NewMockClient(gomock.NewController(t))
+	if setup != nil {
+		setup(service)
+	}
+	core, logs := observer.New(zapcore.InfoLevel)
+	poller := &workflowTaskPoller{taskHandler: &workflowTaskHandlerImpl{}}
+	return &aggregatedWorker{
+		workflowWorker:          &workflowWorker{poller: poller},
+		logger:                  zap.New(core),
+		validator:               &workerValidator{service: service},
+		capabilitiesNegotiation: setup != nil,
+	}, poller, logs
+}
+
+func clusterInfo(goSdk string) *s.ClusterInfo {
+	return &s.ClusterInfo{SupportedClientVersions: &s.SupportedClientVersions{GoSdk: common.StringPtr(goSdk)}}
+}
+
+func TestNegotiateCapabilities(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		setup          func(service *workflowservicetest.MockClient)
+		expected       WorkerCapabilities
+		stickyDisabled bool
+		warnings       []string
+	}{
+		{
+			name: "current server",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().GetClusterInfo(gomock.Any(), gomock.Any()).Return(clusterInfo(FeatureVersion), nil)
+			},
+			expected: WorkerCapabilities{Detected: true, ServerFeatureVersion: FeatureVersion, StickyExecution: true},
+		},
+		{
+			name: "older server",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().GetClusterInfo(gomock.Any(), gomock.Any()).Return(clusterInfo("1.5.0"), nil)
+			},
+			expected: WorkerCapabilities{Detected: true, ServerFeatureVersion: "1.5.0", StickyExecution: true},
+			warnings: []string{"The server supports Go client feature versions older than the one of this client, requests may be rejected as unsupported."},
+		},
+		{
+			name: "server without sticky execution",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().GetClusterInfo(gomock.Any(), gomock.Any()).Return(clusterInfo("0.9.0"), nil)
+			},
+			expected:       WorkerCapabilities{Detected: true, ServerFeatureVersion: "0.9.0"},
+			stickyDisabled: true,
+			warnings: []string{
+				"The server supports Go client feature versions older than the one of this client, requests may be rejected as unsupported.",
+				"Sticky execution is not supported by the server, it is disabled as if WorkerOptions.DisableStickyExecution was set.",
+			},
+		},
+		{
+			name: "server unreachable",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().GetClusterInfo(gomock.Any(), gomock.Any()).Return(nil, errors.New("unavailable"))
+			},
+			expected: allWorkerCapabilities,
+			warnings: []string{"Unable to detect the capabilities of the server, every feature is assumed to be supported."},
+		},
+		{
+			name: "server without versions",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().GetClusterInfo(gomock.Any(), gomock.Any()).Return(&s.ClusterInfo{}, nil)
+			},
+			expected: allWorkerCapabilities,
+			warnings: []string{"The server did not report a valid Go client version, every feature is assumed to be supported."},
+		},
+		{
+			name:     "negotiation disabled",
+			expected: allWorkerCapabilities,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			aw, poller, logs := newCapabilitiesTestWorker(t, tc.setup)
+			assert.Equal(t, WorkerCapabilities{}, aw.Capabilities(), "nothing is detected before the worker starts")
+
+			aw.negotiateCapabilities()
+			assert.Equal(t, tc.expected, aw.Capabilities())
+			assert.Equal(t, tc.stickyDisabled, poller.disableStickyExecution)
+			assert.Equal(t, tc.stickyDisabled, poller.taskHandler.(*workflowTaskHandlerImpl).disableStickyExecution)
+
+			var warnings []string
+			for _, entry := range logs.All() {
+				if entry.Level == zapcore.WarnLevel {
+					warnings = append(warnings, entry.Message)
+				}
+			}
+			require.Equal(t, tc.warnings, warnings)
+		})
+	}
+}
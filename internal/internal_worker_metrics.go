@@ -0,0 +1,141 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const latencyWindowSize = 1000
+
+type (
+	// WorkerMetrics is a point in time snapshot of the worker state returned by aggregatedWorker.Metrics().
+	// It allows applications to export worker utilization to their own telemetry without subscribing to tally.
+	WorkerMetrics struct {
+		// Decision task pollers and task slots.
+		Decision WorkerTaskMetrics
+		// Activity task pollers and task slots, including activities dispatched locally by the decision poller.
+		Activity WorkerTaskMetrics
+		// Local activity task slots.
+		LocalActivity WorkerTaskMetrics
+		// Number of workflow executions in the sticky cache, which is shared by all workers in the process.
+		StickyCacheSize int
+		// Schedule to start latency percentiles of the last 1000 decision tasks polled by the worker.
+		DecisionScheduleToStartLatencyP50 time.Duration
+		DecisionScheduleToStartLatencyP99 time.Duration
+	}
+
+	// WorkerTaskMetrics is a point in time snapshot of the pollers and task slots of one task type.
+	WorkerTaskMetrics struct {
+		// Number of pollers currently running.
+		PollersRunning int
+		// Number of task slots in use. A slot is taken before polling, so it includes slots held by pending polls.
+		TaskSlotsUsed int
+		// Maximum number of tasks processed concurrently.
+		TaskSlotsTotal int
+	}
+
+	// latencyWindow keeps the most recent latency samples to compute percentiles.
+	latencyWindow struct {
+		sync.Mutex
+		samples []time.Duration
+		next    int
+	}
+)
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, 0, size)}
+}
+
+func (w *latencyWindow) record(d time.Duration) {
+	w.Lock()
+	defer w.Unlock()
+
+	if len(w.samples) < cap(w.samples) {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+}
+
+// percentiles returns the latency at each of the given percentiles (0-100), zero if there are no samples.
+func (w *latencyWindow) percentiles(ps ...float64) []time.Duration {
+	w.Lock()
+	sorted := make([]time.Duration, len(w.samples))
+	copy(sorted, w.samples)
+	w.Unlock()
+
+	result := make([]time.Duration, len(ps))
+	if len(sorted) == 0 {
+		return result
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, p := range ps {
+		idx := int(p / 100 * float64(len(sorted)-1))
+		result[i] = sorted[idx]
+	}
+	return result
+}
+
+func (bw *baseWorker) taskMetrics() WorkerTaskMetrics {
+	if bw == nil {
+		return WorkerTaskMetrics{}
+	}
+	return WorkerTaskMetrics{
+		PollersRunning: bw.concurrency.PollerPermit.Count(),
+		TaskSlotsUsed:  bw.concurrency.TaskPermit.Count(),
+		TaskSlotsTotal: bw.concurrency.TaskPermit.Quota(),
+	}
+}
+
+func (m WorkerTaskMetrics) add(other WorkerTaskMetrics) WorkerTaskMetrics {
+	return WorkerTaskMetrics{
+		PollersRunning: m.PollersRunning + other.PollersRunning,
+		TaskSlotsUsed:  m.TaskSlotsUsed + other.TaskSlotsUsed,
+		TaskSlotsTotal: m.TaskSlotsTotal + other.TaskSlotsTotal,
+	}
+}
+
+// Metrics returns a point in time snapshot of the worker state.
+func (aw *aggregatedWorker) Metrics() WorkerMetrics {
+	result := WorkerMetrics{
+		StickyCacheSize: getWorkflowCache().Size(),
+	}
+	if aw.workflowWorker != nil {
+		result.Decision = aw.workflowWorker.worker.taskMetrics()
+		result.LocalActivity = aw.workflowWorker.localActivityWorker.taskMetrics()
+		if poller, ok := aw.workflowWorker.poller.(*workflowTaskPoller); ok && poller.scheduleToStartLatency != nil {
+			latencies := poller.scheduleToStartLatency.percentiles(50, 99)
+			result.DecisionScheduleToStartLatencyP50 = latencies[0]
+			result.DecisionScheduleToStartLatencyP99 = latencies[1]
+		}
+	}
+	if aw.activityWorker != nil {
+		result.Activity = aw.activityWorker.worker.taskMetrics()
+	}
+	if aw.locallyDispatchedActivityWorker != nil {
+		result.Activity = result.Activity.add(aw.locallyDispatchedActivityWorker.worker.taskMetrics())
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	w := newLatencyWindow(100)
+	require.Equal(t, []time.Duration{0, 0}, w.percentiles(50, 99))
+
+	for i := 1; i <= 100; i++ {
+		w.record(time.Duration(i) * time.Millisecond)
+	}
+	require.Equal(t, []time.Duration{50 * time.Millisecond, 99 * time.Millisecond}, w.percentiles(50, 99))
+
+	// oldest samples are overwritten
+	for i := 0; i < 100; i++ {
+		w.record(time.Second)
+	}
+	require.Equal(t, []time.Duration{time.Second, time.Second}, w.percentiles(50, 99))
+}
+
+func TestAggregatedWorkerMetrics(t *testing.T) {
+	aggWorker, err := newAggregatedWorker(nil, "worker-metrics-test", "worker-metrics-tl", WorkerOptions{
+		MaxConcurrentDecisionTaskExecutionSize:  3,
+		MaxConcurrentActivityExecutionSize:      5,
+		MaxConcurrentLocalActivityExecutionSize: 7,
+	})
+	require.NoError(t, err)
+
+	aggWorker.workflowWorker.poller.(*workflowTaskPoller).scheduleToStartLatency.record(time.Second)
+
+	m := aggWorker.Metrics()
+	require.Equal(t, WorkerTaskMetrics{TaskSlotsTotal: 3}, m.Decision)
+	require.Equal(t, WorkerTaskMetrics{TaskSlotsTotal: 7}, m.LocalActivity)
+	// regular and locally dispatched activity workers
+	require.Equal(t, WorkerTaskMetrics{TaskSlotsTotal: 10}, m.Activity)
+	require.Equal(t, time.Second, m.DecisionScheduleToStartLatencyP50)
+	require.Equal(t, time.Second, m.DecisionScheduleToStartLatencyP99)
+}
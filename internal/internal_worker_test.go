@@ -24,6 +24,7 @@ package internal
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"reflect"
 	"runtime"
@@ -43,6 +44,8 @@ import (
 	"github.com/uber-go/tally"
 	"go.uber.org/yarpc"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	"go.uber.org/cadence/.gen/go/shared"
@@ -876,6 +879,41 @@ func TestRegisterActivityWithOptions(t *testing.T) {
 	assert.Equal(t, getFunctionName(testActivityMultipleArgs), runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name())
 }
 
+type testPositionalArgsActivities struct{}
+
+func (a *testPositionalArgsActivities) Single(ctx context.Context, arg testActivityArg) error {
+	return nil
+}
+
+func (a *testPositionalArgsActivities) Multiple(ctx context.Context, i int, s string) error {
+	return nil
+}
+
+func TestRegisterPositionalArgsWarning(t *testing.T) {
+	core, observed := observer.New(zapcore.WarnLevel)
+	w := &aggregatedWorker{registry: newRegistry(), logger: zap.New(core), positionalArgsWarning: true}
+	w.RegisterWorkflow(testWorkflowMultipleArgs)
+	w.RegisterWorkflow(testWorkflowReturnString)
+	w.RegisterActivity(testActivityMultipleArgsWithStruct)
+	w.RegisterActivityWithOptions(&testPositionalArgsActivities{}, RegisterActivityOptions{Name: "positional."})
+
+	logs := observed.FilterMessage("Registered function takes several positional arguments, use a single struct argument instead.").All()
+	require.Len(t, logs, 3)
+	assert.Equal(t, getFunctionName(testWorkflowMultipleArgs), logs[0].ContextMap()[tagWorkflowType])
+	assert.Equal(t, int64(3), logs[0].ContextMap()[tagArgumentCount])
+	assert.Equal(t, getFunctionName(testActivityMultipleArgsWithStruct), logs[1].ContextMap()[tagActivityType])
+	assert.Equal(t, "Multiple", logs[2].ContextMap()[tagActivityType])
+	assert.Equal(t, int64(2), logs[2].ContextMap()[tagArgumentCount])
+}
+
+func TestRegisterPositionalArgsWarningDisabled(t *testing.T) {
+	core, observed := observer.New(zapcore.WarnLevel)
+	w := &aggregatedWorker{registry: newRegistry(), logger: zap.New(core)}
+	w.RegisterWorkflow(testWorkflowMultipleArgs)
+	w.RegisterActivity(testActivityMultipleArgsWithStruct)
+	assert.Zero(t, observed.Len())
+}
+
 type testErrorDetails struct {
 	T string
 }
@@ -1063,6 +1101,40 @@ func TestActivityNilArgs(t *testing.T) {
 	require.Equal(t, nilErr, reflectResults[0].Interface())
 }
 
+func TestActivityMissingTrailingArgs(t *testing.T) {
+	dataConverter := getDefaultDataConverter()
+	scope := tally.NewTestScope("", nil)
+	ctx := context.WithValue(context.Background(), activityEnvContextKey, &activityEnvironment{
+		logger:       testlogger.NewZap(t),
+		metricsScope: scope,
+	})
+	activityFn := func(ctx context.Context, name string, count int, strptr *string) (string, error) {
+		return fmt.Sprintf("%v-%v-%v", name, count, strptr), nil
+	}
+	input := testEncodeFunctionArgs(t, dataConverter, "name")
+
+	a := activityExecutor{name: "test", fn: activityFn}
+	_, err := a.Execute(ctx, input)
+	require.Error(t, err, "missing arguments are rejected by default")
+
+	a.options.AllowMissingTrailingArgs = true
+	encResult, err := a.Execute(ctx, input)
+	require.NoError(t, err)
+	var result string
+	require.NoError(t, dataConverter.FromData(encResult, &result))
+	require.Equal(t, "name-0-<nil>", result)
+	require.Equal(t, int64(1), scope.Snapshot().Counters()[metrics.ActivityMissingArgsCounter+"+"].Value())
+
+	encResult, err = a.Execute(ctx, testEncodeFunctionArgs(t, dataConverter, "name", 2, nil))
+	require.NoError(t, err)
+	require.NoError(t, dataConverter.FromData(encResult, &result))
+	require.Equal(t, "name-2-<nil>", result)
+	require.Equal(t, int64(1), scope.Snapshot().Counters()[metrics.ActivityMissingArgsCounter+"+"].Value())
+
+	_, err = a.Execute(ctx, testEncodeFunctionArgs(t, dataConverter, 1))
+	require.Error(t, err, "mismatching arguments are still rejected")
+}
+
 func TestWorkerOptionDefaults(t *testing.T) {
 	domain := "worker-options-test"
 	taskList := "worker-options-tl"
@@ -1107,10 +1179,28 @@ func TestWorkerOptionDefaults(t *testing.T) {
 	require.NotNil(t, activityWorker.executionParameters.Logger)
 	require.NotNil(t, activityWorker.executionParameters.MetricsScope)
 	require.Nil(t, activityWorker.executionParameters.ContextPropagators)
+	require.NotNil(t, activityWorker.executionParameters.activityClient)
 	assertWorkerExecutionParamsEqual(t, expected, activityWorker.executionParameters)
 	assert.Equal(t, expected.WorkerStats, aggWorker.GetWorkerStats())
 }
 
+func TestWorkerActivityClientOption(t *testing.T) {
+	c := NewClient(nil, "activity-client-domain", nil)
+	aggWorker, err := newAggregatedWorker(nil, "worker-options-test", "worker-options-tl", WorkerOptions{ActivityClient: c})
+	require.NoError(t, err)
+	require.Equal(t, c, aggWorker.activityWorker.executionParameters.activityClient)
+	require.Equal(t, c, aggWorker.workflowWorker.executionParameters.activityClient)
+}
+
+func TestWorkerUserContextCancelCause(t *testing.T) {
+	aggWorker, err := newAggregatedWorker(nil, "worker-options-test", "worker-options-tl", WorkerOptions{})
+	require.NoError(t, err)
+	params := aggWorker.activityWorker.executionParameters
+	params.UserContextCancel()
+	<-params.UserContext.Done()
+	require.ErrorIs(t, context.Cause(params.UserContext), ErrActivityWorkerShutdown)
+}
+
 func TestWorkerOptionNonDefaults(t *testing.T) {
 	domain := "worker-options-test"
 	taskList := "worker-options-tl"
@@ -0,0 +1,272 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/auth"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// The checks run by worker.Validator.
+const (
+	WorkerValidationCheckDomain           = "domain"
+	WorkerValidationCheckAuthorization    = "authorization"
+	WorkerValidationCheckTaskList         = "task-list"
+	WorkerValidationCheckSearchAttributes = "search-attributes"
+	WorkerValidationCheckClockSkew        = "clock-skew"
+)
+
+// The results of a check run by worker.Validator.
+const (
+	WorkerValidationPassed WorkerValidationStatus = iota
+	WorkerValidationFailed
+	// WorkerValidationSkipped is reported for checks which do not apply to the worker, or cannot be run because an
+	// earlier check failed.
+	WorkerValidationSkipped
+)
+
+// maxClockSkew is the difference between the clocks of the worker and the server reported as a failure.
+const maxClockSkew = 5 * time.Second
+
+type (
+	// WorkerValidationStatus is the result of a check run by worker.Validator.
+	WorkerValidationStatus int
+
+	// WorkerValidationCheck is the result of one check run by worker.Validator.
+	WorkerValidationCheck struct {
+		// Name of the check, one of the WorkerValidationCheck constants.
+		Name    string
+		Status  WorkerValidationStatus
+		Message string
+		// Err is the error returned by the server, if any.
+		Err error
+	}
+
+	// WorkerValidationReport is returned by worker.Validator.
+	WorkerValidationReport struct {
+		Checks []WorkerValidationCheck
+	}
+
+	workerValidator struct {
+		service          workflowserviceclient.Interface
+		domain           string
+		taskList         string
+		taskListTypes    []s.TaskListType
+		searchAttributes []string
+		authorization    auth.AuthorizationProvider
+		featureFlags     FeatureFlags
+		now              func() time.Time
+	}
+)
+
+func (st WorkerValidationStatus) String() string {
+	switch st {
+	case WorkerValidationPassed:
+		return "passed"
+	case WorkerValidationFailed:
+		return "failed"
+	case WorkerValidationSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("WorkerValidationStatus(%d)", int(st))
+}
+
+// Failed returns the checks which failed.
+func (r *WorkerValidationReport) Failed() []WorkerValidationCheck {
+	var failed []WorkerValidationCheck
+	for _, check := range r.Checks {
+		if check.Status == WorkerValidationFailed {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing the failed checks, or nil if no check failed.
+func (r *WorkerValidationReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(failed))
+	for _, check := range failed {
+		messages = append(messages, fmt.Sprintf("%v: %v", check.Name, check.Message))
+	}
+	return fmt.Errorf("worker validation failed: %v", strings.Join(messages, "; "))
+}
+
+func (r *WorkerValidationReport) add(name string, status WorkerValidationStatus, err error, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, WorkerValidationCheck{
+		Name:    name,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	})
+}
+
+func (v *workerValidator) validate(ctx context.Context) *WorkerValidationReport {
+	report := &WorkerValidationReport{}
+	if !v.validateDomain(ctx, report) {
+		for _, name := range []string{WorkerValidationCheckTaskList, WorkerValidationCheckSearchAttributes, WorkerValidationCheckClockSkew} {
+			report.add(name, WorkerValidationSkipped, nil, "skipped as the domain could not be described")
+		}
+		return report
+	}
+	pollers := v.validateTaskList(ctx, report)
+	v.validateSearchAttributes(ctx, report)
+	v.validateClockSkew(report, pollers)
+	return report
+}
+
+// validateDomain checks the domain and the authorization with one call, and returns whether the server can be used.
+func (v *workerValidator) validateDomain(ctx context.Context, report *WorkerValidationReport) bool {
+	if v.authorization != nil {
+		if _, err := v.authorization.GetAuthToken(); err != nil {
+			report.add(WorkerValidationCheckAuthorization, WorkerValidationFailed, err, "failed to get an authorization token: %v", err)
+			report.add(WorkerValidationCheckDomain, WorkerValidationSkipped, nil, "skipped as no authorization token could be provided")
+			return false
+		}
+	}
+
+	tchCtx, cancel, opt := newChannelContext(ctx, v.featureFlags)
+	defer cancel()
+	response, err := v.service.DescribeDomain(tchCtx, &s.DescribeDomainRequest{Name: common.StringPtr(v.domain)}, opt...)
+	var accessDenied *s.AccessDeniedError
+	switch {
+	case errors.As(err, &accessDenied) || yarpcerrors.IsUnauthenticated(err) || yarpcerrors.IsPermissionDenied(err):
+		report.add(WorkerValidationCheckAuthorization, WorkerValidationFailed, err, "access to domain %q denied: %v", v.domain, err)
+		report.add(WorkerValidationCheckDomain, WorkerValidationSkipped, nil, "skipped as access was denied")
+		return false
+	case err != nil:
+		report.add(WorkerValidationCheckAuthorization, WorkerValidationSkipped, nil, "skipped as the server could not be reached")
+		var notExists *s.EntityNotExistsError
+		if errors.As(err, &notExists) {
+			report.add(WorkerValidationCheckDomain, WorkerValidationFailed, err, "domain %q does not exist", v.domain)
+		} else {
+			report.add(WorkerValidationCheckDomain, WorkerValidationFailed, err, "failed to describe domain %q: %v", v.domain, err)
+		}
+		return false
+	}
+
+	report.add(WorkerValidationCheckAuthorization, WorkerValidationPassed, nil, "access to domain %q granted", v.domain)
+	if status := response.GetDomainInfo().GetStatus(); status != s.DomainStatusRegistered {
+		report.add(WorkerValidationCheckDomain, WorkerValidationFailed, nil, "domain %q is %v", v.domain, status)
+		return true
+	}
+	report.add(WorkerValidationCheckDomain, WorkerValidationPassed, nil, "domain %q is registered", v.domain)
+	return true
+}
+
+// validateTaskList describes the task list for each task type the worker polls, and returns the pollers seen.
+func (v *workerValidator) validateTaskList(ctx context.Context, report *WorkerValidationReport) []*s.PollerInfo {
+	if len(v.taskListTypes) == 0 {
+		report.add(WorkerValidationCheckTaskList, WorkerValidationSkipped, nil, "the worker polls no task list, as nothing is registered")
+		return nil
+	}
+	var pollers []*s.PollerInfo
+	var descriptions []string
+	for _, taskListType := range v.taskListTypes {
+		tchCtx, cancel, opt := newChannelContext(ctx, v.featureFlags)
+		response, err := v.service.DescribeTaskList(tchCtx, &s.DescribeTaskListRequest{
+			Domain:       common.StringPtr(v.domain),
+			TaskList:     &s.TaskList{Name: common.StringPtr(v.taskList), Kind: s.TaskListKindNormal.Ptr()},
+			TaskListType: taskListType.Ptr(),
+		}, opt...)
+		cancel()
+		if err != nil {
+			report.add(WorkerValidationCheckTaskList, WorkerValidationFailed, err, "failed to describe %v task list %q: %v", taskListType, v.taskList, err)
+			return pollers
+		}
+		pollers = append(pollers, response.GetPollers()...)
+		descriptions = append(descriptions, fmt.Sprintf("%v task list %q has %d pollers", taskListType, v.taskList, len(response.GetPollers())))
+	}
+	report.add(WorkerValidationCheckTaskList, WorkerValidationPassed, nil, "%v", strings.Join(descriptions, ", "))
+	return pollers
+}
+
+func (v *workerValidator) validateSearchAttributes(ctx context.Context, report *WorkerValidationReport) {
+	if len(v.searchAttributes) == 0 {
+		report.add(WorkerValidationCheckSearchAttributes, WorkerValidationSkipped, nil, "WorkerOptions.UpsertedSearchAttributes is not set")
+		return
+	}
+	tchCtx, cancel, opt := newChannelContext(ctx, v.featureFlags)
+	defer cancel()
+	response, err := v.service.GetSearchAttributes(tchCtx, opt...)
+	if err != nil {
+		report.add(WorkerValidationCheckSearchAttributes, WorkerValidationFailed, err, "failed to get search attributes: %v", err)
+		return
+	}
+	var missing []string
+	for _, key := range v.searchAttributes {
+		if _, ok := response.GetKeys()[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		report.add(WorkerValidationCheckSearchAttributes, WorkerValidationFailed, nil, "search attributes %v are not registered in the cluster", missing)
+		return
+	}
+	report.add(WorkerValidationCheckSearchAttributes, WorkerValidationPassed, nil, "all %d search attributes are registered in the cluster", len(v.searchAttributes))
+}
+
+// validateClockSkew compares the local clock with the last access times the server recorded for the pollers of the
+// task list. A poller is last accessed in the past, so only a server clock ahead of the local clock can be detected.
+func (v *workerValidator) validateClockSkew(report *WorkerValidationReport, pollers []*s.PollerInfo) {
+	var latest int64
+	for _, poller := range pollers {
+		if poller.GetLastAccessTime() > latest {
+			latest = poller.GetLastAccessTime()
+		}
+	}
+	if latest == 0 {
+		report.add(WorkerValidationCheckClockSkew, WorkerValidationSkipped, nil, "no pollers on the task list to compare clocks with")
+		return
+	}
+	if skew := time.Unix(0, latest).Sub(v.now()); skew > maxClockSkew {
+		report.add(WorkerValidationCheckClockSkew, WorkerValidationFailed, nil, "server clock is ahead of the local clock by at least %v", skew)
+		return
+	}
+	report.add(WorkerValidationCheckClockSkew, WorkerValidationPassed, nil, "server clock is not ahead of the local clock by more than %v", maxClockSkew)
+}
+
+// Validate checks the worker configuration against the server without polling any task.
+func (aw *aggregatedWorker) Validate(ctx context.Context) (*WorkerValidationReport, error) {
+	validator := *aw.validator
+	validator.taskListTypes = nil
+	if aw.workflowWorker != nil && len(aw.registry.GetRegisteredWorkflowTypes()) > 0 {
+		validator.taskListTypes = append(validator.taskListTypes, s.TaskListTypeDecision)
+	}
+	if aw.activityWorker != nil && len(aw.registry.getRegisteredActivities()) > 0 {
+		validator.taskListTypes = append(validator.taskListTypes, s.TaskListTypeActivity)
+	}
+	report := validator.validate(ctx)
+	return report, report.Err()
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+type failingAuthorization struct{}
+
+func (failingAuthorization) GetAuthToken() ([]byte, error) {
+	return nil, errors.New("token expired")
+}
+
+func TestWorkerValidator(t *testing.T) {
+	now := time.Unix(1000, 0)
+	registered := &s.DescribeDomainResponse{DomainInfo: &s.DomainInfo{Status: s.DomainStatusRegistered.Ptr()}}
+	taskList := func(lastAccess time.Time) *s.DescribeTaskListResponse {
+		return &s.DescribeTaskListResponse{Pollers: []*s.PollerInfo{{LastAccessTime: common.Int64Ptr(lastAccess.UnixNano())}}}
+	}
+	searchAttributes := &s.GetSearchAttributesResponse{Keys: map[string]s.IndexedValueType{"CustomerID": s.IndexedValueTypeKeyword}}
+
+	for _, tc := range []struct {
+		name             string
+		setup            func(service *workflowservicetest.MockClient)
+		taskListTypes    []s.TaskListType
+		searchAttributes []string
+		expected         map[string]WorkerValidationStatus
+	}{
+		{
+			name: "all checks pass",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(registered, nil)
+				service.EXPECT().DescribeTaskList(gomock.Any(), gomock.Any(), callOptions()...).Return(taskList(now.Add(-time.Second)), nil).Times(2)
+				service.EXPECT().GetSearchAttributes(gomock.Any(), callOptions()...).Return(searchAttributes, nil)
+			},
+			taskListTypes:    []s.TaskListType{s.TaskListTypeDecision, s.TaskListTypeActivity},
+			searchAttributes: []string{"CustomerID"},
+			expected: map[string]WorkerValidationStatus{
+				WorkerValidationCheckAuthorization:    WorkerValidationPassed,
+				WorkerValidationCheckDomain:           WorkerValidationPassed,
+				WorkerValidationCheckTaskList:         WorkerValidationPassed,
+				WorkerValidationCheckSearchAttributes: WorkerValidationPassed,
+				WorkerValidationCheckClockSkew:        WorkerValidationPassed,
+			},
+		},
+		{
+			name: "domain does not exist",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, &s.EntityNotExistsError{})
+			},
+			expected: map[string]WorkerValidationStatus{
+				WorkerValidationCheckAuthorization:    WorkerValidationSkipped,
+				WorkerValidationCheckDomain:           WorkerValidationFailed,
+				WorkerValidationCheckTaskList:         WorkerValidationSkipped,
+				WorkerValidationCheckSearchAttributes: WorkerValidationSkipped,
+				WorkerValidationCheckClockSkew:        WorkerValidationSkipped,
+			},
+		},
+		{
+			name: "access denied",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, &s.AccessDeniedError{})
+			},
+			expected: map[string]WorkerValidationStatus{
+				WorkerValidationCheckAuthorization:    WorkerValidationFailed,
+				WorkerValidationCheckDomain:           WorkerValidationSkipped,
+				WorkerValidationCheckTaskList:         WorkerValidationSkipped,
+				WorkerValidationCheckSearchAttributes: WorkerValidationSkipped,
+				WorkerValidationCheckClockSkew:        WorkerValidationSkipped,
+			},
+		},
+		{
+			name: "deprecated domain, unknown search attribute and skewed clock",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(&s.DescribeDomainResponse{
+					DomainInfo: &s.DomainInfo{Status: s.DomainStatusDeprecated.Ptr()},
+				}, nil)
+				service.EXPECT().DescribeTaskList(gomock.Any(), gomock.Any(), callOptions()...).Return(taskList(now.Add(time.Minute)), nil)
+				service.EXPECT().GetSearchAttributes(gomock.Any(), callOptions()...).Return(searchAttributes, nil)
+			},
+			taskListTypes:    []s.TaskListType{s.TaskListTypeDecision},
+			searchAttributes: []string{"CustomerID", "OrderID"},
+			expected: map[string]WorkerValidationStatus{
+				WorkerValidationCheckAuthorization:    WorkerValidationPassed,
+				WorkerValidationCheckDomain:           WorkerValidationFailed,
+				WorkerValidationCheckTaskList:         WorkerValidationPassed,
+				WorkerValidationCheckSearchAttributes: WorkerValidationFailed,
+				WorkerValidationCheckClockSkew:        WorkerValidationFailed,
+			},
+		},
+		{
+			name: "unreachable task list and nothing to compare clocks with",
+			setup: func(service *workflowservicetest.MockClient) {
+				service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(registered, nil)
+				service.EXPECT().DescribeTaskList(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, &s.BadRequestError{Message: "invalid task list"})
+			},
+			taskListTypes: []s.TaskListType{s.TaskListTypeActivity},
+			expected: map[string]WorkerValidationStatus{
+				WorkerValidationCheckAuthorization:    WorkerValidationPassed,
+				WorkerValidationCheckDomain:           WorkerValidationPassed,
+				WorkerValidationCheckTaskList:         WorkerValidationFailed,
+				WorkerValidationCheckSearchAttributes: WorkerValidationSkipped,
+				WorkerValidationCheckClockSkew:        WorkerValidationSkipped,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			service := workflowservicetest.NewMockClient(gomock.NewController(t))
+			tc.setup(service)
+			validator := &workerValidator{
+				service:          service,
+				domain:           "test-domain",
+				taskList:         "test-task-list",
+				taskListTypes:    tc.taskListTypes,
+				searchAttributes: tc.searchAttributes,
+				now:              func() time.Time { return now },
+			}
+
+			report := validator.validate(context.Background())
+			statuses := make(map[string]WorkerValidationStatus)
+			for _, check := range report.Checks {
+				assert.NotEmpty(t, check.Message)
+				statuses[check.Name] = check.Status
+			}
+			assert.Equal(t, tc.expected, statuses)
+			if len(report.Failed()) == 0 {
+				assert.NoError(t, report.Err())
+			} else {
+				assert.ErrorContains(t, report.Err(), "worker validation failed: ")
+			}
+		})
+	}
+
+	t.Run("authorization token cannot be provided", func(t *testing.T) {
+		validator := &workerValidator{
+			service:       workflowservicetest.NewMockClient(gomock.NewController(t)),
+			domain:        "test-domain",
+			authorization: failingAuthorization{},
+			now:           func() time.Time { return now },
+		}
+		report := validator.validate(context.Background())
+		require.Len(t, report.Failed(), 1)
+		assert.Equal(t, WorkerValidationCheckAuthorization, report.Failed()[0].Name)
+		assert.EqualError(t, report.Err(), "worker validation failed: authorization: failed to get an authorization token: token expired")
+	})
+}
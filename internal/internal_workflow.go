@@ -24,11 +24,14 @@ package internal
 // All code in this file is private to the package.
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
+	"runtime/pprof"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -51,6 +54,8 @@ const (
 	panicIllegalAccessCoroutinueState = "getState: illegal access from outside of workflow context"
 )
 
+var errUnknownQueryType = errors.New("unknown queryType")
+
 type (
 	syncWorkflowDefinition struct {
 		workflow   workflow
@@ -138,8 +143,18 @@ type (
 	// Implements Selector interface
 	selectorImpl struct {
 		name        string
-		cases       []*selectCase // cases that this select is comprised from
-		defaultFunc *func()       // default case
+		cases       []*selectCase             // cases that this select is comprised from
+		defaultFunc *func()                   // default case
+		timeouts    []*selectTimeout          // timeout cases, whose timers are started by each Select call
+		coverage    *workflowCoverageRecorder // non nil in the test environment, records the selected arms
+		policy      SelectorPolicy            // see SelectorOptions.Policy
+		next        int                       // index of the case scanned first by SelectorPolicyRoundRobin
+	}
+
+	// selectTimeout is a case added by AddTimeout
+	selectTimeout struct {
+		timeout time.Duration
+		f       func()
 	}
 
 	// unblockFunc is passed evaluated by a coroutine yield. When it returns false the yield returns to a caller.
@@ -147,15 +162,23 @@ type (
 	// Used to truncate internal stack frames from thread stack.
 	unblockFunc func(status string, stackDepth int) (keepBlocked bool)
 
-	coroutineState struct {
-		name         string
-		dispatcher   *dispatcherImpl  // dispatcher this context belongs to
+	// coroutineChannels are used to switch between a coroutine and the dispatcher. They are drained once the
+	// dispatcher removes the closed coroutine, so they are pooled to be reused by the next coroutines.
+	coroutineChannels struct {
 		aboutToBlock chan bool        // used to notify dispatcher that coroutine that owns this context is about to block
 		unblock      chan unblockFunc // used to notify coroutine that it should continue executing.
-		keptBlocked  bool             // true indicates that coroutine didn't make any progress since the last yield unblocking
-		closed       bool             // indicates that owning coroutine has finished execution
-		blocked      atomic.Bool
-		panicError   *workflowPanicError // non nil if coroutine had unhandled panic
+	}
+
+	coroutineState struct {
+		name        string
+		dispatcher  *dispatcherImpl // dispatcher this context belongs to
+		keptBlocked bool            // true indicates that coroutine didn't make any progress since the last yield unblocking
+		closed      bool            // indicates that owning coroutine has finished execution
+		blocked     atomic.Bool
+		panicError  *workflowPanicError // non nil if coroutine had unhandled panic
+		goroutineID atomic.Int64        // set when the dispatcher is guarded by a determinismGuard
+
+		*coroutineChannels // nil once the dispatcher removed the closed coroutine
 	}
 
 	dispatcherImpl struct {
@@ -166,6 +189,9 @@ type (
 		executing        bool       // currently running ExecuteUntilAllBlocked. Used to avoid recursive calls to it.
 		mutex            sync.Mutex // used to synchronize executing
 		closed           bool
+		guard            *determinismGuard         // nil unless WorkerOptions.EnableDeterminismGuard is set
+		watchdogRunID    string                    // labels the goroutines when WorkerOptions.DecisionTaskWatchdog is set
+		coverage         *workflowCoverageRecorder // non nil in the test environment, set on the selectors
 	}
 
 	// The current timeout resolution implementation is in seconds and uses math.Ceil() as the duration. But is
@@ -178,6 +204,7 @@ type (
 		workflowID                          string
 		waitForCancellation                 bool
 		signalChannels                      map[string]Channel
+		signalHandlers                      map[string]struct{} // signal names consumed by SetSignalHandler
 		queryHandlers                       map[string]func([]byte) ([]byte, error)
 		workflowIDReusePolicy               WorkflowIDReusePolicy
 		dataConverter                       DataConverter
@@ -199,6 +226,8 @@ type (
 		attempt              int32     // used by test framework to support child workflow retry
 		scheduledTime        time.Time // used by test framework to support child workflow retry
 		lastCompletionResult []byte    // used by test framework to support cron
+		lastFailureReason    string    // used by test framework to support cron and retry
+		lastFailureDetails   []byte    // used by test framework to support cron and retry
 	}
 
 	// decodeFutureImpl
@@ -236,6 +265,7 @@ type (
 		fn            interface{}
 		queryType     string
 		dataConverter DataConverter
+		ctx           Context // context passed to SetQueryHandler, see GetQueryInfo
 	}
 )
 
@@ -252,11 +282,21 @@ const (
 // Assert that structs do indeed implement the interfaces
 var _ Channel = (*channelImpl)(nil)
 var _ Selector = (*selectorImpl)(nil)
+var _ SelectorWithDeadline = (*selectorImpl)(nil)
 var _ WaitGroup = (*waitGroupImpl)(nil)
 var _ dispatcher = (*dispatcherImpl)(nil)
 
 var stackBuf [100000]byte
 
+var coroutineChannelsPool = sync.Pool{
+	New: func() interface{} {
+		return &coroutineChannels{
+			aboutToBlock: make(chan bool, 1),
+			unblock:      make(chan unblockFunc),
+		}
+	},
+}
+
 // Pointer to pointer to workflow result
 func getWorkflowResultPointerPointer(ctx Context) **workflowResult {
 	rpp := ctx.Value(workflowResultContextKey)
@@ -286,6 +326,10 @@ type workflowEnvironmentInterceptor struct {
 	env                  workflowEnvironment
 	interceptorChainHead WorkflowInterceptor
 	fn                   interface{}
+	args                 []interface{}              // decoded arguments of the workflow, see WorkerOptions.WorkflowPanicArgs
+	children             []*childWorkflowRecord     // child workflows started by the run, see GetChildWorkflowHandles
+	queryInterceptors    []WorkflowQueryInterceptor // the interceptors of the chain intercepting queries, in chain order
+	status               string                     // status text of the run, see SetStatusText
 }
 
 func getWorkflowInterceptor(ctx Context) WorkflowInterceptor {
@@ -452,6 +496,9 @@ func newWorkflowInterceptors(
 	var interceptor WorkflowInterceptor = envInterceptor
 	for i := len(factories) - 1; i >= 0; i-- {
 		interceptor = factories[i].NewInterceptor(env.WorkflowInfo(), interceptor)
+		if queryInterceptor, ok := interceptor.(WorkflowQueryInterceptor); ok {
+			envInterceptor.queryInterceptors = append([]WorkflowQueryInterceptor{queryInterceptor}, envInterceptor.queryInterceptors...)
+		}
 	}
 	envInterceptor.interceptorChainHead = interceptor
 	return interceptor, envInterceptor
@@ -484,6 +531,15 @@ func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, header *shared
 
 	d.rootCtx, d.cancel = WithCancel(rootCtx)
 	d.dispatcher = dispatcher
+	if env.IsDeterminismGuardEnabled() {
+		dispatcher.guard = newDeterminismGuard()
+	}
+	if env.IsDecisionTaskWatchdogEnabled() {
+		dispatcher.watchdogRunID = env.WorkflowInfo().WorkflowExecution.RunID
+	}
+	if testEnv, ok := env.(*testWorkflowEnvironmentImpl); ok {
+		dispatcher.coverage = testEnv.coverage
+	}
 
 	getWorkflowEnvironment(d.rootCtx).RegisterCancelHandler(func() {
 		// It is ok to call this method multiple times.
@@ -501,14 +557,24 @@ func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, header *shared
 		}
 	})
 
-	getWorkflowEnvironment(d.rootCtx).RegisterQueryHandler(func(queryType string, queryArgs []byte) ([]byte, error) {
+	handleQuery := func(queryType string, queryArgs []byte) ([]byte, error) {
+		if queryType == QueryTypeMetadata {
+			return encodeArg(getWorkflowEnvironment(d.rootCtx).GetDataConverter(), getWorkflowMetadata(d.rootCtx))
+		}
 		eo := getWorkflowEnvOptions(d.rootCtx)
 		handler, ok := eo.queryHandlers[queryType]
 		if !ok {
-			return nil, fmt.Errorf("unknown queryType %v. KnownQueryTypes=%v", queryType, eo.KnownQueryTypes())
+			return nil, fmt.Errorf("%w %v. KnownQueryTypes=%v", errUnknownQueryType, queryType, eo.KnownQueryTypes())
 		}
 		return handler(queryArgs)
-	})
+	}
+	for i := len(envInterceptor.queryInterceptors) - 1; i >= 0; i-- {
+		queryInterceptor, next := envInterceptor.queryInterceptors[i], handleQuery
+		handleQuery = func(queryType string, queryArgs []byte) ([]byte, error) {
+			return queryInterceptor.HandleQuery(queryType, queryArgs, next)
+		}
+	}
+	getWorkflowEnvironment(d.rootCtx).RegisterQueryHandler(handleQuery)
 }
 
 func (d *syncWorkflowDefinition) OnDecisionTaskStarted() {
@@ -587,46 +653,86 @@ func getState(ctx Context) *coroutineState {
 
 func (c *channelImpl) Receive(ctx Context, valuePtr interface{}) (more bool) {
 	state := getState(ctx)
-	hasResult := false
-	var result interface{}
-	callback := &receiveCallback{
-		fn: func(v interface{}, m bool) bool {
-			result = v
-			hasResult = true
-			more = m
-			return true
-		},
-	}
-
 	for {
-		hasResult = false
-		v, ok, m := c.receiveAsyncImpl(callback)
+		v, ok, m := c.receiveAsyncImpl(nil)
 
 		if !ok && !m { // channel closed and empty
 			return m
 		}
 
-		if ok || !m {
-			err := c.assignValue(v, valuePtr)
-			if err == nil {
-				state.unblocked()
-				return m
+		if !ok {
+			// The callback and the variables it sets are only allocated when the receive blocks.
+			hasResult := false
+			var result interface{}
+			var resultMore bool
+			c.blockedReceives = append(c.blockedReceives, &receiveCallback{
+				fn: func(v interface{}, m bool) bool {
+					result = v
+					hasResult = true
+					resultMore = m
+					return true
+				},
+			})
+			for !hasResult {
+				state.yield("blocked on " + c.name + ".Receive")
 			}
-			continue // corrupt signal. Drop and reset process
+			v, m = result, resultMore
 		}
-		for {
-			if hasResult {
-				err := c.assignValue(result, valuePtr)
-				if err == nil {
-					state.unblocked()
-					return more
+		err := c.assignValue(v, valuePtr)
+		if err == nil {
+			state.unblocked()
+			return m
+		}
+		// corrupt signal. Drop and reset process
+	}
+}
+
+func (c *channelImpl) receiveWithCancel(ctx Context, valuePtr interface{}) (ok bool, canceled bool) {
+	state := getState(ctx)
+	doneCh, cancellable := ctx.Done().(*channelImpl)
+	for {
+		v, ok, m := c.receiveAsyncImpl(nil)
+
+		if !ok && !m { // channel closed and empty
+			return false, false
+		}
+
+		if !ok {
+			hasResult := false
+			var result interface{}
+			var resultMore bool
+			callback := &receiveCallback{
+				fn: func(v interface{}, m bool) bool {
+					result = v
+					hasResult = true
+					resultMore = m
+					return true
+				},
+			}
+			c.blockedReceives = append(c.blockedReceives, callback)
+			for !hasResult {
+				if cancellable {
+					if _, more := doneCh.ReceiveAsyncWithMoreFlag(nil); !more {
+						c.removeReceiveCallback(callback)
+						state.unblocked()
+						return false, true
+					}
 				}
-				break // Corrupt signal. Drop and reset process.
+				state.yield("blocked on " + c.name + ".ReceiveWithCancel")
+			}
+			if !resultMore { // channel closed while blocked
+				state.unblocked()
+				return false, false
 			}
-			state.yield(fmt.Sprintf("blocked on %s.Receive", c.name))
+			v = result
 		}
+		err := c.assignValue(v, valuePtr)
+		if err == nil {
+			state.unblocked()
+			return true, false
+		}
+		// corrupt signal. Drop and reset process
 	}
-
 }
 
 func (c *channelImpl) ReceiveAsync(valuePtr interface{}) (ok bool) {
@@ -713,31 +819,25 @@ func (c *channelImpl) removeSendCallback(callback *sendCallback) {
 
 func (c *channelImpl) Send(ctx Context, v interface{}) {
 	state := getState(ctx)
-	valueConsumed := false
-	callback := &sendCallback{
-		value: v,
-		fn: func() bool {
-			valueConsumed = true
-			return true
-		},
-	}
-	ok := c.sendAsyncImpl(v, callback)
-	if ok {
-		state.unblocked()
-		return
-	}
-	for {
-		if valueConsumed {
-			state.unblocked()
-			return
-		}
-
-		// Check for closed in the loop as close can be called when send is blocked
-		if c.closed {
-			panic("Closed channel")
+	if !c.sendAsyncImpl(v, nil) {
+		// The callback and the variable it sets are only allocated when the send blocks.
+		valueConsumed := false
+		c.blockedSends = append(c.blockedSends, &sendCallback{
+			value: v,
+			fn: func() bool {
+				valueConsumed = true
+				return true
+			},
+		})
+		for !valueConsumed {
+			// Check for closed in the loop as close can be called when send is blocked
+			if c.closed {
+				panic("Closed channel")
+			}
+			state.yield("blocked on " + c.name + ".Send")
 		}
-		state.yield(fmt.Sprintf("blocked on %s.Send", c.name))
 	}
+	state.unblocked()
 }
 
 func (c *channelImpl) SendAsync(v interface{}) (ok bool) {
@@ -807,6 +907,10 @@ func (s *coroutineState) initialYield(stackDepth int, status string) {
 // yield indicates that coroutine cannot make progress and should sleep
 // this call blocks
 func (s *coroutineState) yield(status string) {
+	if s.coroutineChannels == nil {
+		panic("trying to block on coroutine which has completed, most likely a wrong Context is used to do blocking" +
+			" call (like Future.Get() or Channel.Receive()")
+	}
 	s.aboutToBlock <- true
 	s.initialYield(3, status) // omit three levels of stack. To adjust change to 0 and count the lines to remove.
 	s.keptBlocked = true
@@ -841,6 +945,10 @@ func (s *coroutineState) call() {
 	s.unblock <- func(status string, stackDepth int) bool {
 		return false // unblock
 	}
+	if s.dispatcher.guard != nil {
+		s.dispatcher.guard.waitForYield(s)
+		return
+	}
 	<-s.aboutToBlock
 }
 
@@ -849,6 +957,12 @@ func (s *coroutineState) close() {
 	s.aboutToBlock <- true
 }
 
+// release returns the channels of the closed coroutine to the pool once the dispatcher received its last aboutToBlock.
+func (s *coroutineState) release() {
+	coroutineChannelsPool.Put(s.coroutineChannels)
+	s.coroutineChannels = nil
+}
+
 func (s *coroutineState) exit() {
 	if !s.closed {
 		s.unblock <- func(status string, stackDepth int) bool {
@@ -871,7 +985,7 @@ func (s *coroutineState) stackTrace() string {
 }
 
 func (d *dispatcherImpl) newCoroutine(ctx Context, f func(ctx Context)) Context {
-	return d.newNamedCoroutine(ctx, fmt.Sprintf("%v", d.sequence+1), f)
+	return d.newNamedCoroutine(ctx, strconv.Itoa(d.sequence+1), f)
 }
 
 func (d *dispatcherImpl) newNamedCoroutine(ctx Context, name string, f func(ctx Context)) Context {
@@ -886,6 +1000,16 @@ func (d *dispatcherImpl) newNamedCoroutine(ctx Context, name string, f func(ctx
 			}
 		}()
 		crt.initialYield(1, "")
+		labels := context.Background()
+		if crt.dispatcher.watchdogRunID != "" {
+			labels = pprof.WithLabels(labels, pprof.Labels(decisionTaskWatchdogLabel, crt.dispatcher.watchdogRunID))
+		}
+		if crt.dispatcher.guard != nil {
+			labels = crt.dispatcher.guard.watch(crt, labels)
+		}
+		if labels != context.Background() {
+			pprof.SetGoroutineLabels(labels)
+		}
 		f(spawned)
 	}(state)
 	return spawned
@@ -893,10 +1017,9 @@ func (d *dispatcherImpl) newNamedCoroutine(ctx Context, name string, f func(ctx
 
 func (d *dispatcherImpl) newState(name string) *coroutineState {
 	c := &coroutineState{
-		name:         name,
-		dispatcher:   d,
-		aboutToBlock: make(chan bool, 1),
-		unblock:      make(chan unblockFunc),
+		name:              name,
+		dispatcher:        d,
+		coroutineChannels: coroutineChannelsPool.Get().(*coroutineChannels),
 	}
 	d.sequence++
 	d.coroutines = append(d.coroutines, c)
@@ -933,6 +1056,7 @@ func (d *dispatcherImpl) ExecuteUntilAllBlocked() (err error) {
 				d.coroutines = append(d.coroutines[:i],
 					d.coroutines[i+1:]...)
 				i--
+				c.release()
 				if c.panicError != nil {
 					return c.panicError
 				}
@@ -948,6 +1072,11 @@ func (d *dispatcherImpl) ExecuteUntilAllBlocked() (err error) {
 			break
 		}
 	}
+	if d.guard != nil {
+		if violation := d.guard.check(); violation != nil {
+			return violation
+		}
+	}
 	return nil
 }
 
@@ -986,11 +1115,25 @@ func (d *dispatcherImpl) StackTrace() string {
 }
 
 func (s *selectorImpl) AddReceive(c Channel, f func(c Channel, more bool)) Selector {
+	if s.coverage != nil {
+		arm, fn := s.coverage.addArm(selectorArmReceive), f
+		f = func(c Channel, more bool) {
+			s.coverage.selectArm(arm)
+			fn(c, more)
+		}
+	}
 	s.cases = append(s.cases, &selectCase{channel: c.(*channelImpl), receiveFunc: &f})
 	return s
 }
 
 func (s *selectorImpl) AddSend(c Channel, v interface{}, f func()) Selector {
+	if s.coverage != nil {
+		arm, fn := s.coverage.addArm(selectorArmSend), f
+		f = func() {
+			s.coverage.selectArm(arm)
+			fn()
+		}
+	}
 	s.cases = append(s.cases, &selectCase{channel: c.(*channelImpl), sendFunc: &f, sendValue: &v})
 	return s
 }
@@ -1000,15 +1143,45 @@ func (s *selectorImpl) AddFuture(future Future, f func(future Future)) Selector
 	if !ok {
 		panic("cannot chain Future that wasn't created with workflow.NewFuture")
 	}
+	if s.coverage != nil {
+		arm, fn := s.coverage.addArm(selectorArmFuture), f
+		f = func(future Future) {
+			s.coverage.selectArm(arm)
+			fn(future)
+		}
+	}
 	s.cases = append(s.cases, &selectCase{future: asyncF, futureFunc: &f})
 	return s
 }
 
 func (s *selectorImpl) AddDefault(f func()) {
+	if s.coverage != nil {
+		arm, fn := s.coverage.addArm(selectorArmDefault), f
+		f = func() {
+			s.coverage.selectArm(arm)
+			fn()
+		}
+	}
 	s.defaultFunc = &f
 }
 
+func (s *selectorImpl) AddTimeout(timeout time.Duration, f func()) SelectorWithDeadline {
+	if s.coverage != nil {
+		arm, fn := s.coverage.addArm(selectorArmTimeout), f
+		f = func() {
+			s.coverage.selectArm(arm)
+			fn()
+		}
+	}
+	s.timeouts = append(s.timeouts, &selectTimeout{timeout: timeout, f: f})
+	return s
+}
+
 func (s *selectorImpl) Select(ctx Context) {
+	if s.policy != SelectorPolicyRegistrationOrder {
+		s.selectWithPolicy(ctx)
+		return
+	}
 	state := getState(ctx)
 	var readyBranch func()
 	var cleanups []func()
@@ -1120,13 +1293,41 @@ func (s *selectorImpl) Select(ctx Context) {
 		f()
 		return
 	}
+	for _, t := range s.timeouts {
+		f := t.f
+		// the timer is not canceled with ctx, as Select does not stop waiting when ctx is canceled, but when Select
+		// returns, so that no timer of a timeout that did not win is left pending
+		timerCtx, cancelTimer := NewDisconnectedContext(ctx)
+		timer := NewTimer(timerCtx, t.timeout).(asyncFuture)
+		callback := &receiveCallback{
+			fn: func(v interface{}, more bool) bool {
+				if readyBranch != nil {
+					return false
+				}
+				readyBranch = f
+				return true
+			},
+		}
+		if _, ok, _ := timer.GetAsync(callback); ok {
+			// a timeout which is not positive wins when no other case is ready
+			readyBranch = func() {
+			}
+			cancelTimer()
+			f()
+			return
+		}
+		cleanups = append(cleanups, func() {
+			timer.RemoveReceiveCallback(callback)
+			cancelTimer()
+		})
+	}
 	for {
 		if readyBranch != nil {
 			readyBranch()
 			state.unblocked()
 			return
 		}
-		state.yield(fmt.Sprintf("blocked on %s.Select", s.name))
+		state.yield("blocked on " + s.name + ".Select")
 	}
 }
 
@@ -1222,6 +1423,7 @@ func setWorkflowEnvOptionsIfNotExist(ctx Context) Context {
 		newOptions = *options
 	} else {
 		newOptions.signalChannels = make(map[string]Channel)
+		newOptions.signalHandlers = make(map[string]struct{})
 		newOptions.queryHandlers = make(map[string]func([]byte) ([]byte, error))
 	}
 	if newOptions.dataConverter == nil {
@@ -1278,6 +1480,10 @@ func GetUnhandledSignalNames(ctx Context) []string {
 func (w *workflowOptions) getUnhandledSignalNames() []string {
 	unhandledSignals := []string{}
 	for k, c := range w.signalChannels {
+		if _, ok := w.signalHandlers[k]; ok {
+			// the handler consumes the signal once its coroutine runs, it may not have run yet
+			continue
+		}
 		ch := c.(*channelImpl)
 		v, ok, _ := ch.receiveAsyncImpl(nil)
 		if ok {
@@ -1333,7 +1539,7 @@ func newDecodeFuture(ctx Context, fn interface{}) (Future, Settable) {
 
 // setQueryHandler sets query handler for given queryType.
 func setQueryHandler(ctx Context, queryType string, handler interface{}) error {
-	qh := &queryHandler{fn: handler, queryType: queryType, dataConverter: getDataConverterFromWorkflowContext(ctx)}
+	qh := &queryHandler{fn: handler, queryType: queryType, dataConverter: getDataConverterFromWorkflowContext(ctx), ctx: ctx}
 	err := qh.validateHandlerFn()
 	if err != nil {
 		return err
@@ -1387,7 +1593,11 @@ func (h *queryHandler) execute(input []byte) (result []byte, err error) {
 	fnType := reflect.TypeOf(h.fn)
 	var args []reflect.Value
 
-	if fnType.NumIn() == 1 && util.IsTypeByteSlice(fnType.In(0)) {
+	hasContext := fnType.NumIn() > 0 && isWorkflowContext(fnType.In(0))
+	if hasContext {
+		args = append(args, reflect.ValueOf(WithValue(h.ctx, queryInfoContextKey, &QueryInfo{QueryType: h.queryType})))
+	}
+	if fnType.NumIn() == len(args)+1 && util.IsTypeByteSlice(fnType.In(len(args))) {
 		args = append(args, reflect.ValueOf(input))
 	} else {
 		decoded, err := decodeArgs(h.dataConverter, fnType, input)
@@ -244,7 +244,7 @@ func (wc *workflowClient) ExecuteWorkflow(ctx context.Context, options StartWork
 	var workflowID string
 	executionInfo, err := wc.StartWorkflow(ctx, options, workflow, args...)
 	if err != nil {
-		if alreadyStartedErr, ok := err.(*s.WorkflowExecutionAlreadyStartedError); ok {
+		if alreadyStartedErr := (*s.WorkflowExecutionAlreadyStartedError)(nil); errors.As(err, &alreadyStartedErr) {
 			runID = alreadyStartedErr.GetRunId()
 			// Assumption is that AlreadyStarted is never returned when options.ID is empty as UUID generated by
 			// StartWorkflow is not going to collide ever.
@@ -572,7 +572,7 @@ func (wc *workflowClient) GetWorkflowHistoryWithOptions(ctx context.Context, req
 }
 
 func isEntityNonExistFromPassive(err error) bool {
-	if nonExistError, ok := err.(*s.EntityNotExistsError); ok {
+	if nonExistError := (*s.EntityNotExistsError)(nil); errors.As(err, &nonExistError) {
 		return nonExistError.GetActiveCluster() != "" &&
 			nonExistError.GetCurrentCluster() != "" &&
 			nonExistError.GetActiveCluster() != nonExistError.GetCurrentCluster()
@@ -631,6 +631,9 @@ func (wc *workflowClient) RecordActivityHeartbeat(ctx context.Context, taskToken
 	if err != nil {
 		return err
 	}
+	if tooLarge := validateHeartbeatDetails(data, defaultMaxHeartbeatDetailsSize); tooLarge != nil {
+		return tooLarge
+	}
 	return recordActivityHeartbeat(ctx, wc.workflowService, wc.identity, taskToken, data, wc.featureFlags)
 }
 
@@ -641,6 +644,9 @@ func (wc *workflowClient) RecordActivityHeartbeatByID(ctx context.Context,
 	if err != nil {
 		return err
 	}
+	if tooLarge := validateHeartbeatDetails(data, defaultMaxHeartbeatDetailsSize); tooLarge != nil {
+		return tooLarge
+	}
 	return recordActivityHeartbeatByID(ctx, wc.workflowService, wc.identity, domain, workflowID, runID, activityID, data, wc.featureFlags)
 }
 
@@ -930,6 +936,10 @@ type QueryWorkflowWithOptionsRequest struct {
 	// QueryConsistencyLevel is an optional field used to control the consistency level.
 	// QueryConsistencyLevelEventual means that query will eventually reflect up to date state of a workflow.
 	// QueryConsistencyLevelStrong means that query will reflect a workflow state of having applied all events which came before the query.
+	// How a strongly consistent query reaches a worker is decided by the server: when no decision task of the workflow
+	// is outstanding, the query is dispatched directly to a worker as a query task; while one is outstanding, the query
+	// is buffered and answered by the worker together with that decision task, after the workflow code has processed
+	// its events, so the query waits for the decision task to complete and fails if it fails.
 	QueryConsistencyLevel *s.QueryConsistencyLevel
 }
 
@@ -1148,7 +1158,7 @@ func (wc *workflowClient) getWorkflowStartRequest(
 	// run propagators to extract information about tracing and other stuff, store in headers field
 	startRequest := &s.StartWorkflowExecutionRequest{
 		Domain:                              common.StringPtr(wc.domain),
-		RequestId:                           common.StringPtr(uuid.New()),
+		RequestId:                           common.StringPtr(getRequestID(options.RequestID)),
 		WorkflowId:                          common.StringPtr(workflowID),
 		WorkflowType:                        workflowTypePtr(*workflowType),
 		TaskList:                            common.TaskListPtr(s.TaskList{Name: common.StringPtr(options.TaskList)}),
@@ -1255,7 +1265,7 @@ func (wc *workflowClient) getSignalWithStartRequest(
 
 	signalWithStartRequest := &s.SignalWithStartWorkflowExecutionRequest{
 		Domain:                              common.StringPtr(wc.domain),
-		RequestId:                           common.StringPtr(uuid.New()),
+		RequestId:                           common.StringPtr(getRequestID(options.RequestID)),
 		WorkflowId:                          common.StringPtr(workflowID),
 		WorkflowType:                        workflowTypePtr(*workflowType),
 		TaskList:                            common.TaskListPtr(s.TaskList{Name: common.StringPtr(options.TaskList)}),
@@ -1281,6 +1291,13 @@ func (wc *workflowClient) getSignalWithStartRequest(
 	return signalWithStartRequest, nil
 }
 
+func getRequestID(requestID string) string {
+	if requestID == "" {
+		return uuid.New()
+	}
+	return requestID
+}
+
 func getRunID(runID string) *string {
 	if runID == "" {
 		// Cadence Server will pick current runID if provided empty.
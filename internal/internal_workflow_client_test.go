@@ -40,6 +40,7 @@ import (
 	"go.uber.org/cadence/internal/common"
 	"go.uber.org/cadence/internal/common/metrics"
 	"go.uber.org/cadence/internal/common/serializer"
+	"go.uber.org/cadence/internal/common/serviceerror"
 )
 
 const (
@@ -1047,6 +1048,41 @@ func (s *workflowClientTestSuite) TearDownSubTest() {
 	s.TearDownTest()
 }
 
+func (s *workflowClientTestSuite) TestServiceErrorsEnabled() {
+	notExists := &shared.EntityNotExistsError{Message: "workflow execution not found"}
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(notExists).Times(2)
+
+	err := s.client.SignalWorkflow(context.Background(), workflowID, runID, "signal", nil)
+	s.Equal(notExists, err, "thrift errors are returned by default")
+
+	client := NewClient(s.service, domain, &ClientOptions{FeatureFlags: FeatureFlags{ServiceErrorsEnabled: true}})
+	err = client.SignalWorkflow(context.Background(), workflowID, runID, "signal", nil)
+	var serviceErr *serviceerror.EntityNotExists
+	s.ErrorAs(err, &serviceErr)
+	s.Equal("workflow execution not found", serviceErr.Message)
+	s.ErrorAs(err, new(*shared.EntityNotExistsError))
+}
+
+func (s *workflowClientTestSuite) TestOnThrottle() {
+	var throttles []ThrottleInfo
+	client := NewClient(s.service, domain, &ClientOptions{OnThrottle: func(info ThrottleInfo) {
+		throttles = append(throttles, info)
+	}})
+	busy := &shared.ServiceBusyError{Message: "rate limited"}
+	limitExceeded := &shared.LimitExceededError{Message: "too many signals"}
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(busy)
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(limitExceeded)
+
+	err := client.SignalWorkflow(context.Background(), workflowID, runID, "signal", nil)
+	s.Equal(limitExceeded, err, "service busy errors are retried")
+	s.Require().Len(throttles, 2)
+	s.Equal("SignalWorkflowExecution", throttles[0].Operation)
+	s.ErrorAs(throttles[0].Err, new(*serviceerror.ServiceBusy))
+	s.Equal(serviceerror.DefaultRetryAfter, throttles[0].RetryAfter)
+	s.ErrorAs(throttles[1].Err, new(*serviceerror.LimitExceeded))
+	s.Zero(throttles[1].RetryAfter)
+}
+
 func (s *workflowClientTestSuite) TestSignalWorkflow() {
 	signalName := "my signal"
 	signalInput := []byte("my signal input")
@@ -1098,6 +1134,35 @@ func (s *workflowClientTestSuite) TestSignalWithStartWorkflow() {
 	s.Equal(createResponse.GetRunId(), resp.RunID)
 }
 
+func (s *workflowClientTestSuite) TestSignalWithStartWorkflow_RequestID() {
+	options := StartWorkflowOptions{
+		ID:                           workflowID,
+		TaskList:                     tasklist,
+		ExecutionStartToCloseTimeout: timeoutInSeconds,
+	}
+
+	var requestIDs []string
+	s.service.EXPECT().SignalWithStartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *shared.SignalWithStartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			requestIDs = append(requestIDs, request.GetRequestId())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		}).Times(4)
+
+	for i := 0; i < 2; i++ {
+		_, err := s.client.SignalWithStartWorkflow(context.Background(), workflowID, "signal", nil, options, workflowType)
+		s.NoError(err)
+	}
+	options.RequestID = "producer-message-id"
+	for i := 0; i < 2; i++ {
+		_, err := s.client.SignalWithStartWorkflow(context.Background(), workflowID, "signal", nil, options, workflowType)
+		s.NoError(err)
+	}
+
+	s.NotEmpty(requestIDs[0])
+	s.NotEqual(requestIDs[0], requestIDs[1], "calls without a RequestID get a new request ID")
+	s.Equal([]string{"producer-message-id", "producer-message-id"}, requestIDs[2:])
+}
+
 func (s *workflowClientTestSuite) TestSignalWithStartWorkflow_RPCError() {
 	signalName := "my signal"
 	signalInput := []byte("my signal input")
@@ -2478,6 +2543,7 @@ func (s *workflowClientTestSuite) TestQueryWorkflowWithOptions() {
 	testcases := []struct {
 		name              string
 		queryArgs         []interface{}
+		consistencyLevel  *shared.QueryConsistencyLevel
 		requestValidator  func(req *shared.QueryWorkflowRequest) // nil if RPC is not expected
 		rpcResponse       *shared.QueryWorkflowResponse
 		rpcError          error
@@ -2525,6 +2591,23 @@ func (s *workflowClientTestSuite) TestQueryWorkflowWithOptions() {
 				s.Equal("result", res)
 			},
 		},
+		{
+			name:             "strongly consistent",
+			queryArgs:        nil,
+			consistencyLevel: shared.QueryConsistencyLevelStrong.Ptr(),
+			requestValidator: func(req *shared.QueryWorkflowRequest) {
+				s.Equal(shared.QueryConsistencyLevelStrong, req.GetQueryConsistencyLevel())
+			},
+
+			rpcResponse: &shared.QueryWorkflowResponse{QueryResult: []byte("\"result\"")},
+			rpcError:    nil,
+			responseValidator: func(resp *QueryWorkflowWithOptionsResponse, err error) {
+				s.Require().Nil(err)
+				var res string
+				s.NoError(resp.QueryResult.Get(&res))
+				s.Equal("result", res)
+			},
+		},
 		{
 			name:             "failed to encode arguments",
 			queryArgs:        []interface{}{make(chan int)}, // you can't marshal this object to JSON
@@ -2580,10 +2663,11 @@ func (s *workflowClientTestSuite) TestQueryWorkflowWithOptions() {
 			}
 
 			request := &QueryWorkflowWithOptionsRequest{
-				WorkflowID: workflowID,
-				QueryType:  queryType,
-				RunID:      runID,
-				Args:       tt.queryArgs,
+				WorkflowID:            workflowID,
+				QueryType:             queryType,
+				RunID:                 runID,
+				Args:                  tt.queryArgs,
+				QueryConsistencyLevel: tt.consistencyLevel,
 			}
 			resp, err := s.client.QueryWorkflowWithOptions(context.Background(), request)
 			tt.responseValidator(resp, err)
@@ -0,0 +1,142 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+type (
+	// WorkflowFilterRules matches workflows by type or workflow ID.
+	WorkflowFilterRules struct {
+		// Workflow types, matched exactly.
+		WorkflowTypes []string `json:"workflowTypes,omitempty"`
+		// Regular expressions matched against the workflow ID, e.g. "^billing-" for IDs starting with "billing-".
+		WorkflowIDPatterns []string `json:"workflowIDPatterns,omitempty"`
+	}
+
+	// WorkflowFilter decides which workflows a worker processes decision tasks for, see WorkerOptions.WorkflowFilter.
+	// A workflow is processed if it matches the allow list, or the allow list is empty, and doesn't match the deny list.
+	// Both lists can be changed while the worker is running, e.g. to move a misbehaving workflow type to a canary
+	// fleet by allowing it there and denying it everywhere else.
+	//
+	// Decision tasks of other workflows are failed, which resets their sticky task list so that the server dispatches
+	// them again right away, eventually to a worker which processes them. Their query tasks are failed with the reason.
+	// Use NewWorkflowFilter to create one; it is safe for concurrent use.
+	WorkflowFilter struct {
+		mu    sync.RWMutex
+		allow *workflowFilterMatcher
+		deny  *workflowFilterMatcher
+	}
+
+	workflowFilterMatcher struct {
+		rules      WorkflowFilterRules
+		types      map[string]struct{}
+		idPatterns []*regexp.Regexp
+	}
+)
+
+// NewWorkflowFilter returns a filter with empty allow and deny lists, i.e. allowing all workflows.
+func NewWorkflowFilter() *WorkflowFilter {
+	return &WorkflowFilter{}
+}
+
+// SetAllowList replaces the allow list. Empty rules allow all workflows which are not denied.
+func (f *WorkflowFilter) SetAllowList(rules WorkflowFilterRules) error {
+	m, err := newWorkflowFilterMatcher(rules)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allow = m
+	return nil
+}
+
+// SetDenyList replaces the deny list. Empty rules deny no workflow.
+func (f *WorkflowFilter) SetDenyList(rules WorkflowFilterRules) error {
+	m, err := newWorkflowFilterMatcher(rules)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deny = m
+	return nil
+}
+
+// Allows returns whether decision tasks of the workflow are processed. A nil filter allows all workflows.
+func (f *WorkflowFilter) Allows(workflowType, workflowID string) bool {
+	if f == nil {
+		return true
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.deny != nil && f.deny.matches(workflowType, workflowID) {
+		return false
+	}
+	return f.allow == nil || f.allow.matches(workflowType, workflowID)
+}
+
+// lists returns the rules of the allow and deny lists.
+func (f *WorkflowFilter) lists() (allow, deny WorkflowFilterRules) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.allow != nil {
+		allow = f.allow.rules
+	}
+	if f.deny != nil {
+		deny = f.deny.rules
+	}
+	return allow, deny
+}
+
+// newWorkflowFilterMatcher returns nil for empty rules.
+func newWorkflowFilterMatcher(rules WorkflowFilterRules) (*workflowFilterMatcher, error) {
+	if len(rules.WorkflowTypes) == 0 && len(rules.WorkflowIDPatterns) == 0 {
+		return nil, nil
+	}
+	m := &workflowFilterMatcher{rules: rules, types: make(map[string]struct{}, len(rules.WorkflowTypes))}
+	for _, workflowType := range rules.WorkflowTypes {
+		m.types[workflowType] = struct{}{}
+	}
+	for _, pattern := range rules.WorkflowIDPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workflow ID pattern %q: %w", pattern, err)
+		}
+		m.idPatterns = append(m.idPatterns, re)
+	}
+	return m, nil
+}
+
+func (m *workflowFilterMatcher) matches(workflowType, workflowID string) bool {
+	if _, ok := m.types[workflowType]; ok {
+		return true
+	}
+	for _, re := range m.idPatterns {
+		if re.MatchString(workflowID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+func TestWorkflowFilter(t *testing.T) {
+	var nilFilter *WorkflowFilter
+	assert.True(t, nilFilter.Allows("any", "any"))
+
+	f := NewWorkflowFilter()
+	assert.True(t, f.Allows("any", "any"), "empty lists allow all workflows")
+
+	require.NoError(t, f.SetDenyList(WorkflowFilterRules{
+		WorkflowTypes:      []string{"poison"},
+		WorkflowIDPatterns: []string{"^quarantined-"},
+	}))
+	assert.False(t, f.Allows("poison", "wid"))
+	assert.False(t, f.Allows("any", "quarantined-1"))
+	assert.True(t, f.Allows("any", "wid-quarantined-1"))
+
+	require.NoError(t, f.SetAllowList(WorkflowFilterRules{WorkflowTypes: []string{"canary", "poison"}}))
+	assert.True(t, f.Allows("canary", "wid"))
+	assert.False(t, f.Allows("any", "wid"), "not in the allow list")
+	assert.False(t, f.Allows("poison", "wid"), "deny list wins over allow list")
+	assert.False(t, f.Allows("canary", "quarantined-1"), "deny list wins over allow list")
+
+	require.NoError(t, f.SetDenyList(WorkflowFilterRules{}))
+	require.NoError(t, f.SetAllowList(WorkflowFilterRules{}))
+	assert.True(t, f.Allows("poison", "quarantined-1"))
+
+	assert.Error(t, f.SetAllowList(WorkflowFilterRules{WorkflowIDPatterns: []string{"("}}))
+	assert.True(t, f.Allows("any", "any"), "invalid rules are not applied")
+}
+
+func TestWorkflowTaskPoller_WorkflowFilter(t *testing.T) {
+	poller, service, taskHandler, _ := buildWorkflowTaskPoller(t)
+	scope := tally.NewTestScope("", nil)
+	poller.metricsScope = metrics.NewTaggedScope(scope)
+	poller.filter = NewWorkflowFilter()
+	require.NoError(t, poller.filter.SetDenyList(WorkflowFilterRules{WorkflowTypes: []string{"poison"}}))
+	newTask := func(query *s.WorkflowQuery) *workflowTask {
+		return &workflowTask{task: &s.PollForDecisionTaskResponse{
+			TaskToken:    []byte("token"),
+			Attempt:      common.Int64Ptr(0),
+			WorkflowType: &s.WorkflowType{Name: common.StringPtr("poison")},
+			WorkflowExecution: &s.WorkflowExecution{
+				WorkflowId: common.StringPtr("wid"),
+				RunId:      common.StringPtr("rid"),
+			},
+			Query: query,
+		}}
+	}
+
+	// the filtered decision task is failed, so that the server schedules it again on the normal task list
+	service.EXPECT().RespondDecisionTaskFailed(gomock.Any(), gomock.Any(), callOptions()...).
+		DoAndReturn(func(_ context.Context, request *s.RespondDecisionTaskFailedRequest, _ ...yarpc.CallOption) error {
+			assert.Equal(t, []byte("token"), request.TaskToken)
+			assert.Equal(t, s.DecisionTaskFailedCauseResetStickyTasklist, request.GetCause())
+			return nil
+		})
+	assert.NoError(t, poller.ProcessTask(newTask(nil)))
+
+	// and the filtered query task is failed with the reason
+	service.EXPECT().RespondQueryTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).
+		DoAndReturn(func(_ context.Context, request *s.RespondQueryTaskCompletedRequest, _ ...yarpc.CallOption) error {
+			assert.Equal(t, s.QueryTaskCompletedTypeFailed, request.GetCompletedType())
+			assert.Contains(t, request.GetErrorMessage(), "not allowed")
+			return nil
+		})
+	assert.NoError(t, poller.ProcessTask(newTask(&s.WorkflowQuery{QueryType: common.StringPtr("state")})))
+
+	taskHandler.AssertNotCalled(t, "ProcessWorkflowTask")
+	assert.Equal(t, int64(2), scope.Snapshot().Counters()[metrics.DecisionTaskFilteredCounter+"+"].Value())
+}
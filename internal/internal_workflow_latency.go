@@ -0,0 +1,150 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"time"
+
+	"github.com/uber-go/tally"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+// blockedOn is what woke up a workflow waiting for its next decision task.
+type blockedOn int
+
+const (
+	blockedOnActivities blockedOn = iota
+	blockedOnTimers
+	blockedOnSignals
+	blockedOnChildWorkflows
+	blockedOnOther
+	blockedOnCount
+)
+
+var blockedOnMetrics = [blockedOnCount]string{
+	blockedOnActivities:     metrics.WorkflowBlockedOnActivitiesLatency,
+	blockedOnTimers:         metrics.WorkflowBlockedOnTimersLatency,
+	blockedOnSignals:        metrics.WorkflowBlockedOnSignalsLatency,
+	blockedOnChildWorkflows: metrics.WorkflowBlockedOnChildWorkflowsLatency,
+	blockedOnOther:          metrics.WorkflowBlockedOnOtherLatency,
+}
+
+// workflowLatencyBreakdown accumulates where the time of a workflow execution was spent, from the timestamps of its
+// history events, to be emitted when the execution closes. It is kept by the event handler, which reads the whole
+// history when it is created, so that the breakdown is the same whether the execution was cached or replayed.
+type workflowLatencyBreakdown struct {
+	firstDecisionScheduled        int64
+	firstDecisionScheduledToStart time.Duration
+	firstDecisionStarted          bool
+	decisionStarted               int64
+	decisionProcessing            time.Duration
+	decisionCompleted             int64 // the workflow is waiting since then, unless it is zero
+	woken                         bool  // whether an event woke the workflow up since decisionCompleted
+	wokenBy                       blockedOn
+	blocked                       [blockedOnCount]time.Duration
+}
+
+// handleEvent accounts for an event of the history, read in order.
+func (b *workflowLatencyBreakdown) handleEvent(event *s.HistoryEvent) {
+	timestamp := event.GetTimestamp()
+	switch event.GetEventType() {
+	case s.EventTypeDecisionTaskScheduled:
+		if b.firstDecisionScheduled == 0 {
+			b.firstDecisionScheduled = timestamp
+		}
+		if b.decisionCompleted != 0 && b.woken {
+			b.blocked[b.wokenBy] += time.Duration(timestamp - b.decisionCompleted)
+		}
+		b.decisionCompleted = 0
+	case s.EventTypeDecisionTaskStarted:
+		b.decisionStarted = timestamp
+		if !b.firstDecisionStarted && b.firstDecisionScheduled != 0 {
+			b.firstDecisionStarted = true
+			b.firstDecisionScheduledToStart = time.Duration(timestamp - b.firstDecisionScheduled)
+		}
+	case s.EventTypeDecisionTaskCompleted:
+		b.endDecision(timestamp)
+		b.decisionCompleted = timestamp
+		b.woken = false
+	case s.EventTypeDecisionTaskFailed, s.EventTypeDecisionTaskTimedOut:
+		b.endDecision(timestamp)
+	default:
+		if wokenBy, ok := wakeUpEvent(event.GetEventType()); ok && b.decisionCompleted != 0 && !b.woken {
+			b.woken = true
+			b.wokenBy = wokenBy
+		}
+	}
+}
+
+func (b *workflowLatencyBreakdown) endDecision(timestamp int64) {
+	if b.decisionStarted != 0 {
+		b.decisionProcessing += time.Duration(timestamp - b.decisionStarted)
+		b.decisionStarted = 0
+	}
+}
+
+// emit records the breakdown, including the processing time of the decision task closing the execution, which is
+// not in the history yet.
+func (b *workflowLatencyBreakdown) emit(scope tally.Scope, currentDecisionProcessing time.Duration) {
+	if b.firstDecisionStarted {
+		metrics.RecordHistogram(scope, metrics.WorkflowFirstDecisionScheduledToStartLatency,
+			b.firstDecisionScheduledToStart, metrics.Low1ms100s)
+	}
+	metrics.RecordHistogram(scope, metrics.WorkflowDecisionProcessingLatency,
+		b.decisionProcessing+currentDecisionProcessing, metrics.Low1ms100s)
+	for wokenBy, latency := range b.blocked {
+		metrics.RecordHistogram(scope, blockedOnMetrics[wokenBy], latency, metrics.Mid1ms24h)
+	}
+}
+
+// wakeUpEvent returns what a workflow was blocked on when the event is the one scheduling its next decision task.
+func wakeUpEvent(eventType s.EventType) (blockedOn, bool) {
+	switch eventType {
+	case s.EventTypeActivityTaskCompleted,
+		s.EventTypeActivityTaskFailed,
+		s.EventTypeActivityTaskTimedOut,
+		s.EventTypeActivityTaskCanceled:
+		return blockedOnActivities, true
+	case s.EventTypeTimerFired:
+		return blockedOnTimers, true
+	case s.EventTypeWorkflowExecutionSignaled:
+		return blockedOnSignals, true
+	case s.EventTypeStartChildWorkflowExecutionFailed,
+		s.EventTypeChildWorkflowExecutionStarted,
+		s.EventTypeChildWorkflowExecutionCompleted,
+		s.EventTypeChildWorkflowExecutionFailed,
+		s.EventTypeChildWorkflowExecutionCanceled,
+		s.EventTypeChildWorkflowExecutionTimedOut,
+		s.EventTypeChildWorkflowExecutionTerminated:
+		return blockedOnChildWorkflows, true
+	case s.EventTypeWorkflowExecutionCancelRequested,
+		s.EventTypeRequestCancelExternalWorkflowExecutionFailed,
+		s.EventTypeExternalWorkflowExecutionCancelRequested,
+		s.EventTypeSignalExternalWorkflowExecutionFailed,
+		s.EventTypeExternalWorkflowExecutionSignaled,
+		s.EventTypeRequestCancelActivityTaskFailed,
+		s.EventTypeCancelTimerFailed:
+		return blockedOnOther, true
+	}
+	return 0, false
+}
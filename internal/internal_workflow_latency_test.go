@@ -0,0 +1,128 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+func testLatencyEvent(eventType s.EventType, at time.Duration) *s.HistoryEvent {
+	return &s.HistoryEvent{
+		EventType: common.EventTypePtr(eventType),
+		Timestamp: common.Int64Ptr(time.Unix(1000, 0).Add(at).UnixNano()),
+	}
+}
+
+func TestWorkflowLatencyBreakdown(t *testing.T) {
+	var b workflowLatencyBreakdown
+	for _, event := range []*s.HistoryEvent{
+		testLatencyEvent(s.EventTypeWorkflowExecutionStarted, 0),
+		testLatencyEvent(s.EventTypeDecisionTaskScheduled, time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskStarted, 3*time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskCompleted, 3500*time.Millisecond),
+		testLatencyEvent(s.EventTypeActivityTaskScheduled, 3500*time.Millisecond),
+		testLatencyEvent(s.EventTypeActivityTaskStarted, 4*time.Second),
+		testLatencyEvent(s.EventTypeActivityTaskCompleted, 10*time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskScheduled, 10*time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskStarted, 10500*time.Millisecond),
+		testLatencyEvent(s.EventTypeDecisionTaskFailed, 11*time.Second),
+		// the failed decision task is retried right away: the workflow was not blocked
+		testLatencyEvent(s.EventTypeDecisionTaskScheduled, 11*time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskStarted, 12*time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskCompleted, 12500*time.Millisecond),
+		testLatencyEvent(s.EventTypeTimerStarted, 12500*time.Millisecond),
+		testLatencyEvent(s.EventTypeTimerFired, 72500*time.Millisecond),
+		// only the first event after the decision task woke the workflow up
+		testLatencyEvent(s.EventTypeWorkflowExecutionSignaled, 72600*time.Millisecond),
+		testLatencyEvent(s.EventTypeDecisionTaskScheduled, 72600*time.Millisecond),
+		testLatencyEvent(s.EventTypeDecisionTaskStarted, 73*time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskCompleted, 73200*time.Millisecond),
+		testLatencyEvent(s.EventTypeWorkflowExecutionSignaled, 100*time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskScheduled, 100*time.Second),
+		testLatencyEvent(s.EventTypeDecisionTaskStarted, 101*time.Second),
+	} {
+		b.handleEvent(event)
+	}
+
+	assert.Equal(t, 2*time.Second, b.firstDecisionScheduledToStart)
+	assert.Equal(t, 1700*time.Millisecond, b.decisionProcessing)
+	assert.Equal(t, [blockedOnCount]time.Duration{
+		blockedOnActivities: 6500 * time.Millisecond,
+		blockedOnTimers:     60100 * time.Millisecond,
+		blockedOnSignals:    26800 * time.Millisecond,
+	}, b.blocked)
+}
+
+func TestWorkflowLatencyBreakdown_emit(t *testing.T) {
+	b := workflowLatencyBreakdown{
+		firstDecisionScheduledToStart: time.Second,
+		firstDecisionStarted:          true,
+		decisionProcessing:            time.Second,
+	}
+	b.blocked[blockedOnTimers] = time.Hour
+	scope := tally.NewTestScope("", nil)
+	b.emit(scope, time.Second)
+
+	histograms := scope.Snapshot().Histograms()
+	for _, name := range []string{
+		metrics.WorkflowFirstDecisionScheduledToStartLatency,
+		metrics.WorkflowDecisionProcessingLatency,
+		metrics.WorkflowBlockedOnActivitiesLatency,
+		metrics.WorkflowBlockedOnTimersLatency,
+		metrics.WorkflowBlockedOnSignalsLatency,
+		metrics.WorkflowBlockedOnChildWorkflowsLatency,
+		metrics.WorkflowBlockedOnOtherLatency,
+	} {
+		require.Contains(t, histograms, name+"_ns+", name)
+	}
+	assertHistogramValue(t, histograms[metrics.WorkflowDecisionProcessingLatency+"_ns+"], 2*time.Second)
+	assertHistogramValue(t, histograms[metrics.WorkflowBlockedOnTimersLatency+"_ns+"], time.Hour)
+	assertHistogramValue(t, histograms[metrics.WorkflowBlockedOnSignalsLatency+"_ns+"], 0)
+}
+
+// assertHistogramValue asserts that the histogram recorded a single value, in the bucket of value.
+func assertHistogramValue(t *testing.T, histogram tally.HistogramSnapshot, value time.Duration) {
+	t.Helper()
+	var lower time.Duration = -1
+	for upper, count := range histogram.Durations() {
+		if count == 0 {
+			continue
+		}
+		assert.Equal(t, int64(1), count)
+		assert.GreaterOrEqual(t, upper, value, "the bucket of %v ends at %v", value, upper)
+		lower = upper
+	}
+	require.NotEqual(t, time.Duration(-1), lower, "no value was recorded")
+	for upper := range histogram.Durations() {
+		if upper < lower {
+			assert.Less(t, upper, value, "the bucket of %v is before %v", value, lower)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import "sort"
+
+type (
+	// WorkflowMetadata is the result of the QueryTypeMetadata query. It describes how to interact with the workflow,
+	// as seen by the workflow code of the worker answering the query.
+	WorkflowMetadata struct {
+		// QueryTypes are the query types the workflow answers, including the built-in ones.
+		QueryTypes []string
+		// SignalChannels are the signal channels the workflow has used or received signals on, sorted by name.
+		SignalChannels []SignalChannelMetadata
+		// Status is the text last set by SetStatusText, empty if it was never set.
+		Status string
+	}
+
+	// SignalChannelMetadata describes a signal channel listed by the QueryTypeMetadata query.
+	SignalChannelMetadata struct {
+		Name string
+		// Listening is true when the workflow is blocked receiving from the channel, directly or through a Selector.
+		Listening bool
+		// Pending is the number of signals received and not yet consumed by the workflow.
+		Pending int
+	}
+)
+
+// SetStatusText sets the status text returned by the QueryTypeMetadata query, e.g. "waiting for approval". The text
+// is not recorded in the history, a worker replaying the workflow recomputes it.
+func SetStatusText(ctx Context, text string) {
+	getEnvInterceptor(ctx).status = text
+}
+
+func getWorkflowMetadata(ctx Context) *WorkflowMetadata {
+	eo := getWorkflowEnvOptions(ctx)
+	result := &WorkflowMetadata{
+		QueryTypes:     eo.KnownQueryTypes(),
+		SignalChannels: []SignalChannelMetadata{},
+		Status:         getEnvInterceptor(ctx).status,
+	}
+	for name, c := range eo.signalChannels {
+		ch := c.(*channelImpl)
+		pending := len(ch.buffer)
+		if ch.recValue != nil {
+			pending++
+		}
+		result.SignalChannels = append(result.SignalChannels, SignalChannelMetadata{
+			Name:      name,
+			Listening: len(ch.blockedReceives) > 0,
+			Pending:   pending,
+		})
+	}
+	sort.Slice(result.SignalChannels, func(i, j int) bool {
+		return result.SignalChannels[i].Name < result.SignalChannels[j].Name
+	})
+	return result
+}
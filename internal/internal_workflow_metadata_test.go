@@ -0,0 +1,97 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflowMetadataQuery(t *testing.T) {
+	workflowFn := func(ctx Context) error {
+		err := SetQueryHandler(ctx, "state", func() (string, error) {
+			return "ready", nil
+		})
+		if err != nil {
+			return err
+		}
+		SetStatusText(ctx, "waiting for approval")
+		GetSignalChannel(ctx, "approval").Receive(ctx, nil)
+		SetStatusText(ctx, "approved")
+		return Sleep(ctx, time.Hour)
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+
+	queryMetadata := func() *WorkflowMetadata {
+		value, err := env.QueryWorkflow(QueryTypeMetadata)
+		require.NoError(t, err)
+		var metadata *WorkflowMetadata
+		require.NoError(t, value.Get(&metadata))
+		return metadata
+	}
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("audit", "first")
+		env.SignalWorkflow("audit", "second")
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		metadata := queryMetadata()
+		assert.ElementsMatch(t, append(BuiltinQueryTypes(), "state"), metadata.QueryTypes)
+		assert.Equal(t, []SignalChannelMetadata{
+			{Name: "approval", Listening: true},
+			{Name: "audit", Pending: 2},
+		}, metadata.SignalChannels)
+		assert.Equal(t, "waiting for approval", metadata.Status)
+		env.SignalWorkflow("approval", nil)
+	}, 2*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		metadata := queryMetadata()
+		assert.Equal(t, []SignalChannelMetadata{
+			{Name: "approval"},
+			{Name: "audit", Pending: 2},
+		}, metadata.SignalChannels)
+		assert.Equal(t, "approved", metadata.Status)
+	}, 3*time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+}
+
+func TestWorkflowMetadataQuery_UnhandledSignalPeeked(t *testing.T) {
+	var metadata *WorkflowMetadata
+	d, _ := newDispatcher(createRootTestContext(t), func(ctx Context) {
+		ch := getWorkflowEnvOptions(ctx).getSignalChannel(ctx, "audit")
+		ch.SendAsync("first")
+		ch.SendAsync("second")
+		// getUnhandledSignalNames moves the first signal to the pre-fetch buffer of the channel.
+		require.Equal(t, []string{"audit"}, getWorkflowEnvOptions(ctx).getUnhandledSignalNames())
+		metadata = getWorkflowMetadata(ctx)
+	})
+	defer d.Close()
+	require.NoError(t, d.ExecuteUntilAllBlocked())
+	assert.Equal(t, []SignalChannelMetadata{{Name: "audit", Pending: 2}}, metadata.SignalChannels)
+	assert.Empty(t, metadata.Status)
+}
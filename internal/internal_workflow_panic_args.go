@@ -0,0 +1,140 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+const defaultMaxWorkflowPanicArgsSize = 1024
+
+type (
+	// WorkflowPanicArgsOptions configures the capture of the arguments of panicking workflows, see
+	// WorkerOptions.WorkflowPanicArgs.
+	WorkflowPanicArgsOptions struct {
+		// Optional: Redactor hides the sensitive data of each argument before it is reported.
+		// default: nil, the arguments are reported as decoded
+		Redactor Redactor
+
+		// Optional: MaxSize is the maximum size of the JSON of the reported arguments, which are truncated beyond it.
+		// default: 1KB
+		MaxSize int
+	}
+
+	// Redactor hides the sensitive data of values reported for debugging, e.g. the arguments of a panicking workflow.
+	Redactor interface {
+		// Redact returns the value to report instead of value, which must not be modified.
+		Redact(value interface{}) interface{}
+	}
+
+	// fieldRedactor replaces the fields of the JSON of a value having one of the names by "<redacted>"
+	fieldRedactor struct {
+		fields map[string]bool
+	}
+)
+
+// NewFieldRedactor returns a Redactor replacing the fields of the JSON of values which have one of the names by
+// "<redacted>", at any depth. Values which cannot be converted to JSON are replaced entirely.
+func NewFieldRedactor(names ...string) Redactor {
+	return newFieldRedactor(names)
+}
+
+func newFieldRedactor(names []string) *fieldRedactor {
+	r := &fieldRedactor{fields: make(map[string]bool, len(names))}
+	for _, name := range names {
+		r.fields[name] = true
+	}
+	return r
+}
+
+// Redact implements Redactor.
+func (r *fieldRedactor) Redact(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return redactedValue
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return redactedValue
+	}
+	return r.redact(decoded)
+}
+
+// redact replaces the fields of value, made of the maps and slices of decoded JSON, in place. It is shared with the
+// PayloadLogger, which builds them from the thrift structs.
+func (r *fieldRedactor) redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if r.fields[key] {
+				v[key] = redactedValue
+			} else {
+				v[key] = r.redact(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.redact(child)
+		}
+	}
+	return value
+}
+
+// formatWorkflowPanicArgs returns the JSON of the arguments of a panicking workflow, redacted and truncated as
+// configured, or an empty string if they are not captured.
+func formatWorkflowPanicArgs(options *WorkflowPanicArgsOptions, definition workflowDefinition) string {
+	if options == nil {
+		return ""
+	}
+	d, ok := definition.(*syncWorkflowDefinition)
+	if !ok || d.rootCtx == nil {
+		return ""
+	}
+	envInterceptor, ok := d.rootCtx.Value(workflowEnvInterceptorContextKey).(*workflowEnvironmentInterceptor)
+	if !ok || envInterceptor.args == nil {
+		return ""
+	}
+	args := envInterceptor.args
+	if options.Redactor != nil {
+		redacted := make([]interface{}, len(args))
+		for i, arg := range args {
+			redacted[i] = options.Redactor.Redact(arg)
+		}
+		args = redacted
+	}
+	maxSize := options.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxWorkflowPanicArgsSize
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(args); err != nil {
+		return fmt.Sprintf("<failed to format: %v>", err)
+	}
+	data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if len(data) > maxSize {
+		return fmt.Sprintf("%s...<truncated %d bytes>", data[:maxSize], len(data)-maxSize)
+	}
+	return string(data)
+}
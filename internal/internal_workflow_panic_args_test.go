@@ -0,0 +1,82 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPanicArgsAccount struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Tokens   []struct {
+		Secret string `json:"secret"`
+	} `json:"tokens"`
+}
+
+func TestFieldRedactor(t *testing.T) {
+	account := testPanicArgsAccount{Name: "a", Password: "p"}
+	account.Tokens = append(account.Tokens, struct {
+		Secret string `json:"secret"`
+	}{Secret: "s"})
+
+	redactor := NewFieldRedactor("password", "secret")
+	assert.Equal(t, map[string]interface{}{
+		"name":     "a",
+		"password": redactedValue,
+		"tokens":   []interface{}{map[string]interface{}{"secret": redactedValue}},
+	}, redactor.Redact(account))
+	assert.Equal(t, "p", account.Password, "the value is not modified")
+	assert.Equal(t, "value", redactor.Redact("value"))
+	assert.Equal(t, redactedValue, redactor.Redact(func() {}))
+}
+
+func TestWorkflowPanicArgs(t *testing.T) {
+	workflowFn := func(ctx Context, account testPanicArgsAccount, count int) error {
+		panic("bad account")
+	}
+	run := func(options *WorkflowPanicArgsOptions) *PanicError {
+		var s WorkflowTestSuite
+		env := s.NewTestWorkflowEnvironment()
+		env.SetWorkerOptions(WorkerOptions{WorkflowPanicArgs: options})
+		env.RegisterWorkflow(workflowFn)
+		env.ExecuteWorkflow(workflowFn, testPanicArgsAccount{Name: "a", Password: "p"}, 3)
+		require.True(t, env.IsWorkflowCompleted())
+		var panicErr *PanicError
+		require.ErrorAs(t, env.GetWorkflowError(), &panicErr)
+		assert.Equal(t, "bad account", panicErr.Error())
+		return panicErr
+	}
+
+	assert.Empty(t, run(nil).WorkflowArgs())
+	assert.Equal(t,
+		`[{"name":"a","password":"p","tokens":null},3]`,
+		run(&WorkflowPanicArgsOptions{}).WorkflowArgs())
+	assert.Equal(t,
+		`[{"name":"a","password":"<redacted>","tokens":null},3]`,
+		run(&WorkflowPanicArgsOptions{Redactor: NewFieldRedactor("password")}).WorkflowArgs())
+	assert.Equal(t,
+		`[{"name"...<truncated 37 bytes>`,
+		run(&WorkflowPanicArgsOptions{MaxSize: 8}).WorkflowArgs())
+}
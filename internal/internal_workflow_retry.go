@@ -0,0 +1,89 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/cadence/internal/common/backoff"
+)
+
+// recordedRetryPolicy is the policy used by Retry, or the reason it is invalid, recorded by a SideEffect.
+type recordedRetryPolicy struct {
+	Policy RetryPolicy
+	Error  string
+}
+
+// Retry docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.Retry]
+func Retry(ctx Context, policy RetryPolicy, operation func(ctx Context) error) error {
+	// the policy of the first execution is kept by later deployments, so that changing it does not change the
+	// number of timers of running workflows, and so is the result of its validation, so that changing the validation
+	// rules does not change whether a running workflow retries
+	var recorded recordedRetryPolicy
+	if err := SideEffect(ctx, func(ctx Context) interface{} {
+		if err := ValidateRetryPolicy(&policy); err != nil {
+			return recordedRetryPolicy{Error: err.Error()}
+		}
+		if policy.BackoffCoefficient == 0 {
+			policy.BackoffCoefficient = backoff.DefaultBackoffCoefficient
+		}
+		if policy.MaximumInterval == 0 {
+			// if not set, default to 100x of initial interval
+			policy.MaximumInterval = 100 * policy.InitialInterval
+		}
+		return recordedRetryPolicy{Policy: policy}
+	}).Get(&recorded); err != nil {
+		return err
+	}
+	if recorded.Error != "" {
+		return errors.New(recorded.Error)
+	}
+	policy = recorded.Policy
+
+	var expireTime time.Time
+	if policy.ExpirationInterval > 0 {
+		expireTime = Now(ctx).Add(policy.ExpirationInterval)
+	}
+	for attempt := int32(0); ; attempt++ {
+		err := operation(ctx)
+		if err == nil {
+			return nil
+		}
+		if _, canceled := err.(*CanceledError); canceled || ctx.Err() != nil {
+			return err
+		}
+		if policy.MaximumAttempts > 0 && attempt+1 >= policy.MaximumAttempts {
+			return err
+		}
+		var errReason string
+		if len(policy.NonRetriableErrorReasons) > 0 {
+			errReason, _ = getErrorDetails(err, getDataConverterFromWorkflowContext(ctx))
+		}
+		backoff := getRetryBackoffWithNowTime(&policy, attempt, errReason, Now(ctx), expireTime)
+		if backoff == noRetryBackoff {
+			return err
+		}
+		if sleepErr := Sleep(ctx, backoff); sleepErr != nil {
+			return sleepErr
+		}
+	}
+}
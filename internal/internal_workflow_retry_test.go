@@ -0,0 +1,121 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/internal/common/testlogger"
+)
+
+func newRetryTestEnvironment(t *testing.T) *TestWorkflowEnvironment {
+	s := WorkflowTestSuite{}
+	s.SetLogger(testlogger.NewZap(t))
+	return s.NewTestWorkflowEnvironment()
+}
+
+func TestWorkflowRetry(t *testing.T) {
+	env := newRetryTestEnvironment(t)
+	env.RegisterWorkflowWithOptions(func(ctx Context) ([]time.Duration, error) {
+		start := Now(ctx)
+		var elapsed []time.Duration
+		err := Retry(ctx, RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 5}, func(ctx Context) error {
+			elapsed = append(elapsed, Now(ctx).Sub(start))
+			if len(elapsed) < 4 {
+				return NewCustomError("NotReady")
+			}
+			return nil
+		})
+		return elapsed, err
+	}, RegisterWorkflowOptions{Name: "retry"})
+	env.ExecuteWorkflow("retry")
+	require.NoError(t, env.GetWorkflowError())
+	var elapsed []time.Duration
+	require.NoError(t, env.GetWorkflowResult(&elapsed))
+	assert.Equal(t, []time.Duration{0, time.Second, 3 * time.Second, 7 * time.Second}, elapsed, "the default backoff coefficient is 2")
+}
+
+type retryResult struct {
+	Attempts int
+	Err      string
+}
+
+func TestWorkflowRetryStops(t *testing.T) {
+	tests := map[string]struct {
+		policy           RetryPolicy
+		expectedAttempts int
+		expectedErr      string
+	}{
+		"maximum attempts": {
+			policy:           RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3},
+			expectedAttempts: 3,
+			expectedErr:      "NotReady",
+		},
+		"expiration": {
+			policy:           RetryPolicy{InitialInterval: time.Minute, BackoffCoefficient: 1, ExpirationInterval: 5 * time.Minute},
+			expectedAttempts: 6,
+			expectedErr:      "NotReady",
+		},
+		"maximum interval": {
+			policy:           RetryPolicy{InitialInterval: time.Minute, MaximumInterval: 2 * time.Minute, ExpirationInterval: 7 * time.Minute},
+			expectedAttempts: 5,
+			expectedErr:      "NotReady",
+		},
+		"non retriable error": {
+			policy:           RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3, NonRetriableErrorReasons: []string{"NotReady"}},
+			expectedAttempts: 1,
+			expectedErr:      "NotReady",
+		},
+		"invalid policy": {
+			policy:           RetryPolicy{InitialInterval: time.Second},
+			expectedAttempts: 0,
+			expectedErr:      "neither MaximumAttempts nor ExpirationInterval is set",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			env := newRetryTestEnvironment(t)
+			env.RegisterWorkflowWithOptions(func(ctx Context) (retryResult, error) {
+				var result retryResult
+				err := Retry(ctx, tt.policy, func(ctx Context) error {
+					result.Attempts++
+					return NewCustomError("NotReady")
+				})
+				if err == nil {
+					return result, errors.New("expected an error")
+				}
+				result.Err = err.Error()
+				return result, nil
+			}, RegisterWorkflowOptions{Name: "retry"})
+			env.ExecuteWorkflow("retry")
+			require.NoError(t, env.GetWorkflowError())
+			var result retryResult
+			require.NoError(t, env.GetWorkflowResult(&result))
+			assert.Equal(t, tt.expectedAttempts, result.Attempts)
+			assert.Contains(t, result.Err, tt.expectedErr)
+		})
+	}
+}
@@ -255,6 +255,90 @@ func (s *WorkflowUnitTest) Test_SplitJoinActivityWorkflow() {
 	}, trace)
 }
 
+func (s *WorkflowUnitTest) Test_QueryInterceptor() {
+	workflowFn := func(ctx Context) error {
+		if err := SetQueryHandler(ctx, "state", func() (string, error) {
+			return "running", nil
+		}); err != nil {
+			return err
+		}
+		return Sleep(ctx, time.Hour)
+	}
+	env := newTestWorkflowEnv(s.T())
+	env.RegisterWorkflow(workflowFn)
+	tracer := tracingInterceptorFactory{}
+	env.SetWorkerOptions(WorkerOptions{WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{&tracer, &tracer}})
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow("state")
+		s.NoError(err)
+		var state string
+		s.NoError(value.Get(&state))
+		s.Equal("running", state)
+
+		_, err = env.QueryWorkflow("unknown")
+		s.ErrorIs(err, errUnknownQueryType)
+	}, time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Len(tracer.instances, 2)
+	for _, instance := range tracer.instances {
+		s.Equal([]string{"HandleQuery state", "HandleQuery unknown failed"}, instance.queryTrace)
+	}
+}
+
+type wrappedSignalChannel struct {
+	Channel
+}
+
+type signalChannelInterceptorFactory struct{}
+
+func (signalChannelInterceptorFactory) NewInterceptor(info *WorkflowInfo, next WorkflowInterceptor) WorkflowInterceptor {
+	return &signalChannelInterceptor{WorkflowInterceptorBase: WorkflowInterceptorBase{Next: next}}
+}
+
+type signalChannelInterceptor struct {
+	WorkflowInterceptorBase
+}
+
+func (i *signalChannelInterceptor) GetSignalChannel(ctx Context, signalName string) Channel {
+	return wrappedSignalChannel{i.Next.GetSignalChannel(ctx, signalName)}
+}
+
+func (s *WorkflowUnitTest) Test_ReceiveWithCancelWrappedSignalChannel() {
+	workflowFn := func(ctx Context) ([]string, error) {
+		c := GetSignalChannel(ctx, "signal")
+		if _, ok := c.(wrappedSignalChannel); !ok {
+			return nil, errors.New("the signal channel is not wrapped")
+		}
+		var history []string
+		var value string
+		ok, canceled := ReceiveWithCancel(ctx, c, &value)
+		history = append(history, fmt.Sprintf("received %v %v %v", value, ok, canceled))
+
+		cancelCtx, cancel := WithCancel(ctx)
+		Go(ctx, func(ctx Context) {
+			_ = Sleep(ctx, time.Minute)
+			cancel()
+		})
+		ok, canceled = ReceiveWithCancel(cancelCtx, c, &value)
+		history = append(history, fmt.Sprintf("canceled %v %v", ok, canceled))
+		return history, nil
+	}
+	env := newTestWorkflowEnv(s.T())
+	env.RegisterWorkflow(workflowFn)
+	env.SetWorkerOptions(WorkerOptions{WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{signalChannelInterceptorFactory{}}})
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("signal", "value")
+	}, time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var history []string
+	s.NoError(env.GetWorkflowResult(&history))
+	s.Equal([]string{"received value true false", "canceled false true"}, history)
+}
+
 func TestWorkflowPanic(t *testing.T) {
 	env := newTestWorkflowEnv(t)
 	env.RegisterActivity(testAct)
@@ -974,6 +1058,98 @@ func (s *WorkflowUnitTest) Test_ActivityOptionsWorkflow() {
 	s.Equal("id1 id2", result)
 }
 
+func TestActivityOptionsOverride(t *testing.T) {
+	retryPolicy := &RetryPolicy{
+		InitialInterval:    time.Second,
+		BackoffCoefficient: 2,
+		MaximumInterval:    time.Minute,
+		MaximumAttempts:    5,
+	}
+	ctx := WithActivityOptions(Background(), ActivityOptions{
+		TaskList:               "tl",
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		RetryPolicy:            retryPolicy,
+	})
+	ctx1 := WithActivityOptionsOverride(ctx, ActivityOptions{
+		StartToCloseTimeout: time.Hour,
+		HeartbeatTimeout:    10 * time.Second,
+	})
+
+	require.Equal(t, ActivityOptions{
+		TaskList:               "tl",
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Hour,
+		HeartbeatTimeout:       10 * time.Second,
+		RetryPolicy:            retryPolicy,
+	}, GetActivityOptions(ctx1))
+	// parent context is not modified
+	require.Equal(t, time.Minute, GetActivityOptions(ctx).StartToCloseTimeout)
+
+	require.Equal(t, ActivityOptions{}, GetActivityOptions(Background()))
+	require.Equal(t, "id", GetActivityOptions(WithActivityOptionsOverride(Background(), ActivityOptions{ActivityID: "id"})).ActivityID)
+}
+
+func TestLocalActivityOptionsOverride(t *testing.T) {
+	retryPolicy := &RetryPolicy{
+		InitialInterval: time.Second,
+		MaximumAttempts: 3,
+	}
+	ctx := WithLocalActivityOptions(Background(), LocalActivityOptions{
+		ScheduleToCloseTimeout: time.Minute,
+		RetryPolicy:            retryPolicy,
+	})
+	ctx1 := WithLocalActivityOptionsOverride(ctx, LocalActivityOptions{
+		ScheduleToCloseTimeout: 5 * time.Second,
+	})
+
+	require.Equal(t, LocalActivityOptions{
+		ScheduleToCloseTimeout: 5 * time.Second,
+		RetryPolicy:            retryPolicy,
+	}, GetLocalActivityOptions(ctx1))
+	require.Equal(t, time.Minute, GetLocalActivityOptions(ctx).ScheduleToCloseTimeout)
+	require.Equal(t, LocalActivityOptions{}, GetLocalActivityOptions(Background()))
+}
+
+func TestValidateLocalActivityRetryPolicy(t *testing.T) {
+	for _, valid := range []*RetryPolicy{
+		nil,
+		{},
+		{InitialInterval: time.Second, BackoffCoefficient: 0.5},
+		{InitialInterval: time.Second, MaximumAttempts: 3},
+	} {
+		require.NoError(t, validateLocalActivityRetryPolicy(valid))
+	}
+
+	for _, invalid := range []*RetryPolicy{
+		{MaximumAttempts: 3},
+		{InitialInterval: time.Second, MaximumAttempts: -1},
+		{InitialInterval: time.Second, MaximumAttempts: 3, BackoffCoefficient: 0.5},
+		{InitialInterval: time.Second, MaximumAttempts: 3, MaximumInterval: -time.Second},
+		{InitialInterval: time.Second, ExpirationInterval: -time.Second},
+	} {
+		require.Error(t, validateLocalActivityRetryPolicy(invalid))
+	}
+
+	// the policy is not defaulted
+	policy := &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}
+	require.NoError(t, validateLocalActivityRetryPolicy(policy))
+	require.Equal(t, &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}, policy)
+}
+
+func TestLocalActivityRetryExpired(t *testing.T) {
+	scheduled := time.Unix(1000, 0)
+	params := &executeLocalActivityParams{ScheduledTime: scheduled}
+	require.False(t, params.retryExpired(scheduled.Add(time.Hour)), "no retry policy")
+
+	params.RetryPolicy = &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}
+	require.False(t, params.retryExpired(scheduled.Add(time.Hour)), "no expiration")
+
+	params.RetryPolicy.ExpirationInterval = time.Minute
+	require.False(t, params.retryExpired(scheduled.Add(time.Minute)))
+	require.True(t, params.retryExpired(scheduled.Add(time.Minute+time.Nanosecond)))
+}
+
 const (
 	memoTestKey = "testKey"
 	memoTestVal = "testVal"
@@ -1295,9 +1471,12 @@ var _ WorkflowInterceptor = (*tracingInterceptor)(nil)
 
 type tracingInterceptor struct {
 	WorkflowInterceptorBase
-	trace []string
+	trace      []string
+	queryTrace []string
 }
 
+var _ WorkflowQueryInterceptor = (*tracingInterceptor)(nil)
+
 func (t *tracingInterceptor) ExecuteActivity(ctx Context, activityType string, args ...interface{}) Future {
 	t.trace = append(t.trace, "ExecuteActivity "+activityType)
 	return t.Next.ExecuteActivity(ctx, activityType, args...)
@@ -1310,6 +1489,16 @@ func (t *tracingInterceptor) ExecuteWorkflow(ctx Context, workflowType string, a
 	return result
 }
 
+func (t *tracingInterceptor) HandleQuery(queryType string, queryArgs []byte, next func(string, []byte) ([]byte, error)) ([]byte, error) {
+	result, err := next(queryType, queryArgs)
+	if err != nil {
+		t.queryTrace = append(t.queryTrace, "HandleQuery "+queryType+" failed")
+	} else {
+		t.queryTrace = append(t.queryTrace, "HandleQuery "+queryType)
+	}
+	return result, err
+}
+
 type WorkflowOptionTest struct {
 	suite.Suite
 }
@@ -1325,6 +1514,9 @@ func (t *WorkflowOptionTest) TestKnowQueryType_NoHandlers() {
 			QueryTypeStackTrace,
 			QueryTypeOpenSessions,
 			QueryTypeQueryTypes,
+			QueryTypePendingOperations,
+			QueryTypeRecentLogs,
+			QueryTypeMetadata,
 		},
 		wo.KnownQueryTypes())
 }
@@ -1340,6 +1532,9 @@ func (t *WorkflowOptionTest) TestKnowQueryType_WithHandlers() {
 			QueryTypeStackTrace,
 			QueryTypeOpenSessions,
 			QueryTypeQueryTypes,
+			QueryTypePendingOperations,
+			QueryTypeRecentLogs,
+			QueryTypeMetadata,
 			"a",
 			"b",
 		},
@@ -22,6 +22,7 @@
 package internal
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -120,6 +121,10 @@ type (
 		locker    sync.Mutex
 		testSuite *WorkflowTestSuite
 
+		// decisionsLock guards the decisions of the environments, which can be recorded from the goroutine of a
+		// coroutine being closed, e.g. when it cancels a timer on exit, while the main loop starts a decision task.
+		decisionsLock sync.Mutex
+
 		taskListSpecificActivities map[string]*taskListSpecificActivity
 
 		mock         *mock.Mock
@@ -127,6 +132,7 @@ type (
 		logger       *zap.Logger
 		metricsScope *metrics.TaggedScope
 		ctxProps     []ContextPropagator
+		testClient   Client // returned by GetActivityClient
 		mockClock    *clock.Mock
 		wallClock    clock.Clock
 		startTime    time.Time
@@ -160,6 +166,9 @@ type (
 		onTimerCancelledListener         func(timerID string)
 
 		cronMaxIterations int
+
+		coverage *workflowCoverageRecorder // GetVersion branches and selector arms exercised, shared by child workflows
+		fuzz     *fuzzState                // nil unless executed by WorkflowTestSuite.FuzzWorkflow
 	}
 
 	// testWorkflowEnvironmentImpl is the environment that runs the workflow/activity unit tests.
@@ -169,10 +178,11 @@ type (
 		registry             *registry
 		workflowInterceptors []WorkflowInterceptorFactory
 
-		workflowInfo   *WorkflowInfo
-		workflowDef    workflowDefinition
-		changeVersions map[string]Version
-		openSessions   map[string]*SessionInfo
+		workflowInfo          *WorkflowInfo
+		workflowDef           workflowDefinition
+		workflowDataConverter DataConverter // RegisterWorkflowOptions.DataConverter of the executed workflow
+		changeVersions        map[string]Version
+		openSessions          map[string]*SessionInfo
 
 		workflowCancelHandler func()
 		signalHandler         func(name string, input []byte)
@@ -186,6 +196,8 @@ type (
 		workerOptions    WorkerOptions
 		executionTimeout time.Duration
 
+		queryType string // the type of the query being answered, which tags the logger and metrics scope
+
 		heartbeatDetails []byte
 
 		workerStopChannel  chan struct{}
@@ -195,6 +207,10 @@ type (
 		cronIterations    int
 		workflowInput     []byte
 		cronOverlapPolicy shared.CronOverlapPolicy
+
+		pendingDecisions     []*shared.Decision   // decisions of the decision task in progress
+		producedDecisions    [][]*shared.Decision // decisions of the completed decision tasks
+		mutableSideEffectIDs map[string][]byte    // last recorded value of each MutableSideEffect ID
 	}
 
 	testSessionEnvironmentImpl struct {
@@ -239,6 +255,8 @@ func newTestWorkflowEnvironmentImpl(s *WorkflowTestSuite, parentRegistry *regist
 			expectedMockCalls: make(map[string]struct{}),
 
 			cronMaxIterations: -1,
+
+			coverage: newWorkflowCoverageRecorder(),
 		},
 
 		workflowInfo: &WorkflowInfo{
@@ -302,7 +320,7 @@ func newTestWorkflowEnvironmentImpl(s *WorkflowTestSuite, parentRegistry *regist
 		activityInfo := env.getActivityInfo(activityID, activityHandle.activityType)
 		env.postCallback(func() {
 			if env.onActivityHeartbeatListener != nil {
-				env.onActivityHeartbeatListener(activityInfo, newEncodedValues(r.Details, env.GetDataConverter()))
+				env.onActivityHeartbeatListener(activityInfo, newEncodedValues(r.Details, env.workerOptions.DataConverter))
 			}
 		}, false)
 
@@ -375,6 +393,10 @@ func (env *testWorkflowEnvironmentImpl) newTestWorkflowEnvironmentForChild(param
 	if params.workflowID == "" {
 		params.workflowID = env.workflowInfo.WorkflowExecution.RunID + "_" + getStringID(env.nextID())
 	}
+	// retries and cron runs of a child workflow pass no started handler, they are not started by a decision
+	if startedHandler != nil {
+		env.recordStartChildWorkflowDecision(params)
+	}
 	var cronSchedule *string
 	if len(params.cronSchedule) > 0 {
 		cronSchedule = &params.cronSchedule
@@ -389,9 +411,27 @@ func (env *testWorkflowEnvironmentImpl) newTestWorkflowEnvironmentForChild(param
 	childEnv.workflowInfo.ExecutionStartToCloseTimeoutSeconds = *params.executionStartToCloseTimeoutSeconds
 	childEnv.workflowInfo.TaskStartToCloseTimeoutSeconds = *params.taskStartToCloseTimeoutSeconds
 	childEnv.workflowInfo.lastCompletionResult = params.lastCompletionResult
+	childEnv.workflowInfo.lastFailureReason = params.lastFailureReason
+	childEnv.workflowInfo.lastFailureDetails = params.lastFailureDetails
 	childEnv.workflowInfo.CronSchedule = cronSchedule
-	childEnv.workflowInfo.ParentWorkflowDomain = &env.workflowInfo.Domain
-	childEnv.workflowInfo.ParentWorkflowExecution = &env.workflowInfo.WorkflowExecution
+	childEnv.workflowInfo.RetryPolicy = params.retryPolicy
+	parentDomain, parentExecution := env.workflowInfo.Domain, env.workflowInfo.WorkflowExecution
+	childEnv.workflowInfo.ParentWorkflowDomain = &parentDomain
+	childEnv.workflowInfo.ParentWorkflowExecution = &parentExecution
+	if len(params.memo) > 0 {
+		memo, err := getWorkflowMemo(params.memo, childEnv.GetDataConverter())
+		if err != nil {
+			return nil, err
+		}
+		childEnv.workflowInfo.Memo = memo
+	}
+	if len(params.searchAttributes) > 0 {
+		searchAttributes, err := serializeSearchAttributes(params.searchAttributes)
+		if err != nil {
+			return nil, err
+		}
+		childEnv.workflowInfo.SearchAttributes = searchAttributes
+	}
 	childEnv.executionTimeout = time.Duration(*params.executionStartToCloseTimeoutSeconds) * time.Second
 	childEnv.cronOverlapPolicy = params.cronOverlapPolicy
 	if workflowHandler, ok := env.runningWorkflows[params.workflowID]; ok {
@@ -431,6 +471,9 @@ func (env *testWorkflowEnvironmentImpl) setWorkerOptions(options WorkerOptions)
 	if options.DataConverter != nil {
 		env.workerOptions.DataConverter = options.DataConverter
 	}
+	if options.ActivityClient != nil {
+		env.workerOptions.ActivityClient = options.ActivityClient
+	}
 	// Uncomment when resourceID is exposed to user.
 	// if options.SessionResourceID != "" {
 	// 	env.workerOptions.SessionResourceID = options.SessionResourceID
@@ -444,7 +487,26 @@ func (env *testWorkflowEnvironmentImpl) setWorkerOptions(options WorkerOptions)
 	if options.Logger != nil {
 		env.workerOptions.Logger = options.Logger
 	}
-	env.workflowInterceptors = options.WorkflowInterceptorChainFactories
+	env.workerOptions.EnableDeterminismGuard = options.EnableDeterminismGuard
+	if options.EnableActivityCallerInfo {
+		env.workerOptions.EnableActivityCallerInfo = true
+	}
+	env.workerOptions.WorkflowPanicArgs = options.WorkflowPanicArgs
+	env.workerOptions.WorkerBugPorts = options.WorkerBugPorts
+	if options.MaxHeartbeatDetailsSize != 0 {
+		env.workerOptions.MaxHeartbeatDetailsSize = options.MaxHeartbeatDetailsSize
+	}
+	if options.TruncateLargeHeartbeatDetails {
+		env.workerOptions.TruncateLargeHeartbeatDetails = true
+	}
+	if len(options.WorkflowInterceptorChainFactories) > 0 {
+		env.workflowInterceptors = options.WorkflowInterceptorChainFactories
+	}
+}
+
+func (env *testWorkflowEnvironmentImpl) setWorkflowTimeout(executionTimeout time.Duration) {
+	env.executionTimeout = executionTimeout
+	env.workflowInfo.ExecutionStartToCloseTimeoutSeconds = int32(executionTimeout.Seconds())
 }
 
 func (env *testWorkflowEnvironmentImpl) setWorkerStopChannel(c chan struct{}) {
@@ -466,8 +528,13 @@ func (env *testWorkflowEnvironmentImpl) setActivityTaskList(tasklist string, act
 func (env *testWorkflowEnvironmentImpl) executeWorkflow(workflowFn interface{}, args ...interface{}) {
 	fType := reflect.TypeOf(workflowFn)
 	if getKind(fType) == reflect.Func {
-		env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{DisableAlreadyRegisteredCheck: true})
+		// keep the options of a workflow that was already registered
+		options := env.registry.getWorkflowOptions(getFunctionName(workflowFn))
+		options.DisableAlreadyRegisteredCheck = true
+		env.RegisterWorkflowWithOptions(workflowFn, options)
 	}
+	// the data converter of the workflow is not used by the activities, as on a worker
+	env.workflowDataConverter = env.registry.getWorkflowDataConverter(WorkflowType{Name: getFunctionName(workflowFn)})
 	workflowType, input, err := getValidatedWorkflowFunction(workflowFn, args, env.GetDataConverter(), env.GetRegistry())
 	if err != nil {
 		panic(err)
@@ -490,6 +557,9 @@ func (env *testWorkflowEnvironmentImpl) executeWorkflowInternal(delayStart time.
 	if err != nil {
 		panic(err)
 	}
+	if _, ok := env.registry.getMatchedWorkflowTypeAlias(workflowType); ok {
+		env.workflowInfo.MatchedWorkflowTypeAlias = workflowType
+	}
 	env.workflowDef = workflowDefinition
 	// Store the Workflow input for potential Cron
 	env.workflowInput = input
@@ -516,7 +586,7 @@ func (env *testWorkflowEnvironmentImpl) executeWorkflowInternal(delayStart time.
 		timeoutDuration := env.executionTimeout + delayStart
 		env.registerDelayedCallback(func() {
 			if !env.isTestCompleted {
-				env.Complete(nil, ErrDeadlineExceeded)
+				env.complete(nil, ErrDeadlineExceeded)
 			}
 		}, timeoutDuration)
 	}
@@ -530,7 +600,7 @@ func (env *testWorkflowEnvironmentImpl) getWorkflowDefinition(wt WorkflowType) (
 		return nil, fmt.Errorf("unable to find workflow type: %v. Supported types: [%v]", wt.Name, supported)
 	}
 	wd := &workflowExecutorWrapper{
-		workflowExecutor: &workflowExecutor{workflowType: wt.Name, fn: wf},
+		workflowExecutor: &workflowExecutor{workflowType: wt.Name, fn: wf, options: env.registry.getWorkflowOptions(wt.Name)},
 		env:              env,
 	}
 	return newSyncWorkflowDefinition(wd), nil
@@ -584,7 +654,7 @@ func (env *testWorkflowEnvironmentImpl) executeActivityWithOptions(
 	task.HeartbeatDetails = env.heartbeatDetails
 
 	// ensure activityFn is registered to defaultTestTaskList
-	taskHandler := env.newTestActivityTaskHandler(defaultTestTaskList, env.GetDataConverter())
+	taskHandler := env.newTestActivityTaskHandler(defaultTestTaskList, env.workerOptions.DataConverter)
 	result, err := taskHandler.Execute(defaultTestTaskList, task)
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -602,12 +672,12 @@ func (env *testWorkflowEnvironmentImpl) executeActivityWithOptions(
 
 	switch request := result.(type) {
 	case *shared.RespondActivityTaskCanceledRequest:
-		details := newEncodedValues(request.Details, env.GetDataConverter())
+		details := newEncodedValues(request.Details, env.workerOptions.DataConverter)
 		return nil, NewCanceledError(details)
 	case *shared.RespondActivityTaskFailedRequest:
-		return nil, constructError(request.GetReason(), request.Details, env.GetDataConverter())
+		return nil, constructError(request.GetReason(), request.Details, env.workerOptions.DataConverter)
 	case *shared.RespondActivityTaskCompletedRequest:
-		return newEncodedValue(request.Result, env.GetDataConverter()), nil
+		return newEncodedValue(request.Result, env.workerOptions.DataConverter), nil
 	default:
 		// will never happen
 		return nil, fmt.Errorf("unsupported respond type %T", result)
@@ -638,19 +708,26 @@ func (env *testWorkflowEnvironmentImpl) executeLocalActivity(
 		logger:          env.logger,
 		tracer:          opentracing.NoopTracer{},
 		activityTracker: debug.NewNoopActivityTracker(),
+		client:          env.getActivityClient(),
 	}
 
 	result := taskHandler.executeLocalActivityTask(task)
 	if result.err != nil {
 		return nil, result.err
 	}
-	return newEncodedValue(result.result, env.GetDataConverter()), nil
+	return newEncodedValue(result.result, env.workerOptions.DataConverter), nil
 }
 
 func (env *testWorkflowEnvironmentImpl) startDecisionTask() {
 	if !env.isTestCompleted {
 		env.workflowDef.OnDecisionTaskStarted()
 	}
+	env.decisionsLock.Lock()
+	defer env.decisionsLock.Unlock()
+	if len(env.pendingDecisions) > 0 {
+		env.producedDecisions = append(env.producedDecisions, env.pendingDecisions)
+		env.pendingDecisions = nil
+	}
 }
 
 func (env *testWorkflowEnvironmentImpl) isChildWorkflow() bool {
@@ -795,6 +872,11 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelActivity(activityID string)
 	}
 	activityInfo := env.getActivityInfo(activityID, handle.activityType)
 	env.logger.Debug("RequestCancelActivity", zap.String(tagActivityID, activityID))
+	decision := createNewDecision(shared.DecisionTypeRequestCancelActivityTask)
+	decision.RequestCancelActivityTaskDecisionAttributes = &shared.RequestCancelActivityTaskDecisionAttributes{
+		ActivityId: common.StringPtr(activityID),
+	}
+	env.recordDecision(decision)
 	env.deleteHandle(activityID)
 	env.postCallback(func() {
 		handle.callback(nil, NewCanceledError())
@@ -815,6 +897,11 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelTimer(timerID string) {
 
 	delete(env.timers, timerID)
 	timerHandle.timer.Stop()
+	decision := createNewDecision(shared.DecisionTypeCancelTimer)
+	decision.CancelTimerDecisionAttributes = &shared.CancelTimerDecisionAttributes{
+		TimerId: common.StringPtr(timerID),
+	}
+	env.recordDecision(decision)
 	timerHandle.env.postCallback(func() {
 		timerHandle.callback(nil, NewCanceledError())
 		if timerHandle.env.onTimerCancelledListener != nil {
@@ -824,6 +911,14 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelTimer(timerID string) {
 }
 
 func (env *testWorkflowEnvironmentImpl) Complete(result []byte, err error) {
+	if !env.isTestCompleted {
+		env.recordCloseDecision(result, err)
+	}
+	env.complete(result, err)
+}
+
+// complete closes the workflow without recording a decision, e.g. when it times out.
+func (env *testWorkflowEnvironmentImpl) complete(result []byte, err error) {
 	if env.isTestCompleted {
 		env.logger.Debug("Workflow already completed.")
 		return
@@ -844,13 +939,17 @@ func (env *testWorkflowEnvironmentImpl) Complete(result []byte, err error) {
 		case *CanceledError, *ContinueAsNewError, *TimeoutError, *shared.WorkflowExecutionAlreadyStartedError:
 			env.testError = err
 		case *workflowPanicError:
-			env.testError = newPanicError(err.value, err.stackTrace)
+			panicErr := newPanicError(err.value, err.stackTrace)
+			panicErr.workflowArgs = formatWorkflowPanicArgs(env.workerOptions.WorkflowPanicArgs, env.workflowDef)
+			env.testError = panicErr
 		default:
 			reason, details := getErrorDetails(err, dc)
 			env.testError = constructError(reason, details, dc)
 		}
+		env.testResult = nil
 	} else {
 		env.testResult = newEncodedValue(result, dc)
+		env.testError = nil
 	}
 
 	// Only close on:
@@ -917,8 +1016,15 @@ func (h *testWorkflowHandle) rerun(asChild bool) bool {
 		// not successful run this time, carry over from whatever previous run pass to this run.
 		result = env.workflowInfo.lastCompletionResult
 	}
+	// and the error of this run, if it failed
+	var failureReason string
+	var failureDetails []byte
+	if env.testError != nil {
+		failureReason, failureDetails = getErrorDetails(env.testError, env.GetDataConverter())
+	}
 	if asChild {
 		params.lastCompletionResult = result
+		params.lastFailureReason, params.lastFailureDetails = failureReason, failureDetails
 
 		if params.retryPolicy != nil && env.testError != nil {
 			errReason, _ := getErrorDetails(env.testError, env.GetDataConverter())
@@ -971,17 +1077,16 @@ func (h *testWorkflowHandle) rerun(asChild bool) bool {
 				if backoff > 0 {
 					env.cronIterations++
 					// Prepare the env for the next iteration
-					env.runningCount--
-					env.setLastCompletionResult(result)
+					env.workflowInfo.lastCompletionResult = result
+					env.workflowInfo.lastFailureReason, env.workflowInfo.lastFailureDetails = failureReason, failureDetails
 					// Since MainLoop is already running, we just want to execute the dispatcher
-					// which will run the Workflow,
+					// which will run the Workflow, once the mock clock reaches the next scheduled time
 					env.registerDelayedCallback(func() {
-						env.runningCount++
 						env.workflowDef, _ = env.getWorkflowDefinition(env.workflowInfo.WorkflowType)
 						// Use the existing headers and input
 						env.workflowDef.Execute(env, env.header, env.workflowInput)
 						env.startDecisionTask()
-					}, backoff-backoff)
+					}, backoff)
 					return true
 				}
 			}
@@ -998,7 +1103,7 @@ func (env *testWorkflowEnvironmentImpl) CompleteActivity(taskToken []byte, resul
 	var data []byte
 	if result != nil {
 		var encodeErr error
-		data, encodeErr = encodeArg(env.GetDataConverter(), result)
+		data, encodeErr = encodeArg(env.workerOptions.DataConverter, result)
 		if encodeErr != nil {
 			return encodeErr
 		}
@@ -1012,22 +1117,31 @@ func (env *testWorkflowEnvironmentImpl) CompleteActivity(taskToken []byte, resul
 				zap.String(tagActivityID, activityID))
 			return
 		}
-		request := convertActivityResultToRespondRequest("test-identity", taskToken, data, err, env.GetDataConverter())
-		env.handleActivityResult(activityID, request, activityHandle.activityType, env.GetDataConverter())
+		request := convertActivityResultToRespondRequest("test-identity", taskToken, data, err, env.workerOptions.DataConverter)
+		env.handleActivityResult(activityID, request, activityHandle.activityType, env.workerOptions.DataConverter)
 	}, false /* do not auto schedule decision task, because activity might be still pending */)
 
 	return nil
 }
 
 func (env *testWorkflowEnvironmentImpl) GetLogger() *zap.Logger {
+	if env.queryType != "" {
+		return env.logger.With(zap.String(tagQueryType, env.queryType))
+	}
 	return env.logger
 }
 
 func (env *testWorkflowEnvironmentImpl) GetMetricsScope() tally.Scope {
+	if env.queryType != "" {
+		return tagScope(env.workerOptions.MetricsScope, tagQueryType, env.queryType)
+	}
 	return env.workerOptions.MetricsScope
 }
 
 func (env *testWorkflowEnvironmentImpl) GetDataConverter() DataConverter {
+	if env.workflowDataConverter != nil {
+		return env.workflowDataConverter
+	}
 	return env.workerOptions.DataConverter
 }
 
@@ -1043,6 +1157,7 @@ func (env *testWorkflowEnvironmentImpl) ExecuteActivity(parameters executeActivi
 		activityID = *parameters.ActivityID
 	}
 	activityInfo := &activityInfo{activityID: activityID}
+	env.recordScheduleActivityDecision(activityID, parameters)
 	task := newTestActivityTask(
 		defaultTestWorkflowID,
 		defaultTestRunID,
@@ -1060,6 +1175,7 @@ func (env *testWorkflowEnvironmentImpl) ExecuteActivity(parameters executeActivi
 	// activity runs in separate goroutinue outside of workflow dispatcher
 	// do callback in a defer to handle calls to runtime.Goexit inside the activity (which is done by t.FailNow)
 	go func() {
+		clearDeterminismGuardLabels()
 		var result interface{}
 		defer func() {
 			panicErr := recover()
@@ -1204,12 +1320,14 @@ func (env *testWorkflowEnvironmentImpl) ExecuteLocalActivity(params executeLocal
 		tracer:             wOptions.Tracer,
 		contextPropagators: wOptions.ContextPropagators,
 		activityTracker:    debug.NewNoopActivityTracker(),
+		client:             env.getActivityClient(),
 	}
 
 	env.localActivities[activityID] = task
 	env.runningCount++
 
 	go func() {
+		clearDeterminismGuardLabels()
 		result := taskHandler.executeLocalActivityTask(task)
 		env.postCallback(func() {
 			env.handleLocalActivityResult(result)
@@ -1311,6 +1429,7 @@ func (env *testWorkflowEnvironmentImpl) handleLocalActivityResult(result *localA
 	}
 
 	delete(env.localActivities, activityID)
+	env.recordMarkerDecision(localActivityMarkerName)
 	var encodedErr error
 	if result.err != nil {
 		errReason, errDetails := getErrorDetails(result.err, env.GetDataConverter())
@@ -1436,6 +1555,7 @@ func (w *workflowExecutorWrapper) Execute(ctx Context, input []byte) (result []b
 	envInterceptor := &workflowEnvironmentInterceptor{env: env}
 	ctxCopy := newWorkflowContext(w.env, envInterceptor, envInterceptor)
 	go func() {
+		clearDeterminismGuardLabels()
 		// getMockReturn could block if mock is configured to wait. The returned mockRet is what has been configured
 		// for the mock by using MockCallWrapper.Return(). The mockRet could be mock values or mock function. We process
 		// the returned mockRet by calling executeMock() later in the main thread after it is send over via mockReadyChannel.
@@ -1638,6 +1758,7 @@ func (env *testWorkflowEnvironmentImpl) newTestActivityTaskHandler(taskList stri
 		TaskList:          &shared.TaskList{Name: common.StringPtr(taskList), Kind: shared.TaskListKindNormal.Ptr()},
 		UserContext:       wOptions.BackgroundActivityContext,
 		WorkerStopChannel: env.workerStopChannel,
+		activityClient:    env.getActivityClient(),
 	}
 	ensureRequiredParams(&params)
 	if params.UserContext == nil {
@@ -1731,6 +1852,15 @@ func (env *testWorkflowEnvironmentImpl) newTimer(d time.Duration, callback resul
 		duration:       d,
 		timerID:        nextID,
 	}
+	if notifyListener {
+		// timers without listener notifications are internal to the test environment
+		decision := createNewDecision(shared.DecisionTypeStartTimer)
+		decision.StartTimerDecisionAttributes = &shared.StartTimerDecisionAttributes{
+			TimerId:                   common.StringPtr(timerInfo.timerID),
+			StartToFireTimeoutSeconds: common.Int64Ptr(common.Int64Ceil(d.Seconds())),
+		}
+		env.recordDecision(decision)
+	}
 	if notifyListener && env.onTimerScheduledListener != nil {
 		env.onTimerScheduledListener(timerInfo.timerID, d)
 	}
@@ -1738,6 +1868,9 @@ func (env *testWorkflowEnvironmentImpl) newTimer(d time.Duration, callback resul
 }
 
 func (env *testWorkflowEnvironmentImpl) NewTimer(d time.Duration, callback resultHandler) *timerInfo {
+	if env.fuzz != nil {
+		d += env.fuzz.timerJitter()
+	}
 	return env.newTimer(d, callback, true)
 }
 
@@ -1778,6 +1911,7 @@ func (env *testWorkflowEnvironmentImpl) RegisterQueryHandler(handler func(string
 }
 
 func (env *testWorkflowEnvironmentImpl) RequestCancelChildWorkflow(domainName, workflowID string) {
+	env.recordRequestCancelExternalWorkflowDecision(domainName, workflowID, "", true)
 	if childHandle, ok := env.runningWorkflows[workflowID]; ok && !childHandle.handled {
 		// current workflow is a parent workflow, and we are canceling a child workflow
 		childEnv := childHandle.env
@@ -1787,6 +1921,7 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelChildWorkflow(domainName, w
 }
 
 func (env *testWorkflowEnvironmentImpl) RequestCancelExternalWorkflow(domainName, workflowID, runID string, callback resultHandler) {
+	env.recordRequestCancelExternalWorkflowDecision(domainName, workflowID, runID, false)
 	if env.workflowInfo.WorkflowExecution.ID == workflowID {
 		// cancel current workflow
 		env.workflowCancelHandler()
@@ -1800,7 +1935,7 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelExternalWorkflow(domainName
 	} else if childHandle, ok := env.runningWorkflows[workflowID]; ok && !childHandle.handled {
 		// current workflow is a parent workflow, and we are canceling a child workflow
 		if !childHandle.params.waitForCancellation {
-			childHandle.env.Complete(nil, ErrCanceled)
+			childHandle.env.complete(nil, ErrCanceled)
 		}
 		childEnv := childHandle.env
 		env.postCallback(func() {
@@ -1815,6 +1950,7 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelExternalWorkflow(domainName
 	// configured to delay, it will block the main loop which stops the world.
 	env.runningCount++
 	go func() {
+		clearDeterminismGuardLabels()
 		args := []interface{}{domainName, workflowID, runID}
 		// below call will panic if mock is not properly setup.
 		mockRet := env.mock.MethodCalled(mockMethodForRequestCancelExternalWorkflow, args...)
@@ -1838,12 +1974,40 @@ func (env *testWorkflowEnvironmentImpl) IsReplaying() bool {
 	return false
 }
 
+func (env *testWorkflowEnvironmentImpl) IsDeterminismGuardEnabled() bool {
+	return env.workerOptions.EnableDeterminismGuard
+}
+
+func (env *testWorkflowEnvironmentImpl) IsActivityCallerInfoEnabled() bool {
+	return env.workerOptions.EnableActivityCallerInfo
+}
+
+func (env *testWorkflowEnvironmentImpl) IsLocalActivityRetryExpirationEnabled() bool {
+	return !env.workerOptions.WorkerBugPorts.RetryLocalActivitiesAfterExpiration
+}
+
+func (env *testWorkflowEnvironmentImpl) IsDecisionTaskWatchdogEnabled() bool {
+	return false
+}
+
 func (env *testWorkflowEnvironmentImpl) IsCron() bool {
 	// this test environment never replay
 	return env.workflowInfo.CronSchedule != nil && len(*env.workflowInfo.CronSchedule) > 0
 }
 
 func (env *testWorkflowEnvironmentImpl) SignalExternalWorkflow(domainName, workflowID, runID, signalName string, input []byte, arg interface{}, childWorkflowOnly bool, callback resultHandler) {
+	decision := createNewDecision(shared.DecisionTypeSignalExternalWorkflowExecution)
+	decision.SignalExternalWorkflowExecutionDecisionAttributes = &shared.SignalExternalWorkflowExecutionDecisionAttributes{
+		Domain: common.StringPtr(domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		SignalName:        common.StringPtr(signalName),
+		Input:             input,
+		ChildWorkflowOnly: common.BoolPtr(childWorkflowOnly),
+	}
+	env.recordDecision(decision)
 	// check if target workflow is a known workflow
 	if childHandle, ok := env.runningWorkflows[workflowID]; ok {
 		// target workflow is a child
@@ -1872,6 +2036,7 @@ func (env *testWorkflowEnvironmentImpl) SignalExternalWorkflow(domainName, workf
 	// configured to delay, it will block the main loop which stops the world.
 	env.runningCount++
 	go func() {
+		clearDeterminismGuardLabels()
 		args := []interface{}{domainName, workflowID, runID, signalName, arg}
 		// below call will panic if mock is not properly setup.
 		mockRet := env.mock.MethodCalled(mockMethodForSignalExternalWorkflow, args...)
@@ -1929,18 +2094,24 @@ func (env *testWorkflowEnvironmentImpl) executeChildWorkflowWithDelay(delayStart
 }
 
 func (env *testWorkflowEnvironmentImpl) SideEffect(f func() ([]byte, error), callback resultHandler) {
+	env.recordMarkerDecision(sideEffectMarkerName)
 	callback(f())
 }
 
 func (env *testWorkflowEnvironmentImpl) GetVersion(changeID string, minSupported, maxSupported Version, opts ...GetVersionOption) (retVersion Version) {
+	defer func() {
+		env.coverage.recordVersion(changeID, minSupported, maxSupported, retVersion)
+	}()
 	if mockVersion, ok := env.getMockedVersion(changeID, changeID, minSupported, maxSupported); ok {
 		// GetVersion for changeID is mocked
+		env.recordVersionMarkerDecision(changeID)
 		env.UpsertSearchAttributes(createSearchAttributesForChangeVersion(changeID, mockVersion, env.changeVersions))
 		env.changeVersions[changeID] = mockVersion
 		return mockVersion
 	}
 	if mockVersion, ok := env.getMockedVersion(mock.Anything, changeID, minSupported, maxSupported); ok {
 		// GetVersion is mocked with any changeID.
+		env.recordVersionMarkerDecision(changeID)
 		env.UpsertSearchAttributes(createSearchAttributesForChangeVersion(changeID, mockVersion, env.changeVersions))
 		env.changeVersions[changeID] = mockVersion
 		return mockVersion
@@ -1969,6 +2140,10 @@ func (env *testWorkflowEnvironmentImpl) GetVersion(changeID string, minSupported
 	case config.UseMinVersion:
 		version = minSupported
 
+	// If the workflow is fuzzed, use a random supported version to exercise the branches of older versions
+	case env.fuzz != nil && env.fuzz.options.FuzzVersions:
+		version = env.fuzz.version(minSupported, maxSupported)
+
 	// Otherwise, use the maximum supported version
 	default:
 		version = maxSupported
@@ -1977,6 +2152,7 @@ func (env *testWorkflowEnvironmentImpl) GetVersion(changeID string, minSupported
 	// Validate the version against the min and max supported versions
 	// ensuring it is within the acceptable range
 	validateVersion(changeID, version, minSupported, maxSupported)
+	env.recordVersionMarkerDecision(changeID)
 
 	// If the version is not the DefaultVersion, update search attributes
 	// Keeping the DefaultVersion as a special case where no search attributes are updated
@@ -1990,6 +2166,24 @@ func (env *testWorkflowEnvironmentImpl) GetVersion(changeID string, minSupported
 	return version
 }
 
+func (env *testWorkflowEnvironmentImpl) DecidedVersions(changeIDs []string) map[string]Version {
+	versions := make(map[string]Version, len(changeIDs))
+	for _, changeID := range changeIDs {
+		if version, ok := env.changeVersions[changeID]; ok {
+			versions[changeID] = version
+		}
+	}
+	return versions
+}
+
+func (env *testWorkflowEnvironmentImpl) ExecutionVersions() map[string]Version {
+	versions := make(map[string]Version, len(env.changeVersions))
+	for changeID, version := range env.changeVersions {
+		versions[changeID] = version
+	}
+	return versions
+}
+
 func (env *testWorkflowEnvironmentImpl) getMockedVersion(mockedChangeID, changeID string, minSupported, maxSupported Version) (Version, bool) {
 	mockMethod := getMockMethodForGetVersion(mockedChangeID)
 	if _, ok := env.expectedMockCalls[mockMethod]; !ok {
@@ -2037,12 +2231,28 @@ func (env *testWorkflowEnvironmentImpl) UpsertSearchAttributes(attributes map[st
 	}
 
 	attr, err := validateAndSerializeSearchAttributes(attributes)
+	if err == nil {
+		decision := createNewDecision(shared.DecisionTypeUpsertWorkflowSearchAttributes)
+		decision.UpsertWorkflowSearchAttributesDecisionAttributes = &shared.UpsertWorkflowSearchAttributesDecisionAttributes{
+			SearchAttributes: attr,
+		}
+		env.recordDecision(decision)
+	}
 	env.workflowInfo.SearchAttributes = mergeSearchAttributes(env.workflowInfo.SearchAttributes, attr)
 	return err
 }
 
 func (env *testWorkflowEnvironmentImpl) MutableSideEffect(id string, f func() interface{}, equals func(a, b interface{}) bool) Value {
-	return newEncodedValue(env.encodeValue(f()), env.GetDataConverter())
+	encoded := env.encodeValue(f())
+	// like the real environment, a marker is only recorded when the value changes
+	if previous, ok := env.mutableSideEffectIDs[id]; !ok || !bytes.Equal(previous, encoded) {
+		if env.mutableSideEffectIDs == nil {
+			env.mutableSideEffectIDs = make(map[string][]byte)
+		}
+		env.mutableSideEffectIDs[id] = encoded
+		env.recordMarkerDecision(mutableSideEffectMarkerName)
+	}
+	return newEncodedValue(encoded, env.GetDataConverter())
 }
 
 func (env *testWorkflowEnvironmentImpl) AddSession(sessionInfo *SessionInfo) {
@@ -2126,7 +2336,9 @@ func (env *testWorkflowEnvironmentImpl) queryWorkflow(queryType string, args ...
 	if err != nil {
 		return nil, err
 	}
+	env.queryType = queryType
 	blob, err := env.queryHandler(queryType, data)
+	env.queryType = ""
 	if err != nil {
 		return nil, err
 	}
@@ -2151,6 +2363,14 @@ func (env *testWorkflowEnvironmentImpl) setLastCompletionResult(result interface
 	env.workflowInfo.lastCompletionResult = data
 }
 
+func (env *testWorkflowEnvironmentImpl) setLastError(err error) {
+	if err == nil {
+		env.workflowInfo.lastFailureReason, env.workflowInfo.lastFailureDetails = "", nil
+		return
+	}
+	env.workflowInfo.lastFailureReason, env.workflowInfo.lastFailureDetails = getErrorDetails(err, env.GetDataConverter())
+}
+
 func (env *testWorkflowEnvironmentImpl) setHeartbeatDetails(details interface{}) {
 	data, err := encodeArg(env.GetDataConverter(), details)
 	if err != nil {
@@ -2218,3 +2438,128 @@ func (t *testReporter) Errorf(format string, args ...interface{}) {
 func (t *testReporter) Fatalf(format string, args ...interface{}) {
 	t.logger.Fatal(fmt.Sprintf(format, args...))
 }
+
+func (env *testWorkflowEnvironmentImpl) recordDecision(decision *shared.Decision) {
+	env.decisionsLock.Lock()
+	defer env.decisionsLock.Unlock()
+	env.pendingDecisions = append(env.pendingDecisions, decision)
+}
+
+func (env *testWorkflowEnvironmentImpl) getProducedDecisions() [][]*shared.Decision {
+	env.decisionsLock.Lock()
+	defer env.decisionsLock.Unlock()
+	produced := env.producedDecisions
+	if len(env.pendingDecisions) > 0 {
+		produced = append(produced[:len(produced):len(produced)], env.pendingDecisions)
+	}
+	return produced
+}
+
+func (env *testWorkflowEnvironmentImpl) recordScheduleActivityDecision(activityID string, parameters executeActivityParams) {
+	decision := createNewDecision(shared.DecisionTypeScheduleActivityTask)
+	decision.ScheduleActivityTaskDecisionAttributes = &shared.ScheduleActivityTaskDecisionAttributes{
+		ActivityId:                    common.StringPtr(activityID),
+		ActivityType:                  activityTypePtr(parameters.ActivityType),
+		TaskList:                      common.TaskListPtr(shared.TaskList{Name: common.StringPtr(parameters.TaskListName), Kind: parameters.TaskListKind.Ptr()}),
+		Input:                         parameters.Input,
+		ScheduleToCloseTimeoutSeconds: common.Int32Ptr(parameters.ScheduleToCloseTimeoutSeconds),
+		StartToCloseTimeoutSeconds:    common.Int32Ptr(parameters.StartToCloseTimeoutSeconds),
+		ScheduleToStartTimeoutSeconds: common.Int32Ptr(parameters.ScheduleToStartTimeoutSeconds),
+		HeartbeatTimeoutSeconds:       common.Int32Ptr(parameters.HeartbeatTimeoutSeconds),
+		RetryPolicy:                   parameters.RetryPolicy,
+		Header:                        parameters.Header,
+	}
+	env.recordDecision(decision)
+}
+
+func (env *testWorkflowEnvironmentImpl) recordStartChildWorkflowDecision(params *executeWorkflowParams) {
+	decision := createNewDecision(shared.DecisionTypeStartChildWorkflowExecution)
+	decision.StartChildWorkflowExecutionDecisionAttributes = &shared.StartChildWorkflowExecutionDecisionAttributes{
+		Domain:                              params.domain,
+		WorkflowId:                          common.StringPtr(params.workflowID),
+		WorkflowType:                        workflowTypePtr(*params.workflowType),
+		TaskList:                            common.TaskListPtr(shared.TaskList{Name: params.taskListName}),
+		Input:                               params.input,
+		ExecutionStartToCloseTimeoutSeconds: params.executionStartToCloseTimeoutSeconds,
+		TaskStartToCloseTimeoutSeconds:      params.taskStartToCloseTimeoutSeconds,
+		ParentClosePolicy:                   params.parentClosePolicy.toThriftPtr(),
+		WorkflowIdReusePolicy:               params.workflowIDReusePolicy.toThriftPtr(),
+		RetryPolicy:                         params.retryPolicy,
+		CronSchedule:                        common.StringPtr(params.cronSchedule),
+		Header:                              params.header,
+	}
+	env.recordDecision(decision)
+}
+
+func (env *testWorkflowEnvironmentImpl) recordRequestCancelExternalWorkflowDecision(domainName, workflowID, runID string, childWorkflowOnly bool) {
+	decision := createNewDecision(shared.DecisionTypeRequestCancelExternalWorkflowExecution)
+	decision.RequestCancelExternalWorkflowExecutionDecisionAttributes = &shared.RequestCancelExternalWorkflowExecutionDecisionAttributes{
+		Domain:            common.StringPtr(domainName),
+		WorkflowId:        common.StringPtr(workflowID),
+		RunId:             common.StringPtr(runID),
+		ChildWorkflowOnly: common.BoolPtr(childWorkflowOnly),
+	}
+	env.recordDecision(decision)
+}
+
+// recordMarkerDecision records a RecordMarker decision. Unlike the real environment, the marker details are not set.
+func (env *testWorkflowEnvironmentImpl) recordMarkerDecision(markerName string) {
+	decision := createNewDecision(shared.DecisionTypeRecordMarker)
+	decision.RecordMarkerDecisionAttributes = &shared.RecordMarkerDecisionAttributes{
+		MarkerName: common.StringPtr(markerName),
+	}
+	env.recordDecision(decision)
+}
+
+// recordVersionMarkerDecision records the version marker of changeID the first time its version is decided.
+func (env *testWorkflowEnvironmentImpl) recordVersionMarkerDecision(changeID string) {
+	if _, ok := env.changeVersions[changeID]; !ok {
+		env.recordMarkerDecision(versionMarkerName)
+	}
+}
+
+func (env *testWorkflowEnvironmentImpl) recordCloseDecision(result []byte, err error) {
+	var decision *shared.Decision
+	switch err := err.(type) {
+	case nil:
+		decision = createNewDecision(shared.DecisionTypeCompleteWorkflowExecution)
+		decision.CompleteWorkflowExecutionDecisionAttributes = &shared.CompleteWorkflowExecutionDecisionAttributes{
+			Result: result,
+		}
+	case *workflowPanicError:
+		// a panic fails the decision task instead of the workflow
+		return
+	case *CanceledError:
+		_, details := getErrorDetails(err, env.GetDataConverter())
+		decision = createNewDecision(shared.DecisionTypeCancelWorkflowExecution)
+		decision.CancelWorkflowExecutionDecisionAttributes = &shared.CancelWorkflowExecutionDecisionAttributes{
+			Details: details,
+		}
+	case *ContinueAsNewError:
+		decision = createNewDecision(shared.DecisionTypeContinueAsNewWorkflowExecution)
+		decision.ContinueAsNewWorkflowExecutionDecisionAttributes = &shared.ContinueAsNewWorkflowExecutionDecisionAttributes{
+			WorkflowType:                        workflowTypePtr(*err.params.workflowType),
+			Input:                               err.params.input,
+			TaskList:                            common.TaskListPtr(shared.TaskList{Name: err.params.taskListName}),
+			ExecutionStartToCloseTimeoutSeconds: err.params.executionStartToCloseTimeoutSeconds,
+			TaskStartToCloseTimeoutSeconds:      err.params.taskStartToCloseTimeoutSeconds,
+			Header:                              err.params.header,
+		}
+	default:
+		reason, details := getErrorDetails(err, env.GetDataConverter())
+		decision = createNewDecision(shared.DecisionTypeFailWorkflowExecution)
+		decision.FailWorkflowExecutionDecisionAttributes = &shared.FailWorkflowExecutionDecisionAttributes{
+			Reason:  common.StringPtr(reason),
+			Details: details,
+		}
+	}
+	env.recordDecision(decision)
+}
+
+// getActivityClient returns the client set with SetTestClient, or else WorkerOptions.ActivityClient.
+func (env *testWorkflowEnvironmentImpl) getActivityClient() Client {
+	if env.testClient != nil {
+		return env.testClient
+	}
+	return env.workerOptions.ActivityClient
+}
@@ -0,0 +1,327 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFuzzIterations     = 100
+	defaultFuzzMaxSignalDelay = time.Hour
+
+	selectorArmReceive = "receive"
+	selectorArmSend    = "send"
+	selectorArmFuture  = "future"
+	selectorArmDefault = "default"
+	selectorArmTimeout = "timeout"
+)
+
+type (
+	// FuzzOptions configures WorkflowTestSuite.FuzzWorkflow.
+	FuzzOptions struct {
+		// Required: registers the workflow, its activities and the mocks on the environment of each iteration.
+		Setup func(env *TestWorkflowEnvironment)
+
+		// Optional: number of executions of the workflow.
+		// default: 100
+		Iterations int
+
+		// Optional: seed of the first iteration, iteration i uses Seed+i. A failure is reproduced by running a single
+		// iteration with the seed of its FuzzFailure.
+		// default: the current time
+		Seed int64
+
+		// Optional: signals sent to each execution, each after a random delay up to MaxSignalDelay, so that they are
+		// received in different orders and before or after the timers of the workflow.
+		Signals []FuzzSignal
+
+		// Optional: maximum delay of the signals.
+		// default: 1 hour
+		MaxSignalDelay time.Duration
+
+		// Optional: maximum duration randomly added to each timer of the workflow, so that timers of close durations
+		// fire in different orders.
+		// default: no jitter
+		TimerJitter time.Duration
+
+		// Optional: when set, GetVersion calls which are not mocked and have no GetVersionOption return a random
+		// version between minSupported and maxSupported instead of maxSupported, to exercise the branches kept for
+		// workflows started by previous versions of the code.
+		FuzzVersions bool
+	}
+
+	// FuzzSignal is a signal sent by WorkflowTestSuite.FuzzWorkflow.
+	FuzzSignal struct {
+		Name string
+		Arg  interface{}
+	}
+
+	// FuzzReport is the result of WorkflowTestSuite.FuzzWorkflow.
+	FuzzReport struct {
+		// Seed is the seed of the first iteration.
+		Seed       int64
+		Iterations int
+		Failures   []FuzzFailure
+		// Coverage merges the coverage of all iterations.
+		Coverage WorkflowCoverage
+	}
+
+	// FuzzFailure is an iteration of WorkflowTestSuite.FuzzWorkflow which failed, did not complete or panicked.
+	FuzzFailure struct {
+		Seed int64
+		Err  error
+	}
+
+	// WorkflowCoverage reports the GetVersion branches and selector arms exercised by test workflows, which are
+	// executed by the dispatcher and often missed by the Go coverage of replay only paths.
+	WorkflowCoverage struct {
+		// Versions are sorted by change ID.
+		Versions []VersionCoverage
+		// SelectorArms are sorted by location.
+		SelectorArms []SelectorArmCoverage
+	}
+
+	// VersionCoverage is the coverage of the versions of a GetVersion change ID.
+	VersionCoverage struct {
+		ChangeID string
+		// MinSupported and MaxSupported are the widest range passed to GetVersion for the change ID.
+		MinSupported Version
+		MaxSupported Version
+		// Exercised are the sorted versions returned by GetVersion.
+		Exercised []Version
+	}
+
+	// SelectorArmCoverage is the coverage of a case added to selectors.
+	SelectorArmCoverage struct {
+		// Location is the directory, file and line of the AddReceive, AddSend, AddFuture or AddDefault call.
+		Location string
+		// Kind is "receive", "send", "future" or "default".
+		Kind string
+		// Added is the number of times the case was added to a selector.
+		Added int
+		// Selected is the number of times the case was selected.
+		Selected int
+	}
+
+	workflowCoverageRecorder struct {
+		sync.Mutex
+		versions map[string]*versionCoverage
+		arms     map[string]*SelectorArmCoverage
+	}
+
+	versionCoverage struct {
+		minSupported, maxSupported Version
+		exercised                  map[Version]struct{}
+	}
+
+	fuzzState struct {
+		options FuzzOptions
+		random  *rand.Rand
+	}
+)
+
+// FuzzWorkflow executes the workflow registered by options.Setup in a new test environment for each iteration, with
+// the signals of options.Signals sent at random times, optionally jittered timers and random GetVersion versions.
+// Unlike the Go coverage, the returned report tells which GetVersion branches and selector arms were exercised, and
+// the seeds of the iterations which failed:
+//
+//	report := s.FuzzWorkflow(OrderWorkflow, FuzzOptions{
+//		Setup: func(env *TestWorkflowEnvironment) {
+//			env.RegisterWorkflow(OrderWorkflow)
+//			env.OnActivity(ChargeActivity, mock.Anything, mock.Anything).Return(nil)
+//		},
+//		Signals:      []FuzzSignal{{Name: "cancel"}, {Name: "update", Arg: update}},
+//		FuzzVersions: true,
+//	}, order)
+//	s.Empty(report.Failures)
+//	t.Log(report.Coverage)
+func (s *WorkflowTestSuite) FuzzWorkflow(workflowFn interface{}, options FuzzOptions, args ...interface{}) *FuzzReport {
+	if options.Setup == nil {
+		panic("FuzzOptions.Setup must register the workflow")
+	}
+	if options.Iterations <= 0 {
+		options.Iterations = defaultFuzzIterations
+	}
+	if options.Seed == 0 {
+		options.Seed = time.Now().UnixNano()
+	}
+	if options.MaxSignalDelay <= 0 {
+		options.MaxSignalDelay = defaultFuzzMaxSignalDelay
+	}
+
+	report := &FuzzReport{Seed: options.Seed, Iterations: options.Iterations}
+	coverage := newWorkflowCoverageRecorder()
+	for i := 0; i < options.Iterations; i++ {
+		seed := options.Seed + int64(i)
+		if err := s.fuzzIteration(workflowFn, options, seed, coverage, args); err != nil {
+			report.Failures = append(report.Failures, FuzzFailure{Seed: seed, Err: err})
+		}
+	}
+	report.Coverage = coverage.coverage()
+	return report
+}
+
+func (s *WorkflowTestSuite) fuzzIteration(workflowFn interface{}, options FuzzOptions, seed int64, coverage *workflowCoverageRecorder, args []interface{}) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("test environment panic: %v", p)
+		}
+	}()
+
+	env := s.NewTestWorkflowEnvironment()
+	state := &fuzzState{options: options, random: rand.New(rand.NewSource(seed))}
+	env.impl.fuzz = state
+	env.impl.coverage = coverage
+	options.Setup(env)
+	for _, signal := range options.Signals {
+		signal := signal
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(signal.Name, signal.Arg)
+		}, time.Duration(state.random.Int63n(int64(options.MaxSignalDelay)+1)))
+	}
+
+	env.ExecuteWorkflow(workflowFn, args...)
+	if !env.IsWorkflowCompleted() {
+		return fmt.Errorf("workflow did not complete")
+	}
+	return env.GetWorkflowError()
+}
+
+// GetWorkflowCoverage returns the GetVersion branches and selector arms exercised by the workflow and its child
+// workflows so far.
+func (t *TestWorkflowEnvironment) GetWorkflowCoverage() WorkflowCoverage {
+	return t.impl.coverage.coverage()
+}
+
+// Missing returns the versions between MinSupported and MaxSupported which were not exercised.
+func (c VersionCoverage) Missing() []Version {
+	var missing []Version
+	for version := c.MinSupported; version <= c.MaxSupported; version++ {
+		i := sort.Search(len(c.Exercised), func(i int) bool { return c.Exercised[i] >= version })
+		if i == len(c.Exercised) || c.Exercised[i] != version {
+			missing = append(missing, version)
+		}
+	}
+	return missing
+}
+
+// String formats the coverage as a human readable report.
+func (c WorkflowCoverage) String() string {
+	var b strings.Builder
+	b.WriteString("GetVersion branches:\n")
+	for _, v := range c.Versions {
+		fmt.Fprintf(&b, "  %s: exercised %v", v.ChangeID, v.Exercised)
+		if missing := v.Missing(); len(missing) > 0 {
+			fmt.Fprintf(&b, ", missing %v", missing)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Selector arms:\n")
+	for _, arm := range c.SelectorArms {
+		fmt.Fprintf(&b, "  %s %s: selected %d of %d\n", arm.Location, arm.Kind, arm.Selected, arm.Added)
+	}
+	return b.String()
+}
+
+func newWorkflowCoverageRecorder() *workflowCoverageRecorder {
+	return &workflowCoverageRecorder{
+		versions: make(map[string]*versionCoverage),
+		arms:     make(map[string]*SelectorArmCoverage),
+	}
+}
+
+func (r *workflowCoverageRecorder) recordVersion(changeID string, minSupported, maxSupported, version Version) {
+	r.Lock()
+	defer r.Unlock()
+	v, ok := r.versions[changeID]
+	if !ok {
+		v = &versionCoverage{minSupported: minSupported, maxSupported: maxSupported, exercised: make(map[Version]struct{})}
+		r.versions[changeID] = v
+	}
+	if minSupported < v.minSupported {
+		v.minSupported = minSupported
+	}
+	if maxSupported > v.maxSupported {
+		v.maxSupported = maxSupported
+	}
+	v.exercised[version] = struct{}{}
+}
+
+// addArm records a case added to a selector by the caller of the selector method, and returns its location.
+func (r *workflowCoverageRecorder) addArm(kind string) string {
+	location := "unknown"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		location = fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(file)), filepath.Base(file), line)
+	}
+	r.Lock()
+	defer r.Unlock()
+	arm, ok := r.arms[location]
+	if !ok {
+		arm = &SelectorArmCoverage{Location: location, Kind: kind}
+		r.arms[location] = arm
+	}
+	arm.Added++
+	return location
+}
+
+func (r *workflowCoverageRecorder) selectArm(location string) {
+	r.Lock()
+	defer r.Unlock()
+	r.arms[location].Selected++
+}
+
+func (r *workflowCoverageRecorder) coverage() WorkflowCoverage {
+	r.Lock()
+	defer r.Unlock()
+	var c WorkflowCoverage
+	for changeID, v := range r.versions {
+		coverage := VersionCoverage{ChangeID: changeID, MinSupported: v.minSupported, MaxSupported: v.maxSupported}
+		for version := range v.exercised {
+			coverage.Exercised = append(coverage.Exercised, version)
+		}
+		sort.Slice(coverage.Exercised, func(i, j int) bool { return coverage.Exercised[i] < coverage.Exercised[j] })
+		c.Versions = append(c.Versions, coverage)
+	}
+	sort.Slice(c.Versions, func(i, j int) bool { return c.Versions[i].ChangeID < c.Versions[j].ChangeID })
+	for _, arm := range r.arms {
+		c.SelectorArms = append(c.SelectorArms, *arm)
+	}
+	sort.Slice(c.SelectorArms, func(i, j int) bool { return c.SelectorArms[i].Location < c.SelectorArms[j].Location })
+	return c
+}
+
+func (f *fuzzState) version(minSupported, maxSupported Version) Version {
+	return minSupported + Version(f.random.Intn(int(maxSupported-minSupported)+1))
+}
+
+func (f *fuzzState) timerJitter() time.Duration {
+	if f.options.TimerJitter <= 0 {
+		return 0
+	}
+	return time.Duration(f.random.Int63n(int64(f.options.TimerJitter) + 1))
+}
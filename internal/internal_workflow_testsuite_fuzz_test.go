@@ -0,0 +1,127 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func fuzzedTestWorkflow(ctx Context) (string, error) {
+	version := GetVersion(ctx, "add-deadline", DefaultVersion, 1)
+	timeout := time.Hour
+	if version == DefaultVersion {
+		timeout = 2 * time.Hour
+	}
+
+	var result string
+	selector := NewSelector(ctx)
+	selector.AddReceive(GetSignalChannel(ctx, "approve"), func(c Channel, more bool) {
+		c.Receive(ctx, nil)
+		result = "approved"
+	})
+	selector.AddReceive(GetSignalChannel(ctx, "reject"), func(c Channel, more bool) {
+		c.Receive(ctx, nil)
+		result = "rejected"
+	})
+	selector.AddFuture(NewTimer(ctx, timeout), func(f Future) {
+		result = "timed out"
+	})
+	selector.Select(ctx)
+	if result == "rejected" && version == DefaultVersion {
+		return "", errors.New("rejected by the old version")
+	}
+	return result, nil
+}
+
+func TestFuzzWorkflow(t *testing.T) {
+	s := &WorkflowTestSuite{}
+	s.SetLogger(zap.NewNop())
+	options := FuzzOptions{
+		Setup: func(env *TestWorkflowEnvironment) {
+			env.RegisterWorkflow(fuzzedTestWorkflow)
+		},
+		Iterations:     50,
+		Seed:           1,
+		Signals:        []FuzzSignal{{Name: "approve"}, {Name: "reject"}},
+		MaxSignalDelay: 3 * time.Hour,
+		TimerJitter:    time.Minute,
+		FuzzVersions:   true,
+	}
+	report := s.FuzzWorkflow(fuzzedTestWorkflow, options)
+	assert.Equal(t, int64(1), report.Seed)
+	assert.Equal(t, 50, report.Iterations)
+
+	require.NotEmpty(t, report.Failures, "the old version rejects in some orderings")
+	for _, failure := range report.Failures {
+		assert.EqualError(t, failure.Err, "rejected by the old version")
+	}
+
+	require.Len(t, report.Coverage.Versions, 1)
+	assert.Equal(t, VersionCoverage{ChangeID: "add-deadline", MinSupported: DefaultVersion, MaxSupported: 1, Exercised: []Version{DefaultVersion, 0, 1}}, report.Coverage.Versions[0])
+	assert.Empty(t, report.Coverage.Versions[0].Missing())
+
+	require.Len(t, report.Coverage.SelectorArms, 3)
+	kinds := map[string]bool{}
+	for _, arm := range report.Coverage.SelectorArms {
+		assert.True(t, strings.HasPrefix(arm.Location, "internal/internal_workflow_testsuite_fuzz_test.go:"), arm.Location)
+		assert.Equal(t, 50, arm.Added)
+		assert.NotZero(t, arm.Selected, arm.Location)
+		kinds[arm.Kind] = true
+	}
+	assert.Equal(t, map[string]bool{selectorArmReceive: true, selectorArmFuture: true}, kinds)
+	assert.Contains(t, report.Coverage.String(), "add-deadline: exercised [-1 0 1]\n")
+
+	// a failure is reproduced with its seed
+	options.Iterations = 1
+	options.Seed = report.Failures[0].Seed
+	assert.Len(t, s.FuzzWorkflow(fuzzedTestWorkflow, options).Failures, 1)
+}
+
+func TestWorkflowCoverage(t *testing.T) {
+	s := &WorkflowTestSuite{}
+	s.SetLogger(zap.NewNop())
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(fuzzedTestWorkflow)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("approve", nil)
+	}, time.Minute)
+	env.ExecuteWorkflow(fuzzedTestWorkflow)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	coverage := env.GetWorkflowCoverage()
+	require.Len(t, coverage.Versions, 1)
+	assert.Equal(t, []Version{1}, coverage.Versions[0].Exercised)
+	assert.Equal(t, []Version{DefaultVersion, 0}, coverage.Versions[0].Missing())
+	assert.Contains(t, coverage.String(), "add-deadline: exercised [1], missing [-1 0]\n")
+	selected := 0
+	for _, arm := range coverage.SelectorArms {
+		assert.Equal(t, 1, arm.Added)
+		selected += arm.Selected
+	}
+	assert.Equal(t, 1, selected)
+}
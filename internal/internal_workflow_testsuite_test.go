@@ -37,7 +37,10 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
@@ -382,6 +385,91 @@ func (s *WorkflowTestSuiteUnitTest) Test_ActivityWithUserContext() {
 	s.Equal(testValue, value)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityReportCancellation() {
+	type progress struct {
+		Processed int
+	}
+	activityFn := func(ctx context.Context, processed int) error {
+		if err := ReportActivityCancellation(ctx, progress{Processed: processed}); !errors.As(err, new(*CanceledError)) {
+			return fmt.Errorf("unexpected error %v", err)
+		}
+		// any error returned once the cancellation is reported is replaced by the reported one
+		return errors.New("stopped")
+	}
+	succeedingFn := func(ctx context.Context) (string, error) {
+		_ = ReportActivityCancellation(ctx, progress{Processed: 1})
+		return "done", nil
+	}
+
+	env := s.NewTestActivityEnvironment()
+	env.RegisterActivity(activityFn)
+	env.RegisterActivity(succeedingFn)
+	_, err := env.ExecuteActivity(activityFn, 42)
+	var canceledErr *CanceledError
+	s.Require().True(errors.As(err, &canceledErr), "unexpected error %v", err)
+	var details progress
+	s.NoError(canceledErr.Details(&details))
+	s.Equal(progress{Processed: 42}, details)
+
+	// the activity completes when it does not return an error
+	value, err := env.ExecuteActivity(succeedingFn)
+	s.NoError(err)
+	var result string
+	s.NoError(value.Get(&result))
+	s.Equal("done", result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityMarkAsyncCompletion() {
+	var handle TaskTokenHandle
+	asyncFn := func(ctx context.Context, fail bool) (string, error) {
+		var err error
+		if handle, err = MarkActivityAsyncCompletion(ctx); err != nil {
+			return "", err
+		}
+		if fail {
+			return "", errors.New("enqueue failed")
+		}
+		return "ignored", nil
+	}
+
+	env := s.NewTestActivityEnvironment()
+	env.RegisterActivity(asyncFn)
+	_, err := env.ExecuteActivity(asyncFn, false)
+	s.Equal(ErrActivityResultPending, err)
+	s.NotEmpty(handle.TaskToken)
+	s.Equal(defaultTestWorkflowID, handle.WorkflowID)
+	s.Equal(defaultTestRunID, handle.RunID)
+	s.Equal(defaultTestDomainName, handle.Domain)
+	s.NotEmpty(handle.ActivityID)
+
+	data, err := json.Marshal(handle)
+	s.NoError(err)
+	var decoded TaskTokenHandle
+	s.NoError(json.Unmarshal(data, &decoded))
+	s.Equal(handle, decoded)
+
+	// an activity returning an error after being marked fails
+	_, err = env.ExecuteActivity(asyncFn, true)
+	s.EqualError(err, "enqueue failed")
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_LocalActivityMarkAsyncCompletion() {
+	localActivityFn := func(ctx context.Context) error {
+		_, err := MarkActivityAsyncCompletion(ctx)
+		return err
+	}
+	workflowFn := func(ctx Context) error {
+		ctx = WithLocalActivityOptions(ctx, s.localActivityOptions)
+		return ExecuteLocalActivity(ctx, localActivityFn).Get(ctx, nil)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.ErrorContains(env.GetWorkflowError(), "local activities cannot be completed asynchronously")
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ActivityWithHeaderContext() {
 	workerOptions := WorkerOptions{
 		ContextPropagators: []ContextPropagator{NewStringMapPropagator([]string{testHeader})},
@@ -626,6 +714,72 @@ func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflow_Basic() {
 	s.Equal("hello_activity hello_world", actualResult)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflow_ActivityInfo() {
+	activityFn := func(ctx context.Context) (ActivityInfo, error) {
+		return GetActivityInfo(ctx), nil
+	}
+	childWorkflowFn := func(ctx Context) ([]ActivityInfo, error) {
+		var infos [2]ActivityInfo
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		if err := ExecuteActivity(ctx, activityFn).Get(ctx, &infos[0]); err != nil {
+			return nil, err
+		}
+		ctx = WithLocalActivityOptions(ctx, s.localActivityOptions)
+		if err := ExecuteLocalActivity(ctx, activityFn).Get(ctx, &infos[1]); err != nil {
+			return nil, err
+		}
+		return infos[:], nil
+	}
+	workflowFn := func(ctx Context) ([]ActivityInfo, error) {
+		var info ActivityInfo
+		err := ExecuteActivity(WithActivityOptions(ctx, s.activityOptions), activityFn).Get(ctx, &info)
+		if err != nil {
+			return nil, err
+		}
+		var childInfos []ActivityInfo
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{ExecutionStartToCloseTimeout: time.Minute})
+		if err := ExecuteChildWorkflow(ctx, childWorkflowFn).Get(ctx, &childInfos); err != nil {
+			return nil, err
+		}
+		return append([]ActivityInfo{info}, childInfos...), nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterWorkflow(childWorkflowFn)
+	env.RegisterActivity(activityFn)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var infos []ActivityInfo
+	s.NoError(env.GetWorkflowResult(&infos))
+	s.Len(infos, 3)
+	s.Empty(infos[1].WorkflowTaskList, "activities get the caller info only when enabled")
+	s.Nil(infos[1].ParentWorkflowExecution)
+
+	env = s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{EnableActivityCallerInfo: true})
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterWorkflow(childWorkflowFn)
+	env.RegisterActivity(activityFn)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.NoError(env.GetWorkflowResult(&infos))
+	s.Len(infos, 3)
+
+	s.Equal(defaultTestTaskList, infos[0].WorkflowTaskList)
+	s.Nil(infos[0].ParentWorkflowExecution)
+	s.Nil(infos[0].ParentWorkflowDomain)
+	for _, info := range infos[1:] {
+		s.Equal(defaultTestTaskList, info.WorkflowTaskList)
+		s.Equal(infos[0].WorkflowExecution, *info.ParentWorkflowExecution)
+		s.Equal(defaultTestDomain, *info.ParentWorkflowDomain)
+	}
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflow_Basic_WithDataConverter() {
 	workflowFn := func(ctx Context) (string, error) {
 		ctx = WithActivityOptions(ctx, s.activityOptions)
@@ -1274,6 +1428,44 @@ func (s *WorkflowTestSuiteUnitTest) Test_GetVersion() {
 	env.AssertExpectations(s.T())
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_GetVersions() {
+	workflowFn := func(ctx Context) (map[string]Version, error) {
+		GetVersion(ctx, "change_1", DefaultVersion, 2)
+		versions := GetVersions(ctx, "change_1", "change_2")
+		s.Equal(map[string]Version{"change_1": 2, "change_2": DefaultVersion}, versions)
+		s.Equal(DefaultVersion, GetVersion(ctx, "change_2", DefaultVersion, 2))
+		return ExecutionVersions(ctx), nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var versions map[string]Version
+	s.NoError(env.GetWorkflowResult(&versions))
+	s.Equal(map[string]Version{"change_1": 2, "change_2": DefaultVersion}, versions)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_GetVersions_Mock() {
+	workflowFn := func(ctx Context) (map[string]Version, error) {
+		return GetVersions(ctx, "change_1", "change_2"), nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.OnGetVersion("change_1", DefaultVersion, DefaultVersion).Return(Version(1))
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var versions map[string]Version
+	s.NoError(env.GetWorkflowResult(&versions))
+	s.Equal(map[string]Version{"change_1": 1, "change_2": DefaultVersion}, versions)
+	env.AssertExpectations(s.T())
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_GetVersion_ExecuteWithMinVersion() {
 	oldActivity := func(ctx context.Context, msg string) (string, error) {
 		return "hello" + "_" + msg, nil
@@ -1735,6 +1927,159 @@ func (s *WorkflowTestSuiteUnitTest) Test_WorkflowRegistration() {
 	env.ExecuteWorkflow(workflowAlias)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowTypeAliases() {
+	workflowFn := func(ctx Context) (string, error) {
+		return GetWorkflowInfo(ctx).MatchedWorkflowTypeAlias, nil
+	}
+
+	for _, tt := range []struct {
+		workflowType string
+		matchedAlias string
+	}{
+		{workflowType: "workflow-v2", matchedAlias: ""},
+		{workflowType: "workflow-v1", matchedAlias: "workflow-v1"},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "workflow-v2", Aliases: []string{"workflow-v1"}})
+		env.ExecuteWorkflow(tt.workflowType)
+
+		s.True(env.IsWorkflowCompleted())
+		s.NoError(env.GetWorkflowError())
+		var matchedAlias string
+		s.NoError(env.GetWorkflowResult(&matchedAlias))
+		s.Equal(tt.matchedAlias, matchedAlias)
+	}
+}
+
+type signalRecordingClient struct {
+	Client
+	signals []string
+}
+
+func (c *signalRecordingClient) SignalWorkflow(ctx context.Context, workflowID, runID, signalName string, arg interface{}) error {
+	c.signals = append(c.signals, workflowID+"/"+signalName)
+	return nil
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityClient() {
+	signalActivity := func(ctx context.Context, workflowID string) error {
+		return GetActivityClient(ctx).SignalWorkflow(ctx, workflowID, "", "signal", nil)
+	}
+	workflowFn := func(ctx Context) error {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		if err := ExecuteActivity(ctx, signalActivity, "wid1").Get(ctx, nil); err != nil {
+			return err
+		}
+		ctx = WithLocalActivityOptions(ctx, s.localActivityOptions)
+		return ExecuteLocalActivity(ctx, signalActivity, "wid2").Get(ctx, nil)
+	}
+
+	activityEnv := s.NewTestActivityEnvironment()
+	activityEnv.RegisterActivity(signalActivity)
+	_, err := activityEnv.ExecuteActivity(signalActivity, "wid0")
+	s.Error(err, "there is no client by default")
+
+	c := &signalRecordingClient{}
+	activityEnv.SetWorkerOptions(WorkerOptions{ActivityClient: c})
+	_, err = activityEnv.ExecuteActivity(signalActivity, "wid0")
+	s.NoError(err)
+	activityEnv.SetTestClient(c)
+	_, err = activityEnv.ExecuteActivity(signalActivity, "wid0")
+	s.NoError(err)
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(signalActivity)
+	env.SetTestClient(c)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{"wid0/signal", "wid0/signal", "wid1/signal", "wid2/signal"}, c.signals)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_GetProducedDecisions() {
+	workflowFn := func(ctx Context) (string, error) {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		var result string
+		if err := ExecuteActivity(ctx, testActivityHello, "activity").Get(ctx, &result); err != nil {
+			return "", err
+		}
+		if err := Sleep(ctx, time.Minute); err != nil {
+			return "", err
+		}
+		var id string
+		if err := SideEffect(ctx, func(ctx Context) interface{} { return "id" }).Get(&id); err != nil {
+			return "", err
+		}
+		timerCtx, cancel := WithCancel(ctx)
+		NewTimer(timerCtx, time.Hour)
+		cancel()
+		return result + " " + id, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(testActivityHello)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var decisionTypes [][]shared.DecisionType
+	for _, decisions := range env.GetProducedDecisions() {
+		var types []shared.DecisionType
+		for _, decision := range decisions {
+			types = append(types, decision.GetDecisionType())
+		}
+		decisionTypes = append(decisionTypes, types)
+	}
+	s.Equal([][]shared.DecisionType{
+		{shared.DecisionTypeScheduleActivityTask},
+		{shared.DecisionTypeStartTimer},
+		{
+			shared.DecisionTypeRecordMarker,
+			shared.DecisionTypeStartTimer,
+			shared.DecisionTypeCancelTimer,
+			shared.DecisionTypeCompleteWorkflowExecution,
+		},
+	}, decisionTypes)
+
+	decisions := env.GetProducedDecisions()
+	activity := decisions[0][0].ScheduleActivityTaskDecisionAttributes
+	s.Equal(getFunctionName(testActivityHello), activity.ActivityType.GetName())
+	s.Equal(int64(60), decisions[1][0].StartTimerDecisionAttributes.GetStartToFireTimeoutSeconds())
+	s.Equal(sideEffectMarkerName, decisions[2][0].RecordMarkerDecisionAttributes.GetMarkerName())
+	var result string
+	s.NoError(getDefaultDataConverter().FromData(decisions[2][3].CompleteWorkflowExecutionDecisionAttributes.Result, &result))
+	s.Equal("hello_activity id", result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowDefaultActivityOptions() {
+	workflowFn := func(ctx Context) (string, error) {
+		var result, localResult string
+		if err := ExecuteActivity(ctx, testActivityHello, "activity").Get(ctx, &result); err != nil {
+			return "", err
+		}
+		if err := ExecuteLocalActivity(ctx, testActivityHello, "local").Get(ctx, &localResult); err != nil {
+			return "", err
+		}
+		return result + " " + localResult, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{
+		DefaultActivityOptions:      &s.activityOptions,
+		DefaultLocalActivityOptions: &s.localActivityOptions,
+	})
+	env.RegisterActivity(testActivityHello)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("hello_activity hello_local", result)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ActivityFriendlyName() {
 	activityFn := func(msg string) (string, error) {
 		return "hello_" + msg, nil
@@ -1974,6 +2319,88 @@ func (s *WorkflowTestSuiteUnitTest) Test_QueryWorkflow() {
 	verifyStateWithQuery(stateDone)
 }
 
+func TestQueryHandlerLoggerAndMetricsScope(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	scope := tally.NewTestScope("", nil)
+	var s WorkflowTestSuite
+	s.SetLogger(zap.New(core))
+	s.SetMetricsScope(scope)
+	workflowFn := func(ctx Context) error {
+		err := SetQueryHandler(ctx, "state", func() (string, error) {
+			GetLogger(ctx).Info("in query handler")
+			GetMetricsScope(ctx).Counter("queries").Inc(1)
+			return "ready", nil
+		})
+		if err != nil {
+			return err
+		}
+		GetLogger(ctx).Info("in workflow")
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	require.NoError(t, env.GetWorkflowError())
+	_, err := env.QueryWorkflow("state")
+	require.NoError(t, err)
+
+	assert.NotContains(t, logs.FilterMessage("in workflow").All()[0].ContextMap(), tagQueryType)
+	require.Equal(t, 1, logs.FilterMessage("in query handler").Len())
+	assert.Equal(t, "state", logs.FilterMessage("in query handler").All()[0].ContextMap()[tagQueryType])
+	var queries []map[string]string
+	for _, counter := range scope.Snapshot().Counters() {
+		if counter.Name() == "queries" {
+			queries = append(queries, counter.Tags())
+		}
+	}
+	require.Len(t, queries, 1)
+	assert.Equal(t, "state", queries[0][tagQueryType])
+}
+
+func TestQueryHandlerWithContextAndArguments(t *testing.T) {
+	type item struct {
+		Name  string
+		Count int
+	}
+	workflowFn := func(ctx Context) error {
+		if GetQueryInfo(ctx) != nil {
+			return errors.New("workflow context has a query info")
+		}
+		ctx = WithValue(ctx, "tenant", "acme")
+		err := SetQueryHandler(ctx, "items", func(ctx Context, filter item, limit int) (string, error) {
+			return fmt.Sprintf("%v %v %v %v %v", GetQueryInfo(ctx).QueryType, ctx.Value("tenant"), filter.Name, filter.Count, limit), nil
+		})
+		if err != nil {
+			return err
+		}
+		return SetQueryHandler(ctx, "raw", func(ctx Context, input []byte) ([]byte, error) {
+			return append([]byte(GetQueryInfo(ctx).QueryType+" "), input...), nil
+		})
+	}
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	require.NoError(t, env.GetWorkflowError())
+
+	value, err := env.QueryWorkflow("items", item{Name: "apple", Count: 2}, 10)
+	require.NoError(t, err)
+	var result string
+	require.NoError(t, value.Get(&result))
+	assert.Equal(t, "items acme apple 2 10", result)
+
+	_, err = env.QueryWorkflow("items", "not an item", 10)
+	assert.ErrorContains(t, err, "unable to decode the input for queryType: items")
+
+	value, err = env.QueryWorkflow("raw", "input")
+	require.NoError(t, err)
+	var raw []byte
+	require.NoError(t, value.Get(&raw))
+	assert.Equal(t, "raw \"input\"\n", string(raw))
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_WorkflowWithLocalActivity() {
 	localActivityFn := func(ctx context.Context, name string) (string, error) {
 		return "hello " + name, nil
@@ -2673,6 +3100,43 @@ func (s *WorkflowTestSuiteUnitTest) Test_LocalActivityRetry() {
 	s.Equal(3, retriableCount)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_LocalActivityRetryExpiration() {
+	attempts := 0
+	failingFn := func(ctx context.Context) error {
+		attempts++
+		return NewCustomError("bad-luck")
+	}
+
+	workflowFn := func(ctx Context) error {
+		ctx = WithLocalActivityOptions(ctx, LocalActivityOptions{
+			ScheduleToCloseTimeout: time.Minute,
+			RetryPolicy: &RetryPolicy{
+				InitialInterval:    10 * time.Minute,
+				BackoffCoefficient: 1,
+				ExpirationInterval: 25 * time.Minute,
+			},
+		})
+		return ExecuteLocalActivity(ctx, failingFn).Get(ctx, nil)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	timers := 0
+	env.SetOnTimerScheduledListener(func(timerID string, duration time.Duration) {
+		s.Equal(10*time.Minute, duration)
+		timers++
+	})
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	var customErr *CustomError
+	s.True(errors.As(env.GetWorkflowError(), &customErr))
+	s.Equal("bad-luck", customErr.Reason())
+	// attempts at 0, 10 and 20 minutes, the next one at 30 minutes is after the expiration
+	s.Equal(3, attempts)
+	s.Equal(2, timers)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_LocalActivityRetryOnCancel() {
 	attempts := 0
 	localActivityFn := func(ctx context.Context) (int32, error) {
@@ -3132,6 +3596,113 @@ func (s *WorkflowTestSuiteUnitTest) Test_CronWorkflow() {
 	s.Equal(2, totalRuns)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_ExecuteCronWorkflow() {
+	var starts []time.Time
+	var lastResults []int
+	var lastErrors []error
+	cronWorkflow := func(ctx Context) (int, error) {
+		starts = append(starts, Now(ctx))
+		lastErrors = append(lastErrors, GetLastError(ctx))
+		var result int
+		if HasLastCompletionResult(ctx) {
+			s.NoError(GetLastCompletionResult(ctx, &result))
+		}
+		lastResults = append(lastResults, result)
+		Sleep(ctx, time.Minute)
+		if len(starts) == 2 {
+			return 0, errors.New("second run fails")
+		}
+		return result + 1, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkflowCronSchedule("*/5 * * * *")
+	env.RegisterWorkflow(cronWorkflow)
+	startTime, _ := time.Parse(time.RFC3339, "2018-12-20T16:32:00Z")
+	env.SetStartTime(startTime)
+	env.ExecuteCronWorkflow(3, cronWorkflow)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result int
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal(2, result)
+	s.Equal([]int{0, 1, 1}, lastResults)
+	s.Require().Len(lastErrors, 3)
+	s.NoError(lastErrors[0])
+	s.NoError(lastErrors[1])
+	s.IsType(&GenericError{}, lastErrors[2])
+	s.EqualError(lastErrors[2], "second run fails")
+	s.Require().Len(starts, 3)
+	s.Equal(startTime, starts[0])
+	s.Equal(startTime.Add(3*time.Minute), starts[1])
+	s.Equal(startTime.Add(8*time.Minute), starts[2])
+
+	s.Panics(func() {
+		s.NewTestWorkflowEnvironment().ExecuteCronWorkflow(1, cronWorkflow)
+	})
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_LastError() {
+	workflowFn := func(ctx Context) (string, error) {
+		var customErr *CustomError
+		if !errors.As(GetLastError(ctx), &customErr) {
+			return "no error", nil
+		}
+		var details string
+		if err := customErr.Details(&details); err != nil {
+			return "", err
+		}
+		return customErr.Reason() + " " + details, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("no error", result)
+
+	env = s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.SetLastError(NewCustomError("quota-exceeded", "checkpoint-3"))
+	env.ExecuteWorkflow(workflowFn)
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("quota-exceeded checkpoint-3", result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflowRetryLastError() {
+	var lastErrors []error
+	childFn := func(ctx Context) error {
+		lastErrors = append(lastErrors, GetLastError(ctx))
+		if len(lastErrors) < 3 {
+			return NewCustomError(fmt.Sprintf("attempt-%v", len(lastErrors)))
+		}
+		return nil
+	}
+	parentFn := func(ctx Context) error {
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{
+			ExecutionStartToCloseTimeout: time.Minute,
+			RetryPolicy: &RetryPolicy{
+				InitialInterval:    time.Second,
+				BackoffCoefficient: 1,
+				MaximumAttempts:    3,
+			},
+		})
+		return ExecuteChildWorkflow(ctx, childFn).Get(ctx, nil)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(parentFn)
+	env.RegisterWorkflow(childFn)
+	env.ExecuteWorkflow(parentFn)
+	s.NoError(env.GetWorkflowError())
+	s.Require().Len(lastErrors, 3)
+	s.NoError(lastErrors[0])
+	s.EqualError(lastErrors[1], "attempt-1")
+	s.EqualError(lastErrors[2], "attempt-2")
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_CronHasLastResult() {
 	cronWorkflow := func(ctx Context) (int, error) {
 		var result int
@@ -3318,6 +3889,142 @@ func (s *WorkflowTestSuiteUnitTest) Test_AwaitWithTimeout() {
 	s.False(result)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_AwaitWithTimeoutPrimitive() {
+	workflowFn := func(ctx Context) ([]bool, error) {
+		approved := false
+		Go(ctx, func(ctx Context) {
+			GetSignalChannel(ctx, "approve").Receive(ctx, &approved)
+		})
+		start := Now(ctx)
+		ok, err := AwaitWithTimeout(ctx, time.Hour, func() bool { return approved })
+		if err != nil {
+			return nil, err
+		}
+		s.Equal(time.Minute, Now(ctx).Sub(start))
+
+		expired, err := AwaitWithTimeout(ctx, time.Hour, func() bool { return false })
+		if err != nil {
+			return nil, err
+		}
+		s.Equal(time.Minute+time.Hour, Now(ctx).Sub(start))
+
+		immediate, err := AwaitWithTimeout(ctx, time.Hour, func() bool { return approved })
+		return []bool{ok, expired, immediate}, err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("approve", true)
+	}, time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result []bool
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal([]bool{true, false, true}, result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_AwaitWithTimeoutCancellation() {
+	workflowFn := func(ctx Context) error {
+		ctx, cancel := WithCancel(ctx)
+		Go(ctx, func(ctx Context) {
+			_ = Sleep(ctx, time.Minute)
+			cancel()
+		})
+		ok, err := AwaitWithTimeout(ctx, time.Hour, func() bool { return false })
+		s.False(ok)
+		return err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	var canceledErr *CanceledError
+	s.True(errors.As(env.GetWorkflowError(), &canceledErr), "unexpected error %v", env.GetWorkflowError())
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_SelectorWithDeadline() {
+	workflowFn := func(ctx Context) ([]string, error) {
+		var selected []string
+		start := Now(ctx)
+		selector := NewSelectorWithDeadline(ctx).
+			AddTimeout(time.Hour, func() { selected = append(selected, "timeout") })
+		selector.AddFuture(NewTimer(ctx, time.Minute), func(Future) { selected = append(selected, "future") })
+
+		// the future wins, and the timer of the timeout is canceled
+		selector.Select(ctx)
+		s.Equal(time.Minute, Now(ctx).Sub(start))
+
+		// the future was already selected, and the timeout is restarted
+		selector.Select(ctx)
+		s.Equal(time.Minute+time.Hour, Now(ctx).Sub(start))
+
+		// a timeout which is not positive wins when no other case is ready
+		ch := NewBufferedChannel(ctx, 1)
+		ch.Send(ctx, "value")
+		NewSelectorWithDeadline(ctx).
+			AddTimeout(0, func() { selected = append(selected, "zero timeout") }).
+			AddReceive(ch, func(c Channel, more bool) {
+				c.Receive(ctx, nil)
+				selected = append(selected, "receive")
+			}).
+			Select(ctx)
+		NewSelectorWithDeadline(ctx).
+			AddTimeout(0, func() { selected = append(selected, "zero timeout") }).
+			Select(ctx)
+
+		// the default case wins over the timeouts, without starting their timers
+		withDefault := NewSelectorWithDeadline(ctx).
+			AddTimeout(time.Hour, func() { selected = append(selected, "timeout") })
+		withDefault.AddDefault(func() { selected = append(selected, "default") })
+		withDefault.Select(ctx)
+		s.Equal(time.Minute+time.Hour, Now(ctx).Sub(start))
+		return selected, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	var canceledTimers, firedTimers int
+	env.SetOnTimerCancelledListener(func(string) { canceledTimers++ })
+	env.SetOnTimerFiredListener(func(string) { firedTimers++ })
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var selected []string
+	s.NoError(env.GetWorkflowResult(&selected))
+	s.Equal([]string{"future", "timeout", "receive", "zero timeout", "default"}, selected)
+	s.Equal(1, canceledTimers)
+	s.Equal(2, firedTimers)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_SelectorWithDeadlineContextCanceled() {
+	workflowFn := func(ctx Context) (bool, error) {
+		ctx, cancel := WithCancel(ctx)
+		Go(ctx, func(ctx Context) {
+			_ = Sleep(ctx, time.Minute)
+			cancel()
+		})
+		timedOut := false
+		start := Now(ctx)
+		NewSelectorWithDeadline(ctx).
+			AddTimeout(time.Hour, func() { timedOut = true }).
+			Select(ctx)
+		s.Equal(time.Hour, Now(ctx).Sub(start), "the timeout does not fire when the context is canceled")
+		return timedOut, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var timedOut bool
+	s.NoError(env.GetWorkflowResult(&timedOut))
+	s.True(timedOut)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_Regression_ExecuteChildWorkflowWithCanceledContext() {
 	// cancelTime of:
 	// - <0 == do not cancel
@@ -3416,3 +4123,51 @@ func TestRegression_LocalActivityErrorEncoding(t *testing.T) {
 	assert.Contains(t, err.Error(), "sentinel error value", "should contain the user error text")
 	assert.NotContains(t, err.Error(), "need to be encoded", "should not contain the wrong-err-type branch message")
 }
+
+func (s *WorkflowTestSuiteUnitTest) Test_RegisterWithDataConverter() {
+	activityFn := func(ctx context.Context, msg string) (string, error) {
+		s.IsType(&testDataConverter{}, getDataConverterFromActivityCtx(ctx))
+		return "hello_" + msg, nil
+	}
+	workflowFn := func(ctx Context, msg string) (string, error) {
+		s.IsType(&testDataConverter{}, getDataConverterFromWorkflowContext(ctx))
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		var result string
+		err := ExecuteActivity(ctx, activityFn, msg).Get(ctx, &result)
+		return result, err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{DataConverter: newTestDataConverter()})
+	env.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{DataConverter: newTestDataConverter()})
+	env.ExecuteWorkflow(workflowFn, "world")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("hello_world", result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ExecuteWorkflowKeepsRegistrationOptions() {
+	workflowFn := func(ctx Context) error {
+		s.IsType(&testDataConverter{}, getDataConverterFromWorkflowContext(ctx))
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{
+		DataConverter: newTestDataConverter(),
+		Aliases:       []string{"workflow-alias"},
+	})
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	_, ok := env.impl.registry.getMatchedWorkflowTypeAlias("workflow-alias")
+	s.True(ok, "the aliases are kept")
+	s.Equal(getDefaultDataConverter(), env.impl.workerOptions.DataConverter, "the data converter is scoped to the workflow")
+}
@@ -22,6 +22,7 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -39,21 +40,25 @@ var globalRegistry *registry
 
 func newRegistry() *registry {
 	return &registry{
-		workflowFuncMap:  make(map[string]workflow),
-		workflowAliasMap: make(map[string]string),
-		activityFuncMap:  make(map[string]activity),
-		activityAliasMap: make(map[string]string),
-		next:             getGlobalRegistry(),
+		workflowFuncMap:      make(map[string]workflow),
+		workflowAliasMap:     make(map[string]string),
+		workflowTypeAliasMap: make(map[string]string),
+		activityFuncMap:      make(map[string]activity),
+		activityAliasMap:     make(map[string]string),
+		activityTypeAliasMap: make(map[string]string),
+		next:                 getGlobalRegistry(),
 	}
 }
 
 func getGlobalRegistry() *registry {
 	once.Do(func() {
 		globalRegistry = &registry{
-			workflowFuncMap:  make(map[string]workflow),
-			workflowAliasMap: make(map[string]string),
-			activityFuncMap:  make(map[string]activity),
-			activityAliasMap: make(map[string]string),
+			workflowFuncMap:      make(map[string]workflow),
+			workflowAliasMap:     make(map[string]string),
+			workflowTypeAliasMap: make(map[string]string),
+			activityFuncMap:      make(map[string]activity),
+			activityAliasMap:     make(map[string]string),
+			activityTypeAliasMap: make(map[string]string),
 		}
 	})
 	return globalRegistry
@@ -61,11 +66,17 @@ func getGlobalRegistry() *registry {
 
 type registry struct {
 	sync.Mutex
-	workflowFuncMap  map[string]workflow
-	workflowAliasMap map[string]string
-	activityFuncMap  map[string]activity
-	activityAliasMap map[string]string
-	next             *registry // Allows to chain registries
+	workflowFuncMap      map[string]workflow
+	workflowAliasMap     map[string]string
+	workflowTypeAliasMap map[string]string // RegisterWorkflowOptions.Aliases to the registered name
+	activityFuncMap      map[string]activity
+	activityAliasMap     map[string]string
+	activityTypeAliasMap map[string]string // RegisterActivityOptions.Aliases to the registered name
+	next                 *registry         // Allows to chain registries
+	// workerScope is set for the registries of workers, whose registrations must not conflict with the ones of the
+	// other workers of the process polling the same task list, see processRegistrations.
+	workerScope          *registrationScope
+	pendingRegistrations []workerRegistration // the registrations checked when the worker starts
 }
 
 func (r *registry) RegisterWorkflow(af interface{}) {
@@ -81,6 +92,9 @@ func (r *registry) RegisterWorkflowWithOptions(
 	if err := validateFnFormat(fnType, true); err != nil {
 		panic(err)
 	}
+	if err := validateRegisterWorkflowOptions(options); err != nil {
+		panic(err)
+	}
 	fnName := getFunctionName(wf)
 	alias := options.Name
 	registerName := fnName
@@ -95,15 +109,45 @@ func (r *registry) RegisterWorkflowWithOptions(
 	r.Lock()
 	defer r.Unlock()
 
+	for _, typeAlias := range options.Aliases {
+		if typeAlias == registerName {
+			panic(fmt.Sprintf("workflow alias \"%v\" is the same as the registered name", typeAlias))
+		}
+	}
 	if !options.DisableAlreadyRegisteredCheck {
-		if _, ok := r.getWorkflowNoLock(registerName); ok {
-			panic(fmt.Sprintf("workflow name \"%v\" is already registered", registerName))
+		for _, name := range append([]string{registerName}, options.Aliases...) {
+			if _, ok := r.getWorkflowNoLock(name); ok {
+				panic(fmt.Sprintf("workflow name \"%v\" is already registered", name))
+			}
+			if _, ok := r.getWorkflowTypeAliasNoLock(name); ok {
+				panic(fmt.Sprintf("workflow name \"%v\" is already registered as an alias", name))
+			}
+			if r.workerScope != nil {
+				r.addRegistration("workflow", name, fnName)
+			}
 		}
 	}
-	r.workflowFuncMap[registerName] = &workflowExecutor{registerName, wf, fnName}
+	r.workflowFuncMap[registerName] = &workflowExecutor{registerName, wf, fnName, options}
 	if len(alias) > 0 || options.EnableShortName {
 		r.workflowAliasMap[fnName] = registerName
 	}
+	for _, typeAlias := range options.Aliases {
+		r.workflowTypeAliasMap[typeAlias] = registerName
+	}
+}
+
+func validateRegisterWorkflowOptions(options RegisterWorkflowOptions) error {
+	if options.DefaultActivityOptions != nil {
+		if err := ValidateRetryPolicy(options.DefaultActivityOptions.RetryPolicy); err != nil {
+			return fmt.Errorf("default activity options: %v", err)
+		}
+	}
+	if options.DefaultLocalActivityOptions != nil {
+		if err := validateLocalActivityRetryPolicy(options.DefaultLocalActivityOptions.RetryPolicy); err != nil {
+			return fmt.Errorf("default local activity options: %v", err)
+		}
+	}
+	return nil
 }
 
 func (r *registry) RegisterActivity(af interface{}) {
@@ -158,20 +202,40 @@ func (r *registry) registerActivityFunction(af interface{}, options RegisterActi
 	r.Lock()
 	defer r.Unlock()
 
+	for _, typeAlias := range options.Aliases {
+		if typeAlias == registerName {
+			return fmt.Errorf("activity alias \"%v\" is the same as the registered name", typeAlias)
+		}
+	}
 	if !options.DisableAlreadyRegisteredCheck {
-		if _, ok := r.getActivityNoLock(registerName); ok {
-			return fmt.Errorf("activity type \"%v\" is already registered", registerName)
+		for _, name := range append([]string{registerName}, options.Aliases...) {
+			if _, ok := r.getActivityNoLock(name); ok {
+				return fmt.Errorf("activity type \"%v\" is already registered", name)
+			}
+			if _, ok := r.getActivityTypeAliasNoLock(name); ok {
+				return fmt.Errorf("activity type \"%v\" is already registered as an alias", name)
+			}
+			if r.workerScope != nil {
+				r.addRegistration("activity", name, fnName)
+			}
 		}
 	}
 	r.activityFuncMap[registerName] = &activityExecutor{registerName, af, options, fnName}
 	if len(alias) > 0 || options.EnableShortName {
 		r.activityAliasMap[fnName] = registerName
 	}
+	for _, typeAlias := range options.Aliases {
+		r.activityTypeAliasMap[typeAlias] = registerName
+	}
 
 	return nil
 }
 
 func (r *registry) registerActivityStruct(aStruct interface{}, options RegisterActivityOptions) error {
+	if len(options.Aliases) > 0 {
+		return errors.New("activity aliases are not supported when registering an activity structure")
+	}
+
 	r.Lock()
 	defer r.Unlock()
 
@@ -204,6 +268,9 @@ func (r *registry) registerActivityStruct(aStruct interface{}, options RegisterA
 			if _, ok := r.getActivityNoLock(registerName); ok {
 				return fmt.Errorf("activity type \"%v\" is already registered", registerName)
 			}
+			if r.workerScope != nil {
+				r.addRegistration("activity", registerName, methodName)
+			}
 		}
 		r.activityFuncMap[registerName] = &activityExecutor{registerName, methodValue.Interface(), options, methodName}
 		if len(structPrefix) > 0 || options.EnableShortName {
@@ -235,12 +302,26 @@ func (r *registry) getWorkflowAlias(fnName string) (string, bool) {
 	return alias, ok
 }
 
+// lookupWorkflowNoLock finds a workflow by its registered name, its name without the -fm suffix, or one of its
+// RegisterWorkflowOptions.Aliases, in this order.
+func (r *registry) lookupWorkflowNoLock(name string) (workflow, bool) {
+	if wf, ok := r.workflowFuncMap[name]; ok {
+		return wf, true
+	}
+	// if exact match is not found, check for backwards compatible name without -fm suffix
+	if wf, ok := r.workflowFuncMap[strings.TrimSuffix(name, "-fm")]; ok {
+		return wf, true
+	}
+	if registerName, ok := r.workflowTypeAliasMap[name]; ok {
+		wf, ok := r.workflowFuncMap[registerName]
+		return wf, ok
+	}
+	return nil, false
+}
+
 func (r *registry) getWorkflowFn(fnName string) (interface{}, bool) {
 	r.Lock() // do not defer for Unlock to call next.getWorkflowFn without lock
-	wf, ok := r.workflowFuncMap[fnName]
-	if !ok { // if exact match is not found, check for backwards compatible name without -fm suffix
-		wf, ok = r.workflowFuncMap[strings.TrimSuffix(fnName, "-fm")]
-	}
+	wf, ok := r.lookupWorkflowNoLock(fnName)
 	if !ok && r.next != nil {
 		r.Unlock()
 		return r.next.getWorkflowFn(fnName)
@@ -252,6 +333,29 @@ func (r *registry) getWorkflowFn(fnName string) (interface{}, bool) {
 	return nil, ok
 }
 
+func (r *registry) getWorkflowOptions(fnName string) RegisterWorkflowOptions {
+	r.Lock() // do not defer for Unlock to call next.getWorkflowOptions without lock
+	wf, ok := r.lookupWorkflowNoLock(fnName)
+	if !ok && r.next != nil {
+		r.Unlock()
+		return r.next.getWorkflowOptions(fnName)
+	}
+	r.Unlock()
+	if we, ok := wf.(*workflowExecutor); ok {
+		return we.options
+	}
+	return RegisterWorkflowOptions{}
+}
+
+// getWorkflowDataConverter returns RegisterWorkflowOptions.DataConverter of the workflow, or nil if it is not set.
+func (r *registry) getWorkflowDataConverter(wt WorkflowType) DataConverter {
+	lookup := getFunctionName(wt.Name)
+	if alias, ok := r.getWorkflowAlias(lookup); ok {
+		lookup = alias
+	}
+	return r.getWorkflowOptions(lookup).DataConverter
+}
+
 func (r *registry) getWorkflowNoLock(registerName string) (interface{}, bool) {
 	a, ok := r.workflowFuncMap[registerName]
 	if !ok && r.next != nil {
@@ -263,6 +367,30 @@ func (r *registry) getWorkflowNoLock(registerName string) (interface{}, bool) {
 	return nil, ok
 }
 
+func (r *registry) getWorkflowTypeAliasNoLock(typeAlias string) (string, bool) {
+	registerName, ok := r.workflowTypeAliasMap[typeAlias]
+	if !ok && r.next != nil {
+		return r.next.getWorkflowTypeAliasNoLock(typeAlias)
+	}
+	return registerName, ok
+}
+
+// getMatchedWorkflowTypeAlias returns the registered name of the workflow when workflowType is not registered
+// itself but is one of the RegisterWorkflowOptions.Aliases of a workflow.
+func (r *registry) getMatchedWorkflowTypeAlias(workflowType string) (string, bool) {
+	r.Lock() // do not defer for Unlock to call next.getMatchedWorkflowTypeAlias without lock
+	if _, ok := r.lookupWorkflowNoLock(workflowType); ok {
+		registerName, isAlias := r.workflowTypeAliasMap[workflowType]
+		r.Unlock()
+		return registerName, isAlias
+	}
+	r.Unlock()
+	if r.next != nil {
+		return r.next.getMatchedWorkflowTypeAlias(workflowType)
+	}
+	return "", false
+}
+
 func (r *registry) GetRegisteredWorkflowTypes() []string {
 	r.Lock() // do not defer for Unlock to call next.getRegisteredWorkflowTypes without lock
 	var result []string
@@ -301,6 +429,11 @@ func (r *registry) GetActivity(fnName string) (activity, bool) {
 	if !ok { // if exact match is not found, check for backwards compatible name without -fm suffix
 		a, ok = r.activityFuncMap[strings.TrimSuffix(fnName, "-fm")]
 	}
+	if !ok {
+		if registerName, isAlias := r.activityTypeAliasMap[fnName]; isAlias {
+			a, ok = r.activityFuncMap[registerName]
+		}
+	}
 	if !ok && r.next != nil {
 		r.Unlock()
 		return r.next.GetActivity(fnName)
@@ -317,6 +450,14 @@ func (r *registry) getActivityNoLock(registerName string) (activity, bool) {
 	return a, ok
 }
 
+func (r *registry) getActivityTypeAliasNoLock(typeAlias string) (string, bool) {
+	registerName, ok := r.activityTypeAliasMap[typeAlias]
+	if !ok && r.next != nil {
+		return r.next.getActivityTypeAliasNoLock(typeAlias)
+	}
+	return registerName, ok
+}
+
 func (r *registry) getRegisteredActivities() []activity {
 	r.Lock() // do not defer for Unlock to call next.getRegisteredActivities without lock
 	activities := make([]activity, 0, len(r.activityFuncMap))
@@ -341,6 +482,6 @@ func (r *registry) getWorkflowDefinition(wt WorkflowType) (workflowDefinition, e
 		supported := strings.Join(r.GetRegisteredWorkflowTypes(), ", ")
 		return nil, fmt.Errorf(errMsgUnknownWorkflowType+": %v. Supported types: [%v]", lookup, supported)
 	}
-	wd := &workflowExecutor{workflowType: lookup, fn: wf}
+	wd := &workflowExecutor{workflowType: lookup, fn: wf, options: r.getWorkflowOptions(lookup)}
 	return newSyncWorkflowDefinition(wd), nil
 }
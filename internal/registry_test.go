@@ -22,6 +22,7 @@ package internal
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -84,6 +85,79 @@ func TestWorkflowRegistration(t *testing.T) {
 			workflowType:      "go.uber.org/cadence/internal.testWorkflowFunction",
 			resolveByFunction: testWorkflowFunction,
 		},
+		{
+			msg: "register workflow with invalid default retry policy (should panic)",
+			register: func(r *registry) {
+				r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+					DefaultActivityOptions: &ActivityOptions{RetryPolicy: &RetryPolicy{InitialInterval: time.Second}},
+				})
+			},
+			registerPanic: true,
+		},
+		{
+			msg: "register workflow with default local retry policy which never retries",
+			register: func(r *registry) {
+				r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+					DefaultLocalActivityOptions: &LocalActivityOptions{RetryPolicy: &RetryPolicy{InitialInterval: time.Second}},
+				})
+			},
+			workflowType:      "go.uber.org/cadence/internal.testWorkflowFunction",
+			resolveByFunction: testWorkflowFunction,
+		},
+		{
+			msg: "register workflow with invalid default local retry policy (should panic)",
+			register: func(r *registry) {
+				r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+					DefaultLocalActivityOptions: &LocalActivityOptions{RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: -1}},
+				})
+			},
+			registerPanic: true,
+		},
+		{
+			msg: "register workflow function with type aliases",
+			register: func(r *registry) {
+				r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+					Name:    "workflow.v2",
+					Aliases: []string{"workflow.v1"},
+				})
+			},
+			workflowType:      "workflow.v2",
+			altWorkflowType:   "workflow.v1",
+			resolveByFunction: testWorkflowFunction,
+			resolveByAlias:    "workflow.v2",
+		},
+		{
+			msg: "register workflow with type alias equal to its name (should panic)",
+			register: func(r *registry) {
+				r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+					Name:    "workflow.v2",
+					Aliases: []string{"workflow.v2"},
+				})
+			},
+			registerPanic: true,
+		},
+		{
+			msg: "register workflow with type alias of another workflow (should panic)",
+			register: func(r *registry) {
+				r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{Name: "workflow.v1"})
+				r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+					Name:    "workflow.v2",
+					Aliases: []string{"workflow.v1"},
+				})
+			},
+			registerPanic: true,
+		},
+		{
+			msg: "register workflow named like a type alias in chained registry (should panic)",
+			register: func(r *registry) {
+				r.next.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+					Name:    "chained.workflow.v2",
+					Aliases: []string{"chained.workflow.v1"},
+				})
+				r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{Name: "chained.workflow.v1"})
+			},
+			registerPanic: true,
+		},
 		{
 			msg: "register duplicated workflow in chained registry (should panic)",
 			register: func(r *registry) {
@@ -131,6 +205,42 @@ func TestWorkflowRegistration(t *testing.T) {
 	}
 }
 
+func TestWorkflowTypeAliases(t *testing.T) {
+	r := newRegistry()
+	r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+		Name:    "workflow.v3",
+		Aliases: []string{"workflow.v1", "workflow.v2"},
+	})
+
+	registerName, ok := r.getMatchedWorkflowTypeAlias("workflow.v1")
+	require.True(t, ok)
+	require.Equal(t, "workflow.v3", registerName)
+	_, ok = r.getMatchedWorkflowTypeAlias("workflow.v3")
+	require.False(t, ok, "the registered name is not an alias")
+	_, ok = r.getMatchedWorkflowTypeAlias("unknown")
+	require.False(t, ok)
+
+	_, err := r.getWorkflowDefinition(WorkflowType{Name: "workflow.v2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"workflow.v1", "workflow.v2"}, r.getWorkflowOptions("workflow.v2").Aliases)
+}
+
+func TestWorkflowDataConverter(t *testing.T) {
+	r := newRegistry()
+	dc := newTestDataConverter()
+	r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+		Name:          "workflow.v2",
+		Aliases:       []string{"workflow.v1"},
+		DataConverter: dc,
+	})
+	r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{Name: "workflow.json"})
+
+	require.Equal(t, dc, r.getWorkflowDataConverter(WorkflowType{Name: "workflow.v2"}))
+	require.Equal(t, dc, r.getWorkflowDataConverter(WorkflowType{Name: "workflow.v1"}))
+	require.Nil(t, r.getWorkflowDataConverter(WorkflowType{Name: "workflow.json"}), "the worker data converter is used")
+	require.Nil(t, r.getWorkflowDataConverter(WorkflowType{Name: "unknown"}))
+}
+
 func TestActivityRegistration(t *testing.T) {
 	tests := []struct {
 		msg               string
@@ -164,6 +274,47 @@ func TestActivityRegistration(t *testing.T) {
 			resolveByFunction: testActivityFunction,
 			resolveByAlias:    "activity.alias",
 		},
+		{
+			msg: "register activity function with aliases",
+			register: func(r *registry) {
+				r.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{
+					Name:    "activity.v2",
+					Aliases: []string{"activity.v1"},
+				})
+			},
+			activityType:      "activity.v2",
+			altActivityType:   "activity.v1",
+			resolveByFunction: testActivityFunction,
+			resolveByAlias:    "activity.v2",
+		},
+		{
+			msg: "register activity function with an alias equal to its name (should panic)",
+			register: func(r *registry) {
+				r.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{
+					Name:    "activity.v2",
+					Aliases: []string{"activity.v2"},
+				})
+			},
+			registerPanic: true,
+		},
+		{
+			msg: "register activity function with an alias of another activity (should panic)",
+			register: func(r *registry) {
+				r.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{Name: "activity.v1"})
+				r.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{
+					Name:    "activity.v2",
+					Aliases: []string{"activity.v1"},
+				})
+			},
+			registerPanic: true,
+		},
+		{
+			msg: "register activity struct with aliases (should panic)",
+			register: func(r *registry) {
+				r.RegisterActivityWithOptions(&testActivityStruct{}, RegisterActivityOptions{Aliases: []string{"activity.v1"}})
+			},
+			registerPanic: true,
+		},
 		{
 			msg:               "register activity struct",
 			register:          func(r *registry) { r.RegisterActivity(&testActivityStruct{}) },
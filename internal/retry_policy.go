@@ -0,0 +1,59 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import "fmt"
+
+// ValidateRetryPolicy checks the retry policy for invalid values and for combinations of values that
+// would make the policy behave differently than intended, e.g. an expiration interval shorter than
+// the first backoff. A nil policy is valid and means no retry.
+func ValidateRetryPolicy(p *RetryPolicy) error {
+	if p == nil {
+		return nil
+	}
+
+	if p.InitialInterval <= 0 {
+		return fmt.Errorf("invalid retry policy: InitialInterval must be positive, got %v", p.InitialInterval)
+	}
+	if p.BackoffCoefficient != 0 && p.BackoffCoefficient < 1 {
+		return fmt.Errorf("invalid retry policy: BackoffCoefficient %v is less than 1.0, backoff intervals would shrink on every attempt", p.BackoffCoefficient)
+	}
+	if p.MaximumInterval < 0 {
+		return fmt.Errorf("invalid retry policy: MaximumInterval must not be negative, got %v", p.MaximumInterval)
+	}
+	if p.MaximumInterval > 0 && p.MaximumInterval < p.InitialInterval {
+		return fmt.Errorf("invalid retry policy: MaximumInterval %v is less than InitialInterval %v", p.MaximumInterval, p.InitialInterval)
+	}
+	if p.MaximumAttempts < 0 {
+		return fmt.Errorf("invalid retry policy: MaximumAttempts must not be negative, got %v", p.MaximumAttempts)
+	}
+	if p.ExpirationInterval < 0 {
+		return fmt.Errorf("invalid retry policy: ExpirationInterval must not be negative, got %v", p.ExpirationInterval)
+	}
+	if p.MaximumAttempts == 0 && p.ExpirationInterval == 0 {
+		return fmt.Errorf("invalid retry policy: neither MaximumAttempts nor ExpirationInterval is set, at least one of them is required to stop retrying")
+	}
+	if p.ExpirationInterval > 0 && p.ExpirationInterval < p.InitialInterval {
+		return fmt.Errorf("invalid retry policy: ExpirationInterval %v is less than InitialInterval %v, no retry would ever be scheduled", p.ExpirationInterval, p.InitialInterval)
+	}
+
+	return nil
+}
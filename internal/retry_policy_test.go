@@ -0,0 +1,86 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRetryPolicy(t *testing.T) {
+	tests := []struct {
+		msg       string
+		policy    *RetryPolicy
+		expectErr string
+	}{
+		{
+			msg: "nil policy",
+		},
+		{
+			msg:    "valid policy",
+			policy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3},
+		},
+		{
+			msg:       "missing initial interval",
+			policy:    &RetryPolicy{MaximumAttempts: 3},
+			expectErr: "InitialInterval must be positive",
+		},
+		{
+			msg:       "coefficient less than 1",
+			policy:    &RetryPolicy{InitialInterval: time.Second, BackoffCoefficient: 0.5, MaximumAttempts: 3},
+			expectErr: "BackoffCoefficient 0.5 is less than 1.0",
+		},
+		{
+			msg:       "maximum interval less than initial interval",
+			policy:    &RetryPolicy{InitialInterval: time.Minute, MaximumInterval: time.Second, MaximumAttempts: 3},
+			expectErr: "MaximumInterval 1s is less than InitialInterval 1m0s",
+		},
+		{
+			msg:       "negative maximum attempts",
+			policy:    &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: -1},
+			expectErr: "MaximumAttempts must not be negative",
+		},
+		{
+			msg:       "no stop condition",
+			policy:    &RetryPolicy{InitialInterval: time.Second},
+			expectErr: "neither MaximumAttempts nor ExpirationInterval is set",
+		},
+		{
+			msg:       "expiration less than initial interval",
+			policy:    &RetryPolicy{InitialInterval: time.Minute, ExpirationInterval: time.Second},
+			expectErr: "ExpirationInterval 1s is less than InitialInterval 1m0s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			err := ValidateRetryPolicy(tt.policy)
+			if tt.expectErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectErr)
+		})
+	}
+}
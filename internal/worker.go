@@ -35,6 +35,7 @@ import (
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common/auth"
+	"go.uber.org/cadence/internal/worker"
 )
 
 type (
@@ -159,6 +160,20 @@ type (
 		// default: defaultMaxConcurrentActivityExecutionSize(1k)
 		MaxConcurrentActivityExecutionSize int
 
+		// Optional: pool of extra activity execution slots shared with the other workers of the process which use it.
+		// When MaxConcurrentActivityExecutionSize activities are executing, the activity worker keeps polling while the
+		// pool has a free slot, and borrows the slot once a task is polled, so that the workers of backlogged task lists
+		// share the extra capacity. The own slots of a worker are not lent to the others, and empty polls hold no slot
+		// of the pool. Only the execution slots are shared, the pollers of each worker are unchanged.
+		// default: nil, no slot is shared
+		ActivitySlotPool *SlotPool
+
+		// Optional: schedules the activity executions fairly between the tenants of the task list, so that a burst of
+		// tasks of one tenant does not take all the execution slots. See ActivityFairnessOptions.
+		// It cannot be used with ActivitySlotPool.
+		// default: nil, the tasks are executed in the order they are polled
+		ActivityFairness *ActivityFairnessOptions
+
 		// Optional: Sets the rate limiting on number of activities that can be executed per second per
 		// worker. This can be used to limit resources used by the worker.
 		// Notice that the number is represented in float, so that you can set it to less than
@@ -196,6 +211,11 @@ type (
 		// NOTE: if AutoScalerOptions.Enabled is set to true, this value will be ignored and AutoScalerOptions.PollerMaxCount will be used instead
 		MaxConcurrentActivityTaskPollers int
 
+		// Optional: Pauses activity polling while the worker process is short on memory or CPU, and resumes it
+		// once the usage is back to normal. It applies to activity and session workers.
+		// default: disabled, see AdmissionControlOptions for details
+		ActivityAdmissionControl AdmissionControlOptions
+
 		// optional: Sets the minimum number of goroutines that will concurrently poll the
 		// cadence-server to retrieve activity tasks. Changing this value will NOT affect the
 		// rate at which the worker is able to consume tasks from a task list,
@@ -285,6 +305,11 @@ type (
 		// default: no metrics.
 		MetricsScope tally.Scope
 
+		// Optional: Prefix, tags and sanitizer applied to MetricsScope for this worker, so the scope does not
+		// need to be wrapped before it is passed in. Use the same options as the client to keep names consistent.
+		// default: metrics are emitted to MetricsScope unchanged.
+		MetricsOptions MetricsOptions
+
 		// Optional: Logger framework can use to log.
 		// default: default logger provided.
 		Logger *zap.Logger
@@ -296,6 +321,78 @@ type (
 		// default: false
 		EnableLoggingInReplay bool
 
+		// Optional: Number of the latest entries of the workflow logger kept per cached workflow execution. They are
+		// returned by the QueryTypeRecentLogs query, e.g. to see what a stuck execution logged without searching
+		// centralized logs. Entries skipped during replay are not kept, and entries are dropped when the execution
+		// is evicted from the sticky cache or replayed from the start, e.g. after a failed decision task.
+		// default: 0, no entries are kept
+		WorkflowLogBufferSize int
+
+		// Optional: Make decision tasks fail with a panic error when workflow code starts native goroutines, or
+		// blocks outside of the workflow dispatcher, e.g. in time.Sleep or on a native channel, instead of using
+		// workflow.Go, workflow.Sleep and workflow.Channel. The goroutines of the workflow are inspected at run time,
+		// which catches violations static checks miss, but costs CPU on every decision task, so enable it in unit tests
+		// (see TestWorkflowEnvironment.SetWorkerOptions) and on canary workers. Violations are only caught while they
+		// happen, e.g. a blocking call shorter than 100ms may be missed, and calls like time.Now or math/rand which do
+		// not block cannot be detected at all: use workflow.Now and workflow.SideEffect for those.
+		// default: false
+		EnableDeterminismGuard bool
+
+		// Optional: Pass the task list and the parent of workflows to the activities they schedule, in
+		// activity.Info.WorkflowTaskList, ParentWorkflowDomain and ParentWorkflowExecution. They are sent in
+		// cadence-* headers of the scheduled activities, which are not visible to the context propagators, and the
+		// activity workers must run a version of the client reading them.
+		// default: false, the activity info does not contain them
+		EnableActivityCallerInfo bool
+
+		// Optional: Report the decoded arguments of panicking workflows, as JSON, in the logged panic and in the
+		// details of the failed decision task, to debug the panics depending on the workflow input. The arguments
+		// may contain sensitive data, see WorkflowPanicArgsOptions.Redactor.
+		// default: nil, the arguments are not reported
+		WorkflowPanicArgs *WorkflowPanicArgsOptions
+
+		// Optional: Log the stacks of the goroutine processing a decision task and of the coroutines of its workflow,
+		// with the workflow identifiers, when the decision task is still being processed close to its decision
+		// timeout, so that decision task timeouts can be investigated, e.g. workflow code blocked on a lock or
+		// spinning in a loop. The goroutines of the workflows are labelled with pprof labels to find them.
+		// default: nil, no stacks are dumped
+		DecisionTaskWatchdog *DecisionTaskWatchdogOptions
+
+		// Optional: Record the SDK version and the Go version of the worker in a marker when it starts a workflow.
+		// Workers replaying the history of such a workflow log a warning and increment the
+		// cadence-sdk-version-mismatch counter when the marker was recorded by another major or minor SDK version or
+		// another Go release, to find the workflows to watch when upgrading a fleet. The marker is ignored by the
+		// replay checks of the SDK versions which know it, but older SDK versions fail the decision tasks of the
+		// workflows with an "unknown marker name" error. Only enable it once no worker of the domain runs an older SDK
+		// version and no rollback to one is planned.
+		// default: false
+		RecordSDKVersionMarker bool
+
+		// Optional: Names of the search attributes the workflows of the worker upsert. worker.Validator checks they are
+		// registered in the cluster, as upserting an unknown search attribute fails the decision task.
+		// default: nil, no search attributes are checked
+		UpsertedSearchAttributes []string
+
+		// Optional: Size limit of the serialized details of activity heartbeats. Larger details are not sent to the
+		// server, which would fail the activity, and activity.RecordHeartbeatWithError returns an error matching
+		// activity.ErrHeartbeatDetailsTooLarge instead. Every such heartbeat, including the ones of activity.RecordHeartbeat
+		// which returns no error, is logged at warn level and counted by the
+		// cadence-activity-heartbeat-details-too-large metric.
+		// Set it to the blob size limit of the cluster if it was changed.
+		// default: 2MB, the default blob size limit of the server
+		MaxHeartbeatDetailsSize int
+
+		// Optional: Record heartbeats with details larger than MaxHeartbeatDetailsSize without their details, so the
+		// activity keeps heartbeating. The next attempt of the activity gets activity.ErrHeartbeatDetailsTruncated from
+		// activity.GetHeartbeatDetails.
+		// default: false, heartbeats with too large details are not recorded
+		TruncateLargeHeartbeatDetails bool
+
+		// Optional: Log sampled requests and responses of the service calls of the worker, and of its activity
+		// client, for debugging. See NewPayloadLogger.
+		// default: nil, nothing is logged
+		PayloadLogger *PayloadLogger
+
 		// Optional: Disable running workflow workers.
 		// default: false
 		DisableWorkflowWorker bool
@@ -314,20 +411,84 @@ type (
 		// is available for any worker to pick up and resume the progress.
 		DisableStickyExecution bool
 
+		// Optional: Enable the detection of the capabilities of the server when the worker starts.
+		// default: false, every feature is assumed to be supported
+		// When the worker starts, it gets the highest Go client feature version supported by the server, with an RPC
+		// of up to 1s, and disables the features the server does not support, e.g. sticky execution, with a warning.
+		// The detected capabilities are returned by worker.CapabilitiesProvider.
+		EnableCapabilitiesNegotiation bool
+
+		// Optional: Log a warning for every registered workflow or activity taking more than one argument besides the
+		// context, whose positional arguments cannot be added, removed or reordered without breaking running workflows
+		// and in-flight activities, see the x/positionalargs analyzer.
+		// default: false
+		EnablePositionalArgsWarning bool
+
 		// Optional: Sticky schedule to start timeout.
 		// default: 5s
 		// The resolution is seconds. See details about StickyExecution on the comments for DisableStickyExecution.
 		StickyScheduleToStartTimeout time.Duration
 
+		// Optional: Number of history pages fetched ahead while the current page is being replayed, which cuts
+		// decision latency for workflows whose history spans several pages, e.g. when sticky execution is disabled
+		// or the workflow was evicted from the cache. Pages have to be fetched in order, so they are prefetched
+		// one after another rather than in parallel.
+		// default: 0, each page is fetched when the replay reaches it.
+		HistoryPrefetchPages int
+
 		// Optional: sets context for activity. The context can be used to pass any configuration to activity
 		// like common logger for all activities.
 		BackgroundActivityContext context.Context
 
+		// Optional: client returned by activity.GetClient, e.g. one created with different client options.
+		// default: a client of the worker's domain, using the service, identity, data converter, context propagators,
+		// tracer and authorization of the worker.
+		ActivityClient Client
+
 		// Optional: Sets how decision worker deals with non-deterministic history events
 		// (presumably arising from non-deterministic workflow definitions or non-backward compatible workflow definition changes).
 		// default: NonDeterministicWorkflowPolicyBlockWorkflow, which just logs error but reply nothing back to server
 		NonDeterministicWorkflowPolicy NonDeterministicWorkflowPolicy
 
+		// Optional: Sets how decision worker resets workflows whose decision tasks keep failing, e.g. because of
+		// a poison history. The reset is requested through the ResetWorkflowExecution API.
+		// default: disabled, see AutoResetOptions for details
+		AutoResetOptions AutoResetOptions
+
+		// Optional: Restricts the workflows this worker processes decision tasks for, by workflow type or ID.
+		// The filter can be updated while the worker is running.
+		// default: nil, all workflows are processed, see WorkflowFilter for details
+		WorkflowFilter *WorkflowFilter
+
+		// Optional: Stops processing decision tasks of a workflow type for a cooldown after they fail repeatedly,
+		// e.g. because its workflow code panics. The breaker can be reset while the worker is running.
+		// default: nil, decision tasks are always processed, see DecisionCircuitBreaker for details
+		DecisionCircuitBreaker *DecisionCircuitBreaker
+
+		// Optional: Flags the executions whose history grows faster than a rate, e.g. because of a runaway loop in
+		// the workflow code, before they reach the history limits of the server. The flagged executions can be
+		// listed while the worker is running.
+		// default: nil, the growth of the histories is not checked, see HistoryGrowthDetector for details
+		HistoryGrowthDetector *HistoryGrowthDetector
+
+		// Optional: Changes settings of the worker while it is running: the activity rate limits, the allow and deny
+		// lists of its WorkflowFilter, created if not set, and its log level. The changes are audit logged.
+		// default: nil, the settings can't be changed, see WorkerAdmin for details
+		WorkerAdmin *WorkerAdmin
+
+		// Optional: Called when a decision task is still being processed after 70% of its decision timeout, e.g. while
+		// replaying a huge history. It is called on a separate goroutine and must not block. Decision tasks waiting on
+		// local activities are additionally kept alive by heartbeating the decision task at 80% of the timeout, it is
+		// called once per task, not after each heartbeat.
+		// default: nil, a warning is logged and the decision-task-near-timeout counter is emitted.
+		OnDecisionTaskNearTimeout func(DecisionTaskNearTimeoutInfo)
+
+		// Optional: Called when the server rejects a service call of the worker, e.g. a poll, with ServiceBusy or
+		// LimitExceeded, so that the application can shed or queue load. It is called on the goroutine of the call and
+		// must not block. The worker retries the calls after at least ThrottleInfo.RetryAfter.
+		// default: nil
+		OnThrottle func(ThrottleInfo)
+
 		// Optional: Sets DataConverter to customize serialization/deserialization of arguments in Cadence
 		// default: defaultDataConverter, an combination of thriftEncoder and jsonEncoder
 		DataConverter DataConverter
@@ -417,6 +578,14 @@ type (
 		//
 		// Deprecated: All bugports are always deprecated and may be removed at any time
 		DisableStrictNonDeterminismCheck bool
+
+		// Optional: Schedule the next attempt of a local activity when its retry timer fires after the
+		// ExpirationInterval of its retry policy, as workers did before local activity retries expired with their
+		// timer. Set it to replay the histories which recorded such an attempt until their workflows are closed.
+		// Default: false, which means the local activity fails with the error of its last attempt.
+		//
+		// Deprecated: All bugports are always deprecated and may be removed at any time
+		RetryLocalActivitiesAfterExpiration bool
 	}
 )
 
@@ -439,6 +608,14 @@ const (
 	NonDeterministicWorkflowPolicyFailWorkflow
 )
 
+// SlotPool is a pool of task execution slots shared by the workers of a process, see WorkerOptions.ActivitySlotPool.
+type SlotPool = worker.SlotPool
+
+// NewSlotPool creates a pool of size execution slots, see WorkerOptions.ActivitySlotPool.
+func NewSlotPool(size int) *SlotPool {
+	return worker.NewSlotPool(size)
+}
+
 // NewWorker creates an instance of worker for managing workflow and activity executions.
 // service 	- thrift connection to the cadence server.
 // domain - the name of the cadence domain.
@@ -509,5 +686,11 @@ func (o WorkerOptions) Validate() error {
 	if !o.DisableStickyExecution && (o.MaxConcurrentDecisionTaskPollers == 1) {
 		return fmt.Errorf("DecisionTaskPollers must be >= 2 or use default value")
 	}
-	return nil
+	if o.ActivityFairness != nil && o.ActivitySlotPool != nil {
+		return fmt.Errorf("ActivityFairness cannot be used with ActivitySlotPool")
+	}
+	if err := validateActivityFairnessOptions(o.ActivityFairness); err != nil {
+		return err
+	}
+	return validateDecisionTaskWatchdogOptions(o.DecisionTaskWatchdog)
 }
@@ -0,0 +1,235 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/marusama/semaphore/v2"
+)
+
+var _ BorrowingPermit = (*sharedPermit)(nil)
+
+// BorrowingPermit is a Permit which lets a poll start when a slot may be borrowed from a shared pool, without holding
+// the slot during the poll. Borrow must be called once the poll returned a task, before executing it.
+type BorrowingPermit interface {
+	Permit
+	// Borrow turns the permit acquired for a poll into an execution slot. It is a no-op for a permit of the own quota,
+	// and blocks until a slot is available if the pool was exhausted while polling.
+	Borrow(context.Context) error
+}
+
+// SlotPool is a pool of task execution slots shared by the workers of a process, on top of their own slots. A worker
+// using the pool borrows a slot from it when all of its own slots are executing tasks, so that the backlogged workers
+// share the extra capacity. The own slots of a worker are never lent to the others. It is safe for concurrent use.
+type SlotPool struct {
+	mu       sync.Mutex
+	size     int
+	inUse    int
+	released chan struct{} // closed and replaced when a slot is released
+}
+
+// NewSlotPool creates a pool of size slots.
+func NewSlotPool(size int) *SlotPool {
+	return &SlotPool{size: size, released: make(chan struct{})}
+}
+
+// Size returns the number of slots of the pool.
+func (p *SlotPool) Size() int {
+	return p.size
+}
+
+// InUse returns the number of slots currently borrowed from the pool.
+func (p *SlotPool) InUse() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inUse
+}
+
+func (p *SlotPool) available() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inUse < p.size
+}
+
+func (p *SlotPool) tryAcquire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inUse >= p.size {
+		return false
+	}
+	p.inUse++
+	return true
+}
+
+func (p *SlotPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inUse--
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+// releasedChan returns a channel closed when a slot is released after this call
+func (p *SlotPool) releasedChan() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.released
+}
+
+// sharedPermit issues the permits of its own quota first. When they are all in use, it issues pending permits while
+// the pool has a free slot, which only borrow the slot once Borrow is called, so that empty polls hold no pool slot.
+type sharedPermit struct {
+	own  semaphore.Semaphore
+	pool *SlotPool
+
+	mu       sync.Mutex
+	pending  int // permits acquired for a poll, without an own permit or a pool slot
+	borrowed int
+	released chan struct{} // closed and replaced when an own permit is released or the quota is increased
+}
+
+// NewSharedPermit creates a permit of initCount permits, which borrows slots from pool when they are all in use.
+func NewSharedPermit(initCount int, pool *SlotPool) BorrowingPermit {
+	return &sharedPermit{own: semaphore.New(initCount), pool: pool, released: make(chan struct{})}
+}
+
+// Acquire is blocking until a permit is acquired or returns error after context is done
+// Remember to call Release() to release the permit after usage
+func (p *sharedPermit) Acquire(ctx context.Context) error {
+	for {
+		// get the notification channels first, so that a release happening after a failed attempt is not missed
+		ownReleased, poolReleased := p.releasedChan(), p.pool.releasedChan()
+		if p.own.TryAcquire(1) {
+			return nil
+		}
+		if p.pool.available() {
+			p.mu.Lock()
+			p.pending++
+			p.mu.Unlock()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to acquire permit before context is done: %w", ctx.Err())
+		case <-ownReleased:
+		case <-poolReleased:
+		}
+	}
+}
+
+// Borrow turns a pending permit into an own permit or a slot of the pool, waiting for one if needed
+func (p *sharedPermit) Borrow(ctx context.Context) error {
+	for {
+		ownReleased, poolReleased := p.releasedChan(), p.pool.releasedChan()
+		p.mu.Lock()
+		if p.pending == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		if p.own.TryAcquire(1) {
+			p.pending--
+			p.mu.Unlock()
+			return nil
+		}
+		if p.pool.tryAcquire() {
+			p.pending--
+			p.borrowed++
+			p.mu.Unlock()
+			return nil
+		}
+		p.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to borrow slot before context is done: %w", ctx.Err())
+		case <-ownReleased:
+		case <-poolReleased:
+		}
+	}
+}
+
+// AcquireChan returns a channel that could be used to wait for the permit and a close function when done
+func (p *sharedPermit) AcquireChan(ctx context.Context) (<-chan struct{}, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	pc := &permitChannel{
+		p:      p,
+		c:      make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+		wg:     &sync.WaitGroup{},
+	}
+	pc.Start()
+	return pc.C(), func() {
+		pc.Close()
+	}
+}
+
+// Release releases one permit, returning the borrowed slots to the pool first, then the pending permits
+func (p *sharedPermit) Release() {
+	p.mu.Lock()
+	if p.borrowed > 0 {
+		p.borrowed--
+		p.mu.Unlock()
+		p.pool.release()
+		return
+	}
+	if p.pending > 0 {
+		p.pending--
+		p.mu.Unlock()
+		return
+	}
+	p.own.Release(1)
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// Quota returns the maximum number of own permits, not counting the slots which can be borrowed
+func (p *sharedPermit) Quota() int {
+	return p.own.GetLimit()
+}
+
+// SetQuota sets the maximum number of own permits
+func (p *sharedPermit) SetQuota(c int) {
+	p.own.SetLimit(c)
+	p.mu.Lock()
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// Count returns the number of permits in use, including the pending permits and the borrowed slots
+func (p *sharedPermit) Count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.own.GetCount() + p.pending + p.borrowed
+}
+
+func (p *sharedPermit) releasedChan() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.released
+}
+
+func (p *sharedPermit) notifyLocked() {
+	close(p.released)
+	p.released = make(chan struct{})
+}
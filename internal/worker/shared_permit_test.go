@@ -0,0 +1,158 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedPermit_BorrowsFromPool(t *testing.T) {
+	pool := NewSlotPool(2)
+	idle := NewSharedPermit(1, pool)
+	busy := NewSharedPermit(1, pool)
+	ctx := context.Background()
+
+	// polls beyond the own quota hold no pool slot until they return a task
+	require.NoError(t, busy.Acquire(ctx))
+	require.NoError(t, busy.Acquire(ctx))
+	require.NoError(t, busy.Acquire(ctx))
+	assert.Equal(t, 3, busy.Count())
+	assert.Equal(t, 1, busy.Quota())
+	assert.Equal(t, 0, pool.InUse())
+
+	require.NoError(t, busy.Borrow(ctx))
+	require.NoError(t, busy.Borrow(ctx))
+	assert.Equal(t, 2, pool.InUse())
+	assert.NoError(t, busy.Borrow(ctx), "no pending permit left")
+
+	// the pool is exhausted, the idle worker still has its own permit
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, busy.Acquire(timeoutCtx))
+	require.NoError(t, idle.Acquire(ctx))
+	assert.Equal(t, 1, idle.Count())
+
+	// borrowed slots are returned first
+	busy.Release()
+	assert.Equal(t, 1, pool.InUse())
+	assert.Equal(t, 2, busy.Count())
+	busy.Release()
+	busy.Release()
+	assert.Equal(t, 0, pool.InUse())
+	assert.Equal(t, 0, busy.Count())
+}
+
+func TestSharedPermit_EmptyPollHoldsNoSlot(t *testing.T) {
+	pool := NewSlotPool(1)
+	idle := NewSharedPermit(0, pool)
+	busy := NewSharedPermit(0, pool)
+	ctx := context.Background()
+
+	// the idle worker polls without taking the slot, which stays available to the busy worker
+	require.NoError(t, idle.Acquire(ctx))
+	require.NoError(t, busy.Acquire(ctx))
+	require.NoError(t, busy.Borrow(ctx))
+	assert.Equal(t, 1, pool.InUse())
+
+	// the idle worker gets a task once the slot is released
+	borrowed := make(chan error)
+	go func() {
+		borrowed <- idle.Borrow(ctx)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	busy.Release()
+	select {
+	case err := <-borrowed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("slot not borrowed after it was released")
+	}
+	assert.Equal(t, 1, pool.InUse())
+
+	// an empty poll returns the pending permit without touching the pool
+	idle.Release()
+	require.NoError(t, busy.Acquire(ctx))
+	assert.Equal(t, 0, pool.InUse())
+	busy.Release()
+	assert.Equal(t, 0, pool.InUse())
+	assert.Equal(t, 0, busy.Count())
+}
+
+func TestSharedPermit_AcquireWaitsForRelease(t *testing.T) {
+	pool := NewSlotPool(1)
+	first := NewSharedPermit(0, pool)
+	second := NewSharedPermit(1, pool)
+	ctx := context.Background()
+
+	require.NoError(t, first.Acquire(ctx))
+	require.NoError(t, first.Borrow(ctx))
+	require.NoError(t, second.Acquire(ctx))
+
+	// a slot released to the pool by another worker unblocks the acquisition
+	acquired := make(chan error)
+	go func() {
+		acquired <- second.Acquire(ctx)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	first.Release()
+	select {
+	case err := <-acquired:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("permit not acquired after the pool slot was released")
+	}
+	assert.Equal(t, 0, pool.InUse(), "the permit is pending until a task is polled")
+
+	// and so does an own permit
+	require.NoError(t, second.Borrow(ctx))
+	permitChannel, done := second.AcquireChan(ctx)
+	defer done()
+	second.Release()
+	select {
+	case <-permitChannel:
+	case <-time.After(time.Second):
+		t.Fatal("permit not acquired after the own permit was released")
+	}
+	assert.Equal(t, 2, second.Count())
+}
+
+func TestSharedPermit_SetQuota(t *testing.T) {
+	pool := NewSlotPool(0)
+	permit := NewSharedPermit(0, pool)
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- permit.Acquire(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+	permit.SetQuota(1)
+	select {
+	case err := <-acquired:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("permit not acquired after the quota was increased")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/uber-go/tally"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	s "go.uber.org/cadence/.gen/go/shared"
@@ -292,6 +293,8 @@ type (
 		//
 		// Note that this applies to each Select call.  If you create a Selector with only one AddDefault, and then call
 		// Select on it twice, f will be invoked twice.
+		//
+		// To fall through after a timeout rather than immediately, use AddTimeout of a SelectorWithDeadline.
 		AddDefault(f func())
 		// Select waits for one of the added conditions to be met and invokes the callback as described above.
 		// If no condition is met, Select will block until one or more are available, then one callback will be invoked.
@@ -317,6 +320,20 @@ type (
 		Select(ctx Context)
 	}
 
+	// SelectorWithDeadline is a Selector with timeout cases, created by NewSelectorWithDeadline.
+	SelectorWithDeadline interface {
+		Selector
+		// AddTimeout adds a case whose f is invoked when no other case is met within timeout of a Select call.
+		// Each Select call starts a timer for each timeout case when no case is met immediately, and cancels the
+		// timers when it returns, so that no timer is left pending in the history when another case is met first.
+		// The timers are not canceled when the Context passed to Select is canceled. When a default case was added
+		// with AddDefault, it is invoked instead and no timer is started.
+		//
+		// This is equivalent to a `case <-time.After(timeout):`, and to adding the Future of a Timer canceled once
+		// Select returned.
+		AddTimeout(timeout time.Duration, f func()) SelectorWithDeadline
+	}
+
 	// WaitGroup must be used instead of native go sync.WaitGroup by
 	// workflow code.  Use workflow.NewWaitGroup(ctx) method to create
 	// a new WaitGroup instance
@@ -551,8 +568,26 @@ type RegisterWorkflowOptions struct {
 	Name string
 	// Workflow type name is equal to function name instead of fully qualified name including function package.
 	// This option has no effect when explicit Name is provided.
-	EnableShortName               bool
+	EnableShortName bool
+	// DisableAlreadyRegisteredCheck allows registering a name again, replacing the previous registration, and
+	// registering a name already registered with another function by another worker of the process polling the same
+	// task list.
 	DisableAlreadyRegisteredCheck bool
+	// Optional: previous names of the workflow type. Executions started under one of them, e.g. before the workflow
+	// was renamed, are still executed and replayed by this workflow. WorkflowInfo.MatchedWorkflowTypeAlias tells
+	// which alias an execution matched.
+	Aliases []string
+	// Optional: activity options set on the root workflow context before the workflow function is invoked.
+	// They are used by activities scheduled without calling WithActivityOptions.
+	DefaultActivityOptions *ActivityOptions
+	// Optional: local activity options set on the root workflow context before the workflow function is invoked.
+	// They are used by local activities scheduled without calling WithLocalActivityOptions.
+	DefaultLocalActivityOptions *LocalActivityOptions
+	// Optional: data converter of this workflow, used instead of WorkerOptions.DataConverter to decode its input and
+	// encode its result, and set on its root context, e.g. for the activities it schedules, markers and signals.
+	// The clients starting it, and the workflows starting it as a child, must use the same data converter.
+	// It allows a worker to host workflows with different encodings, e.g. while migrating them one at a time.
+	DataConverter DataConverter
 }
 
 // RegisterWorkflow - registers a workflow function with the framework.
@@ -625,6 +660,33 @@ func Await(ctx Context, condition func() bool) error {
 	return nil
 }
 
+// AwaitWithTimeout blocks the calling thread until condition() returns true or the timeout expires.
+// Returns ok equal to false if the timeout expired, and CanceledError if the ctx is canceled.
+func AwaitWithTimeout(ctx Context, timeout time.Duration, condition func() bool) (ok bool, err error) {
+	if condition() {
+		return true, nil
+	}
+	state := getState(ctx)
+	defer state.unblocked()
+
+	timerCtx, cancelTimer := WithCancel(ctx)
+	defer cancelTimer()
+	timer := NewTimer(timerCtx, timeout)
+	for !condition() {
+		doneCh := ctx.Done()
+		if doneCh != nil {
+			if _, more := doneCh.ReceiveAsyncWithMoreFlag(nil); !more {
+				return false, NewCanceledError("AwaitWithTimeout context cancelled")
+			}
+		}
+		if timer.IsReady() {
+			return false, nil
+		}
+		state.yield("AwaitWithTimeout")
+	}
+	return true, nil
+}
+
 // NewChannel create new Channel instance
 func NewChannel(ctx Context) Channel {
 	state := getState(ctx)
@@ -652,17 +714,54 @@ func NewNamedBufferedChannel(ctx Context, name string, size int) Channel {
 	return &channelImpl{name: name, size: size, dataConverter: getDataConverterFromWorkflowContext(ctx), env: env}
 }
 
+// ReceiveWithCancel is the same as c.Receive, but also stops blocking when ctx is canceled, instead of requiring a
+// Selector with ctx.Done().
+// `ok` is true when a value was received and assigned to valuePtr.
+// `canceled` is true when ctx was canceled before a value was received, ctx.Err() then returns ErrCanceled.
+// Both are false when the Channel is closed and empty.
+// A value which can be received without blocking is received even when ctx is already canceled.
+//
+// This is equivalent to:
+//
+//	select {
+//	case value, ok = <- aChannel:
+//	case <- ctx.Done():
+//		canceled = true
+//	}
+//
+// Decoding or assigning failures are handled like Receive.
+//
+// Channels which were not created by the SDK, e.g. the ones returned by a WorkflowInterceptor wrapping
+// GetSignalChannel, are polled with their ReceiveAsyncWithMoreFlag each time the workflow is unblocked, as they cannot
+// be added to a Selector.
+func ReceiveWithCancel(ctx Context, c Channel, valuePtr interface{}) (ok bool, canceled bool) {
+	if impl, isImpl := c.(*channelImpl); isImpl {
+		return impl.receiveWithCancel(ctx, valuePtr)
+	}
+	var more bool
+	err := Await(ctx, func() bool {
+		ok, more = c.ReceiveAsyncWithMoreFlag(valuePtr)
+		return ok || !more
+	})
+	return ok, err != nil
+}
+
 // NewSelector creates a new Selector instance.
 func NewSelector(ctx Context) Selector {
 	state := getState(ctx)
 	state.dispatcher.selectorSequence++
-	return NewNamedSelector(ctx, fmt.Sprintf("selector-%v", state.dispatcher.selectorSequence))
+	return &selectorImpl{name: fmt.Sprintf("selector-%v", state.dispatcher.selectorSequence), coverage: state.dispatcher.coverage}
 }
 
 // NewNamedSelector creates a new Selector instance with a given human readable name.
 // Name appears in stack traces that are blocked on this Selector.
 func NewNamedSelector(ctx Context, name string) Selector {
-	return &selectorImpl{name: name}
+	return &selectorImpl{name: name, coverage: getState(ctx).dispatcher.coverage}
+}
+
+// NewSelectorWithDeadline creates a new SelectorWithDeadline instance.
+func NewSelectorWithDeadline(ctx Context) SelectorWithDeadline {
+	return NewSelector(ctx).(*selectorImpl)
 }
 
 // NewWaitGroup creates a new WaitGroup instance.
@@ -784,6 +883,9 @@ func (wc *workflowEnvironmentInterceptor) ExecuteActivity(ctx Context, typeName
 
 	// Retrieve headers from context to pass them on
 	header := getHeadersFromContext(ctx)
+	if wc.env.IsActivityCallerInfoEnabled() {
+		setWorkflowCallerHeaders(header, wc.env.WorkflowInfo())
+	}
 
 	input, err := encodeArgs(dataConverter, args)
 	if err != nil {
@@ -898,8 +1000,14 @@ func (wc *workflowEnvironmentInterceptor) ExecuteLocalActivity(ctx Context, acti
 			var result []byte
 			err := f.Get(ctx, &result)
 			if retryErr, ok := err.(*needRetryError); ok && retryErr.Backoff > 0 {
-				// Backoff for retry
+				// Backoff for retry with a timer, as the backoff is too long to retry within the decision task
 				Sleep(ctx, retryErr.Backoff)
+				// the timer may fire after the expiration of the retry policy, e.g. when no worker was available,
+				// histories recorded before this check schedule the next attempt instead, see WorkerBugPorts
+				if params.retryExpired(Now(ctx)) && wc.env.IsLocalActivityRetryExpirationEnabled() {
+					settable.Set(nil, retryErr.Err)
+					return
+				}
 				// increase the attempt, and retry the local activity
 				params.Attempt = retryErr.Attempt + 1
 				continue
@@ -917,6 +1025,7 @@ func (wc *workflowEnvironmentInterceptor) ExecuteLocalActivity(ctx Context, acti
 type needRetryError struct {
 	Backoff time.Duration
 	Attempt int32
+	Err     error // of the failed attempt
 }
 
 func (e *needRetryError) Error() string {
@@ -939,7 +1048,7 @@ func (wc *workflowEnvironmentInterceptor) scheduleLocalActivity(ctx Context, par
 		}
 
 		// set retry error, and it will be handled by workflow.ExecuteLocalActivity().
-		f.Set(nil, &needRetryError{Backoff: lar.backoff, Attempt: lar.attempt})
+		f.Set(nil, &needRetryError{Backoff: lar.backoff, Attempt: lar.attempt, Err: lar.err})
 		return
 	})
 
@@ -1036,11 +1145,20 @@ func (wc *workflowEnvironmentInterceptor) ExecuteChildWorkflow(ctx Context, chil
 	}
 
 	var childWorkflowExecution *WorkflowExecution
+	child := &childWorkflowRecord{handle: ChildWorkflowHandle{
+		WorkflowID:   options.workflowID,
+		WorkflowType: wfType.Name,
+		Domain:       *options.domain,
+		State:        ChildWorkflowStatePending,
+		Future:       result,
+	}}
 
 	ctxDone, cancellable := ctx.Done().(*channelImpl)
 	cancellationCallback := &receiveCallback{}
 	shouldCancelAsync := false
 	err = getWorkflowEnvironment(ctx).ExecuteChildWorkflow(params, func(r []byte, e error) {
+		child.closed(e)
+		wc.dropClosedChildren()
 		mainSettable.Set(r, e)
 		if cancellable {
 			// future is done, we don't need cancellation anymore
@@ -1050,6 +1168,7 @@ func (wc *workflowEnvironmentInterceptor) ExecuteChildWorkflow(ctx Context, chil
 		if e == nil {
 			childWorkflowExecution = &r
 		}
+		child.started(r, e)
 		executionSettable.Set(r, e)
 
 		// forward the delayed cancellation if necessary
@@ -1067,6 +1186,7 @@ func (wc *workflowEnvironmentInterceptor) ExecuteChildWorkflow(ctx Context, chil
 		mainSettable.Set(nil, err)
 		return result
 	}
+	wc.children = append(wc.children, child)
 
 	if cancellable {
 		cancellationCallback.fn = func(v interface{}, more bool) bool {
@@ -1119,6 +1239,8 @@ type WorkflowInfo struct {
 	Domain                              string
 	Attempt                             int32 // Attempt starts from 0 and increased by 1 for every retry if retry policy is specified.
 	lastCompletionResult                []byte
+	lastFailureReason                   string
+	lastFailureDetails                  []byte
 	CronSchedule                        *string
 	ContinuedExecutionRunID             *string
 	ParentWorkflowDomain                *string
@@ -1131,6 +1253,9 @@ type WorkflowInfo struct {
 	TotalHistoryBytes                   int64
 	HistoryBytesServer                  int64
 	HistoryCount                        int64
+	// MatchedWorkflowTypeAlias is set to WorkflowType.Name when it matched one of the RegisterWorkflowOptions.Aliases
+	// of the workflow instead of its registered name.
+	MatchedWorkflowTypeAlias string
 }
 
 // GetBinaryChecksum returns the binary checksum(identifier) of this worker
@@ -1307,6 +1432,34 @@ func (wc *workflowEnvironmentInterceptor) RequestCancelExternalWorkflow(ctx Cont
 	return future
 }
 
+// RequestCancelExternalWorkflows requests the cancellation of the executions, like RequestCancelExternalWorkflow, and
+// returns a Future ready once all the requests completed. Its error combines the errors of the failed requests with
+// go.uber.org/multierr, each one naming its workflow ID, so that all the executions are requested to cancel even when
+// some requests fail.
+func RequestCancelExternalWorkflows(ctx Context, executions []WorkflowExecution) Future {
+	futures := make([]Future, 0, len(executions))
+	for _, execution := range executions {
+		futures = append(futures, RequestCancelExternalWorkflow(ctx, execution.ID, execution.RunID))
+	}
+	return combineCancelRequests(ctx, executions, futures)
+}
+
+// combineCancelRequests returns a Future ready once all the futures of the cancel requests of the executions are,
+// with their combined errors.
+func combineCancelRequests(ctx Context, executions []WorkflowExecution, futures []Future) Future {
+	future, settable := NewFuture(ctx)
+	Go(ctx, func(ctx Context) {
+		var errs error
+		for i, f := range futures {
+			if err := f.Get(ctx, nil); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("cancel workflow %v: %w", executions[i].ID, err))
+			}
+		}
+		settable.Set(nil, errs)
+	})
+	return future
+}
+
 // SignalExternalWorkflow can be used to send signal info to an external workflow.
 // Input workflowID is the workflow ID of target workflow.
 // Input runID indicates the instance of a workflow. Input runID is optional (default is ""). When runID is not specified,
@@ -1527,6 +1680,17 @@ func (b EncodedValue) HasValue() bool {
 	return b.value != nil
 }
 
+// Size returns the number of bytes of the encoded value
+func (b EncodedValue) Size() int {
+	return len(b.value)
+}
+
+// TypeHints returns the type of the encoded value written by the DataConverter, if any
+func (b EncodedValue) TypeHints() []string {
+	hints, _ := splitTypeHints(b.value)
+	return hints
+}
+
 // SideEffect docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.SideEffect]
 func SideEffect(ctx Context, f func(ctx Context) interface{}) Value {
 	i := getWorkflowInterceptor(ctx)
@@ -1689,10 +1853,34 @@ func (wc *workflowEnvironmentInterceptor) GetVersion(ctx Context, changeID strin
 	return wc.env.GetVersion(changeID, minSupported, maxSupported, opts...)
 }
 
+// GetVersions docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.GetVersions]
+func GetVersions(ctx Context, changeIDs ...string) map[string]Version {
+	decided := getWorkflowEnvironment(ctx).DecidedVersions(changeIDs)
+	versions := make(map[string]Version, len(changeIDs))
+	for _, changeID := range changeIDs {
+		// the decided version is the only one supported, so GetVersion returns it, and an undecided change is pinned
+		// to DefaultVersion which records no marker. Going through GetVersion keeps the interceptors and the mocks of
+		// the test environment in the loop.
+		maxSupported := DefaultVersion
+		if version, ok := decided[changeID]; ok {
+			maxSupported = version
+		}
+		versions[changeID] = GetVersion(ctx, changeID, DefaultVersion, maxSupported)
+	}
+	return versions
+}
+
+// ExecutionVersions docs are in the public API to prevent duplication: [go.uber.org/cadence/workflow.ExecutionVersions]
+func ExecutionVersions(ctx Context) map[string]Version {
+	return getWorkflowEnvironment(ctx).ExecutionVersions()
+}
+
 // SetQueryHandler sets the query handler to handle workflow query. The queryType specify which query type this handler
 // should handle. The handler must be a function that returns 2 values. The first return value must be a serializable
 // result. The second return value must be an error. The handler function could receive any number of input parameters.
-// All the input parameter must be serializable. You should call workflow.SetQueryHandler() at the beginning of the workflow
+// All the input parameter must be serializable, they are decoded from the query arguments with the DataConverter of the
+// workflow. The first parameter can also be a Context, the context passed to SetQueryHandler with the QueryInfo of
+// the query, see GetQueryInfo. You should call workflow.SetQueryHandler() at the beginning of the workflow
 // code. When client calls Client.QueryWorkflow() to cadence server, a task will be generated on server that will be dispatched
 // to a workflow worker, which will replay the history events and then execute a query handler based on the query type.
 // The query handler will be invoked out of the context of the workflow, meaning that the handler code must not use cadence
@@ -1797,6 +1985,17 @@ func (wc *workflowEnvironmentInterceptor) GetLastCompletionResult(ctx Context, d
 	return encodedVal.Get(d...)
 }
 
+// GetLastError returns the error of the previous run of this cron workflow, or of the previous attempt of a workflow
+// with a retry policy, or nil when the previous run completed successfully or there is no previous run. The error has
+// the type returned by that run, e.g. *CustomError with its details decoded by the data converter or *TimeoutError.
+func GetLastError(ctx Context) error {
+	info := getWorkflowEnvironment(ctx).WorkflowInfo()
+	if len(info.lastFailureReason) == 0 {
+		return nil
+	}
+	return constructError(info.lastFailureReason, info.lastFailureDetails, getDataConverterFromWorkflowContext(ctx))
+}
+
 // WithActivityOptions adds all options to the copy of the context.
 // The current timeout resolution implementation is in seconds and uses math.Ceil(d.Seconds()) as the duration. But is
 // subjected to change in the future.
@@ -1815,6 +2014,64 @@ func WithActivityOptions(ctx Context, options ActivityOptions) Context {
 	return ctx1
 }
 
+// WithActivityOptionsOverride merges the non-zero fields of options into the activity options of the copy of the context.
+// Unlike WithActivityOptions, fields that are not set in options keep the value configured upstream.
+func WithActivityOptionsOverride(ctx Context, options ActivityOptions) Context {
+	ctx1 := setActivityParametersIfNotExist(ctx)
+	eap := getActivityOptions(ctx1)
+
+	if options.TaskList != "" {
+		eap.TaskListName = options.TaskList
+	}
+	if options.ScheduleToCloseTimeout != 0 {
+		eap.ScheduleToCloseTimeoutSeconds = common.Int32Ceil(options.ScheduleToCloseTimeout.Seconds())
+	}
+	if options.StartToCloseTimeout != 0 {
+		eap.StartToCloseTimeoutSeconds = common.Int32Ceil(options.StartToCloseTimeout.Seconds())
+	}
+	if options.ScheduleToStartTimeout != 0 {
+		eap.ScheduleToStartTimeoutSeconds = common.Int32Ceil(options.ScheduleToStartTimeout.Seconds())
+	}
+	if options.HeartbeatTimeout != 0 {
+		eap.HeartbeatTimeoutSeconds = common.Int32Ceil(options.HeartbeatTimeout.Seconds())
+	}
+	if options.WaitForCancellation {
+		eap.WaitForCancellation = true
+	}
+	if options.ActivityID != "" {
+		eap.ActivityID = common.StringPtr(options.ActivityID)
+	}
+	if options.RetryPolicy != nil {
+		eap.RetryPolicy = convertRetryPolicy(options.RetryPolicy)
+	}
+	return ctx1
+}
+
+// GetActivityOptions returns the activity options configured in the context.
+// A zero value is returned if no activity options were set.
+func GetActivityOptions(ctx Context) ActivityOptions {
+	eap := getActivityOptions(ctx)
+	if eap == nil {
+		return ActivityOptions{}
+	}
+
+	options := ActivityOptions{
+		TaskList:               eap.TaskListName,
+		ScheduleToCloseTimeout: time.Duration(eap.ScheduleToCloseTimeoutSeconds) * time.Second,
+		ScheduleToStartTimeout: time.Duration(eap.ScheduleToStartTimeoutSeconds) * time.Second,
+		StartToCloseTimeout:    time.Duration(eap.StartToCloseTimeoutSeconds) * time.Second,
+		HeartbeatTimeout:       time.Duration(eap.HeartbeatTimeoutSeconds) * time.Second,
+		WaitForCancellation:    eap.WaitForCancellation,
+	}
+	if eap.ActivityID != nil {
+		options.ActivityID = *eap.ActivityID
+	}
+	if eap.RetryPolicy != nil {
+		options.RetryPolicy = fromThriftRetryPolicy(eap.RetryPolicy)
+	}
+	return options
+}
+
 // WithLocalActivityOptions adds local activity options to the copy of the context.
 // The current timeout resolution implementation is in seconds and uses math.Ceil(d.Seconds()) as the duration. But is
 // subjected to change in the future.
@@ -1827,6 +2084,39 @@ func WithLocalActivityOptions(ctx Context, options LocalActivityOptions) Context
 	return ctx1
 }
 
+// WithLocalActivityOptionsOverride merges the non-zero fields of options into the local activity options of the copy of the context.
+// Unlike WithLocalActivityOptions, fields that are not set in options keep the value configured upstream.
+func WithLocalActivityOptionsOverride(ctx Context, options LocalActivityOptions) Context {
+	ctx1 := setLocalActivityParametersIfNotExist(ctx)
+	opts := getLocalActivityOptions(ctx1)
+
+	if options.ScheduleToCloseTimeout != 0 {
+		opts.ScheduleToCloseTimeoutSeconds = common.Int32Ceil(options.ScheduleToCloseTimeout.Seconds())
+	}
+	if options.RetryPolicy != nil {
+		opts.RetryPolicy = options.RetryPolicy
+	}
+	return ctx1
+}
+
+// GetLocalActivityOptions returns the local activity options configured in the context.
+// A zero value is returned if no local activity options were set.
+func GetLocalActivityOptions(ctx Context) LocalActivityOptions {
+	opts := getLocalActivityOptions(ctx)
+	if opts == nil {
+		return LocalActivityOptions{}
+	}
+
+	options := LocalActivityOptions{
+		ScheduleToCloseTimeout: time.Duration(opts.ScheduleToCloseTimeoutSeconds) * time.Second,
+	}
+	if opts.RetryPolicy != nil {
+		retryPolicy := *opts.RetryPolicy
+		options.RetryPolicy = &retryPolicy
+	}
+	return options
+}
+
 // WithTaskList adds a task list to the copy of the context.
 // Note this shall not confuse with WithWorkflowTaskList. This is the tasklist for activities
 func WithTaskList(ctx Context, name string) Context {
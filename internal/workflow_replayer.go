@@ -34,6 +34,7 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/pborman/uuid"
 	"github.com/uber-go/tally"
+	apiv1 "github.com/uber/cadence-idl/go/proto/api/v1"
 	"go.uber.org/zap"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
@@ -42,6 +43,7 @@ import (
 	"go.uber.org/cadence/internal/common"
 	"go.uber.org/cadence/internal/common/backoff"
 	"go.uber.org/cadence/internal/common/serializer"
+	"go.uber.org/cadence/internal/compatibility/thrift"
 )
 
 const (
@@ -87,6 +89,11 @@ type ReplayOptions struct {
 	// Optional: flags to turn on/off some features on server side
 	// default: all features under the struct is turned off
 	FeatureFlags FeatureFlags
+
+	// Optional: See WorkerBugPorts for more details
+	//
+	// Deprecated: All bugports are always deprecated and may be removed at any time.
+	WorkerBugPorts WorkerBugPorts
 }
 
 // IsReplayDomain checks if the domainName is from replay
@@ -188,6 +195,48 @@ func (r *WorkflowReplayer) ReplayPartialWorkflowHistoryFromJSON(logger *zap.Logg
 	return r.replayWorkflowHistory(logger, service, replayDomainName, nil, history, nil)
 }
 
+// ReplayWorkflowHistoryFromReader executes a single decision task for the given history. The encoding of the
+// history is detected automatically: json as downloaded from the cli, or a thriftrw or protobuf encoded History
+// as found in archived or exported history blobs.
+// The logger is an optional parameter. Defaults to the noop logger.
+func (r *WorkflowReplayer) ReplayWorkflowHistoryFromReader(logger *zap.Logger, reader io.Reader) error {
+	return r.ReplayPartialWorkflowHistoryFromReader(logger, reader, 0)
+}
+
+// ReplayPartialWorkflowHistoryFromReader executes a single decision task for the given history up to provided
+// lastEventID(inclusive). See ReplayWorkflowHistoryFromReader for the supported encodings.
+// The logger is an optional parameter. Defaults to the noop logger.
+func (r *WorkflowReplayer) ReplayPartialWorkflowHistoryFromReader(logger *zap.Logger, reader io.Reader, lastEventID int64) error {
+	history, err := extractEncodedHistoryFromReader(reader, lastEventID)
+	if err != nil {
+		return err
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	testReporter := logger.Sugar()
+	controller := gomock.NewController(testReporter)
+	service := workflowservicetest.NewMockClient(controller)
+
+	return r.replayWorkflowHistory(logger, service, replayDomainName, nil, history, nil)
+}
+
+// ReplayWorkflowHistoryFromFile executes a single decision task for the given history file.
+// See ReplayWorkflowHistoryFromReader for the supported encodings.
+// The logger is an optional parameter. Defaults to the noop logger.
+func (r *WorkflowReplayer) ReplayWorkflowHistoryFromFile(logger *zap.Logger, fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return r.ReplayWorkflowHistoryFromReader(logger, file)
+}
+
 // ReplayWorkflowHistoryFromJSONFile executes a single decision task for the given json history file.
 // Use for testing the backwards compatibility of code changes and troubleshooting workflows in a debugger.
 // The logger is an optional parameter. Defaults to the noop logger.
@@ -323,6 +372,7 @@ func (r *WorkflowReplayer) replayWorkflowHistory(
 			Tracer:                            r.options.Tracer,
 			Logger:                            logger,
 			DisableStickyExecution:            true,
+			WorkerBugPorts:                    r.options.WorkerBugPorts,
 		},
 		TaskList: &shared.TaskList{
 			Name: common.StringPtr(replayTaskListName),
@@ -385,8 +435,93 @@ func extractHistoryFromReader(r io.Reader, lastEventID int64) (*shared.History,
 		return nil, fmt.Errorf("invalid json contents: %w", err)
 	}
 
+	return truncateHistory(deserializedEvents, lastEventID), nil
+}
+
+// extractEncodedHistoryFromReader reads a history in any of the supported encodings:
+//   - a json array of events, as downloaded from the cli
+//   - a json object with an events field, i.e. a json encoded History
+//   - a thriftrw encoded History, as stored in history blobs
+//   - a protobuf encoded History of the gRPC API
+func extractEncodedHistoryFromReader(r io.Reader, lastEventID int64) (*shared.History, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+
+	events, err := decodeHistoryEvents(raw)
+	if err != nil {
+		return nil, err
+	}
+	return truncateHistory(events, lastEventID), nil
+}
+
+func decodeHistoryEvents(raw []byte) ([]*shared.HistoryEvent, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return nil, errReplayEmptyHistory
+	}
+
+	switch trimmed[0] {
+	case '[':
+		var events []*shared.HistoryEvent
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, fmt.Errorf("invalid json contents: %w", err)
+		}
+		return events, nil
+	case '{':
+		var history shared.History
+		if err := json.Unmarshal(trimmed, &history); err != nil {
+			return nil, fmt.Errorf("invalid json contents: %w", err)
+		}
+		return history.Events, nil
+	}
+
+	var thriftHistory shared.History
+	thriftErr := serializer.Decode(raw, &thriftHistory)
+	if thriftErr == nil && isDecodedHistoryValid(thriftHistory.Events) {
+		return thriftHistory.Events, nil
+	}
+
+	var protoHistory apiv1.History
+	protoErr := protoHistory.Unmarshal(raw)
+	if protoErr == nil && hasEventAttributes(protoHistory.Events) {
+		events := thrift.HistoryEventArray(protoHistory.Events)
+		if isDecodedHistoryValid(events) {
+			return events, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown history encoding, expected json, thriftrw or protobuf: thriftrw: %v, protobuf: %v", thriftErr, protoErr)
+}
+
+// isDecodedHistoryValid guards against a history of one encoding being decoded without an error by the decoder
+// of another encoding, which skips fields it doesn't know about.
+func isDecodedHistoryValid(events []*shared.HistoryEvent) bool {
+	if len(events) == 0 {
+		return false
+	}
+	for _, event := range events {
+		if event == nil || event.GetEventId() <= 0 || event.EventType == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// hasEventAttributes reports whether every protobuf event has attributes, which is required to convert it.
+func hasEventAttributes(events []*apiv1.HistoryEvent) bool {
+	for _, event := range events {
+		if event == nil || event.Attributes == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func truncateHistory(deserializedEvents []*shared.HistoryEvent, lastEventID int64) *shared.History {
 	if lastEventID <= 0 {
-		return &shared.History{Events: deserializedEvents}, nil
+		return &shared.History{Events: deserializedEvents}
 	}
 
 	// Caller is potentially asking for subset of history instead of all history events
@@ -399,7 +534,7 @@ func extractHistoryFromReader(r io.Reader, lastEventID int64) (*shared.History,
 		}
 	}
 
-	return &shared.History{Events: events}, nil
+	return &shared.History{Events: events}
 }
 
 func augmentReplayOptions(
@@ -21,9 +21,12 @@
 package internal
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"reflect"
 	"runtime"
 	"testing"
@@ -35,6 +38,8 @@ import (
 
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/serializer"
+	"go.uber.org/cadence/internal/compatibility/proto"
 )
 
 type workflowReplayerSuite struct {
@@ -65,6 +70,7 @@ func (s *workflowReplayerSuite) SetupTest() {
 	})
 	s.replayer.RegisterWorkflow(testReplayWorkflow)
 	s.replayer.RegisterWorkflow(testReplayWorkflowLocalActivity)
+	s.replayer.RegisterWorkflow(testReplayWorkflowLocalActivityRetry)
 	s.replayer.RegisterWorkflow(testReplayWorkflowContextPropagator)
 	s.replayer.RegisterWorkflow(testReplayWorkflowFromFile)
 	s.replayer.RegisterWorkflow(testReplayWorkflowFromFileParent)
@@ -76,6 +82,16 @@ func (s *workflowReplayerSuite) TestReplayWorkflowHistory_Full() {
 	s.NoError(err)
 }
 
+func (s *workflowReplayerSuite) TestReplayWorkflowHistory_RenamedWorkflow() {
+	replayer := NewWorkflowReplayer()
+	replayer.RegisterWorkflowWithOptions(testReplayWorkflow, RegisterWorkflowOptions{
+		Name:    "testReplayWorkflowRenamed",
+		Aliases: []string{"go.uber.org/cadence/internal.testReplayWorkflow"},
+	})
+	err := replayer.ReplayWorkflowHistory(s.logger, getTestReplayWorkflowFullHistory(s.T()))
+	s.NoError(err)
+}
+
 func (s *workflowReplayerSuite) TestReplayWorkflowHistory_Full_ResultMisMatch() {
 	fullHistory := getTestReplayWorkflowFullHistory(s.T())
 	completedEvent := fullHistory.Events[len(fullHistory.Events)-1]
@@ -111,6 +127,33 @@ func (s *workflowReplayerSuite) TestReplayWorkflowHistory_LocalActivity() {
 	s.NoError(err)
 }
 
+func (s *workflowReplayerSuite) TestReplayWorkflowHistory_LocalActivity_RetryAfterExpiration() {
+	// the history scheduled the next attempt when the retry timer fired after the expiration
+	err := s.replayer.ReplayWorkflowHistory(s.logger, getTestReplayWorkflowLocalActivityRetryAfterExpirationHistory(s.T()))
+	s.Error(err)
+
+	replayer := NewWorkflowReplayerWithOptions(ReplayOptions{
+		WorkerBugPorts: WorkerBugPorts{RetryLocalActivitiesAfterExpiration: true},
+	})
+	replayer.RegisterWorkflow(testReplayWorkflowLocalActivityRetry)
+	err = replayer.ReplayWorkflowHistory(s.logger, getTestReplayWorkflowLocalActivityRetryAfterExpirationHistory(s.T()))
+	s.NoError(err)
+}
+
+func (s *workflowReplayerSuite) TestReplayWorkflowHistory_Mismatch_DecisionDiff() {
+	err := s.replayer.ReplayWorkflowHistory(s.logger, getTestReplayWorkflowMismatchHistory(s.T()))
+	var nonDeterministicErr *NonDeterministicError
+	s.True(errors.As(err, &nonDeterministicErr))
+	s.NotNil(nonDeterministicErr.DecisionDiff)
+
+	differences := nonDeterministicErr.DecisionDiff.Differences()
+	s.Len(differences, 1)
+	s.Equal(HistoryDiffTypeMismatch, differences[0].Type)
+	s.Equal(int64(5), differences[0].ExpectedEventID)
+	s.Contains(differences[0].ExpectedText, "unknownActivityType")
+	s.Contains(differences[0].ActualText, "testActivity")
+}
+
 func (s *workflowReplayerSuite) TestReplayWorkflowHistory_LocalActivity_Result_Mismatch() {
 	err := s.replayer.ReplayWorkflowHistory(s.logger, getTestReplayWorkflowLocalActivityResultMismatchHistory(s.T()))
 	s.Error(err)
@@ -141,6 +184,44 @@ func (s *workflowReplayerSuite) TestReplayWorkflowHistoryFromFile() {
 	s.NoError(err)
 }
 
+func (s *workflowReplayerSuite) TestReplayWorkflowHistoryFromReader_Encodings() {
+	file, err := os.Open("testdata/sampleHistory.json")
+	s.NoError(err)
+	defer file.Close()
+	history, err := extractHistoryFromReader(file, 0)
+	s.NoError(err)
+
+	jsonEvents, err := json.Marshal(history.Events)
+	s.NoError(err)
+	jsonHistory, err := json.Marshal(history)
+	s.NoError(err)
+	thriftHistory, err := serializer.Encode(history)
+	s.NoError(err)
+	protoHistory, err := proto.History(history).Marshal()
+	s.NoError(err)
+
+	for name, encoded := range map[string][]byte{
+		"json events":  jsonEvents,
+		"json history": jsonHistory,
+		"thriftrw":     thriftHistory,
+		"protobuf":     protoHistory,
+	} {
+		s.Run(name, func() {
+			s.NoError(s.replayer.ReplayWorkflowHistoryFromReader(s.logger, bytes.NewReader(encoded)))
+		})
+	}
+}
+
+func (s *workflowReplayerSuite) TestReplayWorkflowHistoryFromReader_UnknownEncoding() {
+	err := s.replayer.ReplayWorkflowHistoryFromReader(s.logger, bytes.NewReader([]byte("not a history")))
+	s.ErrorContains(err, "unknown history encoding")
+}
+
+func (s *workflowReplayerSuite) TestReplayWorkflowHistoryFromFile_DetectEncoding() {
+	err := s.replayer.ReplayWorkflowHistoryFromFile(s.logger, "testdata/sampleHistory.json")
+	s.NoError(err)
+}
+
 func (s *workflowReplayerSuite) TestActivityRegistration() {
 	name := "test-Activity"
 	s.replayer.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{Name: name})
@@ -179,6 +260,18 @@ func testReplayWorkflowLocalActivity(ctx Context) error {
 	return err
 }
 
+func testReplayWorkflowLocalActivityRetry(ctx Context) error {
+	ctx = WithLocalActivityOptions(ctx, LocalActivityOptions{
+		ScheduleToCloseTimeout: time.Second,
+		RetryPolicy: &RetryPolicy{
+			InitialInterval:    10 * time.Minute,
+			BackoffCoefficient: 1,
+			ExpirationInterval: 25 * time.Minute,
+		},
+	})
+	return ExecuteLocalActivity(ctx, testActivity).Get(ctx, nil)
+}
+
 func testReplayWorkflowContextPropagator(ctx Context) error {
 	value := ctx.Value(contextKey(testHeader))
 	if val, ok := value.(string); ok && val != "" {
@@ -424,6 +517,62 @@ func getTestReplayWorkflowLocalActivityHistory(t *testing.T) *shared.History {
 	}
 }
 
+func getTestReplayWorkflowLocalActivityRetryAfterExpirationHistory(t *testing.T) *shared.History {
+	startTime := time.Unix(1000, 0)
+	// the retry timer of 10 minutes fired after the expiration of 25 minutes, e.g. when no worker was available
+	fireTime := startTime.Add(30 * time.Minute)
+	failed, err := encodeArg(nil, localActivityMarkerData{
+		ActivityID:   "0",
+		ActivityType: "go.uber.org/cadence/internal.testActivity",
+		ErrReason:    "bad-luck",
+		ReplayTime:   startTime,
+		Backoff:      10 * time.Minute,
+	})
+	require.NoError(t, err)
+	completed, err := encodeArg(nil, localActivityMarkerData{
+		ActivityID:   "2",
+		ActivityType: "go.uber.org/cadence/internal.testActivity",
+		ReplayTime:   fireTime,
+		Attempt:      1,
+	})
+	require.NoError(t, err)
+
+	firstDecisionStarted := createTestEventDecisionTaskStarted(3)
+	firstDecisionStarted.Timestamp = common.Int64Ptr(startTime.UnixNano())
+	secondDecisionStarted := createTestEventDecisionTaskStarted(9)
+	secondDecisionStarted.Timestamp = common.Int64Ptr(fireTime.UnixNano())
+	return &shared.History{
+		Events: []*shared.HistoryEvent{
+			createTestEventWorkflowExecutionStarted(1, &shared.WorkflowExecutionStartedEventAttributes{
+				WorkflowType: &shared.WorkflowType{Name: common.StringPtr("go.uber.org/cadence/internal.testReplayWorkflowLocalActivityRetry")},
+				TaskList:     testTaskList,
+				Input:        testEncodeFunctionArgs(t, getDefaultDataConverter()),
+			}),
+			createTestEventDecisionTaskScheduled(2, &shared.DecisionTaskScheduledEventAttributes{}),
+			firstDecisionStarted,
+			createTestEventDecisionTaskCompleted(4, &shared.DecisionTaskCompletedEventAttributes{}),
+			createTestEventLocalActivity(5, &shared.MarkerRecordedEventAttributes{
+				MarkerName:                   common.StringPtr(localActivityMarkerName),
+				Details:                      failed,
+				DecisionTaskCompletedEventId: common.Int64Ptr(4),
+			}),
+			createTestEventTimerStarted(6, 1),
+			createTestEventTimerFired(7, 1),
+			createTestEventDecisionTaskScheduled(8, &shared.DecisionTaskScheduledEventAttributes{}),
+			secondDecisionStarted,
+			createTestEventDecisionTaskCompleted(10, &shared.DecisionTaskCompletedEventAttributes{}),
+			createTestEventLocalActivity(11, &shared.MarkerRecordedEventAttributes{
+				MarkerName:                   common.StringPtr(localActivityMarkerName),
+				Details:                      completed,
+				DecisionTaskCompletedEventId: common.Int64Ptr(10),
+			}),
+			createTestEventWorkflowExecutionCompleted(12, &shared.WorkflowExecutionCompletedEventAttributes{
+				DecisionTaskCompletedEventId: common.Int64Ptr(10),
+			}),
+		},
+	}
+}
+
 func getTestReplayWorkflowLocalActivityResultMismatchHistory(t *testing.T) *shared.History {
 	return &shared.History{
 		Events: []*shared.HistoryEvent{
@@ -87,7 +87,7 @@ func lastPartOfName(name string) string {
 func skipDeterministicCheckForEvent(e *s.HistoryEvent) bool {
 	if e.GetEventType() == s.EventTypeMarkerRecorded {
 		markerName := e.MarkerRecordedEventAttributes.GetMarkerName()
-		if markerName == versionMarkerName || markerName == mutableSideEffectMarkerName {
+		if markerName == versionMarkerName || markerName == mutableSideEffectMarkerName || markerName == sdkVersionMarkerName {
 			return true
 		}
 	}
@@ -111,7 +111,7 @@ func skipDeterministicCheckForUpsertChangeVersion(events []*s.HistoryEvent, idx
 func skipDeterministicCheckForDecision(d *s.Decision) bool {
 	if d.GetDecisionType() == s.DecisionTypeRecordMarker {
 		markerName := d.RecordMarkerDecisionAttributes.GetMarkerName()
-		if markerName == versionMarkerName || markerName == mutableSideEffectMarkerName {
+		if markerName == versionMarkerName || markerName == mutableSideEffectMarkerName || markerName == sdkVersionMarkerName {
 			return true
 		}
 	}
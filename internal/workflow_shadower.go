@@ -31,11 +31,13 @@ import (
 	"time"
 
 	"github.com/facebookgo/clock"
+	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	"go.uber.org/cadence/.gen/go/shadower"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 	"go.uber.org/cadence/internal/common/util"
 )
 
@@ -103,6 +105,20 @@ type (
 		// An error will be returned if it's set to be larger than 1 when used to NewWorkflowShadower
 		// default: 1
 		Concurrency int
+
+		// Optional: run shadowing in dark launch mode.
+		// In this mode a workflow whose replayed decisions don't match the decisions recorded in history
+		// won't stop shadowing. The decisions which diverged are logged and the mismatch is reported via
+		// MetricsScope instead, so the shadower can keep replaying fresh production histories with a new binary
+		// as a pre-deploy gate. Usually used together with ShadowModeContinuous.
+		// Note: the shadow worker always keeps shadowing after a mismatch, in this mode it reports the
+		// mismatch through the metrics scope of its activities.
+		// default: false
+		DarkLaunch bool
+
+		// Optional: metrics scope for replay results reported by the local WorkflowShadower.
+		// default: no-op scope
+		MetricsScope tally.Scope
 	}
 
 	// TimeFilter represents a time range through the min and max timestamp
@@ -135,6 +151,7 @@ type (
 		shadowOptions ShadowOptions
 		logger        *zap.Logger
 		replayer      *WorkflowReplayer
+		metricsScope  tally.Scope
 
 		status     int32
 		shutdownCh chan struct{}
@@ -188,6 +205,7 @@ func NewWorkflowShadower(
 		shadowOptions: shadowOptions,
 		logger:        logger,
 		replayer:      NewWorkflowReplayerWithOptions(replayOptions),
+		metricsScope:  tagScope(shadowOptions.MetricsScope, tagDomain, domain),
 
 		status:     statusInitialized,
 		shutdownCh: make(chan struct{}),
@@ -269,17 +287,10 @@ func (s *WorkflowShadower) shadowWorker() error {
 				return nil
 			}
 
-			success, err := replayWorkflowExecutionHelper(
-				ctx,
-				s.replayer,
-				s.service,
-				s.logger,
-				s.domain,
-				WorkflowExecution{
-					ID:    execution.GetWorkflowId(),
-					RunID: execution.GetRunId(),
-				},
-			)
+			success, err := s.replayExecution(ctx, WorkflowExecution{
+				ID:    execution.GetWorkflowId(),
+				RunID: execution.GetRunId(),
+			})
 			if err != nil {
 				return err
 			}
@@ -305,6 +316,29 @@ func (s *WorkflowShadower) shadowWorker() error {
 
 }
 
+func (s *WorkflowShadower) replayExecution(ctx context.Context, execution WorkflowExecution) (bool, error) {
+	sw := metrics.StartLatency(s.metricsScope, metrics.ReplayLatency, metrics.Default1ms100s)
+	defer sw.Stop()
+
+	success, err := replayWorkflowExecutionHelper(ctx, s.replayer, s.service, s.logger, s.domain, execution)
+	switch {
+	case err != nil:
+		s.metricsScope.Counter(metrics.ReplayFailedCounter).Inc(1)
+		if s.shadowOptions.DarkLaunch && isNondeterministicErr(err) {
+			// decisions produced by the new binary differ from the ones recorded in history,
+			// report the mismatch and keep shadowing.
+			reportDecisionMismatch(s.logger, s.metricsScope, execution, err)
+			return false, nil
+		}
+		return false, err
+	case success:
+		s.metricsScope.Counter(metrics.ReplaySucceedCounter).Inc(1)
+	default:
+		s.metricsScope.Counter(metrics.ReplaySkippedCounter).Inc(1)
+	}
+	return success, nil
+}
+
 func (o *ShadowOptions) validateAndPopulateFields() error {
 	exitConditionSpecified := o.ExitCondition.ExpirationInterval > 0 || o.ExitCondition.ShadowCount > 0
 	if o.Mode == ShadowModeContinuous && !exitConditionSpecified {
@@ -371,6 +405,10 @@ func (o *ShadowOptions) validateAndPopulateFields() error {
 		o.Concurrency = 1
 	}
 
+	if o.MetricsScope == nil {
+		o.MetricsScope = tally.NoopScope
+	}
+
 	return nil
 }
 
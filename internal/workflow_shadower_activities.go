@@ -22,10 +22,12 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"strings"
 	"time"
 
+	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
@@ -46,6 +48,7 @@ type (
 const (
 	serviceClientContextKey    contextKey = "serviceClient"
 	workflowReplayerContextKey contextKey = "workflowReplayer"
+	darkLaunchContextKey       contextKey = "darkLaunch"
 )
 
 const (
@@ -151,6 +154,7 @@ func replayWorkflowActivity(
 	scope := tagScope(GetActivityMetricsScope(ctx), tagDomain, params.GetDomain(), tagTaskList, GetActivityInfo(ctx).TaskList)
 	service := ctx.Value(serviceClientContextKey).(workflowserviceclient.Interface)
 	replayer := ctx.Value(workflowReplayerContextKey).(*WorkflowReplayer)
+	darkLaunch, _ := ctx.Value(darkLaunchContextKey).(bool)
 
 	var progress replayWorkflowActivityProgress
 	if err := GetHeartbeatDetails(ctx, &progress); err != nil {
@@ -174,10 +178,11 @@ func replayWorkflowActivity(
 		}
 
 		sw := metrics.StartLatency(scope, metrics.ReplayLatency, metrics.Default1ms100s)
-		success, err := replayWorkflowExecutionHelper(ctx, replayer, service, logger, params.GetDomain(), WorkflowExecution{
+		workflowExecution := WorkflowExecution{
 			ID:    execution.GetWorkflowId(),
 			RunID: execution.GetRunId(),
-		})
+		}
+		success, err := replayWorkflowExecutionHelper(ctx, replayer, service, logger, params.GetDomain(), workflowExecution)
 		if err != nil {
 			scope.Counter(metrics.ReplayFailedCounter).Inc(1)
 			*progress.Result.Failed++
@@ -185,6 +190,9 @@ func replayWorkflowActivity(
 				// this should fail the replay workflow as it requires worker deployment to fix the workflow registration.
 				return progress.Result, NewCustomError(shadower.ErrReasonWorkflowTypeNotRegistered, err.Error())
 			}
+			if darkLaunch && isNondeterministicErr(err) {
+				reportDecisionMismatch(logger, scope, workflowExecution, err)
+			}
 		} else if success {
 			scope.Counter(metrics.ReplaySucceedCounter).Inc(1)
 			*progress.Result.Succeeded++
@@ -229,6 +237,24 @@ func replayWorkflowExecutionHelper(
 	return false, nil
 }
 
+// reportDecisionMismatch reports a dark launch replay whose decisions differ from the ones recorded in history,
+// logging the decisions which diverged.
+func reportDecisionMismatch(logger *zap.Logger, scope tally.Scope, execution WorkflowExecution, err error) {
+	scope.Counter(metrics.ReplayDecisionMismatchCounter).Inc(1)
+
+	fields := []zap.Field{
+		zap.String(tagWorkflowID, execution.ID),
+		zap.String(tagRunID, execution.RunID),
+		zap.Error(err),
+	}
+	var nonDeterministicErr *NonDeterministicError
+	if errors.As(err, &nonDeterministicErr) && nonDeterministicErr.DecisionDiff != nil {
+		differences := &HistoryDiff{Entries: nonDeterministicErr.DecisionDiff.Differences()}
+		fields = append(fields, zap.String("DecisionDiff", differences.String()))
+	}
+	logger.Warn("Replayed decisions differ from history", fields...)
+}
+
 func isNondeterministicErr(err error) bool {
 	// There're a few expected replay errors, for example:
 	//   1. errReplayHistoryTooShort
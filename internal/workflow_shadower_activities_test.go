@@ -32,11 +32,16 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	"go.uber.org/cadence/.gen/go/shadower"
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 )
 
 type workflowShadowerActivitiesSuite struct {
@@ -260,6 +265,51 @@ func (s *workflowShadowerActivitiesSuite) TestReplayWorkflowExecutionActivity_Ra
 	s.Equal(numFailed, result.GetFailed())
 }
 
+func (s *workflowShadowerActivitiesSuite) TestReplayWorkflowExecutionActivity_DarkLaunch() {
+	testScope := tally.NewTestScope("", nil)
+	core, observed := observer.New(zapcore.WarnLevel)
+	activityContext := context.Background()
+	activityContext = context.WithValue(activityContext, serviceClientContextKey, s.mockService)
+	activityContext = context.WithValue(activityContext, workflowReplayerContextKey, s.testReplayer)
+	activityContext = context.WithValue(activityContext, darkLaunchContextKey, true)
+	s.env.SetWorkerOptions(WorkerOptions{
+		BackgroundActivityContext: activityContext,
+		Logger:                    zap.New(core),
+		MetricsScope:              testScope,
+	})
+
+	gomock.InOrder(
+		s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.GetWorkflowExecutionHistoryResponse{
+			History: s.testWorkflowHistory,
+		}, nil).Times(1),
+		s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.GetWorkflowExecutionHistoryResponse{
+			History: getTestReplayWorkflowMismatchHistory(s.T()),
+		}, nil).Times(1),
+	)
+
+	params := newTestReplayWorkflowActivityParams(2)
+
+	resultValue, err := s.env.ExecuteActivity(shadower.ReplayWorkflowActivityName, params)
+	s.NoError(err)
+
+	var result shadower.ReplayWorkflowActivityResult
+	s.NoError(resultValue.Get(&result))
+	s.Equal(int32(1), result.GetSucceeded())
+	s.Equal(int32(1), result.GetFailed())
+
+	var mismatches int64
+	for _, counter := range testScope.Snapshot().Counters() {
+		if counter.Name() == metrics.ReplayDecisionMismatchCounter {
+			mismatches += counter.Value()
+		}
+	}
+	s.Equal(int64(1), mismatches)
+
+	logs := observed.FilterMessage("Replayed decisions differ from history").All()
+	s.Len(logs, 1)
+	s.Contains(logs[0].ContextMap()["DecisionDiff"], "ActivityType:(Name:unknownActivityType)")
+}
+
 func (s *workflowShadowerActivitiesSuite) TestReplayWorkflowExecutionActivity_WorkflowNotRegistered() {
 	s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.GetWorkflowExecutionHistoryResponse{
 		History: getTestReplayWorkflowLocalActivityHistory(s.T()), // this workflow type is not registered
@@ -33,10 +33,15 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 )
 
 type workflowShadowerSuite struct {
@@ -393,6 +398,43 @@ func (s *workflowShadowerSuite) TestShadowWorker_ReplayFailed() {
 	s.Error(s.testShadower.shadowWorker())
 }
 
+func (s *workflowShadowerSuite) TestShadowWorker_DarkLaunch_ReplayMismatch() {
+	testScope := tally.NewTestScope("", nil)
+	core, observed := observer.New(zapcore.WarnLevel)
+	s.testShadower.shadowOptions.DarkLaunch = true
+	s.testShadower.metricsScope = testScope
+	s.testShadower.logger = zap.New(core)
+
+	s.mockService.EXPECT().ScanWorkflowExecutions(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.ListWorkflowExecutionsResponse{
+		Executions:    newTestWorkflowExecutions(3),
+		NextPageToken: nil,
+	}, nil).Times(1)
+	gomock.InOrder(
+		s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.GetWorkflowExecutionHistoryResponse{
+			History: s.testWorkflowHistory,
+		}, nil).Times(1),
+		s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.GetWorkflowExecutionHistoryResponse{
+			History: getTestReplayWorkflowMismatchHistory(s.T()),
+		}, nil).Times(1),
+		s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.GetWorkflowExecutionHistoryResponse{
+			History: s.testWorkflowHistory,
+		}, nil).Times(1),
+	)
+
+	s.NoError(s.testShadower.shadowWorker())
+
+	counters := testScope.Snapshot().Counters()
+	s.Equal(int64(2), counters[metrics.ReplaySucceedCounter+"+"].Value())
+	s.Equal(int64(1), counters[metrics.ReplayFailedCounter+"+"].Value())
+	s.Equal(int64(1), counters[metrics.ReplayDecisionMismatchCounter+"+"].Value())
+
+	logs := observed.FilterMessage("Replayed decisions differ from history").All()
+	s.Len(logs, 1)
+	diff := logs[0].ContextMap()["DecisionDiff"]
+	s.Contains(diff, "- [5] ActivityTaskScheduled: (ActivityId:0, ActivityType:(Name:unknownActivityType)")
+	s.Contains(diff, "+ ScheduleActivityTask: (ActivityId:0, ActivityType:(Name:testActivity)")
+}
+
 func (s *workflowShadowerSuite) TestShadowWorker_ExpectedReplayError() {
 	testCases := []struct {
 		msg                string
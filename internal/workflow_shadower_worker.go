@@ -68,6 +68,7 @@ func newShadowWorker(
 		WorkflowInterceptorChainFactories: params.WorkflowInterceptorChainFactories,
 		Tracer:                            params.Tracer,
 		FeatureFlags:                      params.FeatureFlags,
+		WorkerBugPorts:                    params.WorkerBugPorts,
 	})
 	replayer.registry = registry
 
@@ -85,6 +86,7 @@ func newShadowWorker(
 
 	params.UserContext = context.WithValue(params.UserContext, serviceClientContextKey, service)
 	params.UserContext = context.WithValue(params.UserContext, workflowReplayerContextKey, replayer)
+	params.UserContext = context.WithValue(params.UserContext, darkLaunchContextKey, shadowOptions.DarkLaunch)
 
 	// data converter, interceptors, context propagators, tracers provided by user is for replay
 	// for the actual shadowing workflow use default values.
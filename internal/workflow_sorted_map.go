@@ -0,0 +1,69 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"cmp"
+	"sort"
+)
+
+// SortedKeys returns the keys of the map m in ascending order.
+// Go randomizes map iteration order, so workflow code must not range over a map when
+// the order affects decisions (scheduling activities, timers, child workflows, etc.).
+func SortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	return SortedKeysFunc(m, cmp.Less[K])
+}
+
+// SortedKeysFunc returns the keys of the map m ordered by less. Use it for key types that
+// are not ordered, e.g. structs. less must define a strict weak ordering on all keys of m,
+// otherwise the order is not deterministic.
+func SortedKeysFunc[K comparable, V any](m map[K]V, less func(a, b K) bool) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return less(keys[i], keys[j])
+	})
+	return keys
+}
+
+// SortedRange calls fn for each key and value of the map m in ascending key order.
+// Iteration stops if fn returns false. It is the replay-safe replacement of
+//
+//	for k, v := range m {
+//	  ...
+//	}
+//
+// in workflow code.
+func SortedRange[K cmp.Ordered, V any](m map[K]V, fn func(key K, value V) bool) {
+	SortedRangeFunc(m, cmp.Less[K], fn)
+}
+
+// SortedRangeFunc calls fn for each key and value of the map m in the key order defined by less.
+// Iteration stops if fn returns false.
+func SortedRangeFunc[K comparable, V any](m map[K]V, less func(a, b K) bool, fn func(key K, value V) bool) {
+	for _, k := range SortedKeysFunc(m, less) {
+		if !fn(k, m[k]) {
+			return
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedKeys(t *testing.T) {
+	m := map[string]int{"c": 3, "a": 1, "b": 2, "d": 4}
+	for i := 0; i < 10; i++ {
+		require.Equal(t, []string{"a", "b", "c", "d"}, SortedKeys(m))
+	}
+	require.Empty(t, SortedKeys(map[int]bool{}))
+}
+
+func TestSortedKeysFunc(t *testing.T) {
+	type key struct {
+		group string
+		id    int
+	}
+	m := map[key]string{
+		{"b", 1}: "b1",
+		{"a", 2}: "a2",
+		{"a", 1}: "a1",
+	}
+	keys := SortedKeysFunc(m, func(x, y key) bool {
+		if x.group != y.group {
+			return x.group < y.group
+		}
+		return x.id < y.id
+	})
+	require.Equal(t, []key{{"a", 1}, {"a", 2}, {"b", 1}}, keys)
+}
+
+func TestSortedRange(t *testing.T) {
+	m := map[int]string{3: "c", 1: "a", 2: "b"}
+
+	var values []string
+	SortedRange(m, func(_ int, v string) bool {
+		values = append(values, v)
+		return true
+	})
+	require.Equal(t, []string{"a", "b", "c"}, values)
+
+	values = nil
+	SortedRange(m, func(k int, v string) bool {
+		values = append(values, v)
+		return k < 2
+	})
+	require.Equal(t, []string{"a", "b"}, values)
+}
@@ -47,6 +47,14 @@ type (
 		scope    tally.Scope
 		ctxProps []ContextPropagator
 		header   *shared.Header
+
+		// defaults of the environments created by the suite
+		workerOptions        *WorkerOptions
+		workflowTimeout      time.Duration
+		testTimeout          time.Duration
+		taskList             string
+		dataConverter        DataConverter
+		workflowInterceptors []WorkflowInterceptorFactory
 	}
 
 	// TestWorkflowEnvironment is the environment that you use to test workflow
@@ -90,16 +98,31 @@ func (b EncodedValues) HasValues() bool {
 	return b.values != nil
 }
 
+// Size returns the number of bytes of the encoded values
+func (b EncodedValues) Size() int {
+	return len(b.values)
+}
+
+// TypeHints returns the types of the encoded values written by the DataConverter, if any
+func (b EncodedValues) TypeHints() []string {
+	hints, _ := splitTypeHints(b.values)
+	return hints
+}
+
 // NewTestWorkflowEnvironment creates a new instance of TestWorkflowEnvironment. Use the returned TestWorkflowEnvironment
 // to run your workflow in the test environment.
 func (s *WorkflowTestSuite) NewTestWorkflowEnvironment() *TestWorkflowEnvironment {
-	return &TestWorkflowEnvironment{impl: newTestWorkflowEnvironmentImpl(s, nil)}
+	env := &TestWorkflowEnvironment{impl: newTestWorkflowEnvironmentImpl(s, nil)}
+	s.setEnvironmentDefaults(env.impl)
+	return env
 }
 
 // NewTestActivityEnvironment creates a new instance of TestActivityEnvironment. Use the returned TestActivityEnvironment
 // to run your activity in the test environment.
 func (s *WorkflowTestSuite) NewTestActivityEnvironment() *TestActivityEnvironment {
-	return &TestActivityEnvironment{impl: newTestWorkflowEnvironmentImpl(s, nil)}
+	env := &TestActivityEnvironment{impl: newTestWorkflowEnvironmentImpl(s, nil)}
+	s.setEnvironmentDefaults(env.impl)
+	return env
 }
 
 // SetLogger sets the logger for this WorkflowTestSuite. If you don't set logger, test suite will create a default logger
@@ -131,6 +154,64 @@ func (s *WorkflowTestSuite) SetHeader(header *shared.Header) {
 	s.header = header
 }
 
+// SetWorkerOptions sets the WorkerOptions of the environments created by this WorkflowTestSuite, see
+// TestWorkflowEnvironment.SetWorkerOptions for the options which are used. Options set on an environment override them.
+// Note: WorkerOptions is defined in internal package, use public type worker.Options instead.
+func (s *WorkflowTestSuite) SetWorkerOptions(options WorkerOptions) {
+	s.workerOptions = &options
+}
+
+// SetWorkflowTimeout sets the execution timeout of the workflows tested by the environments created by this
+// WorkflowTestSuite, see TestWorkflowEnvironment.SetWorkflowTimeout.
+func (s *WorkflowTestSuite) SetWorkflowTimeout(executionTimeout time.Duration) {
+	s.workflowTimeout = executionTimeout
+}
+
+// SetTestTimeout sets the wall clock idle timeout of the environments created by this WorkflowTestSuite, see
+// TestWorkflowEnvironment.SetTestTimeout. If you don't set it, the idle timeout is 3 seconds.
+func (s *WorkflowTestSuite) SetTestTimeout(idleTimeout time.Duration) {
+	s.testTimeout = idleTimeout
+}
+
+// SetTaskList sets the task list of the workflows tested by the environments created by this WorkflowTestSuite. If you
+// don't set it, the task list is "default-test-tasklist".
+func (s *WorkflowTestSuite) SetTaskList(taskList string) {
+	s.taskList = taskList
+}
+
+// SetDataConverter sets the data converter of the environments created by this WorkflowTestSuite. It overrides the
+// DataConverter of the options passed to SetWorkerOptions.
+func (s *WorkflowTestSuite) SetDataConverter(dataConverter DataConverter) {
+	s.dataConverter = dataConverter
+}
+
+// SetWorkflowInterceptors sets the workflow interceptors of the environments created by this WorkflowTestSuite. It
+// overrides the WorkflowInterceptorChainFactories of the options passed to SetWorkerOptions.
+func (s *WorkflowTestSuite) SetWorkflowInterceptors(interceptors []WorkflowInterceptorFactory) {
+	s.workflowInterceptors = interceptors
+}
+
+func (s *WorkflowTestSuite) setEnvironmentDefaults(env *testWorkflowEnvironmentImpl) {
+	if s.workerOptions != nil {
+		env.setWorkerOptions(*s.workerOptions)
+	}
+	if s.workflowTimeout > 0 {
+		env.setWorkflowTimeout(s.workflowTimeout)
+	}
+	if s.testTimeout > 0 {
+		env.testTimeout = s.testTimeout
+	}
+	if s.taskList != "" {
+		env.workflowInfo.TaskListName = s.taskList
+	}
+	if s.dataConverter != nil {
+		env.workerOptions.DataConverter = s.dataConverter
+	}
+	if len(s.workflowInterceptors) > 0 {
+		env.workflowInterceptors = s.workflowInterceptors
+	}
+}
+
 // RegisterActivity registers activity implementation with TestWorkflowEnvironment
 func (t *TestActivityEnvironment) RegisterActivity(a interface{}) {
 	t.impl.RegisterActivity(a)
@@ -158,7 +239,8 @@ func (t *TestActivityEnvironment) ExecuteLocalActivity(activityFn interface{}, a
 }
 
 // SetWorkerOptions sets the WorkerOptions that will be use by TestActivityEnvironment. TestActivityEnvironment will
-// use options of Identity, MetricsScope and BackgroundActivityContext on the WorkerOptions. Other options are ignored.
+// use options of Identity, MetricsScope, BackgroundActivityContext and ActivityClient on the WorkerOptions. Other
+// options are ignored.
 // Note: WorkerOptions is defined in internal package, use public type worker.Options instead.
 func (t *TestActivityEnvironment) SetWorkerOptions(options WorkerOptions) *TestActivityEnvironment {
 	t.impl.setWorkerOptions(options)
@@ -177,6 +259,12 @@ func (t *TestActivityEnvironment) SetHeartbeatDetails(details interface{}) {
 	t.impl.setHeartbeatDetails(details)
 }
 
+// SetTestClient sets the client returned by activity.GetClient(context), e.g. a mocks.Client, so activities
+// signaling or starting workflows can be tested without a server.
+func (t *TestActivityEnvironment) SetTestClient(c Client) {
+	t.impl.testClient = c
+}
+
 // SetWorkerStopChannel sets the worker stop channel to be returned from activity.GetWorkerStopChannel(context)
 // To test your activity on worker stop, you can provide a go channel with this function and call ExecuteActivity().
 // Then call close(channel) to test the activity worker stop logic.
@@ -443,19 +531,42 @@ func (t *TestWorkflowEnvironment) ExecuteWorkflow(workflowFn interface{}, args .
 	t.impl.executeWorkflow(workflowFn, args...)
 }
 
+// ExecuteCronWorkflow executes runs consecutive runs of the workflow with the cron schedule set by
+// SetWorkflowCronSchedule, like SetWorkflowCronMaxIterations(runs - 1) followed by ExecuteWorkflow. The first run starts
+// immediately and each next one at the next time of the schedule after the previous run closed, moving the workflow
+// clock forward. Each run gets the result of the last successful run from GetLastCompletionResult, and
+// GetWorkflowResult and GetWorkflowError return the outcome of the last run.
+func (t *TestWorkflowEnvironment) ExecuteCronWorkflow(runs int, workflowFn interface{}, args ...interface{}) {
+	if t.impl.workflowInfo.CronSchedule == nil {
+		panic("ExecuteCronWorkflow requires a cron schedule, set it with SetWorkflowCronSchedule")
+	}
+	if runs < 1 {
+		panic(fmt.Sprintf("ExecuteCronWorkflow requires at least one run, got %v", runs))
+	}
+	t.impl.setCronMaxIterationas(runs - 1)
+	t.ExecuteWorkflow(workflowFn, args...)
+}
+
 // Now returns the current workflow time (a.k.a workflow.Now() time) of this TestWorkflowEnvironment.
 func (t *TestWorkflowEnvironment) Now() time.Time {
 	return t.impl.Now()
 }
 
 // SetWorkerOptions sets the WorkerOptions for TestWorkflowEnvironment. TestWorkflowEnvironment will use options set by
-// use options of Identity, MetricsScope and BackgroundActivityContext on the WorkerOptions. Other options are ignored.
+// use options of Identity, MetricsScope, BackgroundActivityContext and ActivityClient on the WorkerOptions. Other
+// options are ignored.
 // Note: WorkerOptions is defined in internal package, use public type worker.Options instead.
 func (t *TestWorkflowEnvironment) SetWorkerOptions(options WorkerOptions) *TestWorkflowEnvironment {
 	t.impl.setWorkerOptions(options)
 	return t
 }
 
+// SetTestClient sets the client returned by activity.GetClient(context) to the activities executed by the tested
+// workflow, e.g. a mocks.Client, so activities signaling or starting workflows can be tested without a server.
+func (t *TestWorkflowEnvironment) SetTestClient(c Client) {
+	t.impl.testClient = c
+}
+
 // SetWorkerStopChannel sets the activity worker stop channel to be returned from activity.GetWorkerStopChannel(context)
 // You can use this function to set the activity worker stop channel and use close(channel) to test your activity execution
 // from workflow execution.
@@ -477,8 +588,7 @@ func (t *TestWorkflowEnvironment) SetTestTimeout(idleTimeout time.Duration) *Tes
 // workflow execution timeout to return timeout error when the workflow mock clock is moved head of the timeout.
 // This is based on the workflow time (a.k.a workflow.Now() time).
 func (t *TestWorkflowEnvironment) SetWorkflowTimeout(executionTimeout time.Duration) *TestWorkflowEnvironment {
-	t.impl.executionTimeout = executionTimeout
-	t.impl.workflowInfo.ExecutionStartToCloseTimeoutSeconds = int32(executionTimeout.Seconds())
+	t.impl.setWorkflowTimeout(executionTimeout)
 	return t
 }
 
@@ -619,6 +729,15 @@ func (t *TestWorkflowEnvironment) GetWorkflowError() error {
 	return t.impl.testError
 }
 
+// GetProducedDecisions returns the decisions produced by the test workflow so far, grouped by decision task in the
+// order they were made. Decision tasks which produced no decision are left out. It complements the listeners, e.g.
+// SetOnActivityStartedListener, for libraries and interceptors which need to assert the exact decision output.
+// The decisions are reconstructed by the test environment: the attributes of RecordMarker decisions only carry the
+// marker name, and decisions of child workflows are not included.
+func (t *TestWorkflowEnvironment) GetProducedDecisions() [][]*shared.Decision {
+	return t.impl.getProducedDecisions()
+}
+
 // CompleteActivity complete an activity that had returned activity.ErrResultPending error
 func (t *TestWorkflowEnvironment) CompleteActivity(taskToken []byte, result interface{}, err error) error {
 	return t.impl.CompleteActivity(taskToken, result, err)
@@ -672,6 +791,22 @@ func (t *TestWorkflowEnvironment) SetLastCompletionResult(result interface{}) {
 	t.impl.setLastCompletionResult(result)
 }
 
+// SetLastError sets the error to be returned from workflow.GetLastError(), as if the previous run of the workflow
+// failed with it. Use cadence.NewCustomError to set an error with details.
+func (t *TestWorkflowEnvironment) SetLastError(err error) {
+	t.impl.setLastError(err)
+}
+
+// SetParentWorkflowExecution makes the tested workflow run as a child of the parent execution, which is returned as
+// ParentWorkflowExecution by GetWorkflowInfo with the domain of the tested workflow as ParentWorkflowDomain. Child
+// workflows started by the tested workflow always have it as their parent.
+func (t *TestWorkflowEnvironment) SetParentWorkflowExecution(parent WorkflowExecution) *TestWorkflowEnvironment {
+	domain := t.impl.workflowInfo.Domain
+	t.impl.workflowInfo.ParentWorkflowDomain = &domain
+	t.impl.workflowInfo.ParentWorkflowExecution = &parent
+	return t
+}
+
 // SetMemoOnStart sets the memo when start workflow.
 func (t *TestWorkflowEnvironment) SetMemoOnStart(memo map[string]interface{}) error {
 	memoStruct, err := getWorkflowMemo(memo, t.impl.GetDataConverter())
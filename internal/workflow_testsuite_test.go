@@ -23,11 +23,14 @@ package internal
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/internal/common/testlogger"
 )
 
 func TestSetWorkflowTimeout(t *testing.T) {
@@ -44,6 +47,107 @@ func TestSetWorkflowTimeout(t *testing.T) {
 	require.Equal(t, executionTimeout, result.impl.executionTimeout)
 }
 
+func TestWorkflowTestSuiteDefaults(t *testing.T) {
+	t.Parallel()
+	s := WorkflowTestSuite{}
+	s.SetLogger(testlogger.NewZap(t))
+	tracer := tracingInterceptorFactory{}
+	dataConverter := newTestDataConverter()
+	s.SetWorkerOptions(WorkerOptions{Identity: "suite-identity", MaxHeartbeatDetailsSize: 1024})
+	s.SetWorkflowTimeout(time.Hour)
+	s.SetTestTimeout(time.Minute)
+	s.SetTaskList("suite-tasklist")
+	s.SetDataConverter(dataConverter)
+	s.SetWorkflowInterceptors([]WorkflowInterceptorFactory{&tracer})
+
+	for i := 0; i < 2; i++ {
+		env := s.NewTestWorkflowEnvironment()
+		require.Equal(t, "suite-identity", env.impl.workerOptions.Identity)
+		require.Equal(t, time.Minute, env.impl.testTimeout)
+		require.Equal(t, dataConverter, env.impl.GetDataConverter())
+
+		workflowFn := func(ctx Context) (string, error) {
+			info := GetWorkflowInfo(ctx)
+			return fmt.Sprintf("%v %v", info.TaskListName, info.ExecutionStartToCloseTimeoutSeconds), nil
+		}
+		env.RegisterWorkflow(workflowFn)
+		env.ExecuteWorkflow(workflowFn)
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		var result string
+		require.NoError(t, env.GetWorkflowResult(&result))
+		require.Equal(t, "suite-tasklist 3600", result)
+		require.Len(t, tracer.instances, i+1)
+	}
+
+	// options set on an environment override the defaults
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{Identity: "env-identity"})
+	env.SetWorkflowTimeout(time.Minute)
+	require.Equal(t, "env-identity", env.impl.workerOptions.Identity)
+	require.Equal(t, 1024, env.impl.workerOptions.MaxHeartbeatDetailsSize, "unset options keep the defaults")
+	require.Equal(t, time.Minute, env.impl.executionTimeout)
+	require.Equal(t, []WorkflowInterceptorFactory{&tracer}, env.impl.workflowInterceptors)
+}
+
+func parentInfoTestWorkflow(ctx Context) (string, error) {
+	info := GetWorkflowInfo(ctx)
+	if info.ParentWorkflowExecution == nil {
+		return "root", nil
+	}
+	var memo string
+	if info.Memo != nil {
+		if err := NewValue(info.Memo.Fields["key"]).Get(&memo); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("child of %v/%v/%v %v %v", *info.ParentWorkflowDomain, info.ParentWorkflowExecution.ID,
+		info.ParentWorkflowExecution.RunID, memo, info.RetryPolicy != nil), nil
+}
+
+func TestSetParentWorkflowExecution(t *testing.T) {
+	t.Parallel()
+	env := newTestWorkflowEnv(t)
+	env.RegisterWorkflow(parentInfoTestWorkflow)
+	env.ExecuteWorkflow(parentInfoTestWorkflow)
+	var result string
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, "root", result)
+
+	env = newTestWorkflowEnv(t)
+	env.RegisterWorkflow(parentInfoTestWorkflow)
+	require.Equal(t, env, env.SetParentWorkflowExecution(WorkflowExecution{ID: "parent-id", RunID: "parent-run-id"}))
+	env.ExecuteWorkflow(parentInfoTestWorkflow)
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, "child of default-test-domain/parent-id/parent-run-id  false", result)
+}
+
+func TestChildWorkflowInfo(t *testing.T) {
+	t.Parallel()
+	env := newTestWorkflowEnv(t)
+	env.RegisterWorkflow(parentInfoTestWorkflow)
+	parentFn := func(ctx Context) (string, error) {
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{
+			ExecutionStartToCloseTimeout: time.Minute,
+			Memo:                         map[string]interface{}{"key": "memo"},
+			RetryPolicy: &RetryPolicy{
+				InitialInterval:          time.Second,
+				BackoffCoefficient:       2,
+				ExpirationInterval:       time.Minute,
+				NonRetriableErrorReasons: []string{"bad"},
+			},
+		})
+		var result string
+		err := ExecuteChildWorkflow(ctx, parentInfoTestWorkflow).Get(ctx, &result)
+		return result, err
+	}
+	env.RegisterWorkflow(parentFn)
+	env.ExecuteWorkflow(parentFn)
+	var result string
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, "child of default-test-domain/default-test-workflow-id/default-test-run-id memo true", result)
+}
+
 func TestSetMemoOnStart(t *testing.T) {
 	t.Parallel()
 	env := newTestWorkflowEnv(t)
@@ -0,0 +1,55 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cadence
+
+import "go.uber.org/cadence/internal"
+
+type (
+	// LazyValue is the manifest of a large value written to a BlobSink in chunks by an activity, typically with
+	// activity.NewChunkWriter or activity.EncodeLazyValue. Activities return it instead of a value that would exceed
+	// the payload limits, and workflows pass it to the activities consuming the value, which read it with its Open,
+	// Bytes or Decode methods:
+	//
+	//	func ExportActivity(ctx context.Context) (cadence.LazyValue, error) {
+	//		w := activity.NewChunkWriter(ctx, sink, activity.ChunkWriterOptions{})
+	//		if err := export(ctx, w); err != nil {
+	//			return cadence.LazyValue{}, err
+	//		}
+	//		return w.Close()
+	//	}
+	//
+	//	func UploadActivity(ctx context.Context, export cadence.LazyValue) error {
+	//		return upload(ctx, export.Open(ctx, sink), export.Size)
+	//	}
+	//
+	// Workflows must not read a LazyValue, as reading the BlobSink is not deterministic.
+	LazyValue = internal.LazyValue
+
+	// BlobSink stores the chunks of a LazyValue outside of the workflow history. Data written for a key must never
+	// change.
+	BlobSink = internal.BlobSink
+
+	// BlobChunk references a chunk of a LazyValue stored in a BlobSink.
+	BlobChunk = internal.BlobChunk
+)
+
+// ErrBlobChecksumMismatch is returned when reading a chunk of a LazyValue which does not match its checksum.
+var ErrBlobChecksumMismatch = internal.ErrBlobChecksumMismatch
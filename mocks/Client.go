@@ -774,6 +774,39 @@ func (_m *Client) StartWorkflowAsync(ctx context.Context, options internal.Start
 	return r0, r1
 }
 
+// StartWorkflowValidated provides a mock function with given fields: ctx, options, workflow, args
+func (_m *Client) StartWorkflowValidated(ctx context.Context, options internal.StartWorkflowOptions, workflow interface{}, args ...interface{}) (*internal.WorkflowExecution, error) {
+	var _ca []interface{}
+	_ca = append(_ca, ctx, options, workflow)
+	_ca = append(_ca, args...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StartWorkflowValidated")
+	}
+
+	var r0 *internal.WorkflowExecution
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, internal.StartWorkflowOptions, interface{}, ...interface{}) (*internal.WorkflowExecution, error)); ok {
+		return rf(ctx, options, workflow, args...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, internal.StartWorkflowOptions, interface{}, ...interface{}) *internal.WorkflowExecution); ok {
+		r0 = rf(ctx, options, workflow, args...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*internal.WorkflowExecution)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, internal.StartWorkflowOptions, interface{}, ...interface{}) error); ok {
+		r1 = rf(ctx, options, workflow, args...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // TerminateWorkflow provides a mock function with given fields: ctx, workflowID, runID, reason, details
 func (_m *Client) TerminateWorkflow(ctx context.Context, workflowID string, runID string, reason string, details []byte) error {
 	ret := _m.Called(ctx, workflowID, runID, reason, details)
@@ -0,0 +1,129 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+
+	internal "go.uber.org/cadence/internal"
+)
+
+// Worker is an autogenerated mock type for the Worker type
+type Worker struct {
+	mock.Mock
+}
+
+// GetRegisteredActivities provides a mock function with no fields
+func (_m *Worker) GetRegisteredActivities() []internal.RegistryActivityInfo {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRegisteredActivities")
+	}
+
+	var r0 []internal.RegistryActivityInfo
+	if rf, ok := ret.Get(0).(func() []internal.RegistryActivityInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]internal.RegistryActivityInfo)
+		}
+	}
+
+	return r0
+}
+
+// GetRegisteredWorkflows provides a mock function with no fields
+func (_m *Worker) GetRegisteredWorkflows() []internal.RegistryWorkflowInfo {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRegisteredWorkflows")
+	}
+
+	var r0 []internal.RegistryWorkflowInfo
+	if rf, ok := ret.Get(0).(func() []internal.RegistryWorkflowInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]internal.RegistryWorkflowInfo)
+		}
+	}
+
+	return r0
+}
+
+// RegisterActivity provides a mock function with given fields: a
+func (_m *Worker) RegisterActivity(a interface{}) {
+	_m.Called(a)
+}
+
+// RegisterActivityWithOptions provides a mock function with given fields: a, options
+func (_m *Worker) RegisterActivityWithOptions(a interface{}, options internal.RegisterActivityOptions) {
+	_m.Called(a, options)
+}
+
+// RegisterWorkflow provides a mock function with given fields: w
+func (_m *Worker) RegisterWorkflow(w interface{}) {
+	_m.Called(w)
+}
+
+// RegisterWorkflowWithOptions provides a mock function with given fields: w, options
+func (_m *Worker) RegisterWorkflowWithOptions(w interface{}, options internal.RegisterWorkflowOptions) {
+	_m.Called(w, options)
+}
+
+// Run provides a mock function with no fields
+func (_m *Worker) Run() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Run")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Start provides a mock function with no fields
+func (_m *Worker) Start() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Start")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Stop provides a mock function with no fields
+func (_m *Worker) Stop() {
+	_m.Called()
+}
+
+// NewWorker creates a new instance of Worker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewWorker(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Worker {
+	mock := &Worker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
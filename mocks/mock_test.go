@@ -30,6 +30,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
 	"go.uber.org/cadence/workflow"
 )
 
@@ -97,3 +98,17 @@ func Test_MockClient(t *testing.T) {
 	require.NotNil(t, next)
 	require.NoError(t, err)
 }
+
+func Test_MockWorker(t *testing.T) {
+	mockWorker := NewWorker(t)
+	workflowFn := func(ctx workflow.Context) error { return nil }
+
+	mockWorker.On("RegisterWorkflowWithOptions", mock.Anything, workflow.RegisterOptions{Name: "wf"}).Once()
+	mockWorker.On("Start").Return(nil).Once()
+	mockWorker.On("Stop").Once()
+
+	var w worker.Worker = mockWorker
+	w.RegisterWorkflowWithOptions(workflowFn, workflow.RegisterOptions{Name: "wf"})
+	require.NoError(t, w.Start())
+	w.Stop()
+}
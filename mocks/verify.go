@@ -23,6 +23,7 @@ package mocks
 import (
 	"go.uber.org/cadence/client"
 	"go.uber.org/cadence/encoded"
+	"go.uber.org/cadence/worker"
 )
 
 // make sure mocks are in sync with interfaces
@@ -31,3 +32,4 @@ var _ client.DomainClient = (*DomainClient)(nil)
 var _ client.HistoryEventIterator = (*HistoryEventIterator)(nil)
 var _ encoded.Value = (*Value)(nil)
 var _ client.WorkflowRun = (*WorkflowRun)(nil)
+var _ worker.Worker = (*Worker)(nil)
@@ -0,0 +1,37 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cadence
+
+import "go.uber.org/cadence/internal"
+
+// RawPayload is passed through untouched by the default DataConverter in either direction, e.g. to exchange bytes
+// already encoded by another system as protobuf without encoding them again as base64 JSON:
+//
+//	func ForwardActivity(ctx context.Context, event cadence.RawPayload) (cadence.RawPayload, error) {
+//		return publish(ctx, []byte(event))
+//	}
+//
+//	workflow.ExecuteActivity(ctx, ForwardActivity, cadence.RawPayload(protoBytes))
+//
+// It must be the only argument or result, as its bytes cannot be delimited from the encoded values around it: the
+// default DataConverter fails to encode or decode a RawPayload among other values. Custom DataConverters handle it as
+// a []byte unless they special case it.
+type RawPayload = internal.RawPayload
@@ -0,0 +1,76 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package retry contains retry policy presets and validation helpers for activities, child workflows and workflows.
+//
+// The presets return a new policy on every call, so they can be tweaked without affecting other callers:
+//
+//	policy := retry.StandardBackoff()
+//	policy.NonRetriableErrorReasons = []string{"bad-input"}
+//	ctx = workflow.WithRetryPolicy(ctx, *policy)
+package retry
+
+import (
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/internal"
+)
+
+// Quick retries a few times in quick succession. It fits short, idempotent calls
+// where failures are expected to be transient, e.g. a flaky network request.
+func Quick() *cadence.RetryPolicy {
+	return &cadence.RetryPolicy{
+		InitialInterval:    time.Second,
+		BackoffCoefficient: 2,
+		MaximumInterval:    10 * time.Second,
+		MaximumAttempts:    5,
+		ExpirationInterval: time.Minute,
+	}
+}
+
+// StandardBackoff retries with exponential backoff for up to an hour.
+// It is a reasonable default for most activities.
+func StandardBackoff() *cadence.RetryPolicy {
+	return &cadence.RetryPolicy{
+		InitialInterval:    time.Second,
+		BackoffCoefficient: 2,
+		MaximumInterval:    2 * time.Minute,
+		ExpirationInterval: time.Hour,
+	}
+}
+
+// LongHaul keeps retrying with a slow backoff for up to a day. It fits calls to
+// dependencies that may be unavailable for a long time, e.g. during an outage.
+func LongHaul() *cadence.RetryPolicy {
+	return &cadence.RetryPolicy{
+		InitialInterval:    10 * time.Second,
+		BackoffCoefficient: 2,
+		MaximumInterval:    10 * time.Minute,
+		ExpirationInterval: 24 * time.Hour,
+	}
+}
+
+// Validate returns an error explaining why the retry policy is invalid, e.g. when the
+// expiration interval is shorter than the initial interval or the backoff coefficient is less than 1.
+// A nil policy is valid and means no retry.
+func Validate(policy *cadence.RetryPolicy) error {
+	return internal.ValidateRetryPolicy(policy)
+}
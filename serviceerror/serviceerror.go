@@ -0,0 +1,87 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package serviceerror contains the errors returned by the Cadence service, independent of the transport.
+//
+// With client.FeatureFlags.ServiceErrorsEnabled, the clients return these types instead of the thrift errors of
+// go.uber.org/cadence/.gen/go/shared, both with gRPC and TChannel:
+//
+//	_, err := cadenceClient.StartWorkflow(ctx, options, OrderWorkflow, order)
+//	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+//	if errors.As(err, &alreadyStarted) {
+//		runID = alreadyStarted.RunID
+//	}
+//
+//	var busy *serviceerror.ServiceBusy
+//	if errors.As(err, &busy) {
+//		time.Sleep(busy.RetryAfter)
+//	}
+//
+// The errors unwrap to the thrift errors, so errors.As with the thrift types keeps working while callers migrate.
+package serviceerror
+
+import (
+	internal "go.uber.org/cadence/internal/common/serviceerror"
+)
+
+type (
+	// EntityNotExists is returned when the domain, workflow execution or activity of the request does not exist.
+	EntityNotExists = internal.EntityNotExists
+
+	// DomainNotActive is returned when the domain is not active in the cluster the request was sent to.
+	DomainNotActive = internal.DomainNotActive
+
+	// LimitExceeded is returned when a limit of the domain or workflow execution is exceeded, e.g. the number of
+	// pending activities.
+	LimitExceeded = internal.LimitExceeded
+
+	// ServiceBusy is returned when the server rate limits the request or is overloaded, retry after RetryAfter.
+	ServiceBusy = internal.ServiceBusy
+
+	// BadRequest is returned when the request is invalid.
+	BadRequest = internal.BadRequest
+
+	// AccessDenied is returned when the caller is not authorized to make the request.
+	AccessDenied = internal.AccessDenied
+
+	// InternalService is returned when the request failed because of an internal error of the server.
+	InternalService = internal.InternalService
+
+	// WorkflowExecutionAlreadyStarted is returned when starting a workflow whose ID is already used.
+	WorkflowExecutionAlreadyStarted = internal.WorkflowExecutionAlreadyStarted
+
+	// WorkflowExecutionAlreadyCompleted is returned when the workflow execution of the request is closed.
+	WorkflowExecutionAlreadyCompleted = internal.WorkflowExecutionAlreadyCompleted
+
+	// DomainAlreadyExists is returned when registering a domain which already exists.
+	DomainAlreadyExists = internal.DomainAlreadyExists
+
+	// CancellationAlreadyRequested is returned when requesting the cancellation of a workflow execution twice.
+	CancellationAlreadyRequested = internal.CancellationAlreadyRequested
+
+	// QueryFailed is returned when the query failed in the workflow.
+	QueryFailed = internal.QueryFailed
+)
+
+// Convert returns the error of a service call as one of the types of this package, e.g. for errors returned by
+// workflowserviceclient.Interface directly. Other errors are returned unchanged.
+func Convert(err error) error {
+	return internal.Convert(err)
+}
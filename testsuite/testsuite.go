@@ -37,7 +37,32 @@ type (
 
 	// MockCallWrapper is a wrapper to mock.Call. It offers the ability to wait on workflow's clock instead of wall clock.
 	MockCallWrapper = internal.MockCallWrapper
+
+	// FuzzOptions configures WorkflowTestSuite.FuzzWorkflow.
+	FuzzOptions = internal.FuzzOptions
+
+	// FuzzSignal is a signal sent by WorkflowTestSuite.FuzzWorkflow.
+	FuzzSignal = internal.FuzzSignal
+
+	// FuzzReport is the result of WorkflowTestSuite.FuzzWorkflow.
+	FuzzReport = internal.FuzzReport
+
+	// FuzzFailure is an iteration of WorkflowTestSuite.FuzzWorkflow which failed.
+	FuzzFailure = internal.FuzzFailure
+
+	// WorkflowCoverage reports the GetVersion branches and selector arms exercised by test workflows.
+	WorkflowCoverage = internal.WorkflowCoverage
+
+	// VersionCoverage is the coverage of the versions of a GetVersion change ID.
+	VersionCoverage = internal.VersionCoverage
+
+	// SelectorArmCoverage is the coverage of a case added to selectors.
+	SelectorArmCoverage = internal.SelectorArmCoverage
 )
 
 // ErrMockStartChildWorkflowFailed is special error used to indicate the mocked child workflow should fail to start.
 var ErrMockStartChildWorkflowFailed = internal.ErrMockStartChildWorkflowFailed
+
+// UpdateGoldenEnv is the environment variable which, set to true, makes TestWorkflowEnvironment.AssertDecisionsGolden
+// write the golden files instead of comparing them.
+const UpdateGoldenEnv = internal.UpdateGoldenEnv
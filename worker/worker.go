@@ -19,12 +19,16 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
+// when adding any, make sure you update the files that it checks in the makefile
+//go:generate mockery --srcpkg . --name Worker --output ../mocks
+
 // Package worker contains functions to manage lifecycle of a Cadence client side worker.
 package worker
 
 import (
 	"context"
 	"io"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -51,6 +55,43 @@ type (
 		Stop()
 	}
 
+	// MetricsProvider is implemented by the workers returned by New and NewV2. It is not part of Worker, so that
+	// the existing implementations of Worker keep compiling:
+	//
+	//	if provider, ok := w.(worker.MetricsProvider); ok {
+	//		metrics := provider.Metrics()
+	//	}
+	MetricsProvider interface {
+		// Metrics returns a point in time snapshot of the worker pollers, task slots,
+		// sticky cache size and decision task schedule to start latency.
+		Metrics() Metrics
+	}
+
+	// Validator is implemented by the workers returned by New and NewV2. It is not part of Worker, so that the
+	// existing implementations of Worker keep compiling.
+	Validator interface {
+		// Validate checks the configuration of the worker against the server without polling any task, so
+		// misconfigurations fail at deploy time rather than once tasks are processed. It checks the domain exists,
+		// access to it is authorized, the task lists of the registered workflows and activities can be described,
+		// the search attributes of WorkerOptions.UpsertedSearchAttributes are registered, and the server clock is not
+		// ahead of the local clock. The returned error describes the failed checks, it is nil if none failed.
+		//
+		//	report, err := w.(worker.Validator).Validate(ctx)
+		//	if err != nil {
+		//		log.Fatal(err)
+		//	}
+		Validate(ctx context.Context) (*ValidationReport, error)
+	}
+
+	// CapabilitiesProvider is implemented by the workers returned by New and NewV2. It is not part of Worker, so that
+	// the existing implementations of Worker keep compiling.
+	CapabilitiesProvider interface {
+		// Capabilities returns the capabilities of the server detected when the worker started, when
+		// Options.EnableCapabilitiesNegotiation is set. The options the server does not support, e.g. sticky
+		// execution, are disabled on the worker with a warning.
+		Capabilities() Capabilities
+	}
+
 	// Registry exposes registration functions to consumers.
 	Registry interface {
 		WorkflowRegistry
@@ -207,6 +248,28 @@ type (
 		ReplayPartialWorkflowHistoryFromJSON(logger *zap.Logger, reader io.Reader, lastEventID int64) error
 	}
 
+	// EncodedHistoryReplayer is implemented by the WorkflowReplayer returned by NewWorkflowReplayer. It is not part
+	// of WorkflowReplayer, so that the existing implementations of WorkflowReplayer keep compiling:
+	//
+	//	replayer := worker.NewWorkflowReplayer().(worker.EncodedHistoryReplayer)
+	EncodedHistoryReplayer interface {
+		// ReplayWorkflowHistoryFromReader executes a single decision task for the given history. The encoding of the
+		// history is detected automatically: json as downloaded from the cli, or a thriftrw or protobuf encoded
+		// History as found in archived or exported history blobs.
+		// The logger is an optional parameter. Defaults to the noop logger.
+		ReplayWorkflowHistoryFromReader(logger *zap.Logger, reader io.Reader) error
+
+		// ReplayPartialWorkflowHistoryFromReader executes a single decision task for the given history up to provided
+		// lastEventID(inclusive). See ReplayWorkflowHistoryFromReader for the supported encodings.
+		// The logger is an optional parameter. Defaults to the noop logger.
+		ReplayPartialWorkflowHistoryFromReader(logger *zap.Logger, reader io.Reader, lastEventID int64) error
+
+		// ReplayWorkflowHistoryFromFile executes a single decision task for the given history file.
+		// See ReplayWorkflowHistoryFromReader for the supported encodings.
+		// The logger is an optional parameter. Defaults to the noop logger.
+		ReplayWorkflowHistoryFromFile(logger *zap.Logger, fileName string) error
+	}
+
 	// WorkflowShadower retrieves and replays workflow history from Cadence service to determine if there's any nondeterministic changes in the workflow definition
 	WorkflowShadower interface {
 		WorkflowRegistry
@@ -217,6 +280,9 @@ type (
 	// Options is used to configure a worker instance.
 	Options = internal.WorkerOptions
 
+	// MetricsOptions configures the prefix, tags and sanitizer applied to the worker metrics scope.
+	MetricsOptions = internal.MetricsOptions
+
 	// ShadowOptions is used to configure a WorkflowShadower.
 	ShadowOptions = internal.ShadowOptions
 	// AutoScalerOptions is used to configure the auto scaler.
@@ -233,10 +299,102 @@ type (
 	// ReplayOptions is used to configure the replay decision task worker.
 	ReplayOptions = internal.ReplayOptions
 
+	// HistoryDiff is the structured result of comparing two histories, or replay decisions with a recorded history.
+	// Use HistoryDiff.String() to render it for humans.
+	HistoryDiff = internal.HistoryDiff
+	// HistoryDiffEntry is a single aligned line of a HistoryDiff.
+	HistoryDiffEntry = internal.HistoryDiffEntry
+	// HistoryDiffType describes how a single HistoryDiffEntry differs between the two inputs.
+	HistoryDiffType = internal.HistoryDiffType
+
 	// NonDeterministicWorkflowPolicy is an enum for configuring how client's decision task handler deals with
 	// mismatched history events (presumably arising from non-deterministic workflow definitions).
 	NonDeterministicWorkflowPolicy = internal.NonDeterministicWorkflowPolicy
 
+	// AutoResetOptions configures automatic reset of workflows whose decision tasks keep failing.
+	AutoResetOptions = internal.AutoResetOptions
+	// DecisionTaskFailureInfo describes a failed decision task for a DecisionTaskFailureClassifier.
+	DecisionTaskFailureInfo = internal.DecisionTaskFailureInfo
+	// DecisionTaskFailureClassifier classifies failed decision tasks, see AutoResetOptions.
+	DecisionTaskFailureClassifier = internal.DecisionTaskFailureClassifier
+	// DecisionTaskFailureAction is returned by a DecisionTaskFailureClassifier.
+	DecisionTaskFailureAction = internal.DecisionTaskFailureAction
+
+	// DecisionTaskNearTimeoutInfo describes a decision task passed to Options.OnDecisionTaskNearTimeout.
+	DecisionTaskNearTimeoutInfo = internal.DecisionTaskNearTimeoutInfo
+
+	// DecisionTaskWatchdogOptions configures the dump of the goroutine stacks of decision tasks close to their
+	// timeout, see Options.DecisionTaskWatchdog.
+	DecisionTaskWatchdogOptions = internal.DecisionTaskWatchdogOptions
+
+	// ThrottleInfo describes a service call rejected because of rate limiting or overload, passed to
+	// Options.OnThrottle.
+	ThrottleInfo = internal.ThrottleInfo
+
+	// WorkflowFilter decides which workflows a worker processes decision tasks for, see Options.WorkflowFilter.
+	WorkflowFilter = internal.WorkflowFilter
+	// WorkflowFilterRules matches workflows by type or workflow ID.
+	WorkflowFilterRules = internal.WorkflowFilterRules
+
+	// DecisionCircuitBreaker stops processing decision tasks of workflow types which keep failing, see
+	// Options.DecisionCircuitBreaker.
+	DecisionCircuitBreaker = internal.DecisionCircuitBreaker
+	// DecisionCircuitBreakerOptions configures a DecisionCircuitBreaker.
+	DecisionCircuitBreakerOptions = internal.DecisionCircuitBreakerOptions
+
+	// HistoryGrowthDetector flags the executions whose history grows faster than a rate, see
+	// Options.HistoryGrowthDetector.
+	HistoryGrowthDetector = internal.HistoryGrowthDetector
+	// HistoryGrowthDetectorOptions configures a HistoryGrowthDetector.
+	HistoryGrowthDetectorOptions = internal.HistoryGrowthDetectorOptions
+	// HistoryGrowthReport describes an execution flagged by a HistoryGrowthDetector.
+	HistoryGrowthReport = internal.HistoryGrowthReport
+
+	// WorkerAdmin changes settings of a running worker and audit logs the changes, see Options.WorkerAdmin.
+	WorkerAdmin = internal.WorkerAdmin
+	// WorkerAdminOptions configures a WorkerAdmin.
+	WorkerAdminOptions = internal.WorkerAdminOptions
+	// WorkerSettings are the settings of a worker which can be changed by a WorkerAdmin.
+	WorkerSettings = internal.WorkerSettings
+
+	// AdmissionControlOptions configures pausing activity polling while the worker is short on memory or CPU.
+	AdmissionControlOptions = internal.AdmissionControlOptions
+	// ResourceMonitor reports the resource usage of the worker process, see AdmissionControlOptions.
+	ResourceMonitor = internal.ResourceMonitor
+	// ResourceUsage is the fraction of the available memory and CPU in use.
+	ResourceUsage = internal.ResourceUsage
+
+	// SlotPool is a pool of activity execution slots shared by the workers of a process, see
+	// Options.ActivitySlotPool.
+	SlotPool = internal.SlotPool
+
+	// ActivityFairnessOptions configures the fair scheduling of activity executions between the tenants of a task
+	// list, see Options.ActivityFairness.
+	ActivityFairnessOptions = internal.ActivityFairnessOptions
+	// ActivityTenantInfo is the information of an activity task passed to ActivityFairnessOptions.TenantKey.
+	ActivityTenantInfo = internal.ActivityTenantInfo
+
+	// WorkflowPanicArgsOptions configures the capture of the arguments of panicking workflows, see
+	// Options.WorkflowPanicArgs.
+	WorkflowPanicArgsOptions = internal.WorkflowPanicArgsOptions
+	// Redactor hides the sensitive data of values reported for debugging, e.g. the arguments of a panicking workflow.
+	Redactor = internal.Redactor
+
+	// Metrics is a point in time snapshot of the worker state returned by MetricsProvider.Metrics().
+	Metrics = internal.WorkerMetrics
+	// TaskMetrics is a point in time snapshot of the pollers and task slots of one task type.
+	TaskMetrics = internal.WorkerTaskMetrics
+
+	// ValidationReport is returned by Validator.Validate.
+	ValidationReport = internal.WorkerValidationReport
+	// ValidationCheck is the result of one check run by Validator.Validate.
+	ValidationCheck = internal.WorkerValidationCheck
+	// ValidationStatus is the result of a check run by Validator.Validate.
+	ValidationStatus = internal.WorkerValidationStatus
+
+	// Capabilities are the features of the server detected when the worker starts, returned by CapabilitiesProvider.Capabilities.
+	Capabilities = internal.WorkerCapabilities
+
 	// AuthorizationProvider is the interface that contains the method to get the auth token
 	AuthorizationProvider = auth.AuthorizationProvider
 
@@ -259,6 +417,48 @@ const (
 	NonDeterministicWorkflowPolicyFailWorkflow = internal.NonDeterministicWorkflowPolicyFailWorkflow
 )
 
+const (
+	// DecisionTaskFailureActionNone keeps the default handling: the decision task is retried by the server.
+	DecisionTaskFailureActionNone = internal.DecisionTaskFailureActionNone
+	// DecisionTaskFailureActionReset resets the workflow to the last successfully completed decision.
+	DecisionTaskFailureActionReset = internal.DecisionTaskFailureActionReset
+)
+
+const (
+	// HistoryDiffTypeEqual means the entry is present in both inputs.
+	HistoryDiffTypeEqual = internal.HistoryDiffTypeEqual
+	// HistoryDiffTypeMissing means the entry is present in the expected input only.
+	HistoryDiffTypeMissing = internal.HistoryDiffTypeMissing
+	// HistoryDiffTypeExtra means the entry is present in the actual input only.
+	HistoryDiffTypeExtra = internal.HistoryDiffTypeExtra
+	// HistoryDiffTypeMismatch means both inputs have an entry at this position, but they do not match.
+	HistoryDiffTypeMismatch = internal.HistoryDiffTypeMismatch
+)
+
+const (
+	// ValidationPassed is reported for checks which passed.
+	ValidationPassed = internal.WorkerValidationPassed
+	// ValidationFailed is reported for checks which failed, see ValidationCheck.Message.
+	ValidationFailed = internal.WorkerValidationFailed
+	// ValidationSkipped is reported for checks which do not apply to the worker, or cannot be run because an
+	// earlier check failed.
+	ValidationSkipped = internal.WorkerValidationSkipped
+)
+
+const (
+	// ValidationCheckDomain checks the domain exists and is registered.
+	ValidationCheckDomain = internal.WorkerValidationCheckDomain
+	// ValidationCheckAuthorization checks an authorization token can be provided, and grants access to the domain.
+	ValidationCheckAuthorization = internal.WorkerValidationCheckAuthorization
+	// ValidationCheckTaskList checks the decision and activity task lists polled by the worker can be described.
+	ValidationCheckTaskList = internal.WorkerValidationCheckTaskList
+	// ValidationCheckSearchAttributes checks WorkerOptions.UpsertedSearchAttributes are registered in the cluster.
+	ValidationCheckSearchAttributes = internal.WorkerValidationCheckSearchAttributes
+	// ValidationCheckClockSkew checks the server clock is not ahead of the local clock, using the last access times
+	// the server recorded for the pollers of the task list.
+	ValidationCheckClockSkew = internal.WorkerValidationCheckClockSkew
+)
+
 const (
 	// ShadowModeNormal is the default mode for workflow shadowing.
 	// Shadowing will complete after all workflows matches WorkflowQuery have been replayed.
@@ -303,6 +503,8 @@ func NewV2(
 	return internal.NewWorker(service, domain, taskList, options)
 }
 
+var _ EncodedHistoryReplayer = (*internal.WorkflowReplayer)(nil)
+
 // NewWorkflowReplayer creates a WorkflowReplayer instance.
 func NewWorkflowReplayer() WorkflowReplayer {
 	return internal.NewWorkflowReplayer()
@@ -367,6 +569,86 @@ func ReplayWorkflowExecution(ctx context.Context, service workflowserviceclient.
 	return internal.ReplayWorkflowExecution(ctx, service, logger, domain, execution)
 }
 
+// DiffHistories compares two histories, e.g. an original run and a run reset from it, and returns the aligned diff.
+// Events are matched on their type and identifying attributes, so event IDs and timestamps are ignored.
+func DiffHistories(expected, actual *shared.History) *HistoryDiff {
+	return internal.DiffHistories(expected, actual)
+}
+
+// DiffDecisionsWithHistory compares decisions produced by a replay with the decision events recorded in a history,
+// using the same matching rules as the replay non-determinism check.
+func DiffDecisionsWithHistory(decisions []*shared.Decision, history *shared.History) *HistoryDiff {
+	return internal.DiffDecisionsWithHistory(decisions, history)
+}
+
+// NewWorkflowFilter returns a WorkflowFilter allowing all workflows until its allow or deny list is set.
+func NewWorkflowFilter() *WorkflowFilter {
+	return internal.NewWorkflowFilter()
+}
+
+// NewDecisionCircuitBreaker returns a closed DecisionCircuitBreaker, or nil if options.FailureThreshold is not positive.
+func NewDecisionCircuitBreaker(options DecisionCircuitBreakerOptions) *DecisionCircuitBreaker {
+	return internal.NewDecisionCircuitBreaker(options)
+}
+
+// NewHistoryGrowthDetector returns a HistoryGrowthDetector, to set on the workers with Options.HistoryGrowthDetector.
+// The flagged executions can be served on a debug endpoint of the service:
+//
+//	detector := worker.NewHistoryGrowthDetector(worker.HistoryGrowthDetectorOptions{MaxEventsPerHour: 500})
+//	w := worker.New(service, domain, taskList, worker.Options{HistoryGrowthDetector: detector})
+//	http.Handle("/debug/cadence/history-growth", detector)
+func NewHistoryGrowthDetector(options HistoryGrowthDetectorOptions) *HistoryGrowthDetector {
+	return internal.NewHistoryGrowthDetector(options)
+}
+
+// NewWorkerAdmin returns a WorkerAdmin, to set on a worker with Options.WorkerAdmin. The settings can be changed with
+// its methods, or on a debug endpoint of the service:
+//
+//	level := zap.NewAtomicLevel()
+//	admin := worker.NewWorkerAdmin(worker.WorkerAdminOptions{LogLevel: &level})
+//	w := worker.New(service, domain, taskList, worker.Options{Logger: newLogger(level), WorkerAdmin: admin})
+//	http.Handle("/debug/cadence/worker", admin)
+//
+// and later, e.g. to stop processing a misbehaving workflow type on this worker:
+//
+//	curl -d '{"denyList": {"workflowTypes": ["billing"]}, "changedBy": "oncall"}' localhost:8080/debug/cadence/worker
+func NewWorkerAdmin(options WorkerAdminOptions) *WorkerAdmin {
+	return internal.NewWorkerAdmin(options)
+}
+
+// NewFieldRedactor returns a Redactor replacing the fields of the JSON of values which have one of the names by
+// "<redacted>", at any depth:
+//
+//	worker.Options{
+//		WorkflowPanicArgs: &worker.WorkflowPanicArgsOptions{
+//			Redactor: worker.NewFieldRedactor("password", "ssn"),
+//		},
+//	}
+func NewFieldRedactor(names ...string) Redactor {
+	return internal.NewFieldRedactor(names...)
+}
+
+// NewSlotPool creates a pool of size activity execution slots, to be set as Options.ActivitySlotPool of the workers
+// of the process sharing it:
+//
+//	pool := worker.NewSlotPool(200)
+//	for _, taskList := range taskLists {
+//		w, err := worker.NewV2(service, domain, taskList, worker.Options{
+//			MaxConcurrentActivityExecutionSize: 50,
+//			ActivitySlotPool:                   pool,
+//		})
+//		...
+//	}
+func NewSlotPool(size int) *SlotPool {
+	return internal.NewSlotPool(size)
+}
+
+// NewProcessResourceMonitor returns the default ResourceMonitor of AdmissionControlOptions. It reports the usage of the
+// current process against the cgroup limits when running in a container.
+func NewProcessResourceMonitor() ResourceMonitor {
+	return internal.NewProcessResourceMonitor()
+}
+
 // SetStickyWorkflowCacheSize sets the cache size for sticky workflow cache. Sticky workflow execution is the affinity
 // between decision tasks of a specific workflow execution to a specific worker. The affinity is set if sticky execution
 // is enabled via Worker.Options (It is enabled by default unless disabled explicitly). The benefit of sticky execution
@@ -378,6 +660,17 @@ func SetStickyWorkflowCacheSize(cacheSize int) {
 	internal.SetStickyWorkflowCacheSize(cacheSize)
 }
 
+// SetStickyWorkflowCacheIdleTimeout evicts the workflow executions which did not receive a decision task for longer
+// than timeout from the sticky workflow cache, see SetStickyWorkflowCacheSize. Executions waiting for a long time, or
+// abandoned by the server after the worker lost its sticky task list, otherwise hold their goroutines and state until
+// the cache is full. Evicted executions are replayed from their history by their next decision task, and counted by the
+// cadence-sticky-cache-idle-evict counter. The cache is checked while workflow workers are running, and the timeout
+// must be at least 1s. This must be called before any worker is started. If not called, executions are only evicted
+// when the cache is full.
+func SetStickyWorkflowCacheIdleTimeout(timeout time.Duration) {
+	internal.SetStickyWorkflowCacheIdleTimeout(timeout)
+}
+
 // SetBinaryChecksum sets the identifier of the binary(aka BinaryChecksum).
 // The identifier is mainly used in recording reset points when respondDecisionTaskCompleted. For each workflow, the very first
 // decision completed by a binary will be associated as a auto-reset point for the binary. So that when a customer wants to
@@ -35,6 +35,9 @@ type LocalActivityOptions = internal.LocalActivityOptions
 // RetryPolicy specify how to retry activity if error happens.
 type RetryPolicy = internal.RetryPolicy
 
+// PollOptions configure how ExecutePollingActivity retries the activity until it is ready.
+type PollOptions = internal.PollOptions
+
 // WithActivityOptions makes a copy of the context and adds the
 // passed in options to the context. If an activity options exists,
 // it will be overwritten by the passed in value as a whole.
@@ -44,6 +47,23 @@ func WithActivityOptions(ctx Context, options ActivityOptions) Context {
 	return internal.WithActivityOptions(ctx, options)
 }
 
+// WithActivityOptionsOverride makes a copy of the context and merges the
+// passed in options into the existing activity options. Only the fields
+// set in options are overwritten, the rest (e.g. a retry policy configured
+// by the caller) are carried over. An empty activity options will be created
+// if it does not exist in the original context.
+func WithActivityOptionsOverride(ctx Context, options ActivityOptions) Context {
+	return internal.WithActivityOptionsOverride(ctx, options)
+}
+
+// GetActivityOptions returns the activity options in the Context.
+// A zero value is returned if no activity options were set.
+//
+// Timeouts are returned with the resolution stored in the context, which is seconds.
+func GetActivityOptions(ctx Context) ActivityOptions {
+	return internal.GetActivityOptions(ctx)
+}
+
 // WithLocalActivityOptions makes a copy of the context and adds the
 // passed in options to the context. If a local activity options exists,
 // it will be overwritten by the passed in value.
@@ -51,6 +71,19 @@ func WithLocalActivityOptions(ctx Context, options LocalActivityOptions) Context
 	return internal.WithLocalActivityOptions(ctx, options)
 }
 
+// WithLocalActivityOptionsOverride makes a copy of the context and merges the
+// passed in options into the existing local activity options. Only the fields
+// set in options are overwritten, the rest are carried over.
+func WithLocalActivityOptionsOverride(ctx Context, options LocalActivityOptions) Context {
+	return internal.WithLocalActivityOptionsOverride(ctx, options)
+}
+
+// GetLocalActivityOptions returns the local activity options in the Context.
+// A zero value is returned if no local activity options were set.
+func GetLocalActivityOptions(ctx Context) LocalActivityOptions {
+	return internal.GetLocalActivityOptions(ctx)
+}
+
 // WithTaskList makes a copy of the current context and update the taskList
 // field in its activity options. An empty activity options will be created
 // if it does not exist in the original context.
@@ -22,6 +22,7 @@
 package workflow
 
 import (
+	"cmp"
 	"time"
 
 	"go.uber.org/cadence/internal"
@@ -37,6 +38,16 @@ type (
 	// Use workflow.NewSelector(ctx) method to create a Selector instance.
 	Selector = internal.Selector
 
+	// SelectorWithDeadline is a Selector with timeout cases, whose timers are canceled when another case is met.
+	// Use workflow.NewSelectorWithDeadline(ctx) method to create a SelectorWithDeadline instance.
+	SelectorWithDeadline = internal.SelectorWithDeadline
+
+	// SelectorOptions configure a Selector created by workflow.NewSelectorWithOptions(ctx, options).
+	SelectorOptions = internal.SelectorOptions
+
+	// SelectorPolicy chooses the case invoked by Select when several cases of a Selector are ready.
+	SelectorPolicy = internal.SelectorPolicy
+
 	// Future represents the result of an asynchronous computation.
 	Future = internal.Future
 
@@ -47,6 +58,23 @@ type (
 	// WaitGroup is used to wait for a collection of
 	// coroutines to finish
 	WaitGroup = internal.WaitGroup
+
+	// Semaphore bounds the number of coroutines of a workflow holding a resource.
+	// Use workflow.NewSemaphore(ctx, n) method to create a Semaphore instance.
+	Semaphore = internal.Semaphore
+)
+
+const (
+	// SelectorPolicyRegistrationOrder invokes the first ready case in the order the cases were added when Select is
+	// called, and otherwise the first case that becomes ready while Select is blocked. This is the policy of the
+	// Selectors created by NewSelector.
+	SelectorPolicyRegistrationOrder SelectorPolicy = internal.SelectorPolicyRegistrationOrder
+	// SelectorPolicyRoundRobin invokes the first ready case starting after the case invoked by the previous Select
+	// call, so that a case which is always ready does not starve the other cases.
+	SelectorPolicyRoundRobin SelectorPolicy = internal.SelectorPolicyRoundRobin
+	// SelectorPolicyPriority invokes the first ready case in the order the cases were added, including when several
+	// cases become ready while Select is blocked. The cases added first have the highest priority.
+	SelectorPolicyPriority SelectorPolicy = internal.SelectorPolicyPriority
 )
 
 // Await blocks the calling thread until condition() returns true.
@@ -62,6 +90,23 @@ func Await(ctx Context, condition func() bool) error {
 	return internal.Await(ctx, condition)
 }
 
+// AwaitWithTimeout blocks the calling thread until condition() returns true or the timeout expires.
+// Do not mutate values or trigger side effects inside condition.
+// Returns ok equal to false if the timeout expired, and CanceledError if the ctx is canceled.
+// The condition is re-evaluated each time the workflow state may have changed, e.g. when a signal
+// is received or an activity completes, so it replaces channels and selectors in signal driven
+// state machines. The following code waits up to an hour for an approval signal.
+//
+//	workflow.Go(ctx, func(ctx workflow.Context) {
+//	  workflow.GetSignalChannel(ctx, "approve").Receive(ctx, &approved)
+//	})
+//	ok, err := workflow.AwaitWithTimeout(ctx, time.Hour, func() bool {
+//	  return approved
+//	})
+func AwaitWithTimeout(ctx Context, timeout time.Duration, condition func() bool) (ok bool, err error) {
+	return internal.AwaitWithTimeout(ctx, timeout, condition)
+}
+
 // NewChannel create new Channel instance
 func NewChannel(ctx Context) Channel {
 	return internal.NewChannel(ctx)
@@ -84,17 +129,82 @@ func NewNamedBufferedChannel(ctx Context, name string, size int) Channel {
 	return internal.NewNamedBufferedChannel(ctx, name, size)
 }
 
+// ReceiveWithCancel is the same as c.Receive, but also stops blocking when ctx is canceled, instead of requiring a
+// Selector with ctx.Done(). ok is true when a value was received, canceled is true when ctx was canceled before, and
+// both are false when c is closed and empty. A value which can be received without blocking is received even when ctx
+// is already canceled.
+//
+//	var approval Approval
+//	ok, canceled := workflow.ReceiveWithCancel(ctx, workflow.GetSignalChannel(ctx, "approve"), &approval)
+func ReceiveWithCancel(ctx Context, c Channel, valuePtr interface{}) (ok bool, canceled bool) {
+	return internal.ReceiveWithCancel(ctx, c, valuePtr)
+}
+
 // NewSelector creates a new Selector instance.
 func NewSelector(ctx Context) Selector {
 	return internal.NewSelector(ctx)
 }
 
+// NewSelectorWithDeadline creates a new SelectorWithDeadline instance, to race cases against a timeout without
+// leaving a pending timer in the history when another case wins:
+//
+//	workflow.NewSelectorWithDeadline(ctx).
+//	  AddTimeout(time.Hour, func() {
+//	    timedOut = true
+//	  }).
+//	  AddFuture(approvalFuture, func(f workflow.Future) {
+//	    err = f.Get(ctx, &approval)
+//	  }).
+//	  Select(ctx)
+func NewSelectorWithDeadline(ctx Context) SelectorWithDeadline {
+	return internal.NewSelectorWithDeadline(ctx)
+}
+
+// NewSelectorWithOptions creates a new Selector instance with options, e.g. to always handle the signals of a high
+// priority channel before the ones of a low priority channel, even when both are received in the same decision task:
+//
+//	selector := workflow.NewSelectorWithOptions(ctx, workflow.SelectorOptions{Policy: workflow.SelectorPolicyPriority})
+//	selector.AddReceive(highPriority, func(c workflow.Channel, more bool) {
+//	  c.Receive(ctx, &urgent)
+//	})
+//	selector.AddReceive(lowPriority, func(c workflow.Channel, more bool) {
+//	  c.Receive(ctx, &routine)
+//	})
+//
+// With a policy other than SelectorPolicyRegistrationOrder, a blocked Select does not receive from a channel when a
+// value is sent to it, but scans the cases again once woken up: SendAsync to an unbuffered channel fails even when
+// such a Select waits to receive from it. Like the Selectors created by NewSelectorWithDeadline, it supports
+// AddTimeout.
+func NewSelectorWithOptions(ctx Context, options SelectorOptions) SelectorWithDeadline {
+	return internal.NewSelectorWithOptions(ctx, options)
+}
+
 // NewNamedSelector creates a new Selector instance with a given human readable name.
 // Name appears in stack traces that are blocked on this Selector.
 func NewNamedSelector(ctx Context, name string) Selector {
 	return internal.NewNamedSelector(ctx, name)
 }
 
+// NewSemaphore creates a new Semaphore with n units, e.g. to bound the number of activities executing concurrently
+// in a fan-out workflow:
+//
+//	sem := workflow.NewSemaphore(ctx, 10)
+//	for _, item := range items {
+//	  if err := sem.Acquire(ctx, 1); err != nil {
+//	    return err // ctx is canceled
+//	  }
+//	  item := item
+//	  workflow.Go(ctx, func(ctx workflow.Context) {
+//	    defer sem.Release(1)
+//	    _ = workflow.ExecuteActivity(ctx, ProcessItem, item).Get(ctx, nil)
+//	  })
+//	}
+//
+// Acquire returns CanceledError when ctx is canceled while waiting, without acquiring any unit.
+func NewSemaphore(ctx Context, n int64) Semaphore {
+	return internal.NewSemaphore(ctx, n)
+}
+
 // NewWaitGroup creates a new WaitGroup instance.
 func NewWaitGroup(ctx Context) WaitGroup {
 	return internal.NewWaitGroup(ctx)
@@ -117,6 +227,48 @@ func NewFuture(ctx Context) (Future, Settable) {
 	return internal.NewFuture(ctx)
 }
 
+// WhenAll returns a Future that is ready once all futures are ready, e.g. to wait for the activities of a fan-out:
+//
+//	var futures []workflow.Future
+//	for _, item := range items {
+//	  futures = append(futures, workflow.ExecuteActivity(ctx, ProcessItem, item))
+//	}
+//	if err := workflow.WhenAll(ctx, futures...).Get(ctx, nil); err != nil {
+//	  return err // combines the errors of all failed activities, see multierr.Errors
+//	}
+//
+// Its value is nil, the values are retrieved from futures once it is ready.
+func WhenAll(ctx Context, futures ...Future) Future {
+	return internal.WhenAll(ctx, futures...)
+}
+
+// WhenAny returns a Future that is ready once any of futures is ready, whether it succeeded or failed. Its value is
+// the index in futures of the first future that became ready:
+//
+//	var index int
+//	_ = workflow.WhenAny(ctx, futures...).Get(ctx, &index)
+//	err := futures[index].Get(ctx, &result)
+//
+// It panics when there are no futures.
+func WhenAny(ctx Context, futures ...Future) Future {
+	return internal.WhenAny(ctx, futures...)
+}
+
+// WhenAnyResult returns a Future that is ready once any of futures succeeds, with the value of the first future that
+// succeeded, e.g. to query redundant backends and use the first answer:
+//
+//	var quote Quote
+//	err := workflow.WhenAnyResult(ctx,
+//	  workflow.ExecuteActivity(ctx, GetQuote, "backend-1"),
+//	  workflow.ExecuteActivity(ctx, GetQuote, "backend-2"),
+//	).Get(ctx, &quote)
+//
+// When all futures fail, its error combines their errors in the order they failed, see multierr.Errors. The futures
+// that are still pending are not canceled. It panics when there are no futures.
+func WhenAnyResult(ctx Context, futures ...Future) Future {
+	return internal.WhenAnyResult(ctx, futures...)
+}
+
 // Now returns the time that the current decision task was started.
 // Workflows need to base any behavior off this time, rather than `time.Now()`, because `time.Now()` will change during
 // future replays.
@@ -165,3 +317,96 @@ func NewTimer(ctx Context, d time.Duration) Future {
 func Sleep(ctx Context, d time.Duration) (err error) {
 	return internal.Sleep(ctx, d)
 }
+
+// Retry calls operation until it succeeds, sleeping with workflow timers between the attempts according to the
+// policy, e.g. to poll an external system through an activity with an increasing interval:
+//
+//	err := workflow.Retry(ctx, workflow.RetryPolicy{
+//		InitialInterval:    time.Minute,
+//		BackoffCoefficient: 2,
+//		MaximumInterval:    time.Hour,
+//		ExpirationInterval: 24 * time.Hour,
+//	}, func(ctx workflow.Context) error {
+//		var status string
+//		if err := workflow.ExecuteActivity(ctx, GetPaymentStatus, paymentID).Get(ctx, &status); err != nil {
+//			return err
+//		}
+//		if status != "settled" {
+//			return cadence.NewCustomError("PaymentNotSettled")
+//		}
+//		return nil
+//	})
+//
+// It returns nil once operation succeeds, and otherwise the last error of operation once the policy is exhausted
+// or the error reason is one of the NonRetriableErrorReasons. A *CanceledError is returned when ctx is canceled.
+// The policy defaults are the ones of activities: a BackoffCoefficient of 2 and a MaximumInterval of 100 times the
+// InitialInterval, and either MaximumAttempts or ExpirationInterval is required.
+//
+// Unlike the retry policy of an activity, which retries it on the server, each attempt of operation and each backoff
+// timer are recorded in the history, so a single activity with a retry policy is preferable when operation is one
+// activity whose intermediate failures do not matter. Retry records the policy, or the reason it is invalid, with
+// SideEffect when it is called, so changing the policy in a new deployment only applies to new calls and does not need
+// GetVersion.
+func Retry(ctx Context, policy RetryPolicy, operation func(ctx Context) error) error {
+	return internal.Retry(ctx, policy, operation)
+}
+
+// SortedKeys returns the keys of the map m in ascending order.
+// Go randomizes map iteration order, so workflow code must not range over a map
+// when the order affects the decisions made by the workflow.
+func SortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	return internal.SortedKeys(m)
+}
+
+// SortedKeysFunc returns the keys of the map m ordered by less.
+// Use it for key types that are not ordered, e.g. structs.
+func SortedKeysFunc[K comparable, V any](m map[K]V, less func(a, b K) bool) []K {
+	return internal.SortedKeysFunc(m, less)
+}
+
+// SortedRange calls fn for each key and value of the map m in ascending key order.
+// Iteration stops if fn returns false. Use it instead of ranging over a map in workflow code:
+//
+//	workflow.SortedRange(tasks, func(name string, input Input) bool {
+//	  futures = append(futures, workflow.ExecuteActivity(ctx, name, input))
+//	  return true
+//	})
+//
+// The x/maprange analyzer reports the range statements over maps in workflow code.
+func SortedRange[K cmp.Ordered, V any](m map[K]V, fn func(key K, value V) bool) {
+	internal.SortedRange(m, fn)
+}
+
+// SortedRangeFunc calls fn for each key and value of the map m in the key order defined by less.
+// Iteration stops if fn returns false.
+func SortedRangeFunc[K comparable, V any](m map[K]V, less func(a, b K) bool, fn func(key K, value V) bool) {
+	internal.SortedRangeFunc(m, less, fn)
+}
+
+// LoadLocation returns the time zone with the given IANA name, e.g. "America/New_York", for use in workflow code.
+// The time zone is loaded with time.LoadLocation, whether it could be loaded is recorded in the workflow history
+// with MutableSideEffect, so an unknown name fails the same way on every replay. A replay on a worker missing the
+// tzdata of a time zone loaded before panics, which fails the decision task so it is retried; import time/tzdata to
+// embed the tzdata in binaries running on hosts without it, e.g. distroless images. The tzdata itself is not recorded,
+// so local times follow the tzdata version of the worker. "UTC" and "" return time.UTC, "Local" is rejected.
+func LoadLocation(ctx Context, name string) (*time.Location, error) {
+	return internal.LoadLocation(ctx, name)
+}
+
+// NextBusinessDay returns midnight of the next Monday to Friday after workflow.Now(ctx) in the time zone tz,
+// loaded with LoadLocation. Holidays are not taken into account.
+func NextBusinessDay(ctx Context, tz string) (time.Time, error) {
+	return internal.NextBusinessDay(ctx, tz)
+}
+
+// NextCronTime returns the first time after workflow.Now(ctx) matching the standard cron schedule in the time zone tz,
+// loaded with LoadLocation. Use it with workflow.NewTimer to wait until e.g. 9am local time:
+//
+//	next, err := workflow.NextCronTime(ctx, "0 9 * * *", "Europe/Berlin")
+//	if err != nil {
+//	  return err
+//	}
+//	workflow.NewTimer(ctx, next.Sub(workflow.Now(ctx))).Get(ctx, nil)
+func NextCronTime(ctx Context, cronSchedule string, tz string) (time.Time, error) {
+	return internal.NextCronTime(ctx, cronSchedule, tz)
+}
@@ -115,7 +115,7 @@ A simplistic way to think about these requirements is that the workflow code:
   - Should do all logging via the logger provided by the Cadence client
     library (i.e. workflow.GetLogger())
   - Should not iterate over maps using range as order of map iteration is
-    randomized
+    randomized (use workflow.SortedRange() or workflow.SortedKeys() instead)
 
 Now that we laid out the ground rules we can take a look at how to implement some common patterns inside workflows.
 
@@ -416,6 +416,14 @@ So the error handling code would look like:
 		// all other cases (ideally, this should not happen)
 	}
 
+Errors of a type registered with cadence.RegisterFailureType are received as a *workflow.CustomError wrapping the
+failure decoded back to its type, which errors.As extracts:
+
+	var insufficientFunds *InsufficientFundsError
+	if errors.As(err, &insufficientFunds) {
+		// handle the typed failure
+	}
+
 # Signals
 
 Signals provide a mechanism to send data directly to a running workflow. Previously, you had two options for passing
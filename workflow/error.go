@@ -121,6 +121,12 @@ func NewContinueAsNewError(ctx Context, wfn interface{}, args ...interface{}) *C
 	return internal.NewContinueAsNewError(ctx, wfn, args...)
 }
 
+// IsActivityNotReadyError returns whether the activity failed with activity.ErrNotReady, e.g. when
+// ExecutePollingActivity times out.
+func IsActivityNotReadyError(err error) bool {
+	return internal.IsActivityNotReadyError(err)
+}
+
 // NewTimeoutError creates TimeoutError instance.
 // Use NewHeartbeatTimeoutError to create heartbeat TimeoutError
 // WARNING: This function is public only to support unit testing of workflows.
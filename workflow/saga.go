@@ -0,0 +1,60 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package workflow
+
+import (
+	"go.uber.org/cadence/internal"
+)
+
+type (
+	// SagaOptions configures how a Saga runs its compensations.
+	SagaOptions = internal.SagaOptions
+
+	// Saga records the compensations of the steps of a workflow and runs them in the reverse order when a later step
+	// fails or the workflow is canceled. Use workflow.NewSaga(options) method to create a Saga instance.
+	Saga = internal.Saga
+)
+
+// NewSaga creates a Saga, recording the compensation of each step once it succeeded, and compensating when the
+// workflow fails or is canceled:
+//
+//	func TransferWorkflow(ctx workflow.Context, transfer Transfer) (err error) {
+//	  ctx = workflow.WithActivityOptions(ctx, activityOptions)
+//	  saga := workflow.NewSaga(workflow.SagaOptions{ContinueWithError: true})
+//	  defer func() {
+//	    if err != nil {
+//	      err = multierr.Append(err, saga.Compensate(ctx))
+//	    }
+//	  }()
+//
+//	  if err := workflow.ExecuteActivity(ctx, Withdraw, transfer).Get(ctx, nil); err != nil {
+//	    return err
+//	  }
+//	  saga.AddCompensation(Refund, transfer)
+//
+//	  return workflow.ExecuteActivity(ctx, Deposit, transfer).Get(ctx, nil)
+//	}
+//
+// The compensations run on a disconnected context, so a canceled workflow is compensated too, and its error is the
+// CanceledError of the canceled step.
+func NewSaga(options SagaOptions) *Saga {
+	return internal.NewSaga(options)
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package workflow
+
+import (
+	"go.uber.org/cadence/internal"
+)
+
+var _ TypedChannel[string] = internal.TypedChannel[string](nil) // to ensure it's compatible
+
+// TypedChannel is a Channel of values of type T, so that the values sent and received are checked by the compiler,
+// instead of failing to be decoded when received:
+//
+//	approvals := workflow.GetTypedSignalChannel[Approval](ctx, "approval")
+//	approval, _ := approvals.Receive(ctx)
+//
+// Use Channel to add it to a Selector:
+//
+//	selector.AddReceive(approvals.Channel(), func(workflow.Channel, bool) {
+//		approval, _ := approvals.Receive(ctx)
+//		...
+//	})
+type TypedChannel[T any] interface {
+	// Receive blocks until it receives a value, and returns it. more is false when the channel is closed and
+	// empty, along with the zero value of T.
+	Receive(ctx Context) (value T, more bool)
+	// ReceiveWithCancel is like Receive, and also stops blocking when ctx is canceled, returning canceled equal to
+	// true. ok is false when no value was received, i.e. ctx was canceled or the channel is closed and empty.
+	ReceiveWithCancel(ctx Context) (value T, ok bool, canceled bool)
+	// ReceiveAsync tries to receive a value without blocking. ok is false when no value was received.
+	ReceiveAsync() (value T, ok bool)
+	// ReceiveAsyncWithMoreFlag is like ReceiveAsync, and returns more equal to false when the channel is closed.
+	ReceiveAsyncWithMoreFlag() (value T, ok bool, more bool)
+	// Send blocks until the value is sent.
+	Send(ctx Context, value T)
+	// SendAsync tries to send the value without blocking, and returns true if it was sent.
+	SendAsync(value T) (ok bool)
+	// Close closes the channel.
+	Close()
+	// Channel returns the underlying Channel, e.g. to add it to a Selector.
+	Channel() Channel
+}
+
+// NewTypedChannel creates a new TypedChannel.
+func NewTypedChannel[T any](ctx Context) TypedChannel[T] {
+	return internal.NewTypedChannel[T](ctx)
+}
+
+// NewTypedBufferedChannel creates a new buffered TypedChannel.
+func NewTypedBufferedChannel[T any](ctx Context, size int) TypedChannel[T] {
+	return internal.NewTypedBufferedChannel[T](ctx, size)
+}
+
+// GetTypedSignalChannel returns the channel of the signals of signalName, whose values are decoded into a T.
+// The signals which cannot be decoded into a T are dropped, and counted like for GetSignalChannel.
+func GetTypedSignalChannel[T any](ctx Context, signalName string) TypedChannel[T] {
+	return internal.GetTypedSignalChannel[T](ctx, signalName)
+}
+
+// SetSignalHandler calls handler with each signal of signalName, decoded into a T, instead of receiving the signals
+// from GetSignalChannel in a loop:
+//
+//	err := workflow.SetSignalHandler(ctx, "add-item", func(ctx workflow.Context, item Item) {
+//		items = append(items, item)
+//	})
+//
+// The handler is called in its own coroutine, one signal at a time and in the order the signals were received, with
+// the context of that coroutine. It may block, e.g. to execute an activity, the next signals wait until it returns.
+// The signals which cannot be decoded into a T are dropped, and counted like for GetSignalChannel. The signal channel
+// must not be received from elsewhere, and setting a second handler for the same signal name returns an error.
+// Signal names with a handler are never reported by GetUnhandledSignalNames, nor logged as unhandled when the workflow
+// completes while signals wait for the handler.
+func SetSignalHandler[T any](ctx Context, signalName string, handler func(ctx Context, value T)) error {
+	return internal.SetSignalHandler[T](ctx, signalName, handler)
+}
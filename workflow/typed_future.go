@@ -0,0 +1,68 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package workflow
+
+import (
+	"go.uber.org/cadence/internal"
+)
+
+var _ TypedFuture[string] = internal.TypedFuture[string](nil) // to ensure it's compatible
+
+// TypedFuture is a Future whose value is decoded into a T, so that it is retrieved without passing a pointer and the
+// type of the value is checked by the compiler:
+//
+//	future := workflow.ExecuteActivityTyped[*Order](ctx, LoadOrder, orderID)
+//	order, err := future.Get(ctx)
+//
+// Use Future to add it to a Selector:
+//
+//	selector.AddFuture(future.Future(), func(f workflow.Future) {
+//		order, err := future.Get(ctx)
+//		...
+//	})
+type TypedFuture[T any] interface {
+	// Get blocks until the future is ready, and returns its value decoded into a T, or its error.
+	// When the future failed, the zero value of T is returned with the error.
+	Get(ctx Context) (T, error)
+	// IsReady returns true when the future is ready, i.e. Get does not block.
+	IsReady() bool
+	// Future returns the underlying Future, e.g. to add it to a Selector.
+	Future() Future
+}
+
+// NewTypedFuture wraps future, whose value is decoded into a T, e.g. the future of a child workflow:
+//
+//	result := workflow.NewTypedFuture[Result](workflow.ExecuteChildWorkflow(ctx, ChildWorkflow))
+func NewTypedFuture[T any](future Future) TypedFuture[T] {
+	return internal.NewTypedFuture[T](future)
+}
+
+// ExecuteActivityTyped executes an activity like ExecuteActivity, and returns a future of its result decoded into a T.
+// See ExecuteActivity for the options and the errors.
+func ExecuteActivityTyped[T any](ctx Context, activity interface{}, args ...interface{}) TypedFuture[T] {
+	return internal.ExecuteActivityTyped[T](ctx, activity, args...)
+}
+
+// ExecuteLocalActivityTyped executes a local activity like ExecuteLocalActivity, and returns a future of its result
+// decoded into a T. See ExecuteLocalActivity for the options and the errors.
+func ExecuteLocalActivityTyped[T any](ctx Context, activity interface{}, args ...interface{}) TypedFuture[T] {
+	return internal.ExecuteLocalActivityTyped[T](ctx, activity, args...)
+}
@@ -33,6 +33,12 @@ type (
 	// ChildWorkflowFuture represents the result of a child workflow execution
 	ChildWorkflowFuture = internal.ChildWorkflowFuture
 
+	// ChildWorkflowHandle is a snapshot of a child workflow started by the current run. See GetChildWorkflowHandles.
+	ChildWorkflowHandle = internal.ChildWorkflowHandle
+
+	// ChildWorkflowState is the state of a child workflow started by the current run.
+	ChildWorkflowState = internal.ChildWorkflowState
+
 	// Type identifies a workflow type.
 	Type = internal.WorkflowType
 
@@ -58,6 +64,9 @@ type (
 	// Info information about currently executing workflow
 	Info = internal.WorkflowInfo
 
+	// QueryInfo describes the query a query handler is answering, see GetQueryInfo.
+	QueryInfo = internal.QueryInfo
+
 	RegistryInfo = internal.RegistryWorkflowInfo
 
 	// GetVersionOption is used to specify options for GetVersion
@@ -183,6 +192,44 @@ func ExecuteLocalActivity(ctx Context, activity interface{}, args ...interface{}
 	return internal.ExecuteLocalActivity(ctx, activity, args...)
 }
 
+// ExecutePollingActivity executes an activity which polls an external system until it is ready, e.g. until a payment
+// is settled. The activity returns activity.ErrNotReady while the external system is not ready:
+//
+//	func GetPaymentStatus(ctx context.Context, paymentID string) (string, error) {
+//		status, err := payments.Status(ctx, paymentID)
+//		if err == nil && status == "pending" {
+//			return "", activity.ErrNotReady
+//		}
+//		return status, err
+//	}
+//
+// and is retried by the server every PollOptions.Interval until it succeeds or PollOptions.Timeout expires:
+//
+//	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+//		ScheduleToStartTimeout: time.Minute,
+//		StartToCloseTimeout:    10 * time.Second,
+//	})
+//	var status string
+//	err := workflow.ExecutePollingActivity(ctx, workflow.PollOptions{
+//		Interval: time.Minute,
+//		Timeout:  24 * time.Hour,
+//	}, GetPaymentStatus, paymentID).Get(ctx, &status)
+//	if workflow.IsActivityNotReadyError(err) {
+//		// still not ready after 24 hours
+//	}
+//
+// The attempts are retried with the retry policy of the activity, so only the last attempt is recorded in the
+// history, while a loop executing the activity and sleeping records 5 events per attempt. The context's activity
+// options apply to each attempt, and its retry policy and ScheduleToCloseTimeout are replaced by the ones built from
+// the PollOptions. Other errors of the activity are retried too, unless their reason is one of
+// PollOptions.NonRetriableErrorReasons.
+//
+// The Future fails with the last error of the activity when polling times out, e.g. activity.ErrNotReady, or with
+// a *TimeoutError.
+func ExecutePollingActivity(ctx Context, options PollOptions, activity interface{}, args ...interface{}) Future {
+	return internal.ExecutePollingActivity(ctx, options, activity, args...)
+}
+
 // ExecuteChildWorkflow requests child workflow execution in the context of a workflow.
 // Context can be used to pass the settings for the child workflow.
 // For example: task list that this child workflow should be routed, timeouts that need to be configured.
@@ -205,22 +252,63 @@ func ExecuteChildWorkflow(ctx Context, childWorkflow interface{}, args ...interf
 	return internal.ExecuteChildWorkflow(ctx, childWorkflow, args...)
 }
 
+// GetChildWorkflowHandles returns the child workflows started by the current run, in the order they were started,
+// with their IDs, type and state, e.g. to cancel the ones still running or to wait for them:
+//
+//	for _, child := range workflow.GetChildWorkflowHandles(ctx) {
+//	  if child.State == workflow.ChildWorkflowStateRunning {
+//	    _ = workflow.RequestCancelExternalWorkflow(ctx, child.WorkflowID, child.RunID).Get(ctx, nil)
+//	  }
+//	}
+//
+// The handles are snapshots, call GetChildWorkflowHandles again to get the current states. The child workflows which
+// were not started because their options or arguments were invalid, or because the context was already canceled,
+// are not returned. Only the last 1000 closed child workflows are returned, the pending and running ones are always
+// returned.
+func GetChildWorkflowHandles(ctx Context) []ChildWorkflowHandle {
+	return internal.GetChildWorkflowHandles(ctx)
+}
+
 // GetInfo extracts info of a current workflow from a context.
 func GetInfo(ctx Context) *Info {
 	return internal.GetWorkflowInfo(ctx)
 }
 
-// GetLogger returns a logger to be used in workflow's context
+// GetLogger returns a logger to be used in workflow's context. Its logs are skipped while the workflow is replaying,
+// unless WorkerOptions.EnableLoggingInReplay is set. In SideEffect functions it is tagged with the side effect ID, and in
+// query handlers with the query type.
 func GetLogger(ctx Context) *zap.Logger {
 	return internal.GetLogger(ctx)
 }
 
+// SetStatusText sets the status text returned by the client.QueryTypeMetadata query, which also lists the query
+// types and signal channels of the workflow. For example:
+//
+//	workflow.SetStatusText(ctx, "waiting for approval")
+//	var approval Approval
+//	workflow.GetSignalChannel(ctx, "approval").Receive(ctx, &approval)
+//	workflow.SetStatusText(ctx, "processing")
+//
+// The text is not recorded in the history, a worker replaying the workflow recomputes it.
+func SetStatusText(ctx Context, text string) {
+	internal.SetStatusText(ctx, text)
+}
+
+// GetQueryInfo returns the query answered by the query handler which received ctx as its first parameter, or nil if
+// ctx was not passed to a query handler, see SetQueryHandler. The context of a query handler is the context passed to
+// SetQueryHandler, so it carries the values set on it and the ones extracted from the workflow headers by the context
+// propagators. A query has neither a deadline nor headers of its own.
+func GetQueryInfo(ctx Context) *QueryInfo {
+	return internal.GetQueryInfo(ctx)
+}
+
 // GetUnhandledSignalNames returns signal names that have  unconsumed signals.
 func GetUnhandledSignalNames(ctx Context) []string {
 	return internal.GetUnhandledSignalNames(ctx)
 }
 
-// GetMetricsScope returns a metrics scope to be used in workflow's context
+// GetMetricsScope returns a metrics scope to be used in workflow's context. Its metrics are not emitted while the
+// workflow is replaying. In query handlers it is tagged with the query type.
 func GetMetricsScope(ctx Context) tally.Scope {
 	return internal.GetMetricsScope(ctx)
 }
@@ -249,6 +337,30 @@ func RequestCancelExternalWorkflow(ctx Context, workflowID, runID string) Future
 	return internal.RequestCancelExternalWorkflow(ctx, workflowID, runID)
 }
 
+// RequestCancelExternalWorkflows requests the cancellation of the executions, like RequestCancelExternalWorkflow, and
+// returns a Future ready once all the requests completed. Its error combines the errors of the failed requests with
+// go.uber.org/multierr, each one naming its workflow ID, so that all the executions are requested to cancel even when
+// some requests fail. Use multierr.Errors to get them.
+func RequestCancelExternalWorkflows(ctx Context, executions []Execution) Future {
+	return internal.RequestCancelExternalWorkflows(ctx, executions)
+}
+
+// CancelChildren requests the cancellation of the child workflows of the current run which are pending or running, and
+// for which filter returns true, or all of them when filter is nil, e.g. to tear down the children of an orchestrator
+// workflow:
+//
+//	err := workflow.CancelChildren(ctx, func(child workflow.ChildWorkflowHandle) bool {
+//	  return child.WorkflowType == "ProcessShard"
+//	}).Get(ctx, nil)
+//
+// It returns a Future ready once all the requests completed, whose error combines the errors of the failed requests
+// like RequestCancelExternalWorkflows. The pending child workflows are canceled once they started, the ones which fail
+// to start are ignored. Use a disconnected context to cancel the children when the workflow itself is canceled, see
+// NewDisconnectedContext.
+func CancelChildren(ctx Context, filter func(child ChildWorkflowHandle) bool) Future {
+	return internal.CancelChildren(ctx, filter)
+}
+
 // SignalExternalWorkflow can be used to send signal info to an external workflow.
 // Input workflowID is the workflow ID of target workflow.
 // Input runID indicates the instance of a workflow. Input runID is optional (default is ""). When runID is not specified,
@@ -356,6 +468,26 @@ func MutableSideEffect(ctx Context, id string, f func(ctx Context) interface{},
 // DefaultVersion is a version returned by GetVersion for code that wasn't versioned before
 const DefaultVersion Version = internal.DefaultVersion
 
+const (
+	// ChildWorkflowStatePending means the start of the child workflow was requested, and it did not start yet.
+	ChildWorkflowStatePending ChildWorkflowState = internal.ChildWorkflowStatePending
+	// ChildWorkflowStateStartFailed means the child workflow could not be started, e.g. because a workflow with the
+	// same ID is running.
+	ChildWorkflowStateStartFailed ChildWorkflowState = internal.ChildWorkflowStateStartFailed
+	// ChildWorkflowStateRunning means the child workflow started, and did not close yet.
+	ChildWorkflowStateRunning ChildWorkflowState = internal.ChildWorkflowStateRunning
+	// ChildWorkflowStateCompleted means the child workflow completed successfully.
+	ChildWorkflowStateCompleted ChildWorkflowState = internal.ChildWorkflowStateCompleted
+	// ChildWorkflowStateFailed means the child workflow failed.
+	ChildWorkflowStateFailed ChildWorkflowState = internal.ChildWorkflowStateFailed
+	// ChildWorkflowStateCanceled means the child workflow was canceled.
+	ChildWorkflowStateCanceled ChildWorkflowState = internal.ChildWorkflowStateCanceled
+	// ChildWorkflowStateTimedOut means the child workflow timed out.
+	ChildWorkflowStateTimedOut ChildWorkflowState = internal.ChildWorkflowStateTimedOut
+	// ChildWorkflowStateTerminated means the child workflow was terminated.
+	ChildWorkflowStateTerminated ChildWorkflowState = internal.ChildWorkflowStateTerminated
+)
+
 // ExecuteWithVersion forces a specific version to be returned when GetVersion is executed for the first time,
 // instead of returning maxSupported version. This option can be used when you want to separate the versioning of the workflow code and
 // activation of the new logic in the workflow code, to ensure that your changes can be safely rolled back, if needed.
@@ -486,16 +618,44 @@ func GetVersion(ctx Context, changeID string, minSupported, maxSupported Version
 	return internal.GetVersion(ctx, changeID, minSupported, maxSupported, opts...)
 }
 
+// GetVersions returns the versions of the changes in one call, e.g. to log them or to pass them to an activity.
+// It records no marker. Each change goes through GetVersion, so interceptors and the GetVersion mocks of the test
+// environment see it: a decided change with its version as the only supported one, and a change which GetVersion has
+// not been called for yet with GetVersion(ctx, changeID, DefaultVersion, DefaultVersion), which pins it to
+// DefaultVersion. GetVersions must only be called for changes decided earlier in the workflow code, or for changes
+// which are no longer made.
+//
+//	versions := workflow.GetVersions(ctx, "fooChange", "barChange")
+//	if versions["fooChange"] == workflow.DefaultVersion {
+//	  ...
+//	}
+func GetVersions(ctx Context, changeIDs ...string) map[string]Version {
+	return internal.GetVersions(ctx, changeIDs...)
+}
+
+// ExecutionVersions returns the version of every change decided so far by the workflow code, including versions
+// recorded in history by earlier runs of the code which are no longer asked for. It records no marker, and returns the
+// same result when replayed, which makes it suitable for version cleanup tooling, e.g. reporting the change IDs still
+// at DefaultVersion from a query handler.
+func ExecutionVersions(ctx Context) map[string]Version {
+	return internal.ExecutionVersions(ctx)
+}
+
 // SetQueryHandler sets the query handler to handle workflow query. The queryType specify which query type this handler
 // should handle. The handler must be a function that returns 2 values. The first return value must be a serializable
 // result. The second return value must be an error. The handler function could receive any number of input parameters.
-// All the input parameter must be serializable. You should call workflow.SetQueryHandler() at the beginning of the workflow
+// All the input parameter must be serializable, they are decoded from the query arguments with the DataConverter of the
+// workflow. The first parameter can also be a workflow.Context, the context passed to SetQueryHandler with the QueryInfo
+// of the query, see GetQueryInfo. You should call workflow.SetQueryHandler() at the beginning of the workflow
 // code. When client calls Client.QueryWorkflow() to cadence server, a task will be generated on server that will be dispatched
 // to a workflow worker, which will replay the history events and then execute a query handler based on the query type.
 // The query handler will be invoked out of the context of the workflow, meaning that the handler code must not use workflow
 // context to do things like workflow.NewChannel(), workflow.Go() or to call any workflow blocking functions like
 // Channel.Get() or Future.Get(). Trying to do so in query handler code will fail the query and client will receive
 // QueryFailedError.
+// The logger and metrics scope returned by workflow.GetLogger() and workflow.GetMetricsScope() for the workflow context
+// can be used in the handler: they are tagged with the query type, and their logs and metrics are emitted even when the
+// query is answered after replaying the history, as queries are not replayed.
 // Example of workflow code that support query type "current_state":
 //
 //	func MyWorkflow(ctx workflow.Context, input string) error {
@@ -525,6 +685,14 @@ func GetVersion(ctx Context, changeID string, minSupported, maxSupported Version
 //	  currentState = "done"
 //	  return nil
 //	}
+//
+// Example of a query handler receiving the context and several arguments, queried with
+// Client.QueryWorkflow(ctx, workflowID, runID, "items", "pending", 10):
+//
+//	err := workflow.SetQueryHandler(ctx, "items", func(ctx workflow.Context, status string, limit int) ([]Item, error) {
+//	  workflow.GetLogger(ctx).Info("Listing items", zap.String("QueryType", workflow.GetQueryInfo(ctx).QueryType))
+//	  return filterItems(items, status, limit), nil
+//	})
 func SetQueryHandler(ctx Context, queryType string, handler interface{}) error {
 	return internal.SetQueryHandler(ctx, queryType, handler)
 }
@@ -565,6 +733,20 @@ func GetLastCompletionResult(ctx Context, d ...interface{}) error {
 	return internal.GetLastCompletionResult(ctx, d...)
 }
 
+// GetLastError returns the error of the previous run of this cron workflow, or of the previous attempt of a workflow
+// with a retry policy, or nil when the previous run completed successfully or there is no previous run. The error has
+// the type returned by that run, e.g. *CustomError with its details decoded by the data converter or *TimeoutError:
+//
+//	var customErr *cadence.CustomError
+//	if errors.As(workflow.GetLastError(ctx), &customErr) && customErr.Reason() == "quota-exceeded" {
+//		// resume from the last checkpoint
+//	}
+//
+// See TestWorkflowEnvironment.SetLastError() for unit test support.
+func GetLastError(ctx Context) error {
+	return internal.GetLastError(ctx)
+}
+
 // UpsertSearchAttributes is used to add or update workflow search attributes.
 // The search attributes can be used in query of List/Scan/Count workflow APIs.
 // The key and value type must be registered on cadence server side;
@@ -0,0 +1,164 @@
+package benchmark
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/workflow"
+)
+
+// benchmarks run their primitive n times in workflow coroutines of a single workflow execution.
+var benchmarks = []struct {
+	name     string
+	workflow func(ctx workflow.Context, n int)
+}{
+	{name: "CoroutineSwitch", workflow: coroutineSwitch},
+	{name: "CoroutineSpawn", workflow: coroutineSpawn},
+	{name: "ChannelSendReceive", workflow: channelSendReceive},
+	{name: "ChannelBlockingReceive", workflow: channelBlockingReceive},
+	{name: "Selector", workflow: selector},
+	{name: "FutureResolution", workflow: futureResolution},
+	{name: "FutureBlockingGet", workflow: futureBlockingGet},
+}
+
+func BenchmarkDispatcher(b *testing.B) {
+	for _, benchmark := range benchmarks {
+		fn := benchmark.workflow
+		b.Run(benchmark.name, func(b *testing.B) {
+			b.ReportAllocs()
+			run(b, b.N, fn)
+		})
+	}
+}
+
+// TestDispatcher runs each benchmark for a few iterations, as go test runs the benchmarks only with -bench.
+func TestDispatcher(t *testing.T) {
+	for _, benchmark := range benchmarks {
+		fn := benchmark.workflow
+		t.Run(benchmark.name, func(t *testing.T) {
+			run(t, 10, fn)
+		})
+	}
+}
+
+// run executes fn in a workflow of a new test environment, and only times the execution when tb is a benchmark.
+func run(tb testing.TB, n int, fn func(ctx workflow.Context, n int)) {
+	var suite testsuite.WorkflowTestSuite
+	suite.SetLogger(zap.NewNop())
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context) error {
+		fn(ctx, n)
+		return nil
+	}, workflow.RegisterOptions{Name: "benchmark"})
+
+	if b, ok := tb.(*testing.B); ok {
+		b.ResetTimer()
+		defer b.StopTimer()
+	}
+	env.ExecuteWorkflow("benchmark")
+	require.True(tb, env.IsWorkflowCompleted())
+	require.NoError(tb, env.GetWorkflowError())
+}
+
+// coroutineSwitch measures a round trip between two coroutines, i.e. two switches through the dispatcher.
+func coroutineSwitch(ctx workflow.Context, n int) {
+	ping, pong := workflow.NewChannel(ctx), workflow.NewChannel(ctx)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for i := 0; i < n; i++ {
+			ping.Receive(ctx, nil)
+			pong.Send(ctx, i)
+		}
+	})
+	for i := 0; i < n; i++ {
+		ping.Send(ctx, i)
+		pong.Receive(ctx, nil)
+	}
+}
+
+// coroutineSpawn measures starting a coroutine and waiting for it to complete.
+func coroutineSpawn(ctx workflow.Context, n int) {
+	wg := workflow.NewWaitGroup(ctx)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			wg.Done()
+		})
+		wg.Wait(ctx)
+	}
+}
+
+// channelSendReceive measures a send and a receive on a buffered channel, which do not block.
+func channelSendReceive(ctx workflow.Context, n int) {
+	c := workflow.NewBufferedChannel(ctx, 1)
+	var v int
+	for i := 0; i < n; i++ {
+		c.Send(ctx, i)
+		c.Receive(ctx, &v)
+	}
+}
+
+// channelBlockingReceive measures a receive blocking until another coroutine sends, i.e. one switch.
+func channelBlockingReceive(ctx workflow.Context, n int) {
+	c := workflow.NewChannel(ctx)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for i := 0; i < n; i++ {
+			c.Send(ctx, i)
+		}
+	})
+	var v int
+	for i := 0; i < n; i++ {
+		c.Receive(ctx, &v)
+	}
+}
+
+// selector measures a new selector with two receive cases of which one is ready.
+func selector(ctx workflow.Context, n int) {
+	c1, c2 := workflow.NewBufferedChannel(ctx, 1), workflow.NewBufferedChannel(ctx, 1)
+	var v int
+	for i := 0; i < n; i++ {
+		c := c1
+		if i%2 == 1 {
+			c = c2
+		}
+		c.SendAsync(i)
+		workflow.NewSelector(ctx).
+			AddReceive(c1, func(c workflow.Channel, more bool) { c.Receive(ctx, &v) }).
+			AddReceive(c2, func(c workflow.Channel, more bool) { c.Receive(ctx, &v) }).
+			Select(ctx)
+	}
+}
+
+// futureResolution measures setting a future and getting its value, which does not block.
+func futureResolution(ctx workflow.Context, n int) {
+	var v int
+	for i := 0; i < n; i++ {
+		f, s := workflow.NewFuture(ctx)
+		s.SetValue(i)
+		if err := f.Get(ctx, &v); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// futureBlockingGet measures getting the value of a future blocking until another coroutine sets it.
+func futureBlockingGet(ctx workflow.Context, n int) {
+	settables := workflow.NewChannel(ctx)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		var s workflow.Settable
+		for i := 0; i < n; i++ {
+			settables.Receive(ctx, &s)
+			s.SetValue(i)
+		}
+	})
+	var v int
+	for i := 0; i < n; i++ {
+		f, s := workflow.NewFuture(ctx)
+		settables.Send(ctx, s)
+		if err := f.Get(ctx, &v); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package benchmark measures the primitives of the deterministic dispatcher running workflow code: switching between
+// coroutines, sending and receiving on channels, selecting and resolving futures. The benchmarks are written with the
+// public workflow API and run in the environment of the testsuite package, see the readme for how to compare Go
+// versions or client versions with benchstat.
+package benchmark
@@ -0,0 +1,181 @@
+// Package cadencetest contains assertion helpers for the tests of workflows and activities run by testsuite.
+package cadencetest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/encoded"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/workflow"
+)
+
+// DiagnoseError describes err and the errors it wraps as an indented tree, one error per line, followed by the
+// attributes of the Cadence errors: the reason and details of a CustomError, the timeout type and the last heartbeat
+// details of a TimeoutError, the stack trace of a PanicError, etc. It returns an empty string for a nil error.
+//
+//	require.NoError(t, err, cadencetest.DiagnoseError(err))
+func DiagnoseError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var b strings.Builder
+	diagnoseError(&b, err, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func diagnoseError(b *strings.Builder, err error, depth int) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(b, "%s%s: %s\n", indent, errorName(err), err.Error())
+	for _, attribute := range errorAttributes(err) {
+		for _, line := range strings.Split(strings.TrimRight(attribute, "\n"), "\n") {
+			fmt.Fprintf(b, "%s  | %s\n", indent, line)
+		}
+	}
+	for _, wrapped := range unwrapErrors(err) {
+		diagnoseError(b, wrapped, depth+1)
+	}
+}
+
+func errorName(err error) string {
+	switch err.(type) {
+	case *cadence.CustomError:
+		return "CustomError"
+	case *cadence.CanceledError:
+		return "CanceledError"
+	case *cadence.NonDeterministicError:
+		return "NonDeterministicError"
+	case *workflow.GenericError:
+		return "GenericError"
+	case *workflow.TimeoutError:
+		return "TimeoutError"
+	case *workflow.TerminatedError:
+		return "TerminatedError"
+	case *workflow.PanicError:
+		return "PanicError"
+	case *workflow.ContinueAsNewError:
+		return "ContinueAsNewError"
+	case *workflow.UnknownExternalWorkflowExecutionError:
+		return "UnknownExternalWorkflowExecutionError"
+	}
+	if len(multierr.Errors(err)) > 1 {
+		return "multiple errors"
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+func errorAttributes(err error) []string {
+	switch e := err.(type) {
+	case *cadence.CustomError:
+		return []string{"reason: " + e.Reason(), "details: " + describeDetails(e.HasDetails(), e.Details)}
+	case *cadence.CanceledError:
+		return []string{"details: " + describeDetails(e.HasDetails(), e.Details)}
+	case *workflow.TimeoutError:
+		return []string{
+			"timeout type: " + e.TimeoutType().String(),
+			"heartbeat details: " + describeDetails(e.HasDetails(), e.Details),
+		}
+	case *workflow.PanicError:
+		attributes := []string{"stack trace:\n" + e.StackTrace()}
+		if args := e.WorkflowArgs(); args != "" {
+			attributes = append(attributes, "workflow args: "+args)
+		}
+		return attributes
+	case *workflow.ContinueAsNewError:
+		if workflowType := e.WorkflowType(); workflowType != nil {
+			return []string{"workflow type: " + workflowType.Name}
+		}
+	case *cadence.NonDeterministicError:
+		return []string{"reason: " + e.Reason}
+	}
+	return nil
+}
+
+// describeDetails decodes the first value of the details into an interface{}, as their types are unknown.
+func describeDetails(hasDetails bool, details func(d ...interface{}) error) string {
+	if !hasDetails {
+		return "none"
+	}
+	var value interface{}
+	if err := details(&value); err != nil {
+		return fmt.Sprintf("cannot be decoded: %v", err)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func unwrapErrors(err error) []error {
+	if errs := multierr.Errors(err); len(errs) > 1 {
+		return errs
+	}
+	if wrapper, ok := err.(interface{ Unwrap() []error }); ok {
+		return wrapper.Unwrap()
+	}
+	if wrapped := errors.Unwrap(err); wrapped != nil {
+		return []error{wrapped}
+	}
+	return nil
+}
+
+// EncodedArg matches an argument of a mocked activity or workflow whose JSON encoding with the default DataConverter
+// is equal to the one of expected, ignoring the formatting and the order of the fields. An argument of type []byte or
+// cadence.RawPayload is compared as already encoded. It compares arguments whose type differs from expected, e.g. an
+// interface{} argument decoded as a map with a struct:
+//
+//	env.OnActivity("ChargeActivity", mock.Anything, cadencetest.EncodedArg(Charge{Amount: 10})).Return(nil)
+func EncodedArg(expected interface{}) interface{} {
+	want, err := normalizedJSON(expected)
+	if err != nil {
+		panic(fmt.Sprintf("cadencetest.EncodedArg: %v", err))
+	}
+	return mock.MatchedBy(func(actual interface{}) bool {
+		got, err := normalizedJSON(actual)
+		return err == nil && reflect.DeepEqual(want, got)
+	})
+}
+
+func normalizedJSON(value interface{}) (interface{}, error) {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case cadence.RawPayload:
+		data = v
+	default:
+		var err error
+		if data, err = encoded.GetDefaultDataConverter().ToData(value); err != nil {
+			return nil, err
+		}
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// RequireWorkflowCompletedWith requires the workflow run by env to be completed without error, with a result equal
+// to expected once decoded into the type of expected. The error of a failed workflow is reported with DiagnoseError.
+//
+//	env.ExecuteWorkflow(OrderWorkflow, "order-1")
+//	cadencetest.RequireWorkflowCompletedWith(t, env, OrderResult{Status: "shipped"})
+func RequireWorkflowCompletedWith(t testing.TB, env *testsuite.TestWorkflowEnvironment, expected interface{}) {
+	t.Helper()
+	require.True(t, env.IsWorkflowCompleted(), "the workflow is not completed")
+	err := env.GetWorkflowError()
+	require.NoError(t, err, "the workflow failed:\n%s", DiagnoseError(err))
+	if expected == nil {
+		return
+	}
+	actual := reflect.New(reflect.TypeOf(expected))
+	require.NoError(t, env.GetWorkflowResult(actual.Interface()), "cannot decode the workflow result")
+	require.Equal(t, expected, actual.Elem().Interface())
+}
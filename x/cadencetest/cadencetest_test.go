@@ -0,0 +1,147 @@
+package cadencetest_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/cadence/x/cadencetest"
+)
+
+type charge struct {
+	Account string
+	Amount  int
+}
+
+func chargeActivity(ctx context.Context, c interface{}) error {
+	return nil
+}
+
+func chargeWorkflow(ctx workflow.Context, account string, amount int) (charge, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	})
+	c := charge{Account: account, Amount: amount}
+	if err := workflow.ExecuteActivity(ctx, chargeActivity, c).Get(ctx, nil); err != nil {
+		return charge{}, err
+	}
+	return c, nil
+}
+
+func TestDiagnoseError(t *testing.T) {
+	assert.Empty(t, cadencetest.DiagnoseError(nil))
+
+	err := fmt.Errorf("charge: %w", multierr.Combine(
+		cadence.NewCustomError("insufficient-funds", "missing 10"),
+		workflow.NewTimeoutError(shared.TimeoutTypeHeartbeat),
+	))
+	assert.Equal(t, strings.Join([]string{
+		"*fmt.wrapError: charge: insufficient-funds; TimeoutType: HEARTBEAT",
+		"  multiple errors: insufficient-funds; TimeoutType: HEARTBEAT",
+		"    CustomError: insufficient-funds",
+		"      | reason: insufficient-funds",
+		"      | details: missing 10",
+		"    TimeoutError: TimeoutType: HEARTBEAT",
+		"      | timeout type: HEARTBEAT",
+		"      | heartbeat details: none",
+	}, "\n"), cadencetest.DiagnoseError(err))
+}
+
+func TestDiagnoseErrorPanic(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context) error {
+		panic("boom")
+	}, workflow.RegisterOptions{Name: "panicking"})
+	env.ExecuteWorkflow("panicking")
+	diagnosis := cadencetest.DiagnoseError(env.GetWorkflowError())
+	assert.True(t, strings.HasPrefix(diagnosis, "PanicError: boom\n  | stack trace:\n  | "), diagnosis)
+}
+
+func TestEncodedArgAndRequireWorkflowCompletedWith(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(chargeWorkflow)
+	env.RegisterActivity(chargeActivity)
+	// the argument is decoded as a map, as the activity takes an interface{}
+	env.OnActivity(chargeActivity, mock.Anything, cadencetest.EncodedArg(charge{Account: "a", Amount: 10})).
+		Return(nil).Once()
+	env.OnActivity(chargeActivity, mock.Anything, mock.Anything).
+		Return(cadence.NewCustomError("unexpected-charge"))
+	env.ExecuteWorkflow(chargeWorkflow, "a", 10)
+	cadencetest.RequireWorkflowCompletedWith(t, env, charge{Account: "a", Amount: 10})
+
+	env = s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(chargeWorkflow)
+	env.RegisterActivity(chargeActivity)
+	env.OnActivity(chargeActivity, mock.Anything, cadencetest.EncodedArg([]byte(`{"Amount": 10, "Account": "a"}`))).
+		Return(nil).Once()
+	env.OnActivity(chargeActivity, mock.Anything, mock.Anything).
+		Return(cadence.NewCustomError("unexpected-charge"))
+	env.ExecuteWorkflow(chargeWorkflow, "b", 10)
+
+	failing := &recordingT{TB: t}
+	failing.run(func() {
+		cadencetest.RequireWorkflowCompletedWith(failing, env, charge{Account: "b", Amount: 10})
+	})
+	assert.True(t, failing.failed)
+	require.Len(t, failing.errors, 1)
+	assert.Contains(t, failing.errors[0], "the workflow failed:\n\t            \tCustomError: unexpected-charge\n\t            \t  | reason: unexpected-charge\n\t            \t  | details: none")
+}
+
+func TestRequireWorkflowCompletedWithMismatch(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(chargeWorkflow)
+	env.RegisterActivity(chargeActivity)
+	env.OnActivity(chargeActivity, mock.Anything, mock.Anything).Return(nil)
+	env.ExecuteWorkflow(chargeWorkflow, "a", 10)
+
+	failing := &recordingT{TB: t}
+	failing.run(func() {
+		cadencetest.RequireWorkflowCompletedWith(failing, env, charge{Account: "a", Amount: 20})
+	})
+	assert.True(t, failing.failed)
+	require.Len(t, failing.errors, 1)
+	assert.Contains(t, failing.errors[0], "Not equal")
+}
+
+// recordingT records the failures of the assertions instead of failing the test.
+type recordingT struct {
+	testing.TB
+	errors []string
+	failed bool
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) FailNow() {
+	t.failed = true
+	runtime.Goexit()
+}
+
+func (t *recordingT) run(f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	<-done
+}
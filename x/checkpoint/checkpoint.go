@@ -0,0 +1,156 @@
+package checkpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence/encoded"
+	"go.uber.org/cadence/workflow"
+)
+
+const (
+	defaultSaveTimeout = time.Minute
+	defaultLoadTimeout = time.Minute
+)
+
+type (
+	// Store persists checkpoint data outside of the workflow history.
+	// Data written for a key must never change: checkpoints are content addressed.
+	//
+	// Both methods are called from local activities, never from workflow code, so a replay does not access the store.
+	// The trade-off is on Load: the result of its local activity, i.e. the data read, is recorded in the history of
+	// the run which loads the checkpoint. Saving keeps the data out of the history, and loading records it once per
+	// run, instead of with every activity input, result and ContinueAsNew argument carrying the state.
+	Store interface {
+		// Put stores data under key. It is called from a local activity and may be retried.
+		Put(ctx context.Context, key string, data []byte) error
+		// Get returns the data stored under key. It is called from a local activity and may be retried.
+		Get(ctx context.Context, key string) ([]byte, error)
+	}
+
+	// Ref is the reference to a checkpoint that is kept in the workflow instead of the checkpoint itself.
+	// It is small, serializable and can be passed to the next run when continuing as new.
+	Ref struct {
+		Key      string `json:"key"`
+		Size     int    `json:"size"`
+		Checksum string `json:"checksum"`
+	}
+
+	// Options configures a Checkpointer.
+	Options struct {
+		// Optional: used to encode the checkpointed values.
+		// default: encoded.GetDefaultDataConverter()
+		DataConverter encoded.DataConverter
+
+		// Optional: ScheduleToCloseTimeout of the local activity writing a checkpoint.
+		// default: 1 minute
+		SaveTimeout time.Duration
+
+		// Optional: retry policy of the local activities writing and reading a checkpoint.
+		// default: no retry
+		RetryPolicy *workflow.RetryPolicy
+
+		// Optional: ScheduleToCloseTimeout of the local activity reading a checkpoint.
+		// default: 1 minute
+		LoadTimeout time.Duration
+	}
+
+	// Checkpointer saves workflow state to a Store and tracks the references saved by the current run.
+	// It must be created and used from workflow code only.
+	Checkpointer struct {
+		store   Store
+		options Options
+		refs    []Ref
+	}
+)
+
+// New creates a Checkpointer backed by store.
+func New(store Store, options Options) *Checkpointer {
+	if options.DataConverter == nil {
+		options.DataConverter = encoded.GetDefaultDataConverter()
+	}
+	if options.SaveTimeout <= 0 {
+		options.SaveTimeout = defaultSaveTimeout
+	}
+	if options.LoadTimeout <= 0 {
+		options.LoadTimeout = defaultLoadTimeout
+	}
+	return &Checkpointer{store: store, options: options}
+}
+
+// Save encodes value and writes it to the store through a local activity. Only the returned Ref is recorded
+// in the workflow history, the encoded value itself is not.
+func (c *Checkpointer) Save(ctx workflow.Context, value interface{}) (Ref, error) {
+	data, err := c.options.DataConverter.ToData(value)
+	if err != nil {
+		return Ref{}, fmt.Errorf("checkpoint: unable to encode value: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	ref := Ref{
+		Key:      workflow.GetInfo(ctx).WorkflowExecution.ID + "/" + checksum,
+		Size:     len(data),
+		Checksum: checksum,
+	}
+
+	ctx = workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		ScheduleToCloseTimeout: c.options.SaveTimeout,
+		RetryPolicy:            c.options.RetryPolicy,
+	})
+	if err := workflow.ExecuteLocalActivity(ctx, c.put, ref.Key, data).Get(ctx, nil); err != nil {
+		return Ref{}, err
+	}
+	c.refs = append(c.refs, ref)
+	return ref, nil
+}
+
+// Load reads the checkpoint referenced by ref through a local activity and decodes it into valuePtr. Typically used
+// at the beginning of a run started by ContinueAsNew, or after a reset, with a Ref passed as a workflow argument.
+//
+// The data read is recorded in the history by the local activity, so replays decode it from the history instead of
+// reading the store again, see Store. A read error, or data not matching the checksum of ref, fails the local
+// activity, which is retried according to Options.RetryPolicy, and is then returned.
+func (c *Checkpointer) Load(ctx workflow.Context, ref Ref, valuePtr interface{}) error {
+	ctx = workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		ScheduleToCloseTimeout: c.options.LoadTimeout,
+		RetryPolicy:            c.options.RetryPolicy,
+	})
+	var data []byte
+	if err := workflow.ExecuteLocalActivity(ctx, c.get, ref).Get(ctx, &data); err != nil {
+		return err
+	}
+	c.refs = append(c.refs, ref)
+	return c.options.DataConverter.FromData(data, valuePtr)
+}
+
+// Latest returns the reference saved or loaded most recently by this run.
+func (c *Checkpointer) Latest() (Ref, bool) {
+	if len(c.refs) == 0 {
+		return Ref{}, false
+	}
+	return c.refs[len(c.refs)-1], true
+}
+
+// Refs returns all references saved or loaded by this run, in order.
+func (c *Checkpointer) Refs() []Ref {
+	return append([]Ref(nil), c.refs...)
+}
+
+func (c *Checkpointer) put(ctx context.Context, key string, data []byte) error {
+	return c.store.Put(ctx, key, data)
+}
+
+func (c *Checkpointer) get(ctx context.Context, ref Ref) ([]byte, error) {
+	data, err := c.store.Get(ctx, ref.Key)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: unable to read %v: %w", ref.Key, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != ref.Checksum {
+		return nil, fmt.Errorf("checkpoint: checksum mismatch for %v", ref.Key)
+	}
+	return data, nil
+}
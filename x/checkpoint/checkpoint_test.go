@@ -0,0 +1,174 @@
+package checkpoint_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"go.uber.org/cadence/internal"
+	"go.uber.org/cadence/internal/common/testlogger"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/cadence/x/checkpoint"
+)
+
+type CheckpointTestSuite struct {
+	suite.Suite
+	internal.WorkflowTestSuite
+}
+
+func (s *CheckpointTestSuite) SetupTest() {
+	s.SetLogger(testlogger.NewZap(s.T()))
+}
+
+func TestCheckpointSuite(t *testing.T) {
+	suite.Run(t, new(CheckpointTestSuite))
+}
+
+type memoryStore struct {
+	sync.Mutex
+	data map[string][]byte
+	puts int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: make(map[string][]byte)}
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, data []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.puts++
+	m.data[key] = data
+	return nil
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	data, ok := m.data[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+type state struct {
+	Items []string
+}
+
+func (s *CheckpointTestSuite) TestSaveAndLoad() {
+	store := newMemoryStore()
+	wf := func(ctx workflow.Context) (state, error) {
+		c := checkpoint.New(store, checkpoint.Options{})
+		ref, err := c.Save(ctx, state{Items: []string{"a", "b"}})
+		if err != nil {
+			return state{}, err
+		}
+		latest, ok := c.Latest()
+		s.True(ok)
+		s.Equal(ref, latest)
+
+		var restored state
+		err = checkpoint.New(store, checkpoint.Options{}).Load(ctx, ref, &restored)
+		return restored, err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result state
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal([]string{"a", "b"}, result.Items)
+	s.Equal(1, store.puts)
+	s.Len(store.data, 1)
+}
+
+func (s *CheckpointTestSuite) TestSaveIsContentAddressed() {
+	store := newMemoryStore()
+	wf := func(ctx workflow.Context) ([]checkpoint.Ref, error) {
+		c := checkpoint.New(store, checkpoint.Options{})
+		for _, v := range []string{"x", "y", "x"} {
+			if _, err := c.Save(ctx, v); err != nil {
+				return nil, err
+			}
+		}
+		return c.Refs(), nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+
+	s.NoError(env.GetWorkflowError())
+	var refs []checkpoint.Ref
+	s.NoError(env.GetWorkflowResult(&refs))
+	s.Len(refs, 3)
+	s.Equal(refs[0], refs[2])
+	s.NotEqual(refs[0].Key, refs[1].Key)
+	s.Len(store.data, 2)
+}
+
+func (s *CheckpointTestSuite) TestLoadChecksumMismatch() {
+	store := newMemoryStore()
+	store.data["key"] = []byte(`"tampered"`)
+	wf := func(ctx workflow.Context) error {
+		var v string
+		return checkpoint.New(store, checkpoint.Options{}).Load(ctx, checkpoint.Ref{Key: "key", Checksum: "abc"}, &v)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	s.Contains(env.GetWorkflowError().Error(), "checksum mismatch")
+}
+
+func (s *CheckpointTestSuite) TestLoadNotFound() {
+	store := newMemoryStore()
+	wf := func(ctx workflow.Context) error {
+		var v string
+		return checkpoint.New(store, checkpoint.Options{}).Load(ctx, checkpoint.Ref{Key: "key"}, &v)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	s.Contains(env.GetWorkflowError().Error(), "unable to read key: not found")
+}
+
+type slowStore struct {
+	*memoryStore
+}
+
+func (m slowStore) Get(ctx context.Context, key string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *CheckpointTestSuite) TestLoadTimeout() {
+	store := slowStore{newMemoryStore()}
+	wf := func(ctx workflow.Context) error {
+		var v string
+		return checkpoint.New(store, checkpoint.Options{LoadTimeout: time.Millisecond}).Load(ctx, checkpoint.Ref{Key: "key"}, &v)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+
+	s.True(env.IsWorkflowCompleted())
+	var timeoutErr *workflow.TimeoutError
+	s.True(errors.As(env.GetWorkflowError(), &timeoutErr))
+}
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the encoding of a configuration file.
+type Format int
+
+const (
+	// YAML configuration, which is the default for unknown file extensions.
+	YAML Format = iota
+	// JSON configuration.
+	JSON
+)
+
+const (
+	// TransportGRPC is the default transport, the frontend serves it on port 7833.
+	TransportGRPC = "grpc"
+	// TransportTChannel is the legacy transport, the frontend serves it on port 7933.
+	TransportTChannel = "tchannel"
+
+	defaultServiceName = "cadence-frontend"
+	defaultCallerName  = "cadence-client"
+)
+
+type (
+	// Config describes how to reach the Cadence frontend, and the domains and task lists served by this process.
+	Config struct {
+		Service      Service      `yaml:"service" json:"service"`
+		FeatureFlags FeatureFlags `yaml:"featureFlags" json:"featureFlags"`
+		Domains      []Domain     `yaml:"domains" json:"domains"`
+	}
+
+	// Service is the connection to the Cadence frontend.
+	Service struct {
+		// Endpoint is the host:port of the frontend.
+		Endpoint string `yaml:"endpoint" json:"endpoint"`
+		// Transport is TransportGRPC or TransportTChannel, it defaults to TransportGRPC.
+		Transport string `yaml:"transport" json:"transport"`
+		// ServiceName is the name of the frontend, it defaults to "cadence-frontend".
+		ServiceName string `yaml:"serviceName" json:"serviceName"`
+		// CallerName identifies this process to the frontend, it defaults to "cadence-client".
+		CallerName string `yaml:"callerName" json:"callerName"`
+		// TLS enables TLS when set. The frontend only serves TLS with gRPC, TLS with TChannel requires a TLS
+		// terminating proxy.
+		TLS *TLS `yaml:"tls" json:"tls"`
+	}
+
+	// TLS configures the TLS connection to the frontend.
+	TLS struct {
+		// CAFile is a PEM file with the certificate authorities to verify the frontend with, the system ones are
+		// used when it is empty.
+		CAFile string `yaml:"caFile" json:"caFile"`
+		// CertFile and KeyFile are PEM files with the client certificate, for mutual TLS.
+		CertFile string `yaml:"certFile" json:"certFile"`
+		KeyFile  string `yaml:"keyFile" json:"keyFile"`
+		// ServerName overrides the name the certificate of the frontend is verified against.
+		ServerName string `yaml:"serverName" json:"serverName"`
+		// MinVersion is the minimum TLS version, "1.2" or "1.3". It defaults to "1.2".
+		MinVersion string `yaml:"minVersion" json:"minVersion"`
+		// InsecureSkipVerify disables verifying the certificate of the frontend, only use it for testing.
+		InsecureSkipVerify bool `yaml:"insecureSkipVerify" json:"insecureSkipVerify"`
+		// ReloadInterval is how often the files are read again to pick up rotated certificates, e.g. "5m".
+		// The files are loaded once when it is empty.
+		ReloadInterval string `yaml:"reloadInterval" json:"reloadInterval"`
+	}
+
+	// FeatureFlags are applied to all clients and workers, see client.FeatureFlags.
+	FeatureFlags struct {
+		WorkflowExecutionAlreadyCompletedErrorEnabled bool `yaml:"workflowExecutionAlreadyCompletedErrorEnabled" json:"workflowExecutionAlreadyCompletedErrorEnabled"`
+		EphemeralTaskListsEnabled                     bool `yaml:"ephemeralTaskListsEnabled" json:"ephemeralTaskListsEnabled"`
+	}
+
+	// Domain is a domain used by this process, and the task lists it polls in that domain.
+	Domain struct {
+		Name    string   `yaml:"name" json:"name"`
+		Workers []Worker `yaml:"workers" json:"workers"`
+	}
+
+	// Worker configures the worker polling a task list. Zero values keep the worker.Options passed to NewWorker,
+	// see worker.Options for the meaning of each field.
+	Worker struct {
+		TaskList string `yaml:"taskList" json:"taskList"`
+
+		MaxConcurrentActivityExecutionSize      int     `yaml:"maxConcurrentActivityExecutionSize" json:"maxConcurrentActivityExecutionSize"`
+		MaxConcurrentLocalActivityExecutionSize int     `yaml:"maxConcurrentLocalActivityExecutionSize" json:"maxConcurrentLocalActivityExecutionSize"`
+		MaxConcurrentDecisionTaskExecutionSize  int     `yaml:"maxConcurrentDecisionTaskExecutionSize" json:"maxConcurrentDecisionTaskExecutionSize"`
+		MaxConcurrentActivityTaskPollers        int     `yaml:"maxConcurrentActivityTaskPollers" json:"maxConcurrentActivityTaskPollers"`
+		MaxConcurrentDecisionTaskPollers        int     `yaml:"maxConcurrentDecisionTaskPollers" json:"maxConcurrentDecisionTaskPollers"`
+		WorkerActivitiesPerSecond               float64 `yaml:"workerActivitiesPerSecond" json:"workerActivitiesPerSecond"`
+		TaskListActivitiesPerSecond             float64 `yaml:"taskListActivitiesPerSecond" json:"taskListActivitiesPerSecond"`
+
+		DisableWorkflowWorker bool `yaml:"disableWorkflowWorker" json:"disableWorkflowWorker"`
+		DisableActivityWorker bool `yaml:"disableActivityWorker" json:"disableActivityWorker"`
+		EnableSessionWorker   bool `yaml:"enableSessionWorker" json:"enableSessionWorker"`
+	}
+)
+
+var tlsVersions = map[string]uint16{
+	"":    0,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ${NAME} or ${NAME:-default}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Load reads the configuration file, JSON if its extension is ".json" and YAML otherwise, see Parse.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := YAML
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = JSON
+	}
+	config, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return config, nil
+}
+
+// Parse decodes the configuration, sets the defaults and validates it.
+//
+// Environment variables are expanded in the string values after decoding: ${NAME} is replaced by the value of NAME,
+// and ${NAME:-default} by default when NAME is unset or empty, which allows overriding string values per environment.
+// Expanded values are never decoded themselves, so they cannot change the structure of the configuration. Unknown
+// fields are rejected, so that misspelled settings are not silently ignored.
+func Parse(data []byte, format Format) (*Config, error) {
+	var config Config
+	switch format {
+	case JSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	default:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	}
+	expandEnv(reflect.ValueOf(&config))
+
+	if config.Service.Transport == "" {
+		config.Service.Transport = TransportGRPC
+	}
+	if config.Service.ServiceName == "" {
+		config.Service.ServiceName = defaultServiceName
+	}
+	if config.Service.CallerName == "" {
+		config.Service.CallerName = defaultCallerName
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// expandEnv expands the environment variables in all the strings reachable from v
+func expandEnv(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			expandEnv(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			expandEnv(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandEnv(v.Index(i))
+		}
+	case reflect.String:
+		v.SetString(envPattern.ReplaceAllStringFunc(v.String(), func(match string) string {
+			groups := envPattern.FindStringSubmatch(match)
+			if value := os.Getenv(groups[1]); value != "" {
+				return value
+			}
+			return groups[3]
+		}))
+	}
+}
+
+// Validate returns all the errors of the configuration, or nil if it is valid.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Service.Endpoint == "" {
+		errs = append(errs, errors.New("service.endpoint is required"))
+	}
+	if transport := c.Service.Transport; transport != TransportGRPC && transport != TransportTChannel {
+		errs = append(errs, fmt.Errorf("service.transport %q is not one of %q or %q", transport, TransportGRPC, TransportTChannel))
+	}
+	if tls := c.Service.TLS; tls != nil {
+		if (tls.CertFile == "") != (tls.KeyFile == "") {
+			errs = append(errs, errors.New("service.tls.certFile and service.tls.keyFile must be set together"))
+		}
+		if _, ok := tlsVersions[tls.MinVersion]; !ok {
+			errs = append(errs, fmt.Errorf("service.tls.minVersion %q is not one of \"1.2\" or \"1.3\"", tls.MinVersion))
+		}
+		if tls.ReloadInterval != "" {
+			if interval, err := time.ParseDuration(tls.ReloadInterval); err != nil || interval <= 0 {
+				errs = append(errs, fmt.Errorf("service.tls.reloadInterval %q is not a positive duration", tls.ReloadInterval))
+			}
+		}
+	}
+
+	domains := make(map[string]bool, len(c.Domains))
+	for i, domain := range c.Domains {
+		if domain.Name == "" {
+			errs = append(errs, fmt.Errorf("domains[%d].name is required", i))
+		} else if domains[domain.Name] {
+			errs = append(errs, fmt.Errorf("domains[%d]: domain %q is configured more than once", i, domain.Name))
+		}
+		domains[domain.Name] = true
+
+		taskLists := make(map[string]bool, len(domain.Workers))
+		for j, w := range domain.Workers {
+			path := fmt.Sprintf("domains[%d].workers[%d]", i, j)
+			if w.TaskList == "" {
+				errs = append(errs, fmt.Errorf("%v.taskList is required", path))
+			} else if taskLists[w.TaskList] {
+				errs = append(errs, fmt.Errorf("%v: task list %q is configured more than once", path, w.TaskList))
+			}
+			taskLists[w.TaskList] = true
+			if w.DisableWorkflowWorker && w.DisableActivityWorker {
+				errs = append(errs, fmt.Errorf("%v: both the workflow and the activity worker are disabled", path))
+			}
+			if w.MaxConcurrentActivityExecutionSize < 0 || w.MaxConcurrentLocalActivityExecutionSize < 0 ||
+				w.MaxConcurrentDecisionTaskExecutionSize < 0 || w.MaxConcurrentActivityTaskPollers < 0 ||
+				w.MaxConcurrentDecisionTaskPollers < 0 || w.WorkerActivitiesPerSecond < 0 || w.TaskListActivitiesPerSecond < 0 {
+				errs = append(errs, fmt.Errorf("%v: concurrency and rate limits must not be negative", path))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Domain returns the configuration of the domain, or nil if it is not configured.
+func (c *Config) Domain(name string) *Domain {
+	for i := range c.Domains {
+		if c.Domains[i].Name == name {
+			return &c.Domains[i]
+		}
+	}
+	return nil
+}
+
+// Worker returns the configuration of the task list, or nil if it is not configured.
+func (d *Domain) Worker(taskList string) *Worker {
+	for i := range d.Workers {
+		if d.Workers[i].TaskList == taskList {
+			return &d.Workers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
+)
+
+const testYAML = `
+service:
+  endpoint: ${TEST_CADENCE_ENDPOINT:-localhost:7833}
+featureFlags:
+  workflowExecutionAlreadyCompletedErrorEnabled: true
+domains:
+  - name: orders
+    workers:
+      - taskList: checkout
+        maxConcurrentActivityExecutionSize: 50
+        workerActivitiesPerSecond: 10.5
+      - taskList: ${TEST_CADENCE_TASKLIST}
+        disableWorkflowWorker: true
+`
+
+func TestParse(t *testing.T) {
+	t.Setenv("TEST_CADENCE_TASKLIST", "shipping")
+
+	config, err := Parse([]byte(testYAML), YAML)
+	require.NoError(t, err)
+	assert.Equal(t, Service{
+		Endpoint:    "localhost:7833",
+		Transport:   TransportGRPC,
+		ServiceName: "cadence-frontend",
+		CallerName:  "cadence-client",
+	}, config.Service)
+	assert.True(t, config.FeatureFlags.WorkflowExecutionAlreadyCompletedErrorEnabled)
+	require.NotNil(t, config.Domain("orders"))
+	assert.Nil(t, config.Domain("payments"))
+	assert.Equal(t, &Worker{
+		TaskList:                           "checkout",
+		MaxConcurrentActivityExecutionSize: 50,
+		WorkerActivitiesPerSecond:          10.5,
+	}, config.Domain("orders").Worker("checkout"))
+	assert.Equal(t, &Worker{TaskList: "shipping", DisableWorkflowWorker: true}, config.Domain("orders").Worker("shipping"))
+
+	t.Setenv("TEST_CADENCE_ENDPOINT", "cadence:7833")
+	config, err = Parse([]byte(testYAML), YAML)
+	require.NoError(t, err)
+	assert.Equal(t, "cadence:7833", config.Service.Endpoint, "environment overrides the default")
+
+	t.Setenv("TEST_CADENCE_TASKLIST", "shipping\n        disableActivityWorker: true")
+	config, err = Parse([]byte(testYAML), YAML)
+	require.NoError(t, err)
+	worker := config.Domain("orders").Worker("shipping\n        disableActivityWorker: true")
+	require.NotNil(t, worker, "expanded values are not decoded")
+	assert.False(t, worker.DisableActivityWorker)
+}
+
+func TestParseJSON(t *testing.T) {
+	config, err := Parse([]byte(`{
+		"service": {"endpoint": "localhost:7933", "transport": "tchannel"},
+		"domains": [{"name": "orders", "workers": [{"taskList": "checkout"}]}]
+	}`), JSON)
+	require.NoError(t, err)
+	assert.Equal(t, TransportTChannel, config.Service.Transport)
+	assert.NotNil(t, config.Domain("orders").Worker("checkout"))
+
+	t.Setenv("TEST_CADENCE_ENDPOINT", "cadence:7933")
+	config, err = Parse([]byte(`{"service": {"endpoint": "${TEST_CADENCE_ENDPOINT}"}}`), JSON)
+	require.NoError(t, err)
+	assert.Equal(t, "cadence:7933", config.Service.Endpoint)
+
+	_, err = Parse([]byte(`{"service": {"endpoint": "localhost:7933", "endpiont": "typo"}}`), JSON)
+	assert.ErrorContains(t, err, "endpiont")
+}
+
+func TestValidate(t *testing.T) {
+	_, err := Parse([]byte(`
+service:
+  transport: http
+  tls:
+    certFile: cert.pem
+domains:
+  - name: orders
+    workers:
+      - taskList: checkout
+        disableWorkflowWorker: true
+        disableActivityWorker: true
+      - taskList: checkout
+        maxConcurrentDecisionTaskPollers: -1
+  - name: orders
+  - workers:
+      - {}
+`), YAML)
+	require.Error(t, err)
+	for _, expected := range []string{
+		"service.endpoint is required",
+		`service.transport "http" is not one of "grpc" or "tchannel"`,
+		"service.tls.certFile and service.tls.keyFile must be set together",
+		"domains[0].workers[0]: both the workflow and the activity worker are disabled",
+		`domains[0].workers[1]: task list "checkout" is configured more than once`,
+		"domains[0].workers[1]: concurrency and rate limits must not be negative",
+		`domains[1]: domain "orders" is configured more than once`,
+		"domains[2].name is required",
+		"domains[2].workers[0].taskList is required",
+	} {
+		assert.ErrorContains(t, err, expected)
+	}
+
+	_, err = Parse([]byte("service:\n  endpoint: localhost:7833\n  tls:\n    minVersion: \"1.1\"\n    reloadInterval: soon\n"), YAML)
+	assert.ErrorContains(t, err, `service.tls.minVersion "1.1" is not one of "1.2" or "1.3"`)
+	assert.ErrorContains(t, err, `service.tls.reloadInterval "soon" is not a positive duration`)
+
+	_, err = Parse([]byte("service:\n  endpiont: localhost:7833\n"), YAML)
+	assert.ErrorContains(t, err, "endpiont", "unknown fields are rejected")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "cadence.yaml")
+	jsonPath := filepath.Join(dir, "cadence.json")
+	require.NoError(t, os.WriteFile(yamlPath, []byte("service:\n  endpoint: localhost:7833\n"), 0600))
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"service": {"endpoint": "localhost:7833"}}`), 0600))
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		config, err := Load(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, "localhost:7833", config.Service.Endpoint)
+	}
+
+	_, err := Load(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestWorkerApply(t *testing.T) {
+	options := worker.Options{
+		MaxConcurrentActivityExecutionSize: 10,
+		MaxConcurrentDecisionTaskPollers:   4,
+		EnableSessionWorker:                true,
+	}
+	w := Worker{
+		TaskList:                           "checkout",
+		MaxConcurrentActivityExecutionSize: 50,
+		TaskListActivitiesPerSecond:        2.5,
+		DisableActivityWorker:              true,
+	}
+	w.apply(&options)
+	assert.Equal(t, worker.Options{
+		MaxConcurrentActivityExecutionSize: 50,
+		MaxConcurrentDecisionTaskPollers:   4,
+		TaskListActivitiesPerSecond:        2.5,
+		DisableActivityWorker:              true,
+		EnableSessionWorker:                true,
+	}, options)
+}
+
+func TestConnection(t *testing.T) {
+	for _, transport := range []string{TransportGRPC, TransportTChannel} {
+		t.Run(transport, func(t *testing.T) {
+			config, err := Parse([]byte(`
+service:
+  endpoint: 127.0.0.1:7833
+  transport: `+transport+`
+featureFlags:
+  ephemeralTaskListsEnabled: true
+domains:
+  - name: orders
+    workers:
+      - taskList: checkout
+      - taskList: shipping
+`), YAML)
+			require.NoError(t, err)
+			connection, err := Dial(config)
+			require.NoError(t, err)
+			defer connection.Close()
+			assert.NotNil(t, connection.Service())
+
+			_, err = connection.NewClient("orders", nil)
+			assert.NoError(t, err)
+			_, err = connection.NewClient("payments", nil)
+			assert.ErrorContains(t, err, `domain "payments" is not configured`)
+			assert.NotNil(t, connection.NewDomainClient(nil))
+
+			_, err = connection.NewWorker("orders", "checkout", worker.Options{})
+			assert.NoError(t, err)
+			_, err = connection.NewWorker("orders", "billing", worker.Options{})
+			assert.ErrorContains(t, err, `task list "billing" of domain "orders" is not configured`)
+			workers, err := connection.NewWorkers("orders", worker.Options{})
+			require.NoError(t, err)
+			assert.Len(t, workers, 2)
+			assert.Contains(t, workers, "shipping")
+		})
+	}
+}
+
+func TestTLS(t *testing.T) {
+	config := &Config{Service: Service{
+		Endpoint:    "127.0.0.1:7833",
+		Transport:   TransportGRPC,
+		ServiceName: defaultServiceName,
+		CallerName:  defaultCallerName,
+		TLS:         &TLS{ServerName: "cadence.example.com"},
+	}}
+	connection, err := Dial(config)
+	require.NoError(t, err)
+	require.NoError(t, connection.Close())
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0600))
+	config.Service.TLS.CAFile = caFile
+	_, err = Dial(config)
+	assert.ErrorContains(t, err, "does not contain any PEM certificate")
+
+	config.Service.Transport = TransportTChannel
+	config.Service.TLS = &TLS{MinVersion: "1.3", ReloadInterval: "5m"}
+	assert.Equal(t, client.TLSOptions{MinVersion: tls.VersionTLS13, ReloadInterval: 5 * time.Minute}, config.Service.TLS.options())
+	connection, err = Dial(config)
+	require.NoError(t, err)
+	require.NoError(t, connection.Close())
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	apiv1 "github.com/uber/cadence-idl/go/proto/api/v1"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/transport/grpc"
+	"go.uber.org/yarpc/transport/tchannel"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/compatibility"
+	"go.uber.org/cadence/worker"
+)
+
+// Connection is a connection to the frontend configured by a Config, it builds the clients and workers of the
+// configured domains and task lists.
+type Connection struct {
+	config     *Config
+	service    workflowserviceclient.Interface
+	dispatcher *yarpc.Dispatcher
+}
+
+// Dial connects to the frontend of the configuration. Close must be called once the clients and workers are not
+// used anymore.
+func Dial(config *Config) (*Connection, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	outbound, err := newOutbound(config.Service)
+	if err != nil {
+		return nil, err
+	}
+	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name: config.Service.CallerName,
+		Outbounds: yarpc.Outbounds{
+			config.Service.ServiceName: {Unary: outbound},
+		},
+	})
+	if err := dispatcher.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start dispatcher: %w", err)
+	}
+
+	clientConfig := dispatcher.ClientConfig(config.Service.ServiceName)
+	var service workflowserviceclient.Interface
+	if config.Service.Transport == TransportTChannel {
+		service = workflowserviceclient.New(clientConfig)
+	} else {
+		service = compatibility.NewThrift2ProtoAdapter(
+			apiv1.NewDomainAPIYARPCClient(clientConfig),
+			apiv1.NewWorkflowAPIYARPCClient(clientConfig),
+			apiv1.NewWorkerAPIYARPCClient(clientConfig),
+			apiv1.NewVisibilityAPIYARPCClient(clientConfig),
+		)
+	}
+	return &Connection{config: config, service: service, dispatcher: dispatcher}, nil
+}
+
+func newOutbound(service Service) (transport.UnaryOutbound, error) {
+	if service.Transport == TransportTChannel {
+		options := []tchannel.TransportOption{tchannel.ServiceName(service.CallerName)}
+		if service.TLS != nil {
+			tlsConfig, err := client.NewTLSConfig(service.TLS.options())
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, tchannel.Dialer((&tls.Dialer{Config: tlsConfig}).DialContext))
+		}
+		t, err := tchannel.NewTransport(options...)
+		if err != nil {
+			return nil, err
+		}
+		return t.NewSingleOutbound(service.Endpoint), nil
+	}
+
+	if service.TLS != nil {
+		return client.NewGRPCOutbound(service.Endpoint, service.TLS.options())
+	}
+	return grpc.NewTransport().NewSingleOutbound(service.Endpoint), nil
+}
+
+// options converts the validated configuration.
+func (t *TLS) options() client.TLSOptions {
+	reloadInterval, _ := time.ParseDuration(t.ReloadInterval)
+	return client.TLSOptions{
+		CAFile:             t.CAFile,
+		CertFile:           t.CertFile,
+		KeyFile:            t.KeyFile,
+		ServerName:         t.ServerName,
+		MinVersion:         tlsVersions[t.MinVersion],
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		ReloadInterval:     reloadInterval,
+	}
+}
+
+// Service returns the connection to the frontend, e.g. for worker.NewWorkflowReplayer.
+func (c *Connection) Service() workflowserviceclient.Interface {
+	return c.service
+}
+
+// NewClient returns a client of the domain with the configured feature flags. The domain must be configured.
+// The options may be nil.
+func (c *Connection) NewClient(domain string, options *client.Options) (client.Client, error) {
+	if c.config.Domain(domain) == nil {
+		return nil, fmt.Errorf("domain %q is not configured", domain)
+	}
+	var opts client.Options
+	if options != nil {
+		opts = *options
+	}
+	opts.FeatureFlags = c.featureFlags(opts.FeatureFlags)
+	return client.NewClient(c.service, domain, &opts), nil
+}
+
+// NewDomainClient returns a client to manage domains.
+func (c *Connection) NewDomainClient(options *client.Options) client.DomainClient {
+	var opts client.Options
+	if options != nil {
+		opts = *options
+	}
+	opts.FeatureFlags = c.featureFlags(opts.FeatureFlags)
+	return client.NewDomainClient(c.service, &opts)
+}
+
+// NewWorker returns a worker polling the task list of the domain, both must be configured. The configured values
+// override the ones of options, which sets everything that cannot be configured, e.g. the logger or metrics scope.
+func (c *Connection) NewWorker(domain, taskList string, options worker.Options) (worker.Worker, error) {
+	d := c.config.Domain(domain)
+	if d == nil {
+		return nil, fmt.Errorf("domain %q is not configured", domain)
+	}
+	w := d.Worker(taskList)
+	if w == nil {
+		return nil, fmt.Errorf("task list %q of domain %q is not configured", taskList, domain)
+	}
+	options.FeatureFlags = c.featureFlags(options.FeatureFlags)
+	w.apply(&options)
+	return worker.New(c.service, domain, taskList, options), nil
+}
+
+// NewWorkers returns the workers of all the configured task lists of the domain, keyed by task list.
+func (c *Connection) NewWorkers(domain string, options worker.Options) (map[string]worker.Worker, error) {
+	d := c.config.Domain(domain)
+	if d == nil {
+		return nil, fmt.Errorf("domain %q is not configured", domain)
+	}
+	workers := make(map[string]worker.Worker, len(d.Workers))
+	for _, w := range d.Workers {
+		var err error
+		if workers[w.TaskList], err = c.NewWorker(domain, w.TaskList, options); err != nil {
+			return nil, err
+		}
+	}
+	return workers, nil
+}
+
+// Close closes the connection to the frontend.
+func (c *Connection) Close() error {
+	return c.dispatcher.Stop()
+}
+
+func (c *Connection) featureFlags(flags client.FeatureFlags) client.FeatureFlags {
+	flags.WorkflowExecutionAlreadyCompletedErrorEnabled = flags.WorkflowExecutionAlreadyCompletedErrorEnabled ||
+		c.config.FeatureFlags.WorkflowExecutionAlreadyCompletedErrorEnabled
+	flags.EphemeralTaskListsEnabled = flags.EphemeralTaskListsEnabled || c.config.FeatureFlags.EphemeralTaskListsEnabled
+	return flags
+}
+
+func (w *Worker) apply(options *worker.Options) {
+	setIfPositive(&options.MaxConcurrentActivityExecutionSize, w.MaxConcurrentActivityExecutionSize)
+	setIfPositive(&options.MaxConcurrentLocalActivityExecutionSize, w.MaxConcurrentLocalActivityExecutionSize)
+	setIfPositive(&options.MaxConcurrentDecisionTaskExecutionSize, w.MaxConcurrentDecisionTaskExecutionSize)
+	setIfPositive(&options.MaxConcurrentActivityTaskPollers, w.MaxConcurrentActivityTaskPollers)
+	setIfPositive(&options.MaxConcurrentDecisionTaskPollers, w.MaxConcurrentDecisionTaskPollers)
+	setIfPositive(&options.WorkerActivitiesPerSecond, w.WorkerActivitiesPerSecond)
+	setIfPositive(&options.TaskListActivitiesPerSecond, w.TaskListActivitiesPerSecond)
+	options.DisableWorkflowWorker = options.DisableWorkflowWorker || w.DisableWorkflowWorker
+	options.DisableActivityWorker = options.DisableActivityWorker || w.DisableActivityWorker
+	options.EnableSessionWorker = options.EnableSessionWorker || w.EnableSessionWorker
+}
+
+func setIfPositive[T int | float64](option *T, value T) {
+	if value > 0 {
+		*option = value
+	}
+}
@@ -0,0 +1,242 @@
+package configsnapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/workflow"
+)
+
+const (
+	// DefaultQueryType is the query returning the snapshot currently used by the workflow.
+	DefaultQueryType = "config_snapshot"
+
+	// SchemaChangedCounter is incremented when the schema of the configuration changes, within a run or since the
+	// snapshot passed by the previous run.
+	SchemaChangedCounter = "cadence-config-schema-changed"
+
+	defaultFetchTimeout = time.Minute
+)
+
+type (
+	// Source returns the JSON configuration. It is called from a local activity and may be retried.
+	Source func(ctx context.Context) ([]byte, error)
+
+	// Snapshot is a version of the configuration used by a workflow. It is small enough to be passed to the next run
+	// when continuing as new, so that the versions and schema changes are tracked across runs.
+	Snapshot struct {
+		// Version is incremented each time the content of the configuration changes.
+		Version int `json:"version"`
+		// Checksum is the SHA-256 of the configuration.
+		Checksum string `json:"checksum"`
+		// Schema is the sorted list of the fields of the configuration with the JSON kind of their value, e.g.
+		// "limits.rate number", which is compared to detect schema changes.
+		Schema []string `json:"schema"`
+		// FetchedAt is the workflow time at which the configuration was fetched.
+		FetchedAt time.Time `json:"fetchedAt"`
+		// Config is the configuration itself.
+		Config json.RawMessage `json:"config"`
+	}
+
+	// Options configures a Snapshotter.
+	Options struct {
+		// Optional: query type returning the current Snapshot, or an error if none was fetched yet.
+		// default: DefaultQueryType
+		QueryType string
+
+		// Optional: ScheduleToCloseTimeout of the local activity fetching the configuration.
+		// default: 1 minute
+		FetchTimeout time.Duration
+
+		// Optional: retry policy of the local activity fetching the configuration.
+		// default: no retry
+		RetryPolicy *workflow.RetryPolicy
+	}
+
+	// Snapshotter fetches the configuration of a workflow and keeps the snapshot it currently uses.
+	// It must be created and used from workflow code only.
+	Snapshotter struct {
+		source  Source
+		options Options
+		current *Snapshot
+	}
+)
+
+// New creates a Snapshotter fetching from source, and registers the query returning its current snapshot.
+// previous is the snapshot used by the previous run, if any, typically passed as an argument by ContinueAsNew: it is
+// the current snapshot until Fetch is called, and the first fetched configuration is compared to it.
+func New(ctx workflow.Context, source Source, options Options, previous *Snapshot) (*Snapshotter, error) {
+	if options.QueryType == "" {
+		options.QueryType = DefaultQueryType
+	}
+	if options.FetchTimeout <= 0 {
+		options.FetchTimeout = defaultFetchTimeout
+	}
+	s := &Snapshotter{source: source, options: options, current: previous}
+	if err := workflow.SetQueryHandler(ctx, options.QueryType, s.query); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Fetch reads the configuration through a local activity, which records it in the history so that replays use the
+// same one, and makes it the current snapshot. The version is incremented when the content changed. When the schema
+// changed, a warning is logged and SchemaChangedCounter is incremented, as the workflow code may not handle the new
+// or missing fields.
+func (s *Snapshotter) Fetch(ctx workflow.Context) (Snapshot, error) {
+	ctx = workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		ScheduleToCloseTimeout: s.options.FetchTimeout,
+		RetryPolicy:            s.options.RetryPolicy,
+	})
+	var config json.RawMessage
+	if err := workflow.ExecuteLocalActivity(ctx, s.fetch).Get(ctx, &config); err != nil {
+		return Snapshot{}, err
+	}
+	schema, err := Schema(config)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	sum := sha256.Sum256(config)
+	snapshot := Snapshot{
+		Version:   1,
+		Checksum:  hex.EncodeToString(sum[:]),
+		Schema:    schema,
+		FetchedAt: workflow.Now(ctx),
+		Config:    config,
+	}
+
+	if previous := s.current; previous != nil {
+		snapshot.Version = previous.Version
+		if previous.Checksum != snapshot.Checksum {
+			snapshot.Version++
+		}
+		if added, removed := diff(previous.Schema, snapshot.Schema); len(added) > 0 || len(removed) > 0 {
+			workflow.GetLogger(ctx).Warn("Configuration schema changed.",
+				zap.Int("PreviousVersion", previous.Version),
+				zap.Int("Version", snapshot.Version),
+				zap.Strings("AddedFields", added),
+				zap.Strings("RemovedFields", removed))
+			workflow.GetMetricsScope(ctx).Counter(SchemaChangedCounter).Inc(1)
+		}
+	}
+	s.current = &snapshot
+	return snapshot, nil
+}
+
+// Current returns the snapshot currently used, which is the one passed to New until Fetch is called.
+func (s *Snapshotter) Current() (Snapshot, bool) {
+	if s.current == nil {
+		return Snapshot{}, false
+	}
+	return *s.current, true
+}
+
+// Decode decodes the current configuration into valuePtr.
+func (s *Snapshotter) Decode(valuePtr interface{}) error {
+	if s.current == nil {
+		return errors.New("configsnapshot: no configuration was fetched")
+	}
+	return json.Unmarshal(s.current.Config, valuePtr)
+}
+
+func (s *Snapshotter) query() (Snapshot, error) {
+	snapshot, ok := s.Current()
+	if !ok {
+		return Snapshot{}, errors.New("configsnapshot: no configuration was fetched")
+	}
+	return snapshot, nil
+}
+
+func (s *Snapshotter) fetch(ctx context.Context) (json.RawMessage, error) {
+	config, err := s.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(config) {
+		return nil, errors.New("configsnapshot: the configuration is not valid JSON")
+	}
+	return config, nil
+}
+
+// Schema returns the sorted fields of the JSON configuration with the JSON kind of their value, e.g.
+// "limits.rate number". The elements of arrays are merged under "[]", e.g. "hosts[].name string".
+func Schema(config []byte) ([]string, error) {
+	var value interface{}
+	if err := json.Unmarshal(config, &value); err != nil {
+		return nil, fmt.Errorf("configsnapshot: invalid configuration: %w", err)
+	}
+	fields := map[string]bool{}
+	collectSchema(fields, "", value)
+	schema := make([]string, 0, len(fields))
+	for field := range fields {
+		schema = append(schema, field)
+	}
+	sort.Strings(schema)
+	return schema, nil
+}
+
+func collectSchema(fields map[string]bool, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			collectSchema(fields, childPath, child)
+		}
+		if path != "" {
+			fields[path+" object"] = true
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectSchema(fields, path+"[]", child)
+		}
+		fields[strings.TrimSpace(path+" array")] = true
+	default:
+		fields[strings.TrimSpace(path+" "+kind(v))] = true
+	}
+}
+
+func kind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// diff returns the fields of schema not in previous, and the ones of previous not in schema.
+func diff(previous, schema []string) (added, removed []string) {
+	before := make(map[string]bool, len(previous))
+	for _, field := range previous {
+		before[field] = true
+	}
+	after := make(map[string]bool, len(schema))
+	for _, field := range schema {
+		after[field] = true
+		if !before[field] {
+			added = append(added, field)
+		}
+	}
+	for _, field := range previous {
+		if !after[field] {
+			removed = append(removed, field)
+		}
+	}
+	return added, removed
+}
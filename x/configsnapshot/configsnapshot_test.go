@@ -0,0 +1,160 @@
+package configsnapshot_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.uber.org/cadence/internal"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/cadence/x/configsnapshot"
+)
+
+type ConfigSnapshotTestSuite struct {
+	suite.Suite
+	internal.WorkflowTestSuite
+
+	logs  *observer.ObservedLogs
+	scope tally.TestScope
+}
+
+func (s *ConfigSnapshotTestSuite) SetupTest() {
+	var core zapcore.Core
+	core, s.logs = observer.New(zapcore.WarnLevel)
+	s.SetLogger(zap.New(core))
+	s.scope = tally.NewTestScope("", nil)
+	s.SetMetricsScope(s.scope)
+}
+
+func TestConfigSnapshotSuite(t *testing.T) {
+	suite.Run(t, new(ConfigSnapshotTestSuite))
+}
+
+type limits struct {
+	Rate  int      `json:"rate"`
+	Hosts []string `json:"hosts"`
+}
+
+// source returns the configurations in order, and then the last one
+func source(configs ...string) configsnapshot.Source {
+	calls := 0
+	return func(ctx context.Context) ([]byte, error) {
+		config := configs[calls]
+		if calls < len(configs)-1 {
+			calls++
+		}
+		return []byte(config), nil
+	}
+}
+
+func (s *ConfigSnapshotTestSuite) TestFetch() {
+	wf := func(ctx workflow.Context) ([]configsnapshot.Snapshot, error) {
+		snapshotter, err := configsnapshot.New(ctx, source(
+			`{"rate": 10, "hosts": ["a"]}`,
+			`{"rate": 10, "hosts": ["a"]}`,
+			`{"rate": 20, "hosts": ["a", "b"]}`,
+		), configsnapshot.Options{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := snapshotter.Current()
+		s.False(ok)
+		s.Error(snapshotter.Decode(&limits{}))
+
+		var snapshots []configsnapshot.Snapshot
+		for i := 0; i < 3; i++ {
+			snapshot, err := snapshotter.Fetch(ctx)
+			if err != nil {
+				return nil, err
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		var l limits
+		s.NoError(snapshotter.Decode(&l))
+		s.Equal(limits{Rate: 20, Hosts: []string{"a", "b"}}, l)
+		return snapshots, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var snapshots []configsnapshot.Snapshot
+	s.NoError(env.GetWorkflowResult(&snapshots))
+	s.Equal([]int{1, 1, 2}, []int{snapshots[0].Version, snapshots[1].Version, snapshots[2].Version})
+	s.Equal(snapshots[0].Checksum, snapshots[1].Checksum)
+	s.NotEqual(snapshots[1].Checksum, snapshots[2].Checksum)
+	s.Equal([]string{"hosts array", "hosts[] string", "rate number"}, snapshots[2].Schema)
+	s.Zero(s.logs.Len(), "the schema did not change")
+
+	value, err := env.QueryWorkflow(configsnapshot.DefaultQueryType)
+	s.NoError(err)
+	var current configsnapshot.Snapshot
+	s.NoError(value.Get(&current))
+	s.Equal(2, current.Version)
+	s.JSONEq(`{"rate": 20, "hosts": ["a", "b"]}`, string(current.Config))
+}
+
+func (s *ConfigSnapshotTestSuite) TestSchemaChangedSincePreviousRun() {
+	wf := func(ctx workflow.Context, previous *configsnapshot.Snapshot) (configsnapshot.Snapshot, error) {
+		snapshotter, err := configsnapshot.New(ctx, source(`{"rate": 10, "burst": 5}`), configsnapshot.Options{QueryType: "config"}, previous)
+		if err != nil {
+			return configsnapshot.Snapshot{}, err
+		}
+		current, ok := snapshotter.Current()
+		s.True(ok)
+		s.Equal(*previous, current)
+		return snapshotter.Fetch(ctx)
+	}
+	schema, err := configsnapshot.Schema([]byte(`{"rate": 10, "hosts": ["a"]}`))
+	s.NoError(err)
+	previous := &configsnapshot.Snapshot{Version: 3, Checksum: "previous", Schema: schema, Config: []byte(`{"rate": 10, "hosts": ["a"]}`)}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf, previous)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var snapshot configsnapshot.Snapshot
+	s.NoError(env.GetWorkflowResult(&snapshot))
+	s.Equal(4, snapshot.Version)
+
+	logs := s.logs.FilterMessage("Configuration schema changed.").All()
+	s.Require().Len(logs, 1)
+	s.Equal([]interface{}{"burst number"}, logs[0].ContextMap()["AddedFields"])
+	s.Equal([]interface{}{"hosts array", "hosts[] string"}, logs[0].ContextMap()["RemovedFields"])
+	s.Equal(int64(1), s.scope.Snapshot().Counters()[configsnapshot.SchemaChangedCounter+"+"].Value())
+
+	_, err = env.QueryWorkflow("config")
+	s.NoError(err)
+}
+
+func (s *ConfigSnapshotTestSuite) TestFetchError() {
+	wf := func(ctx workflow.Context) error {
+		snapshotter, err := configsnapshot.New(ctx, func(ctx context.Context) ([]byte, error) {
+			return []byte("{"), nil
+		}, configsnapshot.Options{}, nil)
+		if err != nil {
+			return err
+		}
+		_, err = snapshotter.Fetch(ctx)
+		return err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+	s.ErrorContains(env.GetWorkflowError(), "not valid JSON")
+
+	_, err := env.QueryWorkflow(configsnapshot.DefaultQueryType)
+	s.Error(err)
+}
@@ -0,0 +1,146 @@
+package entityworkflow
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/cadence/workflow"
+)
+
+const defaultMaxEventsPerRun = 1000
+
+// ErrDone can be returned by Handler.HandleSignal to complete the entity workflow instead of continuing it.
+var ErrDone = errors.New("entity workflow is done")
+
+type (
+	// Handler implements the business logic of an entity workflow.
+	// It is driven by Run and owns the state that is carried over from one run to the next.
+	Handler interface {
+		// HandleSignal is called for every signal received on one of Options.SignalNames.
+		// receive decodes the signal value into valuePtr. If receive is not called the signal is dropped.
+		// Return ErrDone to complete the workflow, any other error fails it.
+		HandleSignal(ctx workflow.Context, signalName string, receive func(valuePtr interface{})) error
+
+		// State returns the value passed as the only argument to the next run when the workflow continues as new.
+		State() interface{}
+	}
+
+	// Options configures when Run continues the entity workflow as new.
+	Options struct {
+		// Required: names of the signal channels to process.
+		SignalNames []string
+
+		// Optional: number of events in the history of a single run before continuing as new. The history length
+		// is the event ID of the decision task being processed, see workflow.Info.GetDecisionStartedEventID,
+		// so it counts the signals as well as the events of the activities, timers and child workflows started by
+		// the handler. It is checked after every signal.
+		// default: 1000
+		MaxEventsPerRun int
+
+		// Optional: wall clock time a single run is allowed to live before continuing as new, even if idle.
+		// default: no limit
+		MaxDuration time.Duration
+	}
+)
+
+// Run processes signals with handler until a threshold from options is reached, and then returns a
+// ContinueAsNewError that restarts the current workflow type with handler.State() as its only argument.
+// The workflow should return the result of Run as is:
+//
+//	func CounterWorkflow(ctx workflow.Context, count int) error {
+//		return entityworkflow.Run(ctx, &counter{count: count}, entityworkflow.Options{
+//			SignalNames:     []string{"add"},
+//			MaxEventsPerRun: 500,
+//		})
+//	}
+//
+// Signals that are already buffered when the threshold is reached are handled before continuing as new,
+// so no signal is lost between runs.
+func Run(ctx workflow.Context, handler Handler, options Options) error {
+	if len(options.SignalNames) == 0 {
+		return errors.New("entityworkflow: at least one signal name is required")
+	}
+	maxEvents := int64(options.MaxEventsPerRun)
+	if maxEvents <= 0 {
+		maxEvents = defaultMaxEventsPerRun
+	}
+
+	channels := make([]workflow.Channel, len(options.SignalNames))
+	for i, name := range options.SignalNames {
+		channels[i] = workflow.GetSignalChannel(ctx, name)
+	}
+
+	var err error
+	expired := false
+	selector := workflow.NewSelector(ctx)
+	for i := range channels {
+		name := options.SignalNames[i]
+		selector.AddReceive(channels[i], func(c workflow.Channel, more bool) {
+			err = handleSignal(ctx, handler, name, c)
+		})
+	}
+	if options.MaxDuration > 0 {
+		timerCtx, cancel := workflow.WithCancel(ctx)
+		defer cancel()
+		selector.AddFuture(workflow.NewTimer(timerCtx, options.MaxDuration), func(f workflow.Future) {
+			expired = true
+		})
+	}
+
+	for workflow.GetInfo(ctx).GetDecisionStartedEventID() < maxEvents && !expired {
+		selector.Select(ctx)
+		if err != nil {
+			return finish(err)
+		}
+	}
+
+	if err := drain(ctx, handler, options.SignalNames, channels); err != nil {
+		return finish(err)
+	}
+	return workflow.NewContinueAsNewError(ctx, workflow.GetInfo(ctx).WorkflowType.Name, handler.State())
+}
+
+// drain handles all signals that are already buffered without blocking.
+func drain(ctx workflow.Context, handler Handler, names []string, channels []workflow.Channel) error {
+	var err error
+	empty := false
+	selector := workflow.NewSelector(ctx)
+	for i := range channels {
+		name := names[i]
+		selector.AddReceive(channels[i], func(c workflow.Channel, more bool) {
+			err = handleSignal(ctx, handler, name, c)
+		})
+	}
+	selector.AddDefault(func() {
+		empty = true
+	})
+
+	for !empty {
+		selector.Select(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func handleSignal(ctx workflow.Context, handler Handler, name string, c workflow.Channel) error {
+	received := false
+	err := handler.HandleSignal(ctx, name, func(valuePtr interface{}) {
+		if !received {
+			received = true
+			c.Receive(ctx, valuePtr)
+		}
+	})
+	if !received {
+		c.Receive(ctx, nil)
+	}
+	return err
+}
+
+func finish(err error) error {
+	if errors.Is(err, ErrDone) {
+		return nil
+	}
+	return err
+}
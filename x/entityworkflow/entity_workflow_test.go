@@ -0,0 +1,133 @@
+package entityworkflow_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"go.uber.org/cadence/internal"
+	"go.uber.org/cadence/internal/common/testlogger"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/cadence/x/entityworkflow"
+)
+
+type EntityWorkflowTestSuite struct {
+	suite.Suite
+	internal.WorkflowTestSuite
+}
+
+func (s *EntityWorkflowTestSuite) SetupTest() {
+	s.SetLogger(testlogger.NewZap(s.T()))
+}
+
+func TestEntityWorkflowSuite(t *testing.T) {
+	suite.Run(t, new(EntityWorkflowTestSuite))
+}
+
+type counter struct {
+	count int
+}
+
+func (c *counter) HandleSignal(ctx workflow.Context, signalName string, receive func(valuePtr interface{})) error {
+	switch signalName {
+	case "add":
+		var delta int
+		receive(&delta)
+		c.count += delta
+	case "stop":
+		return entityworkflow.ErrDone
+	}
+	return nil
+}
+
+func (c *counter) State() interface{} {
+	return c.count
+}
+
+// history simulates the events added to the history by the handler, as the test environment does not record them.
+type history struct {
+	*counter
+}
+
+func (h history) HandleSignal(ctx workflow.Context, signalName string, receive func(valuePtr interface{})) error {
+	workflow.GetInfo(ctx).DecisionStartedEventID += 10
+	return h.counter.HandleSignal(ctx, signalName, receive)
+}
+
+func CounterWorkflow(ctx workflow.Context, count int) (int, error) {
+	c := &counter{count: count}
+	err := entityworkflow.Run(ctx, history{c}, entityworkflow.Options{
+		SignalNames:     []string{"add", "stop"},
+		MaxEventsPerRun: 30,
+		MaxDuration:     time.Hour,
+	})
+	return c.count, err
+}
+
+func (s *EntityWorkflowTestSuite) TestContinueAsNewAfterMaxEvents() {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(CounterWorkflow)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("add", 1)
+		env.SignalWorkflow("add", 2)
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflowSkippingDecision("add", 3)
+		env.SignalWorkflow("add", 4)
+	}, 2*time.Minute)
+
+	env.ExecuteWorkflow(CounterWorkflow, 10)
+
+	s.True(env.IsWorkflowCompleted())
+	var continueAsNew *internal.ContinueAsNewError
+	s.True(errors.As(env.GetWorkflowError(), &continueAsNew))
+	s.Equal("go.uber.org/cadence/x/entityworkflow_test.CounterWorkflow", continueAsNew.WorkflowType().Name)
+	// the fourth signal was already buffered and must be carried over
+	s.Equal([]interface{}{20}, continueAsNew.Args())
+}
+
+func (s *EntityWorkflowTestSuite) TestContinueAsNewAfterMaxDuration() {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(CounterWorkflow)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("add", 5)
+	}, time.Minute)
+
+	env.ExecuteWorkflow(CounterWorkflow, 0)
+
+	s.True(env.IsWorkflowCompleted())
+	var continueAsNew *internal.ContinueAsNewError
+	s.True(errors.As(env.GetWorkflowError(), &continueAsNew))
+	s.Equal([]interface{}{5}, continueAsNew.Args())
+}
+
+func (s *EntityWorkflowTestSuite) TestDone() {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(CounterWorkflow)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("add", 5)
+		env.SignalWorkflow("stop", nil)
+	}, time.Minute)
+
+	env.ExecuteWorkflow(CounterWorkflow, 1)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result int
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal(6, result)
+}
+
+func (s *EntityWorkflowTestSuite) TestSignalNamesRequired() {
+	wf := func(ctx workflow.Context) error {
+		return entityworkflow.Run(ctx, &counter{}, entityworkflow.Options{})
+	}
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+
+	s.True(env.IsWorkflowCompleted())
+	s.ErrorContains(env.GetWorkflowError(), "at least one signal name is required")
+}
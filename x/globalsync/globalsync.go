@@ -0,0 +1,348 @@
+package globalsync
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+)
+
+const (
+	// SemaphoreWorkflowName is the workflow type of the coordinator workflow, one of which runs per resource.
+	SemaphoreWorkflowName = "globalsync.SemaphoreWorkflow"
+
+	signalWithStartActivityName = "globalsync.SignalWithStartSemaphore"
+
+	acquireSignalName = "globalsync-acquire"
+	releaseSignalName = "globalsync-release"
+	grantSignalPrefix = "globalsync-grant-"
+
+	workflowIDPrefix = "globalsync:"
+
+	defaultMaxEventsPerRun = 1000
+
+	semaphoreExecutionTimeout = 365 * 24 * time.Hour
+	semaphoreDecisionTimeout  = 10 * time.Second
+)
+
+type (
+	// Registry is implemented by worker.Worker.
+	Registry interface {
+		worker.WorkflowRegistry
+		worker.ActivityRegistry
+	}
+
+	// Options configures the coordinator workflows started by Acquire.
+	Options struct {
+		// Optional: task list of the coordinator workflows.
+		// default: the task list of the worker executing the workflow calling Acquire.
+		TaskList string
+
+		// Optional: number of acquire and release requests handled by a single run of a coordinator workflow
+		// before it continues as new.
+		// default: 1000
+		MaxEventsPerRun int
+	}
+
+	// AcquireOptions configures a single Acquire call.
+	AcquireOptions struct {
+		// Optional: number of leases of the resource which can be held at the same time. It is only applied when
+		// the coordinator workflow of the resource is started, i.e. when the resource is not in use.
+		// default: 1, i.e. a mutex
+		Permits int
+	}
+
+	// Lease is held by a workflow from a successful Acquire until Release is called or its TTL expires.
+	Lease struct {
+		Resource string
+		ID       string
+	}
+
+	// SemaphoreState is the argument of the coordinator workflow, carried over when it continues as new.
+	SemaphoreState struct {
+		Permits         int
+		MaxEventsPerRun int
+		Holders         []Holder
+		Waiters         []Request
+	}
+
+	// Request asks the coordinator workflow for a lease on behalf of a workflow execution.
+	Request struct {
+		LeaseID    string
+		WorkflowID string
+		RunID      string
+		TTL        time.Duration
+	}
+
+	// Holder is a request which was granted a lease.
+	Holder struct {
+		Request
+		ExpiresAt time.Time
+	}
+
+	activities struct {
+		client  client.Client
+		options Options
+	}
+)
+
+// Register registers the coordinator workflow and the activity used by Acquire to start it. The client must be
+// connected to the domain of the workflows calling Acquire.
+func Register(registry Registry, c client.Client, options Options) {
+	registry.RegisterWorkflowWithOptions(SemaphoreWorkflow, workflow.RegisterOptions{Name: SemaphoreWorkflowName})
+	a := &activities{client: c, options: options}
+	registry.RegisterActivityWithOptions(a.signalWithStart, activity.RegisterOptions{Name: signalWithStartActivityName})
+}
+
+// Acquire blocks until the workflow holds a lease on resource, which is released by Lease.Release or once ttl has
+// passed, whichever comes first. At most one lease is held at a time unless AcquireWithOptions sets more permits.
+// Waiters are granted leases in the order their requests reached the coordinator workflow.
+// If ctx is canceled while waiting, the request is withdrawn and ctx.Err() is returned.
+func Acquire(ctx workflow.Context, resource string, ttl time.Duration) (*Lease, error) {
+	return AcquireWithOptions(ctx, resource, ttl, AcquireOptions{})
+}
+
+// AcquireWithOptions is Acquire with additional options.
+func AcquireWithOptions(ctx workflow.Context, resource string, ttl time.Duration, options AcquireOptions) (*Lease, error) {
+	if resource == "" {
+		return nil, errors.New("globalsync: resource is required")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("globalsync: ttl must be positive")
+	}
+	permits := options.Permits
+	if permits <= 0 {
+		permits = 1
+	}
+
+	var leaseID string
+	if err := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return uuid.New()
+	}).Get(&leaseID); err != nil {
+		return nil, err
+	}
+	info := workflow.GetInfo(ctx)
+	request := Request{
+		LeaseID:    leaseID,
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		TTL:        ttl,
+	}
+	grantCh := workflow.GetSignalChannel(ctx, grantSignalPrefix+leaseID)
+
+	activityCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    10 * time.Second,
+		RetryPolicy: &cadence.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2,
+			MaximumInterval:    time.Minute,
+			ExpirationInterval: 10 * time.Minute,
+		},
+	})
+	if err := workflow.ExecuteActivity(activityCtx, signalWithStartActivityName, resource, permits, request).Get(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	lease := &Lease{Resource: resource, ID: leaseID}
+	granted := false
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(grantCh, func(c workflow.Channel, more bool) {
+		c.Receive(ctx, nil)
+		granted = true
+	})
+	selector.AddReceive(ctx.Done(), func(c workflow.Channel, more bool) {})
+	selector.Select(ctx)
+	if !granted {
+		// withdraw the request, or release the lease if it was granted concurrently
+		disconnectedCtx, cancel := workflow.NewDisconnectedContext(ctx)
+		defer cancel()
+		_ = lease.Release(disconnectedCtx)
+		return nil, ctx.Err()
+	}
+	return lease, nil
+}
+
+// Release returns the lease to the coordinator workflow of its resource. Releasing a lease that has already
+// expired has no effect.
+func (l *Lease) Release(ctx workflow.Context) error {
+	return workflow.SignalExternalWorkflow(ctx, workflowID(l.Resource), "", releaseSignalName, l.ID).Get(ctx, nil)
+}
+
+// SemaphoreWorkflow is the coordinator workflow of a single resource. It grants leases to the workflows that
+// requested them in the order of their requests, expires leases after their TTL, continues as new after
+// MaxEventsPerRun requests and completes once no lease is held or requested.
+func SemaphoreWorkflow(ctx workflow.Context, state SemaphoreState) error {
+	maxEvents := state.MaxEventsPerRun
+	if maxEvents <= 0 {
+		maxEvents = defaultMaxEventsPerRun
+	}
+	s := &semaphore{state: state}
+	acquireCh := workflow.GetSignalChannel(ctx, acquireSignalName)
+	releaseCh := workflow.GetSignalChannel(ctx, releaseSignalName)
+
+	handled := 0
+	for handled < maxEvents {
+		s.expire(workflow.Now(ctx))
+		s.grant(ctx)
+		if s.idle() {
+			drained := s.drain(ctx, acquireCh, releaseCh)
+			if drained == 0 {
+				return nil
+			}
+			handled += drained
+			continue
+		}
+
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		selector := workflow.NewSelector(ctx)
+		s.addReceivers(ctx, selector, acquireCh, releaseCh, &handled)
+		if expiresAt, ok := s.nextExpiry(); ok {
+			selector.AddFuture(workflow.NewTimer(timerCtx, expiresAt.Sub(workflow.Now(ctx))), func(f workflow.Future) {})
+		}
+		selector.Select(ctx)
+		cancelTimer()
+	}
+
+	s.drain(ctx, acquireCh, releaseCh)
+	s.expire(workflow.Now(ctx))
+	s.grant(ctx)
+	return workflow.NewContinueAsNewError(ctx, SemaphoreWorkflowName, s.state)
+}
+
+type semaphore struct {
+	state SemaphoreState
+}
+
+func (s *semaphore) addReceivers(ctx workflow.Context, selector workflow.Selector, acquireCh, releaseCh workflow.Channel, handled *int) {
+	selector.AddReceive(acquireCh, func(c workflow.Channel, more bool) {
+		var request Request
+		c.Receive(ctx, &request)
+		s.enqueue(request)
+		*handled++
+	})
+	selector.AddReceive(releaseCh, func(c workflow.Channel, more bool) {
+		var leaseID string
+		c.Receive(ctx, &leaseID)
+		s.release(leaseID)
+		*handled++
+	})
+}
+
+// drain handles all requests that are already buffered without blocking and returns their number.
+func (s *semaphore) drain(ctx workflow.Context, acquireCh, releaseCh workflow.Channel) int {
+	handled := 0
+	empty := false
+	selector := workflow.NewSelector(ctx)
+	s.addReceivers(ctx, selector, acquireCh, releaseCh, &handled)
+	selector.AddDefault(func() {
+		empty = true
+	})
+	for !empty {
+		selector.Select(ctx)
+	}
+	return handled
+}
+
+func (s *semaphore) enqueue(request Request) {
+	for _, holder := range s.state.Holders {
+		if holder.LeaseID == request.LeaseID {
+			return
+		}
+	}
+	for _, waiter := range s.state.Waiters {
+		if waiter.LeaseID == request.LeaseID {
+			return
+		}
+	}
+	s.state.Waiters = append(s.state.Waiters, request)
+}
+
+func (s *semaphore) release(leaseID string) {
+	for i, holder := range s.state.Holders {
+		if holder.LeaseID == leaseID {
+			s.state.Holders = append(s.state.Holders[:i], s.state.Holders[i+1:]...)
+			return
+		}
+	}
+	for i, waiter := range s.state.Waiters {
+		if waiter.LeaseID == leaseID {
+			s.state.Waiters = append(s.state.Waiters[:i], s.state.Waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *semaphore) expire(now time.Time) {
+	holders := s.state.Holders[:0]
+	for _, holder := range s.state.Holders {
+		if holder.ExpiresAt.After(now) {
+			holders = append(holders, holder)
+		}
+	}
+	s.state.Holders = holders
+}
+
+// grant hands out leases to waiters while permits are available. A waiter which can't be signaled, e.g. because
+// its workflow has completed in the meantime, doesn't get a lease.
+func (s *semaphore) grant(ctx workflow.Context) {
+	permits := s.state.Permits
+	if permits <= 0 {
+		permits = 1
+	}
+	for len(s.state.Holders) < permits && len(s.state.Waiters) > 0 {
+		request := s.state.Waiters[0]
+		s.state.Waiters = s.state.Waiters[1:]
+		err := workflow.SignalExternalWorkflow(ctx, request.WorkflowID, request.RunID, grantSignalPrefix+request.LeaseID, nil).Get(ctx, nil)
+		if err != nil {
+			workflow.GetLogger(ctx).Warn("globalsync: failed to grant lease, skipping request")
+			continue
+		}
+		s.state.Holders = append(s.state.Holders, Holder{
+			Request:   request,
+			ExpiresAt: workflow.Now(ctx).Add(request.TTL),
+		})
+	}
+}
+
+func (s *semaphore) idle() bool {
+	return len(s.state.Holders) == 0 && len(s.state.Waiters) == 0
+}
+
+func (s *semaphore) nextExpiry() (time.Time, bool) {
+	var next time.Time
+	for _, holder := range s.state.Holders {
+		if next.IsZero() || holder.ExpiresAt.Before(next) {
+			next = holder.ExpiresAt
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func (a *activities) signalWithStart(ctx context.Context, resource string, permits int, request Request) error {
+	taskList := a.options.TaskList
+	if taskList == "" {
+		taskList = activity.GetInfo(ctx).TaskList
+	}
+	_, err := a.client.SignalWithStartWorkflow(ctx, workflowID(resource), acquireSignalName, request,
+		client.StartWorkflowOptions{
+			ID:                              workflowID(resource),
+			TaskList:                        taskList,
+			ExecutionStartToCloseTimeout:    semaphoreExecutionTimeout,
+			DecisionTaskStartToCloseTimeout: semaphoreDecisionTimeout,
+			WorkflowIDReusePolicy:           client.WorkflowIDReusePolicyAllowDuplicate,
+		},
+		SemaphoreWorkflowName, SemaphoreState{Permits: permits, MaxEventsPerRun: a.options.MaxEventsPerRun})
+	return err
+}
+
+func workflowID(resource string) string {
+	return workflowIDPrefix + resource
+}
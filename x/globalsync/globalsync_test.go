@@ -0,0 +1,265 @@
+package globalsync_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"go.uber.org/cadence/internal"
+	"go.uber.org/cadence/internal/common/testlogger"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/cadence/x/globalsync"
+)
+
+type GlobalSyncTestSuite struct {
+	suite.Suite
+	internal.WorkflowTestSuite
+}
+
+func (s *GlobalSyncTestSuite) SetupTest() {
+	s.SetLogger(testlogger.NewZap(s.T()))
+}
+
+func TestGlobalSyncSuite(t *testing.T) {
+	suite.Run(t, new(GlobalSyncTestSuite))
+}
+
+func request(id string, ttl time.Duration) globalsync.Request {
+	return globalsync.Request{LeaseID: id, WorkflowID: "wf-" + id, RunID: "run-" + id, TTL: ttl}
+}
+
+func (s *GlobalSyncTestSuite) newSemaphoreEnv() *internal.TestWorkflowEnvironment {
+	env := s.NewTestWorkflowEnvironment()
+	globalsync.Register(env, nil, globalsync.Options{})
+	return env
+}
+
+func (s *GlobalSyncTestSuite) expectGrant(env *internal.TestWorkflowEnvironment, id string, granted *[]string) {
+	env.OnSignalExternalWorkflow(mock.Anything, "wf-"+id, "run-"+id, "globalsync-grant-"+id, nil).
+		Return(func(domainName, workflowID, runID, signalName string, arg interface{}) error {
+			*granted = append(*granted, id)
+			return nil
+		}).Once()
+}
+
+func (s *GlobalSyncTestSuite) TestMutexGrantsInOrder() {
+	env := s.newSemaphoreEnv()
+	var granted []string
+	s.expectGrant(env, "a", &granted)
+	s.expectGrant(env, "b", &granted)
+	s.expectGrant(env, "c", &granted)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("globalsync-acquire", request("b", time.Hour))
+		env.SignalWorkflow("globalsync-acquire", request("c", time.Hour))
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		s.Equal([]string{"a"}, granted)
+		env.SignalWorkflow("globalsync-release", "a")
+	}, 2*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		s.Equal([]string{"a", "b"}, granted)
+		env.SignalWorkflow("globalsync-release", "b")
+	}, 3*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("globalsync-release", "c")
+	}, 4*time.Minute)
+
+	env.ExecuteWorkflow(globalsync.SemaphoreWorkflowName, globalsync.SemaphoreState{
+		Permits: 1,
+		Waiters: []globalsync.Request{request("a", time.Hour)},
+	})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{"a", "b", "c"}, granted)
+	env.AssertExpectations(s.T())
+}
+
+func (s *GlobalSyncTestSuite) TestPermits() {
+	env := s.newSemaphoreEnv()
+	var granted []string
+	s.expectGrant(env, "a", &granted)
+	s.expectGrant(env, "b", &granted)
+	s.expectGrant(env, "c", &granted)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("globalsync-acquire", request("b", time.Hour))
+		env.SignalWorkflow("globalsync-acquire", request("c", time.Hour))
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		s.Equal([]string{"a", "b"}, granted)
+		env.SignalWorkflow("globalsync-release", "b")
+	}, 2*time.Minute)
+
+	env.ExecuteWorkflow(globalsync.SemaphoreWorkflowName, globalsync.SemaphoreState{
+		Permits: 2,
+		Waiters: []globalsync.Request{request("a", time.Hour)},
+	})
+
+	// the remaining leases are expired by their TTL
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{"a", "b", "c"}, granted)
+}
+
+func (s *GlobalSyncTestSuite) TestLeaseExpires() {
+	env := s.newSemaphoreEnv()
+	var granted []string
+	s.expectGrant(env, "a", &granted)
+	s.expectGrant(env, "b", &granted)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("globalsync-acquire", request("b", time.Minute))
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		s.Equal([]string{"a"}, granted)
+	}, 9*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		s.Equal([]string{"a", "b"}, granted)
+	}, 11*time.Minute)
+
+	env.ExecuteWorkflow(globalsync.SemaphoreWorkflowName, globalsync.SemaphoreState{
+		Permits: 1,
+		Waiters: []globalsync.Request{request("a", 10*time.Minute)},
+	})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{"a", "b"}, granted)
+}
+
+func (s *GlobalSyncTestSuite) TestSkipsWaiterWhichCantBeSignaled() {
+	env := s.newSemaphoreEnv()
+	var granted []string
+	env.OnSignalExternalWorkflow(mock.Anything, "wf-a", "run-a", "globalsync-grant-a", nil).
+		Return(errors.New("workflow execution already completed")).Once()
+	s.expectGrant(env, "b", &granted)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("globalsync-release", "b")
+	}, time.Minute)
+
+	env.ExecuteWorkflow(globalsync.SemaphoreWorkflowName, globalsync.SemaphoreState{
+		Permits: 1,
+		Waiters: []globalsync.Request{request("a", time.Hour), request("b", time.Hour)},
+	})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{"b"}, granted)
+}
+
+func (s *GlobalSyncTestSuite) TestContinueAsNewAfterMaxEvents() {
+	env := s.newSemaphoreEnv()
+	var granted []string
+	s.expectGrant(env, "a", &granted)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflowSkippingDecision("globalsync-acquire", request("b", time.Hour))
+		env.SignalWorkflowSkippingDecision("globalsync-acquire", request("c", time.Hour))
+		env.SignalWorkflow("globalsync-acquire", request("d", time.Hour))
+	}, time.Minute)
+
+	env.ExecuteWorkflow(globalsync.SemaphoreWorkflowName, globalsync.SemaphoreState{
+		Permits:         1,
+		MaxEventsPerRun: 2,
+		Waiters:         []globalsync.Request{request("a", time.Hour)},
+	})
+
+	s.True(env.IsWorkflowCompleted())
+	var continueAsNew *internal.ContinueAsNewError
+	s.True(errors.As(env.GetWorkflowError(), &continueAsNew))
+	s.Equal(globalsync.SemaphoreWorkflowName, continueAsNew.WorkflowType().Name)
+	s.Require().Len(continueAsNew.Args(), 1)
+	state := continueAsNew.Args()[0].(globalsync.SemaphoreState)
+	s.Equal(1, state.Permits)
+	s.Equal(2, state.MaxEventsPerRun)
+	s.Require().Len(state.Holders, 1)
+	s.Equal("a", state.Holders[0].LeaseID)
+	// the last request was already buffered and must be carried over
+	s.Equal([]globalsync.Request{request("b", time.Hour), request("c", time.Hour), request("d", time.Hour)}, state.Waiters)
+}
+
+func (s *GlobalSyncTestSuite) TestAcquireAndRelease() {
+	env := s.NewTestWorkflowEnvironment()
+	globalsync.Register(env, nil, globalsync.Options{})
+	var leaseID string
+	env.OnActivity("globalsync.SignalWithStartSemaphore", mock.Anything, "resource", 1, mock.Anything).
+		Return(func(ctx context.Context, resource string, permits int, request globalsync.Request) error {
+			leaseID = request.LeaseID
+			s.Equal(time.Hour, request.TTL)
+			s.Equal("default-test-workflow-id", request.WorkflowID)
+			return nil
+		}).Once()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("globalsync-grant-"+leaseID, nil)
+	}, time.Minute)
+	env.OnSignalExternalWorkflow(mock.Anything, "globalsync:resource", "", "globalsync-release", mock.Anything).
+		Return(func(domainName, workflowID, runID, signalName string, arg interface{}) error {
+			s.Equal(leaseID, arg)
+			return nil
+		}).Once()
+
+	wf := func(ctx workflow.Context) (time.Time, error) {
+		lease, err := globalsync.Acquire(ctx, "resource", time.Hour)
+		if err != nil {
+			return time.Time{}, err
+		}
+		acquiredAt := workflow.Now(ctx)
+		return acquiredAt, lease.Release(ctx)
+	}
+	env.RegisterWorkflow(wf)
+	start := env.Now()
+	env.ExecuteWorkflow(wf)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var acquiredAt time.Time
+	s.NoError(env.GetWorkflowResult(&acquiredAt))
+	s.Equal(time.Minute, acquiredAt.Sub(start))
+	env.AssertExpectations(s.T())
+}
+
+func (s *GlobalSyncTestSuite) TestAcquireCanceled() {
+	env := s.NewTestWorkflowEnvironment()
+	globalsync.Register(env, nil, globalsync.Options{})
+	var leaseID string
+	env.OnActivity("globalsync.SignalWithStartSemaphore", mock.Anything, "resource", 3, mock.Anything).
+		Return(func(ctx context.Context, resource string, permits int, request globalsync.Request) error {
+			leaseID = request.LeaseID
+			return nil
+		}).Once()
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Minute)
+	env.OnSignalExternalWorkflow(mock.Anything, "globalsync:resource", "", "globalsync-release", mock.Anything).
+		Return(func(domainName, workflowID, runID, signalName string, arg interface{}) error {
+			s.Equal(leaseID, arg)
+			return nil
+		}).Once()
+
+	wf := func(ctx workflow.Context) error {
+		_, err := globalsync.AcquireWithOptions(ctx, "resource", time.Hour, globalsync.AcquireOptions{Permits: 3})
+		return err
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+
+	s.True(env.IsWorkflowCompleted())
+	s.True(internal.IsCanceledError(env.GetWorkflowError()))
+	env.AssertExpectations(s.T())
+}
+
+func (s *GlobalSyncTestSuite) TestAcquireValidatesArguments() {
+	wf := func(ctx workflow.Context, resource string, ttl time.Duration) error {
+		_, err := globalsync.Acquire(ctx, resource, ttl)
+		return err
+	}
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf, "", time.Hour)
+	s.ErrorContains(env.GetWorkflowError(), "resource is required")
+
+	env = s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf, "resource", time.Duration(0))
+	s.ErrorContains(env.GetWorkflowError(), "ttl must be positive")
+}
@@ -0,0 +1,331 @@
+package integrationtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/transport/tchannel"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
+)
+
+const (
+	// HostPortEnv is the environment variable which points the tests to an already running server,
+	// e.g. "127.0.0.1:7933", instead of starting one with Docker.
+	HostPortEnv = "CADENCE_HOSTPORT"
+
+	// DefaultServerImage is the server image started when Options.ServerImage is empty.
+	DefaultServerImage = "ubercadence/server:master-auto-setup"
+	// DefaultCassandraImage is the database image started when Options.CassandraImage is empty.
+	DefaultCassandraImage = "cassandra:4.1.3"
+
+	defaultStartTimeout = 3 * time.Minute
+	serviceName         = "cadence-frontend"
+	frontendPort        = "7933/tcp"
+	retryInterval       = time.Second
+)
+
+// ErrDockerUnavailable is returned by Start when no server is configured and the docker command is not available.
+var ErrDockerUnavailable = errors.New("no Cadence server configured and docker is not available")
+
+// Options configures the server used by the integration tests.
+type Options struct {
+	// HostPort of an already running frontend, which serves tchannel. Defaults to the CADENCE_HOSTPORT
+	// environment variable. When both are empty, a server and its database are started with Docker.
+	HostPort string
+
+	// ServerImage and CassandraImage are the images started with Docker, they default to
+	// DefaultServerImage and DefaultCassandraImage.
+	ServerImage    string
+	CassandraImage string
+
+	// Domain is registered for the tests if it does not exist yet. Defaults to a random name, which keeps
+	// tests sharing a server apart.
+	Domain string
+
+	// StartTimeout bounds starting the containers and waiting until the domain can be used.
+	// Defaults to 3 minutes, the database schema is set up on the first start.
+	StartTimeout time.Duration
+}
+
+// Server is a Cadence server with a domain registered for the tests.
+type Server struct {
+	hostPort   string
+	domain     string
+	service    workflowserviceclient.Interface
+	closeConn  func()
+	containers []string
+	network    string
+	env        *environment
+}
+
+// environment is how the server is started and reached, it is replaced in tests.
+type environment struct {
+	run      func(ctx context.Context, name string, args ...string) (string, error)
+	lookPath func(file string) (string, error)
+	getenv   func(key string) string
+	dial     func(hostPort string) (workflowserviceclient.Interface, func(), error)
+	interval time.Duration
+}
+
+func defaultEnvironment() *environment {
+	return &environment{
+		run:      runCommand,
+		lookPath: exec.LookPath,
+		getenv:   os.Getenv,
+		dial:     dialTChannel,
+		interval: retryInterval,
+	}
+}
+
+// New starts a server for the test, see Start, and stops it when the test finishes.
+// The test is skipped when there is neither a configured server nor Docker.
+func New(t testing.TB, opts Options) *Server {
+	t.Helper()
+	s, err := Start(context.Background(), opts)
+	if errors.Is(err, ErrDockerUnavailable) {
+		t.Skip(err.Error())
+	}
+	if err != nil {
+		t.Fatalf("failed to start Cadence server: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := s.Stop(); err != nil {
+			t.Errorf("failed to stop Cadence server: %v", err)
+		}
+	})
+	return s
+}
+
+// Start connects to the server in Options.HostPort or CADENCE_HOSTPORT, or starts one with Docker, and registers
+// the domain. It returns once the domain can be used. Stop must be called to remove the started containers.
+func Start(ctx context.Context, opts Options) (*Server, error) {
+	return start(ctx, opts, defaultEnvironment())
+}
+
+func start(ctx context.Context, opts Options, env *environment) (*Server, error) {
+	if opts.StartTimeout <= 0 {
+		opts.StartTimeout = defaultStartTimeout
+	}
+	if opts.Domain == "" {
+		opts.Domain = "integration-test-" + randomSuffix()
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.StartTimeout)
+	defer cancel()
+
+	s := &Server{hostPort: opts.HostPort, domain: opts.Domain, env: env}
+	if s.hostPort == "" {
+		s.hostPort = strings.TrimSpace(env.getenv(HostPortEnv))
+	}
+	if s.hostPort == "" {
+		if _, err := env.lookPath("docker"); err != nil {
+			return nil, ErrDockerUnavailable
+		}
+	}
+	if err := s.start(ctx, opts); err != nil {
+		// the error of starting is more useful than the one of cleaning up
+		_ = s.Stop()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) start(ctx context.Context, opts Options) error {
+	if s.hostPort == "" {
+		if err := s.startContainers(ctx, opts); err != nil {
+			return err
+		}
+	}
+	var err error
+	if s.service, s.closeConn, err = s.env.dial(s.hostPort); err != nil {
+		return fmt.Errorf("failed to connect to %v: %w", s.hostPort, err)
+	}
+	return s.registerDomain(ctx)
+}
+
+func (s *Server) startContainers(ctx context.Context, opts Options) error {
+	serverImage, cassandraImage := opts.ServerImage, opts.CassandraImage
+	if serverImage == "" {
+		serverImage = DefaultServerImage
+	}
+	if cassandraImage == "" {
+		cassandraImage = DefaultCassandraImage
+	}
+
+	network := "cadence-integration-test-" + randomSuffix()
+	if _, err := s.env.run(ctx, "docker", "network", "create", network); err != nil {
+		return err
+	}
+	s.network = network
+
+	cassandra, err := s.env.run(ctx, "docker", "run", "--detach", "--rm",
+		"--network", network, "--network-alias", "cassandra",
+		cassandraImage)
+	if err != nil {
+		return err
+	}
+	s.containers = append(s.containers, cassandra)
+
+	server, err := s.env.run(ctx, "docker", "run", "--detach", "--rm",
+		"--network", network,
+		"--env", "CASSANDRA_SEEDS=cassandra",
+		"--publish", "127.0.0.1::"+frontendPort,
+		serverImage)
+	if err != nil {
+		return err
+	}
+	s.containers = append(s.containers, server)
+
+	// e.g. "127.0.0.1:49153", one line per published address
+	ports, err := s.env.run(ctx, "docker", "port", server, frontendPort)
+	if err != nil {
+		return err
+	}
+	s.hostPort = strings.TrimSpace(strings.SplitN(ports, "\n", 2)[0])
+	if s.hostPort == "" {
+		return fmt.Errorf("server container %v does not publish %v", server, frontendPort)
+	}
+	return nil
+}
+
+// registerDomain retries until the server has started, and then until the domain is in the domain cache of the
+// frontend, as requests for the domain fail before.
+func (s *Server) registerDomain(ctx context.Context) error {
+	domainClient := client.NewDomainClient(s.service, &client.Options{})
+	retention := int32(1)
+	err := s.retry(ctx, func() error {
+		err := domainClient.Register(ctx, &shared.RegisterDomainRequest{
+			Name:                                   &s.domain,
+			WorkflowExecutionRetentionPeriodInDays: &retention,
+		})
+		var alreadyExists *shared.DomainAlreadyExistsError
+		if errors.As(err, &alreadyExists) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register domain %v: %w", s.domain, err)
+	}
+
+	domainCheck := s.NewClient(nil)
+	err = s.retry(ctx, func() error {
+		_, err := domainCheck.DescribeTaskList(ctx, "integration-test-domain-check", shared.TaskListTypeDecision)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("domain %v is not ready: %w", s.domain, err)
+	}
+	return nil
+}
+
+func (s *Server) retry(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(s.env.interval):
+		}
+	}
+}
+
+// HostPort returns the address of the frontend.
+func (s *Server) HostPort() string {
+	return s.hostPort
+}
+
+// Domain returns the domain registered for the tests.
+func (s *Server) Domain() string {
+	return s.domain
+}
+
+// Service returns the connection to the frontend, e.g. for worker.NewWorkflowReplayer.
+func (s *Server) Service() workflowserviceclient.Interface {
+	return s.service
+}
+
+// NewClient returns a client of the test domain. The options may be nil.
+func (s *Server) NewClient(opts *client.Options) client.Client {
+	return client.NewClient(s.service, s.domain, opts)
+}
+
+// NewWorker returns a worker of the test domain polling the task list, it still needs to be started.
+func (s *Server) NewWorker(taskList string, opts worker.Options) worker.Worker {
+	return worker.New(s.service, s.domain, taskList, opts)
+}
+
+// Stop closes the connection and removes the containers started by Start. Workers must be stopped before.
+func (s *Server) Stop() error {
+	if s.closeConn != nil {
+		s.closeConn()
+		s.closeConn = nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var errs []error
+	if len(s.containers) > 0 {
+		args := append([]string{"rm", "--force", "--volumes"}, s.containers...)
+		if _, err := s.env.run(ctx, "docker", args...); err != nil {
+			errs = append(errs, err)
+		}
+		s.containers = nil
+	}
+	if s.network != "" {
+		if _, err := s.env.run(ctx, "docker", "network", "rm", s.network); err != nil {
+			errs = append(errs, err)
+		}
+		s.network = ""
+	}
+	return errors.Join(errs...)
+}
+
+func runCommand(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%v %v: %w: %v", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func dialTChannel(hostPort string) (workflowserviceclient.Interface, func(), error) {
+	transport, err := tchannel.NewTransport(tchannel.ServiceName("cadence-integration-test"))
+	if err != nil {
+		return nil, nil, err
+	}
+	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name: "cadence-integration-test",
+		Outbounds: yarpc.Outbounds{
+			serviceName: {Unary: transport.NewSingleOutbound(hostPort)},
+		},
+	})
+	if err := dispatcher.Start(); err != nil {
+		return nil, nil, err
+	}
+	return workflowserviceclient.New(dispatcher.ClientConfig(serviceName)), func() { _ = dispatcher.Stop() }, nil
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
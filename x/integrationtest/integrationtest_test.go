@@ -0,0 +1,126 @@
+package integrationtest
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+type fakeDocker struct {
+	commands []string
+	failOn   string
+}
+
+func (d *fakeDocker) run(_ context.Context, name string, args ...string) (string, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	d.commands = append(d.commands, command)
+	if d.failOn != "" && strings.Contains(command, d.failOn) {
+		return "", errors.New("docker failed")
+	}
+	switch {
+	case strings.Contains(command, "cassandra:"):
+		return "cassandra-container", nil
+	case strings.Contains(command, "server:"):
+		return "server-container", nil
+	case strings.HasPrefix(command, "docker port"):
+		return "127.0.0.1:49153\n[::1]:49153", nil
+	}
+	return "", nil
+}
+
+func newTestEnvironment(t *testing.T, docker *fakeDocker, hostPortEnv string) (*environment, *workflowservicetest.MockClient, *string) {
+	service := workflowservicetest.NewMockClient(gomock.NewController(t))
+	var dialed string
+	return &environment{
+		run:      docker.run,
+		lookPath: func(string) (string, error) { return "/usr/bin/docker", nil },
+		getenv: func(key string) string {
+			if key == HostPortEnv {
+				return hostPortEnv
+			}
+			return ""
+		},
+		dial: func(hostPort string) (workflowserviceclient.Interface, func(), error) {
+			dialed = hostPort
+			return service, func() {}, nil
+		},
+		interval: time.Millisecond,
+	}, service, &dialed
+}
+
+func TestStartWithDocker(t *testing.T) {
+	docker := &fakeDocker{}
+	env, service, dialed := newTestEnvironment(t, docker, "")
+	gomock.InOrder(
+		service.EXPECT().RegisterDomain(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("connection refused")),
+		service.EXPECT().RegisterDomain(gomock.Any(), gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, request *shared.RegisterDomainRequest, _ ...interface{}) {
+				assert.Equal(t, "orders", request.GetName())
+			}).Return(nil),
+		service.EXPECT().DescribeTaskList(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, &shared.EntityNotExistsError{}),
+		service.EXPECT().DescribeTaskList(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shared.DescribeTaskListResponse{}, nil),
+	)
+
+	s, err := start(context.Background(), Options{Domain: "orders"}, env)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:49153", s.HostPort())
+	assert.Equal(t, "127.0.0.1:49153", *dialed)
+	assert.Equal(t, "orders", s.Domain())
+	assert.NotNil(t, s.NewClient(nil))
+
+	require.NoError(t, s.Stop())
+	require.Len(t, docker.commands, 6)
+	assert.Regexp(t, "^docker network create cadence-integration-test-", docker.commands[0])
+	assert.Contains(t, docker.commands[1], DefaultCassandraImage)
+	assert.Contains(t, docker.commands[2], DefaultServerImage)
+	assert.Equal(t, "docker port server-container 7933/tcp", docker.commands[3])
+	assert.Equal(t, "docker rm --force --volumes cassandra-container server-container", docker.commands[4])
+	assert.Regexp(t, "^docker network rm cadence-integration-test-", docker.commands[5])
+}
+
+func TestStartWithRunningServer(t *testing.T) {
+	docker := &fakeDocker{}
+	env, service, dialed := newTestEnvironment(t, docker, "10.0.0.1:7933")
+	service.EXPECT().RegisterDomain(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shared.DomainAlreadyExistsError{})
+	service.EXPECT().DescribeTaskList(gomock.Any(), gomock.Any(), gomock.Any()).Return(&shared.DescribeTaskListResponse{}, nil)
+
+	s, err := start(context.Background(), Options{}, env)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:7933", *dialed)
+	assert.Regexp(t, "^integration-test-", s.Domain())
+	require.NoError(t, s.Stop())
+	assert.Empty(t, docker.commands, "no containers are started for a running server")
+}
+
+func TestStartFailures(t *testing.T) {
+	t.Run("docker unavailable", func(t *testing.T) {
+		env, _, _ := newTestEnvironment(t, &fakeDocker{}, "")
+		env.lookPath = func(string) (string, error) { return "", exec.ErrNotFound }
+		_, err := start(context.Background(), Options{}, env)
+		assert.ErrorIs(t, err, ErrDockerUnavailable)
+	})
+	t.Run("containers are removed when the server does not start", func(t *testing.T) {
+		docker := &fakeDocker{failOn: "docker port"}
+		env, _, _ := newTestEnvironment(t, docker, "")
+		_, err := start(context.Background(), Options{}, env)
+		assert.Error(t, err)
+		assert.Contains(t, docker.commands, "docker rm --force --volumes cassandra-container server-container")
+	})
+	t.Run("domain is not registered before the timeout", func(t *testing.T) {
+		env, service, _ := newTestEnvironment(t, &fakeDocker{}, "127.0.0.1:7933")
+		service.EXPECT().RegisterDomain(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("connection refused")).MinTimes(1)
+		_, err := start(context.Background(), Options{StartTimeout: 50 * time.Millisecond}, env)
+		assert.ErrorContains(t, err, "connection refused")
+	})
+}
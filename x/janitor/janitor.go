@@ -0,0 +1,260 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+)
+
+const (
+	// WorkflowName is the workflow type of JanitorWorkflow registered by Register.
+	WorkflowName = "janitor.JanitorWorkflow"
+
+	sweepActivityName = "janitor.Sweep"
+
+	defaultMaxExecutions = 1000
+	defaultSweepTimeout  = 10 * time.Minute
+	listPageSize         = 100
+)
+
+// Action is applied by Sweep to the idle executions it finds.
+type Action int
+
+const (
+	// ActionReport only reports the idle executions.
+	ActionReport Action = iota
+	// ActionSignal sends Policy.SignalName to the idle executions, with Policy.Reason as argument.
+	ActionSignal
+	// ActionCancel requests the cancellation of the idle executions.
+	ActionCancel
+	// ActionTerminate terminates the idle executions with Policy.Reason.
+	ActionTerminate
+)
+
+type (
+	// Registry is implemented by worker.Worker.
+	Registry interface {
+		worker.WorkflowRegistry
+		worker.ActivityRegistry
+	}
+
+	// Policy describes which open executions are abandoned and what to do with them.
+	Policy struct {
+		// Required: executions without a new history event for longer than TTL are idle.
+		TTL time.Duration
+
+		// Optional: visibility query restricting the candidates, e.g. "WorkflowType = 'OrderWorkflow'". It is
+		// combined with the filters on open executions started more than TTL ago, and requires advanced visibility
+		// like every query.
+		// default: all open executions of the domain
+		Query string
+
+		// Optional: action applied to the idle executions.
+		// default: ActionReport
+		Action Action
+
+		// Optional: signal sent by ActionSignal. Required with ActionSignal.
+		SignalName string
+
+		// Optional: argument of the signal sent by ActionSignal and reason of ActionTerminate.
+		// default: "idle for longer than <TTL>"
+		Reason string
+
+		// Optional: maximum number of idle executions handled by a single sweep, the next sweep handles the rest.
+		// default: 1000
+		MaxExecutions int
+
+		// Optional: start to close timeout of the activity sweeping the executions in JanitorWorkflow.
+		// default: 10 minutes
+		SweepTimeout time.Duration
+	}
+
+	// IdleExecution is an open execution without a new history event for longer than Policy.TTL.
+	IdleExecution struct {
+		WorkflowID   string
+		RunID        string
+		WorkflowType string
+		// LastUpdate is the time of the last change of the execution reported by DescribeWorkflowExecution.
+		LastUpdate time.Time
+		// Error is the error of the action applied to the execution, empty if it succeeded.
+		Error string
+	}
+
+	// Report is the result of Sweep and JanitorWorkflow.
+	Report struct {
+		Executions []IdleExecution
+		// Failed is the number of executions the action failed for, see IdleExecution.Error.
+		Failed int
+	}
+
+	activities struct {
+		client client.Client
+	}
+)
+
+// Register registers JanitorWorkflow and its activity. The client must be connected to the domain to sweep.
+func Register(registry Registry, c client.Client) {
+	registry.RegisterWorkflowWithOptions(JanitorWorkflow, workflow.RegisterOptions{Name: WorkflowName})
+	a := &activities{client: c}
+	registry.RegisterActivityWithOptions(a.sweep, activity.RegisterOptions{Name: sweepActivityName})
+}
+
+// JanitorWorkflow sweeps the executions of the domain once with policy, see Sweep. Start it with a cron schedule to
+// sweep periodically:
+//
+//	c.StartWorkflow(ctx, client.StartWorkflowOptions{
+//		ID:                           "janitor",
+//		TaskList:                     taskList,
+//		ExecutionStartToCloseTimeout: time.Hour,
+//		CronSchedule:                 "0 * * * *",
+//	}, janitor.WorkflowName, janitor.Policy{TTL: 30 * 24 * time.Hour, Action: janitor.ActionTerminate})
+func JanitorWorkflow(ctx workflow.Context, policy Policy) (*Report, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	timeout := policy.SweepTimeout
+	if timeout <= 0 {
+		timeout = defaultSweepTimeout
+	}
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: timeout,
+		StartToCloseTimeout:    timeout,
+	})
+	var report *Report
+	err := workflow.ExecuteActivity(ctx, sweepActivityName, policy).Get(ctx, &report)
+	return report, err
+}
+
+func (a *activities) sweep(ctx context.Context, policy Policy) (*Report, error) {
+	// The running janitor is open and may have been started more than TTL ago, it must not sweep itself.
+	self := activity.GetInfo(ctx).WorkflowExecution.ID
+	return sweep(ctx, a.client, policy, time.Now(), self)
+}
+
+// Sweep finds the open executions of the domain of c without a new history event for longer than policy.TTL and
+// applies policy.Action to them. The candidates are listed with a visibility query on the start time, and
+// DescribeWorkflowExecution tells when each of them last changed. Failing actions are reported in the returned report
+// instead of stopping the sweep.
+func Sweep(ctx context.Context, c client.Client, policy Policy) (*Report, error) {
+	return sweep(ctx, c, policy, time.Now(), "")
+}
+
+func sweep(ctx context.Context, c client.Client, policy Policy, now time.Time, skipWorkflowID string) (*Report, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	maxExecutions := policy.MaxExecutions
+	if maxExecutions <= 0 {
+		maxExecutions = defaultMaxExecutions
+	}
+	idleSince := now.Add(-policy.TTL)
+	query := fmt.Sprintf("CloseTime = missing AND StartTime < %d", idleSince.UnixNano())
+	if policy.Query != "" {
+		query += " AND (" + policy.Query + ")"
+	}
+
+	report := &Report{Executions: []IdleExecution{}}
+	request := &shared.ListWorkflowExecutionsRequest{
+		PageSize: int32Ptr(listPageSize),
+		Query:    &query,
+	}
+	for {
+		response, err := c.ListWorkflow(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range response.GetExecutions() {
+			execution := info.GetExecution()
+			if execution.GetWorkflowId() == skipWorkflowID {
+				continue
+			}
+			lastUpdate, err := lastUpdateTime(ctx, c, execution)
+			if err != nil {
+				var notExists *shared.EntityNotExistsError
+				if errors.As(err, &notExists) {
+					continue
+				}
+				return nil, err
+			}
+			if !lastUpdate.Before(idleSince) {
+				continue
+			}
+			idle := IdleExecution{
+				WorkflowID:   execution.GetWorkflowId(),
+				RunID:        execution.GetRunId(),
+				WorkflowType: info.GetType().GetName(),
+				LastUpdate:   lastUpdate,
+			}
+			if err := apply(ctx, c, policy, idle); err != nil {
+				idle.Error = err.Error()
+				report.Failed++
+			}
+			report.Executions = append(report.Executions, idle)
+			if len(report.Executions) >= maxExecutions {
+				return report, nil
+			}
+		}
+		if len(response.NextPageToken) == 0 {
+			return report, nil
+		}
+		request.NextPageToken = response.NextPageToken
+	}
+}
+
+// lastUpdateTime returns the time the execution last changed. Visibility records aren't updated by every history
+// event, so it is read from DescribeWorkflowExecution.
+func lastUpdateTime(ctx context.Context, c client.Client, execution *shared.WorkflowExecution) (time.Time, error) {
+	response, err := c.DescribeWorkflowExecution(ctx, execution.GetWorkflowId(), execution.GetRunId())
+	if err != nil {
+		return time.Time{}, err
+	}
+	info := response.GetWorkflowExecutionInfo()
+	if info.UpdateTime != nil {
+		return time.Unix(0, info.GetUpdateTime()), nil
+	}
+	return time.Unix(0, info.GetStartTime()), nil
+}
+
+func apply(ctx context.Context, c client.Client, policy Policy, idle IdleExecution) error {
+	switch policy.Action {
+	case ActionSignal:
+		return c.SignalWorkflow(ctx, idle.WorkflowID, idle.RunID, policy.SignalName, policy.reason())
+	case ActionCancel:
+		return c.CancelWorkflow(ctx, idle.WorkflowID, idle.RunID)
+	case ActionTerminate:
+		return c.TerminateWorkflow(ctx, idle.WorkflowID, idle.RunID, policy.reason(), nil)
+	default:
+		return nil
+	}
+}
+
+func (p Policy) validate() error {
+	if p.TTL <= 0 {
+		return errors.New("janitor: TTL must be positive")
+	}
+	if p.Action < ActionReport || p.Action > ActionTerminate {
+		return fmt.Errorf("janitor: unknown action %d", p.Action)
+	}
+	if p.Action == ActionSignal && p.SignalName == "" {
+		return errors.New("janitor: SignalName is required with ActionSignal")
+	}
+	return nil
+}
+
+func (p Policy) reason() string {
+	if p.Reason != "" {
+		return p.Reason
+	}
+	return fmt.Sprintf("idle for longer than %v", p.TTL)
+}
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
@@ -0,0 +1,161 @@
+package janitor_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/testlogger"
+	"go.uber.org/cadence/mocks"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/x/janitor"
+)
+
+func executionInfo(workflowID string) *shared.WorkflowExecutionInfo {
+	runID := "run-" + workflowID
+	workflowType := "OrderWorkflow"
+	return &shared.WorkflowExecutionInfo{
+		Execution: &shared.WorkflowExecution{WorkflowId: &workflowID, RunId: &runID},
+		Type:      &shared.WorkflowType{Name: &workflowType},
+	}
+}
+
+func describeResponse(updateTime time.Time) *shared.DescribeWorkflowExecutionResponse {
+	nanos := updateTime.UnixNano()
+	return &shared.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &shared.WorkflowExecutionInfo{UpdateTime: &nanos},
+	}
+}
+
+func listRequest(query string, nextPageToken string) interface{} {
+	return mock.MatchedBy(func(request *shared.ListWorkflowExecutionsRequest) bool {
+		return strings.HasPrefix(request.GetQuery(), "CloseTime = missing AND StartTime < ") &&
+			strings.HasSuffix(request.GetQuery(), query) &&
+			string(request.NextPageToken) == nextPageToken
+	})
+}
+
+// newMockClient lists idle-1, active and the others on the first page and idle-2 and gone on the second one for
+// queries ending with query.
+func newMockClient(query string, others ...*shared.WorkflowExecutionInfo) *mocks.Client {
+	now := time.Now()
+	c := &mocks.Client{}
+	c.On("ListWorkflow", mock.Anything, listRequest(query, "")).Return(&shared.ListWorkflowExecutionsResponse{
+		Executions:    append([]*shared.WorkflowExecutionInfo{executionInfo("idle-1"), executionInfo("active")}, others...),
+		NextPageToken: []byte("page-2"),
+	}, nil)
+	c.On("ListWorkflow", mock.Anything, listRequest(query, "page-2")).Return(&shared.ListWorkflowExecutionsResponse{
+		Executions: []*shared.WorkflowExecutionInfo{executionInfo("idle-2"), executionInfo("gone")},
+	}, nil)
+	c.On("DescribeWorkflowExecution", mock.Anything, "idle-1", "run-idle-1").Return(describeResponse(now.Add(-48*time.Hour)), nil)
+	c.On("DescribeWorkflowExecution", mock.Anything, "active", "run-active").Return(describeResponse(now.Add(-time.Hour)), nil)
+	c.On("DescribeWorkflowExecution", mock.Anything, "idle-2", "run-idle-2").Return(describeResponse(now.Add(-72*time.Hour)), nil)
+	c.On("DescribeWorkflowExecution", mock.Anything, "gone", "run-gone").Return(nil, &shared.EntityNotExistsError{})
+	return c
+}
+
+func workflowIDs(report *janitor.Report) []string {
+	var ids []string
+	for _, execution := range report.Executions {
+		ids = append(ids, execution.WorkflowID)
+	}
+	return ids
+}
+
+func TestSweepReport(t *testing.T) {
+	c := newMockClient("")
+	report, err := janitor.Sweep(context.Background(), c, janitor.Policy{TTL: 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"idle-1", "idle-2"}, workflowIDs(report))
+	assert.Equal(t, "OrderWorkflow", report.Executions[0].WorkflowType)
+	assert.Equal(t, "run-idle-1", report.Executions[0].RunID)
+	assert.Zero(t, report.Failed)
+	c.AssertNotCalled(t, "TerminateWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSweepQuery(t *testing.T) {
+	c := newMockClient(" AND (WorkflowType = 'OrderWorkflow')")
+	report, err := janitor.Sweep(context.Background(), c, janitor.Policy{
+		TTL:   24 * time.Hour,
+		Query: "WorkflowType = 'OrderWorkflow'",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"idle-1", "idle-2"}, workflowIDs(report))
+}
+
+func TestSweepActions(t *testing.T) {
+	t.Run("signal", func(t *testing.T) {
+		c := newMockClient("")
+		c.On("SignalWorkflow", mock.Anything, mock.Anything, mock.Anything, "expire", "abandoned").Return(nil)
+		report, err := janitor.Sweep(context.Background(), c, janitor.Policy{
+			TTL:        24 * time.Hour,
+			Action:     janitor.ActionSignal,
+			SignalName: "expire",
+			Reason:     "abandoned",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"idle-1", "idle-2"}, workflowIDs(report))
+		c.AssertNumberOfCalls(t, "SignalWorkflow", 2)
+	})
+	t.Run("cancel", func(t *testing.T) {
+		c := newMockClient("")
+		c.On("CancelWorkflow", mock.Anything, "idle-1", "run-idle-1").Return(nil)
+		c.On("CancelWorkflow", mock.Anything, "idle-2", "run-idle-2").Return(errors.New("service busy"))
+		report, err := janitor.Sweep(context.Background(), c, janitor.Policy{TTL: 24 * time.Hour, Action: janitor.ActionCancel})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Failed)
+		assert.Empty(t, report.Executions[0].Error)
+		assert.Equal(t, "service busy", report.Executions[1].Error)
+	})
+	t.Run("terminate", func(t *testing.T) {
+		c := newMockClient("")
+		c.On("TerminateWorkflow", mock.Anything, mock.Anything, mock.Anything, "idle for longer than 24h0m0s", []byte(nil)).Return(nil)
+		_, err := janitor.Sweep(context.Background(), c, janitor.Policy{TTL: 24 * time.Hour, Action: janitor.ActionTerminate})
+		require.NoError(t, err)
+		c.AssertNumberOfCalls(t, "TerminateWorkflow", 2)
+	})
+}
+
+func TestSweepMaxExecutions(t *testing.T) {
+	c := newMockClient("")
+	report, err := janitor.Sweep(context.Background(), c, janitor.Policy{TTL: 24 * time.Hour, MaxExecutions: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"idle-1"}, workflowIDs(report))
+	c.AssertNumberOfCalls(t, "ListWorkflow", 1)
+}
+
+func TestSweepInvalidPolicy(t *testing.T) {
+	for _, policy := range []janitor.Policy{
+		{},
+		{TTL: time.Hour, Action: janitor.ActionSignal},
+		{TTL: time.Hour, Action: janitor.Action(10)},
+	} {
+		_, err := janitor.Sweep(context.Background(), &mocks.Client{}, policy)
+		assert.Error(t, err)
+	}
+}
+
+func TestJanitorWorkflow(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	s.SetLogger(testlogger.NewZap(t))
+	env := s.NewTestWorkflowEnvironment()
+	// The running janitor is listed as well, it must not sweep itself.
+	c := newMockClient("", executionInfo("default-test-workflow-id"))
+	c.On("TerminateWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	janitor.Register(env, c)
+
+	env.ExecuteWorkflow(janitor.WorkflowName, janitor.Policy{TTL: 24 * time.Hour, Action: janitor.ActionTerminate})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var report *janitor.Report
+	require.NoError(t, env.GetWorkflowResult(&report))
+	assert.Equal(t, []string{"idle-1", "idle-2"}, workflowIDs(report))
+	c.AssertNumberOfCalls(t, "TerminateWorkflow", 2)
+}
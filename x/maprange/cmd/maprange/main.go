@@ -0,0 +1,15 @@
+// Command maprange reports the range statements over maps in workflow code, see the maprange package. It is run by
+// go vet:
+//
+//	go vet -vettool=$(which maprange) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"go.uber.org/cadence/x/maprange"
+)
+
+func main() {
+	unitchecker.Main(maprange.Analyzer)
+}
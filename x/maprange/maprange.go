@@ -0,0 +1,92 @@
+// Package maprange reports the range statements over maps in workflow code.
+package maprange
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `report range statements over maps in workflow code
+
+Go randomizes the iteration order of maps, so a workflow ranging over a map may schedule its activities, timers or
+child workflows in a different order when it is replayed, which fails the replay as non deterministic.
+
+The analyzer reports the range statements over maps in the functions taking a workflow.Context as first parameter,
+including the function literals they declare, e.g. the ones passed to workflow.Go. Use workflow.SortedRange or
+workflow.SortedKeys instead.`
+
+// Analyzer reports the range statements over maps in workflow code.
+var Analyzer = &analysis.Analyzer{
+	Name:     "maprange",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+const (
+	workflowPackage = "go.uber.org/cadence/workflow"
+	internalPackage = "go.uber.org/cadence/internal"
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.WithStack([]ast.Node{(*ast.RangeStmt)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		stmt := n.(*ast.RangeStmt)
+		t := pass.TypesInfo.TypeOf(stmt.X)
+		if t == nil {
+			return true
+		}
+		if _, ok := t.Underlying().(*types.Map); !ok {
+			return true
+		}
+		if inWorkflow(pass, stack) {
+			pass.Reportf(stmt.Pos(), "range over map %s in workflow code is not deterministic, use workflow.SortedRange or workflow.SortedKeys",
+				types.TypeString(t, types.RelativeTo(pass.Pkg)))
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// inWorkflow returns true if one of the functions enclosing the last node of the stack takes a workflow.Context as
+// first parameter.
+func inWorkflow(pass *analysis.Pass, stack []ast.Node) bool {
+	for _, n := range stack {
+		var fnType *ast.FuncType
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			fnType = fn.Type
+		case *ast.FuncLit:
+			fnType = fn.Type
+		default:
+			continue
+		}
+		if fnType.Params == nil || len(fnType.Params.List) == 0 {
+			continue
+		}
+		if isWorkflowContext(pass.TypesInfo.TypeOf(fnType.Params.List[0].Type)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWorkflowContext(t types.Type) bool {
+	// workflow.Context is an alias, which is a *types.Alias rather than the aliased *types.Named with recent versions
+	named, ok := t.(interface{ Obj() *types.TypeName })
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Name() != "Context" {
+		return false
+	}
+	switch named.Obj().Pkg().Path() {
+	case workflowPackage, internalPackage:
+		return true
+	}
+	return false
+}
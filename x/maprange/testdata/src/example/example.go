@@ -0,0 +1,48 @@
+package example
+
+import (
+	"context"
+
+	"go.uber.org/cadence/workflow"
+)
+
+type Weights map[string]int
+
+func Order(ctx workflow.Context, weights map[string]int, items []string) error {
+	for name := range weights { // want `range over map map\[string\]int in workflow code`
+		workflow.ExecuteActivity(ctx, name)
+	}
+	for _, item := range items {
+		workflow.ExecuteActivity(ctx, item)
+	}
+	for range (Weights{}) { // want `range over map Weights in workflow code`
+	}
+	workflow.SortedRange(weights, func(name string, weight int) bool {
+		for k := range map[int]bool{} { // want `range over map map\[int\]bool in workflow code`
+			_ = k
+		}
+		return true
+	})
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for range weights { // want `range over map map\[string\]int in workflow code`
+		}
+	})
+	for _, name := range workflow.SortedKeys(weights) {
+		workflow.ExecuteActivity(ctx, name)
+	}
+	return nil
+}
+
+func Charge(ctx context.Context, weights map[string]int) error {
+	for range weights {
+	}
+	return nil
+}
+
+func total(weights Weights) int {
+	var sum int
+	for _, weight := range weights {
+		sum += weight
+	}
+	return sum
+}
@@ -0,0 +1,6 @@
+package internal
+
+type (
+	Context interface{}
+	Future  interface{}
+)
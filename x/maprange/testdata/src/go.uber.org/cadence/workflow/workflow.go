@@ -0,0 +1,16 @@
+package workflow
+
+import "go.uber.org/cadence/internal"
+
+type (
+	Context = internal.Context
+	Future  = internal.Future
+)
+
+func ExecuteActivity(ctx Context, activity interface{}, args ...interface{}) Future { return nil }
+
+func Go(ctx Context, f func(ctx Context)) {}
+
+func SortedKeys[K string, V any](m map[K]V) []K { return nil }
+
+func SortedRange[K string, V any](m map[K]V, fn func(key K, value V) bool) {}
@@ -0,0 +1,132 @@
+// Command positionalargs reports workflows and activities with several positional arguments, see the positionalargs
+// package. It is run by go vet:
+//
+//	go vet -vettool=$(which positionalargs) ./...
+//
+// and "positionalargs fix ./..." converts the positional values passed to the ones taking a single struct to struct
+// literals, by applying the fixes suggested by go vet -json.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"go.uber.org/cadence/x/positionalargs"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fix" {
+		if err := fix(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	unitchecker.Main(positionalargs.Analyzer)
+}
+
+type (
+	diagnostic struct {
+		Posn           string `json:"posn"`
+		Message        string `json:"message"`
+		SuggestedFixes []struct {
+			Edits []edit `json:"edits"`
+		} `json:"suggested_fixes"`
+	}
+
+	edit struct {
+		Filename string `json:"filename"`
+		Start    int    `json:"start"`
+		End      int    `json:"end"`
+		New      string `json:"new"`
+	}
+)
+
+func fix(patterns []string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("go", append([]string{"vet", "-vettool=" + self, "-json"}, patterns...)...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("go vet: %v\n%s", err, output.Bytes())
+	}
+
+	// the JSON tree of each package, keyed by package and analyzer, follows a "# package" line
+	var tree bytes.Buffer
+	for _, line := range bytes.SplitAfter(output.Bytes(), []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("#")) {
+			tree.Write(line)
+		}
+	}
+	edits := map[string][]edit{}
+	decoder := json.NewDecoder(&tree)
+	for {
+		var packages map[string]map[string]json.RawMessage
+		if err := decoder.Decode(&packages); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("invalid go vet output: %v", err)
+		}
+		for _, analyzers := range packages {
+			result, ok := analyzers[positionalargs.Analyzer.Name]
+			if !ok {
+				continue
+			}
+			var diagnostics []diagnostic
+			if err := json.Unmarshal(result, &diagnostics); err != nil {
+				// the analyzer failed, e.g. the package does not type check
+				return fmt.Errorf("%s", result)
+			}
+			for _, d := range diagnostics {
+				if len(d.SuggestedFixes) == 0 {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", d.Posn, d.Message)
+					continue
+				}
+				for _, e := range d.SuggestedFixes[0].Edits {
+					edits[e.Filename] = append(edits[e.Filename], e)
+				}
+			}
+		}
+	}
+
+	for filename, fileEdits := range edits {
+		converted, err := apply(filename, fileEdits)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d invocations converted\n", filename, converted)
+	}
+	return nil
+}
+
+// apply replaces the edited ranges of the file, which do not overlap. An edit may be reported by several packages,
+// e.g. by the package and its test variant.
+func apply(filename string, edits []edit) (int, error) {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	converted := 0
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start > edits[j].Start })
+	previous := -1
+	for _, e := range edits {
+		if e.Start == previous {
+			continue
+		}
+		previous = e.Start
+		converted++
+		source = append(source[:e.Start], append([]byte(e.New), source[e.End:]...)...)
+	}
+	return converted, os.WriteFile(filename, source, 0644)
+}
@@ -0,0 +1,256 @@
+// Package positionalargs reports workflows and activities taking several positional arguments, and converts the
+// invocations of the ones migrated to a single argument struct.
+package positionalargs
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"strconv"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `report workflows and activities with several positional arguments
+
+Adding, removing or reordering a positional argument breaks running workflows and in-flight activities, while a
+field can be added to a single argument struct safely, as unknown and missing fields are tolerated when decoding.
+
+The analyzer reports the registration and invocation of functions with more than one argument besides the
+context. Once such a function takes a single struct instead, the invocations still passing positional values are
+reported with a fix converting them to a struct literal, which "positionalargs fix" applies.`
+
+// Analyzer reports the positional arguments of workflows and activities.
+var Analyzer = &analysis.Analyzer{
+	Name:     "positionalargs",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+const (
+	workflowPackage = "go.uber.org/cadence/workflow"
+	activityPackage = "go.uber.org/cadence/activity"
+	clientPackage   = "go.uber.org/cadence/client"
+	workerPackage   = "go.uber.org/cadence/worker"
+	internalPackage = "go.uber.org/cadence/internal"
+)
+
+// call is a function of the Cadence packages which takes a workflow or activity function.
+type call struct {
+	pkg  string
+	name string
+	// fn is the index of the workflow or activity function in the arguments of the call
+	fn int
+	// args is whether the arguments of the call after fn are passed to the function
+	args bool
+}
+
+var calls = []call{
+	{workflowPackage, "ExecuteActivity", 1, true},
+	{workflowPackage, "ExecuteLocalActivity", 1, true},
+	{workflowPackage, "ExecuteChildWorkflow", 1, true},
+	{workflowPackage, "NewContinueAsNewError", 1, true},
+	{clientPackage, "ExecuteWorkflow", 2, true},
+	{clientPackage, "StartWorkflow", 2, true},
+	{clientPackage, "SignalWithStartWorkflow", 5, true},
+	{workerPackage, "RegisterWorkflow", 0, false},
+	{workerPackage, "RegisterWorkflowWithOptions", 0, false},
+	{workerPackage, "RegisterActivity", 0, false},
+	{workerPackage, "RegisterActivityWithOptions", 0, false},
+	{workflowPackage, "Register", 0, false},
+	{workflowPackage, "RegisterWithOptions", 0, false},
+	{activityPackage, "Register", 0, false},
+	{activityPackage, "RegisterWithOptions", 0, false},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	var file *ast.File
+	inspect.Preorder([]ast.Node{(*ast.File)(nil), (*ast.CallExpr)(nil)}, func(n ast.Node) {
+		if f, ok := n.(*ast.File); ok {
+			file = f
+			return
+		}
+		expr := n.(*ast.CallExpr)
+		c, ok := matchCall(pass, expr)
+		if !ok || len(expr.Args) <= c.fn {
+			return
+		}
+		fn, ok := calledFunction(pass, expr.Args[c.fn])
+		if !ok {
+			return
+		}
+		params := functionParams(fn.Type().(*types.Signature))
+		if len(params) > 1 {
+			pass.Reportf(expr.Pos(), "%s takes %d positional arguments, use a single struct argument so that it can evolve without breaking running workflows",
+				fn.Name(), len(params))
+			return
+		}
+		if !c.args || expr.Ellipsis.IsValid() {
+			return
+		}
+		values := expr.Args[c.fn+1:]
+		if len(params) != 1 || len(values) < 2 {
+			return
+		}
+		structType, ok := params[0].Type().Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		diagnostic := analysis.Diagnostic{
+			Pos: values[0].Pos(),
+			End: values[len(values)-1].End(),
+			Message: fmt.Sprintf("%s takes a single %s argument but is passed %d positional values", fn.Name(),
+				types.TypeString(params[0].Type(), types.RelativeTo(pass.Pkg)), len(values)),
+		}
+		if fix, ok := structLiteralFix(pass, file, params[0].Type(), structType, values); ok {
+			diagnostic.SuggestedFixes = []analysis.SuggestedFix{fix}
+		}
+		pass.Report(diagnostic)
+	})
+	return nil, nil
+}
+
+// matchCall returns the Cadence function called by the expression, as a package function or as a method of one of
+// the interfaces of the package, e.g. worker.Worker.
+func matchCall(pass *analysis.Pass, expr *ast.CallExpr) (call, bool) {
+	selector, ok := unparen(expr.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return call{}, false
+	}
+	fn, ok := pass.TypesInfo.Uses[selector.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return call{}, false
+	}
+	pkg := fn.Pkg().Path()
+	if fn.Type().(*types.Signature).Recv() != nil && pkg == internalPackage {
+		// methods of the interfaces aliased by the public packages, i.e. client.Client
+		pkg = clientPackage
+	}
+	for _, c := range calls {
+		if c.pkg == pkg && c.name == fn.Name() {
+			return c, true
+		}
+	}
+	return call{}, false
+}
+
+// calledFunction returns the function of the expression, if it is a function or method declared in Go.
+func calledFunction(pass *analysis.Pass, expr ast.Expr) (*types.Func, bool) {
+	var ident *ast.Ident
+	switch e := unparen(expr).(type) {
+	case *ast.Ident:
+		ident = e
+	case *ast.SelectorExpr:
+		ident = e.Sel
+	default:
+		return nil, false
+	}
+	fn, ok := pass.TypesInfo.Uses[ident].(*types.Func)
+	return fn, ok
+}
+
+// functionParams returns the parameters of the function, without the context.
+func functionParams(signature *types.Signature) []*types.Var {
+	var params []*types.Var
+	for i := 0; i < signature.Params().Len(); i++ {
+		param := signature.Params().At(i)
+		if i == 0 && isContext(param.Type()) {
+			continue
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+func isContext(t types.Type) bool {
+	// workflow.Context is an alias, which is a *types.Alias rather than the aliased *types.Named with recent versions
+	named, ok := t.(interface{ Obj() *types.TypeName })
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Name() != "Context" {
+		return false
+	}
+	switch named.Obj().Pkg().Path() {
+	case "context", workflowPackage, internalPackage:
+		return true
+	}
+	return false
+}
+
+// structLiteralFix replaces the positional values by a literal of the struct, when they match its fields.
+func structLiteralFix(pass *analysis.Pass, file *ast.File, t types.Type, structType *types.Struct, values []ast.Expr) (analysis.SuggestedFix, bool) {
+	if structType.NumFields() != len(values) {
+		return analysis.SuggestedFix{}, false
+	}
+	name := typeString(pass, file, t)
+	if name == "" {
+		return analysis.SuggestedFix{}, false
+	}
+	var literal bytes.Buffer
+	literal.WriteString(name + "{")
+	for i, value := range values {
+		field := structType.Field(i)
+		if !field.Exported() && field.Pkg() != pass.Pkg {
+			return analysis.SuggestedFix{}, false
+		}
+		valueType := pass.TypesInfo.TypeOf(value)
+		if valueType == nil || !types.AssignableTo(valueType, field.Type()) {
+			return analysis.SuggestedFix{}, false
+		}
+		if i > 0 {
+			literal.WriteString(", ")
+		}
+		literal.WriteString(field.Name() + ": ")
+		if err := format.Node(&literal, pass.Fset, value); err != nil {
+			return analysis.SuggestedFix{}, false
+		}
+	}
+	literal.WriteString("}")
+	return analysis.SuggestedFix{
+		Message: "Convert to a struct literal",
+		TextEdits: []analysis.TextEdit{{
+			Pos:     values[0].Pos(),
+			End:     values[len(values)-1].End(),
+			NewText: literal.Bytes(),
+		}},
+	}, true
+}
+
+// typeString returns the name of the type in the file, or "" if its package is not imported by the file.
+func typeString(pass *analysis.Pass, file *ast.File, t types.Type) string {
+	imported := true
+	name := types.TypeString(t, func(pkg *types.Package) string {
+		if pkg == pass.Pkg {
+			return ""
+		}
+		for _, spec := range file.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err != nil || path != pkg.Path() {
+				continue
+			}
+			if spec.Name != nil {
+				return spec.Name.Name
+			}
+			return pkg.Name()
+		}
+		imported = false
+		return pkg.Name()
+	})
+	if !imported {
+		return ""
+	}
+	return name
+}
+
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
@@ -0,0 +1,142 @@
+package positionalargs
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// the analyzer is run on the packages of testdata/src, with stubs of the Cadence packages, and each diagnostic must
+// match the `// want` comment of its line, like with analysistest
+
+func TestAnalyzer(t *testing.T) {
+	fset := token.NewFileSet()
+	imp := &testImporter{fset: fset, fallback: importer.ForCompiler(fset, "source", nil), packages: map[string]*types.Package{}}
+	files, pkg, info, err := imp.check("example")
+	require.NoError(t, err)
+
+	var diagnostics []analysis.Diagnostic
+	pass := &analysis.Pass{
+		Analyzer:   Analyzer,
+		Fset:       fset,
+		Files:      files,
+		Pkg:        pkg,
+		TypesInfo:  info,
+		TypesSizes: types.SizesFor("gc", "amd64"),
+		ResultOf:   map[*analysis.Analyzer]interface{}{inspect.Analyzer: inspector.New(files)},
+		Report: func(d analysis.Diagnostic) {
+			diagnostics = append(diagnostics, d)
+		},
+	}
+	_, err = Analyzer.Run(pass)
+	require.NoError(t, err)
+
+	wants := wantComments(t, fset, files)
+	for _, d := range diagnostics {
+		line := fset.Position(d.Pos).Line
+		want, ok := wants[line]
+		if assert.True(t, ok, "unexpected diagnostic on line %d: %s", line, d.Message) {
+			assert.Regexp(t, want, d.Message, "line %d", line)
+			delete(wants, line)
+		}
+	}
+	assert.Empty(t, wants, "missing diagnostics")
+
+	filename := fset.Position(files[0].Pos()).Filename
+	source, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	golden, err := os.ReadFile(filename + ".golden")
+	require.NoError(t, err)
+	var edits []analysis.TextEdit
+	for _, d := range diagnostics {
+		for _, fix := range d.SuggestedFixes {
+			edits = append(edits, fix.TextEdits...)
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Pos > edits[j].Pos })
+	for _, edit := range edits {
+		start, end := fset.Position(edit.Pos).Offset, fset.Position(edit.End).Offset
+		source = append(source[:start], append(edit.NewText, source[end:]...)...)
+	}
+	assert.Equal(t, string(golden), string(source))
+}
+
+var wantPattern = regexp.MustCompile("^// want `(.*)`$")
+
+func wantComments(t *testing.T, fset *token.FileSet, files []*ast.File) map[int]*regexp.Regexp {
+	wants := map[int]*regexp.Regexp{}
+	for _, file := range files {
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				if match := wantPattern.FindStringSubmatch(comment.Text); match != nil {
+					wants[fset.Position(comment.Pos()).Line] = regexp.MustCompile(match[1])
+				}
+			}
+		}
+	}
+	require.NotEmpty(t, wants)
+	return wants
+}
+
+// testImporter type checks the packages of testdata/src from source, and the other ones, i.e. the standard library,
+// with the fallback importer.
+type testImporter struct {
+	fset     *token.FileSet
+	fallback types.Importer
+	packages map[string]*types.Package
+}
+
+func (i *testImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := i.packages[path]; ok {
+		return pkg, nil
+	}
+	if _, err := os.Stat(filepath.Join("testdata", "src", path)); err != nil {
+		return i.fallback.Import(path)
+	}
+	_, pkg, _, err := i.check(path)
+	return pkg, err
+}
+
+func (i *testImporter) check(path string) ([]*ast.File, *types.Package, *types.Info, error) {
+	dir := filepath.Join("testdata", "src", path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var files []*ast.File
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		file, err := parser.ParseFile(i.fset, filepath.Join(dir, entry.Name()), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		files = append(files, file)
+	}
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	pkg, err := (&types.Config{Importer: i}).Check(path, i.fset, files, info)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	i.packages[path] = pkg
+	return files, pkg, info, nil
+}
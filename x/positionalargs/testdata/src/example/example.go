@@ -0,0 +1,47 @@
+package example
+
+import (
+	"context"
+
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+)
+
+type OrderArgs struct {
+	ID     string
+	Amount int
+}
+
+func Order(ctx workflow.Context, args OrderArgs) error {
+	workflow.ExecuteActivity(ctx, Charge, args.ID, args.Amount) // want `Charge takes 2 positional arguments, use a single struct argument`
+	workflow.ExecuteActivity(ctx, Ship, args)
+	workflow.ExecuteActivity(ctx, Ship, "order-1", 10)   // want `Ship takes a single OrderArgs argument but is passed 2 positional values`
+	workflow.ExecuteActivity(ctx, Ship, "order-1", "10") // want `Ship takes a single OrderArgs argument but is passed 2 positional values`
+	workflow.ExecuteActivity(ctx, "Ship", "order-1", 10)
+	workflow.ExecuteChildWorkflow(ctx, Legacy, "order-1") // want `Legacy takes 2 positional arguments`
+	return nil
+}
+
+func Legacy(ctx workflow.Context, id string, amount int) error {
+	return nil
+}
+
+func Charge(ctx context.Context, id string, amount int) error {
+	return nil
+}
+
+func Ship(ctx context.Context, args OrderArgs) error {
+	return nil
+}
+
+func register(w worker.Worker) {
+	w.RegisterWorkflow(Order)
+	w.RegisterWorkflow(Legacy) // want `Legacy takes 2 positional arguments`
+	w.RegisterActivity(Ship)
+	workflow.Register(Legacy) // want `Legacy takes 2 positional arguments`
+}
+
+func start(ctx context.Context, c client.Client) {
+	c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{}, Order, "order-1", 10) // want `Order takes a single OrderArgs argument but is passed 2 positional values`
+}
@@ -0,0 +1,9 @@
+package client
+
+import "go.uber.org/cadence/internal"
+
+type (
+	StartWorkflowOptions = internal.StartWorkflowOptions
+	WorkflowRun          = internal.WorkflowRun
+	Client               = internal.Client
+)
@@ -0,0 +1,14 @@
+package internal
+
+import "context"
+
+type (
+	Context              interface{}
+	Future               interface{}
+	StartWorkflowOptions struct{}
+	WorkflowRun          interface{}
+
+	Client interface {
+		ExecuteWorkflow(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (WorkflowRun, error)
+	}
+)
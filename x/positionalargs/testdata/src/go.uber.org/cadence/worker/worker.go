@@ -0,0 +1,13 @@
+package worker
+
+type (
+	Registry interface {
+		RegisterWorkflow(w interface{})
+		RegisterActivity(a interface{})
+	}
+
+	Worker interface {
+		Registry
+		Start() error
+	}
+)
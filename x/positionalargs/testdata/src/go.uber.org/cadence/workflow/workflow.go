@@ -0,0 +1,16 @@
+package workflow
+
+import "go.uber.org/cadence/internal"
+
+type (
+	Context = internal.Context
+	Future  = internal.Future
+)
+
+func ExecuteActivity(ctx Context, activity interface{}, args ...interface{}) Future { return nil }
+
+func ExecuteChildWorkflow(ctx Context, childWorkflow interface{}, args ...interface{}) Future {
+	return nil
+}
+
+func Register(workflowFunc interface{}) {}
@@ -0,0 +1,195 @@
+package tasklisthealth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/client"
+)
+
+// The problems reported by Check.
+const (
+	// ProblemNoPollers is reported when no poller polled the task list within Options.StaleAfter.
+	ProblemNoPollers Problem = "no-pollers"
+	// ProblemStalePollers is reported when some pollers did not poll the task list within Options.StaleAfter, e.g.
+	// because their worker is stuck or was stopped without being replaced.
+	ProblemStalePollers Problem = "stale-pollers"
+	// ProblemTooFewZones is reported when the fresh pollers of the task list run in less than Options.MinZones zones,
+	// e.g. all in one zone, so losing a zone stops the task list.
+	ProblemTooFewZones Problem = "too-few-zones"
+)
+
+const (
+	defaultStaleAfter = 2 * time.Minute
+	defaultMinZones   = 2
+)
+
+type (
+	// Problem is a reason for a task list to be unhealthy.
+	Problem string
+
+	// Options configures Check.
+	Options struct {
+		// Optional: task list types to check.
+		// default: decision and activity
+		TaskListTypes []shared.TaskListType
+
+		// Optional: pollers which did not poll the task list for longer are stale. A poll lasts up to a minute, so
+		// it should be well over a minute.
+		// default: 2 minutes
+		StaleAfter time.Duration
+
+		// Optional: returns the zone of a poller from its identity, e.g. by parsing its host name or looking it up.
+		// Pollers mapped to an empty zone are ignored by the zone check.
+		// default: the host name in the default worker identity, "pid@host@tasklist@uuid", so the zone check requires
+		// pollers on several hosts
+		Zone func(identity string) string
+
+		// Optional: number of zones the fresh pollers of each task list type must run in. 1 disables the check.
+		// default: 2
+		MinZones int
+	}
+
+	// Verdict is the result of Check.
+	Verdict struct {
+		TaskList string
+		// TaskListTypes has the health of each type checked, in the order of Options.TaskListTypes.
+		TaskListTypes []TaskListHealth
+	}
+
+	// TaskListHealth is the health of one type of the task list.
+	TaskListHealth struct {
+		Type shared.TaskListType
+		// Pollers is the number of pollers the server reported, stale or not.
+		Pollers int
+		// StalePollers are the identities of the stale pollers, sorted.
+		StalePollers []string
+		// Zones are the zones of the fresh pollers, sorted.
+		Zones    []string
+		Problems []Problem
+	}
+)
+
+// Check describes each type of the task list with c, in the domain of c, and evaluates the recency and zones of its
+// pollers. The returned error is only set when the task list cannot be described, see Verdict.Err for the problems.
+func Check(ctx context.Context, c client.Client, taskList string, options Options) (*Verdict, error) {
+	options = options.withDefaults()
+	verdict := &Verdict{TaskList: taskList}
+	now := time.Now()
+	for _, taskListType := range options.TaskListTypes {
+		response, err := c.DescribeTaskList(ctx, taskList, taskListType)
+		if err != nil {
+			return nil, fmt.Errorf("describe %v task list %q: %w", taskListType, taskList, err)
+		}
+		verdict.TaskListTypes = append(verdict.TaskListTypes, evaluate(taskListType, response.GetPollers(), options, now))
+	}
+	return verdict, nil
+}
+
+func evaluate(taskListType shared.TaskListType, pollers []*shared.PollerInfo, options Options, now time.Time) TaskListHealth {
+	health := TaskListHealth{Type: taskListType, Pollers: len(pollers), StalePollers: []string{}, Zones: []string{}}
+	zones := make(map[string]struct{})
+	fresh := 0
+	for _, poller := range pollers {
+		if now.Sub(time.Unix(0, poller.GetLastAccessTime())) > options.StaleAfter {
+			health.StalePollers = append(health.StalePollers, poller.GetIdentity())
+			continue
+		}
+		fresh++
+		if zone := options.Zone(poller.GetIdentity()); zone != "" {
+			zones[zone] = struct{}{}
+		}
+	}
+	for zone := range zones {
+		health.Zones = append(health.Zones, zone)
+	}
+	sort.Strings(health.StalePollers)
+	sort.Strings(health.Zones)
+
+	if fresh == 0 {
+		health.Problems = append(health.Problems, ProblemNoPollers)
+	}
+	if len(health.StalePollers) > 0 {
+		health.Problems = append(health.Problems, ProblemStalePollers)
+	}
+	if fresh > 0 && len(health.Zones) < options.MinZones {
+		health.Problems = append(health.Problems, ProblemTooFewZones)
+	}
+	return health
+}
+
+// Healthy returns true if no type of the task list has a problem.
+func (v *Verdict) Healthy() bool {
+	return v.Err() == nil
+}
+
+// Problems returns the problems of all types of the task list, without duplicates.
+func (v *Verdict) Problems() []Problem {
+	var problems []Problem
+	seen := make(map[Problem]struct{})
+	for _, health := range v.TaskListTypes {
+		for _, problem := range health.Problems {
+			if _, ok := seen[problem]; !ok {
+				seen[problem] = struct{}{}
+				problems = append(problems, problem)
+			}
+		}
+	}
+	return problems
+}
+
+// Err returns an error describing the problems of the task list, or nil if it is healthy.
+func (v *Verdict) Err() error {
+	var messages []string
+	for _, health := range v.TaskListTypes {
+		for _, problem := range health.Problems {
+			messages = append(messages, fmt.Sprintf("%v task list %q: %v", health.Type, v.TaskList, health.describe(problem)))
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+func (h TaskListHealth) describe(problem Problem) string {
+	switch problem {
+	case ProblemNoPollers:
+		return fmt.Sprintf("no fresh pollers, %d stale", len(h.StalePollers))
+	case ProblemStalePollers:
+		return fmt.Sprintf("stale pollers %v", h.StalePollers)
+	case ProblemTooFewZones:
+		return fmt.Sprintf("fresh pollers only in zones %v", h.Zones)
+	}
+	return string(problem)
+}
+
+func (o Options) withDefaults() Options {
+	if len(o.TaskListTypes) == 0 {
+		o.TaskListTypes = []shared.TaskListType{shared.TaskListTypeDecision, shared.TaskListTypeActivity}
+	}
+	if o.StaleAfter <= 0 {
+		o.StaleAfter = defaultStaleAfter
+	}
+	if o.Zone == nil {
+		o.Zone = hostFromIdentity
+	}
+	if o.MinZones <= 0 {
+		o.MinZones = defaultMinZones
+	}
+	return o
+}
+
+// hostFromIdentity returns the host of the default worker identity, or the identity itself if it has another format.
+func hostFromIdentity(identity string) string {
+	parts := strings.Split(identity, "@")
+	if len(parts) < 2 {
+		return identity
+	}
+	return parts[1]
+}
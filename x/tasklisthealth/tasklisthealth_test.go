@@ -0,0 +1,119 @@
+package tasklisthealth_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/mocks"
+	"go.uber.org/cadence/x/tasklisthealth"
+)
+
+func poller(identity string, age time.Duration) *shared.PollerInfo {
+	lastAccessTime := time.Now().Add(-age).UnixNano()
+	return &shared.PollerInfo{Identity: &identity, LastAccessTime: &lastAccessTime}
+}
+
+func mockClient(decisionPollers, activityPollers []*shared.PollerInfo) *mocks.Client {
+	c := &mocks.Client{}
+	c.On("DescribeTaskList", mock.Anything, "orders", shared.TaskListTypeDecision).
+		Return(&shared.DescribeTaskListResponse{Pollers: decisionPollers}, nil)
+	c.On("DescribeTaskList", mock.Anything, "orders", shared.TaskListTypeActivity).
+		Return(&shared.DescribeTaskListResponse{Pollers: activityPollers}, nil)
+	return c
+}
+
+func TestCheckHealthy(t *testing.T) {
+	pollers := []*shared.PollerInfo{
+		poller("1@host-a@orders@uuid", time.Second),
+		poller("2@host-b@orders@uuid", 30*time.Second),
+	}
+	verdict, err := tasklisthealth.Check(context.Background(), mockClient(pollers, pollers), "orders", tasklisthealth.Options{})
+	require.NoError(t, err)
+	assert.True(t, verdict.Healthy())
+	assert.NoError(t, verdict.Err())
+	assert.Empty(t, verdict.Problems())
+	require.Len(t, verdict.TaskListTypes, 2)
+	assert.Equal(t, shared.TaskListTypeDecision, verdict.TaskListTypes[0].Type)
+	assert.Equal(t, 2, verdict.TaskListTypes[0].Pollers)
+	assert.Equal(t, []string{"host-a", "host-b"}, verdict.TaskListTypes[0].Zones)
+}
+
+func TestCheckProblems(t *testing.T) {
+	decisionPollers := []*shared.PollerInfo{
+		poller("1@host-a@orders@uuid", time.Second),
+		poller("2@host-a@orders@uuid", time.Second),
+		poller("3@host-b@orders@uuid", time.Hour),
+	}
+	activityPollers := []*shared.PollerInfo{
+		poller("4@host-c@orders@uuid", time.Hour),
+	}
+	verdict, err := tasklisthealth.Check(context.Background(), mockClient(decisionPollers, activityPollers), "orders", tasklisthealth.Options{})
+	require.NoError(t, err)
+	assert.False(t, verdict.Healthy())
+	assert.Equal(t, []tasklisthealth.Problem{
+		tasklisthealth.ProblemStalePollers,
+		tasklisthealth.ProblemTooFewZones,
+		tasklisthealth.ProblemNoPollers,
+	}, verdict.Problems())
+
+	decision := verdict.TaskListTypes[0]
+	assert.Equal(t, []string{"3@host-b@orders@uuid"}, decision.StalePollers)
+	assert.Equal(t, []string{"host-a"}, decision.Zones)
+	activity := verdict.TaskListTypes[1]
+	assert.Equal(t, []tasklisthealth.Problem{tasklisthealth.ProblemNoPollers, tasklisthealth.ProblemStalePollers}, activity.Problems)
+
+	require.Error(t, verdict.Err())
+	assert.Equal(t, []string{
+		`Decision task list "orders": stale pollers [3@host-b@orders@uuid]`,
+		`Decision task list "orders": fresh pollers only in zones [host-a]`,
+		`Activity task list "orders": no fresh pollers, 1 stale`,
+		`Activity task list "orders": stale pollers [4@host-c@orders@uuid]`,
+	}, strings.Split(verdict.Err().Error(), "; "))
+}
+
+func TestCheckOptions(t *testing.T) {
+	pollers := []*shared.PollerInfo{
+		poller("worker-1.us-east-1a", 5*time.Minute),
+		poller("worker-2.us-east-1b", 5*time.Minute),
+		poller("worker-3.us-east-1b", 5*time.Minute),
+	}
+	c := &mocks.Client{}
+	c.On("DescribeTaskList", mock.Anything, "orders", shared.TaskListTypeDecision).
+		Return(&shared.DescribeTaskListResponse{Pollers: pollers}, nil)
+	options := tasklisthealth.Options{
+		TaskListTypes: []shared.TaskListType{shared.TaskListTypeDecision},
+		StaleAfter:    10 * time.Minute,
+		Zone: func(identity string) string {
+			return identity[strings.Index(identity, ".")+1:]
+		},
+	}
+
+	verdict, err := tasklisthealth.Check(context.Background(), c, "orders", options)
+	require.NoError(t, err)
+	assert.True(t, verdict.Healthy())
+	assert.Equal(t, []string{"us-east-1a", "us-east-1b"}, verdict.TaskListTypes[0].Zones)
+
+	options.MinZones = 3
+	verdict, err = tasklisthealth.Check(context.Background(), c, "orders", options)
+	require.NoError(t, err)
+	assert.Equal(t, []tasklisthealth.Problem{tasklisthealth.ProblemTooFewZones}, verdict.Problems())
+	c.AssertNotCalled(t, "DescribeTaskList", mock.Anything, "orders", shared.TaskListTypeActivity)
+}
+
+func TestCheckDescribeError(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("DescribeTaskList", mock.Anything, "orders", shared.TaskListTypeDecision).
+		Return(nil, &shared.EntityNotExistsError{Message: "domain not found"})
+	_, err := tasklisthealth.Check(context.Background(), c, "orders", tasklisthealth.Options{})
+	var notExists *shared.EntityNotExistsError
+	assert.True(t, errors.As(err, &notExists))
+	assert.Contains(t, err.Error(), `describe Decision task list "orders"`)
+}
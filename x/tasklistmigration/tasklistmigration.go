@@ -0,0 +1,286 @@
+package tasklistmigration
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/uber-go/tally"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+)
+
+const (
+	// StartCounter counts workflow starts routed by the client returned by NewClient, tagged by task list and role.
+	StartCounter = "cadence-tasklist-migration-start"
+
+	tagTaskList = "tasklist"
+	tagRole     = "role"
+	roleOld     = "old"
+	roleNew     = "new"
+
+	routingBuckets = 10000
+)
+
+type (
+	// Options describes a task list migration.
+	Options struct {
+		// Required: task list which is being renamed.
+		OldTaskList string
+
+		// Required: task list replacing OldTaskList.
+		NewTaskList string
+
+		// Optional: fraction of the starts targeting OldTaskList which are sent to NewTaskList instead, between 0 and 1.
+		// Starts with a workflow ID are routed by a hash of the ID, so retried starts of the same workflow target the
+		// same task list. Only raise it once workers poll NewTaskList, see NewWorker.
+		// default: 0, starts keep OldTaskList
+		NewTaskListRatio float64
+
+		// Optional: scope of the StartCounter metric.
+		// default: no metrics
+		MetricsScope tally.Scope
+	}
+
+	migrationWorker struct {
+		oldWorker worker.Worker
+		newWorker worker.Worker
+	}
+
+	migrationClient struct {
+		client.Client
+		options Options
+
+		sync.Mutex
+		rand *rand.Rand
+	}
+)
+
+var (
+	_ worker.Worker               = (*migrationWorker)(nil)
+	_ worker.MetricsProvider      = (*migrationWorker)(nil)
+	_ worker.Validator            = (*migrationWorker)(nil)
+	_ worker.CapabilitiesProvider = (*migrationWorker)(nil)
+)
+
+// NewWorker returns a worker polling both options.OldTaskList and options.NewTaskList with the same registrations
+// and worker options. Run it in place of the worker of the old task list for the whole migration window, i.e. until
+// no workflow started on the old task list is open anymore. Metrics of each task list are tagged with its name as usual.
+func NewWorker(
+	service workflowserviceclient.Interface,
+	domain string,
+	options Options,
+	workerOptions worker.Options,
+) (worker.Worker, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	oldWorker, err := worker.NewV2(service, domain, options.OldTaskList, workerOptions)
+	if err != nil {
+		return nil, err
+	}
+	newWorker, err := worker.NewV2(service, domain, options.NewTaskList, workerOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &migrationWorker{oldWorker: oldWorker, newWorker: newWorker}, nil
+}
+
+// NewClient returns a client which sends a fraction of the workflow starts targeting options.OldTaskList to
+// options.NewTaskList, see Options.NewTaskListRatio. All other calls are passed to c as is.
+func NewClient(c client.Client, options Options) (client.Client, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	if options.MetricsScope == nil {
+		options.MetricsScope = tally.NoopScope
+	}
+	return &migrationClient{
+		Client:  c,
+		options: options,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+func (o Options) validate() error {
+	if o.OldTaskList == "" || o.NewTaskList == "" {
+		return errors.New("tasklistmigration: OldTaskList and NewTaskList are required")
+	}
+	if o.OldTaskList == o.NewTaskList {
+		return errors.New("tasklistmigration: OldTaskList and NewTaskList must differ")
+	}
+	if o.NewTaskListRatio < 0 || o.NewTaskListRatio > 1 {
+		return errors.New("tasklistmigration: NewTaskListRatio must be between 0 and 1")
+	}
+	return nil
+}
+
+func (w *migrationWorker) RegisterWorkflow(wf interface{}) {
+	w.oldWorker.RegisterWorkflow(wf)
+	w.newWorker.RegisterWorkflow(wf)
+}
+
+func (w *migrationWorker) RegisterWorkflowWithOptions(wf interface{}, options workflow.RegisterOptions) {
+	w.oldWorker.RegisterWorkflowWithOptions(wf, options)
+	w.newWorker.RegisterWorkflowWithOptions(wf, options)
+}
+
+func (w *migrationWorker) RegisterActivity(a interface{}) {
+	w.oldWorker.RegisterActivity(a)
+	w.newWorker.RegisterActivity(a)
+}
+
+func (w *migrationWorker) RegisterActivityWithOptions(a interface{}, options activity.RegisterOptions) {
+	w.oldWorker.RegisterActivityWithOptions(a, options)
+	w.newWorker.RegisterActivityWithOptions(a, options)
+}
+
+// GetRegisteredWorkflows returns the workflows registered on both task lists.
+func (w *migrationWorker) GetRegisteredWorkflows() []workflow.RegistryInfo {
+	return w.oldWorker.GetRegisteredWorkflows()
+}
+
+// GetRegisteredActivities returns the activities registered on both task lists.
+func (w *migrationWorker) GetRegisteredActivities() []activity.RegistryInfo {
+	return w.oldWorker.GetRegisteredActivities()
+}
+
+func (w *migrationWorker) Start() error {
+	if err := w.oldWorker.Start(); err != nil {
+		return err
+	}
+	if err := w.newWorker.Start(); err != nil {
+		w.oldWorker.Stop()
+		return err
+	}
+	return nil
+}
+
+func (w *migrationWorker) Run() error {
+	if err := w.Start(); err != nil {
+		return err
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	<-c
+	w.Stop()
+	return nil
+}
+
+func (w *migrationWorker) Stop() {
+	w.newWorker.Stop()
+	w.oldWorker.Stop()
+}
+
+// Metrics returns the sum of the pollers and task slots of both task lists.
+func (w *migrationWorker) Metrics() worker.Metrics {
+	return sumMetrics(w.oldWorker.(worker.MetricsProvider).Metrics(), w.newWorker.(worker.MetricsProvider).Metrics())
+}
+
+// Validate validates the workers of both task lists, the returned report has the checks of both.
+func (w *migrationWorker) Validate(ctx context.Context) (*worker.ValidationReport, error) {
+	oldReport, _ := w.oldWorker.(worker.Validator).Validate(ctx)
+	newReport, _ := w.newWorker.(worker.Validator).Validate(ctx)
+	report := &worker.ValidationReport{Checks: append(oldReport.Checks, newReport.Checks...)}
+	return report, report.Err()
+}
+
+// Capabilities returns the capabilities detected by the worker of the new task list, both workers use the same server.
+func (w *migrationWorker) Capabilities() worker.Capabilities {
+	return w.newWorker.(worker.CapabilitiesProvider).Capabilities()
+}
+
+func sumMetrics(a, b worker.Metrics) worker.Metrics {
+	sum := func(a, b worker.TaskMetrics) worker.TaskMetrics {
+		return worker.TaskMetrics{
+			PollersRunning: a.PollersRunning + b.PollersRunning,
+			TaskSlotsUsed:  a.TaskSlotsUsed + b.TaskSlotsUsed,
+			TaskSlotsTotal: a.TaskSlotsTotal + b.TaskSlotsTotal,
+		}
+	}
+	return worker.Metrics{
+		Decision:      sum(a.Decision, b.Decision),
+		Activity:      sum(a.Activity, b.Activity),
+		LocalActivity: sum(a.LocalActivity, b.LocalActivity),
+		// the sticky cache is shared by all workers in the process
+		StickyCacheSize:                   a.StickyCacheSize,
+		DecisionScheduleToStartLatencyP50: maxDuration(a.DecisionScheduleToStartLatencyP50, b.DecisionScheduleToStartLatencyP50),
+		DecisionScheduleToStartLatencyP99: maxDuration(a.DecisionScheduleToStartLatencyP99, b.DecisionScheduleToStartLatencyP99),
+	}
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (c *migrationClient) StartWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflowFunc interface{}, args ...interface{}) (*workflow.Execution, error) {
+	return c.Client.StartWorkflow(ctx, c.route(options.ID, options), workflowFunc, args...)
+}
+
+func (c *migrationClient) StartWorkflowAsync(ctx context.Context, options client.StartWorkflowOptions, workflowFunc interface{}, args ...interface{}) (*workflow.ExecutionAsync, error) {
+	return c.Client.StartWorkflowAsync(ctx, c.route(options.ID, options), workflowFunc, args...)
+}
+
+func (c *migrationClient) StartWorkflowValidated(ctx context.Context, options client.StartWorkflowOptions, workflowFunc interface{}, args ...interface{}) (*workflow.Execution, error) {
+	return c.Client.StartWorkflowValidated(ctx, c.route(options.ID, options), workflowFunc, args...)
+}
+
+func (c *migrationClient) ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflowFunc interface{}, args ...interface{}) (client.WorkflowRun, error) {
+	return c.Client.ExecuteWorkflow(ctx, c.route(options.ID, options), workflowFunc, args...)
+}
+
+func (c *migrationClient) SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+	options client.StartWorkflowOptions, workflowFunc interface{}, workflowArgs ...interface{}) (*workflow.Execution, error) {
+	return c.Client.SignalWithStartWorkflow(ctx, workflowID, signalName, signalArg, c.route(workflowID, options), workflowFunc, workflowArgs...)
+}
+
+func (c *migrationClient) SignalWithStartWorkflowAsync(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+	options client.StartWorkflowOptions, workflowFunc interface{}, workflowArgs ...interface{}) (*workflow.ExecutionAsync, error) {
+	return c.Client.SignalWithStartWorkflowAsync(ctx, workflowID, signalName, signalArg, c.route(workflowID, options), workflowFunc, workflowArgs...)
+}
+
+// route moves a start from the old to the new task list according to NewTaskListRatio.
+func (c *migrationClient) route(workflowID string, options client.StartWorkflowOptions) client.StartWorkflowOptions {
+	role := roleNew
+	switch options.TaskList {
+	case c.options.OldTaskList:
+		if c.sample(workflowID) < c.options.NewTaskListRatio {
+			options.TaskList = c.options.NewTaskList
+		} else {
+			role = roleOld
+		}
+	case c.options.NewTaskList:
+	default:
+		return options
+	}
+	c.options.MetricsScope.Tagged(map[string]string{
+		tagTaskList: options.TaskList,
+		tagRole:     role,
+	}).Counter(StartCounter).Inc(1)
+	return options
+}
+
+// sample returns a number in [0, 1) derived from the workflow ID, or a random one if there is no ID.
+func (c *migrationClient) sample(workflowID string) float64 {
+	if workflowID == "" {
+		c.Lock()
+		defer c.Unlock()
+		return c.rand.Float64()
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(workflowID))
+	return float64(h.Sum32()%routingBuckets) / routingBuckets
+}
@@ -0,0 +1,138 @@
+package tasklistmigration_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/internal/common/testlogger"
+	"go.uber.org/cadence/mocks"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/cadence/x/tasklistmigration"
+)
+
+func startedTaskLists(c *mocks.Client) []string {
+	var taskLists []string
+	for _, call := range c.Calls {
+		for _, arg := range call.Arguments {
+			if options, ok := arg.(client.StartWorkflowOptions); ok {
+				taskLists = append(taskLists, options.TaskList)
+			}
+		}
+	}
+	return taskLists
+}
+
+func TestClientRoutesStarts(t *testing.T) {
+	for _, tc := range []struct {
+		ratio    float64
+		taskList string
+		expected string
+	}{
+		{ratio: 0, taskList: "old", expected: "old"},
+		{ratio: 1, taskList: "old", expected: "new"},
+		{ratio: 1, taskList: "new", expected: "new"},
+		{ratio: 1, taskList: "other", expected: "other"},
+	} {
+		t.Run(fmt.Sprintf("%v %v", tc.ratio, tc.taskList), func(t *testing.T) {
+			mockClient := &mocks.Client{}
+			mockClient.On("StartWorkflow", mock.Anything, mock.Anything, "Workflow").Return(&workflow.Execution{}, nil)
+			mockClient.On("SignalWithStartWorkflow", mock.Anything, "wid", "signal", nil, mock.Anything, "Workflow").Return(&workflow.Execution{}, nil)
+			mockClient.On("ExecuteWorkflow", mock.Anything, mock.Anything, "Workflow").Return(nil, nil)
+
+			c, err := tasklistmigration.NewClient(mockClient, tasklistmigration.Options{
+				OldTaskList:      "old",
+				NewTaskList:      "new",
+				NewTaskListRatio: tc.ratio,
+			})
+			require.NoError(t, err)
+
+			options := client.StartWorkflowOptions{ID: "wid", TaskList: tc.taskList}
+			_, err = c.StartWorkflow(context.Background(), options, "Workflow")
+			require.NoError(t, err)
+			_, err = c.SignalWithStartWorkflow(context.Background(), "wid", "signal", nil, options, "Workflow")
+			require.NoError(t, err)
+			_, err = c.ExecuteWorkflow(context.Background(), options, "Workflow")
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{tc.expected, tc.expected, tc.expected}, startedTaskLists(mockClient))
+			assert.Equal(t, tc.taskList, options.TaskList, "options of the caller must not be modified")
+		})
+	}
+}
+
+func TestClientRoutesByWorkflowID(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	mockClient := &mocks.Client{}
+	mockClient.On("StartWorkflow", mock.Anything, mock.Anything, "Workflow").Return(&workflow.Execution{}, nil)
+	c, err := tasklistmigration.NewClient(mockClient, tasklistmigration.Options{
+		OldTaskList:      "old",
+		NewTaskList:      "new",
+		NewTaskListRatio: 0.5,
+		MetricsScope:     scope,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		for attempt := 0; attempt < 2; attempt++ {
+			_, err := c.StartWorkflow(context.Background(), client.StartWorkflowOptions{
+				ID:       fmt.Sprintf("wid-%d", i),
+				TaskList: "old",
+			}, "Workflow")
+			require.NoError(t, err)
+		}
+	}
+
+	taskLists := startedTaskLists(mockClient)
+	for i := 0; i < len(taskLists); i += 2 {
+		assert.Equal(t, taskLists[i], taskLists[i+1], "starts of the same workflow ID must target the same task list")
+	}
+	counters := scope.Snapshot().Counters()
+	oldStarts := counters[tasklistmigration.StartCounter+"+role=old,tasklist=old"].Value()
+	newStarts := counters[tasklistmigration.StartCounter+"+role=new,tasklist=new"].Value()
+	assert.EqualValues(t, 200, oldStarts+newStarts)
+	assert.InDelta(t, 100, newStarts, 40)
+}
+
+func TestOptionsValidation(t *testing.T) {
+	for _, options := range []tasklistmigration.Options{
+		{NewTaskList: "new"},
+		{OldTaskList: "old"},
+		{OldTaskList: "same", NewTaskList: "same"},
+		{OldTaskList: "old", NewTaskList: "new", NewTaskListRatio: 1.5},
+	} {
+		_, err := tasklistmigration.NewClient(&mocks.Client{}, options)
+		assert.Error(t, err, "%+v", options)
+		_, err = tasklistmigration.NewWorker(nil, "domain", options, worker.Options{})
+		assert.Error(t, err, "%+v", options)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	service := workflowservicetest.NewMockClient(gomock.NewController(t))
+	options := worker.Options{
+		Logger:                             testlogger.NewZap(t),
+		MaxConcurrentActivityExecutionSize: 3,
+	}
+	w, err := tasklistmigration.NewWorker(service, "domain", tasklistmigration.Options{
+		OldTaskList: "old",
+		NewTaskList: "new",
+	}, options)
+	require.NoError(t, err)
+	single, err := worker.NewV2(service, "domain", "old", options)
+	require.NoError(t, err)
+
+	w.RegisterWorkflowWithOptions(func(ctx workflow.Context) error { return nil }, workflow.RegisterOptions{Name: "Workflow"})
+	require.Len(t, w.GetRegisteredWorkflows(), 1)
+	assert.Equal(t, "Workflow", w.GetRegisteredWorkflows()[0].WorkflowType().Name)
+	assert.Equal(t, 2*single.(worker.MetricsProvider).Metrics().Activity.TaskSlotsTotal, w.(worker.MetricsProvider).Metrics().Activity.TaskSlotsTotal)
+}
@@ -0,0 +1,197 @@
+package tasktoken
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/client"
+)
+
+const (
+	// the first byte of a wrapped token tells which codec wrapped it
+	hmacVersion   byte = 1
+	aesGCMVersion byte = 2
+
+	minHMACKeyLength = 16
+)
+
+var (
+	// ErrInvalidToken is returned when a wrapped task token was not produced by the codec with any of its keys,
+	// i.e. it is malformed, was tampered with, or was wrapped with an unknown key.
+	ErrInvalidToken = errors.New("invalid task token")
+
+	// ErrEmptyToken is returned when wrapping an empty task token, e.g. outside of an activity.
+	ErrEmptyToken = errors.New("empty task token")
+
+	encoding = base64.RawURLEncoding
+)
+
+// Codec wraps activity task tokens before they are handed to a third party, and unwraps them when they come back.
+// Unwrap fails with ErrInvalidToken for any token which was not returned by Wrap.
+type Codec interface {
+	Wrap(taskToken []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+type hmacCodec struct {
+	keys [][]byte
+}
+
+type aesGCMCodec struct {
+	aeads []cipher.AEAD
+}
+
+// NewHMAC returns a Codec which signs task tokens with HMAC-SHA256. The task token is readable by the third party, but
+// cannot be changed or forged. Tokens are signed with key and verified with key or any of previousKeys, which allows
+// rotating keys while wrapped tokens are outstanding. Keys must be at least 16 bytes long.
+func NewHMAC(key []byte, previousKeys ...[]byte) (Codec, error) {
+	keys := append([][]byte{key}, previousKeys...)
+	for i, k := range keys {
+		if len(k) < minHMACKeyLength {
+			return nil, fmt.Errorf("HMAC key %d is %d bytes long, at least %d bytes are required", i, len(k), minHMACKeyLength)
+		}
+	}
+	return &hmacCodec{keys: keys}, nil
+}
+
+// NewAESGCM returns a Codec which encrypts task tokens with AES-GCM. The task token is neither readable by the third
+// party, nor can it be changed or forged. Tokens are encrypted with key and decrypted with key or any of previousKeys,
+// which allows rotating keys while wrapped tokens are outstanding. Keys must be 16, 24 or 32 bytes long.
+func NewAESGCM(key []byte, previousKeys ...[]byte) (Codec, error) {
+	keys := append([][]byte{key}, previousKeys...)
+	aeads := make([]cipher.AEAD, 0, len(keys))
+	for i, k := range keys {
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, fmt.Errorf("AES key %d: %w", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("AES key %d: %w", i, err)
+		}
+		aeads = append(aeads, aead)
+	}
+	return &aesGCMCodec{aeads: aeads}, nil
+}
+
+func (c *hmacCodec) Wrap(taskToken []byte) ([]byte, error) {
+	if len(taskToken) == 0 {
+		return nil, ErrEmptyToken
+	}
+	wrapped := make([]byte, 0, 1+len(taskToken)+sha256.Size)
+	wrapped = append(wrapped, hmacVersion)
+	wrapped = append(wrapped, taskToken...)
+	return append(wrapped, sign(c.keys[0], wrapped)...), nil
+}
+
+func (c *hmacCodec) Unwrap(wrapped []byte) ([]byte, error) {
+	if len(wrapped) <= 1+sha256.Size || wrapped[0] != hmacVersion {
+		return nil, ErrInvalidToken
+	}
+	signed, mac := wrapped[:len(wrapped)-sha256.Size], wrapped[len(wrapped)-sha256.Size:]
+	for _, key := range c.keys {
+		if hmac.Equal(mac, sign(key, signed)) {
+			return append([]byte(nil), signed[1:]...), nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+func sign(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (c *aesGCMCodec) Wrap(taskToken []byte) ([]byte, error) {
+	if len(taskToken) == 0 {
+		return nil, ErrEmptyToken
+	}
+	aead := c.aeads[0]
+	wrapped := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(taskToken)+aead.Overhead())
+	wrapped[0] = aesGCMVersion
+	nonce := wrapped[1:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(wrapped, nonce, taskToken, wrapped[:1]), nil
+}
+
+func (c *aesGCMCodec) Unwrap(wrapped []byte) ([]byte, error) {
+	if len(wrapped) == 0 || wrapped[0] != aesGCMVersion {
+		return nil, ErrInvalidToken
+	}
+	for _, aead := range c.aeads {
+		if len(wrapped) < 1+aead.NonceSize()+aead.Overhead() {
+			continue
+		}
+		nonce, ciphertext := wrapped[1:1+aead.NonceSize()], wrapped[1+aead.NonceSize():]
+		if taskToken, err := aead.Open(nil, nonce, ciphertext, wrapped[:1]); err == nil {
+			return taskToken, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// WrapToString wraps the task token and encodes it as URL safe base64, e.g. for a callback URL.
+func WrapToString(codec Codec, taskToken []byte) (string, error) {
+	wrapped, err := codec.Wrap(taskToken)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(wrapped), nil
+}
+
+// UnwrapString decodes and unwraps a token returned by WrapToString.
+func UnwrapString(codec Codec, wrapped string) ([]byte, error) {
+	decoded, err := encoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return codec.Unwrap(decoded)
+}
+
+// WrapActivityToken wraps the task token of the activity running in ctx, see WrapToString. It is meant to be called
+// from an activity which completes asynchronously, i.e. returns activity.ErrResultPending.
+func WrapActivityToken(ctx context.Context, codec Codec) (string, error) {
+	return WrapToString(codec, activity.GetInfo(ctx).TaskToken)
+}
+
+// Completer completes activities with wrapped task tokens. Tokens which were not wrapped by its codec are rejected
+// with ErrInvalidToken before the service is called.
+type Completer struct {
+	client client.Client
+	codec  Codec
+}
+
+// NewCompleter returns a Completer using the client to complete activities with tokens wrapped by the codec.
+func NewCompleter(c client.Client, codec Codec) *Completer {
+	return &Completer{client: c, codec: codec}
+}
+
+// CompleteActivity unwraps the token and reports the activity completed, see client.Client.CompleteActivity.
+func (c *Completer) CompleteActivity(ctx context.Context, wrapped string, result interface{}, err error) error {
+	taskToken, unwrapErr := UnwrapString(c.codec, wrapped)
+	if unwrapErr != nil {
+		return unwrapErr
+	}
+	return c.client.CompleteActivity(ctx, taskToken, result, err)
+}
+
+// RecordActivityHeartbeat unwraps the token and records a heartbeat, see client.Client.RecordActivityHeartbeat.
+func (c *Completer) RecordActivityHeartbeat(ctx context.Context, wrapped string, details ...interface{}) error {
+	taskToken, err := UnwrapString(c.codec, wrapped)
+	if err != nil {
+		return err
+	}
+	return c.client.RecordActivityHeartbeat(ctx, taskToken, details...)
+}
@@ -0,0 +1,143 @@
+package tasktoken_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/mocks"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/x/tasktoken"
+)
+
+var (
+	key1 = []byte("0123456789abcdef0123456789abcdef")
+	key2 = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func newCodecs(t *testing.T, key []byte, previousKeys ...[]byte) map[string]tasktoken.Codec {
+	hmacCodec, err := tasktoken.NewHMAC(key, previousKeys...)
+	require.NoError(t, err)
+	aesCodec, err := tasktoken.NewAESGCM(key, previousKeys...)
+	require.NoError(t, err)
+	return map[string]tasktoken.Codec{"hmac": hmacCodec, "aes-gcm": aesCodec}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, codec := range newCodecs(t, key1) {
+		t.Run(name, func(t *testing.T) {
+			wrapped, err := tasktoken.WrapToString(codec, []byte("task-token"))
+			require.NoError(t, err)
+			taskToken, err := tasktoken.UnwrapString(codec, wrapped)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("task-token"), taskToken)
+
+			_, err = codec.Wrap(nil)
+			assert.ErrorIs(t, err, tasktoken.ErrEmptyToken)
+		})
+	}
+}
+
+func TestForgedTokens(t *testing.T) {
+	codecs := newCodecs(t, key1)
+	otherKeyCodecs := newCodecs(t, key2)
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			wrapped, err := codec.Wrap([]byte("task-token"))
+			require.NoError(t, err)
+
+			for i := range wrapped {
+				tampered := append([]byte(nil), wrapped...)
+				tampered[i] ^= 1
+				_, err = codec.Unwrap(tampered)
+				assert.ErrorIs(t, err, tasktoken.ErrInvalidToken, "byte %d flipped", i)
+			}
+			_, err = codec.Unwrap(wrapped[:len(wrapped)-1])
+			assert.ErrorIs(t, err, tasktoken.ErrInvalidToken)
+			_, err = codec.Unwrap([]byte("task-token"))
+			assert.ErrorIs(t, err, tasktoken.ErrInvalidToken, "unwrapped tokens are rejected")
+			_, err = tasktoken.UnwrapString(codec, "not base64!")
+			assert.ErrorIs(t, err, tasktoken.ErrInvalidToken)
+
+			_, err = otherKeyCodecs[name].Unwrap(wrapped)
+			assert.ErrorIs(t, err, tasktoken.ErrInvalidToken, "tokens wrapped with another key are rejected")
+		})
+	}
+
+	wrapped, err := codecs["hmac"].Wrap([]byte("task-token"))
+	require.NoError(t, err)
+	_, err = codecs["aes-gcm"].Unwrap(wrapped)
+	assert.ErrorIs(t, err, tasktoken.ErrInvalidToken, "tokens wrapped by another codec are rejected")
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldCodecs := newCodecs(t, key1)
+	rotatedCodecs := newCodecs(t, key2, key1)
+	for name, codec := range rotatedCodecs {
+		t.Run(name, func(t *testing.T) {
+			wrapped, err := oldCodecs[name].Wrap([]byte("task-token"))
+			require.NoError(t, err)
+			taskToken, err := codec.Unwrap(wrapped)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("task-token"), taskToken)
+
+			wrapped, err = codec.Wrap([]byte("task-token"))
+			require.NoError(t, err)
+			_, err = oldCodecs[name].Unwrap(wrapped)
+			assert.ErrorIs(t, err, tasktoken.ErrInvalidToken, "new tokens are wrapped with the new key")
+		})
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	_, err := tasktoken.NewHMAC([]byte("short"))
+	assert.Error(t, err)
+	_, err = tasktoken.NewHMAC(key1, []byte("short"))
+	assert.Error(t, err)
+	_, err = tasktoken.NewAESGCM([]byte("not an AES key"))
+	assert.Error(t, err)
+}
+
+func TestWrapActivityToken(t *testing.T) {
+	codec, err := tasktoken.NewAESGCM(key1)
+	require.NoError(t, err)
+
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestActivityEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context) (string, error) {
+		return tasktoken.WrapActivityToken(ctx, codec)
+	}, activity.RegisterOptions{Name: "wrap"})
+	value, err := env.ExecuteActivity("wrap")
+	require.NoError(t, err)
+	var wrapped string
+	require.NoError(t, value.Get(&wrapped))
+
+	taskToken, err := tasktoken.UnwrapString(codec, wrapped)
+	require.NoError(t, err)
+	assert.NotEmpty(t, taskToken)
+}
+
+func TestCompleter(t *testing.T) {
+	codec, err := tasktoken.NewHMAC(key1)
+	require.NoError(t, err)
+	mockClient := &mocks.Client{}
+	completer := tasktoken.NewCompleter(mockClient, codec)
+	ctx := context.Background()
+
+	wrapped, err := tasktoken.WrapToString(codec, []byte("task-token"))
+	require.NoError(t, err)
+	mockClient.On("RecordActivityHeartbeat", mock.Anything, []byte("task-token"), "progress").Return(nil).Once()
+	mockClient.On("CompleteActivity", mock.Anything, []byte("task-token"), "done", nil).Return(nil).Once()
+	assert.NoError(t, completer.RecordActivityHeartbeat(ctx, wrapped, "progress"))
+	assert.NoError(t, completer.CompleteActivity(ctx, wrapped, "done", nil))
+
+	forged, err := tasktoken.WrapToString(newCodecs(t, key2)["hmac"], []byte("task-token"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, completer.CompleteActivity(ctx, forged, "done", nil), tasktoken.ErrInvalidToken)
+	assert.ErrorIs(t, completer.RecordActivityHeartbeat(ctx, forged), tasktoken.ErrInvalidToken)
+	mockClient.AssertExpectations(t)
+}
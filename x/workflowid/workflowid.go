@@ -0,0 +1,143 @@
+package workflowid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/workflow"
+)
+
+const separator = ":"
+
+// Partition is the length of the time window covered by a date partitioned workflow ID.
+type Partition int
+
+const (
+	// Hourly partitions IDs by hour, e.g. "report:eu:2026-10-16T13".
+	Hourly Partition = iota + 1
+	// Daily partitions IDs by day, e.g. "report:eu:2026-10-16".
+	Daily
+	// Monthly partitions IDs by month, e.g. "report:eu:2026-10".
+	Monthly
+)
+
+// Collision decides what Start does when an execution with the same workflow ID already exists.
+// There is no default: the right choice depends on the ID scheme, and a wrong one either drops work or runs it twice.
+type Collision int
+
+const (
+	collisionUnspecified Collision = iota
+
+	// RejectDuplicate allows a single execution per workflow ID, ever. Starting it again fails with
+	// *shared.WorkflowExecutionAlreadyStartedError, even after the first execution has failed.
+	// It fits IDs derived from a business key which must be processed exactly once, e.g. a payment ID.
+	RejectDuplicate
+
+	// RetryFailed allows starting a new execution only when the previous one did not complete successfully,
+	// i.e. it failed, timed out, was canceled or terminated. This is the server default.
+	// It fits IDs derived from a business key where a failed attempt may be retried by starting it again.
+	RetryFailed
+
+	// Restart allows starting a new execution once the previous one has closed, no matter how it closed.
+	// It fits recurring work on the same key, e.g. date partitioned or sharded IDs.
+	Restart
+
+	// TerminateRunning terminates the running execution, if any, and starts a new one.
+	// It fits workflows where only the latest request matters, e.g. recomputing a derived view.
+	TerminateRunning
+
+	// JoinRunning returns the running execution, if any, instead of starting a new one, and otherwise starts a new
+	// execution. It fits deduplicating concurrent requests for the same key.
+	JoinRunning
+)
+
+// String returns the name of the collision policy.
+func (c Collision) String() string {
+	switch c {
+	case RejectDuplicate:
+		return "RejectDuplicate"
+	case RetryFailed:
+		return "RetryFailed"
+	case Restart:
+		return "Restart"
+	case TerminateRunning:
+		return "TerminateRunning"
+	case JoinRunning:
+		return "JoinRunning"
+	}
+	return fmt.Sprintf("Collision(%d)", int(c))
+}
+
+// Prefixed returns a workflow ID made of prefix and a business key, e.g. "payment:4711".
+func Prefixed(prefix, key string) string {
+	return prefix + separator + key
+}
+
+// Sharded returns the workflow ID of the bucket key hashes to, e.g. "inventory:shard-7". It spreads keys over a fixed
+// number of long running workflows, so the same key always maps to the same workflow as long as buckets is unchanged.
+// A bucket count less than 1 is treated as 1.
+func Sharded(prefix, key string, buckets int) string {
+	if buckets < 1 {
+		buckets = 1
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return fmt.Sprintf("%s%sshard-%d", prefix, separator, h.Sum32()%uint32(buckets))
+}
+
+// DatePartitioned returns a workflow ID made of prefix, a business key and the UTC time window containing t,
+// e.g. "report:eu:2026-10-16". It allows one execution per key and window.
+func DatePartitioned(prefix, key string, t time.Time, partition Partition) string {
+	var layout string
+	switch partition {
+	case Hourly:
+		layout = "2006-01-02T15"
+	case Monthly:
+		layout = "2006-01"
+	default:
+		layout = "2006-01-02"
+	}
+	return Prefixed(prefix, key) + separator + t.UTC().Format(layout)
+}
+
+// Start starts a workflow execution with options.ID, applying the workflow ID reuse policy of collision.
+// The WorkflowIDReusePolicy of options is ignored.
+//
+// With JoinRunning the execution returned may have been started by an earlier call.
+func Start(
+	ctx context.Context,
+	c client.Client,
+	collision Collision,
+	options client.StartWorkflowOptions,
+	workflowFunc interface{},
+	args ...interface{},
+) (*workflow.Execution, error) {
+	if options.ID == "" {
+		return nil, errors.New("workflowid: workflow ID is required")
+	}
+	switch collision {
+	case RejectDuplicate:
+		options.WorkflowIDReusePolicy = client.WorkflowIDReusePolicyRejectDuplicate
+	case RetryFailed:
+		options.WorkflowIDReusePolicy = client.WorkflowIDReusePolicyAllowDuplicateFailedOnly
+	case Restart, JoinRunning:
+		options.WorkflowIDReusePolicy = client.WorkflowIDReusePolicyAllowDuplicate
+	case TerminateRunning:
+		options.WorkflowIDReusePolicy = client.WorkflowIDReusePolicyTerminateIfRunning
+	default:
+		return nil, fmt.Errorf("workflowid: collision policy is required, got %v", collision)
+	}
+
+	execution, err := c.StartWorkflow(ctx, options, workflowFunc, args...)
+	var alreadyStarted *shared.WorkflowExecutionAlreadyStartedError
+	if collision == JoinRunning && errors.As(err, &alreadyStarted) {
+		// with AllowDuplicate the server only rejects the start while an execution is running
+		return &workflow.Execution{ID: options.ID, RunID: alreadyStarted.GetRunId()}, nil
+	}
+	return execution, err
+}
@@ -0,0 +1,100 @@
+package workflowid_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/mocks"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/cadence/x/workflowid"
+)
+
+func TestPrefixed(t *testing.T) {
+	assert.Equal(t, "payment:4711", workflowid.Prefixed("payment", "4711"))
+}
+
+func TestSharded(t *testing.T) {
+	id := workflowid.Sharded("inventory", "sku-1", 16)
+	assert.Regexp(t, `^inventory:shard-([0-9]|1[0-5])$`, id)
+	assert.Equal(t, id, workflowid.Sharded("inventory", "sku-1", 16), "the same key must map to the same bucket")
+	assert.Equal(t, "inventory:shard-0", workflowid.Sharded("inventory", "sku-1", 0))
+
+	buckets := map[string]bool{}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		buckets[workflowid.Sharded("inventory", key, 4)] = true
+	}
+	assert.Greater(t, len(buckets), 1, "keys should be spread over buckets")
+}
+
+func TestDatePartitioned(t *testing.T) {
+	ts := time.Date(2026, 10, 16, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	assert.Equal(t, "report:eu:2026-10-17T01", workflowid.DatePartitioned("report", "eu", ts, workflowid.Hourly))
+	assert.Equal(t, "report:eu:2026-10-17", workflowid.DatePartitioned("report", "eu", ts, workflowid.Daily))
+	assert.Equal(t, "report:eu:2026-10", workflowid.DatePartitioned("report", "eu", ts, workflowid.Monthly))
+}
+
+func TestStartReusePolicy(t *testing.T) {
+	for collision, policy := range map[workflowid.Collision]client.WorkflowIDReusePolicy{
+		workflowid.RejectDuplicate:  client.WorkflowIDReusePolicyRejectDuplicate,
+		workflowid.RetryFailed:      client.WorkflowIDReusePolicyAllowDuplicateFailedOnly,
+		workflowid.Restart:          client.WorkflowIDReusePolicyAllowDuplicate,
+		workflowid.TerminateRunning: client.WorkflowIDReusePolicyTerminateIfRunning,
+		workflowid.JoinRunning:      client.WorkflowIDReusePolicyAllowDuplicate,
+	} {
+		t.Run(collision.String(), func(t *testing.T) {
+			c := &mocks.Client{}
+			execution := &workflow.Execution{ID: "payment:4711", RunID: "run"}
+			c.On("StartWorkflow", mock.Anything, client.StartWorkflowOptions{
+				ID:                    "payment:4711",
+				TaskList:              "tl",
+				WorkflowIDReusePolicy: policy,
+			}, "PaymentWorkflow", 1).Return(execution, nil).Once()
+
+			result, err := workflowid.Start(context.Background(), c, collision, client.StartWorkflowOptions{
+				ID:                    "payment:4711",
+				TaskList:              "tl",
+				WorkflowIDReusePolicy: client.WorkflowIDReusePolicyRejectDuplicate,
+			}, "PaymentWorkflow", 1)
+			require.NoError(t, err)
+			assert.Equal(t, execution, result)
+			c.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStartJoinRunning(t *testing.T) {
+	runID := "running"
+	alreadyStarted := &shared.WorkflowExecutionAlreadyStartedError{RunId: &runID}
+
+	c := &mocks.Client{}
+	c.On("StartWorkflow", mock.Anything, mock.Anything, "PaymentWorkflow").Return(nil, alreadyStarted).Twice()
+
+	result, err := workflowid.Start(context.Background(), c, workflowid.JoinRunning,
+		client.StartWorkflowOptions{ID: "payment:4711"}, "PaymentWorkflow")
+	require.NoError(t, err)
+	assert.Equal(t, &workflow.Execution{ID: "payment:4711", RunID: "running"}, result)
+
+	_, err = workflowid.Start(context.Background(), c, workflowid.Restart,
+		client.StartWorkflowOptions{ID: "payment:4711"}, "PaymentWorkflow")
+	assert.True(t, errors.As(err, &alreadyStarted))
+	c.AssertExpectations(t)
+}
+
+func TestStartValidation(t *testing.T) {
+	c := &mocks.Client{}
+	_, err := workflowid.Start(context.Background(), c, workflowid.Restart, client.StartWorkflowOptions{}, "PaymentWorkflow")
+	assert.ErrorContains(t, err, "workflow ID is required")
+
+	var unspecified workflowid.Collision
+	_, err = workflowid.Start(context.Background(), c, unspecified, client.StartWorkflowOptions{ID: "payment:4711"}, "PaymentWorkflow")
+	assert.ErrorContains(t, err, "collision policy is required")
+	c.AssertExpectations(t)
+}